@@ -0,0 +1,213 @@
+package hdfs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AclScope tells the entries of the access ACL of a path, checked on access
+// to it, from those of the default ACL of a directory, inherited by the
+// files and directories created in it.
+type AclScope int
+
+const (
+	AclAccess AclScope = iota
+	AclDefault
+)
+
+func (s AclScope) String() string {
+	if s == AclDefault {
+		return "default"
+	}
+	return "access"
+}
+
+// AclType is the kind of principal an AclEntry grants permission to.
+type AclType int
+
+const (
+	AclUser AclType = iota
+	AclGroup
+	AclMask
+	AclOther
+)
+
+var aclTypes = []string{"user", "group", "mask", "other"}
+
+func (t AclType) String() string {
+	if t < 0 || int(t) >= len(aclTypes) {
+		return fmt.Sprintf("AclType(%d)", int(t))
+	}
+	return aclTypes[t]
+}
+
+// AclEntry is an entry of the ACL of a path, granting the permission bits
+// Perm, read 4, write 2 and execute 1, to the user or group Name. Entries
+// without a name are those of the owner, the group of the path and others,
+// and the mask bounding the permission of every entry but those of the
+// owner and others.
+type AclEntry struct {
+	Scope AclScope
+	Type  AclType
+	Name  string
+	Perm  int16
+}
+
+// String formats e as in an ACL spec, such as "default:user:alice:r-x".
+func (e AclEntry) String() string {
+	var b strings.Builder
+	if e.Scope == AclDefault {
+		b.WriteString("default:")
+	}
+	b.WriteString(e.Type.String())
+	b.WriteByte(':')
+	b.WriteString(e.Name)
+	b.WriteByte(':')
+	b.WriteString(permSymbol(e.Perm))
+	return b.String()
+}
+
+// permSymbol formats the permission bits perm as rwx.
+func permSymbol(perm int16) string {
+	b := []byte("---")
+	for i, c := range "rwx" {
+		if perm&(4>>i) != 0 {
+			b[i] = byte(c)
+		}
+	}
+	return string(b)
+}
+
+// ParseAclSpec parses a comma-separated list of ACL entries, as given to
+// hdfs dfs -setfacl: [default:]<user|group|mask|other>:[name][:perm], the
+// permission as rwx with - for missing bits. Entries without a permission,
+// as given to RemoveAclEntries, get none.
+func ParseAclSpec(spec string) ([]AclEntry, error) {
+	var entries []AclEntry
+	for _, s := range strings.Split(spec, ",") {
+		e, err := parseAclEntry(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseAclEntry(s string) (AclEntry, error) {
+	var e AclEntry
+	bad := fmt.Errorf("hdfs: invalid ACL entry %q", s)
+	fields := strings.Split(s, ":")
+	if fields[0] == "default" {
+		e.Scope, fields = AclDefault, fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 3 {
+		return e, bad
+	}
+	t := -1
+	for i, name := range aclTypes {
+		if fields[0] == name {
+			t = i
+		}
+	}
+	if t < 0 {
+		return e, bad
+	}
+	e.Type = AclType(t)
+	if len(fields) > 1 {
+		e.Name = fields[1]
+	}
+	if e.Name != "" && (e.Type == AclMask || e.Type == AclOther) {
+		return e, bad
+	}
+	if len(fields) == 3 && fields[2] != "" {
+		p := fields[2]
+		if len(p) != 3 {
+			return e, bad
+		}
+		for i, c := range "rwx" {
+			switch p[i] {
+			case byte(c):
+				e.Perm |= 4 >> i
+			case '-':
+			default:
+				return e, bad
+			}
+		}
+	}
+	return e, nil
+}
+
+// FormatAclSpec formats entries as an ACL spec that ParseAclSpec parses.
+func FormatAclSpec(entries []AclEntry) string {
+	specs := make([]string, len(entries))
+	for i, e := range entries {
+		specs[i] = e.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+// SortAcl sorts entries in the order HDFS keeps them: access entries
+// first, then by type, the entry without a name first, then by name.
+func SortAcl(entries []AclEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case a.Scope != b.Scope:
+			return a.Scope < b.Scope
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		case (a.Name == "") != (b.Name == ""):
+			return a.Name == ""
+		}
+		return a.Name < b.Name
+	})
+}
+
+// AclStatus is the ACL of a path. Entries holds its entries but for those
+// its permission holds: the owner, others and, if it has no other access
+// entries, the group. The group bits of Permission are the mask of the
+// ACL if it has access entries.
+type AclStatus struct {
+	Owner      string
+	Group      string
+	Sticky     bool
+	Permission int16
+	Entries    []AclEntry
+}
+
+// Acl returns the whole ACL of the path, with the entries its permission
+// holds, sorted as SortAcl does, as hdfs dfs -getfacl prints it.
+func (s *AclStatus) Acl() []AclEntry {
+	perm := s.Permission
+	acl := []AclEntry{{Type: AclUser, Perm: perm >> 6 & 7}, {Type: AclOther, Perm: perm & 7}}
+	access := false
+	for _, e := range s.Entries {
+		if e.Scope == AclAccess {
+			access = true
+		}
+	}
+	if access {
+		acl = append(acl, AclEntry{Type: AclMask, Perm: perm >> 3 & 7})
+	} else {
+		acl = append(acl, AclEntry{Type: AclGroup, Perm: perm >> 3 & 7})
+	}
+	acl = append(acl, s.Entries...)
+	SortAcl(acl)
+	return acl
+}
+
+// Effective returns the permission e grants once bounded by the mask of
+// its scope, as entries other than those of the owner and others are.
+func (s *AclStatus) Effective(e AclEntry) int16 {
+	if e.Type == AclMask || e.Type == AclOther || e.Type == AclUser && e.Name == "" {
+		return e.Perm
+	}
+	for _, m := range s.Acl() {
+		if m.Scope == e.Scope && m.Type == AclMask {
+			return e.Perm & m.Perm
+		}
+	}
+	return e.Perm
+}
@@ -0,0 +1,50 @@
+package hdfs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+func TestParseAclSpec(t *testing.T) {
+	entries, err := hdfs.ParseAclSpec("user:alice:rwx, default:group::r-x,mask::-w-,other::")
+	if err != nil {
+		t.Fatalf("Error on ParseAclSpec: %v\n", err)
+	}
+	want := []hdfs.AclEntry{
+		{Scope: hdfs.AclAccess, Type: hdfs.AclUser, Name: "alice", Perm: 7},
+		{Scope: hdfs.AclDefault, Type: hdfs.AclGroup, Perm: 5},
+		{Scope: hdfs.AclAccess, Type: hdfs.AclMask, Perm: 2},
+		{Scope: hdfs.AclAccess, Type: hdfs.AclOther},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseAclSpec: got %v, want %v\n", entries, want)
+	}
+	if spec := hdfs.FormatAclSpec(entries); spec != "user:alice:rwx,default:group::r-x,mask::-w-,other::---" {
+		t.Errorf("FormatAclSpec: got %s\n", spec)
+	}
+	for _, spec := range []string{"", "owner::rwx", "user:alice:rwxr", "user:alice:rwz", "mask:alice:rwx", "other:x:r--", "default:", "user:a:rwx:x"} {
+		if _, err := hdfs.ParseAclSpec(spec); err == nil {
+			t.Errorf("ParseAclSpec %q: got no error\n", spec)
+		}
+	}
+}
+
+func TestAclStatus(t *testing.T) {
+	entries, _ := hdfs.ParseAclSpec("group::rwx,user:alice:rw-,default:user::rwx")
+	s := &hdfs.AclStatus{Permission: 0741, Entries: entries}
+	acl := s.Acl()
+	if spec := hdfs.FormatAclSpec(acl); spec != "user::rwx,user:alice:rw-,group::rwx,mask::r--,other::--x,default:user::rwx" {
+		t.Errorf("Acl: got %s\n", spec)
+	}
+	for i, want := range []int16{7, 4, 4, 4, 1, 7} {
+		if got := s.Effective(acl[i]); got != want {
+			t.Errorf("Effective of %v: got %o, want %o\n", acl[i], got, want)
+		}
+	}
+	s = &hdfs.AclStatus{Permission: 0750}
+	if spec := hdfs.FormatAclSpec(s.Acl()); spec != "user::rwx,group::r-x,other::---" {
+		t.Errorf("Acl of a minimal ACL: got %s\n", spec)
+	}
+}
@@ -0,0 +1,321 @@
+package hdfs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// FileChecksum is the checksum HDFS computes for the content of a file from
+// the checksums of its blocks. Files with the same content, block size and
+// checksum settings have the same FileChecksum.
+type FileChecksum struct {
+	Algorithm string // such as "MD5-of-0MD5-of-512CRC32C"
+	Bytes     []byte
+}
+
+// Combine modes of the checksums of blocks into a FileChecksum, the values
+// of dfs.checksum.combine.mode.
+const (
+	// ChecksumMD5MD5CRC is the MD5 of the MD5s of the chunk CRCs of each
+	// block, which depends on the block size and bytes per checksum. Its
+	// Bytes are the bytes per checksum, as 4 big-endian bytes, the
+	// checksums per block, as 8, and the MD5.
+	ChecksumMD5MD5CRC = "MD5MD5CRC"
+	// ChecksumCompositeCRC is the CRC of the whole file, as 4 big-endian
+	// bytes, composed from the CRCs of its blocks. It depends on nothing
+	// but the content and CRC type, so that it compares across file
+	// systems and block sizes.
+	ChecksumCompositeCRC = "COMPOSITE_CRC"
+)
+
+const defaultBytesPerChecksum = 512
+
+// ChecksumOptions are the settings a FileChecksum is computed with.
+type ChecksumOptions struct {
+	Mode             string // ChecksumMD5MD5CRC, the default, or ChecksumCompositeCRC
+	Type             string // "CRC32C", the default, or "CRC32"
+	BytesPerChecksum int    // 512 if 0
+	BlockSize        int64  // block size of the file, needed by ChecksumMD5MD5CRC
+}
+
+var crcTables = map[string]*crc32.Table{
+	"CRC32":  crc32.IEEETable,
+	"CRC32C": crc32.MakeTable(crc32.Castagnoli),
+}
+
+// withDefaults returns o with its defaults set, or an error if o is
+// invalid.
+func (o ChecksumOptions) withDefaults() (ChecksumOptions, error) {
+	if o.Mode == "" {
+		o.Mode = ChecksumMD5MD5CRC
+	}
+	if o.Type == "" {
+		o.Type = "CRC32C"
+	}
+	if o.BytesPerChecksum == 0 {
+		o.BytesPerChecksum = defaultBytesPerChecksum
+	}
+	switch {
+	case o.Mode != ChecksumMD5MD5CRC && o.Mode != ChecksumCompositeCRC:
+		return o, fmt.Errorf("hdfs: unknown checksum combine mode %q", o.Mode)
+	case crcTables[o.Type] == nil:
+		return o, fmt.Errorf("hdfs: unknown checksum type %q", o.Type)
+	case o.BytesPerChecksum < 0:
+		return o, fmt.Errorf("hdfs: invalid bytes per checksum %d", o.BytesPerChecksum)
+	case o.Mode == ChecksumMD5MD5CRC && o.BlockSize <= 0:
+		return o, errors.New("hdfs: block size needed for an MD5MD5CRC checksum")
+	}
+	return o, nil
+}
+
+// Options returns the options c was computed with, but for the block size,
+// which c does not record. The BytesPerChecksum of a composite CRC, which
+// does not depend on it, are left 0.
+func (c *FileChecksum) Options() (ChecksumOptions, error) {
+	if typ := strings.TrimPrefix(c.Algorithm, "COMPOSITE-"); typ != c.Algorithm && crcTables[typ] != nil {
+		return ChecksumOptions{Mode: ChecksumCompositeCRC, Type: typ}, nil
+	}
+	var crcPerBlock int64
+	var bpc int
+	var typ string
+	if _, err := fmt.Sscanf(c.Algorithm, "MD5-of-%dMD5-of-%d%s", &crcPerBlock, &bpc, &typ); err != nil || crcTables[typ] == nil {
+		return ChecksumOptions{}, fmt.Errorf("hdfs: unknown checksum algorithm %q", c.Algorithm)
+	}
+	return ChecksumOptions{Mode: ChecksumMD5MD5CRC, Type: typ, BytesPerChecksum: bpc}, nil
+}
+
+// Equal reports whether c and d are the same checksum.
+func (c *FileChecksum) Equal(d *FileChecksum) bool {
+	return c.Algorithm == d.Algorithm && bytes.Equal(c.Bytes, d.Bytes)
+}
+
+// String returns the algorithm and the hexadecimal bytes of c, as hadoop
+// fs -checksum prints them.
+func (c *FileChecksum) String() string {
+	return c.Algorithm + "\t" + hex.EncodeToString(c.Bytes)
+}
+
+// ComputeChecksum returns the FileChecksum HDFS reports for a file written
+// with opts holding the content of r, such as a local copy of the file, so
+// that the copies can be compared without reading the file from HDFS.
+func ComputeChecksum(r io.Reader, opts ChecksumOptions) (*FileChecksum, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	blockSize := opts.BlockSize
+	if opts.Mode == ChecksumCompositeCRC {
+		blockSize = 1 << 62 // blocks do not matter
+	}
+	table := crcTables[opts.Type]
+	chunk := make([]byte, opts.BytesPerChecksum)
+	b := newChecksumBuilder(opts.Mode)
+	for {
+		var length int64
+		crc := uint32(0)
+		h := md5.New()
+		for length < blockSize {
+			n := int64(len(chunk))
+			if n > blockSize-length {
+				n = blockSize - length
+			}
+			m, err := io.ReadFull(r, chunk[:n])
+			if m > 0 {
+				sum := crc32.Checksum(chunk[:m], table)
+				crc = crc32.Update(crc, table, chunk[:m])
+				h.Write(binary.BigEndian.AppendUint32(nil, sum))
+				length += int64(m)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if length == 0 {
+			return b.checksum(), nil
+		}
+		crcPerBlock := (length + int64(opts.BytesPerChecksum) - 1) / int64(opts.BytesPerChecksum)
+		sum := h.Sum(nil)
+		if opts.Mode == ChecksumCompositeCRC {
+			sum = binary.BigEndian.AppendUint32(nil, crc)
+		}
+		if err := b.add(opts.Type, opts.BytesPerChecksum, crcPerBlock, sum, length); err != nil {
+			return nil, err
+		}
+		if length < blockSize {
+			return b.checksum(), nil
+		}
+	}
+}
+
+// VerifyChecksum checks that the file path holds the content of r, such
+// as the local file it was copied from, comparing its checksum with the one
+// computed for r with the same settings. It fails with ErrChecksum if they
+// differ, and with ErrUnsupported on FileSystems that are not Checksummers.
+func VerifyChecksum(fsys FileSystem, path string, r io.Reader) error {
+	c, ok := fsys.(Checksummer)
+	if !ok {
+		return &PathError{"checksum", path, ErrUnsupported}
+	}
+	info, err := fsys.GetPathInfo(path)
+	if err != nil {
+		return err
+	}
+	remote, err := c.GetFileChecksum(path)
+	if err != nil {
+		return err
+	}
+	opts, err := remote.Options()
+	if err != nil {
+		return &PathError{"checksum", path, err}
+	}
+	opts.BlockSize = info.BlockSize
+	local, err := ComputeChecksum(r, opts)
+	if err != nil {
+		return &PathError{"checksum", path, err}
+	}
+	if !local.Equal(remote) {
+		return &PathError{"checksum", path, ErrChecksum}
+	}
+	return nil
+}
+
+// checksumBuilder combines the checksums of the blocks of a file, in
+// order, into its FileChecksum, as the HDFS client does.
+type checksumBuilder struct {
+	mode        string
+	typ         string
+	bpc         int
+	crcPerBlock int64
+	blocks      int
+	md5         hash.Hash // of the MD5s of the blocks
+	crc         uint32
+}
+
+func newChecksumBuilder(mode string) *checksumBuilder {
+	return &checksumBuilder{mode: mode, md5: md5.New()}
+}
+
+// add adds the checksum of the next block, of length bytes: its MD5 or
+// CRC, as 4 big-endian bytes, depending on the mode.
+func (b *checksumBuilder) add(typ string, bpc int, crcPerBlock int64, sum []byte, length int64) error {
+	if b.blocks == 0 {
+		b.typ, b.bpc, b.crcPerBlock = typ, bpc, crcPerBlock
+	} else if typ != b.typ {
+		return fmt.Errorf("hdfs: blocks with both %s and %s checksums", b.typ, typ)
+	}
+	b.blocks++
+	if b.mode == ChecksumCompositeCRC {
+		if len(sum) != 4 {
+			return fmt.Errorf("hdfs: malformed block CRC of %d bytes", len(sum))
+		}
+		crc := binary.BigEndian.Uint32(sum)
+		if b.blocks == 1 {
+			b.crc = crc
+		} else {
+			b.crc = crcCombine(crcPolys[typ], b.crc, crc, length)
+		}
+		return nil
+	}
+	if len(sum) != md5.Size {
+		return fmt.Errorf("hdfs: malformed block MD5 of %d bytes", len(sum))
+	}
+	b.md5.Write(sum)
+	return nil
+}
+
+func (b *checksumBuilder) checksum() *FileChecksum {
+	if b.blocks == 0 {
+		// what HDFS has always answered for files without blocks
+		sum := md5.Sum(make([]byte, 32))
+		return &FileChecksum{Algorithm: "MD5-of-0MD5-of-0CRC32", Bytes: append(make([]byte, 12), sum[:]...)}
+	}
+	if b.mode == ChecksumCompositeCRC {
+		return &FileChecksum{Algorithm: "COMPOSITE-" + b.typ, Bytes: binary.BigEndian.AppendUint32(nil, b.crc)}
+	}
+	crcPerBlock := b.crcPerBlock
+	if b.blocks == 1 {
+		crcPerBlock = 0
+	}
+	// the HDFS client hashes the whole buffer the block MD5s were written
+	// to: 32 bytes, doubled as it fills, zeros after the MD5s
+	size := 32
+	for size < b.blocks*md5.Size {
+		size *= 2
+	}
+	b.md5.Write(make([]byte, size-b.blocks*md5.Size))
+	buf := binary.BigEndian.AppendUint32(nil, uint32(b.bpc))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(crcPerBlock))
+	return &FileChecksum{
+		Algorithm: fmt.Sprintf("MD5-of-%dMD5-of-%d%s", crcPerBlock, b.bpc, b.typ),
+		Bytes:     b.md5.Sum(buf),
+	}
+}
+
+// crcPolys are the reversed polynomials of the CRC types.
+var crcPolys = map[string]uint32{
+	"CRC32":  crc32.IEEE,
+	"CRC32C": crc32.Castagnoli,
+}
+
+// crcCombine returns the CRC of the concatenation of two byte strings
+// from their CRCs, crc1 and crc2, and the length of the second, as zlib's
+// crc32_combine does.
+func crcCombine(poly, crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1
+	}
+	// odd is the operator appending a zero bit, even the one appending two
+	var even, odd [32]uint32
+	odd[0] = poly
+	for n, row := 1, uint32(1); n < 32; n, row = n+1, row<<1 {
+		odd[n] = row
+	}
+	gf2Square(&even, &odd)
+	gf2Square(&odd, &even)
+	// append len2 zero bytes to crc1, squaring the operator for every bit
+	// of len2
+	for {
+		gf2Square(&even, &odd)
+		if len2&1 != 0 {
+			crc1 = gf2Times(&even, crc1)
+		}
+		if len2 >>= 1; len2 == 0 {
+			break
+		}
+		gf2Square(&odd, &even)
+		if len2&1 != 0 {
+			crc1 = gf2Times(&odd, crc1)
+		}
+		if len2 >>= 1; len2 == 0 {
+			break
+		}
+	}
+	return crc1 ^ crc2
+}
+
+func gf2Times(mat *[32]uint32, vec uint32) uint32 {
+	var sum uint32
+	for i := 0; vec != 0; i, vec = i+1, vec>>1 {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+	}
+	return sum
+}
+
+func gf2Square(square, mat *[32]uint32) {
+	for n := range mat {
+		square[n] = gf2Times(mat, mat[n])
+	}
+}
@@ -0,0 +1,154 @@
+package hdfs_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func TestComputeChecksum(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 100) // 1600 bytes
+	table := crc32.MakeTable(crc32.Castagnoli)
+
+	// MD5 of the MD5s of the chunk CRCs of blocks of 1024 bytes
+	blockMD5 := func(block []byte) []byte {
+		h := md5.New()
+		for len(block) > 0 {
+			n := 512
+			if n > len(block) {
+				n = len(block)
+			}
+			h.Write(binary.BigEndian.AppendUint32(nil, crc32.Checksum(block[:n], table)))
+			block = block[n:]
+		}
+		return h.Sum(nil)
+	}
+	sum := md5.Sum(append(blockMD5(data[:1024]), blockMD5(data[1024:])...))
+	want := append([]byte{0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 2}, sum[:]...)
+	c, err := hdfs.ComputeChecksum(bytes.NewReader(data), hdfs.ChecksumOptions{BlockSize: 1024})
+	if err != nil || c.Algorithm != "MD5-of-2MD5-of-512CRC32C" || !bytes.Equal(c.Bytes, want) {
+		t.Errorf("MD5MD5CRC: got %v, %v\n", c, err)
+	}
+
+	// a single block records no CRCs per block
+	c, err = hdfs.ComputeChecksum(bytes.NewReader(data), hdfs.ChecksumOptions{BlockSize: 4096, BytesPerChecksum: 1024, Type: "CRC32"})
+	if err != nil || c.Algorithm != "MD5-of-0MD5-of-1024CRC32" {
+		t.Errorf("MD5MD5CRC of one block: got %v, %v\n", c, err)
+	}
+
+	// the composite CRC is the CRC of the whole content
+	for _, blockSize := range []int64{0, 100, 1024} {
+		c, err = hdfs.ComputeChecksum(bytes.NewReader(data), hdfs.ChecksumOptions{Mode: hdfs.ChecksumCompositeCRC, BlockSize: blockSize})
+		if err != nil || c.Algorithm != "COMPOSITE-CRC32C" || binary.BigEndian.Uint32(c.Bytes) != crc32.Checksum(data, table) {
+			t.Errorf("COMPOSITE_CRC with blocks of %d: got %v, %v\n", blockSize, c, err)
+		}
+	}
+
+	// the file MD5 covers the block MD5s padded with zeros to the buffer
+	// the HDFS client writes them to: 32 bytes, doubled as needed
+	data = bytes.Repeat([]byte("0123456789abcdef"), 160) // 2560 bytes
+	for _, tt := range []struct {
+		blockSize int64
+		want      string
+	}{
+		{128 << 20, "MD5-of-0MD5-of-512CRC32C\t000002000000000000000000bab4334d7caa0dc3db41da66bded0733"},
+		{1024, "MD5-of-2MD5-of-512CRC32C\t0000020000000000000000024343f727b3f72d3a2d20f3edf0a30e86"},
+	} {
+		c, err = hdfs.ComputeChecksum(bytes.NewReader(data), hdfs.ChecksumOptions{BlockSize: tt.blockSize})
+		if err != nil || c.String() != tt.want {
+			t.Errorf("MD5MD5CRC with blocks of %d: got %v, %v, want %s\n", tt.blockSize, c, err, tt.want)
+		}
+	}
+
+	// what HDFS answers for empty files, whatever the settings
+	c, err = hdfs.ComputeChecksum(strings.NewReader(""), hdfs.ChecksumOptions{Mode: hdfs.ChecksumCompositeCRC})
+	if err != nil || c.String() != "MD5-of-0MD5-of-0CRC32\t"+strings.Repeat("0", 24)+"70bc8f4b72a86921468bf8e8441dce51" {
+		t.Errorf("Checksum of no data: got %v, %v\n", c, err)
+	}
+
+	for _, opts := range []hdfs.ChecksumOptions{
+		{},
+		{BlockSize: 1024, Mode: "MD5"},
+		{BlockSize: 1024, Type: "ADLER32"},
+		{BlockSize: 1024, BytesPerChecksum: -1},
+	} {
+		if _, err := hdfs.ComputeChecksum(strings.NewReader("x"), opts); err == nil {
+			t.Errorf("ComputeChecksum with %+v: no error\n", opts)
+		}
+	}
+}
+
+func TestChecksumOptions(t *testing.T) {
+	for _, tt := range []struct {
+		algorithm string
+		want      hdfs.ChecksumOptions
+	}{
+		{"MD5-of-262144MD5-of-512CRC32C", hdfs.ChecksumOptions{Mode: hdfs.ChecksumMD5MD5CRC, Type: "CRC32C", BytesPerChecksum: 512}},
+		{"MD5-of-0MD5-of-0CRC32", hdfs.ChecksumOptions{Mode: hdfs.ChecksumMD5MD5CRC, Type: "CRC32"}},
+		{"COMPOSITE-CRC32", hdfs.ChecksumOptions{Mode: hdfs.ChecksumCompositeCRC, Type: "CRC32"}},
+	} {
+		c := &hdfs.FileChecksum{Algorithm: tt.algorithm}
+		if got, err := c.Options(); err != nil || got != tt.want {
+			t.Errorf("Options of %s: got %+v, %v\n", tt.algorithm, got, err)
+		}
+	}
+	for _, algorithm := range []string{"", "COMPOSITE-MD5", "MD5-of-0MD5-of-512NULL", "SHA-256"} {
+		c := &hdfs.FileChecksum{Algorithm: algorithm}
+		if _, err := c.Options(); err == nil {
+			t.Errorf("Options of %q: no error\n", algorithm)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	data := strings.Repeat("some data ", 1000)
+	put(t, m, "/small-blocks", data, 4096)
+	put(t, m, "/empty", "", 0)
+
+	for _, p := range []string{"/small-blocks", "/empty"} {
+		info, _ := m.GetPathInfo(p)
+		local, err := hdfs.ComputeChecksum(strings.NewReader(seen(t, m, p)), hdfs.ChecksumOptions{BlockSize: info.BlockSize})
+		if err != nil {
+			t.Fatalf("Error on ComputeChecksum of %s: %v\n", p, err)
+		}
+		if remote, err := m.GetFileChecksum(p); err != nil || !remote.Equal(local) {
+			t.Errorf("GetFileChecksum of %s: got %v, %v; want %v\n", p, remote, err, local)
+		}
+		if err := hdfs.VerifyChecksum(m, p, strings.NewReader(seen(t, m, p))); err != nil {
+			t.Errorf("Error on VerifyChecksum of %s: %v\n", p, err)
+		}
+	}
+	if c, _ := m.GetFileChecksum("/small-blocks"); !strings.HasPrefix(c.Algorithm, "MD5-of-8MD5-of-512") {
+		t.Errorf("Algorithm of a file of 3 blocks: got %s\n", c.Algorithm)
+	}
+
+	for _, tt := range []struct {
+		fsys hdfs.FileSystem
+		path string
+		data string
+		want error
+	}{
+		{m, "/small-blocks", data[1:] + "!", hdfs.ErrChecksum},
+		{m, "/small-blocks", data + " ", hdfs.ErrChecksum},
+		{m, "/empty", "x", hdfs.ErrChecksum},
+		{m, "/missing", "", syscall.ENOENT},
+		{m, "/", "", syscall.ENOENT},
+		{struct{ hdfs.FileSystem }{m}, "/empty", "", hdfs.ErrUnsupported},
+	} {
+		if err := hdfs.VerifyChecksum(tt.fsys, tt.path, strings.NewReader(tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("VerifyChecksum of %s: got %v, want %v\n", tt.path, err, tt.want)
+		}
+	}
+	if _, err := m.GetFileChecksum("/"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("GetFileChecksum of a directory: got %v\n", err)
+	}
+}
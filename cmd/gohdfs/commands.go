@@ -0,0 +1,929 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+// copyingSuffix marks files being copied, renamed once complete, as the
+// Hadoop shell does.
+const copyingSuffix = "._COPYING_"
+
+func (sh *shell) cat(opts flags, args []string) error {
+	for _, arg := range args {
+		for _, it := range sh.expand(arg) {
+			if it.info.IsDir() {
+				sh.fail(it.path, syscall.EISDIR)
+				continue
+			}
+			if err := sh.copyOut(sh.stdout, it.path); err != nil {
+				sh.fail(it.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checksum prints the path, algorithm and bytes of the checksum of files.
+func (sh *shell) checksum(opts flags, args []string) error {
+	for _, arg := range args {
+		for _, it := range sh.expand(arg) {
+			if it.info.IsDir() {
+				sh.fail(it.path, syscall.EISDIR)
+				continue
+			}
+			c, err := checksum(sh.fs, it.path)
+			if err != nil {
+				sh.fail(it.path, err)
+				continue
+			}
+			fmt.Fprintf(sh.stdout, "%s\t%s\n", it.path, c)
+		}
+	}
+	return nil
+}
+
+func checksum(fsys hdfs.FileSystem, p string) (*hdfs.FileChecksum, error) {
+	c, ok := fsys.(hdfs.Checksummer)
+	if !ok {
+		return nil, &hdfs.PathError{Op: "checksum", Path: p, Err: hdfs.ErrUnsupported}
+	}
+	return c.GetFileChecksum(p)
+}
+
+// copyOut copies the file p to w.
+func (sh *shell) copyOut(w io.Writer, p string) error {
+	f, err := sh.fs.Open(p, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (sh *shell) ls(opts flags, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	for _, arg := range args {
+		items := sh.expand(arg)
+		var files []item
+		for _, it := range items {
+			if !it.info.IsDir() || opts["d"] {
+				files = append(files, it)
+			}
+		}
+		sh.printItems(files, opts)
+		for _, it := range items {
+			if it.info.IsDir() && !opts["d"] {
+				sh.listDir(it, opts)
+			}
+		}
+	}
+	return nil
+}
+
+// listDir prints the entries of the directory it, and with -R those below
+// them, each directory followed by its own entries.
+func (sh *shell) listDir(it item, opts flags) {
+	children, err := sh.children(it)
+	if err != nil {
+		sh.fail(it.path, err)
+		return
+	}
+	if !opts["R"] {
+		if len(children) > 0 {
+			fmt.Fprintf(sh.stdout, "Found %d items\n", len(children))
+		}
+		sh.printItems(children, opts)
+		return
+	}
+	lines := sh.lines(children, opts)
+	for i, child := range children {
+		fmt.Fprintln(sh.stdout, lines[i])
+		if child.info.IsDir() {
+			sh.listDir(child, opts)
+		}
+	}
+}
+
+func (sh *shell) printItems(items []item, opts flags) {
+	for _, line := range sh.lines(items, opts) {
+		fmt.Fprintln(sh.stdout, line)
+	}
+}
+
+// lines formats items as the lines of ls, in columns as wide as their
+// widest values, or as Hadoop's defaults.
+func (sh *shell) lines(items []item, opts flags) []string {
+	maxRepl, maxLen, maxOwner, maxGroup := 3, 10, 0, 0
+	repls, sizes := make([]string, len(items)), make([]string, len(items))
+	for i, it := range items {
+		repls[i] = "-"
+		if !it.info.IsDir() {
+			repls[i] = strconv.Itoa(int(it.info.Replication))
+		}
+		sizes[i] = formatSize(it.info.Size, opts["h"])
+		maxRepl = maxInt(maxRepl, len(repls[i]))
+		maxLen = maxInt(maxLen, len(sizes[i]))
+		maxOwner = maxInt(maxOwner, len(it.info.Owner))
+		maxGroup = maxInt(maxGroup, len(it.info.Group))
+	}
+	lines := make([]string, len(items))
+	for i, it := range items {
+		info := it.info
+		lines[i] = fmt.Sprintf("%s%s %*s %-*s %-*s %*s %s %s",
+			kindString(info), permString(info.Permissions), maxRepl, repls[i],
+			maxOwner, info.Owner, maxGroup, info.Group, maxLen, sizes[i],
+			info.LastMod.In(sh.loc).Format("2006-01-02 15:04"), it.path)
+	}
+	return lines
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (sh *shell) mkdir(opts flags, args []string) error {
+	for _, arg := range args {
+		info, err := sh.fs.GetPathInfo(arg)
+		switch {
+		case err == nil && !opts["p"]:
+			sh.fail(arg, syscall.EEXIST)
+			continue
+		case err == nil && !info.IsDir():
+			sh.fail(arg, syscall.ENOTDIR)
+			continue
+		case err == nil:
+			continue
+		case !errors.Is(err, fs.ErrNotExist):
+			sh.fail(arg, err)
+			continue
+		}
+		if !opts["p"] {
+			parent, err := sh.fs.GetPathInfo(path.Dir(uriPath(arg)))
+			if err == nil && !parent.IsDir() {
+				err = syscall.ENOTDIR
+			}
+			if err != nil {
+				sh.fail(arg, err)
+				continue
+			}
+		}
+		if err := sh.fs.CreateDirectory(arg); err != nil {
+			sh.fail(arg, err)
+		}
+	}
+	return nil
+}
+
+func (sh *shell) rm(opts flags, args []string) error {
+	recursive := opts["r"] || opts["R"]
+	for _, arg := range args {
+		if opts["f"] && !hasMeta(arg) {
+			if _, err := sh.fs.GetPathInfo(arg); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+		}
+		for _, it := range sh.expand(arg) {
+			if it.info.IsDir() && !recursive {
+				sh.fail(it.path, syscall.EISDIR)
+				continue
+			}
+			if !opts["skipTrash"] {
+				dst, err := hdfs.MoveToTrash(sh.fs, it.path)
+				if err != nil && !errors.Is(err, hdfs.ErrUnsupported) {
+					sh.fail(it.path, err)
+					continue
+				}
+				if dst != "" {
+					fmt.Fprintf(sh.stdout, "Moved: '%s' to trash at: %s\n", it.path, dst)
+					continue
+				}
+			}
+			if err := sh.fs.Delete(it.path); err != nil {
+				sh.fail(it.path, err)
+				continue
+			}
+			fmt.Fprintf(sh.stdout, "Deleted %s\n", it.path)
+		}
+	}
+	return nil
+}
+
+func (sh *shell) expunge(opts flags, args []string) error {
+	return hdfs.Expunge(sh.fs)
+}
+
+// target returns the path the source src of mv, cp or put goes to: below
+// dst if it is a directory, dst itself if not. dst must be a directory for
+// several sources.
+func (sh *shell) target(src, dst string, several bool) (string, error) {
+	info, err := sh.fs.GetPathInfo(dst)
+	switch {
+	case err == nil && info.IsDir():
+		return strings.TrimSuffix(dst, "/") + "/" + baseName(src), nil
+	case err == nil && several:
+		return "", syscall.ENOTDIR
+	case errors.Is(err, fs.ErrNotExist) && several:
+		return "", err
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+	return dst, nil
+}
+
+// sources expands the sources of mv, cp or get.
+func (sh *shell) sources(args []string) []item {
+	var items []item
+	for _, arg := range args {
+		items = append(items, sh.expand(arg)...)
+	}
+	return items
+}
+
+func (sh *shell) mv(opts flags, args []string) error {
+	dst := args[len(args)-1]
+	srcs := sh.sources(args[:len(args)-1])
+	for _, src := range srcs {
+		to, err := sh.target(src.path, dst, len(srcs) > 1)
+		if err != nil {
+			sh.fail(dst, err)
+			return nil
+		}
+		if _, err := sh.fs.GetPathInfo(to); err == nil {
+			sh.fail(to, syscall.EEXIST)
+			continue
+		}
+		if err := sh.fs.Rename(src.path, to); err != nil {
+			sh.fail(src.path, err)
+		}
+	}
+	return nil
+}
+
+func (sh *shell) cp(opts flags, args []string) error {
+	dst := args[len(args)-1]
+	srcs := sh.sources(args[:len(args)-1])
+	for _, src := range srcs {
+		to, err := sh.target(src.path, dst, len(srcs) > 1)
+		if err != nil {
+			sh.fail(dst, err)
+			return nil
+		}
+		_, err = sh.fs.GetPathInfo(to)
+		exists := err == nil
+		if exists && !opts["f"] {
+			sh.fail(to, syscall.EEXIST)
+			continue
+		}
+		if !src.info.IsDir() {
+			// a file replaces to only once copied, so that it is kept
+			// if copying fails, or src is to itself
+			err = sh.copyIn(to, opts["f"], func(w io.Writer) error { return sh.copyOut(w, src.path) }, nil)
+		} else {
+			if exists {
+				if err := sh.fs.Delete(to); err != nil {
+					sh.fail(to, err)
+					continue
+				}
+			}
+			err = hdfs.CopyTree(sh.fs, src.path, sh.fs, to, nil)
+		}
+		if err != nil {
+			sh.fail(src.path, err)
+		}
+	}
+	return nil
+}
+
+// copyIn writes the file p with fill, through a file named with
+// copyingSuffix renamed into place once complete and, if check is not nil,
+// accepted by it, replacing any file p if overwrite is set.
+func (sh *shell) copyIn(p string, overwrite bool, fill func(w io.Writer) error, check func(tmp string) error) error {
+	tmp := p + copyingSuffix
+	f, err := sh.fs.Open(tmp, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	err = fill(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && check != nil {
+		err = check(tmp)
+	}
+	if err == nil && overwrite {
+		if err = sh.fs.Delete(p); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = sh.fs.Rename(tmp, p)
+	}
+	if err != nil {
+		sh.fs.Delete(tmp)
+	}
+	return err
+}
+
+func (sh *shell) put(opts flags, args []string) error {
+	dst := args[len(args)-1]
+	srcs := args[:len(args)-1]
+	for _, src := range srcs {
+		if src == "-" {
+			if len(srcs) > 1 {
+				return usageError("- must be the only source")
+			}
+			if opts["verify"] {
+				return usageError("-verify needs a local file to read again")
+			}
+			to, err := sh.target("-", dst, false)
+			if err == nil && to != dst {
+				err = syscall.EISDIR
+			}
+			if err == nil {
+				err = sh.putFile(sh.stdin, nil, to, opts)
+			}
+			if err != nil {
+				sh.fail(dst, err)
+			}
+			continue
+		}
+		to, err := sh.target(src, dst, len(srcs) > 1)
+		if err != nil {
+			sh.fail(dst, err)
+			return nil
+		}
+		sh.putTree(src, to, opts)
+	}
+	return nil
+}
+
+// putTree copies the local file or directory tree src to dst.
+func (sh *shell) putTree(src, dst string, opts flags) {
+	info, err := os.Stat(src)
+	if err != nil {
+		sh.fail(src, err)
+		return
+	}
+	if _, err := sh.fs.GetPathInfo(dst); err == nil && (info.IsDir() || !opts["f"]) {
+		sh.fail(dst, syscall.EEXIST)
+		return
+	}
+	if !info.IsDir() {
+		f, err := os.Open(src)
+		if err == nil {
+			err = sh.putFile(f, info, dst, opts)
+			f.Close()
+		}
+		if err != nil {
+			sh.fail(dst, err)
+		}
+		return
+	}
+	if err := sh.fs.CreateDirectory(dst); err != nil {
+		sh.fail(dst, err)
+		return
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		sh.fail(src, err)
+	}
+	for _, e := range entries {
+		sh.putTree(filepath.Join(src, e.Name()), dst+"/"+e.Name(), opts)
+	}
+	if opts["p"] {
+		sh.preserve(dst, info)
+	}
+}
+
+// putFile writes r to the file dst, with the times and permission of the
+// local file info with -p. With -verify, r is read again to compare its
+// checksum with that of the copy before it replaces dst.
+func (sh *shell) putFile(r io.Reader, info fs.FileInfo, dst string, opts flags) error {
+	if _, err := sh.fs.GetPathInfo(dst); err == nil && !opts["f"] {
+		return syscall.EEXIST
+	}
+	var check func(tmp string) error
+	if opts["verify"] {
+		check = func(tmp string) error {
+			f := r.(io.ReadSeeker)
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return hdfs.VerifyChecksum(sh.fs, tmp, f)
+		}
+	}
+	err := sh.copyIn(dst, opts["f"], func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	}, check)
+	if err == nil && info != nil && opts["p"] {
+		err = sh.preserve(dst, info)
+	}
+	return err
+}
+
+func (sh *shell) preserve(p string, info fs.FileInfo) error {
+	if err := sh.fs.Chmod(p, int16(info.Mode().Perm())); err != nil {
+		return err
+	}
+	return sh.fs.Utime(p, info.ModTime(), info.ModTime())
+}
+
+func (sh *shell) get(opts flags, args []string) error {
+	dst := args[len(args)-1]
+	srcs := sh.sources(args[:len(args)-1])
+	local, err := os.Stat(dst)
+	if len(srcs) > 1 && (err != nil || !local.IsDir()) {
+		if err == nil {
+			err = syscall.ENOTDIR
+		}
+		sh.fail(dst, err)
+		return nil
+	}
+	for _, src := range srcs {
+		to := dst
+		if err == nil && local.IsDir() {
+			to = filepath.Join(dst, baseName(src.path))
+		}
+		sh.getTree(src, to, opts)
+	}
+	return nil
+}
+
+// getTree copies the file or directory tree it to the local path dst.
+func (sh *shell) getTree(it item, dst string, opts flags) {
+	if _, err := os.Lstat(dst); err == nil && (it.info.IsDir() || !opts["f"]) {
+		sh.fail(dst, syscall.EEXIST)
+		return
+	}
+	if it.info.IsDir() {
+		if err := os.Mkdir(dst, 0777); err != nil {
+			sh.fail(dst, err)
+			return
+		}
+		children, err := sh.children(it)
+		if err != nil {
+			sh.fail(it.path, err)
+		}
+		for _, child := range children {
+			sh.getTree(child, filepath.Join(dst, baseName(child.path)), opts)
+		}
+	} else if err := sh.getFile(it.path, dst); err != nil {
+		sh.fail(it.path, err)
+		return
+	}
+	if opts["p"] {
+		os.Chmod(dst, fs.FileMode(it.info.Permissions&0777))
+		os.Chtimes(dst, it.info.LastAccess, it.info.LastMod)
+	}
+}
+
+// getFile copies the file src to the local file dst, through a file named
+// with copyingSuffix.
+func (sh *shell) getFile(src, dst string) error {
+	tmp := dst + copyingSuffix
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = sh.copyOut(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func (sh *shell) chmod(opts flags, args []string) error {
+	change, err := parseMode(args[0])
+	if err != nil {
+		return err
+	}
+	for _, arg := range args[1:] {
+		for _, it := range sh.expand(arg) {
+			sh.walk(it, opts["R"], func(it item) {
+				perm := change(it.info.Permissions, it.info.IsDir())
+				if perm == it.info.Permissions {
+					return
+				}
+				if err := sh.fs.Chmod(it.path, perm); err != nil {
+					sh.fail(it.path, err)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// ownerPattern is the [OWNER][:[GROUP]] argument of chown.
+var ownerPattern = regexp.MustCompile(`^\s*([-_./@a-zA-Z0-9]+)?(:([-_./@a-zA-Z0-9]*))?\s*$`)
+
+func (sh *shell) chown(opts flags, args []string) error {
+	m := ownerPattern.FindStringSubmatch(args[0])
+	if m == nil || m[1] == "" && m[3] == "" {
+		return usageError("'" + args[0] + "' does not match expected pattern for [owner][:group].")
+	}
+	owner, group := m[1], m[3]
+	for _, arg := range args[1:] {
+		for _, it := range sh.expand(arg) {
+			sh.walk(it, opts["R"], func(it item) {
+				if (owner == "" || owner == it.info.Owner) && (group == "" || group == it.info.Group) {
+					return
+				}
+				if err := sh.fs.Chown(it.path, owner, group); err != nil {
+					sh.fail(it.path, err)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// getfacl prints the ACL of paths as the Hadoop shell does, with the
+// effective permission of the entries the mask bounds.
+func (sh *shell) getfacl(opts flags, args []string) error {
+	for _, it := range sh.expand(args[0]) {
+		sh.walk(it, opts["R"], func(it item) {
+			s, err := aclStatus(sh.fs, it)
+			if err != nil {
+				sh.fail(it.path, err)
+				return
+			}
+			fmt.Fprintf(sh.stdout, "# file: %s\n# owner: %s\n# group: %s\n", it.path, s.Owner, s.Group)
+			if s.Sticky {
+				fmt.Fprintf(sh.stdout, "# flags: --%c\n", permString(s.Permission | 01000)[8])
+			}
+			for _, e := range s.Acl() {
+				if eff := s.Effective(e); eff != e.Perm {
+					fmt.Fprintf(sh.stdout, "%s\t#effective:%s\n", e, permString(eff)[6:])
+				} else {
+					fmt.Fprintln(sh.stdout, e)
+				}
+			}
+			fmt.Fprintln(sh.stdout)
+		})
+	}
+	return nil
+}
+
+// aclStatus returns the ACL of it, the one its permission holds if the
+// file system has no ACLs.
+func aclStatus(fsys hdfs.FileSystem, it item) (*hdfs.AclStatus, error) {
+	if a, ok := fsys.(hdfs.Acler); ok {
+		s, err := a.GetAclStatus(it.path)
+		if !errors.Is(err, hdfs.ErrUnsupported) {
+			return s, err
+		}
+	}
+	info := it.info
+	return &hdfs.AclStatus{Owner: info.Owner, Group: info.Group, Sticky: info.Permissions&01000 != 0, Permission: info.Permissions}, nil
+}
+
+// setfacl changes the ACL of a path with one of -b, removing all but the
+// base entries, -k, removing the default entries, -m and -x, adding and
+// removing the entries of a spec, and --set, replacing the ACL with one.
+// Default entries are left out for files.
+func (sh *shell) setfacl(opts flags, args []string) error {
+	var mode string
+	for _, opt := range []string{"b", "k", "m", "x", "set"} {
+		if opts[opt] && mode != "" {
+			return usageError("Specified flags contains both remove and modify flags")
+		}
+		if opts[opt] {
+			mode = opt
+		}
+	}
+	var entries []hdfs.AclEntry
+	switch {
+	case mode == "":
+		return usageError("One of -b, -k, -m, -x or --set is required")
+	case mode == "b" || mode == "k":
+		if len(args) != 1 {
+			return usageError("Too many arguments: expected 1 but got 2")
+		}
+	case len(args) != 2:
+		return usageError("Missing either <acl_spec> or <path>")
+	default:
+		var err error
+		if entries, err = hdfs.ParseAclSpec(args[0]); err != nil {
+			return usageError(err.Error())
+		}
+		args = args[1:]
+	}
+	a, ok := sh.fs.(hdfs.Acler)
+	for _, it := range sh.expand(args[0]) {
+		sh.walk(it, opts["R"], func(it item) {
+			if !ok {
+				sh.fail(it.path, hdfs.ErrUnsupported)
+				return
+			}
+			spec := entries
+			if !it.info.IsDir() {
+				spec = nil
+				for _, e := range entries {
+					if e.Scope == hdfs.AclAccess {
+						spec = append(spec, e)
+					}
+				}
+			}
+			var err error
+			switch mode {
+			case "b":
+				err = a.RemoveAcl(it.path)
+			case "k":
+				err = a.RemoveDefaultAcl(it.path)
+			case "m":
+				err = a.ModifyAclEntries(it.path, spec)
+			case "x":
+				err = a.RemoveAclEntries(it.path, spec)
+			case "set":
+				err = a.SetAcl(it.path, spec)
+			}
+			if err != nil {
+				sh.fail(it.path, err)
+			}
+		})
+	}
+	return nil
+}
+
+func (sh *shell) setrep(opts flags, args []string) error {
+	rep, err := strconv.ParseInt(args[0], 10, 16)
+	if err != nil || rep < 1 {
+		return usageError("replication must be >= 1")
+	}
+	var waits []item
+	for _, it := range sh.expand(args[1]) {
+		// directories are always recursed into: -R is kept for compatibility
+		sh.walk(it, true, func(it item) {
+			if it.info.IsDir() {
+				return
+			}
+			if err := sh.fs.SetReplication(it.path, int16(rep)); err != nil {
+				sh.fail(it.path, err)
+				return
+			}
+			fmt.Fprintf(sh.stdout, "Replication %d set: %s\n", rep, it.path)
+			waits = append(waits, it)
+		})
+	}
+	if opts["w"] {
+		for _, it := range waits {
+			sh.waitReplication(it, int(rep))
+		}
+	}
+	return nil
+}
+
+// waitReplication waits until every block of the file it has rep replicas.
+func (sh *shell) waitReplication(it item, rep int) {
+	fmt.Fprintf(sh.stdout, "Waiting for %s ...", it.path)
+	for {
+		hosts, err := sh.fs.GetHosts(it.path, 0, it.info.Size)
+		if err != nil {
+			fmt.Fprintln(sh.stdout)
+			sh.fail(it.path, err)
+			return
+		}
+		done := true
+		for _, h := range hosts {
+			done = done && len(h) >= rep
+		}
+		if done {
+			fmt.Fprintln(sh.stdout, " done")
+			return
+		}
+		fmt.Fprint(sh.stdout, ".")
+		time.Sleep(sh.poll)
+	}
+}
+
+func (sh *shell) stat(opts flags, args []string) error {
+	format := "%y"
+	if len(args) > 1 && strings.Contains(args[0], "%") {
+		format, args = args[0], args[1:]
+	}
+	for _, arg := range args {
+		for _, it := range sh.expand(arg) {
+			fmt.Fprintln(sh.stdout, formatStat(format, it))
+		}
+	}
+	return nil
+}
+
+// tailSize is the number of bytes tail prints before following.
+const tailSize = 1024
+
+// tail prints the last kilobyte of a file and, with -f, what is appended to
+// it until the output is closed.
+func (sh *shell) tail(opts flags, args []string) error {
+	for _, it := range sh.expand(args[0]) {
+		if it.info.IsDir() {
+			sh.fail(it.path, syscall.EISDIR)
+			continue
+		}
+		r, err := hdfs.Follow(sh.fs, it.path, -tailSize, &hdfs.FollowOptions{Interval: sh.poll})
+		if err != nil {
+			sh.fail(it.path, err)
+			continue
+		}
+		if !opts["f"] {
+			r.Close()
+			err = sh.tailOnce(it)
+		} else {
+			err = sh.follow(r)
+			r.Close()
+		}
+		if err != nil {
+			sh.fail(it.path, err)
+		}
+	}
+	return nil
+}
+
+func (sh *shell) tailOnce(it item) error {
+	f, err := sh.fs.Open(it.path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if it.info.Size > tailSize {
+		if _, err := f.Seek(-tailSize, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(sh.stdout, f)
+	return err
+}
+
+// follow copies r to the output until r fails, or the output does, as when
+// it is piped to a command that exits.
+func (sh *shell) follow(r io.Reader) error {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := sh.stdout.Write(buf[:n]); err != nil {
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (sh *shell) test(opts flags, args []string) error {
+	if len(opts) != 1 {
+		return usageError("No test flag given")
+	}
+	info, err := sh.fs.GetPathInfo(args[0])
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			sh.fail(args[0], err)
+		}
+		sh.status = exitError
+		return nil
+	}
+	ok := opts["e"] ||
+		opts["d"] && info.IsDir() ||
+		opts["f"] && !info.IsDir() ||
+		opts["s"] && info.Size > 0 ||
+		opts["z"] && info.Size == 0
+	if !ok {
+		sh.status = exitError
+	}
+	return nil
+}
+
+func (sh *shell) touchz(opts flags, args []string) error {
+	for _, arg := range args {
+		info, err := sh.fs.GetPathInfo(arg)
+		switch {
+		case err == nil && info.IsDir():
+			sh.fail(arg, syscall.EISDIR)
+			continue
+		case err == nil && info.Size != 0:
+			sh.fail(arg, errors.New("Not a zero-length file"))
+			continue
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			sh.fail(arg, err)
+			continue
+		}
+		if parent, err := sh.fs.GetPathInfo(path.Dir(uriPath(arg))); err != nil || !parent.IsDir() {
+			if err == nil {
+				err = syscall.ENOTDIR
+			}
+			sh.fail(arg, err)
+			continue
+		}
+		f, err := sh.fs.Open(arg, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+		if err == nil {
+			err = f.Close()
+		}
+		if err != nil {
+			sh.fail(arg, err)
+		}
+	}
+	return nil
+}
+
+func (sh *shell) du(opts flags, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var rows [][]string
+	add := func(it item) {
+		s, err := hdfs.GetContentSummary(sh.fs, it.path)
+		if err != nil {
+			sh.fail(it.path, err)
+			return
+		}
+		rows = append(rows, []string{formatSize(s.Length, opts["h"]), formatSize(s.SpaceConsumed, opts["h"]), it.path})
+	}
+	for _, arg := range args {
+		for _, it := range sh.expand(arg) {
+			if opts["s"] || !it.info.IsDir() {
+				add(it)
+				continue
+			}
+			children, err := sh.children(it)
+			if err != nil {
+				sh.fail(it.path, err)
+				continue
+			}
+			for _, child := range children {
+				add(child)
+			}
+		}
+	}
+	printTable(sh.stdout, rows, nil)
+	return nil
+}
+
+func (sh *shell) df(opts flags, args []string) error {
+	for _, arg := range args {
+		if len(sh.expand(arg)) == 0 {
+			return nil
+		}
+	}
+	root, err := sh.fs.GetPathInfo("/")
+	if err != nil {
+		sh.fail("", err)
+		return nil
+	}
+	name := root.Name
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		name = u.Scheme + "://" + u.Host
+	}
+	capacity, err := sh.fs.GetCapacity()
+	if err != nil {
+		sh.fail("", err)
+		return nil
+	}
+	used, err := sh.fs.GetUsed()
+	if err != nil {
+		sh.fail("", err)
+		return nil
+	}
+	remaining, err := hdfs.GetRemaining(sh.fs)
+	if err != nil {
+		sh.fail("", err)
+		return nil
+	}
+	percent := 0.0
+	if capacity > 0 {
+		percent = float64(used) * 100 / float64(capacity)
+	}
+	h := opts["h"]
+	printTable(sh.stdout, [][]string{
+		{"Filesystem", "Size", "Used", "Available", "Use%"},
+		{name, formatSize(capacity, h), formatSize(used, h), formatSize(remaining, h), fmt.Sprintf("%.0f%%", percent)},
+	}, map[int]bool{1: true, 2: true, 3: true, 4: true})
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zyxar/hdfs"
+)
+
+// humanSize formats n as Hadoop's TraditionalBinaryPrefix.long2String with
+// one decimal place: "1023", "1 K", "1.5 M".
+func humanSize(n int64) string {
+	const symbols = "KMGTPE"
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs < 1<<10 {
+		return strconv.FormatInt(n, 10)
+	}
+	i := 0
+	for i+1 < len(symbols) && abs >= 1<<(10*(i+2)) {
+		i++
+	}
+	shift := uint(10 * (i + 1))
+	if n&(1<<shift-1) == 0 {
+		return strconv.FormatInt(n>>shift, 10) + " " + symbols[i:i+1]
+	}
+	s := strconv.FormatFloat(float64(n)/float64(int64(1)<<shift), 'f', 1, 64)
+	if strings.HasPrefix(s, "1024") && i+1 < len(symbols) {
+		i, shift = i+1, shift+10
+		s = strconv.FormatFloat(float64(n)/float64(int64(1)<<shift), 'f', 1, 64)
+	}
+	return s + " " + symbols[i:i+1]
+}
+
+func formatSize(n int64, human bool) string {
+	if human {
+		return humanSize(n)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// permString returns the nine rwx characters of a permission, the last one
+// being t or T for the sticky bit.
+func permString(perm int16) string {
+	const rwx = "rwxrwxrwx"
+	b := []byte("---------")
+	for i := range b {
+		if perm&(1<<uint(8-i)) != 0 {
+			b[i] = rwx[i]
+		}
+	}
+	if perm&01000 != 0 {
+		if b[8] == 'x' {
+			b[8] = 't'
+		} else {
+			b[8] = 'T'
+		}
+	}
+	return string(b)
+}
+
+func kindString(info *hdfs.FileInfo) string {
+	if info.IsDir() {
+		return "d"
+	}
+	return "-"
+}
+
+// printTable prints rows as the TableBuilder of the Hadoop shell does: two
+// spaces between columns padded to their widest cell, right-aligned if
+// right[column] is set.
+func printTable(w io.Writer, rows [][]string, right map[int]bool) {
+	if len(rows) == 0 {
+		return
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			switch {
+			case right[i]:
+				fmt.Fprintf(&b, "%*s", widths[i], cell)
+			case i < len(row)-1:
+				fmt.Fprintf(&b, "%-*s", widths[i], cell)
+			default:
+				b.WriteString(cell)
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// statTime is the format of the dates of stat, printed in UTC.
+const statTime = "2006-01-02 15:04:05"
+
+// formatStat formats info as the format of `hadoop fs -stat`.
+func formatStat(format string, it item) string {
+	info := it.info
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'a':
+			b.WriteString(strconv.FormatInt(int64(info.Permissions&01777), 8))
+		case 'A':
+			b.WriteString(permString(info.Permissions))
+		case 'b':
+			b.WriteString(strconv.FormatInt(dirZero(info, info.Size), 10))
+		case 'F':
+			if info.IsDir() {
+				b.WriteString("directory")
+			} else {
+				b.WriteString("regular file")
+			}
+		case 'g':
+			b.WriteString(info.Group)
+		case 'n':
+			b.WriteString(baseName(it.path))
+		case 'o':
+			b.WriteString(strconv.FormatInt(dirZero(info, info.BlockSize), 10))
+		case 'r':
+			b.WriteString(strconv.FormatInt(dirZero(info, int64(info.Replication)), 10))
+		case 'u':
+			b.WriteString(info.Owner)
+		case 'x':
+			b.WriteString(info.LastAccess.UTC().Format(statTime))
+		case 'X':
+			b.WriteString(strconv.FormatInt(info.LastAccess.UnixNano()/1e6, 10))
+		case 'y':
+			b.WriteString(info.LastMod.UTC().Format(statTime))
+		case 'Y':
+			b.WriteString(strconv.FormatInt(info.LastMod.UnixNano()/1e6, 10))
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// dirZero returns 0 for directories, which have no size, block size or
+// replication, and n for files.
+func dirZero(info *hdfs.FileInfo, n int64) int64 {
+	if info.IsDir() {
+		return 0
+	}
+	return n
+}
+
+func baseName(p string) string {
+	p = strings.TrimRight(uriPath(p), "/")
+	if i := strings.LastIndexByte(p, '/'); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+// modeChange computes the permission set by chmod from the current one.
+type modeChange func(perm int16, dir bool) int16
+
+// parseMode parses the MODE of chmod: an octal mode of 3 digits, with an
+// optional leading sticky bit digit, or comma-separated symbolic clauses
+// such as "u+x,go-w" or "a=rX".
+func parseMode(s string) (modeChange, error) {
+	bad := usageError("chmod : mode '" + s + "' does not match the expected pattern.")
+	octal := strings.TrimPrefix(s, "+")
+	if len(octal) == 3 || len(octal) == 4 && (octal[0] == '0' || octal[0] == '1') {
+		if m, err := strconv.ParseInt(octal, 8, 16); err == nil {
+			return func(int16, bool) int16 { return int16(m) }, nil
+		}
+	}
+	type clause struct {
+		who, bits int16
+		op        byte
+		execIfX   bool // X: execute only for directories or executables
+		sticky    bool
+	}
+	var clauses []clause
+	for _, part := range strings.Split(s, ",") {
+		var c clause
+		i := 0
+		for ; i < len(part) && strings.IndexByte("ugoa", part[i]) >= 0; i++ {
+			c.who |= map[byte]int16{'u': 0700, 'g': 0070, 'o': 0007, 'a': 0777}[part[i]]
+		}
+		if c.who == 0 {
+			c.who = 0777
+		}
+		if i == len(part) || strings.IndexByte("+-=", part[i]) < 0 {
+			return nil, bad
+		}
+		c.op = part[i]
+		perms := part[i+1:]
+		if perms == "" {
+			return nil, bad
+		}
+		for j := 0; j < len(perms); j++ {
+			switch perms[j] {
+			case 'r':
+				c.bits |= 0444
+			case 'w':
+				c.bits |= 0222
+			case 'x':
+				c.bits |= 0111
+			case 'X':
+				c.execIfX = true
+			case 't':
+				c.sticky = c.who&0007 != 0
+			default:
+				return nil, bad
+			}
+		}
+		clauses = append(clauses, c)
+	}
+	return func(perm int16, dir bool) int16 {
+		for _, c := range clauses {
+			bits := c.bits
+			if c.execIfX && (dir || perm&0111 != 0) {
+				bits |= 0111
+			}
+			bits &= c.who
+			if c.sticky {
+				bits |= 01000
+			}
+			switch c.op {
+			case '+':
+				perm |= bits
+			case '-':
+				perm &^= bits
+			case '=':
+				mask := c.who
+				if c.who&0007 != 0 {
+					mask |= 01000
+				}
+				perm = perm&^mask | bits
+			}
+		}
+		return perm
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Command gohdfs runs the file system commands of `hadoop fs` without
+// starting a JVM per command:
+//
+//	gohdfs [-fs hdfs://nn:8020] [-D property=value] [-conf dir] -ls -R /data
+//
+// Commands may be given with or without their leading "-". Their output,
+// error messages and exit codes are those of the Hadoop shell: 0 on
+// success, 1 if the command failed on some path, and 255 for an unknown
+// command or bad arguments. The supported commands are cat, checksum,
+// chmod, chown, cp, df, du, expunge, get, getfacl, ls, mkdir, mv, put, rm,
+// setfacl, setrep, stat, tail, test and touchz; put -verify compares the
+// checksum of each copy with that of its local file before moving it into
+// place, and rm moves what it removes to the trash, as hdfs.MoveToTrash
+// does, unless given -skipTrash.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+func main() {
+	var opts []hdfs.Option
+	args := os.Args[1:]
+generic:
+	for len(args) >= 2 {
+		switch args[0] {
+		case "-fs":
+			opts = append(opts, hdfs.WithNamenode(args[1]))
+		case "-conf":
+			opts = append(opts, hdfs.WithConfDir(args[1]))
+		case "-D":
+			key, value, ok := strings.Cut(args[1], "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "-D: want property=value, got %q\n", args[1])
+				os.Exit(exitUsage)
+			}
+			opts = append(opts, hdfs.WithSetting(key, value))
+		default:
+			break generic
+		}
+		args = args[2:]
+	}
+	sh := &shell{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, loc: time.Local, poll: time.Second}
+	if len(args) == 0 {
+		sh.usage("")
+		os.Exit(exitUsage)
+	}
+	fs, err := hdfs.ConnectWithOptions(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gohdfs: %v\n", err)
+		os.Exit(exitError)
+	}
+	sh.fs = fs
+	status := sh.run(args)
+	fs.Disconnect()
+	os.Exit(status)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+// Exit codes of the Hadoop shell.
+const (
+	exitOK    = 0
+	exitError = 1   // a command failed on some path
+	exitUsage = 255 // -1 in Java: unknown command or bad arguments
+)
+
+// shell runs the commands of `hadoop fs` on a FileSystem, with the output
+// and error messages of the Hadoop shell.
+type shell struct {
+	fs     hdfs.FileSystem
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	loc    *time.Location // time zone of the dates of ls
+	poll   time.Duration  // interval of setrep -w and tail -f
+
+	name   string // command being run
+	status int
+}
+
+// flags are the options given to a command, without their leading "-".
+type flags map[string]bool
+
+// command is a subcommand of the shell. Options are the single-letter, or
+// single-word, flags it accepts; min and max bound the number of arguments
+// left after them, max being -1 for no limit.
+type command struct {
+	run      func(sh *shell, opts flags, args []string) error
+	options  string
+	min, max int
+	usage    string
+}
+
+var commands = map[string]command{
+	"cat":      {(*shell).cat, "", 1, -1, "<src> ..."},
+	"checksum": {(*shell).checksum, "", 1, -1, "<src> ..."},
+	"chmod":    {(*shell).chmod, "R", 2, -1, "[-R] <MODE[,MODE]... | OCTALMODE> PATH..."},
+	"chown":    {(*shell).chown, "R", 2, -1, "[-R] [OWNER][:[GROUP]] PATH..."},
+	"cp":       {(*shell).cp, "f", 2, -1, "[-f] <src> ... <dst>"},
+	"df":       {(*shell).df, "h", 0, -1, "[-h] [<path> ...]"},
+	"du":       {(*shell).du, "s h", 0, -1, "[-s] [-h] <path> ..."},
+	"expunge":  {(*shell).expunge, "", 0, 0, ""},
+	"get":      {(*shell).get, "f p", 2, -1, "[-f] [-p] <src> ... <localdst>"},
+	"getfacl":  {(*shell).getfacl, "R", 1, 1, "[-R] <path>"},
+	"ls":       {(*shell).ls, "d h R", 0, -1, "[-d] [-h] [-R] [<path> ...]"},
+	"mkdir":    {(*shell).mkdir, "p", 1, -1, "[-p] <path> ..."},
+	"mv":       {(*shell).mv, "", 2, -1, "<src> ... <dst>"},
+	"put":      {(*shell).put, "f p verify", 2, -1, "[-f] [-p] [-verify] <localsrc> ... <dst>"},
+	"rm":       {(*shell).rm, "f r R skipTrash", 1, -1, "[-f] [-r|-R] [-skipTrash] <src> ..."},
+	"setfacl":  {(*shell).setfacl, "R b k m x set", 1, 2, "[-R] [{-b|-k} {-m|-x <acl_spec>} <path>]|[--set <acl_spec> <path>]"},
+	"setrep":   {(*shell).setrep, "R w", 2, 2, "[-R] [-w] <rep> <path>"},
+	"stat":     {(*shell).stat, "", 1, -1, "[format] <path> ..."},
+	"tail":     {(*shell).tail, "f", 1, 1, "[-f] <file>"},
+	"test":     {(*shell).test, "d e f s z", 1, 1, "-[defsz] <path>"},
+	"touchz":   {(*shell).touchz, "", 1, -1, "<path> ..."},
+}
+
+// usageError is a misuse of a command, reported along with its usage.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// run runs the command of args, such as "-ls" or "ls" followed by its
+// options and arguments, and returns the exit code of the Hadoop shell.
+func (sh *shell) run(args []string) int {
+	if len(args) == 0 {
+		sh.usage("")
+		return exitUsage
+	}
+	sh.name, sh.status = strings.TrimPrefix(args[0], "-"), exitOK
+	cmd, ok := commands[sh.name]
+	if !ok {
+		fmt.Fprintf(sh.stderr, "%s: Unknown command\n", args[0])
+		sh.usage("")
+		return exitUsage
+	}
+	opts, args, err := cmd.parse(args[1:])
+	if err == nil {
+		err = cmd.run(sh, opts, args)
+	}
+	if err != nil {
+		fmt.Fprintf(sh.stderr, "-%s: %v\n", sh.name, err)
+		sh.usage(sh.name)
+		return exitUsage
+	}
+	return sh.status
+}
+
+// parse splits args into the leading options and the arguments. Options
+// may also be given with two dashes, as --verify.
+func (cmd command) parse(args []string) (flags, []string, error) {
+	opts := flags{}
+	known := strings.Fields(cmd.options)
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := strings.TrimPrefix(args[0][1:], "-")
+		if !contains(known, opt) {
+			return nil, nil, usageError("Illegal option -" + opt)
+		}
+		opts[opt] = true
+		args = args[1:]
+	}
+	if len(args) < cmd.min {
+		return nil, nil, usageError(fmt.Sprintf("Not enough arguments: expected %d but got %d", cmd.min, len(args)))
+	}
+	if cmd.max >= 0 && len(args) > cmd.max {
+		return nil, nil, usageError(fmt.Sprintf("Too many arguments: expected %d but got %d", cmd.max, len(args)))
+	}
+	return opts, args, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// synopsis returns the command name followed by its usage.
+func synopsis(name string) string {
+	return strings.TrimSpace("-" + name + " " + commands[name].usage)
+}
+
+// usage prints the usage of the command name, or of all commands.
+func (sh *shell) usage(name string) {
+	if name != "" {
+		fmt.Fprintf(sh.stderr, "Usage: gohdfs [generic options] %s\n", synopsis(name))
+		return
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(sh.stderr, "Usage: gohdfs [generic options]")
+	for _, name := range names {
+		fmt.Fprintf(sh.stderr, "\t[%s]\n", synopsis(name))
+	}
+	fmt.Fprintln(sh.stderr, "\nGeneric options are\n"+
+		"-fs <file:///|hdfs://namenode:port>\tspecify a namenode\n"+
+		"-D <property=value>\tuse value for given property\n"+
+		"-conf <directory>\tdirectory holding core-site.xml and hdfs-site.xml")
+}
+
+// fail reports the failure of the command on the path p, as displayed, and
+// sets the exit code to exitError.
+func (sh *shell) fail(p string, err error) {
+	if p == "" {
+		fmt.Fprintf(sh.stderr, "%s: %s\n", sh.name, message(err))
+	} else {
+		fmt.Fprintf(sh.stderr, "%s: `%s': %s\n", sh.name, p, message(err))
+	}
+	sh.status = exitError
+}
+
+// message returns the message of the Hadoop shell for err.
+func message(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "No such file or directory"
+	case errors.Is(err, fs.ErrExist):
+		return "File exists"
+	case errors.Is(err, syscall.EISDIR):
+		return "Is a directory"
+	case errors.Is(err, syscall.ENOTDIR):
+		return "Is not a directory"
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return "Directory is not empty"
+	case errors.Is(err, fs.ErrPermission):
+		return "Permission denied"
+	case errors.Is(err, hdfs.ErrChecksum):
+		return "Checksum mismatch"
+	}
+	var pe *hdfs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err.Error()
+	}
+	return err.Error()
+}
+
+// item is a path given to a command, or found below one, with its path as
+// displayed: as typed, or relative to it.
+type item struct {
+	path string
+	info *hdfs.FileInfo
+}
+
+// hasMeta reports whether p holds glob meta characters.
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[{\`)
+}
+
+// expand returns the items an argument names, expanding glob patterns;
+// failures are reported.
+func (sh *shell) expand(arg string) []item {
+	if !hasMeta(arg) {
+		info, err := sh.fs.GetPathInfo(arg)
+		if err != nil {
+			sh.fail(arg, err)
+			return nil
+		}
+		return []item{{arg, info}}
+	}
+	infos, err := hdfs.Glob(sh.fs, arg)
+	if err == nil && len(infos) == 0 {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		sh.fail(arg, err)
+		return nil
+	}
+	items := make([]item, len(infos))
+	for i, info := range infos {
+		items[i] = item{sh.display(arg, info.Name), info}
+	}
+	return items
+}
+
+// display returns how a match of the pattern is displayed: as a URI if the
+// pattern is one, else as an absolute or relative path like the pattern.
+func (sh *shell) display(pattern, name string) string {
+	if strings.Contains(pattern, "://") {
+		return name
+	}
+	p := uriPath(name)
+	if path.IsAbs(pattern) {
+		return p
+	}
+	buf := make([]byte, 4096)
+	if wd, err := sh.fs.GetWorkingDirectory(buf, uint32(len(buf))); err == nil {
+		if rel := strings.TrimPrefix(p, strings.TrimSuffix(uriPath(string(wd)), "/")+"/"); rel != p {
+			return rel
+		}
+	}
+	return p
+}
+
+// uriPath returns the path of a URI, such as the Name of a FileInfo.
+func uriPath(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		return u.Path
+	}
+	return name
+}
+
+// child returns the item of info, listed in the directory it.
+func (it item) child(info *hdfs.FileInfo) item {
+	name := path.Base(uriPath(info.Name))
+	switch {
+	case it.path == ".":
+		return item{name, info}
+	case strings.HasSuffix(it.path, "/"):
+		return item{it.path + name, info}
+	}
+	return item{it.path + "/" + name, info}
+}
+
+// children lists the directory it by name.
+func (sh *shell) children(it item) ([]item, error) {
+	infos, err := sh.fs.ListDirectory(it.path)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]item, len(infos))
+	for i, info := range infos {
+		items[i] = it.child(info)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].path < items[j].path })
+	return items, nil
+}
+
+// walk calls fn on it and, if it is a directory and recursive is set, on
+// everything below it, parents first; failures to list are reported.
+func (sh *shell) walk(it item, recursive bool, fn func(it item)) {
+	fn(it)
+	if !recursive || !it.info.IsDir() {
+		return
+	}
+	children, err := sh.children(it)
+	if err != nil {
+		sh.fail(it.path, err)
+		return
+	}
+	for _, child := range children {
+		sh.walk(child, true, fn)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func newShell(t *testing.T) (*shell, *hdfstest.MemFS) {
+	m := hdfstest.NewMemFS()
+	now := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+	m.SetClock(func() time.Time { return now })
+	sh := &shell{fs: m, stdin: strings.NewReader(""), loc: time.UTC, poll: time.Millisecond}
+	return sh, m
+}
+
+// run runs a command line, returning its exit code and output.
+func run(sh *shell, line string) (status int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	sh.stdout, sh.stderr = &out, &errOut
+	status = sh.run(strings.Fields(line))
+	return status, out.String(), errOut.String()
+}
+
+func writeFile(t *testing.T, fsys hdfs.FileSystem, p, data string) {
+	f, err := fsys.Open(p, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating %s: %v\n", p, err)
+	}
+	f.Write([]byte(data))
+	if err := f.Close(); err != nil {
+		t.Fatalf("Error on closing %s: %v\n", p, err)
+	}
+}
+
+func readFile(t *testing.T, fsys hdfs.FileSystem, p string) string {
+	f, err := fsys.Open(p, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s: %v\n", p, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", p, err)
+	}
+	return string(b)
+}
+
+func TestCommands(t *testing.T) {
+	sh, m := newShell(t)
+	m.SetNonDFSUsed(1 << 30)
+	m.CreateDirectory("/data/logs")
+	writeFile(t, m, "/data/a.txt", "hello")
+	writeFile(t, m, "/data/logs/1.log", strings.Repeat("x", 1536))
+
+	for _, c := range []struct {
+		line, stdout, stderr string
+		status               int
+	}{
+		{"-ls /data", "Found 2 items\n" +
+			"-rw-r--r--   3 hdfs supergroup          5 2017-07-14 02:40 /data/a.txt\n" +
+			"drwxr-xr-x   - hdfs supergroup          0 2017-07-14 02:40 /data/logs\n", "", 0},
+		{"ls -h /data/logs", "Found 1 items\n" +
+			"-rw-r--r--   3 hdfs supergroup      1.5 K 2017-07-14 02:40 /data/logs/1.log\n", "", 0},
+		{"-ls -R /data", "" +
+			"-rw-r--r--   3 hdfs supergroup          5 2017-07-14 02:40 /data/a.txt\n" +
+			"drwxr-xr-x   - hdfs supergroup          0 2017-07-14 02:40 /data/logs\n" +
+			"-rw-r--r--   3 hdfs supergroup       1536 2017-07-14 02:40 /data/logs/1.log\n", "", 0},
+		{"-ls -d /data/*.txt", "-rw-r--r--   3 hdfs supergroup          5 2017-07-14 02:40 /data/a.txt\n", "", 0},
+		{"-ls /missing", "", "ls: `/missing': No such file or directory\n", 1},
+		{"-ls /data/*.csv", "", "ls: `/data/*.csv': No such file or directory\n", 1},
+		{"-cat /data/a.txt /data/a.txt", "hellohello", "", 0},
+		{"-cat /data/logs", "", "cat: `/data/logs': Is a directory\n", 1},
+		{"-test -d /data/logs", "", "", 0},
+		{"-test -f /data/logs", "", "", 1},
+		{"-test -e /missing", "", "", 1},
+		{"-test -s /data/a.txt", "", "", 0},
+		{"-test -z /data/a.txt", "", "", 1},
+		{"-stat /data/a.txt", "2017-07-14 02:40:00\n", "", 0},
+		{"-stat %F:%n:%b:%r:%u:%g:%a:%A /data/a.txt /data/logs", "regular file:a.txt:5:3:hdfs:supergroup:644:rw-r--r--\n" +
+			"directory:logs:0:0:hdfs:supergroup:755:rwxr-xr-x\n", "", 0},
+		{"-du /data", "5     15    /data/a.txt\n1536  4608  /data/logs\n", "", 0},
+		{"-du -s -h /data", "1.5 K  4.5 K  /data\n", "", 0},
+		{"-mkdir /data/logs", "", "mkdir: `/data/logs': File exists\n", 1},
+		{"-mkdir /x/y", "", "mkdir: `/x/y': No such file or directory\n", 1},
+		{"-mkdir -p /x/y /data/logs", "", "", 0},
+		{"-mkdir -p /data/a.txt", "", "mkdir: `/data/a.txt': Is not a directory\n", 1},
+		{"-touchz /x/y/empty", "", "", 0},
+		{"-touchz /data/a.txt", "", "touchz: `/data/a.txt': Not a zero-length file\n", 1},
+		{"-cp /data/a.txt /x", "", "", 0},
+		{"-cp /data/a.txt /x", "", "cp: `/x/a.txt': File exists\n", 1},
+		{"-cp -f /data/a.txt /x/a.txt", "", "", 0},
+		{"-cp -f /data/a.txt /data/a.txt", "", "", 0},
+		{"-cp /data/logs /x/logs2", "", "", 0},
+		{"-mv /x/a.txt /x/y/empty /data/logs", "", "", 0},
+		{"-mv /data/a.txt /data/logs/1.log /x/y/z", "", "mv: `/x/y/z': No such file or directory\n", 1},
+		{"-mv /data/a.txt /data/logs/a.txt", "", "mv: `/data/logs/a.txt': File exists\n", 1},
+		{"-chmod -R go-rx,o+t /x", "", "", 0},
+		{"-chmod 640 /data/a.txt", "", "", 0},
+		{"-chmod u+q /data/a.txt", "", "-chmod: chmod : mode 'u+q' does not match the expected pattern.\n" +
+			"Usage: gohdfs [generic options] -chmod [-R] <MODE[,MODE]... | OCTALMODE> PATH...\n", 255},
+		{"-chown -R alice:staff /x", "", "", 0},
+		{"-chown :staff /data/a.txt", "", "", 0},
+		{"-setrep 2 /data", "Replication 2 set: /data/a.txt\n" +
+			"Replication 2 set: /data/logs/1.log\n" +
+			"Replication 2 set: /data/logs/a.txt\n" +
+			"Replication 2 set: /data/logs/empty\n", "", 0},
+		{"-setrep -w 1 /data/a.txt", "Replication 1 set: /data/a.txt\nWaiting for /data/a.txt ... done\n", "", 0},
+		{"-rm /data/logs", "", "rm: `/data/logs': Is a directory\n", 1},
+		{"-rm -r /data/logs /missing", "Deleted /data/logs\n", "rm: `/missing': No such file or directory\n", 1},
+		{"-rm -f /missing", "", "", 0},
+		{"-df", "Filesystem                      Size  Used      Available  Use%\n" +
+			"hdfs://localhost:8020  1099511627776  4613  1098437881339    0%\n", "", 0},
+		{"-frob /", "", "-frob: Unknown command\n", 255},
+		{"-ls -x /", "", "-ls: Illegal option -x\nUsage: gohdfs [generic options] -ls [-d] [-h] [-R] [<path> ...]\n", 255},
+		{"-setrep 1", "", "-setrep: Not enough arguments: expected 2 but got 1\nUsage: gohdfs [generic options] -setrep [-R] [-w] <rep> <path>\n", 255},
+	} {
+		status, stdout, stderr := run(sh, c.line)
+		if status != c.status || stdout != c.stdout || !strings.HasPrefix(stderr, c.stderr) || c.stderr == "" && stderr != "" {
+			t.Errorf("%s: got %d\n%s%s\nwant %d\n%s%s\n", c.line, status, stdout, stderr, c.status, c.stdout, c.stderr)
+		}
+	}
+
+	if got := readFile(t, m, "/data/a.txt"); got != "hello" {
+		t.Errorf("File copied over itself: got %q\n", got)
+	}
+	if got := readFile(t, m, "/x/logs2/1.log"); len(got) != 1536 {
+		t.Errorf("Copied directory: got %d bytes\n", len(got))
+	}
+	for p, want := range map[string]int16{"/x": 01700, "/x/y": 01700, "/x/logs2/1.log": 01600, "/data/a.txt": 0640} {
+		if info, err := m.GetPathInfo(p); err != nil || info.Permissions != want {
+			t.Errorf("Permission of %s: got %o, %v, want %o\n", p, info.Permissions, err, want)
+		}
+	}
+	for p, want := range map[string]string{"/x/y": "alice:staff", "/data/a.txt": "hdfs:staff"} {
+		if info, err := m.GetPathInfo(p); err != nil || info.Owner+":"+info.Group != want {
+			t.Errorf("Owner of %s: got %v, %v, want %s\n", p, info, err, want)
+		}
+	}
+}
+
+func TestPutGet(t *testing.T) {
+	sh, m := newShell(t)
+	m.CreateDirectory("/data")
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.txt")
+	ioutil.WriteFile(local, []byte("local data"), 0600)
+	os.MkdirAll(filepath.Join(dir, "tree", "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "tree", "sub", "f"), []byte("f"), 0644)
+
+	for _, c := range []struct {
+		line, stderr string
+		status       int
+	}{
+		{"-put " + local + " /data", "", 0},
+		{"-put " + local + " /data", "put: `/data/local.txt': File exists\n", 1},
+		{"-put -f -p " + local + " /data/local.txt", "", 0},
+		{"-put " + filepath.Join(dir, "tree") + " /data/tree", "", 0},
+		{"-put " + filepath.Join(dir, "missing") + " /data", "put: `" + filepath.Join(dir, "missing") + "': No such file or directory\n", 1},
+		{"-get /data/local.txt " + filepath.Join(dir, "got.txt"), "", 0},
+		{"-get /data/local.txt " + filepath.Join(dir, "got.txt"), "get: `" + filepath.Join(dir, "got.txt") + "': File exists\n", 1},
+		{"-get /data/tree " + filepath.Join(dir, "tree2"), "", 0},
+	} {
+		status, _, stderr := run(sh, c.line)
+		if status != c.status || stderr != c.stderr {
+			t.Errorf("%s: got %d, %s\nwant %d, %s\n", c.line, status, stderr, c.status, c.stderr)
+		}
+	}
+	if got := readFile(t, m, "/data/local.txt"); got != "local data" {
+		t.Errorf("Put file: got %q\n", got)
+	}
+	if info, err := m.GetPathInfo("/data/local.txt"); err != nil || info.Permissions != 0600 {
+		t.Errorf("Put file with -p: got %v, %v\n", info, err)
+	}
+	if got := readFile(t, m, "/data/tree/sub/f"); got != "f" {
+		t.Errorf("Put tree: got %q\n", got)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "got.txt")); err != nil || string(b) != "local data" {
+		t.Errorf("Got file: got %q, %v\n", b, err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "tree2", "sub", "f")); err != nil || string(b) != "f" {
+		t.Errorf("Got tree: got %q, %v\n", b, err)
+	}
+
+	sh.stdin = strings.NewReader("from stdin")
+	if status, _, stderr := run(sh, "-put - /data/stdin"); status != 0 || stderr != "" {
+		t.Errorf("Put from stdin: got %d, %s\n", status, stderr)
+	}
+	if got := readFile(t, m, "/data/stdin"); got != "from stdin" {
+		t.Errorf("Put from stdin: got %q\n", got)
+	}
+	if infos, err := m.ListDirectory("/data"); err != nil || len(infos) != 3 {
+		t.Errorf("Files left by put: got %v, %v\n", infos, err)
+	}
+}
+
+// corruptingFS drops the last byte written to files it creates, as a
+// write lost on its way to the datanodes.
+type corruptingFS struct {
+	*hdfstest.MemFS
+}
+
+type corruptingFile struct {
+	hdfs.FileHandle
+	last []byte
+}
+
+func (c corruptingFS) Open(path string, flags int, buffersize int, replication int, blocksize uint32) (hdfs.FileHandle, error) {
+	f, err := c.MemFS.Open(path, flags, buffersize, replication, blocksize)
+	if err != nil || flags&hdfs.O_WRONLY == 0 {
+		return f, err
+	}
+	return &corruptingFile{FileHandle: f}, nil
+}
+
+func (f *corruptingFile) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := f.FileHandle.Write(f.last); err != nil {
+		return 0, err
+	}
+	f.last = append(f.last[:0], p[len(p)-1])
+	if _, err := f.FileHandle.Write(p[:len(p)-1]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func TestPutVerify(t *testing.T) {
+	sh, m := newShell(t)
+	m.CreateDirectory("/data")
+	local := filepath.Join(t.TempDir(), "local.txt")
+	ioutil.WriteFile(local, []byte("local data"), 0600)
+
+	for _, c := range []struct {
+		line, stderr string
+		status       int
+	}{
+		{"-put -verify " + local + " /data/a", "", 0},
+		{"-put --verify -f " + local + " /data/a", "", 0},
+		{"-put -verify - /data/b", "-put: -verify needs a local file to read again\nUsage: gohdfs [generic options] -put " + commands["put"].usage + "\n", 255},
+		{"-checksum /data", "checksum: `/data': Is a directory\n", 1},
+	} {
+		status, _, stderr := run(sh, c.line)
+		if status != c.status || stderr != c.stderr {
+			t.Errorf("%s: got %d, %s\nwant %d, %s\n", c.line, status, stderr, c.status, c.stderr)
+		}
+	}
+	want, err := hdfs.ComputeChecksum(strings.NewReader("local data"), hdfs.ChecksumOptions{BlockSize: 128 << 20})
+	if err != nil {
+		t.Fatalf("Error on computing checksum: %v\n", err)
+	}
+	if status, stdout, _ := run(sh, "-checksum /data/a"); status != 0 || stdout != "/data/a\t"+want.String()+"\n" {
+		t.Errorf("Checksum: got %d, %q, want %q\n", status, stdout, want)
+	}
+
+	sh.fs = corruptingFS{m}
+	if status, _, stderr := run(sh, "-put -f -verify "+local+" /data/a"); status != 1 || stderr != "put: `/data/a': Checksum mismatch\n" {
+		t.Errorf("Put corrupted with -verify: got %d, %s\n", status, stderr)
+	}
+	if got := readFile(t, m, "/data/a"); got != "local data" {
+		t.Errorf("File replaced by a corrupted copy: got %q\n", got)
+	}
+	if infos, err := m.ListDirectory("/data"); err != nil || len(infos) != 1 {
+		t.Errorf("Files left by put: got %v, %v\n", infos, err)
+	}
+}
+
+func TestAcl(t *testing.T) {
+	sh, m := newShell(t)
+	m.CreateDirectory("/data")
+	writeFile(t, m, "/data/a", "data")
+
+	for _, c := range []struct {
+		line, stdout, stderr string
+		status               int
+	}{
+		{"-getfacl /data/a", "# file: /data/a\n# owner: hdfs\n# group: supergroup\nuser::rw-\ngroup::r--\nother::r--\n\n", "", 0},
+		{"-setfacl -R -m user:alice:rwx,default:user:alice:r-x /data", "", "", 0},
+		{"-chmod 1750 /data", "", "", 0},
+		{"-getfacl /data", "# file: /data\n# owner: hdfs\n# group: supergroup\n# flags: --T\n" +
+			"user::rwx\nuser:alice:rwx\t#effective:r-x\ngroup::r-x\nmask::r-x\nother::---\n" +
+			"default:user::rwx\ndefault:user:alice:r-x\ndefault:group::r-x\ndefault:mask::r-x\ndefault:other::r-x\n\n", "", 0},
+		{"-setfacl -x user:alice /data/a", "", "", 0},
+		{"-setfacl --set user::rw-,group::---,other::--- /data/a", "", "", 0},
+		{"-setfacl -k /data", "", "", 0},
+		{"-setfacl -b -R /data", "", "", 0},
+		{"-getfacl -R /data", "# file: /data\n# owner: hdfs\n# group: supergroup\n# flags: --T\nuser::rwx\ngroup::r-x\nother::---\n\n" +
+			"# file: /data/a\n# owner: hdfs\n# group: supergroup\nuser::rw-\ngroup::---\nother::---\n\n", "", 0},
+		{"-setfacl -x user:: /data/a", "", "setfacl: `/data/a': invalid argument\n", 1},
+		{"-getfacl /missing", "", "getfacl: `/missing': No such file or directory\n", 1},
+		{"-setfacl -m user:alice:rwz /data", "", "-setfacl: hdfs: invalid ACL entry \"user:alice:rwz\"\n", 255},
+		{"-setfacl -b -m user:alice:rwx /data", "", "-setfacl: Specified flags contains both remove and modify flags\n", 255},
+		{"-setfacl -m /data", "", "-setfacl: Missing either <acl_spec> or <path>\n", 255},
+	} {
+		status, stdout, stderr := run(sh, c.line)
+		if status != c.status || stdout != c.stdout || !strings.HasPrefix(stderr, c.stderr) || c.stderr == "" && stderr != "" {
+			t.Errorf("%s: got %d\n%s%s\nwant %d\n%s%s\n", c.line, status, stdout, stderr, c.status, c.stdout, c.stderr)
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	sh, m := newShell(t)
+	m.SetTrashInterval(time.Hour)
+	writeFile(t, m, "/data/a", "a")
+	writeFile(t, m, "/data/b", "b")
+	writeFile(t, m, "/data/c", "c")
+
+	for _, c := range []struct {
+		line, stdout, stderr string
+		status               int
+	}{
+		{"-rm /data/a", "Moved: '/data/a' to trash at: /user/hdfs/.Trash/Current/data/a\n", "", 0},
+		{"-rm -skipTrash /data/b", "Deleted /data/b\n", "", 0},
+		{"-rm /user/hdfs/.Trash/Current/data/a", "Deleted /user/hdfs/.Trash/Current/data/a\n", "", 0},
+		{"-rm /data/c", "Moved: '/data/c' to trash at: /user/hdfs/.Trash/Current/data/c\n", "", 0},
+		{"-expunge", "", "", 0},
+		{"-expunge /data", "", "-expunge: Too many arguments: expected 0 but got 1\n", 255},
+	} {
+		status, stdout, stderr := run(sh, c.line)
+		if status != c.status || stdout != c.stdout || !strings.HasPrefix(stderr, c.stderr) || c.stderr == "" && stderr != "" {
+			t.Errorf("%s: got %d\n%s%s\nwant %d\n%s%s\n", c.line, status, stdout, stderr, c.status, c.stdout, c.stderr)
+		}
+	}
+	if infos, err := m.ListDirectory("/user/hdfs/.Trash"); err != nil || len(infos) != 1 || strings.HasSuffix(infos[0].Name, "/Current") {
+		t.Errorf("Trash after expunge: got %v, %v\n", infos, err)
+	}
+}
+
+// limitWriter keeps the first n bytes written to it and fails on more, as
+// a pipe closed by its reader.
+type limitWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if left := w.n - w.Len(); len(p) > left {
+		w.Buffer.Write(p[:left])
+		return left, io.ErrClosedPipe
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestTail(t *testing.T) {
+	sh, m := newShell(t)
+	writeFile(t, m, "/big", strings.Repeat("x", 1000)+strings.Repeat("y", 1024))
+	if status, stdout, stderr := run(sh, "-tail /big"); status != 0 || stdout != strings.Repeat("y", 1024) || stderr != "" {
+		t.Errorf("tail: got %d, %d bytes, %s\n", status, len(stdout), stderr)
+	}
+	if status, _, stderr := run(sh, "-tail -f /missing"); status != 1 || stderr != "tail: `/missing': No such file or directory\n" {
+		t.Errorf("tail -f of missing file: got %d, %s\n", status, stderr)
+	}
+
+	f, err := m.Open("/log", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating /log: %v\n", err)
+	}
+	defer f.Close()
+	io.WriteString(f, "one\n")
+	f.Flush()
+	out := &limitWriter{n: 8}
+	done := make(chan int, 1)
+	go func() {
+		sh := &shell{fs: m, stdout: out, stderr: ioutil.Discard, poll: time.Millisecond, name: "tail"}
+		done <- sh.run([]string{"-tail", "-f", "/log"})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	io.WriteString(f, "two\n")
+	f.Flush()
+	time.Sleep(10 * time.Millisecond)
+	io.WriteString(f, "three\n")
+	f.Flush()
+	select {
+	case status := <-done:
+		if status != 0 || out.String() != "one\ntwo\n" {
+			t.Errorf("tail -f: got %d, %q\n", status, out.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("tail -f still running after its output closed\n")
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0: "0", 1023: "1023", 1024: "1 K", 1536: "1.5 K", 1<<20 - 1: "1.0 M",
+		10 << 30: "10 G", 3<<40 + 1<<39: "3.5 T",
+	} {
+		if got := humanSize(n); got != want {
+			t.Errorf("humanSize(%d): got %q, want %q\n", n, got, want)
+		}
+	}
+}
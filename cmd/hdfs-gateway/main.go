@@ -0,0 +1,76 @@
+// Command hdfs-gateway serves HDFS over the WebHDFS REST API, for tools
+// that speak WebHDFS, without running a Java HttpFS:
+//
+//	hdfs-gateway -addr :14000 -namenode hdfs://nn:8020
+//
+// Requests are made as the user named by their user.name parameter, or as
+// the user running the gateway without one. That name is not
+// authenticated: expose the gateway only to the users it acts for, or
+// behind a proxy that authenticates them.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/webhdfs"
+)
+
+func main() {
+	addr := flag.String("addr", ":14000", "address to listen on")
+	namenode := flag.String("namenode", "", "URI of the file system, fs.default.name if empty")
+	confDir := flag.String("conf", "", "directory holding core-site.xml and hdfs-site.xml")
+	certFile := flag.String("tls-cert", "", "certificate file, to serve HTTPS")
+	keyFile := flag.String("tls-key", "", "key file of the certificate")
+	maxConns := flag.Int("max-conns", 64, "connections to the file system kept, one per user")
+	flag.Parse()
+
+	h := webhdfs.NewHandler(func(user string) (hdfs.FileSystem, error) {
+		opts := []hdfs.Option{hdfs.WithNamenode(*namenode), hdfs.WithUser(user)}
+		if *confDir != "" {
+			opts = append(opts, hdfs.WithConfDir(*confDir))
+		}
+		fs, err := hdfs.ConnectWithOptions(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	})
+	h.MaxConns = *maxConns
+	srv := &http.Server{Addr: *addr, Handler: h}
+
+	done := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		close(done)
+	}()
+
+	log.Printf("serving WebHDFS on %s", *addr)
+	var err error
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+	if err := h.Close(); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,84 @@
+// Command hdfs-s3gw serves directories of HDFS as the buckets of an
+// S3-compatible object store, for tools that only speak S3:
+//
+//	hdfs-s3gw -addr :9000 -namenode hdfs://nn:8020 -bucket logs=/data/logs
+//
+// Requests are not authenticated and all run as the user of the gateway:
+// expose it only to the users it acts for, or behind a proxy that
+// authenticates them.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/s3gw"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	namenode := flag.String("namenode", "", "URI of the file system, fs.default.name if empty")
+	user := flag.String("user", "", "user to act as, the current user if empty")
+	confDir := flag.String("conf", "", "directory holding core-site.xml and hdfs-site.xml")
+	certFile := flag.String("tls-cert", "", "certificate file, to serve HTTPS")
+	keyFile := flag.String("tls-key", "", "key file of the certificate")
+	buckets := make(map[string]string)
+	flag.Func("bucket", "bucket to serve, as name=directory; repeatable", func(s string) error {
+		name, dir, ok := strings.Cut(s, "=")
+		if !ok || name == "" || !strings.HasPrefix(dir, "/") {
+			return errors.New("want name=/absolute/directory")
+		}
+		buckets[name] = dir
+		return nil
+	})
+	flag.Parse()
+	if len(buckets) == 0 {
+		log.Fatal("no -bucket to serve")
+	}
+
+	opts := []hdfs.Option{hdfs.WithNamenode(*namenode), hdfs.WithUser(*user)}
+	if *confDir != "" {
+		opts = append(opts, hdfs.WithConfDir(*confDir))
+	}
+	fs, err := hdfs.ConnectWithOptions(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &http.Server{Addr: *addr, Handler: s3gw.NewHandler(fs, buckets)}
+
+	done := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		close(done)
+	}()
+
+	log.Printf("serving S3 on %s", *addr)
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+	if err := fs.Disconnect(); err != nil {
+		log.Print(err)
+	}
+}
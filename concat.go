@@ -0,0 +1,100 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// Concat appends the files srcs, in order, to the file target and deletes
+// them. The files must all differ and share the block size of target, as
+// the namenode requires to move their blocks instead of copying them.
+//
+// FileSystems that are not a Concater, or fail with ErrUnsupported, copy
+// the data instead: srcs are appended to target, then deleted once all of
+// them are. If appending fails, target is truncated back to its size when
+// the FileSystem is a Truncater.
+func Concat(fsys FileSystem, target string, srcs []string) error {
+	size, err := checkConcat(fsys, target, srcs)
+	if err != nil {
+		return err
+	}
+	if c, ok := fsys.(Concater); ok {
+		if err := c.ConcatFiles(target, srcs); !errors.Is(err, ErrUnsupported) {
+			return err
+		}
+	}
+	return concatCopy(fsys, target, size, srcs)
+}
+
+// checkConcat checks that target and srcs can be concatenated and returns
+// the size of target.
+func checkConcat(fsys FileSystem, target string, srcs []string) (int64, error) {
+	if len(srcs) == 0 {
+		return 0, &PathError{"concat", target, fmt.Errorf("%w: no files to concatenate", syscall.EINVAL)}
+	}
+	info, err := fsys.GetPathInfo(target)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, &PathError{"concat", target, syscall.EISDIR}
+	}
+	seen := map[string]bool{info.Name: true}
+	for _, src := range srcs {
+		sinfo, err := fsys.GetPathInfo(src)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case sinfo.IsDir():
+			return 0, &PathError{"concat", src, syscall.EISDIR}
+		case seen[sinfo.Name]:
+			return 0, &PathError{"concat", src, fmt.Errorf("%w: file given twice", syscall.EINVAL)}
+		case sinfo.BlockSize != info.BlockSize:
+			return 0, &PathError{"concat", src, fmt.Errorf("%w: block size %d differs from %d of %s", syscall.EINVAL, sinfo.BlockSize, info.BlockSize, target)}
+		}
+		seen[sinfo.Name] = true
+	}
+	return info.Size, nil
+}
+
+// concatCopy concatenates srcs to target, of the given size, by copying
+// their data.
+func concatCopy(fsys FileSystem, target string, size int64, srcs []string) error {
+	w, err := fsys.Open(target, O_WRONLY|O_APPEND, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if err = appendFile(fsys, src, w); err != nil {
+			break
+		}
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if _, ok := fsys.(Truncater); ok {
+			Truncate(fsys, target, size)
+		}
+		return err
+	}
+	for _, src := range srcs {
+		if err := fsys.Delete(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendFile(fsys FileSystem, src string, w io.Writer) error {
+	r, err := fsys.Open(src, O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
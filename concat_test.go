@@ -0,0 +1,95 @@
+package hdfs_test
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+// failingFS is a FileSystem failing to open one file.
+type failingFS struct {
+	*hdfstest.MemFS
+	fail string
+}
+
+func (f *failingFS) Open(path string, flags int, buffersize int, replication int, blocksize uint32) (hdfs.FileHandle, error) {
+	if path == f.fail {
+		return nil, &hdfs.PathError{Op: "open", Path: path, Err: syscall.EIO}
+	}
+	return f.MemFS.Open(path, flags, buffersize, replication, blocksize)
+}
+
+func (f *failingFS) ConcatFiles(target string, srcs []string) error {
+	return &hdfs.PathError{Op: "concat", Path: target, Err: hdfs.ErrUnsupported}
+}
+
+func TestConcat(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		wrap func(m *hdfstest.MemFS) hdfs.FileSystem
+	}{
+		{"namenode", func(m *hdfstest.MemFS) hdfs.FileSystem { return m }},
+		{"copy", func(m *hdfstest.MemFS) hdfs.FileSystem { return struct{ hdfs.FileSystem }{m} }},
+		{"unsupported", func(m *hdfstest.MemFS) hdfs.FileSystem { return &failingFS{MemFS: m} }},
+	} {
+		m := hdfstest.NewMemFS()
+		put(t, m, "/target", "abc", 0)
+		put(t, m, "/d/part-0", "def", 0)
+		put(t, m, "/d/part-1", "", 0)
+		put(t, m, "/d/part-2", "ghi", 0)
+		if err := hdfs.Concat(tt.wrap(m), "/target", []string{"/d/part-0", "/d/part-1", "/d/part-2"}); err != nil {
+			t.Errorf("%s: Error on Concat: %v\n", tt.name, err)
+		}
+		if got := seen(t, m, "/target"); got != "abcdefghi" {
+			t.Errorf("%s: got %q\n", tt.name, got)
+		}
+		if infos, err := m.ListDirectory("/d"); err != nil || len(infos) != 0 {
+			t.Errorf("%s: sources left: %v, %v\n", tt.name, paths(infos), err)
+		}
+	}
+}
+
+func TestConcatErrors(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	put(t, m, "/target", "abc", 0)
+	put(t, m, "/src", "def", 0)
+	put(t, m, "/big", "ghi", 1<<20)
+	m.CreateDirectory("/dir")
+
+	for _, tt := range []struct {
+		target string
+		srcs   []string
+		want   error
+	}{
+		{"/target", nil, syscall.EINVAL},
+		{"/target", []string{"/src", "/src"}, syscall.EINVAL},
+		{"/target", []string{"/target"}, syscall.EINVAL},
+		{"/target", []string{"/big"}, syscall.EINVAL},
+		{"/target", []string{"/dir"}, syscall.EISDIR},
+		{"/dir", []string{"/src"}, syscall.EISDIR},
+		{"/target", []string{"/missing"}, syscall.ENOENT},
+	} {
+		if err := hdfs.Concat(m, tt.target, tt.srcs); !errors.Is(err, tt.want) {
+			t.Errorf("Concat(%s, %v): got %v, want %v\n", tt.target, tt.srcs, err, tt.want)
+		}
+	}
+	if got := seen(t, m, "/target"); got != "abc" {
+		t.Errorf("Target after failures: got %q\n", got)
+	}
+
+	// a failing copy truncates the target back and keeps the sources
+	put(t, m, "/bad", "jkl", 0)
+	fsys := &failingFS{MemFS: m, fail: "/bad"}
+	if err := hdfs.Concat(fsys, "/target", []string{"/src", "/bad"}); !errors.Is(err, syscall.EIO) {
+		t.Errorf("Concat failing to copy: got %v\n", err)
+	}
+	if got := seen(t, m, "/target"); got != "abc" {
+		t.Errorf("Target after a failed copy: got %q\n", got)
+	}
+	if err := m.Exists("/src"); err != nil {
+		t.Errorf("Source after a failed copy: %v\n", err)
+	}
+}
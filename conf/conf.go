@@ -0,0 +1,343 @@
+// Package conf reads Hadoop configuration files, such as core-site.xml and
+// hdfs-site.xml, the way org.apache.hadoop.conf.Configuration does, so that
+// Go code can see what a connection to the "default" file system resolves to
+// before making it.
+package conf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resources are the files Load reads from a configuration directory, in the
+// order Hadoop adds them; missing files are skipped.
+var Resources = []string{"core-default.xml", "core-site.xml", "hdfs-default.xml", "hdfs-site.xml"}
+
+// maxSubst bounds the number of variable expansions in a value, as in Hadoop.
+const maxSubst = 20
+
+var varPattern = regexp.MustCompile(`\$\{[^\}\$ ]+\}`)
+
+// Conf is a set of Hadoop configuration properties. Values added by later
+// resources override earlier ones, except for properties an earlier
+// resource marked final. The zero value is not usable; use New or Load.
+type Conf struct {
+	props   map[string]string
+	final   map[string]bool
+	sources map[string]string
+	system  map[string]string
+}
+
+// New returns an empty Conf whose variable expansion sees the Java system
+// properties user.name, user.home, user.dir and java.io.tmpdir of the
+// current process.
+func New() *Conf {
+	c := &Conf{
+		props:   map[string]string{},
+		final:   map[string]bool{},
+		sources: map[string]string{},
+		system:  map[string]string{"java.io.tmpdir": "/tmp"},
+	}
+	if u, err := user.Current(); err == nil {
+		c.system["user.name"] = u.Username
+		c.system["user.home"] = u.HomeDir
+	} else if name := os.Getenv("USER"); name != "" {
+		c.system["user.name"] = name
+	}
+	if dir, err := os.Getwd(); err == nil {
+		c.system["user.dir"] = dir
+	}
+	return c
+}
+
+// Load returns the configuration of the files named by Resources in dir.
+func Load(dir string) (*Conf, error) {
+	c := New()
+	for _, name := range Resources {
+		err := c.AddFile(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// LoadDefault loads the configuration directory a Hadoop client would use:
+// $HADOOP_CONF_DIR, else $HADOOP_HOME/conf, else /etc/hadoop/conf.
+func LoadDefault() (*Conf, error) {
+	return Load(DefaultDir())
+}
+
+// DefaultDir returns the directory LoadDefault loads.
+func DefaultDir() string {
+	if dir := os.Getenv("HADOOP_CONF_DIR"); dir != "" {
+		return dir
+	}
+	if home := os.Getenv("HADOOP_HOME"); home != "" {
+		return filepath.Join(home, "conf")
+	}
+	return "/etc/hadoop/conf"
+}
+
+// AddFile adds the properties of the configuration file name.
+func (c *Conf) AddFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.AddResource(f, name)
+}
+
+type xmlConfiguration struct {
+	Properties []xmlProperty `xml:"property"`
+}
+
+type xmlProperty struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+	Final string `xml:"final"`
+}
+
+// AddResource adds the properties of the configuration read from r; name
+// identifies it in errors and Source.
+func (c *Conf) AddResource(r io.Reader, name string) error {
+	var doc xmlConfiguration
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("conf: %s: %v", name, err)
+	}
+	for _, p := range doc.Properties {
+		key := strings.TrimSpace(p.Name)
+		if key == "" || c.final[key] {
+			continue
+		}
+		c.props[key] = p.Value
+		c.sources[key] = name
+		if strings.TrimSpace(p.Final) == "true" {
+			c.final[key] = true
+		}
+	}
+	return nil
+}
+
+// Set sets key to value, overriding even final properties, as
+// Configuration.set does.
+func (c *Conf) Set(key, value string) {
+	c.props[key] = value
+	c.sources[key] = "programmatically"
+}
+
+// SetSystemProperty sets a Java system property seen by variable expansion,
+// as -Dkey=value would.
+func (c *Conf) SetSystemProperty(key, value string) {
+	c.system[key] = value
+}
+
+// IsFinal reports whether key was marked final by a resource.
+func (c *Conf) IsFinal(key string) bool {
+	return c.final[key]
+}
+
+// Source returns the name of the resource key was last set from, or "".
+func (c *Conf) Source(key string) string {
+	return c.sources[key]
+}
+
+// Keys returns the names of all properties, sorted.
+func (c *Conf) Keys() []string {
+	keys := make([]string, 0, len(c.props))
+	for key := range c.props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetRaw returns the value of key without variable expansion.
+func (c *Conf) GetRaw(key string) (string, bool) {
+	v, ok := c.props[key]
+	return v, ok
+}
+
+// Get returns the value of key with ${var} references expanded to Java
+// system properties, environment variables (${env.NAME}) or other
+// properties, in that order. As in Hadoop, expansion stops at the first
+// reference that cannot be resolved, leaving it in place.
+func (c *Conf) Get(key string) (string, bool) {
+	v, ok := c.props[key]
+	if !ok {
+		return "", false
+	}
+	return c.expand(v), true
+}
+
+func (c *Conf) expand(v string) string {
+	for i := 0; i < maxSubst; i++ {
+		loc := varPattern.FindStringIndex(v)
+		if loc == nil {
+			return v
+		}
+		name := v[loc[0]+2 : loc[1]-1]
+		val, ok := c.system[name]
+		if !ok && strings.HasPrefix(name, "env.") {
+			val, ok = os.LookupEnv(name[len("env."):])
+		}
+		if !ok {
+			val, ok = c.props[name]
+		}
+		if !ok {
+			return v
+		}
+		v = v[:loc[0]] + val + v[loc[1]:]
+	}
+	return v
+}
+
+// GetString returns the expanded value of key, or def if it is not set.
+func (c *Conf) GetString(key, def string) string {
+	if v, ok := c.Get(key); ok {
+		return v
+	}
+	return def
+}
+
+// lookup returns the trimmed value of key, reporting false if it is unset or
+// blank, in which case typed getters return their default.
+func (c *Conf) lookup(key string) (string, bool) {
+	v, ok := c.Get(key)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+func parseError(key, v, what string) error {
+	return fmt.Errorf("conf: %s: invalid %s %q", key, what, v)
+}
+
+// GetBool returns the value of key as "true" or "false", ignoring case.
+func (c *Conf) GetBool(key string, def bool) (bool, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return def, nil
+	}
+	switch strings.ToLower(v) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return def, parseError(key, v, "boolean")
+}
+
+// GetInt returns the value of key as a decimal or 0x-prefixed hexadecimal
+// integer.
+func (c *Conf) GetInt(key string, def int64) (int64, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := parseInt(v)
+	if err != nil {
+		return def, parseError(key, v, "integer")
+	}
+	return n, nil
+}
+
+func parseInt(v string) (int64, error) {
+	neg := strings.HasPrefix(v, "-")
+	digits := strings.TrimPrefix(v, "-")
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		n, err := strconv.ParseInt(digits[2:], 16, 64)
+		if neg {
+			n = -n
+		}
+		return n, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// GetSize returns the value of key as a number of bytes, with an optional
+// binary suffix k, m, g, t, p or e in either case, so that "128m" is
+// 128<<20, as Configuration.getLongBytes does.
+func (c *Conf) GetSize(key string, def int64) (int64, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return def, nil
+	}
+	digits, shift := v, uint(0)
+	if i := strings.IndexByte("kmgtpe", lower(v[len(v)-1])); i >= 0 {
+		digits, shift = v[:len(v)-1], uint(10*(i+1))
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n<<shift>>shift != n {
+		return def, parseError(key, v, "size")
+	}
+	return n << shift, nil
+}
+
+func lower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	// longer suffixes first, so that "ms" is not taken for "s"
+	{"ns", time.Nanosecond},
+	{"us", time.Microsecond},
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+}
+
+// GetDuration returns the value of key as an integer with an optional unit
+// suffix ns, us, ms, s, m, h or d, ignoring case, as
+// Configuration.getTimeDuration does; a bare number is in unit.
+func (c *Conf) GetDuration(key string, def, unit time.Duration) (time.Duration, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return def, nil
+	}
+	lv := strings.ToLower(v)
+	for _, u := range durationUnits {
+		if strings.HasSuffix(lv, u.suffix) {
+			lv, unit = strings.TrimSpace(lv[:len(lv)-len(u.suffix)]), u.unit
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lv, 10, 64)
+	if err != nil {
+		return def, parseError(key, v, "duration")
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// GetStrings returns the comma-separated values of key with surrounding
+// white space and empty values removed, or nil if it is not set.
+func (c *Conf) GetStrings(key string) []string {
+	v, ok := c.Get(key)
+	if !ok {
+		return nil
+	}
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
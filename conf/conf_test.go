@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const coreSite = `<?xml version="1.0"?>
+<configuration>
+  <property><name>fs.defaultFS</name><value>hdfs://cluster</value></property>
+  <property><name>hadoop.tmp.dir</name><value>/tmp/hadoop-${user.name}</value><final>true</final></property>
+  <property><name>io.file.buffer.size</name><value>4096</value></property>
+</configuration>`
+
+const hdfsSite = `<?xml version="1.0"?>
+<configuration>
+  <property><name>hadoop.tmp.dir</name><value>/elsewhere</value></property>
+  <property><name>io.file.buffer.size</name><value>64k</value></property>
+  <property><name>dfs.nameservices</name><value>cluster, other</value></property>
+  <property><name>dfs.ha.namenodes.cluster</name><value>nn1,nn2</value></property>
+  <property><name>dfs.namenode.rpc-address.cluster.nn1</name><value>nn1.example.com:8020</value></property>
+  <property><name>dfs.namenode.rpc-address.cluster.nn2</name><value>nn2.example.com:8020</value></property>
+  <property><name>dfs.namenode.http-address.cluster.nn1</name><value>nn1.example.com:50070</value></property>
+  <property><name>dfs.namenode.rpc-address.other</name><value>other.example.com:9000</value></property>
+  <property><name>dfs.namenode.name.dir</name><value>${hadoop.tmp.dir}/dfs/name</value></property>
+  <property><name>dfs.client.socket-timeout</name><value>60s</value></property>
+  <property><name>dfs.heartbeat.interval</name><value>3</value></property>
+  <property><name>dfs.permissions</name><value>False</value></property>
+  <property><name>dfs.loop</name><value>${dfs.loop}</value></property>
+  <property><name>dfs.unresolved</name><value>${no.such.var}/x</value></property>
+</configuration>`
+
+func load(t *testing.T) *Conf {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "core-site.xml"), []byte(coreSite), 0644)
+	os.WriteFile(filepath.Join(dir, "hdfs-site.xml"), []byte(hdfsSite), 0644)
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Error on loading: %v\n", err)
+	}
+	c.SetSystemProperty("user.name", "alice")
+	return c
+}
+
+func TestLoad(t *testing.T) {
+	c := load(t)
+	if v, _ := c.Get("hadoop.tmp.dir"); v != "/tmp/hadoop-alice" {
+		t.Errorf("final property overridden: got %q\n", v)
+	}
+	if !c.IsFinal("hadoop.tmp.dir") || !strings.HasSuffix(c.Source("hadoop.tmp.dir"), "core-site.xml") {
+		t.Errorf("final property: IsFinal %v, Source %q\n", c.IsFinal("hadoop.tmp.dir"), c.Source("hadoop.tmp.dir"))
+	}
+	if v, _ := c.Get("dfs.namenode.name.dir"); v != "/tmp/hadoop-alice/dfs/name" {
+		t.Errorf("nested expansion: got %q\n", v)
+	}
+	if v, _ := c.Get("dfs.unresolved"); v != "${no.such.var}/x" {
+		t.Errorf("unresolved expansion: got %q\n", v)
+	}
+	if v, _ := c.Get("dfs.loop"); v != "${dfs.loop}" {
+		t.Errorf("self expansion: got %q\n", v)
+	}
+	os.Setenv("CONF_TEST_VAR", "env")
+	c.Set("from.env", "${env.CONF_TEST_VAR}")
+	if v, _ := c.Get("from.env"); v != "env" || c.Source("from.env") != "programmatically" {
+		t.Errorf("environment expansion: got %q\n", v)
+	}
+	c.Set("hadoop.tmp.dir", "/set")
+	if v, _ := c.Get("hadoop.tmp.dir"); v != "/set" {
+		t.Errorf("Set on a final property: got %q\n", v)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Error on loading a missing directory: %v\n", err)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "core-site.xml"), []byte("<configuration><property>"), 0644)
+	if _, err := Load(dir); err == nil {
+		t.Errorf("Load of a malformed file: no error\n")
+	}
+}
+
+func TestGetters(t *testing.T) {
+	c := load(t)
+	if n, err := c.GetSize("io.file.buffer.size", 0); n != 64<<10 || err != nil {
+		t.Errorf("GetSize: got %d, %v\n", n, err)
+	}
+	if n, err := c.GetSize("missing", 7); n != 7 || err != nil {
+		t.Errorf("GetSize of a missing key: got %d, %v\n", n, err)
+	}
+	if d, err := c.GetDuration("dfs.client.socket-timeout", 0, time.Millisecond); d != time.Minute || err != nil {
+		t.Errorf("GetDuration: got %v, %v\n", d, err)
+	}
+	if d, err := c.GetDuration("dfs.heartbeat.interval", 0, time.Second); d != 3*time.Second || err != nil {
+		t.Errorf("GetDuration without unit: got %v, %v\n", d, err)
+	}
+	if b, err := c.GetBool("dfs.permissions", true); b || err != nil {
+		t.Errorf("GetBool: got %v, %v\n", b, err)
+	}
+	if _, err := c.GetBool("fs.defaultFS", true); err == nil {
+		t.Errorf("GetBool of a URI: no error\n")
+	}
+	c.Set("hex", "0x10")
+	if n, err := c.GetInt("hex", 0); n != 16 || err != nil {
+		t.Errorf("GetInt: got %d, %v\n", n, err)
+	}
+	if _, err := c.GetInt("io.file.buffer.size", 0); err == nil {
+		t.Errorf("GetInt of a size: no error\n")
+	}
+	if list := c.GetStrings("dfs.nameservices"); !reflect.DeepEqual(list, []string{"cluster", "other"}) {
+		t.Errorf("GetStrings: got %q\n", list)
+	}
+}
+
+func TestNamenodes(t *testing.T) {
+	c := load(t)
+	if fs := c.DefaultFS(); fs != "hdfs://cluster" {
+		t.Errorf("DefaultFS: got %q\n", fs)
+	}
+	if fs := New().DefaultFS(); fs != "file:///" {
+		t.Errorf("DefaultFS of an empty Conf: got %q\n", fs)
+	}
+	if !c.IsHA("cluster") || c.IsHA("other") {
+		t.Errorf("IsHA: got %v for cluster, %v for other\n", c.IsHA("cluster"), c.IsHA("other"))
+	}
+	want := []Namenode{
+		{"nn1", "nn1.example.com:8020", "nn1.example.com:50070"},
+		{"nn2", "nn2.example.com:8020", ""},
+	}
+	if nns, err := c.Resolve(""); !reflect.DeepEqual(nns, want) || err != nil {
+		t.Errorf("Resolve default: got %v, %v\n", nns, err)
+	}
+	if nns, _ := c.Resolve("hdfs://other"); !reflect.DeepEqual(nns, []Namenode{{RPCAddress: "other.example.com:9000"}}) {
+		t.Errorf("Resolve non-HA nameservice: got %v\n", nns)
+	}
+	if nns, _ := c.Resolve("hdfs://host"); !reflect.DeepEqual(nns, []Namenode{{RPCAddress: "host:8020"}}) {
+		t.Errorf("Resolve host: got %v\n", nns)
+	}
+	if nns, _ := c.Resolve("file:///"); nns != nil {
+		t.Errorf("Resolve local: got %v\n", nns)
+	}
+}
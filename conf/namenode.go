@@ -0,0 +1,97 @@
+package conf
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultFS returns the URI of the default file system: fs.defaultFS, or
+// its deprecated name fs.default.name, or "file:///" if neither is set.
+func (c *Conf) DefaultFS() string {
+	for _, key := range []string{"fs.defaultFS", "fs.default.name"} {
+		if v, ok := c.lookup(key); ok {
+			return v
+		}
+	}
+	return "file:///"
+}
+
+// Nameservices returns the logical names listed in dfs.nameservices, or
+// their older name dfs.federation.nameservices.
+func (c *Conf) Nameservices() []string {
+	if list := c.GetStrings("dfs.nameservices"); list != nil {
+		return list
+	}
+	return c.GetStrings("dfs.federation.nameservices")
+}
+
+// IsHA reports whether nameservice has more than one namenode listed in
+// dfs.ha.namenodes.<nameservice>.
+func (c *Conf) IsHA(nameservice string) bool {
+	return len(c.GetStrings("dfs.ha.namenodes."+nameservice)) > 1
+}
+
+// Namenode is a namenode of a nameservice. ID is empty unless the
+// nameservice is configured for high availability.
+type Namenode struct {
+	ID          string
+	RPCAddress  string
+	HTTPAddress string
+}
+
+// Namenodes returns the namenodes of nameservice, in the order of
+// dfs.ha.namenodes.<nameservice>, with their dfs.namenode.rpc-address and
+// dfs.namenode.http-address suffixed by the nameservice and namenode ID.
+// A nameservice without HA configuration yields the single namenode of its
+// dfs.namenode.rpc-address.<nameservice>, if set, and nil otherwise.
+func (c *Conf) Namenodes(nameservice string) []Namenode {
+	ids := c.GetStrings("dfs.ha.namenodes." + nameservice)
+	if ids == nil {
+		nn := c.namenode(nameservice)
+		if nn.RPCAddress == "" {
+			return nil
+		}
+		return []Namenode{nn}
+	}
+	namenodes := make([]Namenode, 0, len(ids))
+	for _, id := range ids {
+		nn := c.namenode(nameservice + "." + id)
+		nn.ID = id
+		namenodes = append(namenodes, nn)
+	}
+	return namenodes
+}
+
+func (c *Conf) namenode(suffix string) Namenode {
+	return Namenode{
+		RPCAddress:  c.GetString("dfs.namenode.rpc-address."+suffix, ""),
+		HTTPAddress: c.GetString("dfs.namenode.http-address."+suffix, ""),
+	}
+}
+
+// Resolve returns the namenodes to try for the file system uri, "" standing
+// for DefaultFS: those of the nameservice named by its host if configured,
+// else the host and port of uri itself, with the default port 8020. Local
+// file systems have no namenode and yield nil.
+func (c *Conf) Resolve(uri string) ([]Namenode, error) {
+	if uri == "" {
+		uri = c.DefaultFS()
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "hdfs" || u.Host == "" {
+		return nil, nil
+	}
+	if u.Port() == "" {
+		if namenodes := c.Namenodes(u.Host); namenodes != nil {
+			return namenodes, nil
+		}
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = strings.TrimSuffix(addr, ":") + ":8020"
+	}
+	return []Namenode{{RPCAddress: addr}}, nil
+}
@@ -0,0 +1,125 @@
+package hdfs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config describes a connection made with ConnectConfig or
+// ConnectWithOptions: which file system to connect to, as whom, and the
+// Hadoop configuration of the client.
+type Config struct {
+	// Namenode is the URI of the file system, such as "hdfs://nn:8020", or
+	// a bare "host:port" taken as an hdfs URI; "file:///" is the local file
+	// system, and "" the configured fs.default.name.
+	Namenode string
+	// User is the Hadoop user to connect as, or "" for the current user.
+	User string
+	// ConfDir, if not "", is a directory whose core-site.xml and
+	// hdfs-site.xml are loaded over those found on the CLASSPATH.
+	ConfDir string
+	// Settings are Hadoop configuration properties, such as
+	// "dfs.client.read.shortcircuit" or "dfs.replication", set last.
+	Settings map[string]string
+}
+
+// An Option sets a field of a Config.
+type Option func(*Config)
+
+// WithNamenode sets the URI of the file system; see Config.Namenode.
+func WithNamenode(uri string) Option {
+	return func(c *Config) { c.Namenode = uri }
+}
+
+// WithUser sets the Hadoop user to connect as.
+func WithUser(user string) Option {
+	return func(c *Config) { c.User = user }
+}
+
+// WithConfDir sets the directory holding core-site.xml and hdfs-site.xml.
+func WithConfDir(dir string) Option {
+	return func(c *Config) { c.ConfDir = dir }
+}
+
+// WithSetting sets the Hadoop configuration property key to value.
+func WithSetting(key, value string) Option {
+	return func(c *Config) {
+		if c.Settings == nil {
+			c.Settings = map[string]string{}
+		}
+		c.Settings[key] = value
+	}
+}
+
+// NewConfig returns a Config with opts applied, in order.
+func NewConfig(opts ...Option) *Config {
+	c := &Config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ConnectWithOptions connects to the file system described by opts, applied
+// in order to an empty Config; see ConnectConfig.
+func ConnectWithOptions(opts ...Option) (*Fs, error) {
+	return ConnectConfig(NewConfig(opts...))
+}
+
+// uri returns the normalized Namenode URI, "" standing for the default.
+func (c *Config) uri() (string, error) {
+	if c.Namenode == "" {
+		return "", nil
+	}
+	uri := c.Namenode
+	if !strings.Contains(uri, "://") {
+		uri = "hdfs://" + uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("hdfs: invalid namenode %q: %v", c.Namenode, err)
+	}
+	if u.Scheme != "file" && u.Host == "" {
+		return "", fmt.Errorf("hdfs: invalid namenode %q: missing host", c.Namenode)
+	}
+	return uri, nil
+}
+
+// resources returns the site files of ConfDir, in the order Hadoop loads
+// them.
+func (c *Config) resources() ([]string, error) {
+	if c.ConfDir == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(c.ConfDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &PathError{"connect", c.ConfDir, fmt.Errorf("not a directory")}
+	}
+	var files []string
+	for _, name := range []string{"core-site.xml", "hdfs-site.xml"} {
+		file := filepath.Join(c.ConfDir, name)
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// settings returns Settings as parallel slices sorted by key.
+func (c *Config) settings() (keys, values []string) {
+	for key := range c.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values = append(values, c.Settings[key])
+	}
+	return keys, values
+}
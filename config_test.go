@@ -0,0 +1,45 @@
+package hdfs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	c := NewConfig(WithNamenode("nn:8020"), WithUser("alice"), WithSetting("dfs.replication", "2"), WithSetting("a", "b"))
+	if c.User != "alice" {
+		t.Errorf("WithUser: got %q\n", c.User)
+	}
+	keys, values := c.settings()
+	if !reflect.DeepEqual(keys, []string{"a", "dfs.replication"}) || !reflect.DeepEqual(values, []string{"b", "2"}) {
+		t.Errorf("settings: got %v, %v\n", keys, values)
+	}
+
+	for namenode, want := range map[string]string{
+		"":                    "",
+		"nn:8020":             "hdfs://nn:8020",
+		"hdfs://nn:8020":      "hdfs://nn:8020",
+		"hdfs://nameservice1": "hdfs://nameservice1",
+		"file:///":            "file:///",
+	} {
+		uri, err := (&Config{Namenode: namenode}).uri()
+		if err != nil || uri != want {
+			t.Errorf("uri of %q: got %q, %v, want %q\n", namenode, uri, err, want)
+		}
+	}
+	if _, err := (&Config{Namenode: "hdfs:///"}).uri(); err == nil {
+		t.Errorf("uri of hdfs:///: no error\n")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hdfs-site.xml"), []byte("<configuration/>"), 0644)
+	files, err := NewConfig(WithConfDir(dir)).resources()
+	if err != nil || !reflect.DeepEqual(files, []string{filepath.Join(dir, "hdfs-site.xml")}) {
+		t.Errorf("resources: got %v, %v\n", files, err)
+	}
+	if _, err := NewConfig(WithConfDir(filepath.Join(dir, "missing"))).resources(); err == nil {
+		t.Errorf("resources of a missing directory: no error\n")
+	}
+}
@@ -0,0 +1,46 @@
+package hdfs
+
+import "context"
+
+type callResult struct {
+	v   interface{}
+	err error
+}
+
+// call runs fn and returns its results, or ctx.Err() as soon as ctx is done.
+// fn runs on a goroutine of its own unless ctx can never be cancelled, since
+// a native call blocked on an unresponsive namenode or datanode cannot be
+// interrupted. An abandoned fn carries on; if it then succeeds, release, if
+// not nil, is handed its value, so that what it acquired is not leaked.
+func call(ctx context.Context, fn func() (interface{}, error), release func(interface{})) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+	c := make(chan callResult, 1)
+	go func() {
+		v, err := fn()
+		c <- callResult{v, err}
+	}()
+	select {
+	case r := <-c:
+		return r.v, r.err
+	case <-ctx.Done():
+		if release != nil {
+			go func() {
+				if r := <-c; r.err == nil {
+					release(r.v)
+				}
+			}()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// callErr is call for functions returning only an error.
+func callErr(ctx context.Context, fn func() error) error {
+	_, err := call(ctx, func() (interface{}, error) { return nil, fn() }, nil)
+	return err
+}
@@ -0,0 +1,69 @@
+package hdfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCall(t *testing.T) {
+	v, err := call(context.Background(), func() (interface{}, error) { return 1, nil }, nil)
+	if v != 1 || err != nil {
+		t.Errorf("call: got %v, %v\n", v, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = call(ctx, func() (interface{}, error) {
+		t.Errorf("call ran fn with a cancelled context\n")
+		return nil, nil
+	}, nil)
+	if err != context.Canceled {
+		t.Errorf("call with a cancelled context: got %v\n", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	unblock := make(chan struct{})
+	released := make(chan interface{}, 1)
+	_, err = call(ctx, func() (interface{}, error) {
+		<-unblock
+		return "handle", nil
+	}, func(v interface{}) { released <- v })
+	if err != context.DeadlineExceeded {
+		t.Errorf("call past its deadline: got %v\n", err)
+	}
+	close(unblock)
+	select {
+	case v := <-released:
+		if v != "handle" {
+			t.Errorf("call released %v, want handle\n", v)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("call did not release the result of the abandoned function\n")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	unblock = make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		<-unblock
+		cancel()
+	}()
+	_, err = call(ctx, func() (interface{}, error) {
+		defer close(finished)
+		close(unblock)
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("failed")
+	}, func(v interface{}) { released <- v })
+	if err != context.Canceled {
+		t.Errorf("call cancelled while running: got %v\n", err)
+	}
+	<-finished
+	select {
+	case v := <-released:
+		t.Errorf("call released %v from a failed call\n", v)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
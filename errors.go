@@ -0,0 +1,68 @@
+package hdfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+var (
+	// ErrUnsupported is reported for operations or open flags the file
+	// system does not support, such as opening a file with O_RDWR.
+	ErrUnsupported = errors.New("hdfs: operation not supported")
+
+	// ErrInternal is reported when libhdfs fails without telling why,
+	// typically because of a Java exception it does not translate.
+	ErrInternal = errors.New("hdfs: internal error")
+
+	// ErrChecksum is reported when the checksum of a file differs from
+	// the one of the data it should hold.
+	ErrChecksum = errors.New("hdfs: checksum mismatch")
+
+	// ErrNoXAttr is reported for extended attributes a path does not
+	// have, as ENODATA is by getxattr(2).
+	ErrNoXAttr error = syscall.ENODATA
+)
+
+// PathError records an error and the operation and path that caused it.
+// Err can be tested with errors.Is against fs.ErrNotExist, fs.ErrExist,
+// fs.ErrPermission, ErrUnsupported, ErrInternal and ErrNoXAttr.
+type PathError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// classify maps the errno left by a libhdfs call to an error that can be
+// tested with errors.Is. libhdfs reports many unrelated Java exceptions as
+// ENOENT, and fails calls such as hdfsDelete on a missing path without
+// setting errno at all, so exists, when not nil, is asked whether the path
+// is really missing.
+func classify(err error, exists func() bool) error {
+	if err == nil {
+		if exists != nil && !exists() {
+			return syscall.ENOENT
+		}
+		return ErrInternal
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return err
+	}
+	switch {
+	case errno == 0 || errno == syscall.Errno(EINTERNAL):
+		return ErrInternal
+	case errno == syscall.ENOTSUP || errno == syscall.EOPNOTSUPP || errno == syscall.ENOSYS:
+		return ErrUnsupported
+	case errno == syscall.ENOENT && exists != nil && exists():
+		return ErrInternal
+	}
+	return errno
+}
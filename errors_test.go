@@ -0,0 +1,47 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	yes := func() bool { return true }
+	no := func() bool { return false }
+	tests := []struct {
+		err    error
+		exists func() bool
+		target error
+	}{
+		{syscall.ENOENT, no, fs.ErrNotExist},
+		{syscall.ENOENT, nil, fs.ErrNotExist},
+		{syscall.ENOENT, yes, ErrInternal},
+		{nil, no, fs.ErrNotExist},
+		{syscall.EACCES, nil, fs.ErrPermission},
+		{syscall.EPERM, nil, fs.ErrPermission},
+		{syscall.EEXIST, nil, fs.ErrExist},
+		{syscall.ENOTSUP, nil, ErrUnsupported},
+		{syscall.Errno(EINTERNAL), nil, ErrInternal},
+		{nil, nil, ErrInternal},
+	}
+	for i, tt := range tests {
+		err := &PathError{"open", "/tmp/x", classify(tt.err, tt.exists)}
+		if !errors.Is(err, tt.target) {
+			t.Errorf("%d: classify(%v) = %v, want errors.Is %v\n", i, tt.err, err.Err, tt.target)
+		}
+	}
+}
+
+func TestPathError(t *testing.T) {
+	err := error(&PathError{"stat", "/tmp/missing", syscall.ENOENT})
+	if s := err.Error(); s != "stat /tmp/missing: "+syscall.ENOENT.Error() {
+		t.Errorf("Unexpected error string: %s\n", s)
+	}
+	var perr *PathError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &perr) || perr.Op != "stat" {
+		t.Errorf("errors.As failed on wrapped PathError\n")
+	}
+}
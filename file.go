@@ -0,0 +1,260 @@
+package hdfs
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// maxChunk bounds a single libhdfs read or write, whose length is a tSize (int32).
+const maxChunk = 1 << 30
+
+// copyBufferSize is the buffer size used by WriteTo and ReadFrom.
+const copyBufferSize = 64 << 10
+
+var (
+	errNegativeOffset = errors.New("hdfs: negative offset")
+	errWhence         = errors.New("hdfs: invalid whence")
+)
+
+var (
+	_ FileHandle    = (*File)(nil)
+	_ io.Reader     = (*File)(nil)
+	_ io.Writer     = (*File)(nil)
+	_ io.Seeker     = (*File)(nil)
+	_ io.ReaderAt   = (*File)(nil)
+	_ io.Closer     = (*File)(nil)
+	_ io.WriterTo   = (*File)(nil)
+	_ io.ReaderFrom = (*File)(nil)
+	_ Syncer        = (*File)(nil)
+)
+
+// checkOpen fails op with os.ErrClosed once the file is closed. The backends
+// check again under the lock they take for each call, as Close may come in
+// between.
+func (f *File) checkOpen(op string) error {
+	f.RLock()
+	defer f.RUnlock()
+	if f.isClosed() {
+		return &PathError{op, f.path, os.ErrClosed}
+	}
+	return nil
+}
+
+// Name returns the path the file was opened with.
+func (f *File) Name() string {
+	return f.path
+}
+
+// Read reads up to len(b) bytes from the file into b, advancing the file offset.
+// It returns the number of bytes read and io.EOF at the end of the file.
+func (f *File) Read(b []byte) (int, error) {
+	if err := f.checkOpen("read"); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if len(b) > maxChunk {
+		b = b[:maxChunk]
+	}
+	n, err := f.fs.Read(f, b, len(b))
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return int(n), nil
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off, without
+// moving the file offset. It always returns a non-nil error when n < len(b); at
+// the end of the file that error is io.EOF.
+func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
+	if err := f.checkOpen("read"); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxChunk {
+			chunk = chunk[:maxChunk]
+		}
+		m, e := f.fs.Pread(f, off, chunk, len(chunk))
+		if e != nil {
+			return n, e
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+		n += int(m)
+		off += int64(m)
+		b = b[m:]
+	}
+	return n, nil
+}
+
+// Write writes len(b) bytes from b to the file. It returns the number of bytes
+// written and a non-nil error when n != len(b).
+func (f *File) Write(b []byte) (n int, err error) {
+	if err := f.checkOpen("write"); err != nil {
+		return 0, err
+	}
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxChunk {
+			chunk = chunk[:maxChunk]
+		}
+		m, e := f.fs.Write(f, chunk, len(chunk))
+		if e != nil {
+			return n, e
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+		n += int(m)
+		b = b[m:]
+	}
+	return n, nil
+}
+
+// Seek sets the offset for the next Read on the file, interpreted according to
+// whence: io.SeekStart, io.SeekCurrent or io.SeekEnd. The end of the file is
+// the length the reader sees, which for a file being written counts the
+// data flushed to its last block; past 2GB left to read, it is taken from
+// the size reported by GetPathInfo. It returns the new offset.
+// Only files opened read-only can seek; Seek(0, io.SeekCurrent) reports the
+// current offset for any file.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if err := f.checkOpen("seek"); err != nil {
+		return 0, err
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		pos, err := f.fs.Tell(f)
+		if err != nil {
+			return 0, err
+		}
+		if offset == 0 {
+			return pos, nil
+		}
+		abs = pos + offset
+	case io.SeekEnd:
+		end, err := f.end()
+		if err != nil {
+			return 0, err
+		}
+		abs = end + offset
+	default:
+		return 0, errWhence
+	}
+	if abs < 0 {
+		return 0, errNegativeOffset
+	}
+	if err := f.fs.Seek(f, abs); err != nil {
+		return 0, err
+	}
+	return abs, nil
+}
+
+// end returns the length of the file as its reader sees it.
+func (f *File) end() (int64, error) {
+	pos, err := f.fs.Tell(f)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := f.fs.Available(f); err == nil && n < math.MaxInt32 {
+		return pos + int64(n), nil
+	}
+	info, err := f.fs.GetPathInfo(f.path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// WriteTo writes the remaining content of the file to w until EOF. It
+// implements io.WriterTo, so io.Copy(w, f) does not need an extra buffer.
+func (f *File) WriteTo(w io.Writer) (n int64, err error) {
+	buf := make([]byte, copyBufferSize)
+	for {
+		nr, er := f.Read(buf)
+		if nr > 0 {
+			nw, ew := w.Write(buf[:nr])
+			n += int64(nw)
+			if ew != nil {
+				return n, ew
+			}
+			if nw != nr {
+				return n, io.ErrShortWrite
+			}
+		}
+		if er == io.EOF {
+			return n, nil
+		}
+		if er != nil {
+			return n, er
+		}
+	}
+}
+
+// ReadFrom writes the content of r to the file until r returns EOF. It
+// implements io.ReaderFrom, so io.Copy(f, r) does not need an extra buffer.
+func (f *File) ReadFrom(r io.Reader) (n int64, err error) {
+	buf := make([]byte, copyBufferSize)
+	for {
+		nr, er := r.Read(buf)
+		if nr > 0 {
+			nw, ew := f.Write(buf[:nr])
+			n += int64(nw)
+			if ew != nil {
+				return n, ew
+			}
+		}
+		if er == io.EOF {
+			return n, nil
+		}
+		if er != nil {
+			return n, er
+		}
+	}
+}
+
+// Flush flushes data written so far.
+func (f *File) Flush() error {
+	if err := f.checkOpen("flush"); err != nil {
+		return err
+	}
+	return f.fs.Flush(f)
+}
+
+// Hflush sends the data written so far to every datanode of the pipeline,
+// making it visible to readers opening the file afterwards.
+func (f *File) Hflush() error {
+	if err := f.checkOpen("hflush"); err != nil {
+		return err
+	}
+	return f.fs.Hflush(f)
+}
+
+// Hsync is Hflush, also having the datanodes persist the data to disk, so
+// that it survives them losing power.
+func (f *File) Hsync() error {
+	if err := f.checkOpen("hsync"); err != nil {
+		return err
+	}
+	return f.fs.Hsync(f)
+}
+
+// Close closes the file, flushing any pending writes. The handle is released
+// even when closing fails, so further calls fail with os.ErrClosed.
+func (f *File) Close() error {
+	return f.fs.CloseFile(f)
+}
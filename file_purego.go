@@ -0,0 +1,740 @@
+//go:build !cgo || purego
+
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs/conf"
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/rpc"
+	"github.com/zyxar/hdfs/internal/transfer"
+)
+
+// File data of the pure-Go backend: a reader streams the blocks of a file
+// from its datanodes, moving on to another replica when one fails, and a
+// writer sends the data through a pipeline of datanodes per block, which
+// the transfer package recovers from failed datanodes.
+
+const (
+	defaultSocketTimeout   = 60 * time.Second
+	defaultWritePacketSize = 64 << 10
+	// leaseRenewInterval is how often the leases of the files being
+	// written are renewed, half the soft limit of the namenode.
+	leaseRenewInterval = 30 * time.Second
+	// blockAttempts bounds the datanodes tried to start a block, as
+	// dfs.client.block.write.retries does.
+	blockAttempts = 3
+	// completeAttempts bounds the complete calls made to close a file,
+	// as dfs.client.block.write.locateFollowingBlock.retries does.
+	completeAttempts = 5
+	completeDelay    = 400 * time.Millisecond
+)
+
+// replicaNotFound is thrown by datanodes asked about a replica they do not
+// hold.
+const replicaNotFound = "org.apache.hadoop.hdfs.server.datanode.ReplicaNotFoundException"
+
+var checksumTypes = map[string]uint64{
+	"NULL":   hdfsproto.ChecksumNull,
+	"CRC32":  hdfsproto.ChecksumCRC32,
+	"CRC32C": hdfsproto.ChecksumCRC32C,
+}
+
+var blockChecksumTypes = map[string]uint64{
+	ChecksumMD5MD5CRC:    hdfsproto.BlockChecksumMD5CRC,
+	ChecksumCompositeCRC: hdfsproto.BlockChecksumCompositeCRC,
+}
+
+// configure sets the data transfer settings of fs from c.
+func (fs *Fs) configure(c *conf.Conf) error {
+	var err error
+	if fs.timeout, err = c.GetDuration("dfs.client.socket-timeout", defaultSocketTimeout, time.Millisecond); err != nil {
+		return err
+	}
+	name := strings.ToUpper(c.GetString("dfs.checksum.type", "CRC32C"))
+	typ, ok := checksumTypes[name]
+	if !ok {
+		return fmt.Errorf("hdfs: dfs.checksum.type: unknown checksum %q", name)
+	}
+	bpc, err := c.GetSize("dfs.bytes-per-checksum", defaultBytesPerChecksum)
+	if err != nil {
+		return err
+	}
+	fs.checksum = transfer.Checksum{Type: typ, BytesPerChecksum: int(bpc)}
+	if err := fs.checksum.Valid(); err != nil {
+		return err
+	}
+	packetSize, err := c.GetSize("dfs.client-write-packet-size", defaultWritePacketSize)
+	if err != nil {
+		return err
+	}
+	fs.packetSize = int(packetSize)
+	fs.combine = strings.ToUpper(c.GetString("dfs.checksum.combine.mode", ChecksumMD5MD5CRC))
+	if _, ok := blockChecksumTypes[fs.combine]; !ok {
+		return fmt.Errorf("hdfs: dfs.checksum.combine.mode: unknown mode %q", fs.combine)
+	}
+	fs.clientName = fmt.Sprintf("DFSClient_NONMAPREDUCE_%d_1", rand.Int31())
+	return nil
+}
+
+// dataError reports a failure to transfer data as ErrInternal, as libhdfs
+// does for the IOException of the Java client.
+func dataError(op, path string, err error) error {
+	var pe *PathError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &PathError{op, path, fmt.Errorf("%w: %v", ErrInternal, err)}
+}
+
+// OpenFile opens a file. Flags are those of libhdfs: O_RDONLY opens for
+// reading, O_WRONLY creates or truncates the file, creating its missing
+// parent directories, and O_WRONLY|O_APPEND appends to an existing file,
+// starting a new block. O_RDWR and O_CREATE|O_EXCL fail with
+// ErrUnsupported. A replication or block size of 0 means the default of
+// the namenode; buffersize is ignored.
+func (fs *Fs) OpenFile(path string, flags int, buffersize int, replication int, blocksize uint32) (*File, error) {
+	if flags&syscall.O_RDWR != 0 || flags&(syscall.O_EXCL|syscall.O_CREAT) == syscall.O_EXCL|syscall.O_CREAT {
+		return nil, &PathError{"open", path, ErrUnsupported}
+	}
+	file := &File{RWMutex: new(sync.RWMutex), fs: fs, path: path, flags: flags, abs: fs.abs(path)}
+	var err error
+	if flags&O_WRONLY == 0 {
+		file.r, err = fs.openReader(file)
+	} else {
+		file.w, err = fs.openWriter(file, flags&O_APPEND != 0, replication, int64(blocksize))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// CloseFile closes an open file, releasing it even when closing fails.
+// Closing a file open for writing sends the rest of its data and completes
+// it on the namenode.
+func (fs *Fs) CloseFile(file *File) error {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"close", file.path, os.ErrClosed}
+	}
+	defer file.release()
+	if file.r != nil {
+		file.r.close()
+		return nil
+	}
+	return fs.closeWriter(file)
+}
+
+// Seek moves the offset of a file opened for reading.
+func (fs *Fs) Seek(file *File, pos int64) error {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"seek", file.path, os.ErrClosed}
+	}
+	if file.r == nil {
+		return &PathError{"seek", file.path, syscall.EBADF}
+	}
+	if pos < 0 || pos > file.r.size {
+		return &PathError{"seek", file.path, ErrInternal}
+	}
+	if pos != file.r.pos {
+		file.r.close()
+		file.r.pos = pos
+	}
+	return nil
+}
+
+// Tell returns the offset of a file: the bytes read or skipped of a reader,
+// and the length of the file so far of a writer, counting what it had when
+// opened for appending.
+func (fs *Fs) Tell(file *File) (int64, error) {
+	file.RLock()
+	defer file.RUnlock()
+	if file.isClosed() {
+		return -1, &PathError{"tell", file.path, os.ErrClosed}
+	}
+	if file.r != nil {
+		return file.r.pos, nil
+	}
+	return file.w.written, nil
+}
+
+// Read reads up to length bytes into buffer, returning 0 at the end of the
+// file.
+func (fs *Fs) Read(file *File, buffer []byte, length int) (uint32, error) {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return 0, &PathError{"read", file.path, os.ErrClosed}
+	}
+	if file.r == nil {
+		return 0, &PathError{"read", file.path, syscall.EINVAL}
+	}
+	n, err := fs.read(file, buffer[:length])
+	if err != nil {
+		return 0, dataError("read", file.path, err)
+	}
+	return uint32(n), nil
+}
+
+// Pread reads up to length bytes at position into buffer, without moving
+// the offset; a read does not span blocks.
+func (fs *Fs) Pread(file *File, position int64, buffer []byte, length int) (uint32, error) {
+	file.RLock()
+	defer file.RUnlock()
+	if file.isClosed() {
+		return 0, &PathError{"read", file.path, os.ErrClosed}
+	}
+	if file.r == nil {
+		return 0, &PathError{"read", file.path, syscall.EINVAL}
+	}
+	if position < 0 {
+		return 0, &PathError{"read", file.path, ErrInternal}
+	}
+	n, err := fs.pread(file, position, buffer[:length])
+	if err != nil {
+		return 0, dataError("read", file.path, err)
+	}
+	return uint32(n), nil
+}
+
+// Write writes length bytes of buffer, starting a new block whenever the
+// current one is full. Once a write failed, the file can only be closed.
+func (fs *Fs) Write(file *File, buffer []byte, length int) (uint32, error) {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return 0, &PathError{"write", file.path, os.ErrClosed}
+	}
+	if file.w == nil {
+		return 0, &PathError{"write", file.path, syscall.EINVAL}
+	}
+	n, err := fs.write(file, buffer[:length])
+	if err != nil {
+		return uint32(n), dataError("write", file.path, err)
+	}
+	return uint32(n), nil
+}
+
+// Flush sends the data written so far to the datanodes and waits until
+// all of them received it, which makes it visible to new readers.
+func (fs *Fs) Flush(file *File) error {
+	return fs.flush("flush", file, false)
+}
+
+// Hflush is Flush.
+func (fs *Fs) Hflush(file *File) error {
+	return fs.flush("hflush", file, false)
+}
+
+// Hsync is Flush, also having the datanodes persist the block being
+// written to disk, and records its length on the namenode, as the Java
+// client does with SyncFlag.UPDATE_LENGTH.
+func (fs *Fs) Hsync(file *File) error {
+	return fs.flush("hsync", file, true)
+}
+
+func (fs *Fs) flush(op string, file *File, sync bool) error {
+	file.Lock()
+	defer file.Unlock()
+	w := file.w
+	if w == nil {
+		return &PathError{op, file.path, syscall.EBADF}
+	}
+	if w.closed {
+		return &PathError{op, file.path, os.ErrClosed}
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if w.bw == nil {
+		return nil
+	}
+	var err error
+	if sync {
+		err = w.bw.Sync()
+	} else {
+		err = w.bw.Flush()
+	}
+	if err != nil {
+		w.err = dataError(op, file.path, err)
+		return w.err
+	}
+	if !sync {
+		return nil
+	}
+	req := &hdfsproto.FsyncRequest{Src: file.abs, Client: fs.clientName, LastBlockLength: w.bw.Size(), FileID: w.fileID}
+	return fs.call(op, file.path, "fsync", req, &hdfsproto.Empty{})
+}
+
+// Available returns the number of bytes left to read in the file.
+func (fs *Fs) Available(file *File) (uint32, error) {
+	file.RLock()
+	defer file.RUnlock()
+	if file.isClosed() {
+		return 0, &PathError{"available", file.path, os.ErrClosed}
+	}
+	if file.r == nil {
+		return 0, &PathError{"available", file.path, syscall.EINVAL}
+	}
+	n := file.r.size - file.r.pos
+	if n > math.MaxInt32 {
+		n = math.MaxInt32
+	}
+	return uint32(n), nil
+}
+
+// reader is the state of a file open for reading.
+type reader struct {
+	size   int64
+	blocks []*hdfsproto.LocatedBlock
+	pos    int64
+	br     *transfer.BlockReader // reading from pos, if not nil
+
+	mu   sync.Mutex
+	dead map[string]bool // transfer addresses of the datanodes that failed
+}
+
+func (fs *Fs) openReader(file *File) (*reader, error) {
+	var resp hdfsproto.GetBlockLocationsResponse
+	req := &hdfsproto.GetBlockLocationsRequest{Src: file.abs, Length: math.MaxInt64}
+	if err := fs.call("open", file.path, "getBlockLocations", req, &resp); err != nil {
+		return nil, err
+	}
+	locs := resp.Locations
+	if locs == nil {
+		return nil, &PathError{"open", file.path, syscall.ENOENT}
+	}
+	r := &reader{size: int64(locs.FileLength), blocks: locs.Blocks, dead: map[string]bool{}}
+	if last := locs.LastBlock; locs.UnderConstruction && !locs.IsLastBlockComplete && last != nil {
+		// The namenode leaves the block being written out of the file
+		// length; its datanodes tell what of it readers may see.
+		n, err := fs.visibleLength(last)
+		if err != nil {
+			return nil, dataError("open", file.path, err)
+		}
+		last.Block.NumBytes = uint64(n)
+		r.size += n
+		if i := len(r.blocks) - 1; i >= 0 && r.blocks[i].Block.BlockID == last.Block.BlockID {
+			r.blocks[i] = last
+		} else {
+			r.blocks = append(r.blocks, last)
+		}
+	}
+	return r, nil
+}
+
+// visibleLength asks the datanodes of b, a block being written, for the
+// length of their replica readers may see, as DFSInputStream does: 0 if
+// none of them has the replica yet, as when the pipeline is being set up.
+func (fs *Fs) visibleLength(b *hdfsproto.LocatedBlock) (int64, error) {
+	var err error
+	notFound := 0
+	for _, dn := range b.Locs {
+		var c *rpc.Client
+		if c, err = rpc.Dial(dn.IPCAddr(), fs.user, hdfsproto.ClientDatanodeProtocol, fs.timeout); err != nil {
+			continue
+		}
+		var resp hdfsproto.GetReplicaVisibleLengthResponse
+		err = c.Call("getReplicaVisibleLength", &hdfsproto.GetReplicaVisibleLengthRequest{Block: b.Block}, &resp)
+		c.Close()
+		if err == nil {
+			return int64(resp.Length), nil
+		}
+		var re *rpc.RemoteError
+		if errors.As(err, &re) && re.Class == replicaNotFound {
+			notFound++
+		}
+	}
+	if notFound == len(b.Locs) {
+		return 0, nil
+	}
+	return -1, fmt.Errorf("cannot obtain the length of block %d: %w", b.Block.BlockID, err)
+}
+
+func (r *reader) close() {
+	if r.br != nil {
+		r.br.Close()
+		r.br = nil
+	}
+}
+
+// block returns the block holding the byte at pos.
+func (r *reader) block(pos int64) (*hdfsproto.LocatedBlock, error) {
+	for _, b := range r.blocks {
+		if pos >= int64(b.Offset) && pos < int64(b.Offset+b.Block.NumBytes) {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("no block holds offset %d", pos)
+}
+
+func (r *reader) markDead(dn *hdfsproto.DatanodeInfo) {
+	r.mu.Lock()
+	r.dead[dn.XferAddr()] = true
+	r.mu.Unlock()
+}
+
+// readBlock starts reading b from pos to the end of the block, from the
+// first of its datanodes that has not failed and does not fail now.
+func (fs *Fs) readBlock(r *reader, b *hdfsproto.LocatedBlock, pos int64) (*transfer.BlockReader, error) {
+	off := pos - int64(b.Offset)
+	err := fmt.Errorf("no live datanode holds block %d", b.Block.BlockID)
+	for _, dn := range b.Locs {
+		r.mu.Lock()
+		dead := r.dead[dn.XferAddr()]
+		r.mu.Unlock()
+		if dead {
+			continue
+		}
+		var br *transfer.BlockReader
+		if br, err = transfer.ReadBlock(dn, b, fs.clientName, off, int64(b.Block.NumBytes)-off, fs.timeout); err == nil {
+			return br, nil
+		}
+		r.markDead(dn)
+	}
+	return nil, err
+}
+
+// read reads at the offset of file, moving to another datanode when the
+// current one fails.
+func (fs *Fs) read(file *File, p []byte) (int, error) {
+	r := file.r
+	for r.pos < r.size && len(p) > 0 {
+		if r.br == nil {
+			b, err := r.block(r.pos)
+			if err != nil {
+				return 0, err
+			}
+			if r.br, err = fs.readBlock(r, b, r.pos); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.br.Read(p)
+		r.pos += int64(n)
+		if err == io.EOF {
+			r.close()
+		} else if err != nil {
+			r.markDead(r.br.Datanode())
+			r.close()
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return 0, nil
+}
+
+// pread reads at pos with a reader of its own, trying every datanode of the
+// block until one succeeds.
+func (fs *Fs) pread(file *File, pos int64, p []byte) (int, error) {
+	r := file.r
+	if pos >= r.size || len(p) == 0 {
+		return 0, nil
+	}
+	b, err := r.block(pos)
+	if err != nil {
+		return 0, err
+	}
+	if end := int64(b.Offset+b.Block.NumBytes) - pos; int64(len(p)) > end {
+		p = p[:end]
+	}
+	for {
+		br, err := fs.readBlock(r, b, pos)
+		if err != nil {
+			return 0, err
+		}
+		n, err := io.ReadFull(br, p)
+		br.Close()
+		if err == nil {
+			return n, nil
+		}
+		r.markDead(br.Datanode())
+	}
+}
+
+// GetFileChecksum returns the checksum of the content of a file, combining
+// the checksums its datanodes compute for its blocks in the mode set by
+// dfs.checksum.combine.mode.
+func (fs *Fs) GetFileChecksum(path string) (*FileChecksum, error) {
+	var resp hdfsproto.GetBlockLocationsResponse
+	req := &hdfsproto.GetBlockLocationsRequest{Src: fs.abs(path), Length: math.MaxInt64}
+	if err := fs.call("checksum", path, "getBlockLocations", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Locations == nil {
+		return nil, &PathError{"checksum", path, syscall.ENOENT}
+	}
+	b := newChecksumBuilder(fs.combine)
+	for _, lb := range resp.Locations.Blocks {
+		sum, err := fs.blockChecksum(lb)
+		if err != nil {
+			return nil, dataError("checksum", path, err)
+		}
+		typ := "NULL"
+		for name, t := range checksumTypes {
+			if t == sum.CrcType {
+				typ = name
+			}
+		}
+		if err := b.add(typ, int(sum.BytesPerCrc), int64(sum.CrcPerBlock), sum.BlockChecksum, int64(lb.Block.NumBytes)); err != nil {
+			return nil, dataError("checksum", path, err)
+		}
+	}
+	return b.checksum(), nil
+}
+
+// blockChecksum asks the datanodes of b, in turn, for its checksum.
+func (fs *Fs) blockChecksum(b *hdfsproto.LocatedBlock) (*hdfsproto.BlockChecksumResponse, error) {
+	err := fmt.Errorf("no datanode holds block %d", b.Block.BlockID)
+	for _, dn := range b.Locs {
+		var sum *hdfsproto.BlockChecksumResponse
+		if sum, err = transfer.GetBlockChecksum(dn, b, blockChecksumTypes[fs.combine], fs.timeout); err == nil {
+			return sum, nil
+		}
+	}
+	return nil, err
+}
+
+// writer is the state of a file open for writing.
+type writer struct {
+	fileID    uint64
+	blockSize int64
+	bw        *transfer.BlockWriter // writer of the current block, if any
+	last      *hdfsproto.ExtendedBlock
+	written   int64 // offset in the file, from its length when appending
+	err       error // failure ending the writes
+	closed    bool
+}
+
+func (fs *Fs) openWriter(file *File, appending bool, replication int, blockSize int64) (*writer, error) {
+	var st *hdfsproto.FileStatus
+	if appending {
+		var resp hdfsproto.AppendResponse
+		req := &hdfsproto.AppendRequest{Src: file.abs, ClientName: fs.clientName, Flag: hdfsproto.CreateFlagAppend | hdfsproto.CreateFlagNewBlock}
+		if err := fs.call("open", file.path, "append", req, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Block != nil {
+			// the namenode ignored NEW_BLOCK; appending to the last
+			// block is not supported
+			fs.call("close", file.path, "complete", &hdfsproto.CompleteRequest{Src: file.abs, ClientName: fs.clientName, Last: &resp.Block.Block}, &hdfsproto.BoolResponse{})
+			return nil, &PathError{"open", file.path, ErrUnsupported}
+		}
+		st = resp.FS
+	} else {
+		if replication == 0 {
+			replication = int(fs.defaults.Replication)
+		}
+		if blockSize == 0 {
+			blockSize = int64(fs.defaults.BlockSize)
+		}
+		var resp hdfsproto.CreateResponse
+		req := &hdfsproto.CreateRequest{
+			Src:          file.abs,
+			Masked:       0666 &^ defaultUmask,
+			ClientName:   fs.clientName,
+			CreateFlag:   hdfsproto.CreateFlagCreate | hdfsproto.CreateFlagOverwrite,
+			CreateParent: true,
+			Replication:  uint32(replication),
+			BlockSize:    uint64(blockSize),
+		}
+		if err := fs.call("open", file.path, "create", req, &resp); err != nil {
+			return nil, err
+		}
+		st = resp.FS
+	}
+	w := &writer{blockSize: blockSize}
+	if st != nil {
+		w.fileID, w.blockSize = st.FileID, int64(st.BlockSize)
+		if appending {
+			w.written = int64(st.Length)
+		}
+	}
+	if w.blockSize <= 0 {
+		w.blockSize = int64(fs.defaults.BlockSize)
+	}
+	fs.addWriter()
+	return w, nil
+}
+
+// addWriter counts a file opened for writing, starting the lease renewer
+// for the first one.
+func (fs *Fs) addWriter() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.writers++
+	if fs.stopRenew == nil {
+		fs.stopRenew = make(chan struct{})
+		go fs.renewLease(fs.stopRenew)
+	}
+}
+
+// removeWriter counts a file closed for writing, stopping the lease
+// renewer after the last one.
+func (fs *Fs) removeWriter() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.writers--
+	if fs.writers == 0 && fs.stopRenew != nil {
+		close(fs.stopRenew)
+		fs.stopRenew = nil
+	}
+}
+
+func (fs *Fs) renewLease(stop chan struct{}) {
+	t := time.NewTicker(leaseRenewInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			fs.client.Call("renewLease", &hdfsproto.RenewLeaseRequest{ClientName: fs.clientName}, &hdfsproto.Empty{})
+		}
+	}
+}
+
+// write writes p to the blocks of file.
+func (fs *Fs) write(file *File, p []byte) (int, error) {
+	w := file.w
+	if w.closed {
+		return 0, &PathError{"write", file.path, os.ErrClosed}
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		if w.bw == nil {
+			bw, err := fs.addBlock(file)
+			if err != nil {
+				w.err = dataError("write", file.path, err)
+				return n, w.err
+			}
+			w.bw = bw
+		}
+		chunk := p
+		if room := w.blockSize - w.bw.Size(); int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		m, err := w.bw.Write(chunk)
+		n, w.written, p = n+m, w.written+int64(m), p[m:]
+		if err == nil && w.bw.Size() == w.blockSize {
+			w.last, err = w.bw.Close()
+			w.bw = nil
+		}
+		if err != nil {
+			w.err = dataError("write", file.path, err)
+			return n, w.err
+		}
+	}
+	return n, nil
+}
+
+// addBlock allocates the next block of file and sets up its pipeline,
+// asking for another block elsewhere when a datanode fails the setup.
+func (fs *Fs) addBlock(file *File) (*transfer.BlockWriter, error) {
+	w := file.w
+	var exclude []*hdfsproto.DatanodeInfo
+	var err error
+	for i := 0; i < blockAttempts; i++ {
+		var resp hdfsproto.BlockResponse
+		req := &hdfsproto.AddBlockRequest{Src: file.abs, ClientName: fs.clientName, Previous: w.last, ExcludeNodes: exclude, FileID: w.fileID}
+		if err := fs.call("write", file.path, "addBlock", req, &resp); err != nil {
+			return nil, err
+		}
+		cfg := transfer.WriterConfig{
+			ClientName: fs.clientName,
+			Checksum:   fs.checksum,
+			PacketSize: fs.packetSize,
+			Timeout:    fs.timeout,
+			Recoverer:  recoverer{fs, file},
+		}
+		var bw *transfer.BlockWriter
+		if bw, err = transfer.NewBlockWriter(cfg, &resp.Block); err == nil {
+			return bw, nil
+		}
+		abandon := &hdfsproto.AbandonBlockRequest{Block: resp.Block.Block, Src: file.abs, Holder: fs.clientName, FileID: w.fileID}
+		if err := fs.call("write", file.path, "abandonBlock", abandon, &hdfsproto.Empty{}); err != nil {
+			return nil, err
+		}
+		var de *transfer.DatanodeError
+		if !errors.As(err, &de) {
+			return nil, err
+		}
+		exclude = append(exclude, de.Datanode)
+	}
+	return nil, err
+}
+
+// closeWriter ends the last block of file and completes the file, waiting
+// for the namenode to learn of its replicas.
+func (fs *Fs) closeWriter(file *File) error {
+	w := file.w
+	if w.closed {
+		return &PathError{"close", file.path, os.ErrClosed}
+	}
+	w.closed = true
+	defer fs.removeWriter()
+	if w.err != nil {
+		if w.bw != nil {
+			w.bw.Abort()
+		}
+		return w.err
+	}
+	if w.bw != nil {
+		last, err := w.bw.Close()
+		if err != nil {
+			return dataError("close", file.path, err)
+		}
+		w.last = last
+	}
+	req := &hdfsproto.CompleteRequest{Src: file.abs, ClientName: fs.clientName, Last: w.last, FileID: w.fileID}
+	delay := completeDelay
+	for i := 0; i < completeAttempts; i++ {
+		var resp hdfsproto.BoolResponse
+		if err := fs.call("close", file.path, "complete", req, &resp); err != nil {
+			return err
+		}
+		if resp.Result {
+			return nil
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return &PathError{"close", file.path, ErrInternal}
+}
+
+// recoverer lets the pipeline of a file replace failed datanodes.
+type recoverer struct {
+	fs   *Fs
+	file *File
+}
+
+func (r recoverer) UpdateBlockForPipeline(b *hdfsproto.ExtendedBlock) (*hdfsproto.LocatedBlock, error) {
+	var resp hdfsproto.BlockResponse
+	req := &hdfsproto.UpdateBlockForPipelineRequest{Block: *b, ClientName: r.fs.clientName}
+	if err := r.fs.call("write", r.file.path, "updateBlockForPipeline", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Block, nil
+}
+
+func (r recoverer) UpdatePipeline(old, new *hdfsproto.ExtendedBlock, nodes []*hdfsproto.DatanodeInfo) error {
+	req := &hdfsproto.UpdatePipelineRequest{ClientName: r.fs.clientName, OldBlock: *old, NewBlock: *new, NewNodes: nodes}
+	return r.fs.call("write", r.file.path, "updatePipeline", req, &hdfsproto.Empty{})
+}
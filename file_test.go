@@ -0,0 +1,101 @@
+//go:build cgo && !purego
+
+package hdfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFileIO(t *testing.T) {
+	path := "/tmp/gofileio.txt.gz"
+	lines := []string{"hello hdfs world,", "from io.Writer", "and bufio!"}
+
+	err := func() error {
+		fs, err := Connect(server, ssport)
+		if err != nil {
+			return fmt.Errorf("Error on connecting to hdfs: %v\n", err)
+		}
+		defer fs.Disconnect()
+		defer fs.Delete(path)
+
+		file, err := fs.OpenFile(path, O_WRONLY|O_CREATE, 0, 0, 0)
+		if err != nil {
+			return fmt.Errorf("Error on opening file for writing: %v\n", err)
+		}
+		zw := gzip.NewWriter(file)
+		for _, line := range lines {
+			fmt.Fprintln(zw, line)
+		}
+		if err = zw.Close(); err != nil {
+			return fmt.Errorf("Error on writing gzip stream: %v\n", err)
+		}
+		if pos, err := file.Seek(0, io.SeekCurrent); err != nil || pos == 0 {
+			return fmt.Errorf("Error on telling write offset: %v %v\n", pos, err)
+		}
+		if err = file.Close(); err != nil {
+			return fmt.Errorf("Error on closing file: %v\n", err)
+		}
+		if err = file.Close(); err == nil {
+			return fmt.Errorf("Closing a closed file should fail\n")
+		}
+
+		file, err = fs.OpenFile(path, O_RDONLY, 0, 0, 0)
+		if err != nil {
+			return fmt.Errorf("Error on opening file for reading: %v\n", err)
+		}
+		defer file.Close()
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("Error on reading gzip header: %v\n", err)
+		}
+		scanner := bufio.NewScanner(zr)
+		for i := 0; scanner.Scan(); i++ {
+			if i >= len(lines) || scanner.Text() != lines[i] {
+				return fmt.Errorf("Unexpected line %d: %q\n", i, scanner.Text())
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return fmt.Errorf("Error on scanning file: %v\n", err)
+		}
+
+		size, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("Error on seeking to end: %v\n", err)
+		}
+		if n, err := file.Read(make([]byte, 8)); n != 0 || err != io.EOF {
+			return fmt.Errorf("Read at end of file: got %d, %v; want 0, EOF\n", n, err)
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("Error on seeking to start: %v\n", err)
+		}
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, file)
+		if err != nil || n != size {
+			return fmt.Errorf("Error on copying file: copied %d of %d: %v\n", n, size, err)
+		}
+
+		head := make([]byte, 2)
+		if _, err = file.ReadAt(head, 0); err != nil || head[0] != 0x1f || head[1] != 0x8b {
+			return fmt.Errorf("Error on reading gzip magic: %x %v\n", head, err)
+		}
+		tail := make([]byte, 16)
+		if n, err := file.ReadAt(tail, size-4); n != 4 || err != io.EOF {
+			return fmt.Errorf("ReadAt past end: got %d, %v; want 4, EOF\n", n, err)
+		}
+
+		copied, err := ioutil.ReadAll(io.NewSectionReader(file, 0, size))
+		if err != nil || !bytes.Equal(copied, buf.Bytes()) {
+			return fmt.Errorf("Error on reading through io.SectionReader: %v\n", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+}
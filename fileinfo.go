@@ -0,0 +1,32 @@
+package hdfs
+
+import (
+	"fmt"
+	"time"
+)
+
+type FileInfo struct {
+	Kind         byte
+	Name         string
+	LastMod      time.Time
+	Size         int64
+	Replication  int16
+	BlockSize    int64
+	Owner, Group string
+	Permissions  int16
+	LastAccess   time.Time
+}
+
+func (info *FileInfo) String() (ret string) {
+	ret = fmt.Sprintf("%-8s\t:  %s\n", "Name", info.Name) +
+		fmt.Sprintf("%-8s\t:  %c\n", "Type", info.Kind) +
+		fmt.Sprintf("%-8s\t:  %d\n", "Replication", info.Replication) +
+		fmt.Sprintf("%-8s\t:  %v\n", "BlockSize", info.BlockSize) +
+		fmt.Sprintf("%-8s\t:  %v\n", "Size", info.Size) +
+		fmt.Sprintf("%-8s\t:  %v\n", "LastMod", info.LastMod) +
+		fmt.Sprintf("%-8s\t:  %v\n", "LastAccess", info.LastAccess) +
+		fmt.Sprintf("%-8s\t:  %s\n", "Owner", info.Owner) +
+		fmt.Sprintf("%-8s\t:  %s\n", "Group", info.Group) +
+		fmt.Sprintf("%-8s\t:  %b\n", "Permissions", info.Permissions)
+	return
+}
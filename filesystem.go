@@ -0,0 +1,230 @@
+package hdfs
+
+import (
+	"io"
+	"syscall"
+	"time"
+)
+
+// Open flags and the libhdfs internal errno, matching bits/fcntl.h and hdfs.h.
+const (
+	O_RDONLY  = int(syscall.O_RDONLY)
+	O_WRONLY  = int(syscall.O_WRONLY)
+	O_CREATE  = int(syscall.O_CREAT)
+	O_APPEND  = int(syscall.O_APPEND)
+	EINTERNAL = 255
+)
+
+// FileSystem is the set of path operations of *Fs. Code written against it
+// can run on other backends, such as the in-memory file system of package
+// hdfstest. Methods behave, and fail with the same *PathError values, as
+// documented on *Fs.
+type FileSystem interface {
+	Open(path string, flags int, buffersize int, replication int, blocksize uint32) (FileHandle, error)
+	Exists(path string) error
+	Delete(path string) error
+	Rename(oldpath, newpath string) error
+	GetWorkingDirectory(buffer []byte, size uint32) ([]byte, error)
+	SetWorkingDirectory(path string) error
+	CreateDirectory(path string) error
+	SetReplication(path string, replication int16) error
+	ListDirectory(path string) ([]*FileInfo, error)
+	GetPathInfo(path string) (*FileInfo, error)
+	GetHosts(path string, start, length int64) ([][]string, error)
+	GetDefaultBlockSize() (int64, error)
+	GetCapacity() (int64, error)
+	GetUsed() (int64, error)
+	Chown(path, owner, group string) error
+	Chmod(path string, mode int16) error
+	Utime(path string, mtime, atime time.Time) error
+	Disconnect() error
+}
+
+// FileHandle is an open file of a FileSystem, as *File is for *Fs.
+type FileHandle interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	// Name returns the path the file was opened with.
+	Name() string
+	// Flush flushes data written so far.
+	Flush() error
+}
+
+// Syncer is implemented by the files open for writing that tell making the
+// data written visible apart from making it durable, as *File does.
+type Syncer interface {
+	// Hflush makes the data written so far visible to readers opening
+	// the file afterwards; readers opened before see the length of the
+	// file as it was then.
+	Hflush() error
+	// Hsync is Hflush, also having the data persisted to disk.
+	Hsync() error
+}
+
+// Truncater is implemented by the FileSystems that truncate files in place,
+// as *Fs does.
+type Truncater interface {
+	// TruncateFile cuts the file path to newLength, reporting whether it
+	// is done. If not, the namenode recovers the last block, cut at
+	// newLength, in the background, and the file cannot be appended to
+	// until it is closed again.
+	TruncateFile(path string, newLength int64) (done bool, err error)
+}
+
+// Remover is implemented by the FileSystems that delete a path without
+// recursing, as *Fs does. Unlike checking that a directory is empty before a
+// Delete, it cannot remove what is created below path meanwhile.
+type Remover interface {
+	// Remove removes the file or empty directory path, failing with
+	// ENOTEMPTY on a directory that is not empty.
+	Remove(path string) error
+}
+
+// FileCloseChecker is implemented by the FileSystems that tell whether a
+// file is closed, as *Fs does.
+type FileCloseChecker interface {
+	// IsFileClosed reports whether the file path is closed: not being
+	// written, nor having its last block recovered.
+	IsFileClosed(path string) (bool, error)
+}
+
+// Concater is implemented by the FileSystems that concatenate files without
+// copying their data, as *Fs does.
+type Concater interface {
+	// ConcatFiles moves the blocks of srcs, in order, to the end of the
+	// file target and deletes srcs.
+	ConcatFiles(target string, srcs []string) error
+}
+
+// Checksummer is implemented by the FileSystems that tell the checksum of
+// the content of a file, as *Fs does.
+type Checksummer interface {
+	// GetFileChecksum returns the checksum of the content of the file
+	// path, as its datanodes compute it.
+	GetFileChecksum(path string) (*FileChecksum, error)
+}
+
+// XAttrer is implemented by the FileSystems that store extended attributes
+// of paths, as *Fs does. Names are prefixed with their namespace: "user.",
+// "trusted.", "security." or "raw."; other names fail with EINVAL, and
+// missing attributes with ErrNoXAttr.
+type XAttrer interface {
+	// SetXAttr sets the attribute name of path to value, with flags
+	// XATTR_CREATE, XATTR_REPLACE, or 0 for either.
+	SetXAttr(path, name string, value []byte, flags int) error
+	// GetXAttr returns the value of the attribute name of path.
+	GetXAttr(path, name string) ([]byte, error)
+	// GetXAttrs returns the values of the attributes names of path, all
+	// of them if none is given.
+	GetXAttrs(path string, names ...string) (map[string][]byte, error)
+	// ListXAttrs returns the names of the attributes of path that the
+	// user may read.
+	ListXAttrs(path string) ([]string, error)
+	// RemoveXAttr removes the attribute name of path.
+	RemoveXAttr(path, name string) error
+}
+
+// Acler is implemented by the FileSystems that manage the POSIX ACLs of
+// paths, as *Fs does. The ACL of a path is changed by its owner or the
+// superuser; malformed ACLs fail with EINVAL.
+type Acler interface {
+	// GetAclStatus returns the ACL of path.
+	GetAclStatus(path string) (*AclStatus, error)
+	// SetAcl replaces the entries of the scopes of entries in the ACL of
+	// path. Those of the owner, group and others must be given, default
+	// ones being copied from the access ones; the mask is computed if not
+	// given.
+	SetAcl(path string, entries []AclEntry) error
+	// ModifyAclEntries adds entries to the ACL of path, replacing those
+	// of the same scope, type and name.
+	ModifyAclEntries(path string, entries []AclEntry) error
+	// RemoveAclEntries removes the entries of the scopes, types and names
+	// of entries from the ACL of path; their permission is ignored.
+	RemoveAclEntries(path string, entries []AclEntry) error
+	// RemoveDefaultAcl removes the default ACL of the directory path.
+	RemoveDefaultAcl(path string) error
+	// RemoveAcl removes every entry of the ACL of path but those its
+	// permission holds.
+	RemoveAcl(path string) error
+}
+
+// Snapshotter is implemented by the FileSystems that take read-only
+// snapshots of directories, as *Fs does. Only the superuser allows or
+// disallows snapshots on a directory; its owner takes, renames and deletes
+// them. The files of the snapshot name of dir are read under
+// SnapshotPath(dir, name).
+type Snapshotter interface {
+	// AllowSnapshot lets snapshots be taken of the directory path.
+	AllowSnapshot(path string) error
+	// DisallowSnapshot stops snapshots being taken of the directory
+	// path, which must have none left.
+	DisallowSnapshot(path string) error
+	// CreateSnapshot takes a snapshot of the directory path, named after
+	// the current time if name is empty, and returns its path.
+	CreateSnapshot(path, name string) (string, error)
+	// DeleteSnapshot deletes the snapshot name of the directory path.
+	DeleteSnapshot(path, name string) error
+	// RenameSnapshot renames the snapshot oldName of the directory path.
+	RenameSnapshot(path, oldName, newName string) error
+	// ListSnapshottableDirs returns the directories snapshots are allowed
+	// on, all of them for the superuser and those the user owns for
+	// others.
+	ListSnapshottableDirs() ([]*SnapshottableDir, error)
+	// SnapshotDiff returns the changes made to the directory path between
+	// its snapshots from and to, an empty name standing for its current
+	// state.
+	SnapshotDiff(path, from, to string) (*SnapshotDiffReport, error)
+}
+
+// Summarizer is implemented by the FileSystems that compute the disk usage
+// of a tree themselves, telling its quotas, as *Fs does; see
+// GetContentSummary.
+type Summarizer interface {
+	// GetContentSummary returns the disk usage and quotas of the tree
+	// rooted at path.
+	GetContentSummary(path string) (*ContentSummary, error)
+}
+
+// SpaceReporter is implemented by the FileSystems that tell the raw space
+// left for new blocks, as *Fs does; see GetRemaining.
+type SpaceReporter interface {
+	// GetRemaining returns the raw space the datanodes have left, which
+	// does not count the space used outside the file system.
+	GetRemaining() (int64, error)
+}
+
+// QuotaSetter is implemented by the FileSystems that limit the names and
+// space used under a directory, as *Fs does. Only the superuser sets
+// quotas; operations exceeding one fail with EDQUOT.
+type QuotaSetter interface {
+	// SetQuota sets the namespace quota, the files and directories of
+	// the tree of the directory path including itself, and the space
+	// quota, the bytes of its data times their replication. Either is
+	// QuotaDontSet to leave it as it is or QuotaReset to clear it.
+	SetQuota(path string, nsQuota, ssQuota int64) error
+	// SetTypeQuota sets the space quota of the directory path on the
+	// storage type t, or clears it with QuotaReset.
+	SetTypeQuota(path string, t StorageType, quota int64) error
+	// ClearQuota clears the namespace and space quotas of the directory
+	// path.
+	ClearQuota(path string) error
+}
+
+// Trasher is implemented by the FileSystems that tell where the trash of
+// the user is and how long it keeps files, as *Fs does; see MoveToTrash.
+type Trasher interface {
+	// TrashRoot returns the trash directory of the user for path:
+	// .Trash/<user> at the root of the encryption zone of the parent of
+	// path, whose files cannot be renamed out of it, or .Trash in the
+	// home directory.
+	TrashRoot(path string) (string, error)
+	// TrashRoots returns the trash directories of the user that exist.
+	TrashRoots() ([]string, error)
+	// TrashInterval returns how long files stay in the trash, 0 if it is
+	// disabled: fs.trash.interval of the namenode if set there, else of
+	// the client.
+	TrashInterval() (time.Duration, error)
+}
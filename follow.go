@@ -0,0 +1,192 @@
+package hdfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FollowOptions controls Follow. A nil *FollowOptions polls every second.
+type FollowOptions struct {
+	// Interval is the time between polls for new data, one second if 0.
+	Interval time.Duration
+}
+
+const defaultFollowInterval = time.Second
+
+// Follow returns a reader of the file path from offset on that, like
+// tail -f, waits for the file to grow instead of returning io.EOF. A
+// negative offset counts from the end of the file as a reader sees it,
+// which for a file being written is past the size GetPathInfo reports, and
+// an offset past the end starts at the end.
+//
+// Readers of HDFS only see the length of a file being written as of when
+// they open it, so at the end of the data the file is reopened, right away
+// and then every interval, to see what the writer has flushed since.
+// A file truncated below the offset read so far, or replaced by a shorter
+// one, as when a log is rotated, is read again from its start, and so is
+// the next file at path if it is removed or renamed away. A replacement at
+// least as long as what was read is not told apart from the file growing.
+//
+// Read blocks until there is data, the file cannot be read, or the reader
+// is closed, which makes a pending Read return os.ErrClosed.
+func Follow(fsys FileSystem, path string, offset int64, opts *FollowOptions) (io.ReadCloser, error) {
+	info, err := fsys.GetPathInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &PathError{"follow", path, syscall.EISDIR}
+	}
+	f, err := fsys.Open(path, O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if offset < 0 {
+		offset += end
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > end {
+		offset = end
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r := &follower{fsys: fsys, path: path, f: f, fresh: true, pos: offset, interval: defaultFollowInterval, done: make(chan struct{})}
+	if opts != nil && opts.Interval > 0 {
+		r.interval = opts.Interval
+	}
+	return r, nil
+}
+
+type follower struct {
+	fsys     FileSystem
+	path     string
+	interval time.Duration
+	done     chan struct{}
+	once     sync.Once
+
+	mu    sync.Mutex
+	f     FileHandle // nil between polls
+	fresh bool       // f was opened after the last data read
+	pos   int64
+}
+
+func (r *follower) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		select {
+		case <-r.done:
+			return 0, os.ErrClosed
+		default:
+		}
+		if r.f == nil {
+			if err := r.open(); err != nil {
+				return 0, err
+			}
+		}
+		if r.f != nil {
+			n, err := r.f.Read(p)
+			r.pos += int64(n)
+			if n > 0 {
+				r.fresh = false
+				return n, nil
+			}
+			if err != nil && err != io.EOF && !errors.Is(err, fs.ErrNotExist) {
+				return 0, err
+			}
+			fresh := r.fresh
+			r.f.Close()
+			r.f = nil
+			if !fresh {
+				continue // reopen at once: the writer may be ahead
+			}
+		}
+		if err := r.wait(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// open opens the file at the offset read so far, or at its start if it
+// was truncated below it or replaced; r.f is left nil while there is no
+// file at the path.
+func (r *follower) open() error {
+	info, err := r.fsys.GetPathInfo(r.path)
+	if err == nil && info.IsDir() {
+		err = &PathError{"follow", r.path, syscall.EISDIR}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		r.pos = 0 // removed or rotated: the next file is read whole
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f, err := r.fsys.Open(r.path, O_RDONLY, 0, 0, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.pos = 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.pos > 0 {
+		// A reader cannot seek past the length it sees, which is at least
+		// the offset read so far unless the file got shorter; the size
+		// from the namenode may lag behind for a file being written.
+		if _, err := f.Seek(r.pos, io.SeekStart); err != nil {
+			if info.Size >= r.pos {
+				f.Close()
+				return err
+			}
+			r.pos = 0
+		}
+	}
+	r.f, r.fresh = f, true
+	return nil
+}
+
+// wait waits for the next poll, failing with os.ErrClosed once r is closed.
+func (r *follower) wait() error {
+	t := time.NewTimer(r.interval)
+	defer t.Stop()
+	select {
+	case <-r.done:
+		return os.ErrClosed
+	case <-t.C:
+		return nil
+	}
+}
+
+// Close stops r, making a pending Read return os.ErrClosed.
+func (r *follower) Close() error {
+	err := os.ErrClosed
+	r.once.Do(func() {
+		close(r.done)
+		err = nil
+	})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package hdfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+// readFollow reads n bytes from r, failing the test if they do not come in
+// time.
+func readFollow(t *testing.T, r io.Reader, n int) string {
+	type result struct {
+		b   []byte
+		err error
+	}
+	c := make(chan result, 1)
+	go func() {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		c <- result{b, err}
+	}()
+	select {
+	case res := <-c:
+		if res.err != nil {
+			t.Fatalf("Error on reading followed file: %v\n", res.err)
+		}
+		return string(res.b)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout on reading %d bytes of followed file\n", n)
+		return ""
+	}
+}
+
+func TestFollow(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	w, err := m.Open("/log", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating file: %v\n", err)
+	}
+	io.WriteString(w, "old lines\n")
+	w.Flush()
+
+	r, err := hdfs.Follow(m, "/log", -6, &hdfs.FollowOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error on following file: %v\n", err)
+	}
+	defer r.Close()
+	if got := readFollow(t, r, 6); got != "lines\n" {
+		t.Errorf("Tail of file: got %q\n", got)
+	}
+
+	// data shows up once flushed by the writer
+	io.WriteString(w, "new line\n")
+	w.Flush()
+	if got := readFollow(t, r, 9); got != "new line\n" {
+		t.Errorf("Flushed data: got %q\n", got)
+	}
+	io.WriteString(w, "last\n")
+	w.Close()
+	if got := readFollow(t, r, 5); got != "last\n" {
+		t.Errorf("Data of closed file: got %q\n", got)
+	}
+
+	// rotation: the file is renamed away and a new one created
+	if err := m.Rename("/log", "/log.1"); err != nil {
+		t.Fatalf("Error on rotating file: %v\n", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	w, _ = m.Open("/log", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	io.WriteString(w, "rotated\n")
+	w.Close()
+	if got := readFollow(t, r, 8); got != "rotated\n" {
+		t.Errorf("Rotated file: got %q\n", got)
+	}
+
+	// truncation: the file is replaced by a shorter one in place
+	w, _ = m.Open("/log.tmp", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	io.WriteString(w, "new\n")
+	w.Close()
+	m.Delete("/log")
+	m.Rename("/log.tmp", "/log")
+	if got := readFollow(t, r, 4); got != "new\n" {
+		t.Errorf("Truncated file: got %q\n", got)
+	}
+
+	// Close unblocks a pending Read
+	c := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		c <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := r.Close(); err != nil {
+		t.Errorf("Error on closing: %v\n", err)
+	}
+	select {
+	case err := <-c:
+		if !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read pending on Close: got %v\n", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Read still pending after Close\n")
+	}
+	if err := r.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Second Close: got %v\n", err)
+	}
+}
+
+func TestFollowErrors(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.CreateDirectory("/dir")
+	if _, err := hdfs.Follow(m, "/missing", 0, nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Following missing file: got %v\n", err)
+	}
+	if _, err := hdfs.Follow(m, "/dir", 0, nil); !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("Following directory: got %v\n", err)
+	}
+}
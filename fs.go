@@ -0,0 +1,118 @@
+package hdfs
+
+import "io"
+
+// Walk the file tree rooted at root, calling fn for each file or directory in the tree, including root, in lexical order.
+// root: The path of the tree.
+// fn: The function called for each path; see WalkFunc for the meaning of fs.SkipDir and fs.SkipAll.
+// Returns nil, or the error returned by fn.
+func (fs *Fs) Walk(root string, fn WalkFunc) error {
+	return Walk(fs, root, fn)
+}
+
+// Walk the file tree rooted at root, listing up to workers directories concurrently.
+// root: The path of the tree.
+// workers: The maximum number of concurrent hdfsListDirectory calls.
+// skip: Reports directories not to descend into, or nil.
+// done: Closing it stops the walk early, or nil.
+// Returns a channel of the paths found, in no particular order, closed when the walk is over.
+func (fs *Fs) WalkParallel(root string, workers int, skip func(path string, info *FileInfo) bool, done <-chan struct{}) <-chan WalkResult {
+	return WalkParallel(fs, root, workers, skip, done)
+}
+
+// Get the files and directories matching a pattern, listing only the directories the pattern ranges over.
+// pattern: A path.Match pattern per path element, plus {a,b} alternations and ** for any number of directories.
+// Returns a slice of FileInfo struct pointer sorted by name, empty if nothing matches, or nil on error.
+func (fs *Fs) Glob(pattern string) ([]*FileInfo, error) {
+	return Glob(fs, pattern)
+}
+
+// Remove a path and everything below it, path by path if a recursive delete fails.
+// path: The path to remove; a missing path is not an error.
+// opts: Dry-run mode and change reporting, or nil.
+// Returns nil on success, or a *TreeError listing the paths that could not be removed.
+func (fs *Fs) RemoveAll(path string, opts *TreeOptions) error {
+	return RemoveAll(fs, path, opts)
+}
+
+// Copy a file or directory tree, possibly to another filesystem.
+// src: The path of the source tree.
+// dstFS: The handle to the destination filesystem.
+// dst: The path of the copy; an existing directory receives a copy named after src.
+// opts: Dry-run mode and change reporting, or nil.
+// Returns nil on success, or error.
+func (fs *Fs) CopyTree(src string, dstFS FileSystem, dst string, opts *TreeOptions) error {
+	return CopyTree(fs, src, dstFS, dst, opts)
+}
+
+// Change the permissions of a path and everything below it.
+// path: The path of the tree.
+// mode: The bitmask to set it to.
+// opts: Dry-run mode and change reporting, or nil.
+// Returns nil on success, or a *TreeError listing the failing paths.
+func (fs *Fs) ChmodRecursive(path string, mode int16, opts *TreeOptions) error {
+	return ChmodRecursive(fs, path, mode, opts)
+}
+
+// Change the owner and group of a path and everything below it.
+// path: The path of the tree.
+// owner: The new owner, or empty to leave it unchanged.
+// group: The new group, or empty to leave it unchanged.
+// opts: Dry-run mode and change reporting, or nil.
+// Returns nil on success, or a *TreeError listing the failing paths.
+func (fs *Fs) ChownRecursive(path, owner, group string, opts *TreeOptions) error {
+	return ChownRecursive(fs, path, owner, group, opts)
+}
+
+// Set the replication of every file in a tree.
+// path: The path of the tree.
+// replication: The new replication factor.
+// opts: Dry-run mode and change reporting, or nil.
+// Returns nil on success, or a *TreeError listing the failing paths.
+func (fs *Fs) SetReplicationRecursive(path string, replication int16, opts *TreeOptions) error {
+	return SetReplicationRecursive(fs, path, replication, opts)
+}
+
+// Follow a growing file, as tail -f does, polling every second for the data flushed by its writer.
+// path: The path of the file.
+// offset: The offset to start reading at, counted from the end if negative.
+// Returns a reader waiting for the file to grow at its end, or error.
+func (fs *Fs) Follow(path string, offset int64) (io.ReadCloser, error) {
+	return Follow(fs, path, offset, nil)
+}
+
+// Truncate a file, waiting for the namenode to recover its last block unless newLength is on a block boundary.
+// path: The path of the file.
+// newLength: The size to cut the file to, at most its current size.
+// Returns nil once the file is truncated and closed again, or error; the wait has no bound, see TruncateContext.
+func (fs *Fs) Truncate(path string, newLength int64) error {
+	return Truncate(fs, path, newLength)
+}
+
+// Concatenate files to the end of another, moving their blocks on the namenode.
+// target: The path of the file to append to.
+// srcs: The paths of the files to append, in order, deleted once appended; they must share the block size of target.
+// Returns nil on success, or error.
+func (fs *Fs) Concat(target string, srcs []string) error {
+	return Concat(fs, target, srcs)
+}
+
+// Move a path to the trash of the user, as hdfs dfs -rm does, unless the trash is disabled (fs.trash.interval is 0) or the path is in the trash already.
+// path: The path to move.
+// Returns where the path went, under Current in the trash root, or "" if it was not moved; or error.
+func (fs *Fs) MoveToTrash(path string) (string, error) {
+	return MoveToTrash(fs, path)
+}
+
+// Delete the checkpoints of the trash of the user older than fs.trash.interval, then checkpoint what was moved there since, as hdfs dfs -expunge does.
+// Returns nil on success, or the first error.
+func (fs *Fs) Expunge() error {
+	return Expunge(fs)
+}
+
+// Move a path back from the trash of the user, from Current or else the newest checkpoint holding it.
+// path: The path the file or directory had, which must not exist.
+// Returns where the path was in the trash, or error.
+func (fs *Fs) RestoreFromTrash(path string) (string, error) {
+	return RestoreFromTrash(fs, path)
+}
@@ -0,0 +1,279 @@
+package hdfs
+
+import (
+	"context"
+	"time"
+)
+
+// The Context variants of the Fs methods return ctx.Err() as soon as ctx is
+// done, leaving the libhdfs call or RPC to finish on its own goroutine.
+// Whatever the abandoned call acquires is released when it returns: open
+// files are closed, connections are disconnected, and hdfsFileInfo arrays and
+// host lists are freed as by the plain methods. Buffers are copied, so they may be reused
+// at once. An abandoned call that modifies the file system may still take
+// effect, and one holding a file's lock keeps other calls on that file
+// waiting until it returns.
+
+// ConnectAsUserContext is ConnectAsUser with a context.
+func ConnectAsUserContext(ctx context.Context, host string, port uint16, user string) (*Fs, error) {
+	v, err := call(ctx, func() (interface{}, error) {
+		return ConnectAsUser(host, port, user)
+	}, func(v interface{}) { v.(*Fs).Disconnect() })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Fs), nil
+}
+
+// ConnectContext is Connect with a context.
+func ConnectContext(ctx context.Context, host string, port uint16) (*Fs, error) {
+	return ConnectAsUserContext(ctx, host, port, "")
+}
+
+// ConnectConfigContext is ConnectConfig with a context.
+func ConnectConfigContext(ctx context.Context, cfg *Config) (*Fs, error) {
+	v, err := call(ctx, func() (interface{}, error) {
+		return ConnectConfig(cfg)
+	}, func(v interface{}) { v.(*Fs).Disconnect() })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Fs), nil
+}
+
+// DisconnectContext is Disconnect with a context.
+func (fs *Fs) DisconnectContext(ctx context.Context) error {
+	return callErr(ctx, fs.Disconnect)
+}
+
+// OpenFileContext is OpenFile with a context.
+func (fs *Fs) OpenFileContext(ctx context.Context, path string, flags int, buffersize int, replication int, blocksize uint32) (*File, error) {
+	v, err := call(ctx, func() (interface{}, error) {
+		return fs.OpenFile(path, flags, buffersize, replication, blocksize)
+	}, func(v interface{}) { v.(*File).Close() })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*File), nil
+}
+
+// CloseFileContext is CloseFile with a context.
+func (fs *Fs) CloseFileContext(ctx context.Context, file *File) error {
+	return callErr(ctx, func() error { return fs.CloseFile(file) })
+}
+
+// ExistsContext is Exists with a context.
+func (fs *Fs) ExistsContext(ctx context.Context, path string) error {
+	return callErr(ctx, func() error { return fs.Exists(path) })
+}
+
+// SeekContext is Seek with a context.
+func (fs *Fs) SeekContext(ctx context.Context, file *File, pos int64) error {
+	return callErr(ctx, func() error { return fs.Seek(file, pos) })
+}
+
+// TellContext is Tell with a context.
+func (fs *Fs) TellContext(ctx context.Context, file *File) (int64, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fs.Tell(file) }, nil)
+	if err != nil {
+		return -1, err
+	}
+	return v.(int64), nil
+}
+
+// ReadContext is Read with a context. A length of 0 or less reads nothing.
+func (fs *Fs) ReadContext(ctx context.Context, file *File, buffer []byte, length int) (uint32, error) {
+	if length <= 0 {
+		return 0, nil
+	}
+	if ctx.Done() == nil {
+		return fs.Read(file, buffer, length)
+	}
+	private := make([]byte, length)
+	v, err := call(ctx, func() (interface{}, error) { return fs.Read(file, private, length) }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(copy(buffer, private[:v.(uint32)])), nil
+}
+
+// PreadContext is Pread with a context. A length of 0 or less reads nothing.
+func (fs *Fs) PreadContext(ctx context.Context, file *File, position int64, buffer []byte, length int) (uint32, error) {
+	if length <= 0 {
+		return 0, nil
+	}
+	if ctx.Done() == nil {
+		return fs.Pread(file, position, buffer, length)
+	}
+	private := make([]byte, length)
+	v, err := call(ctx, func() (interface{}, error) { return fs.Pread(file, position, private, length) }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(copy(buffer, private[:v.(uint32)])), nil
+}
+
+// WriteContext is Write with a context. A length of 0 or less writes
+// nothing.
+func (fs *Fs) WriteContext(ctx context.Context, file *File, buffer []byte, length int) (uint32, error) {
+	if length <= 0 {
+		return 0, nil
+	}
+	if ctx.Done() == nil {
+		return fs.Write(file, buffer, length)
+	}
+	private := append([]byte(nil), buffer[:length]...)
+	v, err := call(ctx, func() (interface{}, error) { return fs.Write(file, private, length) }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint32), nil
+}
+
+// FlushContext is Flush with a context.
+func (fs *Fs) FlushContext(ctx context.Context, file *File) error {
+	return callErr(ctx, func() error { return fs.Flush(file) })
+}
+
+// HflushContext is Hflush with a context.
+func (fs *Fs) HflushContext(ctx context.Context, file *File) error {
+	return callErr(ctx, func() error { return fs.Hflush(file) })
+}
+
+// HsyncContext is Hsync with a context.
+func (fs *Fs) HsyncContext(ctx context.Context, file *File) error {
+	return callErr(ctx, func() error { return fs.Hsync(file) })
+}
+
+// AvailableContext is Available with a context.
+func (fs *Fs) AvailableContext(ctx context.Context, file *File) (uint32, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fs.Available(file) }, nil)
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint32), nil
+}
+
+// CopyContext is Copy with a context.
+func (fs *Fs) CopyContext(ctx context.Context, src string, dstFS *Fs, dst string) error {
+	return callErr(ctx, func() error { return fs.Copy(src, dstFS, dst) })
+}
+
+// MoveContext is Move with a context.
+func (fs *Fs) MoveContext(ctx context.Context, src string, dstFS *Fs, dst string) error {
+	return callErr(ctx, func() error { return fs.Move(src, dstFS, dst) })
+}
+
+// DeleteContext is Delete with a context.
+func (fs *Fs) DeleteContext(ctx context.Context, path string) error {
+	return callErr(ctx, func() error { return fs.Delete(path) })
+}
+
+// RenameContext is Rename with a context.
+func (fs *Fs) RenameContext(ctx context.Context, oldpath, newpath string) error {
+	return callErr(ctx, func() error { return fs.Rename(oldpath, newpath) })
+}
+
+// GetWorkingDirectoryContext is GetWorkingDirectory with a context.
+func (fs *Fs) GetWorkingDirectoryContext(ctx context.Context, buffer []byte, size uint32) ([]byte, error) {
+	if ctx.Done() == nil {
+		return fs.GetWorkingDirectory(buffer, size)
+	}
+	private := make([]byte, size)
+	if _, err := call(ctx, func() (interface{}, error) { return fs.GetWorkingDirectory(private, size) }, nil); err != nil {
+		return nil, err
+	}
+	copy(buffer, private)
+	return buffer, nil
+}
+
+// SetWorkingDirectoryContext is SetWorkingDirectory with a context.
+func (fs *Fs) SetWorkingDirectoryContext(ctx context.Context, path string) error {
+	return callErr(ctx, func() error { return fs.SetWorkingDirectory(path) })
+}
+
+// CreateDirectoryContext is CreateDirectory with a context.
+func (fs *Fs) CreateDirectoryContext(ctx context.Context, path string) error {
+	return callErr(ctx, func() error { return fs.CreateDirectory(path) })
+}
+
+// SetReplicationContext is SetReplication with a context.
+func (fs *Fs) SetReplicationContext(ctx context.Context, path string, replication int16) error {
+	return callErr(ctx, func() error { return fs.SetReplication(path, replication) })
+}
+
+// ListDirectoryContext is ListDirectory with a context.
+func (fs *Fs) ListDirectoryContext(ctx context.Context, path string) ([]*FileInfo, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fs.ListDirectory(path) }, nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*FileInfo), nil
+}
+
+// GetPathInfoContext is GetPathInfo with a context.
+func (fs *Fs) GetPathInfoContext(ctx context.Context, path string) (*FileInfo, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fs.GetPathInfo(path) }, nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*FileInfo), nil
+}
+
+// GetHostsContext is GetHosts with a context.
+func (fs *Fs) GetHostsContext(ctx context.Context, path string, start, length int64) ([][]string, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fs.GetHosts(path, start, length) }, nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.([][]string), nil
+}
+
+// GetDefaultBlockSizeContext is GetDefaultBlockSize with a context.
+func (fs *Fs) GetDefaultBlockSizeContext(ctx context.Context) (int64, error) {
+	return callInt64(ctx, fs.GetDefaultBlockSize)
+}
+
+// GetCapacityContext is GetCapacity with a context.
+func (fs *Fs) GetCapacityContext(ctx context.Context) (int64, error) {
+	return callInt64(ctx, fs.GetCapacity)
+}
+
+// GetUsedContext is GetUsed with a context.
+func (fs *Fs) GetUsedContext(ctx context.Context) (int64, error) {
+	return callInt64(ctx, fs.GetUsed)
+}
+
+// GetRemainingContext is GetRemaining with a context.
+func (fs *Fs) GetRemainingContext(ctx context.Context) (int64, error) {
+	return callInt64(ctx, fs.GetRemaining)
+}
+
+func callInt64(ctx context.Context, fn func() (int64, error)) (int64, error) {
+	v, err := call(ctx, func() (interface{}, error) { return fn() }, nil)
+	if err != nil {
+		return -1, err
+	}
+	return v.(int64), nil
+}
+
+// ChownContext is Chown with a context.
+func (fs *Fs) ChownContext(ctx context.Context, path, owner, group string) error {
+	return callErr(ctx, func() error { return fs.Chown(path, owner, group) })
+}
+
+// ChmodContext is Chmod with a context.
+func (fs *Fs) ChmodContext(ctx context.Context, path string, mode int16) error {
+	return callErr(ctx, func() error { return fs.Chmod(path, mode) })
+}
+
+// UtimeContext is Utime with a context.
+func (fs *Fs) UtimeContext(ctx context.Context, path string, mtime, atime time.Time) error {
+	return callErr(ctx, func() error { return fs.Utime(path, mtime, atime) })
+}
+
+// TruncateContext is Truncate with a context; see the package function
+// TruncateContext.
+func (fs *Fs) TruncateContext(ctx context.Context, path string, newLength int64) error {
+	return TruncateContext(ctx, fs, path, newLength)
+}
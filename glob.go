@@ -0,0 +1,213 @@
+package hdfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns the files and directories matching pattern, sorted by name
+// and without duplicates, or an empty slice if there is no match. Besides
+// the syntax of path.Match (*, ?, [...] and \ escapes) within a path element,
+// pattern may contain {a,b} alternations, which may nest and span elements,
+// and ** elements matching any number of directories. Only the directories
+// a meta character ranges over are listed, each at most once. The only
+// possible error besides listing failures is path.ErrBadPattern.
+func Glob(fsys FileSystem, pattern string) ([]*FileInfo, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	g := &globber{fsys: fsys, listings: map[string][]*FileInfo{}}
+	seen := map[string]bool{}
+	matches := []*FileInfo{}
+	for _, p := range patterns {
+		infos, err := g.glob(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if !seen[info.Name] {
+				seen[info.Name] = true
+				matches = append(matches, info)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	return matches, nil
+}
+
+// expandBraces expands the {a,b} alternations of pattern, outermost first.
+func expandBraces(pattern string) ([]string, error) {
+	open, depth := -1, 0
+	var alts []string
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open, start = i, i+1
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				alts = append(alts, pattern[start:i])
+				start = i + 1
+			}
+		case '}':
+			if depth == 0 {
+				continue // literal, as in the shell
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			alts = append(alts, pattern[start:i])
+			var expanded []string
+			for _, alt := range alts {
+				more, err := expandBraces(pattern[:open] + alt + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, more...)
+			}
+			return expanded, nil
+		}
+	}
+	if depth != 0 {
+		return nil, path.ErrBadPattern
+	}
+	return []string{pattern}, nil
+}
+
+// globber expands brace-free patterns, caching directory listings across
+// the patterns of a single Glob call.
+type globber struct {
+	fsys     FileSystem
+	listings map[string][]*FileInfo
+}
+
+// candidate is a path matching a pattern prefix; info is nil until known.
+type candidate struct {
+	path string
+	info *FileInfo
+}
+
+// fsPath returns the path to hand to fsys; "" is the working directory.
+func (c candidate) fsPath() string {
+	if c.path == "" {
+		return "."
+	}
+	return c.path
+}
+
+func (g *globber) glob(pattern string) ([]*FileInfo, error) {
+	var elems []string
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "" {
+			continue
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	cands := []candidate{{}}
+	if strings.HasPrefix(pattern, "/") {
+		cands[0].path = "/"
+	}
+	for i, elem := range elems {
+		last := i == len(elems)-1
+		var next []candidate
+		for _, c := range cands {
+			var err error
+			switch {
+			case elem == "**":
+				next, err = g.descend(c, last, next)
+			case !hasMeta(elem):
+				next = append(next, candidate{path.Join(c.path, elem), nil})
+			default:
+				next, err = g.match(c, elem, next)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		cands = next
+	}
+	var infos []*FileInfo
+	for _, c := range cands {
+		if err := g.stat(&c); err != nil {
+			return nil, err
+		}
+		if c.info != nil {
+			infos = append(infos, c.info)
+		}
+	}
+	return infos, nil
+}
+
+// stat fills in c.info, leaving it nil if c does not exist.
+func (g *globber) stat(c *candidate) error {
+	if c.info != nil {
+		return nil
+	}
+	info, err := g.fsys.GetPathInfo(c.fsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	c.info = info
+	return err
+}
+
+// list returns the entries of c, or nil if c is not a directory.
+func (g *globber) list(c *candidate) ([]*FileInfo, error) {
+	if err := g.stat(c); err != nil || c.info == nil || !c.info.IsDir() {
+		return nil, err
+	}
+	if infos, ok := g.listings[c.path]; ok {
+		return infos, nil
+	}
+	infos, err := g.fsys.ListDirectory(c.fsPath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	g.listings[c.path] = infos
+	return infos, nil
+}
+
+// match appends the entries of c matching elem to next.
+func (g *globber) match(c candidate, elem string, next []candidate) ([]candidate, error) {
+	infos, err := g.list(&c)
+	for _, info := range infos {
+		name := baseName(info.Name)
+		if ok, _ := path.Match(elem, name); ok {
+			next = append(next, candidate{path.Join(c.path, name), info})
+		}
+	}
+	return next, err
+}
+
+// descend appends c and everything below it to next, as ** matches any
+// number of directories; unless ** is the last element, only directories
+// are kept.
+func (g *globber) descend(c candidate, last bool, next []candidate) ([]candidate, error) {
+	if err := g.stat(&c); err != nil || c.info == nil {
+		return next, err
+	}
+	if !last && !c.info.IsDir() {
+		return next, nil
+	}
+	next = append(next, c)
+	infos, err := g.list(&c)
+	for _, info := range infos {
+		if next, err = g.descend(candidate{path.Join(c.path, baseName(info.Name)), info}, last, next); err != nil {
+			return next, err
+		}
+	}
+	return next, err
+}
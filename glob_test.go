@@ -0,0 +1,109 @@
+package hdfs_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+// countingFS records the directories listed through it.
+type countingFS struct {
+	hdfs.FileSystem
+	listed []string
+}
+
+func (c *countingFS) ListDirectory(p string) ([]*hdfs.FileInfo, error) {
+	c.listed = append(c.listed, p)
+	return c.FileSystem.ListDirectory(p)
+}
+
+func TestExpandBraces(t *testing.T) {
+	m := newTree(t, "/b/x1", "/b/x2", "/b/y1", "/b/z/w1", "/b/{lit}")
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/b/{x,y}1", []string{"/b/x1", "/b/y1"}},
+		{"/b/{x{1,2},z/w*}", []string{"/b/x1", "/b/x2", "/b/z/w1"}},
+		{"/b/{x,x}1", []string{"/b/x1"}},
+		{"/b/\\{lit}", []string{"/b/{lit}"}},
+	}
+	for _, tt := range tests {
+		infos, err := hdfs.Glob(m, tt.pattern)
+		if err != nil {
+			t.Errorf("Glob(%s): %v\n", tt.pattern, err)
+			continue
+		}
+		if got := paths(infos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Glob(%s): got %v, want %v\n", tt.pattern, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"/b/{x", "/b/{x,{y}", "/b/[x"} {
+		if _, err := hdfs.Glob(m, bad); err != path.ErrBadPattern {
+			t.Errorf("Glob(%s): got %v, want ErrBadPattern\n", bad, err)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	m := newTree(t,
+		"/logs/2025-12/part-0", "/logs/2025-12/part-1",
+		"/logs/2026-01/part-0", "/logs/2026-01/part-1", "/logs/2026-01/part-2",
+		"/logs/2026-02/part-00", "/logs/2026-02/_SUCCESS",
+		"/logs/2026-02/nested/deep/part-9",
+	)
+	c := &countingFS{FileSystem: m}
+	infos, err := hdfs.Glob(c, "/logs/2026-*/part-{0,1}*")
+	if err != nil {
+		t.Fatalf("Error on globbing: %v\n", err)
+	}
+	want := []string{"/logs/2026-01/part-0", "/logs/2026-01/part-1", "/logs/2026-02/part-00"}
+	if got := paths(infos); !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob: got %v, want %v\n", got, want)
+	}
+	if want = []string{"/logs", "/logs/2026-01", "/logs/2026-02"}; !reflect.DeepEqual(c.listed, want) {
+		t.Errorf("Listed directories: got %v, want %v\n", c.listed, want)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/logs/**/part-?", []string{"/logs/2025-12/part-0", "/logs/2025-12/part-1", "/logs/2026-01/part-0", "/logs/2026-01/part-1", "/logs/2026-01/part-2", "/logs/2026-02/nested/deep/part-9"}},
+		{"/logs/2026-02/**", []string{"/logs/2026-02", "/logs/2026-02/_SUCCESS", "/logs/2026-02/nested", "/logs/2026-02/nested/deep", "/logs/2026-02/nested/deep/part-9", "/logs/2026-02/part-00"}},
+		{"/logs/202[^6]-*/part-[1-9]", []string{"/logs/2025-12/part-1"}},
+		{"/logs/2026-01/part-2", []string{"/logs/2026-01/part-2"}},
+		{"/logs/2026-01/part-3", []string{}},
+		{"/logs/2027-*/*", []string{}},
+		{"/logs/2026-01/part-2/*", []string{}},
+	}
+	for _, tt := range tests {
+		infos, err := hdfs.Glob(m, tt.pattern)
+		if err != nil {
+			t.Errorf("Glob(%s): %v\n", tt.pattern, err)
+			continue
+		}
+		if got := paths(infos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Glob(%s): got %v, want %v\n", tt.pattern, got, tt.want)
+		}
+	}
+
+	if err = m.SetWorkingDirectory("/logs"); err != nil {
+		t.Fatalf("Error on setting working directory: %v\n", err)
+	}
+	infos, err = hdfs.Glob(m, "2025-*/part-0")
+	if want = []string{"/logs/2025-12/part-0"}; err != nil || !reflect.DeepEqual(paths(infos), want) {
+		t.Errorf("Relative glob: got %v, %v; want %v\n", paths(infos), err, want)
+	}
+}
+
+// paths strips the scheme and authority from the names of infos.
+func paths(infos []*hdfs.FileInfo) []string {
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name[len("hdfs://localhost:8020"):])
+	}
+	return names
+}
@@ -1,9 +1,13 @@
+//go:build cgo && !purego
+
 package hdfs
 
 // #cgo linux CFLAGS: -I/opt/jdk/include -I/opt/jdk/include/linux
 // #cgo linux LDFLAGS: -Llib -lhdfs -L/opt/jdk/jre/lib/amd64/server -ljvm
+// #cgo darwin CFLAGS: -I/System/Library/Frameworks/JavaVM.framework/Headers
 // #cgo darwin LDFLAGS: -L/usr/lib/java -lhdfs -framework JavaVM
 // #include "hdfs.h"
+// #include "hdfs_shim.h"
 /*
 int getlen(char*** ptr) {
     int i = 0;
@@ -23,19 +27,13 @@ import "C"
 
 import (
 	"fmt"
+	"os"
 	"sync"
+	"syscall"
 	"time"
 	"unsafe"
 )
 
-const (
-	O_RDONLY  = int(C.O_RDONLY)
-	O_WRONLY  = int(C.O_WRONLY)
-	O_CREATE  = int(C.O_CREAT)
-	O_APPEND  = int(C.O_APPEND)
-	EINTERNAL = int(C.EINTERNAL)
-)
-
 type hdfsFS struct {
 	cptr C.hdfsFS
 }
@@ -43,40 +41,24 @@ type hdfsFS struct {
 type hdfsFile struct {
 	cptr C.hdfsFile
 	*sync.RWMutex
-}
-
-type hdfsFileInfo struct {
-	cptr C.hdfsFileInfo
-}
-
-type FileInfo struct {
-	meta         hdfsFileInfo
-	Kind         byte
-	Name         string
-	LastMod      time.Time
-	Size         int64
-	Replication  int16
-	BlockSize    int64
-	Owner, Group string
-	Permissions  int16
-	LastAccess   time.Time
+	fs    *Fs
+	path  string
+	flags int
 }
 
 type File hdfsFile
 type Fs hdfsFS
 
-func (info *FileInfo) String() (ret string) {
-	ret = fmt.Sprintf("%-8s\t:  %s\n", "Name", info.Name) +
-		fmt.Sprintf("%-8s\t:  %c\n", "Type", info.Kind) +
-		fmt.Sprintf("%-8s\t:  %d\n", "Replication", info.Replication) +
-		fmt.Sprintf("%-8s\t:  %v\n", "BlockSize", info.BlockSize) +
-		fmt.Sprintf("%-8s\t:  %v\n", "Size", info.Size) +
-		fmt.Sprintf("%-8s\t:  %v\n", "LastMod", info.LastMod) +
-		fmt.Sprintf("%-8s\t:  %v\n", "LastAccess", info.LastAccess) +
-		fmt.Sprintf("%-8s\t:  %s\n", "Owner", info.Owner) +
-		fmt.Sprintf("%-8s\t:  %s\n", "Group", info.Group) +
-		fmt.Sprintf("%-8s\t:  %b\n", "Permissions", info.Permissions)
-	return
+var _ FileSystem = (*Fs)(nil)
+
+// isClosed reports whether the file was closed; file.go uses it.
+func (f *File) isClosed() bool {
+	return f.cptr == nil
+}
+
+// release forgets the libhdfs handle once it is closed.
+func (f *File) release() {
+	f.cptr = nil
 }
 
 //Factory method for get a *hdfs.Fs handle: connect to a hdfs file system as a specific user.
@@ -115,12 +97,68 @@ func Connect(host string, port uint16) (*Fs, error) {
 	return ConnectAsUser(host, port, "")
 }
 
+//Factory method for get a *hdfs.Fs handle: connect to a hdfs file system with a Config.
+//cfg: The namenode URI, user, configuration directory and configuration overrides; see Config.
+//Returns a handle to the filesystem or nil on error.
+func ConnectConfig(cfg *Config) (*Fs, error) {
+	uri, err := cfg.uri()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := cfg.resources()
+	if err != nil {
+		return nil, err
+	}
+	keys, values := cfg.settings()
+	var u, usr *C.char
+	if uri != "" {
+		u = C.CString(uri)
+		defer C.free(unsafe.Pointer(u))
+	}
+	if cfg.User != "" {
+		usr = C.CString(cfg.User)
+		defer C.free(unsafe.Pointer(usr))
+	}
+	r, k, v := cStrings(resources), cStrings(keys), cStrings(values)
+	defer freeCStrings(r, len(resources))
+	defer freeCStrings(k, len(keys))
+	defer freeCStrings(v, len(values))
+	ret, err := C.hdfsConnectWithConf(u, usr, r, C.int(len(resources)), k, v, C.int(len(keys)))
+	if ret == nil {
+		return nil, &PathError{"connect", uri, classify(err, nil)}
+	}
+	return &Fs{ret}, nil
+}
+
+// cStrings returns a C array of copies of s, to be freed with freeCStrings.
+func cStrings(s []string) **C.char {
+	if len(s) == 0 {
+		return nil
+	}
+	p := (**C.char)(C.malloc(C.size_t(len(s)) * C.size_t(unsafe.Sizeof((*C.char)(nil)))))
+	a := unsafe.Slice(p, len(s))
+	for i := range s {
+		a[i] = C.CString(s[i])
+	}
+	return p
+}
+
+func freeCStrings(p **C.char, n int) {
+	if p == nil {
+		return
+	}
+	for _, c := range unsafe.Slice(p, n) {
+		C.free(unsafe.Pointer(c))
+	}
+	C.free(unsafe.Pointer(p))
+}
+
 //Disconnect from the hdfs file system.
 //Returns nil on success, else error
 func (fs *Fs) Disconnect() error {
 	ret, err := C.hdfsDisconnect(fs.cptr)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return &PathError{"disconnect", "", classify(err, nil)} // not fs.pathError: the handle is gone
 	}
 	return nil
 }
@@ -140,36 +178,67 @@ func (fs *Fs) OpenFile(path string, flags int, buffersize int, replication int,
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	file, err := C.hdfsOpenFile(fs.cptr, p, C.int(flags), C.int(buffersize), C.short(replication), C.tSize(blocksize))
-	if err != nil && file == (C.hdfsFile)(unsafe.Pointer(uintptr(0))) {
+	if file == (C.hdfsFile)(unsafe.Pointer(uintptr(0))) {
+		return nil, fs.pathError("open", path, err)
+	}
+	return &File{file, new(sync.RWMutex), fs, path, flags}, nil
+}
+
+//Open is OpenFile returning the file as a FileHandle, so that *Fs satisfies FileSystem.
+func (fs *Fs) Open(path string, flags int, buffersize int, replication int, blocksize uint32) (FileHandle, error) {
+	file, err := fs.OpenFile(path, flags, buffersize, replication, blocksize)
+	if err != nil {
 		return nil, err
 	}
-	return &File{file, new(sync.RWMutex)}, nil
+	return file, nil
 }
 
-//Close an open file. 
+//Close an open file, releasing its handle even when closing fails.
 //file: The file handle.
-//Returns nil on success, or error.  
+//Returns nil on success, or error; os.ErrClosed if the file is already closed.
 func (fs *Fs) CloseFile(file *File) error {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"close", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsCloseFile(fs.cptr, file.cptr)
-	if err != nil && ret == C.int(-1) {
-		return err
+	file.release()
+	if ret == C.int(-1) {
+		return fs.pathError("close", file.path, err)
 	}
 	return nil
 }
 
 //Checks if a given path exsits on the filesystem.
 //path: The path to look for.
-//Returns nil on success, or error.
+//Returns nil on success, or error; the error satisfies errors.Is(err, fs.ErrNotExist) if the path does not exist.
 func (fs *Fs) Exists(path string) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsExists(fs.cptr, p)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		if err == nil {
+			// libhdfs leaves errno alone when the path is simply missing
+			return &PathError{"exists", path, syscall.ENOENT}
+		}
+		return fs.pathError("exists", path, err)
 	}
 	return nil
 }
 
+// exists reports whether path exists; pathError uses it to verify ENOENT.
+func (fs *Fs) exists(path string) bool {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	return C.hdfsExists(fs.cptr, p) == C.int(0)
+}
+
+// pathError wraps err, as set by the libhdfs call behind op, in a *PathError.
+func (fs *Fs) pathError(op, path string, err error) error {
+	return &PathError{op, path, classify(err, func() bool { return fs.exists(path) })}
+}
+
 //Seek to given offset in file. This works only for files opened in read-only mode. 
 //file: The file handle.
 //pos: Offset into the file to seek into.
@@ -177,9 +246,12 @@ func (fs *Fs) Exists(path string) error {
 func (fs *Fs) Seek(file *File, pos int64) error {
 	file.Lock()
 	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"seek", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsSeek(fs.cptr, file.cptr, C.tOffset(pos))
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("seek", file.path, err)
 	}
 	return nil
 }
@@ -188,9 +260,14 @@ func (fs *Fs) Seek(file *File, pos int64) error {
 //file: The file handle.
 //Returns current offset, or error.
 func (fs *Fs) Tell(file *File) (int64, error) {
+	file.RLock()
+	defer file.RUnlock()
+	if file.isClosed() {
+		return -1, &PathError{"tell", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsTell(fs.cptr, file.cptr)
-	if err != nil && ret == C.tOffset(-1) {
-		return -1, err
+	if ret == C.tOffset(-1) {
+		return -1, fs.pathError("tell", file.path, err)
 	}
 	return int64(ret), nil
 }
@@ -203,9 +280,12 @@ func (fs *Fs) Tell(file *File) (int64, error) {
 func (fs *Fs) Read(file *File, buffer []byte, length int) (uint32, error) {
 	file.RLock()
 	defer file.RUnlock()
+	if file.isClosed() {
+		return 0, &PathError{"read", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsRead(fs.cptr, file.cptr, (unsafe.Pointer(&buffer[0])), C.tSize(length))
-	if err != nil && ret == C.tSize(-1) {
-		return 0, err
+	if ret == C.tSize(-1) {
+		return 0, fs.pathError("read", file.path, err)
 	}
 	return uint32(ret), nil
 }
@@ -219,9 +299,12 @@ func (fs *Fs) Read(file *File, buffer []byte, length int) (uint32, error) {
 func (fs *Fs) Pread(file *File, position int64, buffer []byte, length int) (uint32, error) {
 	file.RLock()
 	defer file.RUnlock()
+	if file.isClosed() {
+		return 0, &PathError{"read", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsPread(fs.cptr, file.cptr, C.tOffset(position), (unsafe.Pointer(&buffer[0])), C.tSize(length))
-	if err != nil && ret == C.tSize(-1) {
-		return 0, err
+	if ret == C.tSize(-1) {
+		return 0, fs.pathError("read", file.path, err)
 	}
 	return uint32(ret), nil
 }
@@ -234,9 +317,12 @@ func (fs *Fs) Pread(file *File, position int64, buffer []byte, length int) (uint
 func (fs *Fs) Write(file *File, buffer []byte, length int) (uint32, error) {
 	file.Lock()
 	defer file.Unlock()
+	if file.isClosed() {
+		return 0, &PathError{"write", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsWrite(fs.cptr, file.cptr, (unsafe.Pointer(&buffer[0])), C.tSize(length))
-	if err != nil && ret == C.tSize(-1) {
-		return 0, err
+	if ret == C.tSize(-1) {
+		return 0, fs.pathError("write", file.path, err)
 	}
 	return uint32(ret), nil
 }
@@ -247,9 +333,44 @@ func (fs *Fs) Write(file *File, buffer []byte, length int) (uint32, error) {
 func (fs *Fs) Flush(file *File) error {
 	file.Lock()
 	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"flush", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsFlush(fs.cptr, file.cptr)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("flush", file.path, err)
+	}
+	return nil
+}
+
+//Flush the data written so far to every datanode of the pipeline, making it visible to new readers.
+//file: The file handle.
+//Returns nil on success, or error.
+func (fs *Fs) Hflush(file *File) error {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"hflush", file.path, os.ErrClosed}
+	}
+	ret, err := C.hdfsFileHFlush(fs.cptr, file.cptr)
+	if ret == C.int(-1) {
+		return fs.pathError("hflush", file.path, err)
+	}
+	return nil
+}
+
+//Flush the data written so far and have the datanodes persist it to disk.
+//file: The file handle.
+//Returns nil on success, or error; ErrUnsupported if the Hadoop client has no hsync.
+func (fs *Fs) Hsync(file *File) error {
+	file.Lock()
+	defer file.Unlock()
+	if file.isClosed() {
+		return &PathError{"hsync", file.path, os.ErrClosed}
+	}
+	ret, err := C.hdfsFileHSync(fs.cptr, file.cptr)
+	if ret == C.int(-1) {
+		return fs.pathError("hsync", file.path, err)
 	}
 	return nil
 }
@@ -260,9 +381,12 @@ func (fs *Fs) Flush(file *File) error {
 func (fs *Fs) Available(file *File) (uint32, error) {
 	file.RLock()
 	defer file.RUnlock()
+	if file.isClosed() {
+		return 0, &PathError{"available", file.path, os.ErrClosed}
+	}
 	ret, err := C.hdfsAvailable(fs.cptr, file.cptr)
-	if err != nil && ret == C.int(-1) {
-		return 0, err
+	if ret == C.int(-1) {
+		return 0, fs.pathError("available", file.path, err)
 	}
 	return uint32(ret), nil
 }
@@ -278,8 +402,8 @@ func (fs *Fs) Copy(src string, dstFS *Fs, dst string) error {
 	defer C.free(unsafe.Pointer(srcstr))
 	defer C.free(unsafe.Pointer(dststr))
 	ret, err := C.hdfsCopy(fs.cptr, srcstr, dstFS.cptr, dststr)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("copy", src, err)
 	}
 	return nil
 }
@@ -295,8 +419,8 @@ func (fs *Fs) Move(src string, dstFS *Fs, dst string) error {
 	defer C.free(unsafe.Pointer(srcstr))
 	defer C.free(unsafe.Pointer(dststr))
 	ret, err := C.hdfsMove(fs.cptr, srcstr, dstFS.cptr, dststr)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("move", src, err)
 	}
 	return nil
 }
@@ -308,12 +432,505 @@ func (fs *Fs) Delete(path string) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsDelete(fs.cptr, p)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("delete", path, err)
 	}
 	return nil
 }
 
+//Delete a file or an empty directory, unlike Delete, which is recursive.
+//path: The path.
+//Returns nil on success, or error; ENOTEMPTY if path is a directory that is not empty.
+func (fs *Fs) Remove(path string) error {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	ret, err := C.hdfsFsRemove(fs.cptr, p)
+	if ret == C.int(-1) {
+		return fs.pathError("remove", path, err)
+	}
+	return nil
+}
+
+//Truncate a file, leaving the namenode to recover its last block unless newLength is on a block boundary.
+//path: The path of the file.
+//newLength: The size to cut the file to.
+//Returns true if the file is truncated, false if its last block is being recovered; or error.
+func (fs *Fs) TruncateFile(path string, newLength int64) (bool, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	ret, err := C.hdfsFsTruncate(fs.cptr, p, C.tOffset(newLength))
+	if ret == C.int(-1) {
+		return false, fs.pathError("truncate", path, err)
+	}
+	return ret == C.int(1), nil
+}
+
+//Checks if a file is closed: not being written, nor having its last block recovered.
+//path: The path of the file.
+//Returns true if the file is closed; or error, ErrUnsupported if the filesystem cannot tell.
+func (fs *Fs) IsFileClosed(path string) (bool, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	ret, err := C.hdfsFsIsFileClosed(fs.cptr, p)
+	if ret == C.int(-1) {
+		return false, fs.pathError("isfileclosed", path, err)
+	}
+	return ret == C.int(1), nil
+}
+
+//Move the blocks of files to the end of another and delete them.
+//target: The path of the file to append to.
+//srcs: The paths of the files to append, in order.
+//Returns nil on success, or error.
+func (fs *Fs) ConcatFiles(target string, srcs []string) error {
+	t := C.CString(target)
+	defer C.free(unsafe.Pointer(t))
+	s := cStrings(srcs)
+	defer freeCStrings(s, len(srcs))
+	ret, err := C.hdfsFsConcat(fs.cptr, t, s, C.int(len(srcs)))
+	if ret == C.int(-1) {
+		return fs.pathError("concat", target, err)
+	}
+	return nil
+}
+
+//Get the checksum of the content of a file, in the mode set by dfs.checksum.combine.mode.
+//path: The path of the file.
+//Returns the checksum; or error, ErrUnsupported if the filesystem has no checksums.
+func (fs *Fs) GetFileChecksum(path string) (*FileChecksum, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var algorithm, bytes *C.char
+	var length C.int
+	ret, err := C.hdfsFsGetFileChecksum(fs.cptr, p, &algorithm, &bytes, &length)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("checksum", path, err)
+	}
+	defer C.free(unsafe.Pointer(algorithm))
+	defer C.free(unsafe.Pointer(bytes))
+	return &FileChecksum{Algorithm: C.GoString(algorithm), Bytes: C.GoBytes(unsafe.Pointer(bytes), length)}, nil
+}
+
+//Set an extended attribute of a path.
+//path: The path.
+//name: The name of the attribute, prefixed with its namespace: "user.", "trusted.", "security." or "raw.".
+//value: The value of the attribute.
+//flags: XATTR_CREATE to fail if the attribute exists, XATTR_REPLACE to fail if it does not, 0 for either.
+//Returns nil on success, or error.
+func (fs *Fs) SetXAttr(path, name string, value []byte, flags int) error {
+	p, n := C.CString(path), C.CString(name)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(n))
+	var v *C.char
+	if len(value) > 0 {
+		v = (*C.char)(C.CBytes(value))
+		defer C.free(unsafe.Pointer(v))
+	}
+	ret, err := C.hdfsFsSetXAttr(fs.cptr, p, n, v, C.int(len(value)), C.int(xattrFlags(flags)))
+	if ret == C.int(-1) {
+		return fs.pathError("setxattr", path, err)
+	}
+	return nil
+}
+
+//Get an extended attribute of a path.
+//path: The path.
+//name: The name of the attribute.
+//Returns the value of the attribute; or error, ErrNoXAttr if the path has no such attribute.
+func (fs *Fs) GetXAttr(path, name string) ([]byte, error) {
+	p, n := C.CString(path), C.CString(name)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(n))
+	var value *C.char
+	var size C.int
+	ret, err := C.hdfsFsGetXAttr(fs.cptr, p, n, &value, &size)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("getxattr", path, err)
+	}
+	defer C.free(unsafe.Pointer(value))
+	return C.GoBytes(unsafe.Pointer(value), size), nil
+}
+
+//Get extended attributes of a path.
+//path: The path.
+//names: The names of the attributes, all of them if none is given.
+//Returns the values of the attributes by name; or error, ErrNoXAttr if the path lacks one of names.
+func (fs *Fs) GetXAttrs(path string, names ...string) (map[string][]byte, error) {
+	if len(names) == 0 {
+		var err error
+		if names, err = fs.ListXAttrs(path); err != nil {
+			return nil, err
+		}
+	}
+	values := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := fs.GetXAttr(path, name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+//List the names of the extended attributes of a path.
+//path: The path.
+//Returns the names of the attributes the user may read, or error.
+func (fs *Fs) ListXAttrs(path string) ([]string, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var cnames **C.char
+	var count C.int
+	ret, err := C.hdfsFsListXAttrs(fs.cptr, p, &cnames, &count)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("listxattr", path, err)
+	}
+	defer C.hdfsFsFreeXAttrNames(cnames, count)
+	names := make([]string, int(count))
+	for i, s := range unsafe.Slice(cnames, int(count)) {
+		names[i] = C.GoString(s)
+	}
+	return names, nil
+}
+
+//Remove an extended attribute of a path.
+//path: The path.
+//name: The name of the attribute.
+//Returns nil on success; or error, ErrNoXAttr if the path has no such attribute.
+func (fs *Fs) RemoveXAttr(path, name string) error {
+	p, n := C.CString(path), C.CString(name)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(n))
+	ret, err := C.hdfsFsRemoveXAttr(fs.cptr, p, n)
+	if ret == C.int(-1) {
+		return fs.pathError("removexattr", path, err)
+	}
+	return nil
+}
+
+//Get the ACL of a path.
+//path: The path.
+//Returns the ACL of the path, or error.
+func (fs *Fs) GetAclStatus(path string) (*AclStatus, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var owner, group, spec *C.char
+	var sticky, perm C.int
+	ret, err := C.hdfsFsGetAclStatus(fs.cptr, p, &owner, &group, &sticky, &perm, &spec)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("getacl", path, err)
+	}
+	defer C.free(unsafe.Pointer(owner))
+	defer C.free(unsafe.Pointer(group))
+	defer C.free(unsafe.Pointer(spec))
+	s := &AclStatus{Owner: C.GoString(owner), Group: C.GoString(group), Sticky: sticky != 0, Permission: int16(perm)}
+	if perm < 0 {
+		info, err := fs.GetPathInfo(path)
+		if err != nil {
+			return nil, err
+		}
+		s.Permission = info.Permissions
+	}
+	if entries := C.GoString(spec); entries != "" {
+		if s.Entries, err = ParseAclSpec(entries); err != nil {
+			return nil, &PathError{"getacl", path, fmt.Errorf("%w: %v", ErrInternal, err)}
+		}
+	}
+	return s, nil
+}
+
+func (fs *Fs) modifyAcl(method, path string, entries []AclEntry) error {
+	p, m, s := C.CString(path), C.CString(method), C.CString(FormatAclSpec(entries))
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(m))
+	defer C.free(unsafe.Pointer(s))
+	ret, err := C.hdfsFsModifyAcl(fs.cptr, p, m, s)
+	if ret == C.int(-1) {
+		return fs.pathError("setacl", path, err)
+	}
+	return nil
+}
+
+//Replace the entries of an ACL.
+//path: The path.
+//entries: The entries, replacing all those of their scopes; the entries of the owner, group and others of a scope must be given.
+//Returns nil on success, or error.
+func (fs *Fs) SetAcl(path string, entries []AclEntry) error {
+	return fs.modifyAcl("setAcl", path, entries)
+}
+
+//Add or replace entries of an ACL.
+//path: The path.
+//entries: The entries, replacing those of the same scope, type and name.
+//Returns nil on success, or error.
+func (fs *Fs) ModifyAclEntries(path string, entries []AclEntry) error {
+	return fs.modifyAcl("modifyAclEntries", path, entries)
+}
+
+//Remove entries of an ACL.
+//path: The path.
+//entries: The entries, matched by scope, type and name; their permissions are ignored.
+//Returns nil on success, or error.
+func (fs *Fs) RemoveAclEntries(path string, entries []AclEntry) error {
+	return fs.modifyAcl("removeAclEntries", path, entries)
+}
+
+func (fs *Fs) removeAcl(method, path string) error {
+	p, m := C.CString(path), C.CString(method)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(m))
+	ret, err := C.hdfsFsRemoveAcl(fs.cptr, p, m)
+	if ret == C.int(-1) {
+		return fs.pathError("setacl", path, err)
+	}
+	return nil
+}
+
+//Remove the default entries of an ACL.
+//path: The path.
+//Returns nil on success, or error.
+func (fs *Fs) RemoveDefaultAcl(path string) error {
+	return fs.removeAcl("removeDefaultAcl", path)
+}
+
+//Remove the entries of an ACL but those of the owner, group and others.
+//path: The path.
+//Returns nil on success, or error.
+func (fs *Fs) RemoveAcl(path string) error {
+	return fs.removeAcl("removeAcl", path)
+}
+
+func (fs *Fs) snapshot(op, method, path string, names ...string) error {
+	p, m := C.CString(path), C.CString(method)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(m))
+	var n [2]*C.char
+	for i, name := range names {
+		n[i] = C.CString(name)
+		defer C.free(unsafe.Pointer(n[i]))
+	}
+	ret, err := C.hdfsFsSnapshot(fs.cptr, p, m, n[0], n[1])
+	if ret == C.int(-1) {
+		return fs.pathError(op, path, err)
+	}
+	return nil
+}
+
+//Allow snapshots of a directory; only the superuser may.
+//path: The path of the directory.
+//Returns nil on success; or error, ErrUnsupported if the filesystem has no snapshots.
+func (fs *Fs) AllowSnapshot(path string) error {
+	return fs.snapshot("allowsnapshot", "allowSnapshot", path)
+}
+
+//Disallow snapshots of a directory, which must have none left; only the superuser may.
+//path: The path of the directory.
+//Returns nil on success, or error.
+func (fs *Fs) DisallowSnapshot(path string) error {
+	return fs.snapshot("disallowsnapshot", "disallowSnapshot", path)
+}
+
+//Take a snapshot of a directory.
+//path: The path of the snapshottable directory.
+//name: The name of the snapshot, or "" for the namenode to name it after the current time.
+//Returns the path of the snapshot, or error.
+func (fs *Fs) CreateSnapshot(path, name string) (string, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var n, snapshotPath *C.char
+	if name != "" {
+		n = C.CString(name)
+		defer C.free(unsafe.Pointer(n))
+	}
+	ret, err := C.hdfsFsCreateSnapshot(fs.cptr, p, n, &snapshotPath)
+	if ret == C.int(-1) {
+		return "", fs.pathError("createsnapshot", path, err)
+	}
+	defer C.free(unsafe.Pointer(snapshotPath))
+	return C.GoString(snapshotPath), nil
+}
+
+//Delete a snapshot of a directory.
+//path: The path of the snapshottable directory.
+//name: The name of the snapshot.
+//Returns nil on success, or error.
+func (fs *Fs) DeleteSnapshot(path, name string) error {
+	return fs.snapshot("deletesnapshot", "deleteSnapshot", path, name)
+}
+
+//Rename a snapshot of a directory.
+//path: The path of the snapshottable directory.
+//oldName: The name of the snapshot.
+//newName: The new name of the snapshot.
+//Returns nil on success, or error.
+func (fs *Fs) RenameSnapshot(path, oldName, newName string) error {
+	return fs.snapshot("renamesnapshot", "renameSnapshot", path, oldName, newName)
+}
+
+//List the directories snapshots are allowed on: all of them for the superuser, those the user owns for others.
+//Returns the directories, or error.
+func (fs *Fs) ListSnapshottableDirs() ([]*SnapshottableDir, error) {
+	var cdirs *C.hdfsSnapshottableDir
+	var count C.int
+	ret, err := C.hdfsFsListSnapshottableDirs(fs.cptr, &cdirs, &count)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("lssnapshottabledir", "", err)
+	}
+	defer C.hdfsFsFreeSnapshottableDirs(cdirs, count)
+	dirs := make([]*SnapshottableDir, int(count))
+	for i, d := range unsafe.Slice(cdirs, int(count)) {
+		dirs[i] = &SnapshottableDir{Path: C.GoString(d.path), Snapshots: int(d.snapshots), SnapshotQuota: int(d.quota)}
+	}
+	return dirs, nil
+}
+
+//Get the changes made to a directory between two of its snapshots.
+//path: The path of the snapshottable directory.
+//from: The name of the earlier snapshot, or "" for the current state.
+//to: The name of the later snapshot, or "" for the current state.
+//Returns the changes, or error.
+func (fs *Fs) SnapshotDiff(path, from, to string) (*SnapshotDiffReport, error) {
+	p, f, t := C.CString(path), C.CString(from), C.CString(to)
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(f))
+	defer C.free(unsafe.Pointer(t))
+	var centries *C.hdfsSnapshotDiffEntry
+	var count C.int
+	ret, err := C.hdfsFsGetSnapshotDiff(fs.cptr, p, f, t, &centries, &count)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("snapshotdiff", path, err)
+	}
+	defer C.hdfsFsFreeSnapshotDiff(centries, count)
+	r := &SnapshotDiffReport{Dir: path, From: from, To: to}
+	for _, e := range unsafe.Slice(centries, int(count)) {
+		typ, err := ParseDiffType(string(rune(e._type)))
+		if err != nil {
+			return nil, &PathError{"snapshotdiff", path, fmt.Errorf("%w: %v", ErrInternal, err)}
+		}
+		entry := SnapshotDiffEntry{Type: typ, Path: C.GoString(e.source)}
+		if entry.Path == "" {
+			entry.Path = "."
+		}
+		if typ == DiffRename && e.target != nil {
+			entry.Target = C.GoString(e.target)
+		}
+		r.Entries = append(r.Entries, entry)
+	}
+	return r, nil
+}
+
+//Get the disk usage and quotas of a tree.
+//path: The path of the file or directory the tree is rooted at.
+//Returns the summary, or error.
+func (fs *Fs) GetContentSummary(path string) (*ContentSummary, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var cs C.hdfsContentSummary
+	ret, err := C.hdfsFsGetContentSummary(fs.cptr, p, &cs)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("du", path, err)
+	}
+	s := &ContentSummary{
+		Length:         int64(cs.length),
+		FileCount:      int64(cs.fileCount),
+		DirectoryCount: int64(cs.directoryCount),
+		Quota:          int64(cs.quota),
+		SpaceConsumed:  int64(cs.spaceConsumed),
+		SpaceQuota:     int64(cs.spaceQuota),
+	}
+	for i, q := range cs.typeQuota {
+		if q == -1 || !StorageType(i).SupportsQuota() {
+			continue
+		}
+		if s.TypeQuotas == nil {
+			s.TypeQuotas = map[StorageType]TypeQuota{}
+		}
+		s.TypeQuotas[StorageType(i)] = TypeQuota{Quota: int64(q), Consumed: int64(cs.typeConsumed[i])}
+	}
+	return s, nil
+}
+
+//Set the namespace and space quotas of a directory; only the superuser may.
+//path: The path of the directory.
+//nsQuota: The most files and directories its tree may hold, QuotaReset to clear it, or QuotaDontSet.
+//ssQuota: The most bytes its tree may consume, replicas included, as nsQuota.
+//Returns nil on success; or error, ErrUnsupported if the filesystem has no quotas.
+func (fs *Fs) SetQuota(path string, nsQuota, ssQuota int64) error {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	ret, err := C.hdfsFsSetQuota(fs.cptr, p, C.tOffset(nsQuota), C.tOffset(ssQuota))
+	if ret == C.int(-1) {
+		return fs.pathError("setquota", path, err)
+	}
+	return nil
+}
+
+//Set the space quota of a directory on a storage type; only the superuser may.
+//path: The path of the directory.
+//t: The storage type, which must support quotas.
+//quota: The most bytes its tree may consume on t, as SetQuota takes it.
+//Returns nil on success; or error, ErrUnsupported if the filesystem has no quotas.
+func (fs *Fs) SetTypeQuota(path string, t StorageType, quota int64) error {
+	p, typ := C.CString(path), C.CString(t.String())
+	defer C.free(unsafe.Pointer(p))
+	defer C.free(unsafe.Pointer(typ))
+	ret, err := C.hdfsFsSetTypeQuota(fs.cptr, p, typ, C.tOffset(quota))
+	if ret == C.int(-1) {
+		return fs.pathError("setquota", path, err)
+	}
+	return nil
+}
+
+//Clear the namespace and space quotas of a directory; only the superuser may.
+//path: The path of the directory.
+//Returns nil on success, or error.
+func (fs *Fs) ClearQuota(path string) error {
+	return fs.SetQuota(path, QuotaReset, QuotaReset)
+}
+
+//Get the trash directory of the user for a path.
+//path: The path to be moved to the trash.
+//Returns .Trash/<user> at the root of the encryption zone of the parent of path, or .Trash in the home directory; or error.
+func (fs *Fs) TrashRoot(path string) (string, error) {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var root *C.char
+	ret, err := C.hdfsFsGetTrashRoot(fs.cptr, p, &root)
+	if ret == C.int(-1) {
+		return "", fs.pathError("trashroot", path, err)
+	}
+	defer C.free(unsafe.Pointer(root))
+	return C.GoString(root), nil
+}
+
+//Get the trash directories of the user that exist.
+//Returns the paths of the directories, those of encryption zones for the superuser only; or error.
+func (fs *Fs) TrashRoots() ([]string, error) {
+	var croots **C.char
+	var count C.int
+	ret, err := C.hdfsFsGetTrashRoots(fs.cptr, &croots, &count)
+	if ret == C.int(-1) {
+		return nil, fs.pathError("trashroots", "", err)
+	}
+	defer C.hdfsFsFreeTrashRoots(croots, count)
+	roots := make([]string, int(count))
+	for i, s := range unsafe.Slice(croots, int(count)) {
+		roots[i] = C.GoString(s)
+	}
+	return roots, nil
+}
+
+//Get how long files stay in the trash: fs.trash.interval of the namenode unless 0, else of the client.
+//Returns the interval, 0 if the trash is disabled; or error.
+func (fs *Fs) TrashInterval() (time.Duration, error) {
+	var interval C.tOffset
+	ret, err := C.hdfsFsGetTrashInterval(fs.cptr, &interval)
+	if ret == C.int(-1) {
+		return 0, fs.pathError("trashinterval", "", err)
+	}
+	return time.Duration(interval) * time.Millisecond, nil
+}
+
 //Rename file. 
 //oldpath: The path of the source file. 
 //newpath: The path of the destination file. 
@@ -323,8 +940,8 @@ func (fs *Fs) Rename(oldpath, newpath string) error {
 	defer C.free(unsafe.Pointer(op))
 	defer C.free(unsafe.Pointer(np))
 	ret, err := C.hdfsRename(fs.cptr, op, np)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("rename", oldpath, err)
 	}
 	return nil
 }
@@ -334,9 +951,9 @@ func (fs *Fs) Rename(oldpath, newpath string) error {
 //size: The length of user-buffer.
 //Returns buffer, or error.
 func (fs *Fs) GetWorkingDirectory(buffer []byte, size uint32) ([]byte, error) {
-	_, err := C.hdfsGetWorkingDirectory(fs.cptr, (*C.char)(unsafe.Pointer(&buffer[0])), C.size_t(size))
-	if err != nil {
-		return nil, err
+	ret, err := C.hdfsGetWorkingDirectory(fs.cptr, (*C.char)(unsafe.Pointer(&buffer[0])), C.size_t(size))
+	if ret == nil {
+		return nil, &PathError{"getwd", "", classify(err, nil)}
 	}
 	return buffer, nil
 }
@@ -348,8 +965,8 @@ func (fs *Fs) SetWorkingDirectory(path string) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsSetWorkingDirectory(fs.cptr, p)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("chdir", path, err)
 	}
 	return nil
 }
@@ -361,8 +978,8 @@ func (fs *Fs) CreateDirectory(path string) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsCreateDirectory(fs.cptr, p)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("mkdir", path, err)
 	}
 	return nil
 }
@@ -374,30 +991,33 @@ func (fs *Fs) SetReplication(path string, replication int16) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsSetReplication(fs.cptr, p, C.int16_t(replication))
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("setrep", path, err)
 	}
 	return nil
 }
 
 //Get list of files/directories for a given directory-path.
 //path: The path of the directory. 
-//Returns a slice of FileInfo struct pointer, or nil on error.
+//Returns a slice of FileInfo struct pointer, empty for an empty directory, or nil on error.
 func (fs *Fs) ListDirectory(path string) ([]*FileInfo, error) {
-	var num int
+	var num C.int
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
-	info, _ := C.hdfsListDirectory(fs.cptr, p, (*C.int)(unsafe.Pointer(&num)))
+	info, err := C.hdfsListDirectory(fs.cptr, p, &num)
 	if info == nil {
-		return nil, fmt.Errorf("error in listing directory %s", path)
+		// an empty directory is reported as NULL with errno cleared
+		if err == nil && num == 0 {
+			return []*FileInfo{}, nil
+		}
+		return nil, fs.pathError("readdir", path, err)
 	}
-	defer C.hdfsFreeFileInfo(info, C.int(num))
-	ret := make([]*FileInfo, num)
+	defer C.hdfsFreeFileInfo(info, num)
+	ret := make([]*FileInfo, int(num))
 	var cinfo *C.hdfsFileInfo
-	for i := 0; i < num; i++ {
+	for i := range ret {
 		cinfo = (*C.hdfsFileInfo)(unsafe.Pointer(uintptr(unsafe.Pointer(info)) + uintptr(i)*unsafe.Sizeof(C.hdfsFileInfo{})))
 		ret[i] = new(FileInfo)
-		ret[i].meta = hdfsFileInfo{*cinfo}
 		ret[i].Kind = byte(cinfo.mKind)
 		ret[i].Name = C.GoString(cinfo.mName)
 		ret[i].LastMod = time.Unix(int64(cinfo.mLastMod), int64(0))
@@ -420,11 +1040,10 @@ func (fs *Fs) GetPathInfo(path string) (*FileInfo, error) {
 	defer C.free(unsafe.Pointer(p))
 	info, err := C.hdfsGetPathInfo(fs.cptr, p)
 	if info == nil {
-		return nil, err
+		return nil, fs.pathError("stat", path, err)
 	}
 	defer C.hdfsFreeFileInfo(info, C.int(1))
 	ret := new(FileInfo)
-	ret.meta = hdfsFileInfo{*info}
 	ret.Kind = byte(info.mKind)
 	ret.Name = C.GoString(info.mName)
 	ret.LastMod = time.Unix(int64(info.mLastMod), int64(0))
@@ -448,7 +1067,7 @@ func (fs *Fs) GetHosts(path string, start, length int64) ([][]string, error) {
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsGetHosts(fs.cptr, p, C.tOffset(start), C.tOffset(length))
 	if ret == (***C.char)(unsafe.Pointer(uintptr(0))) {
-		return nil, err
+		return nil, fs.pathError("gethosts", path, err)
 	}
 	defer C.hdfsFreeHosts(ret)
 	i := int(C.getlen(ret))
@@ -467,8 +1086,8 @@ func (fs *Fs) GetHosts(path string, start, length int64) ([][]string, error) {
 //Returns the blocksize; -1 on error. 
 func (fs *Fs) GetDefaultBlockSize() (int64, error) {
 	ret, err := C.hdfsGetDefaultBlockSize(fs.cptr)
-	if err != nil && ret == C.tOffset(-1) {
-		return -1, err
+	if ret == C.tOffset(-1) {
+		return -1, fs.pathError("blocksize", "", err)
 	}
 	return int64(ret), nil
 }
@@ -477,8 +1096,8 @@ func (fs *Fs) GetDefaultBlockSize() (int64, error) {
 //Returns the raw-capacity; -1 on error. 
 func (fs *Fs) GetCapacity() (int64, error) {
 	ret, err := C.hdfsGetCapacity(fs.cptr)
-	if err != nil && ret == C.tOffset(-1) {
-		return -1, err
+	if ret == C.tOffset(-1) {
+		return -1, fs.pathError("capacity", "", err)
 	}
 	return int64(ret), nil
 }
@@ -487,8 +1106,18 @@ func (fs *Fs) GetCapacity() (int64, error) {
 //Returns the total-size; check on error. 
 func (fs *Fs) GetUsed() (int64, error) {
 	ret, err := C.hdfsGetUsed(fs.cptr)
-	if err != nil && ret == C.tOffset(-1) {
-		return -1, err
+	if ret == C.tOffset(-1) {
+		return -1, fs.pathError("used", "", err)
+	}
+	return int64(ret), nil
+}
+
+//Get the raw space the datanodes have left, which does not count the space used outside HDFS.
+//Returns the remaining space; or error.
+func (fs *Fs) GetRemaining() (int64, error) {
+	ret, err := C.hdfsFsGetRemaining(fs.cptr)
+	if ret == C.tOffset(-1) {
+		return -1, fs.pathError("remaining", "", err)
 	}
 	return int64(ret), nil
 }
@@ -504,8 +1133,8 @@ func (fs *Fs) Chown(path, owner, group string) error {
 	defer C.free(unsafe.Pointer(o))
 	defer C.free(unsafe.Pointer(g))
 	ret, err := C.hdfsChown(fs.cptr, p, o, g)
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("chown", path, err)
 	}
 	return nil
 }
@@ -518,8 +1147,8 @@ func (fs *Fs) Chmod(path string, mode int16) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsChmod(fs.cptr, p, C.short(mode))
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("chmod", path, err)
 	}
 	return nil
 }
@@ -533,8 +1162,8 @@ func (fs *Fs) Utime(path string, mtime, atime time.Time) error {
 	p := C.CString(path)
 	defer C.free(unsafe.Pointer(p))
 	ret, err := C.hdfsUtime(fs.cptr, p, C.tTime(mtime.Unix()), C.tTime(atime.Unix()))
-	if err != nil && ret == C.int(-1) {
-		return err
+	if ret == C.int(-1) {
+		return fs.pathError("utime", path, err)
 	}
 	return nil
 }
@@ -0,0 +1,870 @@
+//go:build !cgo || purego
+
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs/conf"
+	"github.com/zyxar/hdfs/internal/exception"
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+	"github.com/zyxar/hdfs/internal/rpc"
+	"github.com/zyxar/hdfs/internal/transfer"
+)
+
+// This file is the pure-Go backend, built with the purego tag or without
+// cgo: Fs speaks Hadoop IPC with ClientNamenodeProtocol to the namenode
+// directly, so no JDK, libhdfs or libjvm is needed. File data is read and
+// written with the data transfer protocol of the datanodes; see
+// file_purego.go.
+
+const (
+	defaultPort        = 8020
+	defaultDialTimeout = 20 * time.Second
+	defaultUmask       = 022
+)
+
+type hdfsFS struct {
+	client     *rpc.Client
+	uri        string // hdfs://authority, prefixing the names in FileInfo
+	user       string
+	defaults   hdfsproto.ServerDefaults
+	clientName string // holder of the leases of the files written
+	timeout    time.Duration
+	checksum   transfer.Checksum
+	packetSize int
+	combine    string        // mode of GetFileChecksum
+	trash      time.Duration // fs.trash.interval of the client
+
+	mu        sync.Mutex
+	cwd       string
+	writers   int           // files open for writing
+	stopRenew chan struct{} // stops the lease renewer, while writers > 0
+}
+
+type hdfsFile struct {
+	*sync.RWMutex
+	fs     *Fs
+	path   string
+	flags  int
+	closed bool
+	abs    string
+	r      *reader // state of a file open for reading
+	w      *writer // state of a file open for writing
+}
+
+type File hdfsFile
+type Fs hdfsFS
+
+var _ FileSystem = (*Fs)(nil)
+
+// isClosed reports whether the file was closed; file.go uses it.
+func (f *File) isClosed() bool {
+	return f.closed
+}
+
+// release marks the file closed.
+func (f *File) release() {
+	f.closed = true
+}
+
+// ConnectAsUser connects to the namenode at host and port as user, "" being
+// the current user or $HADOOP_USER_NAME. Host "default" with port 0
+// connects to fs.defaultFS of the configuration in $HADOOP_CONF_DIR. The
+// local file system, host "", needs the cgo backend.
+func ConnectAsUser(host string, port uint16, user string) (*Fs, error) {
+	switch {
+	case host == "":
+		return nil, &PathError{"connect", "file:///", ErrUnsupported}
+	case host == "default" && port == 0:
+		return ConnectConfig(&Config{User: user})
+	}
+	return ConnectConfig(&Config{Namenode: net.JoinHostPort(host, strconv.Itoa(int(port))), User: user})
+}
+
+// Connect connects to the namenode at host and port as the current user.
+func Connect(host string, port uint16) (*Fs, error) {
+	return ConnectAsUser(host, port, "")
+}
+
+// ConnectConfig connects to the file system described by cfg. Of the
+// Hadoop configuration in ConfDir (or $HADOOP_CONF_DIR) and Settings, the
+// backend uses fs.defaultFS, the HA namenodes of nameservices,
+// ipc.client.connect.timeout, fs.trash.interval unless the namenode sets
+// it, and for file data dfs.client.socket-timeout,
+// dfs.checksum.type, dfs.bytes-per-checksum, dfs.client-write-packet-size
+// and dfs.checksum.combine.mode. Namenodes of an HA nameservice are tried in
+// order until one that is not in standby answers.
+func ConnectConfig(cfg *Config) (*Fs, error) {
+	uri, err := cfg.uri()
+	if err != nil {
+		return nil, err
+	}
+	c, err := loadConf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if uri == "" {
+		uri = c.DefaultFS()
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "hdfs" {
+		return nil, &PathError{"connect", uri, ErrUnsupported}
+	}
+	namenodes, err := c.Resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := c.GetDuration("ipc.client.connect.timeout", defaultDialTimeout, time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	trash, err := c.GetDuration("fs.trash.interval", 0, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.User
+	if name == "" {
+		name = currentUser()
+	}
+	fs := &Fs{uri: "hdfs://" + u.Host, user: name, cwd: "/user/" + name, trash: trash}
+	if err := fs.configure(c); err != nil {
+		return nil, err
+	}
+	for _, nn := range namenodes {
+		fs.client, err = rpc.Dial(nn.RPCAddress, name, hdfsproto.ClientProtocol, timeout)
+		if err != nil {
+			continue
+		}
+		var resp hdfsproto.GetServerDefaultsResponse
+		if err = fs.client.Call("getServerDefaults", &hdfsproto.Empty{}, &resp); err == nil {
+			fs.defaults = resp.ServerDefaults
+			return fs, nil
+		}
+		fs.client.Close()
+	}
+	if err == nil {
+		err = errors.New("no namenode configured")
+	}
+	return nil, &PathError{"connect", uri, remoteErrno(err)}
+}
+
+func loadConf(cfg *Config) (*conf.Conf, error) {
+	dir := cfg.ConfDir
+	if dir == "" {
+		dir = conf.DefaultDir()
+	}
+	c, err := conf.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	keys, values := cfg.settings()
+	for i, key := range keys {
+		c.Set(key, values[i])
+	}
+	return c, nil
+}
+
+func currentUser() string {
+	if name := os.Getenv("HADOOP_USER_NAME"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Disconnect closes the connection to the namenode. Files still open for
+// writing are not completed; their leases expire on the namenode.
+func (fs *Fs) Disconnect() error {
+	fs.mu.Lock()
+	if fs.stopRenew != nil {
+		close(fs.stopRenew)
+		fs.stopRenew = nil
+	}
+	fs.mu.Unlock()
+	return fs.client.Close()
+}
+
+// Disconnect closes the connection of fs.
+func Disconnect(fs *Fs) error {
+	return fs.Disconnect()
+}
+
+// remoteErrno maps the exceptions libhdfs maps to an errno, and reports
+// other exceptions as ErrInternal.
+func remoteErrno(err error) error {
+	var re *rpc.RemoteError
+	if !errors.As(err, &re) {
+		return err
+	}
+	switch errno := exception.ErrnoMessage(re.Class, re.Message); errno {
+	case 0:
+		return fmt.Errorf("%w: %v", ErrInternal, re)
+	case syscall.ENOTSUP:
+		return ErrUnsupported
+	default:
+		return errno
+	}
+}
+
+// call invokes a ClientNamenodeProtocol method, wrapping failures in a
+// *PathError.
+func (fs *Fs) call(op, p, method string, req protowire.Marshaler, resp protowire.Unmarshaler) error {
+	if err := fs.client.Call(method, req, resp); err != nil {
+		return &PathError{op, p, remoteErrno(err)}
+	}
+	return nil
+}
+
+// abs resolves p, which may be a URI of the file system, relative to the
+// working directory.
+func (fs *Fs) abs(p string) string {
+	if strings.HasPrefix(p, "hdfs://") {
+		if u, err := url.Parse(p); err == nil {
+			p = u.Path
+		}
+	}
+	if !strings.HasPrefix(p, "/") {
+		fs.mu.Lock()
+		p = path.Join(fs.cwd, p)
+		fs.mu.Unlock()
+	}
+	return path.Clean(p)
+}
+
+// stat returns the status of the absolute path p, or nil if it is missing.
+func (fs *Fs) stat(op, p, abs string) (*hdfsproto.FileStatus, error) {
+	var resp hdfsproto.GetFileInfoResponse
+	if err := fs.call(op, p, "getFileInfo", &hdfsproto.SrcRequest{Src: abs}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.FS, nil
+}
+
+// boolCall makes a call answering a bool result; false is reported as
+// ENOENT if p does not exist and ErrInternal otherwise, as libhdfs does.
+func (fs *Fs) boolCall(op, p, method string, req protowire.Marshaler) error {
+	var resp hdfsproto.BoolResponse
+	if err := fs.call(op, p, method, req, &resp); err != nil {
+		return err
+	}
+	if resp.Result {
+		return nil
+	}
+	if st, err := fs.stat(op, p, fs.abs(p)); err == nil && st == nil {
+		return &PathError{op, p, syscall.ENOENT}
+	}
+	return &PathError{op, p, ErrInternal}
+}
+
+func (fs *Fs) fileInfo(abs string, st *hdfsproto.FileStatus) *FileInfo {
+	info := &FileInfo{
+		Kind:        'F',
+		Name:        fs.uri + abs,
+		LastMod:     time.Unix(int64(st.ModificationTime/1000), 0),
+		Size:        int64(st.Length),
+		Replication: int16(st.BlockReplication),
+		BlockSize:   int64(st.BlockSize),
+		Owner:       st.Owner,
+		Group:       st.Group,
+		Permissions: int16(st.Permission),
+		LastAccess:  time.Unix(int64(st.AccessTime/1000), 0),
+	}
+	if st.FileType == hdfsproto.IsDir {
+		info.Kind = 'D'
+	}
+	return info
+}
+
+// Exists returns nil if path exists; otherwise the error satisfies
+// errors.Is(err, fs.ErrNotExist).
+func (fs *Fs) Exists(path string) error {
+	st, err := fs.stat("exists", path, fs.abs(path))
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return &PathError{"exists", path, syscall.ENOENT}
+	}
+	return nil
+}
+
+// GetPathInfo returns information about path.
+func (fs *Fs) GetPathInfo(path string) (*FileInfo, error) {
+	abs := fs.abs(path)
+	st, err := fs.stat("stat", path, abs)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, &PathError{"stat", path, syscall.ENOENT}
+	}
+	return fs.fileInfo(abs, st), nil
+}
+
+// ListDirectory lists the entries of a directory, fetching them in as many
+// getListing calls as the namenode requires. Listing a file returns the
+// file itself.
+func (fs *Fs) ListDirectory(path string) ([]*FileInfo, error) {
+	abs := fs.abs(path)
+	infos := []*FileInfo{}
+	req := &hdfsproto.GetListingRequest{Src: abs, StartAfter: []byte{}}
+	for {
+		var resp hdfsproto.GetListingResponse
+		if err := fs.call("readdir", path, "getListing", req, &resp); err != nil {
+			return nil, err
+		}
+		if !resp.Found {
+			return nil, &PathError{"readdir", path, syscall.ENOENT}
+		}
+		for _, st := range resp.PartialListing {
+			p := abs
+			if len(st.Path) > 0 {
+				p = pathJoin(abs, string(st.Path))
+			}
+			infos = append(infos, fs.fileInfo(p, st))
+		}
+		if resp.RemainingEntries == 0 || len(resp.PartialListing) == 0 {
+			return infos, nil
+		}
+		req.StartAfter = resp.PartialListing[len(resp.PartialListing)-1].Path
+	}
+}
+
+// pathJoin is path.Join, for methods whose path parameter shadows the
+// package.
+func pathJoin(dir, name string) string {
+	return path.Join(dir, name)
+}
+
+// pathDir is path.Dir, as pathJoin is path.Join.
+func pathDir(p string) string {
+	return path.Dir(p)
+}
+
+// CreateDirectory creates path and its missing parents, with permissions
+// 0777 less the umask 022.
+func (fs *Fs) CreateDirectory(path string) error {
+	return fs.boolCall("mkdir", path, "mkdirs", &hdfsproto.MkdirsRequest{Src: fs.abs(path), Masked: 0777 &^ defaultUmask, CreateParent: true})
+}
+
+// Rename moves oldpath to newpath, or into newpath if it is a directory.
+func (fs *Fs) Rename(oldpath, newpath string) error {
+	return fs.boolCall("rename", oldpath, "rename", &hdfsproto.RenameRequest{Src: fs.abs(oldpath), Dst: fs.abs(newpath)})
+}
+
+// Delete removes path, recursively if it is a directory.
+func (fs *Fs) Delete(path string) error {
+	return fs.boolCall("delete", path, "delete", &hdfsproto.DeleteRequest{Src: fs.abs(path), Recursive: true})
+}
+
+// Remove removes the file or empty directory path, failing with ENOTEMPTY
+// on a directory that is not empty.
+func (fs *Fs) Remove(path string) error {
+	return fs.boolCall("remove", path, "delete", &hdfsproto.DeleteRequest{Src: fs.abs(path)})
+}
+
+// SetReplication sets the replication of a file.
+func (fs *Fs) SetReplication(path string, replication int16) error {
+	return fs.boolCall("setrep", path, "setReplication", &hdfsproto.SetReplicationRequest{Src: fs.abs(path), Replication: uint32(replication)})
+}
+
+// TruncateFile cuts the file path to newLength, reporting false while the
+// namenode recovers its last block.
+func (fs *Fs) TruncateFile(path string, newLength int64) (bool, error) {
+	var resp hdfsproto.BoolResponse
+	req := &hdfsproto.TruncateRequest{Src: fs.abs(path), NewLength: uint64(newLength), ClientName: fs.clientName}
+	if err := fs.call("truncate", path, "truncate", req, &resp); err != nil {
+		return false, err
+	}
+	return resp.Result, nil
+}
+
+// IsFileClosed reports whether the file path is closed.
+func (fs *Fs) IsFileClosed(path string) (bool, error) {
+	var resp hdfsproto.BoolResponse
+	if err := fs.call("isfileclosed", path, "isFileClosed", &hdfsproto.IsFileClosedRequest{Src: fs.abs(path)}, &resp); err != nil {
+		return false, err
+	}
+	return resp.Result, nil
+}
+
+// ConcatFiles moves the blocks of srcs to the end of target and deletes
+// srcs.
+func (fs *Fs) ConcatFiles(target string, srcs []string) error {
+	req := &hdfsproto.ConcatRequest{Trg: fs.abs(target)}
+	for _, src := range srcs {
+		req.Srcs = append(req.Srcs, fs.abs(src))
+	}
+	return fs.call("concat", target, "concat", req, &hdfsproto.Empty{})
+}
+
+// SetXAttr sets the extended attribute name of path to value, with flags
+// XATTR_CREATE, XATTR_REPLACE, or 0 for either.
+func (fs *Fs) SetXAttr(path, name string, value []byte, flags int) error {
+	ns, n, err := splitXAttrName("setxattr", path, name)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	x := &hdfsproto.XAttr{Namespace: uint64(ns), Name: n, Value: value}
+	req := &hdfsproto.SetXAttrRequest{Src: fs.abs(path), XAttr: x, Flag: uint32(xattrFlags(flags))}
+	return fs.call("setxattr", path, "setXAttr", req, &hdfsproto.Empty{})
+}
+
+// GetXAttr returns the value of the extended attribute name of path.
+func (fs *Fs) GetXAttr(path, name string) ([]byte, error) {
+	ns, n, err := splitXAttrName("getxattr", path, name)
+	if err != nil {
+		return nil, err
+	}
+	values, err := fs.GetXAttrs(path, name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := values[xattrName(ns, n)]
+	if !ok {
+		return nil, &PathError{"getxattr", path, ErrNoXAttr}
+	}
+	return value, nil
+}
+
+// GetXAttrs returns the values of the extended attributes names of path,
+// all of them if none is given.
+func (fs *Fs) GetXAttrs(path string, names ...string) (map[string][]byte, error) {
+	req := &hdfsproto.XAttrsRequest{Src: fs.abs(path)}
+	for _, name := range names {
+		ns, n, err := splitXAttrName("getxattr", path, name)
+		if err != nil {
+			return nil, err
+		}
+		req.XAttrs = append(req.XAttrs, &hdfsproto.XAttr{Namespace: uint64(ns), Name: n})
+	}
+	var resp hdfsproto.XAttrsResponse
+	if err := fs.call("getxattr", path, "getXAttrs", req, &resp); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(resp.XAttrs))
+	for _, x := range resp.XAttrs {
+		if x.Value == nil {
+			x.Value = []byte{}
+		}
+		values[xattrName(int(x.Namespace), x.Name)] = x.Value
+	}
+	return values, nil
+}
+
+// ListXAttrs returns the names of the extended attributes of path.
+func (fs *Fs) ListXAttrs(path string) ([]string, error) {
+	var resp hdfsproto.XAttrsResponse
+	if err := fs.call("listxattr", path, "listXAttrs", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &resp); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(resp.XAttrs))
+	for i, x := range resp.XAttrs {
+		names[i] = xattrName(int(x.Namespace), x.Name)
+	}
+	return names, nil
+}
+
+// RemoveXAttr removes the extended attribute name of path.
+func (fs *Fs) RemoveXAttr(path, name string) error {
+	ns, n, err := splitXAttrName("removexattr", path, name)
+	if err != nil {
+		return err
+	}
+	req := &hdfsproto.XAttrsRequest{Src: fs.abs(path), XAttrs: []*hdfsproto.XAttr{{Namespace: uint64(ns), Name: n}}}
+	return fs.call("removexattr", path, "removeXAttr", req, &hdfsproto.Empty{})
+}
+
+// aclSpec converts entries to AclEntryProto, whose types and scopes are in
+// the order of AclType and AclScope.
+func aclSpec(entries []AclEntry) []*hdfsproto.AclEntry {
+	spec := make([]*hdfsproto.AclEntry, len(entries))
+	for i, e := range entries {
+		spec[i] = &hdfsproto.AclEntry{Type: uint32(e.Type), Scope: uint32(e.Scope), Permissions: uint32(e.Perm & 7), Name: e.Name}
+	}
+	return spec
+}
+
+// GetAclStatus returns the ACL of path.
+func (fs *Fs) GetAclStatus(path string) (*AclStatus, error) {
+	var resp hdfsproto.GetAclStatusResponse
+	if err := fs.call("getacl", path, "getAclStatus", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Result == nil {
+		return nil, &PathError{"getacl", path, ErrInternal}
+	}
+	st := resp.Result
+	s := &AclStatus{Owner: st.Owner, Group: st.Group, Sticky: st.Sticky, Permission: int16(st.Permission)}
+	for _, e := range st.Entries {
+		s.Entries = append(s.Entries, AclEntry{Scope: AclScope(e.Scope), Type: AclType(e.Type), Name: e.Name, Perm: int16(e.Permissions)})
+	}
+	return s, nil
+}
+
+// SetAcl replaces the entries of the scopes of entries in the ACL of path.
+func (fs *Fs) SetAcl(path string, entries []AclEntry) error {
+	return fs.call("setacl", path, "setAcl", &hdfsproto.AclRequest{Src: fs.abs(path), AclSpec: aclSpec(entries)}, &hdfsproto.Empty{})
+}
+
+// ModifyAclEntries adds entries to the ACL of path, replacing those of the
+// same scope, type and name.
+func (fs *Fs) ModifyAclEntries(path string, entries []AclEntry) error {
+	return fs.call("setacl", path, "modifyAclEntries", &hdfsproto.AclRequest{Src: fs.abs(path), AclSpec: aclSpec(entries)}, &hdfsproto.Empty{})
+}
+
+// RemoveAclEntries removes the entries of the scopes, types and names of
+// entries from the ACL of path.
+func (fs *Fs) RemoveAclEntries(path string, entries []AclEntry) error {
+	return fs.call("setacl", path, "removeAclEntries", &hdfsproto.AclRequest{Src: fs.abs(path), AclSpec: aclSpec(entries)}, &hdfsproto.Empty{})
+}
+
+// RemoveDefaultAcl removes the default entries of the ACL of path.
+func (fs *Fs) RemoveDefaultAcl(path string) error {
+	return fs.call("setacl", path, "removeDefaultAcl", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &hdfsproto.Empty{})
+}
+
+// RemoveAcl removes the entries of the ACL of path but those its
+// permission holds.
+func (fs *Fs) RemoveAcl(path string) error {
+	return fs.call("setacl", path, "removeAcl", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &hdfsproto.Empty{})
+}
+
+// AllowSnapshot lets snapshots be taken of the directory path.
+func (fs *Fs) AllowSnapshot(path string) error {
+	return fs.call("allowsnapshot", path, "allowSnapshot", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &hdfsproto.Empty{})
+}
+
+// DisallowSnapshot stops snapshots being taken of the directory path.
+func (fs *Fs) DisallowSnapshot(path string) error {
+	return fs.call("disallowsnapshot", path, "disallowSnapshot", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &hdfsproto.Empty{})
+}
+
+// CreateSnapshot takes a snapshot of the directory path and returns its
+// path.
+func (fs *Fs) CreateSnapshot(path, name string) (string, error) {
+	var resp hdfsproto.CreateSnapshotResponse
+	if err := fs.call("createsnapshot", path, "createSnapshot", &hdfsproto.SnapshotRequest{SnapshotRoot: fs.abs(path), Name: name}, &resp); err != nil {
+		return "", err
+	}
+	return resp.SnapshotPath, nil
+}
+
+// DeleteSnapshot deletes the snapshot name of the directory path.
+func (fs *Fs) DeleteSnapshot(path, name string) error {
+	return fs.call("deletesnapshot", path, "deleteSnapshot", &hdfsproto.SnapshotRequest{SnapshotRoot: fs.abs(path), Name: name}, &hdfsproto.Empty{})
+}
+
+// RenameSnapshot renames the snapshot oldName of the directory path.
+func (fs *Fs) RenameSnapshot(path, oldName, newName string) error {
+	req := &hdfsproto.SnapshotRequest{SnapshotRoot: fs.abs(path), Name: oldName, NewName: newName}
+	return fs.call("renamesnapshot", path, "renameSnapshot", req, &hdfsproto.Empty{})
+}
+
+// ListSnapshottableDirs returns the directories snapshots are allowed on.
+func (fs *Fs) ListSnapshottableDirs() ([]*SnapshottableDir, error) {
+	var resp hdfsproto.GetSnapshottableDirListingResponse
+	if err := fs.call("lssnapshottabledir", "", "getSnapshottableDirListing", &hdfsproto.Empty{}, &resp); err != nil {
+		return nil, err
+	}
+	dirs := make([]*SnapshottableDir, len(resp.Dirs))
+	for i, d := range resp.Dirs {
+		dirs[i] = &SnapshottableDir{
+			Path:          path.Join(string(d.ParentFullPath), string(d.DirStatus.Path)),
+			Snapshots:     int(d.SnapshotNumber),
+			SnapshotQuota: int(d.SnapshotQuota),
+		}
+	}
+	return dirs, nil
+}
+
+// diffEntryPath returns the path of a SnapshotDiffReportEntry relative to
+// the snapshot root, which the namenode sends as an empty path.
+func diffEntryPath(p []byte) string {
+	if len(p) == 0 {
+		return "."
+	}
+	return string(p)
+}
+
+// SnapshotDiff returns the changes made to the directory path between its
+// snapshots from and to.
+func (fs *Fs) SnapshotDiff(path, from, to string) (*SnapshotDiffReport, error) {
+	abs := fs.abs(path)
+	var resp hdfsproto.GetSnapshotDiffReportResponse
+	req := &hdfsproto.GetSnapshotDiffReportRequest{SnapshotRoot: abs, FromSnapshot: from, ToSnapshot: to}
+	if err := fs.call("snapshotdiff", path, "getSnapshotDiffReport", req, &resp); err != nil {
+		return nil, err
+	}
+	r := &SnapshotDiffReport{Dir: abs, From: from, To: to}
+	for _, e := range resp.Report.Entries {
+		t, err := ParseDiffType(e.ModificationLabel)
+		if err != nil {
+			return nil, &PathError{"snapshotdiff", path, fmt.Errorf("%w: %v", ErrInternal, err)}
+		}
+		entry := SnapshotDiffEntry{Type: t, Path: diffEntryPath(e.Fullpath)}
+		if t == DiffRename {
+			entry.Target = diffEntryPath(e.TargetPath)
+		}
+		r.Entries = append(r.Entries, entry)
+	}
+	return r, nil
+}
+
+// Chmod sets the permission bits of path.
+func (fs *Fs) Chmod(path string, mode int16) error {
+	return fs.call("chmod", path, "setPermission", &hdfsproto.SetPermissionRequest{Src: fs.abs(path), Permission: uint32(mode) & 01777}, &hdfsproto.Empty{})
+}
+
+// Chown changes the owner and group of path; an empty owner or group is
+// left unchanged.
+func (fs *Fs) Chown(path, owner, group string) error {
+	if owner == "" && group == "" {
+		return nil
+	}
+	return fs.call("chown", path, "setOwner", &hdfsproto.SetOwnerRequest{Src: fs.abs(path), Username: owner, Groupname: group}, &hdfsproto.Empty{})
+}
+
+// Utime sets the modification and access times of path, at one second
+// precision.
+func (fs *Fs) Utime(path string, mtime, atime time.Time) error {
+	return fs.call("utime", path, "setTimes", &hdfsproto.SetTimesRequest{Src: fs.abs(path), Mtime: mtime.Unix() * 1000, Atime: atime.Unix() * 1000}, &hdfsproto.Empty{})
+}
+
+// GetContentSummary returns the disk usage and quotas of the tree rooted at
+// path.
+func (fs *Fs) GetContentSummary(path string) (*ContentSummary, error) {
+	var resp hdfsproto.GetContentSummaryResponse
+	if err := fs.call("du", path, "getContentSummary", &hdfsproto.SrcRequest{Src: fs.abs(path)}, &resp); err != nil {
+		return nil, err
+	}
+	s := resp.Summary
+	cs := &ContentSummary{int64(s.Length), int64(s.FileCount), int64(s.DirectoryCount), int64(s.Quota), int64(s.SpaceConsumed), int64(s.SpaceQuota), nil}
+	for _, info := range s.TypeQuotaInfos {
+		// the namenode lists every storage type once one has a quota
+		if int64(info.Quota) == QuotaReset || info.Type == 0 {
+			continue
+		}
+		if cs.TypeQuotas == nil {
+			cs.TypeQuotas = map[StorageType]TypeQuota{}
+		}
+		cs.TypeQuotas[StorageType(info.Type-1)] = TypeQuota{Quota: int64(info.Quota), Consumed: int64(info.Consumed)}
+	}
+	return cs, nil
+}
+
+// SetQuota sets the namespace and space quotas of the directory path.
+func (fs *Fs) SetQuota(path string, nsQuota, ssQuota int64) error {
+	req := &hdfsproto.SetQuotaRequest{Path: fs.abs(path), NamespaceQuota: uint64(nsQuota), StoragespaceQuota: uint64(ssQuota)}
+	return fs.call("setquota", path, "setQuota", req, &hdfsproto.Empty{})
+}
+
+// SetTypeQuota sets the space quota of the directory path on the storage
+// type t.
+func (fs *Fs) SetTypeQuota(path string, t StorageType, quota int64) error {
+	req := &hdfsproto.SetQuotaRequest{Path: fs.abs(path), NamespaceQuota: uint64(QuotaDontSet), StoragespaceQuota: uint64(quota), StorageType: uint64(t) + 1}
+	return fs.call("setquota", path, "setQuota", req, &hdfsproto.Empty{})
+}
+
+// ClearQuota clears the namespace and space quotas of the directory path.
+func (fs *Fs) ClearQuota(path string) error {
+	return fs.SetQuota(path, QuotaReset, QuotaReset)
+}
+
+// userTrash returns the trash directory of the user in the encryption zone
+// zone, or in the home directory if zone is "".
+func (fs *Fs) userTrash(zone string) string {
+	if zone == "" {
+		return path.Join("/user", fs.user, ".Trash")
+	}
+	return path.Join(zone, ".Trash", fs.user)
+}
+
+// TrashRoot returns the trash directory of the user for path: .Trash/<user>
+// at the root of the encryption zone of the parent of path, or .Trash in
+// the home directory, also when the zone cannot be told.
+func (fs *Fs) TrashRoot(path string) (string, error) {
+	dir := pathDir(fs.abs(path))
+	var resp hdfsproto.GetEZForPathResponse
+	if err := fs.call("trashroot", path, "getEZForPath", &hdfsproto.SrcRequest{Src: dir}, &resp); err == nil && resp.Zone != nil {
+		return fs.userTrash(resp.Zone.Path), nil
+	}
+	return fs.userTrash(""), nil
+}
+
+// TrashRoots returns the trash directories of the user that exist. As
+// listing encryption zones takes the superuser, those of zones are only
+// found by the superuser.
+func (fs *Fs) TrashRoots() ([]string, error) {
+	roots := []string{fs.userTrash("")}
+	req := &hdfsproto.ListEncryptionZonesRequest{}
+	for {
+		var resp hdfsproto.ListEncryptionZonesResponse
+		if err := fs.call("trashroots", "", "listEncryptionZones", req, &resp); err != nil {
+			break
+		}
+		for _, z := range resp.Zones {
+			roots = append(roots, fs.userTrash(z.Path))
+			req.PrevID = z.ID
+		}
+		if !resp.HasMore || len(resp.Zones) == 0 {
+			break
+		}
+	}
+	var found []string
+	for _, root := range roots {
+		info, err := fs.GetPathInfo(root)
+		switch {
+		case err == nil && info.IsDir():
+			found = append(found, root)
+		case err != nil && !errors.Is(err, syscall.ENOENT):
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// TrashInterval returns fs.trash.interval of the namenode, or of the
+// client if the namenode leaves it 0.
+func (fs *Fs) TrashInterval() (time.Duration, error) {
+	if fs.defaults.TrashInterval != 0 {
+		return time.Duration(fs.defaults.TrashInterval) * time.Minute, nil
+	}
+	return fs.trash, nil
+}
+
+// GetHosts returns, for every block of path overlapping the given range, the
+// host names of the datanodes holding its replicas.
+func (fs *Fs) GetHosts(path string, start, length int64) ([][]string, error) {
+	if start < 0 || length < 0 {
+		return nil, &PathError{"gethosts", path, ErrInternal}
+	}
+	var resp hdfsproto.GetBlockLocationsResponse
+	if err := fs.call("gethosts", path, "getBlockLocations", &hdfsproto.GetBlockLocationsRequest{Src: fs.abs(path), Offset: uint64(start), Length: uint64(length)}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Locations == nil {
+		return nil, &PathError{"gethosts", path, syscall.ENOENT}
+	}
+	hosts := [][]string{}
+	for _, b := range resp.Locations.Blocks {
+		names := make([]string, len(b.Locs))
+		for i, loc := range b.Locs {
+			names[i] = loc.HostName
+		}
+		hosts = append(hosts, names)
+	}
+	return hosts, nil
+}
+
+// GetWorkingDirectory copies the URI of the working directory, followed by
+// a NUL byte, into buffer.
+func (fs *Fs) GetWorkingDirectory(buffer []byte, size uint32) ([]byte, error) {
+	fs.mu.Lock()
+	wd := fs.uri + fs.cwd
+	fs.mu.Unlock()
+	if len(wd)+1 > int(size) || len(wd)+1 > len(buffer) {
+		return nil, &PathError{"getwd", "", syscall.ERANGE}
+	}
+	buffer[copy(buffer, wd)] = 0
+	return buffer, nil
+}
+
+// SetWorkingDirectory sets the directory relative paths are resolved
+// against; it does not need to exist.
+func (fs *Fs) SetWorkingDirectory(path string) error {
+	abs := fs.abs(path)
+	fs.mu.Lock()
+	fs.cwd = abs
+	fs.mu.Unlock()
+	return nil
+}
+
+// GetDefaultBlockSize returns the block size the namenode applies to files
+// created without one.
+func (fs *Fs) GetDefaultBlockSize() (int64, error) {
+	return int64(fs.defaults.BlockSize), nil
+}
+
+func (fs *Fs) fsStats() (*hdfsproto.GetFsStatsResponse, error) {
+	var resp hdfsproto.GetFsStatsResponse
+	if err := fs.call("statfs", "", "getFsStats", &hdfsproto.Empty{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetCapacity returns the raw capacity of the file system.
+func (fs *Fs) GetCapacity() (int64, error) {
+	stats, err := fs.fsStats()
+	if err != nil {
+		return -1, err
+	}
+	return int64(stats.Capacity), nil
+}
+
+// GetUsed returns the raw size of all files, replicas included.
+func (fs *Fs) GetUsed() (int64, error) {
+	stats, err := fs.fsStats()
+	if err != nil {
+		return -1, err
+	}
+	return int64(stats.Used), nil
+}
+
+// GetRemaining returns the raw space the datanodes have left, which does
+// not count the space used outside HDFS.
+func (fs *Fs) GetRemaining() (int64, error) {
+	stats, err := fs.fsStats()
+	if err != nil {
+		return -1, err
+	}
+	return int64(stats.Remaining), nil
+}
+
+// Copy copies the file or directory tree src to dst on dstFS; see CopyTree.
+func (fs *Fs) Copy(src string, dstFS *Fs, dst string) error {
+	return CopyTree(fs, src, dstFS, dst, nil)
+}
+
+// Move moves src to dst on dstFS: a rename if both are the same file system,
+// otherwise a copy followed by a delete.
+func (fs *Fs) Move(src string, dstFS *Fs, dst string) error {
+	if fs == dstFS {
+		return fs.Rename(src, dst)
+	}
+	if err := fs.Copy(src, dstFS, dst); err != nil {
+		return err
+	}
+	return fs.Delete(src)
+}
+
+// Open is OpenFile returning the file as a FileHandle.
+func (fs *Fs) Open(path string, flags int, buffersize int, replication int, blocksize uint32) (FileHandle, error) {
+	file, err := fs.OpenFile(path, flags, buffersize, replication, blocksize)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
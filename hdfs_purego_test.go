@@ -0,0 +1,982 @@
+//go:build !cgo || purego
+
+package hdfs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func connectFake(t *testing.T, m *hdfstest.MemFS, user string) (*hdfstest.Namenode, *hdfs.Fs) {
+	t.Setenv("HADOOP_CONF_DIR", t.TempDir())
+	nn, err := hdfstest.NewNamenode(m)
+	if err != nil {
+		t.Fatalf("Error on starting the namenode: %v\n", err)
+	}
+	t.Cleanup(func() { nn.Close() })
+	host, port, _ := net.SplitHostPort(nn.Addr())
+	var p uint16
+	fmt.Sscan(port, &p)
+	c, err := hdfs.ConnectAsUser(host, p, user)
+	if err != nil {
+		t.Fatalf("Error on connecting to the namenode: %v\n", err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return nn, c
+}
+
+// local strips the URI of the file system off a FileInfo name.
+func local(name string) string {
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+		return name[strings.IndexByte(name, '/'):]
+	}
+	return name
+}
+
+func TestRPCMetadata(t *testing.T) {
+	m := newTree(t, "/d/a", "/d/b", "/d/c", "/d/e/f", "/d/g")
+	nn, c := connectFake(t, m, hdfstest.Superuser)
+	nn.SetListingLimit(2)
+
+	want, _ := m.GetPathInfo("/d/a")
+	info, err := c.GetPathInfo("/d/a")
+	if err != nil {
+		t.Fatalf("Error on getting path info: %v\n", err)
+	}
+	if !strings.HasPrefix(info.Name, "hdfs://"+nn.Addr()) || local(info.Name) != "/d/a" {
+		t.Errorf("GetPathInfo: got name %q\n", info.Name)
+	}
+	info.Name, want.Name = "", ""
+	if *info != *want {
+		t.Errorf("GetPathInfo: got %+v, want %+v\n", info, want)
+	}
+
+	infos, err := c.ListDirectory("/d")
+	var names []string
+	for _, info := range infos {
+		names = append(names, local(info.Name))
+	}
+	if err != nil || !reflect.DeepEqual(names, []string{"/d/a", "/d/b", "/d/c", "/d/e", "/d/g"}) {
+		t.Errorf("ListDirectory: got %v, %v\n", names, err)
+	}
+	if infos, err := c.ListDirectory("/d/a"); err != nil || len(infos) != 1 || local(infos[0].Name) != "/d/a" {
+		t.Errorf("ListDirectory of a file: got %v, %v\n", infos, err)
+	}
+	if err := c.CreateDirectory("/empty"); err != nil {
+		t.Errorf("Error on creating a directory: %v\n", err)
+	}
+	if infos, err := c.ListDirectory("/empty"); err != nil || len(infos) != 0 {
+		t.Errorf("ListDirectory of an empty directory: got %v, %v\n", infos, err)
+	}
+	if _, err := c.ListDirectory("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ListDirectory of a missing path: got %v\n", err)
+	}
+	if err := c.Exists("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Exists of a missing path: got %v\n", err)
+	}
+
+	if err := c.Rename("/d/a", "/d/z"); err != nil {
+		t.Errorf("Error on renaming: %v\n", err)
+	}
+	if err := c.Rename("/d/missing", "/d/y"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename of a missing path: got %v\n", err)
+	}
+	if err := c.Rename("/d/b", "/d/c"); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Rename over a file: got %v\n", err)
+	}
+	if err := c.Remove("/d/e"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Remove of a directory that is not empty: got %v, want ENOTEMPTY\n", err)
+	}
+	if err := c.Delete("/d/e"); err != nil {
+		t.Errorf("Error on deleting: %v\n", err)
+	}
+	if err := m.Exists("/d/e/f"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete left /d/e/f: %v\n", err)
+	}
+	if err := c.Delete("/d/e"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Delete of a missing path: got %v\n", err)
+	}
+	if err := c.Remove("/d/e"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a missing path: got %v\n", err)
+	}
+
+	if err := c.Chmod("/d/b", 0600); err != nil {
+		t.Errorf("Error on chmod: %v\n", err)
+	}
+	if err := c.Chown("/d/b", "alice", "users"); err != nil {
+		t.Errorf("Error on chown: %v\n", err)
+	}
+	if err := c.SetReplication("/d/b", 2); err != nil {
+		t.Errorf("Error on setrep: %v\n", err)
+	}
+	if err := c.SetReplication("/d", 2); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("SetReplication of a directory: got %v\n", err)
+	}
+	mtime := time.Unix(1700000000, 0)
+	if err := c.Utime("/d/b", mtime, mtime); err != nil {
+		t.Errorf("Error on utime: %v\n", err)
+	}
+	info, _ = m.GetPathInfo("/d/b")
+	if info.Permissions != 0600 || info.Owner != "alice" || info.Group != "users" || info.Replication != 2 || !info.LastMod.Equal(mtime) {
+		t.Errorf("metadata of /d/b: got %+v\n", info)
+	}
+
+	s, err := c.GetContentSummary("/d")
+	if err != nil || !reflect.DeepEqual(s, &hdfs.ContentSummary{Length: 0, FileCount: 4, DirectoryCount: 1, Quota: -1, SpaceConsumed: 0, SpaceQuota: -1}) {
+		t.Errorf("GetContentSummary: got %+v, %v\n", s, err)
+	}
+	if n, err := c.GetDefaultBlockSize(); n != 64<<20 || err != nil {
+		t.Errorf("GetDefaultBlockSize: got %d, %v\n", n, err)
+	}
+	if n, err := c.GetCapacity(); n != 1<<40 || err != nil {
+		t.Errorf("GetCapacity: got %d, %v\n", n, err)
+	}
+	if n, err := c.GetUsed(); n != 0 || err != nil {
+		t.Errorf("GetUsed: got %d, %v\n", n, err)
+	}
+	m.SetNonDFSUsed(1 << 30)
+	if n, err := c.GetRemaining(); n != 1<<40-1<<30 || err != nil {
+		t.Errorf("GetRemaining: got %d, %v\n", n, err)
+	}
+	if _, err := c.OpenFile("/d/b", syscall.O_RDWR, 0, 0, 0); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("OpenFile O_RDWR: got %v\n", err)
+	}
+	if _, err := c.OpenFile("/d/new", hdfs.O_WRONLY, 0, 0, 0); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("OpenFile for writing without datanodes: got %v\n", err)
+	}
+}
+
+func TestRPCUser(t *testing.T) {
+	m := newTree(t, "/home/alice/file", "/private/file")
+	m.Chown("/home/alice", "alice", "")
+	m.Chmod("/private", 0700)
+	nn, c := connectFake(t, m, "alice")
+	nn.SetGroups("alice", "users")
+
+	buffer := make([]byte, 128)
+	if err := c.SetWorkingDirectory("/home/alice"); err != nil {
+		t.Errorf("Error on chdir: %v\n", err)
+	}
+	if _, err := c.GetWorkingDirectory(buffer, uint32(len(buffer))); err != nil || local(string(buffer[:bytes.IndexByte(buffer, 0)])) != "/home/alice" {
+		t.Errorf("GetWorkingDirectory: got %q, %v\n", buffer, err)
+	}
+	if err := c.CreateDirectory("sub"); err != nil {
+		t.Errorf("Error on creating a relative directory: %v\n", err)
+	}
+	if info, err := m.GetPathInfo("/home/alice/sub"); err != nil || info.Owner != "alice" {
+		t.Errorf("CreateDirectory as alice: got %+v, %v\n", info, err)
+	}
+	if _, err := c.ListDirectory("/private"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("ListDirectory without permission: got %v\n", err)
+	}
+	if err := c.Chmod("/private", 0777); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Chmod without permission: got %v\n", err)
+	}
+
+	var walked []string
+	err := c.Walk("/home", func(path string, info *hdfs.FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	if want := []string{"/home", "/home/alice", "/home/alice/file", "/home/alice/sub"}; err != nil || !reflect.DeepEqual(walked, want) {
+		t.Errorf("Walk: got %v, %v, want %v\n", walked, err, want)
+	}
+	matches, err := c.Glob("/{home,tmp}/*")
+	if err != nil || len(matches) != 1 || local(matches[0].Name) != "/home/alice" {
+		t.Errorf("Glob: got %v, %v\n", matches, err)
+	}
+}
+
+func TestConnectHA(t *testing.T) {
+	m := newTree(t, "/ha")
+	nn, err := hdfstest.NewNamenode(m)
+	if err != nil {
+		t.Fatalf("Error on starting the namenode: %v\n", err)
+	}
+	defer nn.Close()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := l.Addr().String()
+	l.Close()
+
+	dir := t.TempDir()
+	site := `<configuration>
+<property><name>fs.defaultFS</name><value>hdfs://ns</value></property>
+<property><name>dfs.nameservices</name><value>ns</value></property>
+<property><name>dfs.ha.namenodes.ns</name><value>nn1,nn2</value></property>
+<property><name>dfs.namenode.rpc-address.ns.nn1</name><value>` + dead + `</value></property>
+<property><name>dfs.namenode.rpc-address.ns.nn2</name><value>` + nn.Addr() + `</value></property>
+</configuration>`
+	os.WriteFile(filepath.Join(dir, "hdfs-site.xml"), []byte(site), 0644)
+
+	c, err := hdfs.ConnectWithOptions(hdfs.WithConfDir(dir), hdfs.WithUser("bob"))
+	if err != nil {
+		t.Fatalf("Error on connecting to the nameservice: %v\n", err)
+	}
+	defer c.Disconnect()
+	info, err := c.GetPathInfo("/ha")
+	if err != nil || info.Name != "hdfs://ns/ha" {
+		t.Errorf("GetPathInfo through the nameservice: got %v, %v\n", info, err)
+	}
+
+	if _, err := hdfs.ConnectWithOptions(hdfs.WithNamenode(dead), hdfs.WithConfDir(dir), hdfs.WithSetting("ipc.client.connect.timeout", "100")); err == nil {
+		t.Errorf("ConnectWithOptions to a dead namenode: no error\n")
+	}
+	if _, err := hdfs.Connect("", 0); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Connect to the local file system: got %v\n", err)
+	}
+}
+
+// connectCluster starts a namenode for m with n datanodes and connects to
+// it as the superuser with opts.
+func connectCluster(t *testing.T, m *hdfstest.MemFS, n int, opts ...hdfs.Option) ([]*hdfstest.Datanode, *hdfs.Fs) {
+	nn, err := hdfstest.NewNamenode(m)
+	if err != nil {
+		t.Fatalf("Error on starting the namenode: %v\n", err)
+	}
+	t.Cleanup(func() { nn.Close() })
+	dns := make([]*hdfstest.Datanode, n)
+	for i := range dns {
+		if dns[i], err = nn.AddDatanode(); err != nil {
+			t.Fatalf("Error on starting a datanode: %v\n", err)
+		}
+	}
+	opts = append([]hdfs.Option{hdfs.WithNamenode(nn.Addr()), hdfs.WithConfDir(t.TempDir()), hdfs.WithUser(hdfstest.Superuser)}, opts...)
+	c, err := hdfs.ConnectWithOptions(opts...)
+	if err != nil {
+		t.Fatalf("Error on connecting to the namenode: %v\n", err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return dns, c
+}
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+// writeFile writes data to path in pieces of 1000 bytes.
+func writeFile(t *testing.T, c *hdfs.Fs, path string, flags int, data []byte) {
+	file, err := c.OpenFile(path, flags, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for writing: %v\n", path, err)
+	}
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := file.Write(data[:n]); err != nil {
+			t.Fatalf("Error on writing %s: %v\n", path, err)
+		}
+		data = data[n:]
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Error on closing %s: %v\n", path, err)
+	}
+}
+
+func readFile(t *testing.T, c hdfs.FileSystem, path string) []byte {
+	file, err := c.Open(path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for reading: %v\n", path, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", path, err)
+	}
+	return data
+}
+
+func TestRPCReadWrite(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	_, c := connectCluster(t, m, 3)
+
+	data := randomData(10000)
+	file, err := c.OpenFile("/rw/file", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating a file: %v\n", err)
+	}
+	if _, err := file.Write(data[:700]); err != nil {
+		t.Errorf("Error on writing: %v\n", err)
+	}
+	if err := file.Flush(); err != nil {
+		t.Errorf("Error on flushing: %v\n", err)
+	}
+	if _, err := file.Write(data[700:]); err != nil {
+		t.Errorf("Error on writing: %v\n", err)
+	}
+	if _, err := file.Read(make([]byte, 1)); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Read of a writer: got %v\n", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Seek of a writer: got %v\n", err)
+	}
+	if pos, err := file.Seek(0, io.SeekCurrent); pos != 10000 || err != nil {
+		t.Errorf("Tell of a writer: got %d, %v\n", pos, err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Error on closing: %v\n", err)
+	}
+	if got := readFile(t, m, "/rw/file"); !bytes.Equal(got, data) {
+		t.Errorf("content written: got %d bytes, want %d\n", len(got), len(data))
+	}
+	if hosts, err := c.GetHosts("/rw/file", 0, 10000); err != nil || len(hosts) != 3 || len(hosts[0]) != 2 {
+		t.Errorf("GetHosts: got %v, %v\n", hosts, err)
+	}
+
+	if got := readFile(t, c, "/rw/file"); !bytes.Equal(got, data) {
+		t.Errorf("content read: got %d bytes, want %d\n", len(got), len(data))
+	}
+	file, err = c.OpenFile("/rw/file", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening for reading: %v\n", err)
+	}
+	defer file.Close()
+	buf := make([]byte, 20)
+	if n, err := file.ReadAt(buf, 4090); n != 20 || err != nil || !bytes.Equal(buf, data[4090:4110]) {
+		t.Errorf("ReadAt across blocks: got %d, %v\n", n, err)
+	}
+	if n, err := file.ReadAt(buf, 9990); n != 10 || err != io.EOF || !bytes.Equal(buf[:10], data[9990:]) {
+		t.Errorf("ReadAt at the end: got %d, %v\n", n, err)
+	}
+	if _, err := file.Seek(5000, io.SeekStart); err != nil {
+		t.Errorf("Error on seeking: %v\n", err)
+	}
+	if n, err := c.Available(file); n != 5000 || err != nil {
+		t.Errorf("Available: got %d, %v\n", n, err)
+	}
+	if n, err := io.ReadFull(file, buf); n != 20 || err != nil || !bytes.Equal(buf, data[5000:5020]) {
+		t.Errorf("Read after Seek: got %d, %v\n", n, err)
+	}
+	if pos, err := c.Tell(file); pos != 5020 || err != nil {
+		t.Errorf("Tell: got %d, %v\n", pos, err)
+	}
+	if err := file.Flush(); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Flush of a reader: got %v\n", err)
+	}
+	if _, err := file.Write(buf); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Write of a reader: got %v\n", err)
+	}
+	r, err := c.OpenFile("/rw/file", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening for reading: %v\n", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if n, err := c.ReadContext(ctx, r, buf, -1); n != 0 || err != nil {
+		t.Errorf("ReadContext of a negative length: got %d, %v\n", n, err)
+	}
+	if n, err := c.PreadContext(ctx, r, 0, buf, 0); n != 0 || err != nil {
+		t.Errorf("PreadContext of no bytes: got %d, %v\n", n, err)
+	}
+	done := make(chan error)
+	go func() {
+		var err error
+		for err == nil {
+			_, err = r.Read(make([]byte, 100))
+		}
+		done <- err
+	}()
+	r.Close()
+	if err := <-done; !errors.Is(err, os.ErrClosed) && err != io.EOF {
+		t.Errorf("Read racing with Close: got %v\n", err)
+	}
+	if _, err := c.Read(r, buf, len(buf)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read of a closed reader: got %v\n", err)
+	}
+	if err := c.CloseFile(r); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("CloseFile of a closed reader: got %v\n", err)
+	}
+
+	more := randomData(3000)
+	writeFile(t, c, "/rw/file", hdfs.O_WRONLY|hdfs.O_APPEND, more)
+	if got := readFile(t, c, "/rw/file"); !bytes.Equal(got, append(data, more...)) {
+		t.Errorf("content appended: got %d bytes, want %d\n", len(got), len(data)+len(more))
+	}
+	w, err := c.OpenFile("/rw/file", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening for appending: %v\n", err)
+	}
+	if pos, err := c.Tell(w); pos != int64(len(data)+len(more)) || err != nil {
+		t.Errorf("Tell of a file opened for appending: got %d, %v\n", pos, err)
+	}
+	if err := c.CloseFile(w); err != nil {
+		t.Errorf("Error on CloseFile: %v\n", err)
+	}
+	if err := c.CloseFile(w); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("CloseFile of a closed writer: got %v\n", err)
+	}
+	if _, err := c.Write(w, buf, len(buf)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write to a closed writer: got %v\n", err)
+	}
+	var perr *hdfs.PathError
+	if err := w.Close(); !errors.As(err, &perr) || perr.Op != "close" || !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Close of a closed writer: got %v\n", err)
+	}
+
+	local := randomData(5000)
+	f, _ := m.Open("/rw/local", hdfs.O_WRONLY, 0, 0, 0)
+	f.Write(local)
+	f.Close()
+	if got := readFile(t, c, "/rw/local"); !bytes.Equal(got, local) {
+		t.Errorf("content of a MemFS file: got %d bytes, want %d\n", len(got), len(local))
+	}
+	if _, err := c.OpenFile("/rw/missing", hdfs.O_RDONLY, 0, 0, 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenFile of a missing file: got %v\n", err)
+	}
+}
+
+func TestRPCHsync(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	dns, c := connectCluster(t, m, 2)
+	sum := func() (n int64) {
+		for _, dn := range dns {
+			n += dn.Synced()
+		}
+		return n
+	}
+
+	data := randomData(5000)
+	file, err := c.OpenFile("/sync", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating a file: %v\n", err)
+	}
+	file.Write(data[:700])
+	if err := file.Hflush(); err != nil {
+		t.Errorf("Error on Hflush: %v\n", err)
+	}
+	if n := sum(); n != 0 {
+		t.Errorf("Synced after Hflush: got %d\n", n)
+	}
+	if err := file.Hsync(); err != nil {
+		t.Errorf("Error on Hsync: %v\n", err)
+	}
+	if n := sum(); n != 2*700 {
+		t.Errorf("Synced after Hsync: got %d, want %d\n", n, 2*700)
+	}
+	file.Write(data[700:])
+	if err := file.Hsync(); err != nil {
+		t.Errorf("Error on Hsync in the second block: %v\n", err)
+	}
+	if err := file.Hsync(); err != nil {
+		t.Errorf("Error on Hsync without new data: %v\n", err)
+	}
+	if n := sum(); n != 2*(700+5000-4096) {
+		t.Errorf("Synced in the second block: got %d, want %d\n", n, 2*(700+5000-4096))
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Error on closing: %v\n", err)
+	}
+	if got := readFile(t, m, "/sync"); !bytes.Equal(got, data) {
+		t.Errorf("content: got %d bytes, want %d\n", len(got), len(data))
+	}
+	if err := file.Hsync(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Hsync of a closed file: got %v\n", err)
+	}
+}
+
+func TestRPCReadUnderConstruction(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	_, c := connectCluster(t, m, 2)
+
+	data := randomData(10000)
+	w, err := c.OpenFile("/uc", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating a file: %v\n", err)
+	}
+	if got := readFile(t, c, "/uc"); len(got) != 0 {
+		t.Errorf("content before any write: got %d bytes\n", len(got))
+	}
+	w.Write(data[:5000])
+	if err := w.Hflush(); err != nil {
+		t.Fatalf("Error on Hflush: %v\n", err)
+	}
+	if got := readFile(t, c, "/uc"); !bytes.Equal(got, data[:5000]) {
+		t.Errorf("content after Hflush: got %d bytes, want 5000\n", len(got))
+	}
+	if info, err := c.GetPathInfo("/uc"); err != nil || info.Size != 4096 {
+		t.Errorf("Size after Hflush: got %v, %v, want the complete block\n", info, err)
+	}
+	if err := w.Hsync(); err != nil {
+		t.Fatalf("Error on Hsync: %v\n", err)
+	}
+	if info, err := c.GetPathInfo("/uc"); err != nil || info.Size != 5000 {
+		t.Errorf("Size after Hsync: got %v, %v\n", info, err)
+	}
+	r, err := c.OpenFile("/uc", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening for reading: %v\n", err)
+	}
+	defer r.Close()
+	if pos, err := r.Seek(-100, io.SeekEnd); pos != 4900 || err != nil {
+		t.Errorf("Seek from the end of a file being written: got %d, %v\n", pos, err)
+	}
+	if _, err := r.Seek(4500, io.SeekStart); err != nil {
+		t.Errorf("Error on seeking into the block being written: %v\n", err)
+	}
+	buf := make([]byte, 1000)
+	if n, err := io.ReadFull(r, buf); n != 500 || err != io.ErrUnexpectedEOF || !bytes.Equal(buf[:n], data[4500:5000]) {
+		t.Errorf("Read in the block being written: got %d, %v\n", n, err)
+	}
+
+	w.Write(data[5000:])
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error on closing: %v\n", err)
+	}
+	if got := readFile(t, c, "/uc"); !bytes.Equal(got, data) {
+		t.Errorf("content after Close: got %d bytes, want %d\n", len(got), len(data))
+	}
+}
+
+func TestRPCFollow(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	_, c := connectCluster(t, m, 2)
+
+	data := randomData(10000)
+	w, err := c.OpenFile("/log", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating a file: %v\n", err)
+	}
+	w.Write(data[:100])
+	if err := w.Hflush(); err != nil {
+		t.Fatalf("Error on Hflush: %v\n", err)
+	}
+	r, err := hdfs.Follow(c, "/log", -10, &hdfs.FollowOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error on following a file being written: %v\n", err)
+	}
+	defer r.Close()
+	if got := readFollow(t, r, 10); got != string(data[90:100]) {
+		t.Errorf("Tail of a file being written: got %q\n", got)
+	}
+
+	// data flushed past the first block, which the namenode does not count
+	w.Write(data[100:5000])
+	if err := w.Hflush(); err != nil {
+		t.Fatalf("Error on Hflush: %v\n", err)
+	}
+	if got := readFollow(t, r, 4900); got != string(data[100:5000]) {
+		t.Errorf("Data flushed into a new block: got %d bytes\n", len(got))
+	}
+	w.Write(data[5000:])
+	if err := w.Close(); err != nil {
+		t.Fatalf("Error on closing: %v\n", err)
+	}
+	if got := readFollow(t, r, 5000); got != string(data[5000:]) {
+		t.Errorf("Data of the closed file: got %d bytes\n", len(got))
+	}
+}
+
+func TestRPCTruncateConcat(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	_, c := connectCluster(t, m, 2)
+
+	data := randomData(10000)
+	writeFile(t, c, "/file", hdfs.O_WRONLY, data)
+	if done, err := c.TruncateFile("/file", 8192); err != nil || !done {
+		t.Errorf("TruncateFile on a block boundary: got %v, %v\n", done, err)
+	}
+	if err := c.Truncate("/file", 5000); err != nil {
+		t.Errorf("Error on Truncate: %v\n", err)
+	}
+	if got := readFile(t, c, "/file"); !bytes.Equal(got, data[:5000]) {
+		t.Errorf("content after Truncate: got %d bytes, want 5000\n", len(got))
+	}
+	if closed, err := c.IsFileClosed("/file"); err != nil || !closed {
+		t.Errorf("IsFileClosed after Truncate: got %v, %v\n", closed, err)
+	}
+	if err := c.Truncate("/file", 6000); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Truncate past the end: got %v\n", err)
+	}
+
+	writeFile(t, c, "/part", hdfs.O_WRONLY, data[5000:])
+	if err := c.Concat("/file", []string{"/part"}); err != nil {
+		t.Errorf("Error on Concat: %v\n", err)
+	}
+	if got := readFile(t, c, "/file"); !bytes.Equal(got, data) {
+		t.Errorf("content after Concat: got %d bytes, want %d\n", len(got), len(data))
+	}
+	if err := c.Exists("/part"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("source after Concat: got %v\n", err)
+	}
+	if err := c.ConcatFiles("/file", []string{"/file"}); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("ConcatFiles of the target: got %v\n", err)
+	}
+}
+
+func TestRPCXAttrs(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	_, c := connectCluster(t, m, 1)
+	writeFile(t, c, "/file", hdfs.O_WRONLY, []byte("data"))
+
+	if err := c.SetXAttr("/file", "user.schema", []byte("v1"), hdfs.XATTR_CREATE); err != nil {
+		t.Errorf("Error on SetXAttr: %v\n", err)
+	}
+	if err := c.SetXAttr("/file", "user.schema", []byte("v2"), hdfs.XATTR_CREATE); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("SetXAttr with XATTR_CREATE on an existing attribute: got %v\n", err)
+	}
+	if err := c.SetXAttr("/file", "user.lineage", nil, hdfs.XATTR_REPLACE); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("SetXAttr with XATTR_REPLACE on a missing attribute: got %v\n", err)
+	}
+	if err := c.SetXAttr("/file", "schema", nil, 0); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("SetXAttr without a namespace: got %v\n", err)
+	}
+	if err := c.SetXAttr("/file", "TRUSTED.empty", nil, 0); err != nil {
+		t.Errorf("Error on SetXAttr of an empty value: %v\n", err)
+	}
+	if value, err := c.GetXAttr("/file", "user.schema"); err != nil || string(value) != "v1" {
+		t.Errorf("GetXAttr: got %q, %v\n", value, err)
+	}
+	if _, err := c.GetXAttr("/file", "user.lineage"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("GetXAttr of a missing attribute: got %v\n", err)
+	}
+	values, err := c.GetXAttrs("/file")
+	if want := map[string][]byte{"user.schema": []byte("v1"), "trusted.empty": {}}; err != nil || !reflect.DeepEqual(values, want) {
+		t.Errorf("GetXAttrs: got %q, %v, want %q\n", values, err, want)
+	}
+	if names, err := c.ListXAttrs("/file"); err != nil || !reflect.DeepEqual(names, []string{"trusted.empty", "user.schema"}) {
+		t.Errorf("ListXAttrs: got %v, %v\n", names, err)
+	}
+	if err := c.RemoveXAttr("/file", "user.schema"); err != nil {
+		t.Errorf("Error on RemoveXAttr: %v\n", err)
+	}
+	if err := c.RemoveXAttr("/file", "user.schema"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("RemoveXAttr of a missing attribute: got %v\n", err)
+	}
+	if _, err := c.ListXAttrs("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ListXAttrs of a missing path: got %v\n", err)
+	}
+}
+
+func TestRPCAcls(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	_, c := connectCluster(t, m, 0)
+	c.CreateDirectory("/dir")
+
+	entries, _ := hdfs.ParseAclSpec("user:alice:rwx,group:staff:r-x,default:user:alice:r-x")
+	if err := c.SetAcl("/dir", append(entries, hdfs.AclEntry{Type: hdfs.AclUser, Perm: 7}, hdfs.AclEntry{Type: hdfs.AclGroup, Perm: 5}, hdfs.AclEntry{Type: hdfs.AclOther})); err != nil {
+		t.Errorf("Error on SetAcl: %v\n", err)
+	}
+	s, err := c.GetAclStatus("/dir")
+	if err != nil {
+		t.Fatalf("Error on GetAclStatus: %v\n", err)
+	}
+	if s.Owner != hdfstest.Superuser || s.Permission != 0770 || len(s.Entries) != 8 {
+		t.Errorf("GetAclStatus: got %s %o %v\n", s.Owner, s.Permission, s.Entries)
+	}
+	if want, _ := m.GetAclStatus("/dir"); !reflect.DeepEqual(s, want) {
+		t.Errorf("GetAclStatus: got %v, want %v\n", s, want)
+	}
+	entries, _ = hdfs.ParseAclSpec("group:staff:---")
+	if err := c.ModifyAclEntries("/dir", entries); err != nil {
+		t.Errorf("Error on ModifyAclEntries: %v\n", err)
+	}
+	entries, _ = hdfs.ParseAclSpec("user:alice")
+	if err := c.RemoveAclEntries("/dir", entries); err != nil {
+		t.Errorf("Error on RemoveAclEntries: %v\n", err)
+	}
+	if s, _ := c.GetAclStatus("/dir"); hdfs.FormatAclSpec(s.Acl()[:5]) != "user::rwx,group::r-x,group:staff:---,mask::r-x,other::---" {
+		t.Errorf("Acl after ModifyAclEntries and RemoveAclEntries: got %v\n", s.Acl())
+	}
+	if err := c.RemoveDefaultAcl("/dir"); err != nil {
+		t.Errorf("Error on RemoveDefaultAcl: %v\n", err)
+	}
+	if err := c.RemoveAcl("/dir"); err != nil {
+		t.Errorf("Error on RemoveAcl: %v\n", err)
+	}
+	if s, _ := c.GetAclStatus("/dir"); len(s.Entries) != 0 || s.Permission != 0750 {
+		t.Errorf("GetAclStatus after RemoveAcl: got %o %v\n", s.Permission, s.Entries)
+	}
+	if err := c.RemoveAclEntries("/dir", []hdfs.AclEntry{{Type: hdfs.AclOther}}); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("RemoveAclEntries of a base entry: got %v\n", err)
+	}
+	if _, err := c.GetAclStatus("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetAclStatus of a missing path: got %v\n", err)
+	}
+}
+
+func TestRPCSnapshots(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	_, c := connectCluster(t, m, 1)
+	writeFile(t, c, "/dir/file", hdfs.O_WRONLY, []byte("old"))
+
+	if _, err := c.CreateSnapshot("/dir", "s0"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("CreateSnapshot of a directory not snapshottable: got %v\n", err)
+	}
+	if err := c.AllowSnapshot("/dir"); err != nil {
+		t.Fatalf("Error on AllowSnapshot: %v\n", err)
+	}
+	p, err := c.CreateSnapshot("/dir", "s0")
+	if err != nil || p != "/dir/.snapshot/s0" {
+		t.Fatalf("CreateSnapshot: got %s, %v\n", p, err)
+	}
+	if _, err := c.CreateSnapshot("/dir", "s0"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CreateSnapshot of an existing name: got %v\n", err)
+	}
+	writeFile(t, c, "/dir/file", hdfs.O_WRONLY|hdfs.O_APPEND, []byte("new"))
+	c.Rename("/dir/file", "/dir/moved")
+	if data := readFile(t, c, "/dir/.snapshot/s0/file"); string(data) != "old" {
+		t.Errorf("Read from a snapshot: got %q\n", data)
+	}
+
+	r, err := c.SnapshotDiff("/dir", "s0", "")
+	if err != nil {
+		t.Fatalf("Error on SnapshotDiff: %v\n", err)
+	}
+	if want, _ := m.SnapshotDiff("/dir", "s0", ""); !reflect.DeepEqual(r, want) {
+		t.Errorf("SnapshotDiff: got %v, want %v\n", r, want)
+	}
+	if len(r.Entries) != 3 || r.Entries[1] != (hdfs.SnapshotDiffEntry{Type: hdfs.DiffRename, Path: "file", Target: "moved"}) {
+		t.Errorf("SnapshotDiff: got %v\n", r.Entries)
+	}
+	if _, err := c.SnapshotDiff("/dir", "s9", ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SnapshotDiff of a missing snapshot: got %v\n", err)
+	}
+
+	if err := c.RenameSnapshot("/dir", "s0", "s1"); err != nil {
+		t.Errorf("Error on RenameSnapshot: %v\n", err)
+	}
+	dirs, err := c.ListSnapshottableDirs()
+	if err != nil || len(dirs) != 1 || *dirs[0] != (hdfs.SnapshottableDir{Path: "/dir", Snapshots: 1, SnapshotQuota: 65536}) {
+		t.Errorf("ListSnapshottableDirs: got %v, %v\n", dirs, err)
+	}
+	if err := c.Delete("/dir"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Delete of a directory with snapshots: got %v\n", err)
+	}
+	if err := c.DisallowSnapshot("/dir"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("DisallowSnapshot with snapshots: got %v\n", err)
+	}
+	if err := c.DeleteSnapshot("/dir", "s1"); err != nil {
+		t.Errorf("Error on DeleteSnapshot: %v\n", err)
+	}
+	if err := c.DisallowSnapshot("/dir"); err != nil {
+		t.Errorf("Error on DisallowSnapshot: %v\n", err)
+	}
+	if dirs, err := c.ListSnapshottableDirs(); err != nil || len(dirs) != 0 {
+		t.Errorf("ListSnapshottableDirs once disallowed: got %v, %v\n", dirs, err)
+	}
+}
+
+func TestRPCQuotas(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	_, c := connectCluster(t, m, 1)
+	writeFile(t, c, "/q/file", hdfs.O_WRONLY, []byte("abc"))
+
+	if err := c.SetQuota("/q", 3, 30); err != nil {
+		t.Fatalf("Error on SetQuota: %v\n", err)
+	}
+	if err := c.SetTypeQuota("/q", hdfs.StorageSSD, 100); err != nil {
+		t.Fatalf("Error on SetTypeQuota: %v\n", err)
+	}
+	s, err := c.GetContentSummary("/q")
+	if err != nil {
+		t.Fatalf("Error on GetContentSummary: %v\n", err)
+	}
+	if want, _ := m.GetContentSummary("/q"); !reflect.DeepEqual(s, want) {
+		t.Errorf("GetContentSummary: got %+v, want %+v\n", s, want)
+	}
+	if s.Quota != 3 || s.SpaceQuota != 30 || s.TypeQuotas[hdfs.StorageSSD].Quota != 100 {
+		t.Errorf("GetContentSummary: got %+v\n", s)
+	}
+	if err := c.CreateDirectory("/q/a/b"); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("CreateDirectory over the namespace quota: got %v\n", err)
+	}
+	if err := c.SetQuota("/q/file", 1, hdfs.QuotaDontSet); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SetQuota of a file: got %v\n", err)
+	}
+	if err := c.ClearQuota("/q"); err != nil {
+		t.Errorf("Error on ClearQuota: %v\n", err)
+	}
+	if err := c.CreateDirectory("/q/a/b"); err != nil {
+		t.Errorf("Error on CreateDirectory once the quota is cleared: %v\n", err)
+	}
+	if s, _ := c.GetContentSummary("/q"); s.Quota != -1 || s.SpaceQuota != -1 || len(s.TypeQuotas) != 1 {
+		t.Errorf("GetContentSummary once cleared: got %+v\n", s)
+	}
+}
+
+func TestRPCTrash(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.CreateDirectory("/ez")
+	m.CreateEncryptionZone("/ez", "key")
+	_, c := connectCluster(t, m, 1, hdfs.WithSetting("fs.trash.interval", "30"))
+	writeFile(t, c, "/ez/f", hdfs.O_WRONLY, []byte("secret"))
+
+	if d, err := c.TrashInterval(); d != 30*time.Minute || err != nil {
+		t.Errorf("TrashInterval of the client: got %v, %v\n", d, err)
+	}
+	home := "/user/" + hdfstest.Superuser + "/.Trash"
+	zone := "/ez/.Trash/" + hdfstest.Superuser
+	for p, want := range map[string]string{"/ez/f": zone, "/ez": home, "/other": home} {
+		if root, err := c.TrashRoot(p); root != want || err != nil {
+			t.Errorf("TrashRoot of %s: got %q, %v, want %q\n", p, root, err, want)
+		}
+	}
+	if err := c.Rename("/ez/f", "/f"); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("Rename out of an encryption zone: got %v\n", err)
+	}
+	if p, err := hdfs.MoveToTrash(c, "/ez/f"); p != zone+"/Current/ez/f" || err != nil {
+		t.Errorf("MoveToTrash in an encryption zone: got %q, %v\n", p, err)
+	}
+	if roots, err := c.TrashRoots(); err != nil || !reflect.DeepEqual(roots, []string{zone}) {
+		t.Errorf("TrashRoots: got %v, %v\n", roots, err)
+	}
+	if err := hdfs.Expunge(c); err != nil {
+		t.Errorf("Error on Expunge: %v\n", err)
+	}
+	if p, err := hdfs.RestoreFromTrash(c, "/ez/f"); !strings.HasPrefix(p, zone+"/") || err != nil || string(readFile(t, c, "/ez/f")) != "secret" {
+		t.Errorf("RestoreFromTrash: got %q, %v\n", p, err)
+	}
+
+	m.SetTrashInterval(time.Hour)
+	_, c = connectCluster(t, m, 1, hdfs.WithSetting("fs.trash.interval", "30"))
+	if d, err := c.TrashInterval(); d != time.Hour || err != nil {
+		t.Errorf("TrashInterval of the namenode: got %v, %v\n", d, err)
+	}
+}
+
+func TestRPCFileChecksum(t *testing.T) {
+	data := randomData(10000)
+	for _, tt := range []struct {
+		settings  []hdfs.Option
+		algorithm string
+	}{
+		{nil, "MD5-of-8MD5-of-512CRC32C"},
+		{[]hdfs.Option{hdfs.WithSetting("dfs.bytes-per-checksum", "1024"), hdfs.WithSetting("dfs.checksum.type", "CRC32")}, "MD5-of-4MD5-of-1024CRC32"},
+		{[]hdfs.Option{hdfs.WithSetting("dfs.checksum.combine.mode", "COMPOSITE_CRC")}, "COMPOSITE-CRC32C"},
+	} {
+		m := hdfstest.NewMemFS()
+		m.SetDefaults(4096, 2)
+		_, c := connectCluster(t, m, 2, tt.settings...)
+		writeFile(t, c, "/file", hdfs.O_WRONLY, data)
+		sum, err := c.GetFileChecksum("/file")
+		if err != nil || sum.Algorithm != tt.algorithm {
+			t.Errorf("GetFileChecksum: got %v, %v; want %s\n", sum, err, tt.algorithm)
+			continue
+		}
+		if err := hdfs.VerifyChecksum(c, "/file", bytes.NewReader(data)); err != nil {
+			t.Errorf("Error on VerifyChecksum with %s: %v\n", tt.algorithm, err)
+		}
+		if err := hdfs.VerifyChecksum(c, "/file", bytes.NewReader(data[1:])); !errors.Is(err, hdfs.ErrChecksum) {
+			t.Errorf("VerifyChecksum of other data with %s: got %v\n", tt.algorithm, err)
+		}
+	}
+
+	m := hdfstest.NewMemFS()
+	_, c := connectCluster(t, m, 1)
+	writeFile(t, c, "/empty", hdfs.O_WRONLY, nil)
+	if sum, err := c.GetFileChecksum("/empty"); err != nil || sum.Algorithm != "MD5-of-0MD5-of-0CRC32" {
+		t.Errorf("GetFileChecksum of an empty file: got %v, %v\n", sum, err)
+	}
+	if _, err := c.GetFileChecksum("/missing"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("GetFileChecksum of a missing file: got %v\n", err)
+	}
+	if _, err := hdfs.ConnectWithOptions(hdfs.WithNamenode("hdfs://localhost:1"), hdfs.WithConfDir(t.TempDir()), hdfs.WithSetting("dfs.checksum.combine.mode", "SHA")); err == nil || !strings.Contains(err.Error(), "combine") {
+		t.Errorf("Connect with an unknown combine mode: got %v\n", err)
+	}
+}
+
+func TestRPCChecksumSettings(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 1)
+	_, c := connectCluster(t, m, 1, hdfs.WithSetting("dfs.checksum.type", "CRC32"), hdfs.WithSetting("dfs.bytes-per-checksum", "100"))
+	data := randomData(5000)
+	writeFile(t, c, "/crc32", hdfs.O_WRONLY, data)
+	if got := readFile(t, c, "/crc32"); !bytes.Equal(got, data) {
+		t.Errorf("content: got %d bytes, want %d\n", len(got), len(data))
+	}
+}
+
+func TestRPCReadFailover(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 3)
+	dns, c := connectCluster(t, m, 3)
+	data := randomData(10000)
+	f, _ := m.Open("/file", hdfs.O_WRONLY, 0, 0, 0)
+	f.Write(data)
+	f.Close()
+	dns[0].Corrupt()
+	dns[1].Close()
+	if got := readFile(t, c, "/file"); !bytes.Equal(got, data) {
+		t.Errorf("content: got %d bytes, want %d\n", len(got), len(data))
+	}
+	dns[2].Close()
+	file, err := c.OpenFile("/file", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening: %v\n", err)
+	}
+	defer file.Close()
+	if _, err := file.Read(make([]byte, 100)); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Read without a live replica: got %v\n", err)
+	}
+}
+
+func TestRPCWriteRecovery(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 3)
+	dns, c := connectCluster(t, m, 3)
+	dns[0].CrashAfter(5000)
+	data := randomData(12000)
+	writeFile(t, c, "/file", hdfs.O_WRONLY, data)
+	if got := readFile(t, m, "/file"); !bytes.Equal(got, data) {
+		t.Errorf("content: got %d bytes, want %d\n", len(got), len(data))
+	}
+	hosts, err := c.GetHosts("/file", 4096, 8000)
+	if err != nil || len(hosts) != 2 {
+		t.Fatalf("GetHosts: got %v, %v\n", hosts, err)
+	}
+	for _, names := range hosts {
+		if len(names) != 2 || names[0] == dns[0].Name() || names[1] == dns[0].Name() {
+			t.Errorf("hosts after the crash of %s: got %v\n", dns[0].Name(), hosts)
+		}
+	}
+	if got := readFile(t, c, "/file"); !bytes.Equal(got, data) {
+		t.Errorf("content read: got %d bytes, want %d\n", len(got), len(data))
+	}
+}
+
+func TestRPCWriteExclude(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4096, 2)
+	dns, c := connectCluster(t, m, 3)
+	dns[0].Close()
+	data := randomData(9000)
+	writeFile(t, c, "/file", hdfs.O_WRONLY, data)
+	if got := readFile(t, c, "/file"); !bytes.Equal(got, data) {
+		t.Errorf("content: got %d bytes, want %d\n", len(got), len(data))
+	}
+	dns[1].Close()
+	dns[2].Close()
+	file, err := c.OpenFile("/other", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating a file: %v\n", err)
+	}
+	if _, err := file.Write(data); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Write without a live datanode: got %v\n", err)
+	}
+	file.Close()
+}
@@ -0,0 +1,1863 @@
+//go:build cgo && !purego
+
+#include <jni.h>
+#include <string.h>
+#include "hdfs_shim.h"
+
+/* Exported by libhdfs (hdfsJniHelper.c): attaches the thread to the JVM,
+ * creating it from CLASSPATH on first use. */
+extern JNIEnv* getJNIEnv(void);
+
+#define HADOOP_CONF "org/apache/hadoop/conf/Configuration"
+#define HADOOP_PATH "org/apache/hadoop/fs/Path"
+#define HADOOP_FS   "org/apache/hadoop/fs/FileSystem"
+#define JAVA_URI    "java/net/URI"
+#define XATTR_FLAG  "org/apache/hadoop/fs/XAttrSetFlag"
+#define ACL_ENTRY   "org/apache/hadoop/fs/permission/AclEntry"
+#define ACL_STATUS  "org/apache/hadoop/fs/permission/AclStatus"
+#define FS_PERM     "org/apache/hadoop/fs/permission/FsPermission"
+#define SNAP_DIR    "org/apache/hadoop/hdfs/protocol/SnapshottableDirectoryStatus"
+#define SNAP_REPORT "org/apache/hadoop/hdfs/protocol/SnapshotDiffReport"
+#define STORAGE     "org/apache/hadoop/fs/StorageType"
+#define SUMMARY     "org/apache/hadoop/fs/ContentSummary"
+#define FS_DEFAULTS "org/apache/hadoop/fs/FsServerDefaults"
+#define FS_STATUS   "org/apache/hadoop/fs/FsStatus"
+
+#define SIG_CONF "L" HADOOP_CONF ";"
+#define SIG_PATH "L" HADOOP_PATH ";"
+#define SIG_FS   "L" HADOOP_FS ";"
+#define SIG_URI  "L" JAVA_URI ";"
+#define SIG_STR  "Ljava/lang/String;"
+#define SIG_SET  "Ljava/util/EnumSet;"
+#define SIG_LIST "Ljava/util/List;"
+#define SIG_TYPE "L" STORAGE ";"
+#define SIG_COLL "Ljava/util/Collection;"
+
+/* check reports, and clears, a pending Java exception. */
+static int check(JNIEnv *env)
+{
+    if ((*env)->ExceptionCheck(env)) {
+        (*env)->ExceptionDescribe(env);
+        (*env)->ExceptionClear(env);
+        return -1;
+    }
+    return 0;
+}
+
+static jstring newString(JNIEnv *env, const char *s)
+{
+    return (*env)->NewStringUTF(env, s);
+}
+
+/* newConf returns a local reference to a Configuration loaded from resources
+ * and overridden with keys and values, or NULL. */
+static jobject newConf(JNIEnv *env, const char **resources, int nresources,
+                       const char **keys, const char **values, int nkeys)
+{
+    jclass confClass, pathClass;
+    jmethodID confInit, pathInit, addResource, set;
+    jobject conf, path;
+    jstring s, v;
+    int i;
+
+    confClass = (*env)->FindClass(env, HADOOP_CONF);
+    pathClass = (*env)->FindClass(env, HADOOP_PATH);
+    if (check(env) || confClass == NULL || pathClass == NULL) {
+        return NULL;
+    }
+    confInit = (*env)->GetMethodID(env, confClass, "<init>", "()V");
+    pathInit = (*env)->GetMethodID(env, pathClass, "<init>", "(" SIG_STR ")V");
+    addResource = (*env)->GetMethodID(env, confClass, "addResource", "(" SIG_PATH ")V");
+    set = (*env)->GetMethodID(env, confClass, "set", "(" SIG_STR SIG_STR ")V");
+    if (check(env)) {
+        return NULL;
+    }
+    conf = (*env)->NewObject(env, confClass, confInit);
+    if (check(env)) {
+        return NULL;
+    }
+    for (i = 0; i < nresources; i++) {
+        s = newString(env, resources[i]);
+        path = (*env)->NewObject(env, pathClass, pathInit, s);
+        if (path != NULL) {
+            (*env)->CallVoidMethod(env, conf, addResource, path);
+            (*env)->DeleteLocalRef(env, path);
+        }
+        (*env)->DeleteLocalRef(env, s);
+        if (check(env)) {
+            (*env)->DeleteLocalRef(env, conf);
+            return NULL;
+        }
+    }
+    for (i = 0; i < nkeys; i++) {
+        s = newString(env, keys[i]);
+        v = newString(env, values[i]);
+        (*env)->CallVoidMethod(env, conf, set, s, v);
+        (*env)->DeleteLocalRef(env, s);
+        (*env)->DeleteLocalRef(env, v);
+        if (check(env)) {
+            (*env)->DeleteLocalRef(env, conf);
+            return NULL;
+        }
+    }
+    return conf;
+}
+
+hdfsFS hdfsConnectWithConf(const char *uri, const char *user,
+                           const char **resources, int nresources,
+                           const char **keys, const char **values, int nkeys)
+{
+    JNIEnv *env;
+    jclass fsClass, uriClass;
+    jmethodID getDefaultUri, create, get;
+    jobject conf, juri = NULL, fs = NULL;
+    jstring s, u = NULL;
+    hdfsFS ret = NULL;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return NULL;
+    }
+    conf = newConf(env, resources, nresources, keys, values, nkeys);
+    if (conf == NULL) {
+        errno = EINTERNAL;
+        return NULL;
+    }
+    fsClass = (*env)->FindClass(env, HADOOP_FS);
+    uriClass = (*env)->FindClass(env, JAVA_URI);
+    if (check(env) || fsClass == NULL || uriClass == NULL) {
+        goto done;
+    }
+    if (uri == NULL) {
+        getDefaultUri = (*env)->GetStaticMethodID(env, fsClass, "getDefaultUri", "(" SIG_CONF ")" SIG_URI);
+        if (check(env)) {
+            goto done;
+        }
+        juri = (*env)->CallStaticObjectMethod(env, fsClass, getDefaultUri, conf);
+    } else {
+        create = (*env)->GetStaticMethodID(env, uriClass, "create", "(" SIG_STR ")" SIG_URI);
+        if (check(env)) {
+            goto done;
+        }
+        s = newString(env, uri);
+        juri = (*env)->CallStaticObjectMethod(env, uriClass, create, s);
+        (*env)->DeleteLocalRef(env, s);
+    }
+    if (check(env)) {
+        goto done;
+    }
+    if (user == NULL) {
+        get = (*env)->GetStaticMethodID(env, fsClass, "get", "(" SIG_URI SIG_CONF ")" SIG_FS);
+        if (!check(env)) {
+            fs = (*env)->CallStaticObjectMethod(env, fsClass, get, juri, conf);
+        }
+    } else {
+        get = (*env)->GetStaticMethodID(env, fsClass, "get", "(" SIG_URI SIG_CONF SIG_STR ")" SIG_FS);
+        if (!check(env)) {
+            u = newString(env, user);
+            fs = (*env)->CallStaticObjectMethod(env, fsClass, get, juri, conf, u);
+            (*env)->DeleteLocalRef(env, u);
+        }
+    }
+    if (!check(env) && fs != NULL) {
+        /* hdfsDisconnect closes the FileSystem and deletes this reference */
+        ret = (*env)->NewGlobalRef(env, fs);
+    }
+
+done:
+    if (fs != NULL) {
+        (*env)->DeleteLocalRef(env, fs);
+    }
+    if (juri != NULL) {
+        (*env)->DeleteLocalRef(env, juri);
+    }
+    (*env)->DeleteLocalRef(env, conf);
+    if (ret == NULL) {
+        errno = EINTERNAL;
+    }
+    return ret;
+}
+
+/* callStream calls the void method name of the output stream of file, or
+ * fallback if the stream has no such method and fallback is not NULL. */
+static int callStream(hdfsFile file, const char *name, const char *fallback)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid;
+
+    if (file == NULL || file->type != OUTPUT) {
+        errno = EBADF;
+        return -1;
+    }
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    cls = (*env)->GetObjectClass(env, (jobject)file->file);
+    mid = (*env)->GetMethodID(env, cls, name, "()V");
+    if (mid == NULL && fallback != NULL) {
+        (*env)->ExceptionClear(env);
+        mid = (*env)->GetMethodID(env, cls, fallback, "()V");
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL) {
+        (*env)->ExceptionClear(env);
+        errno = ENOTSUP;
+        return -1;
+    }
+    (*env)->CallVoidMethod(env, (jobject)file->file, mid);
+    if (check(env)) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    return 0;
+}
+
+int hdfsFileHFlush(hdfsFS fs, hdfsFile file)
+{
+    (void)fs;
+    return callStream(file, "hflush", "sync");
+}
+
+int hdfsFileHSync(hdfsFS fs, hdfsFile file)
+{
+    (void)fs;
+    return callStream(file, "hsync", NULL);
+}
+
+/* exceptionErrno clears the pending Java exception and returns the errno
+ * libhdfs 2 sets for it, or, for the IOExceptions HDFS tells apart by their
+ * message only, the errno of the message. */
+static int exceptionErrno(JNIEnv *env)
+{
+    static const struct {
+        const char *text;
+        int errnum;
+    } messages[] = {
+        {"At least one of the attributes provided was not found.", ENODATA},
+        {"No matching attributes found for remove operation", ENODATA},
+        {"The CREATE flag must be specified.", ENODATA},
+        {"The REPLACE flag must be specified.", EEXIST},
+        {"is not a snapshottable directory", EINVAL},
+        {"there is already a snapshot with the same name", EEXIST},
+        {"already exists for directory", EEXIST},
+        {"Cannot find the snapshot", ENOENT},
+        {"the snapshot does not exist", ENOENT},
+        {"does not exist for directory", ENOENT},
+        {"Please redo the operation after removing all the snapshots", ENOTEMPTY},
+        {"is snapshottable and already has snapshots", ENOTEMPTY},
+        {"can't be moved from an encryption zone.", EXDEV},
+        {"can't be moved into an encryption zone.", EXDEV},
+        {"can't be moved from encryption zone", EXDEV},
+    };
+    static const struct {
+        const char *name;
+        int errnum;
+    } classes[] = {
+        {"java/io/FileNotFoundException", ENOENT},
+        {"org/apache/hadoop/security/AccessControlException", EACCES},
+        {"org/apache/hadoop/fs/FileAlreadyExistsException", EEXIST},
+        {"org/apache/hadoop/HadoopIllegalArgumentException", EINVAL},
+        {"java/lang/IllegalArgumentException", EINVAL},
+        {"java/lang/UnsupportedOperationException", ENOTSUP},
+        {"org/apache/hadoop/hdfs/protocol/AclException", EINVAL},
+        {"org/apache/hadoop/hdfs/protocol/QuotaExceededException", EDQUOT},
+        {"org/apache/hadoop/fs/PathIsNotEmptyDirectoryException", ENOTEMPTY},
+    };
+    jthrowable exc;
+    jclass cls;
+    jmethodID mid;
+    jstring msg;
+    const char *chars;
+    size_t i;
+    int errnum = EINTERNAL;
+
+    exc = (*env)->ExceptionOccurred(env);
+    if (exc == NULL) {
+        return EINTERNAL;
+    }
+    (*env)->ExceptionDescribe(env);
+    (*env)->ExceptionClear(env);
+    for (i = 0; i < sizeof(classes) / sizeof(classes[0]); i++) {
+        cls = (*env)->FindClass(env, classes[i].name);
+        if (cls == NULL) {
+            (*env)->ExceptionClear(env);
+            continue;
+        }
+        if ((*env)->IsInstanceOf(env, exc, cls)) {
+            errnum = classes[i].errnum;
+        }
+        (*env)->DeleteLocalRef(env, cls);
+        if (errnum != EINTERNAL) {
+            break;
+        }
+    }
+    if (errnum == EINTERNAL) {
+        cls = (*env)->GetObjectClass(env, exc);
+        mid = (*env)->GetMethodID(env, cls, "getMessage", "()" SIG_STR);
+        msg = mid != NULL ? (*env)->CallObjectMethod(env, exc, mid) : NULL;
+        (*env)->ExceptionClear(env);
+        if (msg != NULL) {
+            chars = (*env)->GetStringUTFChars(env, msg, NULL);
+            for (i = 0; chars != NULL && i < sizeof(messages) / sizeof(messages[0]); i++) {
+                if (strstr(chars, messages[i].text) != NULL) {
+                    errnum = messages[i].errnum;
+                    break;
+                }
+            }
+            if (chars != NULL) {
+                (*env)->ReleaseStringUTFChars(env, msg, chars);
+            }
+            (*env)->DeleteLocalRef(env, msg);
+        }
+        (*env)->DeleteLocalRef(env, cls);
+    }
+    (*env)->DeleteLocalRef(env, exc);
+    return errnum;
+}
+
+/* newPath returns a local reference to the Path of path, or NULL. */
+static jobject newPath(JNIEnv *env, const char *path)
+{
+    jclass cls;
+    jmethodID init;
+    jstring s;
+    jobject p;
+
+    cls = (*env)->FindClass(env, HADOOP_PATH);
+    if (cls == NULL) {
+        return NULL;
+    }
+    init = (*env)->GetMethodID(env, cls, "<init>", "(" SIG_STR ")V");
+    if (init == NULL) {
+        (*env)->DeleteLocalRef(env, cls);
+        return NULL;
+    }
+    s = newString(env, path);
+    p = (*env)->NewObject(env, cls, init, s);
+    (*env)->DeleteLocalRef(env, s);
+    (*env)->DeleteLocalRef(env, cls);
+    return p;
+}
+
+/* fsMethod returns the method name of the FileSystem fs, setting errno to
+ * ENOTSUP if it has none. */
+static jmethodID fsMethod(JNIEnv *env, hdfsFS fs, const char *name, const char *sig)
+{
+    jclass cls;
+    jmethodID mid;
+
+    cls = (*env)->GetObjectClass(env, (jobject)fs);
+    mid = (*env)->GetMethodID(env, cls, name, sig);
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL) {
+        (*env)->ExceptionClear(env);
+        errno = ENOTSUP;
+    }
+    return mid;
+}
+
+/* callPathBool calls the boolean method name of fs on the Path of path. */
+static int callPathBool(hdfsFS fs, const char *name, const char *sig, const char *path, tOffset arg, int witharg)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p;
+    jboolean ret;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, name, sig);
+    if (mid == NULL) {
+        return -1;
+    }
+    p = newPath(env, path);
+    if (p == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    if (witharg) {
+        ret = (*env)->CallBooleanMethod(env, (jobject)fs, mid, p, (jlong)arg);
+    } else {
+        ret = (*env)->CallBooleanMethod(env, (jobject)fs, mid, p);
+    }
+    (*env)->DeleteLocalRef(env, p);
+    if ((*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    return ret ? 1 : 0;
+}
+
+int hdfsFsTruncate(hdfsFS fs, const char *path, tOffset newLength)
+{
+    return callPathBool(fs, "truncate", "(" SIG_PATH "J)Z", path, newLength, 1);
+}
+
+int hdfsFsIsFileClosed(hdfsFS fs, const char *path)
+{
+    return callPathBool(fs, "isFileClosed", "(" SIG_PATH ")Z", path, 0, 0);
+}
+
+int hdfsFsConcat(hdfsFS fs, const char *target, const char **srcs, int nsrcs)
+{
+    JNIEnv *env;
+    jclass pathClass;
+    jmethodID mid;
+    jobject trg = NULL, p;
+    jobjectArray arr = NULL;
+    int i, ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "concat", "(" SIG_PATH "[" SIG_PATH ")V");
+    if (mid == NULL) {
+        return -1;
+    }
+    pathClass = (*env)->FindClass(env, HADOOP_PATH);
+    if (pathClass == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    trg = newPath(env, target);
+    if (trg == NULL) {
+        goto done;
+    }
+    arr = (*env)->NewObjectArray(env, nsrcs, pathClass, NULL);
+    if (arr == NULL) {
+        goto done;
+    }
+    for (i = 0; i < nsrcs; i++) {
+        p = newPath(env, srcs[i]);
+        if (p == NULL) {
+            goto done;
+        }
+        (*env)->SetObjectArrayElement(env, arr, i, p);
+        (*env)->DeleteLocalRef(env, p);
+    }
+    (*env)->CallVoidMethod(env, (jobject)fs, mid, trg, arr);
+    if (!(*env)->ExceptionCheck(env)) {
+        ret = 0;
+    }
+
+done:
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (arr != NULL) {
+        (*env)->DeleteLocalRef(env, arr);
+    }
+    if (trg != NULL) {
+        (*env)->DeleteLocalRef(env, trg);
+    }
+    (*env)->DeleteLocalRef(env, pathClass);
+    return ret;
+}
+
+int hdfsFsGetFileChecksum(hdfsFS fs, const char *path, char **algorithm, char **bytes, int *length)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid;
+    jobject p, sum;
+    jstring name = NULL;
+    jbyteArray arr = NULL;
+    const char *chars;
+    int ret = -1, errnum = 0;
+
+    *algorithm = NULL;
+    *bytes = NULL;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getFileChecksum", "(" SIG_PATH ")Lorg/apache/hadoop/fs/FileChecksum;");
+    if (mid == NULL) {
+        return -1;
+    }
+    p = newPath(env, path);
+    if (p == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    sum = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+    (*env)->DeleteLocalRef(env, p);
+    if ((*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    if (sum == NULL) {
+        errno = ENOTSUP;
+        return -1;
+    }
+    cls = (*env)->GetObjectClass(env, sum);
+    mid = (*env)->GetMethodID(env, cls, "getAlgorithmName", "()" SIG_STR);
+    if (mid == NULL || (name = (*env)->CallObjectMethod(env, sum, mid)) == NULL) {
+        goto done;
+    }
+    mid = (*env)->GetMethodID(env, cls, "getBytes", "()[B");
+    if (mid == NULL || (arr = (*env)->CallObjectMethod(env, sum, mid)) == NULL) {
+        goto done;
+    }
+    chars = (*env)->GetStringUTFChars(env, name, NULL);
+    if (chars == NULL) {
+        goto done;
+    }
+    *algorithm = strdup(chars);
+    (*env)->ReleaseStringUTFChars(env, name, chars);
+    *length = (*env)->GetArrayLength(env, arr);
+    *bytes = malloc(*length > 0 ? *length : 1);
+    if (*algorithm == NULL || *bytes == NULL) {
+        errnum = ENOMEM;
+        goto done;
+    }
+    (*env)->GetByteArrayRegion(env, arr, 0, *length, (jbyte *)*bytes);
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        free(*algorithm);
+        free(*bytes);
+        *algorithm = NULL;
+        *bytes = NULL;
+        errno = errnum ? errnum : exceptionErrno(env);
+    }
+    if (arr != NULL) {
+        (*env)->DeleteLocalRef(env, arr);
+    }
+    if (name != NULL) {
+        (*env)->DeleteLocalRef(env, name);
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    (*env)->DeleteLocalRef(env, sum);
+    return ret;
+}
+
+/* xattrFlags returns a local reference to the EnumSet of XAttrSetFlag
+ * holding the flags XATTR_CREATE (1) and XATTR_REPLACE (2), or NULL. */
+static jobject xattrFlags(JNIEnv *env, int flags)
+{
+    static const char *names[] = {"CREATE", "REPLACE"};
+    jclass setClass, flagClass;
+    jmethodID noneOf, add;
+    jfieldID fid;
+    jobject set = NULL, flag;
+    int i;
+
+    flagClass = (*env)->FindClass(env, XATTR_FLAG);
+    if (flagClass == NULL) {
+        return NULL;
+    }
+    setClass = (*env)->FindClass(env, "java/util/EnumSet");
+    if (setClass == NULL) {
+        (*env)->DeleteLocalRef(env, flagClass);
+        return NULL;
+    }
+    noneOf = (*env)->GetStaticMethodID(env, setClass, "noneOf", "(Ljava/lang/Class;)" SIG_SET);
+    add = (*env)->GetMethodID(env, setClass, "add", "(Ljava/lang/Object;)Z");
+    if (noneOf != NULL && add != NULL) {
+        set = (*env)->CallStaticObjectMethod(env, setClass, noneOf, flagClass);
+    }
+    for (i = 0; set != NULL && i < 2; i++) {
+        if (!(flags & (1 << i))) {
+            continue;
+        }
+        fid = (*env)->GetStaticFieldID(env, flagClass, names[i], "L" XATTR_FLAG ";");
+        flag = fid != NULL ? (*env)->GetStaticObjectField(env, flagClass, fid) : NULL;
+        if (flag == NULL) {
+            break;
+        }
+        (*env)->CallBooleanMethod(env, set, add, flag);
+        (*env)->DeleteLocalRef(env, flag);
+    }
+    if (set != NULL && (*env)->ExceptionCheck(env)) {
+        (*env)->DeleteLocalRef(env, set);
+        set = NULL;
+    }
+    (*env)->DeleteLocalRef(env, setClass);
+    (*env)->DeleteLocalRef(env, flagClass);
+    return set;
+}
+
+int hdfsFsSetXAttr(hdfsFS fs, const char *path, const char *name, const char *value, int size, int flags)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL, set = NULL;
+    jstring n = NULL;
+    jbyteArray v = NULL;
+    int ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "setXAttr", "(" SIG_PATH SIG_STR "[B" SIG_SET ")V");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) == NULL || (n = newString(env, name)) == NULL) {
+        goto done;
+    }
+    if ((v = (*env)->NewByteArray(env, size)) == NULL) {
+        goto done;
+    }
+    (*env)->SetByteArrayRegion(env, v, 0, size, (const jbyte *)value);
+    if ((set = xattrFlags(env, flags)) == NULL) {
+        goto done;
+    }
+    (*env)->CallVoidMethod(env, (jobject)fs, mid, p, n, v, set);
+    if (!(*env)->ExceptionCheck(env)) {
+        ret = 0;
+    }
+
+done:
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (set != NULL) {
+        (*env)->DeleteLocalRef(env, set);
+    }
+    if (v != NULL) {
+        (*env)->DeleteLocalRef(env, v);
+    }
+    if (n != NULL) {
+        (*env)->DeleteLocalRef(env, n);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+int hdfsFsGetXAttr(hdfsFS fs, const char *path, const char *name, char **value, int *size)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL;
+    jstring n = NULL;
+    jbyteArray v = NULL;
+    int ret = -1, errnum = 0;
+
+    *value = NULL;
+    *size = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getXAttr", "(" SIG_PATH SIG_STR ")[B");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) == NULL || (n = newString(env, name)) == NULL) {
+        goto done;
+    }
+    v = (*env)->CallObjectMethod(env, (jobject)fs, mid, p, n);
+    if ((*env)->ExceptionCheck(env)) {
+        goto done;
+    }
+    if (v != NULL) {
+        *size = (*env)->GetArrayLength(env, v);
+    }
+    *value = malloc(*size > 0 ? *size : 1);
+    if (*value == NULL) {
+        errnum = ENOMEM;
+        goto done;
+    }
+    if (*size > 0) {
+        (*env)->GetByteArrayRegion(env, v, 0, *size, (jbyte *)*value);
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        free(*value);
+        *value = NULL;
+        errno = errnum ? errnum : exceptionErrno(env);
+    }
+    if (v != NULL) {
+        (*env)->DeleteLocalRef(env, v);
+    }
+    if (n != NULL) {
+        (*env)->DeleteLocalRef(env, n);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+int hdfsFsListXAttrs(hdfsFS fs, const char *path, char ***names, int *count)
+{
+    JNIEnv *env;
+    jclass cls = NULL;
+    jmethodID mid, sizeMid, getMid;
+    jobject p, list;
+    jstring name;
+    const char *chars;
+    int i, ret = -1, errnum = 0;
+
+    *names = NULL;
+    *count = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "listXAttrs", "(" SIG_PATH ")" SIG_LIST);
+    if (mid == NULL) {
+        return -1;
+    }
+    p = newPath(env, path);
+    if (p == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    list = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+    (*env)->DeleteLocalRef(env, p);
+    if ((*env)->ExceptionCheck(env) || list == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    cls = (*env)->GetObjectClass(env, list);
+    sizeMid = (*env)->GetMethodID(env, cls, "size", "()I");
+    getMid = (*env)->GetMethodID(env, cls, "get", "(I)Ljava/lang/Object;");
+    if (sizeMid == NULL || getMid == NULL) {
+        goto done;
+    }
+    *count = (*env)->CallIntMethod(env, list, sizeMid);
+    if ((*env)->ExceptionCheck(env)) {
+        goto done;
+    }
+    *names = calloc(*count > 0 ? *count : 1, sizeof(char *));
+    if (*names == NULL) {
+        errnum = ENOMEM;
+        goto done;
+    }
+    for (i = 0; i < *count; i++) {
+        name = (*env)->CallObjectMethod(env, list, getMid, (jint)i);
+        if (name == NULL) {
+            goto done;
+        }
+        chars = (*env)->GetStringUTFChars(env, name, NULL);
+        if (chars != NULL) {
+            (*names)[i] = strdup(chars);
+            (*env)->ReleaseStringUTFChars(env, name, chars);
+        }
+        (*env)->DeleteLocalRef(env, name);
+        if (chars == NULL) {
+            goto done;
+        }
+        if ((*names)[i] == NULL) {
+            errnum = ENOMEM;
+            goto done;
+        }
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        if (*names != NULL) {
+            hdfsFsFreeXAttrNames(*names, *count);
+        }
+        *names = NULL;
+        *count = 0;
+        errno = errnum ? errnum : exceptionErrno(env);
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    (*env)->DeleteLocalRef(env, list);
+    return ret;
+}
+
+void hdfsFsFreeXAttrNames(char **names, int count)
+{
+    int i;
+
+    for (i = 0; i < count; i++) {
+        free(names[i]);
+    }
+    free(names);
+}
+
+int hdfsFsRemoveXAttr(hdfsFS fs, const char *path, const char *name)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL;
+    jstring n = NULL;
+    int ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "removeXAttr", "(" SIG_PATH SIG_STR ")V");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL && (n = newString(env, name)) != NULL) {
+        (*env)->CallVoidMethod(env, (jobject)fs, mid, p, n);
+        if (!(*env)->ExceptionCheck(env)) {
+            ret = 0;
+        }
+    }
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (n != NULL) {
+        (*env)->DeleteLocalRef(env, n);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+/* callString returns a copy of the String the method name of obj returns,
+ * to be freed with free(), or NULL. */
+static char *callString(JNIEnv *env, jobject obj, const char *name)
+{
+    jclass cls;
+    jmethodID mid;
+    jstring s = NULL;
+    const char *chars;
+    char *ret = NULL;
+
+    cls = (*env)->GetObjectClass(env, obj);
+    mid = (*env)->GetMethodID(env, cls, name, "()" SIG_STR);
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL || (s = (*env)->CallObjectMethod(env, obj, mid)) == NULL) {
+        return NULL;
+    }
+    chars = (*env)->GetStringUTFChars(env, s, NULL);
+    if (chars != NULL) {
+        ret = strdup(chars);
+        (*env)->ReleaseStringUTFChars(env, s, chars);
+    }
+    (*env)->DeleteLocalRef(env, s);
+    return ret;
+}
+
+/* aclSpec returns a copy of the entries of the List list of AclEntry as a
+ * comma-separated ACL spec, to be freed with free(), or NULL. */
+static char *aclSpec(JNIEnv *env, jobject list)
+{
+    jclass cls;
+    jmethodID sizeMid, getMid;
+    jobject entry;
+    char *spec, *s, *grown;
+    size_t len = 0, n;
+    int i, count;
+
+    cls = (*env)->GetObjectClass(env, list);
+    sizeMid = (*env)->GetMethodID(env, cls, "size", "()I");
+    getMid = (*env)->GetMethodID(env, cls, "get", "(I)Ljava/lang/Object;");
+    (*env)->DeleteLocalRef(env, cls);
+    if (sizeMid == NULL || getMid == NULL) {
+        return NULL;
+    }
+    count = (*env)->CallIntMethod(env, list, sizeMid);
+    if ((*env)->ExceptionCheck(env) || (spec = calloc(1, 1)) == NULL) {
+        return NULL;
+    }
+    for (i = 0; i < count; i++) {
+        entry = (*env)->CallObjectMethod(env, list, getMid, (jint)i);
+        s = entry != NULL ? callString(env, entry, "toString") : NULL;
+        if (entry != NULL) {
+            (*env)->DeleteLocalRef(env, entry);
+        }
+        n = s != NULL ? strlen(s) : 0;
+        grown = s != NULL ? realloc(spec, len + n + 2) : NULL;
+        if (grown == NULL) {
+            free(s);
+            free(spec);
+            return NULL;
+        }
+        spec = grown;
+        if (len > 0) {
+            spec[len++] = ',';
+        }
+        memcpy(spec + len, s, n + 1);
+        len += n;
+        free(s);
+    }
+    return spec;
+}
+
+int hdfsFsGetAclStatus(hdfsFS fs, const char *path, char **owner, char **group, int *sticky, int *perm, char **spec)
+{
+    JNIEnv *env;
+    jclass cls, permClass;
+    jmethodID mid;
+    jobject p, st, list = NULL, permission;
+    int ret = -1;
+
+    *owner = *group = *spec = NULL;
+    *sticky = 0;
+    *perm = -1;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getAclStatus", "(" SIG_PATH ")L" ACL_STATUS ";");
+    if (mid == NULL) {
+        return -1;
+    }
+    p = newPath(env, path);
+    if (p == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    st = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+    (*env)->DeleteLocalRef(env, p);
+    if ((*env)->ExceptionCheck(env) || st == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    cls = (*env)->GetObjectClass(env, st);
+    if ((*owner = callString(env, st, "getOwner")) == NULL || (*group = callString(env, st, "getGroup")) == NULL) {
+        goto done;
+    }
+    mid = (*env)->GetMethodID(env, cls, "isStickyBit", "()Z");
+    if (mid == NULL) {
+        goto done;
+    }
+    *sticky = (*env)->CallBooleanMethod(env, st, mid) ? 1 : 0;
+    /* AclStatus has the permission since Hadoop 2.7 */
+    mid = (*env)->GetMethodID(env, cls, "getPermission", "()L" FS_PERM ";");
+    if (mid == NULL) {
+        (*env)->ExceptionClear(env);
+    } else if ((permission = (*env)->CallObjectMethod(env, st, mid)) != NULL) {
+        permClass = (*env)->GetObjectClass(env, permission);
+        mid = (*env)->GetMethodID(env, permClass, "toShort", "()S");
+        if (mid != NULL) {
+            *perm = (*env)->CallShortMethod(env, permission, mid) & 01777;
+        }
+        (*env)->DeleteLocalRef(env, permClass);
+        (*env)->DeleteLocalRef(env, permission);
+    }
+    mid = (*env)->GetMethodID(env, cls, "getEntries", "()" SIG_LIST);
+    if ((*env)->ExceptionCheck(env) || mid == NULL || (list = (*env)->CallObjectMethod(env, st, mid)) == NULL) {
+        goto done;
+    }
+    if ((*spec = aclSpec(env, list)) != NULL) {
+        ret = 0;
+    }
+
+done:
+    if (ret != 0) {
+        free(*owner);
+        free(*group);
+        *owner = *group = NULL;
+        errno = (*env)->ExceptionCheck(env) ? exceptionErrno(env) : ENOMEM;
+    }
+    if (list != NULL) {
+        (*env)->DeleteLocalRef(env, list);
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    (*env)->DeleteLocalRef(env, st);
+    return ret;
+}
+
+int hdfsFsModifyAcl(hdfsFS fs, const char *path, const char *method, const char *spec)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid, parse;
+    jobject p = NULL, list = NULL;
+    jstring s = NULL;
+    int ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, method, "(" SIG_PATH SIG_LIST ")V");
+    if (mid == NULL) {
+        return -1;
+    }
+    cls = (*env)->FindClass(env, ACL_ENTRY);
+    if (cls == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    parse = (*env)->GetStaticMethodID(env, cls, "parseAclSpec", "(" SIG_STR "Z)" SIG_LIST);
+    if (parse != NULL && (s = newString(env, spec)) != NULL) {
+        list = (*env)->CallStaticObjectMethod(env, cls, parse, s, (jboolean)1);
+    }
+    if (list != NULL && (p = newPath(env, path)) != NULL) {
+        (*env)->CallVoidMethod(env, (jobject)fs, mid, p, list);
+        if (!(*env)->ExceptionCheck(env)) {
+            ret = 0;
+        }
+    }
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    if (list != NULL) {
+        (*env)->DeleteLocalRef(env, list);
+    }
+    if (s != NULL) {
+        (*env)->DeleteLocalRef(env, s);
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    return ret;
+}
+
+int hdfsFsRemoveAcl(hdfsFS fs, const char *path, const char *method)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, method, "(" SIG_PATH ")V");
+    if (mid == NULL) {
+        return -1;
+    }
+    p = newPath(env, path);
+    if (p == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    (*env)->CallVoidMethod(env, (jobject)fs, mid, p);
+    (*env)->DeleteLocalRef(env, p);
+    if ((*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    return 0;
+}
+
+int hdfsFsSnapshot(hdfsFS fs, const char *path, const char *method, const char *name, const char *newName)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL;
+    jstring n = NULL, nn = NULL;
+    const char *sig = "(" SIG_PATH ")V";
+    int ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    if (newName != NULL) {
+        sig = "(" SIG_PATH SIG_STR SIG_STR ")V";
+    } else if (name != NULL) {
+        sig = "(" SIG_PATH SIG_STR ")V";
+    }
+    mid = fsMethod(env, fs, method, sig);
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) == NULL) {
+        goto done;
+    }
+    if (name != NULL && (n = newString(env, name)) == NULL) {
+        goto done;
+    }
+    if (newName != NULL && (nn = newString(env, newName)) == NULL) {
+        goto done;
+    }
+    /* the arguments sig has no parameters for are ignored */
+    (*env)->CallVoidMethod(env, (jobject)fs, mid, p, n, nn);
+    if (!(*env)->ExceptionCheck(env)) {
+        ret = 0;
+    }
+
+done:
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (nn != NULL) {
+        (*env)->DeleteLocalRef(env, nn);
+    }
+    if (n != NULL) {
+        (*env)->DeleteLocalRef(env, n);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+/* uriPath returns a copy of the path of the Path p, without the scheme and
+ * authority, to be freed with free(), or NULL. */
+static char *uriPath(JNIEnv *env, jobject p)
+{
+    jclass cls;
+    jmethodID mid;
+    jobject uri;
+    char *ret;
+
+    cls = (*env)->GetObjectClass(env, p);
+    mid = (*env)->GetMethodID(env, cls, "toUri", "()" SIG_URI);
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL || (uri = (*env)->CallObjectMethod(env, p, mid)) == NULL) {
+        return NULL;
+    }
+    ret = callString(env, uri, "getPath");
+    (*env)->DeleteLocalRef(env, uri);
+    return ret;
+}
+
+int hdfsFsCreateSnapshot(hdfsFS fs, const char *path, const char *name, char **snapshotPath)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL, ret = NULL;
+    jstring n = NULL;
+
+    *snapshotPath = NULL;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    if (name != NULL) {
+        mid = fsMethod(env, fs, "createSnapshot", "(" SIG_PATH SIG_STR ")" SIG_PATH);
+    } else {
+        mid = fsMethod(env, fs, "createSnapshot", "(" SIG_PATH ")" SIG_PATH);
+    }
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL && (name == NULL || (n = newString(env, name)) != NULL)) {
+        ret = (*env)->CallObjectMethod(env, (jobject)fs, mid, p, n);
+    }
+    if (ret != NULL && !(*env)->ExceptionCheck(env)) {
+        *snapshotPath = uriPath(env, ret);
+    }
+    if (*snapshotPath == NULL) {
+        errno = (*env)->ExceptionCheck(env) || ret == NULL ? exceptionErrno(env) : ENOMEM;
+    }
+    if (ret != NULL) {
+        (*env)->DeleteLocalRef(env, ret);
+    }
+    if (n != NULL) {
+        (*env)->DeleteLocalRef(env, n);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return *snapshotPath != NULL ? 0 : -1;
+}
+
+/* callInt returns what the int method name of obj returns, or -1. */
+static int callInt(JNIEnv *env, jobject obj, const char *name)
+{
+    jclass cls;
+    jmethodID mid;
+
+    cls = (*env)->GetObjectClass(env, obj);
+    mid = (*env)->GetMethodID(env, cls, name, "()I");
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL) {
+        return -1;
+    }
+    return (*env)->CallIntMethod(env, obj, mid);
+}
+
+int hdfsFsListSnapshottableDirs(hdfsFS fs, hdfsSnapshottableDir **dirs, int *count)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid, getFullPath = NULL;
+    jobjectArray arr;
+    jobject st, p;
+    hdfsSnapshottableDir *d;
+    int i, ret = -1;
+
+    *dirs = NULL;
+    *count = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getSnapshottableDirListing", "()[L" SNAP_DIR ";");
+    if (mid == NULL) {
+        return -1;
+    }
+    /* null if there are none */
+    arr = (*env)->CallObjectMethod(env, (jobject)fs, mid);
+    if ((*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    if (arr != NULL) {
+        *count = (*env)->GetArrayLength(env, arr);
+    }
+    *dirs = calloc(*count > 0 ? *count : 1, sizeof(hdfsSnapshottableDir));
+    if (*dirs == NULL) {
+        goto done;
+    }
+    for (i = 0; i < *count; i++) {
+        d = &(*dirs)[i];
+        st = (*env)->GetObjectArrayElement(env, arr, i);
+        if (st == NULL) {
+            goto done;
+        }
+        if (getFullPath == NULL) {
+            cls = (*env)->GetObjectClass(env, st);
+            getFullPath = (*env)->GetMethodID(env, cls, "getFullPath", "()" SIG_PATH);
+            (*env)->DeleteLocalRef(env, cls);
+        }
+        p = getFullPath != NULL ? (*env)->CallObjectMethod(env, st, getFullPath) : NULL;
+        if (p != NULL) {
+            d->path = uriPath(env, p);
+            (*env)->DeleteLocalRef(env, p);
+        }
+        if (d->path != NULL) {
+            d->snapshots = callInt(env, st, "getSnapshotNumber");
+            d->quota = callInt(env, st, "getSnapshotQuota");
+        }
+        (*env)->DeleteLocalRef(env, st);
+        if (d->path == NULL || (*env)->ExceptionCheck(env)) {
+            goto done;
+        }
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        if (*dirs != NULL) {
+            hdfsFsFreeSnapshottableDirs(*dirs, *count);
+        }
+        *dirs = NULL;
+        *count = 0;
+        errno = (*env)->ExceptionCheck(env) ? exceptionErrno(env) : ENOMEM;
+    }
+    if (arr != NULL) {
+        (*env)->DeleteLocalRef(env, arr);
+    }
+    return ret;
+}
+
+void hdfsFsFreeSnapshottableDirs(hdfsSnapshottableDir *dirs, int count)
+{
+    int i;
+
+    for (i = 0; i < count; i++) {
+        free(dirs[i].path);
+    }
+    free(dirs);
+}
+
+/* callBytes sets s to a NUL-terminated copy of the byte array the method
+ * name of obj returns, to be freed with free(), or to NULL if it returns
+ * null. Returns 0 on success, -1 on error. */
+static int callBytes(JNIEnv *env, jobject obj, const char *name, char **s)
+{
+    jclass cls;
+    jmethodID mid;
+    jbyteArray arr;
+    jsize len;
+
+    *s = NULL;
+    cls = (*env)->GetObjectClass(env, obj);
+    mid = (*env)->GetMethodID(env, cls, name, "()[B");
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL) {
+        return -1;
+    }
+    arr = (*env)->CallObjectMethod(env, obj, mid);
+    if ((*env)->ExceptionCheck(env)) {
+        return -1;
+    }
+    if (arr == NULL) {
+        return 0;
+    }
+    len = (*env)->GetArrayLength(env, arr);
+    *s = malloc(len + 1);
+    if (*s != NULL) {
+        (*env)->GetByteArrayRegion(env, arr, 0, len, (jbyte *)*s);
+        (*s)[len] = '\0';
+    }
+    (*env)->DeleteLocalRef(env, arr);
+    return *s != NULL ? 0 : -1;
+}
+
+/* diffEntry fills e with the DiffReportEntry entry. Returns 0 on success,
+ * -1 on error. */
+static int diffEntry(JNIEnv *env, jobject entry, hdfsSnapshotDiffEntry *e)
+{
+    jclass cls;
+    jmethodID mid;
+    jobject type;
+    char *label;
+
+    cls = (*env)->GetObjectClass(env, entry);
+    mid = (*env)->GetMethodID(env, cls, "getType", "()L" SNAP_REPORT "$DiffType;");
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL || (type = (*env)->CallObjectMethod(env, entry, mid)) == NULL) {
+        return -1;
+    }
+    label = callString(env, type, "getLabel");
+    (*env)->DeleteLocalRef(env, type);
+    if (label == NULL) {
+        return -1;
+    }
+    e->type = label[0];
+    free(label);
+    if (callBytes(env, entry, "getSourcePath", &e->source) != 0 || e->source == NULL) {
+        return -1;
+    }
+    return callBytes(env, entry, "getTargetPath", &e->target);
+}
+
+int hdfsFsGetSnapshotDiff(hdfsFS fs, const char *path, const char *from, const char *to, hdfsSnapshotDiffEntry **entries, int *count)
+{
+    JNIEnv *env;
+    jclass reportClass, cls = NULL;
+    jmethodID mid, sizeMid, getMid;
+    jobject p = NULL, report = NULL, list = NULL, entry;
+    jstring f = NULL, t = NULL;
+    int i, ret = -1;
+
+    *entries = NULL;
+    *count = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getSnapshotDiffReport", "(" SIG_PATH SIG_STR SIG_STR ")L" SNAP_REPORT ";");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) == NULL || (f = newString(env, from)) == NULL || (t = newString(env, to)) == NULL) {
+        goto done;
+    }
+    report = (*env)->CallObjectMethod(env, (jobject)fs, mid, p, f, t);
+    if ((*env)->ExceptionCheck(env) || report == NULL) {
+        goto done;
+    }
+    reportClass = (*env)->GetObjectClass(env, report);
+    mid = (*env)->GetMethodID(env, reportClass, "getDiffList", "()" SIG_LIST);
+    (*env)->DeleteLocalRef(env, reportClass);
+    if (mid == NULL || (list = (*env)->CallObjectMethod(env, report, mid)) == NULL) {
+        goto done;
+    }
+    cls = (*env)->GetObjectClass(env, list);
+    sizeMid = (*env)->GetMethodID(env, cls, "size", "()I");
+    getMid = (*env)->GetMethodID(env, cls, "get", "(I)Ljava/lang/Object;");
+    if (sizeMid == NULL || getMid == NULL) {
+        goto done;
+    }
+    *count = (*env)->CallIntMethod(env, list, sizeMid);
+    if ((*env)->ExceptionCheck(env)) {
+        goto done;
+    }
+    *entries = calloc(*count > 0 ? *count : 1, sizeof(hdfsSnapshotDiffEntry));
+    if (*entries == NULL) {
+        goto done;
+    }
+    for (i = 0; i < *count; i++) {
+        entry = (*env)->CallObjectMethod(env, list, getMid, (jint)i);
+        if (entry == NULL) {
+            goto done;
+        }
+        if (diffEntry(env, entry, &(*entries)[i]) != 0) {
+            (*env)->DeleteLocalRef(env, entry);
+            goto done;
+        }
+        (*env)->DeleteLocalRef(env, entry);
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        if (*entries != NULL) {
+            hdfsFsFreeSnapshotDiff(*entries, *count);
+        }
+        *entries = NULL;
+        *count = 0;
+        errno = (*env)->ExceptionCheck(env) || report == NULL ? exceptionErrno(env) : ENOMEM;
+    }
+    if (cls != NULL) {
+        (*env)->DeleteLocalRef(env, cls);
+    }
+    if (list != NULL) {
+        (*env)->DeleteLocalRef(env, list);
+    }
+    if (report != NULL) {
+        (*env)->DeleteLocalRef(env, report);
+    }
+    if (t != NULL) {
+        (*env)->DeleteLocalRef(env, t);
+    }
+    if (f != NULL) {
+        (*env)->DeleteLocalRef(env, f);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+void hdfsFsFreeSnapshotDiff(hdfsSnapshotDiffEntry *entries, int count)
+{
+    int i;
+
+    for (i = 0; i < count; i++) {
+        free(entries[i].source);
+        free(entries[i].target);
+    }
+    free(entries);
+}
+
+/* storageTypes are the names of the StorageType constants, in the order
+ * of hdfsContentSummary.typeQuota. */
+static const char *storageTypes[HDFS_STORAGE_TYPES] = {"DISK", "SSD", "ARCHIVE", "RAM_DISK", "PROVIDED", "NVDIMM"};
+
+/* storageType returns a local reference to the StorageType constant name,
+ * or NULL, with no exception pending, if the cluster does not know it. */
+static jobject storageType(JNIEnv *env, const char *name)
+{
+    jclass cls;
+    jfieldID fid;
+    jobject t = NULL;
+
+    cls = (*env)->FindClass(env, STORAGE);
+    if (cls == NULL) {
+        return NULL;
+    }
+    fid = (*env)->GetStaticFieldID(env, cls, name, SIG_TYPE);
+    if (fid != NULL) {
+        t = (*env)->GetStaticObjectField(env, cls, fid);
+    } else {
+        (*env)->ExceptionClear(env);
+    }
+    (*env)->DeleteLocalRef(env, cls);
+    return t;
+}
+
+/* callLong returns what the long method name of obj returns, or -1. */
+static tOffset callLong(JNIEnv *env, jobject obj, const char *name)
+{
+    jclass cls;
+    jmethodID mid;
+
+    cls = (*env)->GetObjectClass(env, obj);
+    mid = (*env)->GetMethodID(env, cls, name, "()J");
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL) {
+        return -1;
+    }
+    return (*env)->CallLongMethod(env, obj, mid);
+}
+
+int hdfsFsGetContentSummary(hdfsFS fs, const char *path, hdfsContentSummary *summary)
+{
+    JNIEnv *env;
+    jclass cls = NULL;
+    jmethodID mid, getTypeQuota, getTypeConsumed;
+    jobject p = NULL, cs = NULL, t;
+    int i, ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getContentSummary", "(" SIG_PATH ")L" SUMMARY ";");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) == NULL) {
+        goto done;
+    }
+    cs = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+    if ((*env)->ExceptionCheck(env) || cs == NULL) {
+        goto done;
+    }
+    summary->length = callLong(env, cs, "getLength");
+    summary->fileCount = callLong(env, cs, "getFileCount");
+    summary->directoryCount = callLong(env, cs, "getDirectoryCount");
+    summary->quota = callLong(env, cs, "getQuota");
+    summary->spaceConsumed = callLong(env, cs, "getSpaceConsumed");
+    summary->spaceQuota = callLong(env, cs, "getSpaceQuota");
+    if ((*env)->ExceptionCheck(env)) {
+        goto done;
+    }
+    cls = (*env)->GetObjectClass(env, cs);
+    getTypeQuota = (*env)->GetMethodID(env, cls, "getTypeQuota", "(" SIG_TYPE ")J");
+    getTypeConsumed = (*env)->GetMethodID(env, cls, "getTypeConsumed", "(" SIG_TYPE ")J");
+    if (getTypeQuota == NULL || getTypeConsumed == NULL) {
+        goto done;
+    }
+    for (i = 0; i < HDFS_STORAGE_TYPES; i++) {
+        summary->typeQuota[i] = -1;
+        summary->typeConsumed[i] = 0;
+        if ((t = storageType(env, storageTypes[i])) == NULL) {
+            if ((*env)->ExceptionCheck(env)) {
+                goto done;
+            }
+            continue;
+        }
+        summary->typeQuota[i] = (*env)->CallLongMethod(env, cs, getTypeQuota, t);
+        summary->typeConsumed[i] = (*env)->CallLongMethod(env, cs, getTypeConsumed, t);
+        (*env)->DeleteLocalRef(env, t);
+        if ((*env)->ExceptionCheck(env)) {
+            goto done;
+        }
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    if (cls != NULL) {
+        (*env)->DeleteLocalRef(env, cls);
+    }
+    if (cs != NULL) {
+        (*env)->DeleteLocalRef(env, cs);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+int hdfsFsRemove(hdfsFS fs, const char *path)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p;
+    jboolean ret = 0;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "delete", "(" SIG_PATH "Z)Z");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL) {
+        ret = (*env)->CallBooleanMethod(env, (jobject)fs, mid, p, (jboolean)0);
+        (*env)->DeleteLocalRef(env, p);
+    }
+    if (p == NULL || (*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    if (!ret) {
+        errno = ENOENT; /* delete returns false for a missing path */
+        return -1;
+    }
+    return 0;
+}
+
+int hdfsFsSetQuota(hdfsFS fs, const char *path, tOffset nsQuota, tOffset ssQuota)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "setQuota", "(" SIG_PATH "JJ)V");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL) {
+        (*env)->CallVoidMethod(env, (jobject)fs, mid, p, (jlong)nsQuota, (jlong)ssQuota);
+        (*env)->DeleteLocalRef(env, p);
+    }
+    if (p == NULL || (*env)->ExceptionCheck(env)) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    return 0;
+}
+
+int hdfsFsSetTypeQuota(hdfsFS fs, const char *path, const char *type, tOffset quota)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p = NULL, t;
+    int ret = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "setQuotaByStorageType", "(" SIG_PATH SIG_TYPE "J)V");
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((t = storageType(env, type)) == NULL) {
+        errno = (*env)->ExceptionCheck(env) ? exceptionErrno(env) : EINVAL;
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL) {
+        (*env)->CallVoidMethod(env, (jobject)fs, mid, p, t, (jlong)quota);
+        if (!(*env)->ExceptionCheck(env)) {
+            ret = 0;
+        }
+        (*env)->DeleteLocalRef(env, p);
+    }
+    if (ret != 0) {
+        errno = exceptionErrno(env);
+    }
+    (*env)->DeleteLocalRef(env, t);
+    return ret;
+}
+
+int hdfsFsGetTrashRoot(hdfsFS fs, const char *path, char **root)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject p, ret = NULL;
+
+    *root = NULL;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getTrashRoot", "(" SIG_PATH ")" SIG_PATH);
+    if (mid == NULL) {
+        return -1;
+    }
+    if ((p = newPath(env, path)) != NULL) {
+        ret = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+        (*env)->DeleteLocalRef(env, p);
+    }
+    if (ret != NULL && !(*env)->ExceptionCheck(env)) {
+        *root = uriPath(env, ret);
+    }
+    if (*root == NULL) {
+        errno = (*env)->ExceptionCheck(env) || ret == NULL ? exceptionErrno(env) : ENOMEM;
+    }
+    if (ret != NULL) {
+        (*env)->DeleteLocalRef(env, ret);
+    }
+    return *root != NULL ? 0 : -1;
+}
+
+int hdfsFsGetTrashRoots(hdfsFS fs, char ***roots, int *count)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid, getPath = NULL;
+    jobject coll, arr = NULL, st, p;
+    int i, ret = -1;
+
+    *roots = NULL;
+    *count = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getTrashRoots", "(Z)" SIG_COLL);
+    if (mid == NULL) {
+        return -1;
+    }
+    coll = (*env)->CallObjectMethod(env, (jobject)fs, mid, (jboolean)0);
+    if ((*env)->ExceptionCheck(env) || coll == NULL) {
+        errno = exceptionErrno(env);
+        return -1;
+    }
+    cls = (*env)->GetObjectClass(env, coll);
+    mid = (*env)->GetMethodID(env, cls, "toArray", "()[Ljava/lang/Object;");
+    (*env)->DeleteLocalRef(env, cls);
+    if (mid == NULL || (arr = (*env)->CallObjectMethod(env, coll, mid)) == NULL) {
+        goto done;
+    }
+    *count = (*env)->GetArrayLength(env, arr);
+    *roots = calloc(*count > 0 ? *count : 1, sizeof(char *));
+    if (*roots == NULL) {
+        goto done;
+    }
+    for (i = 0; i < *count; i++) {
+        st = (*env)->GetObjectArrayElement(env, arr, i);
+        if (st == NULL) {
+            goto done;
+        }
+        if (getPath == NULL) {
+            cls = (*env)->GetObjectClass(env, st);
+            getPath = (*env)->GetMethodID(env, cls, "getPath", "()" SIG_PATH);
+            (*env)->DeleteLocalRef(env, cls);
+        }
+        p = getPath != NULL ? (*env)->CallObjectMethod(env, st, getPath) : NULL;
+        if (p != NULL) {
+            (*roots)[i] = uriPath(env, p);
+            (*env)->DeleteLocalRef(env, p);
+        }
+        (*env)->DeleteLocalRef(env, st);
+        if ((*roots)[i] == NULL) {
+            goto done;
+        }
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        if (*roots != NULL) {
+            hdfsFsFreeTrashRoots(*roots, *count);
+        }
+        *roots = NULL;
+        *count = 0;
+        errno = (*env)->ExceptionCheck(env) ? exceptionErrno(env) : ENOMEM;
+    }
+    if (arr != NULL) {
+        (*env)->DeleteLocalRef(env, arr);
+    }
+    (*env)->DeleteLocalRef(env, coll);
+    return ret;
+}
+
+void hdfsFsFreeTrashRoots(char **roots, int count)
+{
+    int i;
+
+    for (i = 0; i < count; i++) {
+        free(roots[i]);
+    }
+    free(roots);
+}
+
+int hdfsFsGetTrashInterval(hdfsFS fs, tOffset *interval)
+{
+    JNIEnv *env;
+    jclass cls;
+    jmethodID mid;
+    jobject p = NULL, defaults = NULL, conf = NULL;
+    jstring key = NULL;
+    jfloat minutes;
+    int ret = -1;
+
+    *interval = 0;
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getServerDefaults", "(" SIG_PATH ")L" FS_DEFAULTS ";");
+    if (mid == NULL || (p = newPath(env, "/")) == NULL) {
+        goto done;
+    }
+    defaults = (*env)->CallObjectMethod(env, (jobject)fs, mid, p);
+    if ((*env)->ExceptionCheck(env) || defaults == NULL) {
+        goto done;
+    }
+    /* in minutes */
+    *interval = callLong(env, defaults, "getTrashInterval") * 60000;
+    if ((*env)->ExceptionCheck(env)) {
+        goto done;
+    }
+    if (*interval == 0) {
+        mid = fsMethod(env, fs, "getConf", "()" SIG_CONF);
+        if (mid == NULL || (conf = (*env)->CallObjectMethod(env, (jobject)fs, mid)) == NULL) {
+            goto done;
+        }
+        cls = (*env)->GetObjectClass(env, conf);
+        mid = (*env)->GetMethodID(env, cls, "getFloat", "(" SIG_STR "F)F");
+        (*env)->DeleteLocalRef(env, cls);
+        if (mid == NULL || (key = newString(env, "fs.trash.interval")) == NULL) {
+            goto done;
+        }
+        minutes = (*env)->CallFloatMethod(env, conf, mid, key, (jfloat)0);
+        if ((*env)->ExceptionCheck(env)) {
+            goto done;
+        }
+        *interval = (tOffset)(minutes * 60000);
+    }
+    ret = 0;
+
+done:
+    if (ret != 0) {
+        *interval = 0;
+        if ((*env)->ExceptionCheck(env)) {
+            errno = exceptionErrno(env);
+        } else if (mid != NULL) {
+            errno = EINTERNAL; /* a method returned null */
+        }
+    }
+    if (key != NULL) {
+        (*env)->DeleteLocalRef(env, key);
+    }
+    if (conf != NULL) {
+        (*env)->DeleteLocalRef(env, conf);
+    }
+    if (defaults != NULL) {
+        (*env)->DeleteLocalRef(env, defaults);
+    }
+    if (p != NULL) {
+        (*env)->DeleteLocalRef(env, p);
+    }
+    return ret;
+}
+
+tOffset hdfsFsGetRemaining(hdfsFS fs)
+{
+    JNIEnv *env;
+    jmethodID mid;
+    jobject status = NULL;
+    tOffset remaining = -1;
+
+    env = getJNIEnv();
+    if (env == NULL) {
+        errno = EINTERNAL;
+        return -1;
+    }
+    mid = fsMethod(env, fs, "getStatus", "()L" FS_STATUS ";");
+    if (mid == NULL) {
+        return -1;
+    }
+    status = (*env)->CallObjectMethod(env, (jobject)fs, mid);
+    if ((*env)->ExceptionCheck(env) || status == NULL) {
+        goto done;
+    }
+    remaining = callLong(env, status, "getRemaining");
+    if ((*env)->ExceptionCheck(env)) {
+        remaining = -1;
+    }
+
+done:
+    if (remaining == -1) {
+        if ((*env)->ExceptionCheck(env)) {
+            errno = exceptionErrno(env);
+        } else {
+            errno = EINTERNAL; /* getStatus returned null */
+        }
+    }
+    if (status != NULL) {
+        (*env)->DeleteLocalRef(env, status);
+    }
+    return remaining;
+}
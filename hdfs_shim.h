@@ -0,0 +1,419 @@
+#ifndef LIBHDFS_SHIM_H
+#define LIBHDFS_SHIM_H
+
+#include "hdfs.h"
+
+#ifdef __cplusplus
+extern  "C" {
+#endif
+
+    /**
+     * hdfsConnectWithConf - Connect to a hdfs file system with a
+     * Configuration built from the given resources and overrides,
+     * which libhdfs 1.x does not expose.
+     * @param uri The URI of the file system, e.g. "hdfs://nn:8020" or
+     * "file:///"; NULL connects to the configured fs.default.name.
+     * @param user The user name, or NULL for the current user.
+     * @param resources Paths of XML files added to the Configuration in
+     * order, as with Configuration.addResource(Path).
+     * @param nresources The number of resources.
+     * @param keys Names of the properties to set, after the resources.
+     * @param values Values of the properties to set.
+     * @param nkeys The number of properties.
+     * @return Returns a handle to the filesystem or NULL on error, setting
+     * errno to EINTERNAL if a Java exception was thrown.
+     */
+    hdfsFS hdfsConnectWithConf(const char *uri, const char *user,
+                               const char **resources, int nresources,
+                               const char **keys, const char **values, int nkeys);
+
+    /**
+     * hdfsFileHFlush - Flush the data written so far to every datanode of
+     * the pipeline, making it visible to new readers, as
+     * FSDataOutputStream.hflush() does; on Hadoop 1, sync() is called.
+     * @param fs The configured filesystem handle.
+     * @param file The file handle, open for writing.
+     * @return Returns 0 on success, -1 on error, setting errno to EBADF if
+     * the file is not open for writing and to EINTERNAL if a Java exception
+     * was thrown.
+     */
+    int hdfsFileHFlush(hdfsFS fs, hdfsFile file);
+
+    /**
+     * hdfsFileHSync - Flush the data written so far, as hdfsFileHFlush does,
+     * and have the datanodes persist it to their disks, as
+     * FSDataOutputStream.hsync() does.
+     * @param fs The configured filesystem handle.
+     * @param file The file handle, open for writing.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFileHFlush does, or to ENOTSUP if the Hadoop client has no hsync.
+     */
+    int hdfsFileHSync(hdfsFS fs, hdfsFile file);
+
+    /**
+     * hdfsFsTruncate - Truncate a file, as FileSystem.truncate() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the file.
+     * @param newLength The size to cut the file to.
+     * @return Returns 1 if the file is truncated, 0 if its last block is
+     * being recovered, and -1 on error, setting errno from the Java
+     * exception thrown, or to ENOTSUP if the Hadoop client has no truncate.
+     */
+    int hdfsFsTruncate(hdfsFS fs, const char *path, tOffset newLength);
+
+    /**
+     * hdfsFsIsFileClosed - Tell whether a file is closed, as
+     * DistributedFileSystem.isFileClosed() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the file.
+     * @return Returns 1 if the file is closed, 0 if not, and -1 on error,
+     * setting errno as hdfsFsTruncate does.
+     */
+    int hdfsFsIsFileClosed(hdfsFS fs, const char *path);
+
+    /**
+     * hdfsFsConcat - Move the blocks of files to the end of another and
+     * delete them, as FileSystem.concat() does.
+     * @param fs The configured filesystem handle.
+     * @param target The path of the file to append to.
+     * @param srcs The paths of the files to append, in order.
+     * @param nsrcs The number of files to append.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsConcat(hdfsFS fs, const char *target, const char **srcs, int nsrcs);
+
+    /**
+     * hdfsFsGetFileChecksum - Get the checksum of the content of a file,
+     * as FileSystem.getFileChecksum() does, in the mode set by
+     * dfs.checksum.combine.mode.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the file.
+     * @param algorithm Set to the name of the algorithm, to be freed with
+     * free().
+     * @param bytes Set to the bytes of the checksum, to be freed with
+     * free().
+     * @param length Set to the number of bytes of the checksum.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, or to ENOTSUP if the filesystem has no
+     * checksums.
+     */
+    int hdfsFsGetFileChecksum(hdfsFS fs, const char *path, char **algorithm, char **bytes, int *length);
+
+    /**
+     * hdfsFsSetXAttr - Set an extended attribute of a path, as
+     * FileSystem.setXAttr() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param name The name of the attribute, prefixed with its namespace.
+     * @param value The value of the attribute.
+     * @param size The number of bytes of the value.
+     * @param flags XATTR_CREATE (1), XATTR_REPLACE (2), or both.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, to EEXIST if the attribute exists without
+     * XATTR_REPLACE and to ENODATA if it is missing without XATTR_CREATE.
+     */
+    int hdfsFsSetXAttr(hdfsFS fs, const char *path, const char *name, const char *value, int size, int flags);
+
+    /**
+     * hdfsFsGetXAttr - Get an extended attribute of a path, as
+     * FileSystem.getXAttr() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param name The name of the attribute.
+     * @param value Set to the value, to be freed with free().
+     * @param size Set to the number of bytes of the value.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, or to ENODATA if the attribute is missing.
+     */
+    int hdfsFsGetXAttr(hdfsFS fs, const char *path, const char *name, char **value, int *size);
+
+    /**
+     * hdfsFsListXAttrs - List the names of the extended attributes of a
+     * path, as FileSystem.listXAttrs() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param names Set to the names, to be freed with hdfsFsFreeXAttrNames.
+     * @param count Set to the number of names.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsListXAttrs(hdfsFS fs, const char *path, char ***names, int *count);
+
+    /**
+     * hdfsFsFreeXAttrNames - Free the names returned by hdfsFsListXAttrs.
+     * @param names The names.
+     * @param count The number of names.
+     */
+    void hdfsFsFreeXAttrNames(char **names, int count);
+
+    /**
+     * hdfsFsRemoveXAttr - Remove an extended attribute of a path, as
+     * FileSystem.removeXAttr() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param name The name of the attribute.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsGetXAttr does.
+     */
+    int hdfsFsRemoveXAttr(hdfsFS fs, const char *path, const char *name);
+
+    /**
+     * hdfsFsGetAclStatus - Get the ACL of a path, as
+     * FileSystem.getAclStatus() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param owner Set to the owner of the path, to be freed with free().
+     * @param group Set to the group of the path, to be freed with free().
+     * @param sticky Set to 1 if the sticky bit is set, 0 if not.
+     * @param perm Set to the permission of the path, whose group bits are
+     * the mask of an ACL with access entries, or to -1 if the Hadoop client
+     * does not report it.
+     * @param spec Set to the entries the permission does not hold, as a
+     * comma-separated ACL spec, to be freed with free().
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, or to EINVAL if the namenode rejects ACLs.
+     */
+    int hdfsFsGetAclStatus(hdfsFS fs, const char *path, char **owner, char **group, int *sticky, int *perm, char **spec);
+
+    /**
+     * hdfsFsModifyAcl - Change the ACL of a path with the entries of an ACL
+     * spec, parsed by AclEntry.parseAclSpec().
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param method "setAcl", "modifyAclEntries" or "removeAclEntries", the
+     * FileSystem method called; the latter ignores the permissions of the
+     * entries.
+     * @param spec The ACL spec, with permissions.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsGetAclStatus does, or to EINVAL if the ACL is invalid.
+     */
+    int hdfsFsModifyAcl(hdfsFS fs, const char *path, const char *method, const char *spec);
+
+    /**
+     * hdfsFsRemoveAcl - Remove entries of the ACL of a path.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @param method "removeDefaultAcl" or "removeAcl", the FileSystem
+     * method called.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsModifyAcl does.
+     */
+    int hdfsFsRemoveAcl(hdfsFS fs, const char *path, const char *method);
+
+    /**
+     * hdfsFsSnapshot - Call a void snapshot method of
+     * DistributedFileSystem on a directory.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the directory.
+     * @param method "allowSnapshot" or "disallowSnapshot", called with the
+     * path only, "deleteSnapshot", also given name, or "renameSnapshot",
+     * also given name and newName.
+     * @param name The name of the snapshot, or NULL.
+     * @param newName The new name of the snapshot, or NULL.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, or to ENOTSUP if the filesystem has no
+     * snapshots.
+     */
+    int hdfsFsSnapshot(hdfsFS fs, const char *path, const char *method, const char *name, const char *newName);
+
+    /**
+     * hdfsFsCreateSnapshot - Take a snapshot of a directory.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the directory.
+     * @param name The name of the snapshot, or NULL for the namenode to
+     * name it after the current time.
+     * @param snapshotPath Set to the path of the snapshot, to be freed with
+     * free().
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsSnapshot does.
+     */
+    int hdfsFsCreateSnapshot(hdfsFS fs, const char *path, const char *name, char **snapshotPath);
+
+    /**
+     * hdfsSnapshottableDir - A directory snapshots are allowed on.
+     */
+    typedef struct {
+        char *path;    /* the full path of the directory */
+        int snapshots; /* the number of its snapshots */
+        int quota;     /* the number of snapshots it may hold */
+    } hdfsSnapshottableDir;
+
+    /**
+     * hdfsFsListSnapshottableDirs - List the directories snapshots are
+     * allowed on, as DistributedFileSystem.getSnapshottableDirListing()
+     * does.
+     * @param fs The configured filesystem handle.
+     * @param dirs Set to the directories, to be freed with
+     * hdfsFsFreeSnapshottableDirs().
+     * @param count Set to the number of directories.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsSnapshot does.
+     */
+    int hdfsFsListSnapshottableDirs(hdfsFS fs, hdfsSnapshottableDir **dirs, int *count);
+
+    /**
+     * hdfsFsFreeSnapshottableDirs - Free the directories
+     * hdfsFsListSnapshottableDirs returns.
+     * @param dirs The directories.
+     * @param count The number of directories.
+     */
+    void hdfsFsFreeSnapshottableDirs(hdfsSnapshottableDir *dirs, int count);
+
+    /**
+     * hdfsSnapshotDiffEntry - A change between two snapshots.
+     */
+    typedef struct {
+        char type;    /* the label of the change: '+', 'M', '-' or 'R' */
+        char *source; /* the path relative to the directory, "" for itself */
+        char *target; /* the new path of a renamed entry, else NULL */
+    } hdfsSnapshotDiffEntry;
+
+    /**
+     * hdfsFsGetSnapshotDiff - Get the changes made to a directory between
+     * two of its snapshots, as
+     * DistributedFileSystem.getSnapshotDiffReport() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the directory.
+     * @param from The name of the earlier snapshot, "" for the current state.
+     * @param to The name of the later snapshot, "" for the current state.
+     * @param entries Set to the changes, to be freed with
+     * hdfsFsFreeSnapshotDiff().
+     * @param count Set to the number of changes.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsSnapshot does.
+     */
+    int hdfsFsGetSnapshotDiff(hdfsFS fs, const char *path, const char *from, const char *to, hdfsSnapshotDiffEntry **entries, int *count);
+
+    /**
+     * hdfsFsFreeSnapshotDiff - Free the changes hdfsFsGetSnapshotDiff
+     * returns.
+     * @param entries The changes.
+     * @param count The number of changes.
+     */
+    void hdfsFsFreeSnapshotDiff(hdfsSnapshotDiffEntry *entries, int count);
+
+    /* The storage types, in the order of the StorageType enum. */
+#define HDFS_STORAGE_TYPES 6
+
+    /**
+     * hdfsContentSummary - The disk usage and quotas of a tree.
+     */
+    typedef struct {
+        tOffset length;         /* bytes of data, not counting replication */
+        tOffset fileCount;
+        tOffset directoryCount; /* including the directory itself */
+        tOffset quota;          /* namespace quota, -1 if not set */
+        tOffset spaceConsumed;  /* bytes of data times replication */
+        tOffset spaceQuota;     /* -1 if not set */
+        tOffset typeQuota[HDFS_STORAGE_TYPES];    /* -1 if not set */
+        tOffset typeConsumed[HDFS_STORAGE_TYPES];
+    } hdfsContentSummary;
+
+    /**
+     * hdfsFsGetContentSummary - Get the disk usage and quotas of the tree
+     * rooted at a path, as FileSystem.getContentSummary() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the tree.
+     * @param summary Set to the summary. The storage types the cluster
+     * does not know of have no quota.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsGetContentSummary(hdfsFS fs, const char *path, hdfsContentSummary *summary);
+
+    /**
+     * hdfsFsRemove - Delete a file or an empty directory, as
+     * FileSystem.delete(path, false) does, which libhdfs 1.x does not
+     * expose: its hdfsDelete is recursive.
+     * @param fs The configured filesystem handle.
+     * @param path The path.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, to ENOENT if the path does not exist and to
+     * ENOTEMPTY if it is a directory that is not empty.
+     */
+    int hdfsFsRemove(hdfsFS fs, const char *path);
+
+    /**
+     * hdfsFsSetQuota - Set the namespace and space quotas of a directory,
+     * as DistributedFileSystem.setQuota() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the directory.
+     * @param nsQuota The namespace quota, -1 to clear it, or LLONG_MAX to
+     * leave it as it is.
+     * @param ssQuota The space quota, as nsQuota.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does, or to ENOTSUP if the filesystem has no quotas.
+     */
+    int hdfsFsSetQuota(hdfsFS fs, const char *path, tOffset nsQuota, tOffset ssQuota);
+
+    /**
+     * hdfsFsSetTypeQuota - Set the space quota of a directory on a storage
+     * type, as DistributedFileSystem.setQuotaByStorageType() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path of the directory.
+     * @param type The name of the storage type, such as "SSD".
+     * @param quota The space quota, as hdfsFsSetQuota takes it.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsSetQuota does, or to EINVAL if the storage type is unknown.
+     */
+    int hdfsFsSetTypeQuota(hdfsFS fs, const char *path, const char *type, tOffset quota);
+
+    /**
+     * hdfsFsGetTrashRoot - Get the trash directory of the user for a path,
+     * as FileSystem.getTrashRoot() does.
+     * @param fs The configured filesystem handle.
+     * @param path The path to be moved to the trash.
+     * @param root Set to the path of the trash directory, to be freed with
+     * free().
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsGetTrashRoot(hdfsFS fs, const char *path, char **root);
+
+    /**
+     * hdfsFsGetTrashRoots - List the trash directories of the user that
+     * exist, as FileSystem.getTrashRoots(false) does.
+     * @param fs The configured filesystem handle.
+     * @param roots Set to the paths of the directories, to be freed with
+     * hdfsFsFreeTrashRoots.
+     * @param count Set to the number of directories.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsGetTrashRoots(hdfsFS fs, char ***roots, int *count);
+
+    /**
+     * hdfsFsFreeTrashRoots - Free the paths returned by hdfsFsGetTrashRoots.
+     * @param roots The paths.
+     * @param count The number of paths.
+     */
+    void hdfsFsFreeTrashRoots(char **roots, int count);
+
+    /**
+     * hdfsFsGetTrashInterval - Get how long files stay in the trash, as
+     * Trash.moveToAppropriateTrash() tells: fs.trash.interval of the
+     * server defaults unless 0, else of the configuration of fs.
+     * @param fs The configured filesystem handle.
+     * @param interval Set to the interval in milliseconds, 0 if the trash
+     * is disabled.
+     * @return Returns 0 on success, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    int hdfsFsGetTrashInterval(hdfsFS fs, tOffset *interval);
+
+    /**
+     * hdfsFsGetRemaining - Get the raw space the datanodes have left, as
+     * FileSystem.getStatus().getRemaining() tells; unlike capacity less
+     * used, it does not count the space used outside the filesystem.
+     * @param fs The configured filesystem handle.
+     * @return Returns the remaining space, -1 on error, setting errno as
+     * hdfsFsTruncate does.
+     */
+    tOffset hdfsFsGetRemaining(hdfsFS fs);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /*LIBHDFS_SHIM_H*/
@@ -1,8 +1,14 @@
+//go:build cgo && !purego
+
 package hdfs
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"syscall"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -504,6 +510,108 @@ func TestOnUser2(t *testing.T) {
 	}
 }
 
+func TestNotExist(t *testing.T) {
+	c, err := Connect(server, ssport)
+	if err != nil {
+		t.Errorf("Error on connecting to hdfs: %v\n", err)
+		return
+	}
+	defer c.Disconnect()
+
+	missing := "/tmp/go-no-such-file"
+	if err = c.Exists(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Exists on missing path: got %v, want ErrNotExist\n", err)
+	}
+	if _, err = c.GetPathInfo(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("GetPathInfo on missing path: got %v, want ErrNotExist\n", err)
+	}
+	if _, err = c.OpenFile(missing, O_RDONLY, 0, 0, 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenFile on missing path: got %v, want ErrNotExist\n", err)
+	}
+	if _, err = c.OpenFile(missing, syscall.O_RDWR, 0, 0, 0); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("OpenFile with O_RDWR: got %v, want ErrUnsupported\n", err)
+	}
+}
+
+func TestIOFS(t *testing.T) {
+	root := "/tmp/goiofs"
+	files := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"}
+
+	err := func() error {
+		c, err := Connect(server, ssport)
+		if err != nil {
+			return fmt.Errorf("Error on connecting to hdfs: %v\n", err)
+		}
+		defer c.Disconnect()
+		defer c.Delete(root)
+
+		for _, name := range files {
+			file, err := c.OpenFile(root+"/"+name, O_WRONLY|O_CREATE, 0, 0, 0)
+			if err != nil {
+				return fmt.Errorf("Error on creating %s: %v\n", name, err)
+			}
+			if _, err = file.Write([]byte("content of " + name)); err != nil {
+				return fmt.Errorf("Error on writing %s: %v\n", name, err)
+			}
+			if err = file.Close(); err != nil {
+				return fmt.Errorf("Error on closing %s: %v\n", name, err)
+			}
+		}
+
+		fsys, err := fs.Sub(FS(c), root[1:])
+		if err != nil {
+			return fmt.Errorf("Error on fs.Sub: %v\n", err)
+		}
+		if err = fstest.TestFS(fsys, files...); err != nil {
+			return fmt.Errorf("fstest.TestFS: %v\n", err)
+		}
+		matches, err := fs.Glob(fsys, "dir/*/*.txt")
+		if err != nil || len(matches) != 1 || matches[0] != "dir/sub/c.txt" {
+			return fmt.Errorf("Unexpected glob result: %v %v\n", matches, err)
+		}
+		return nil
+	}()
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func TestConnectWithOptions(t *testing.T) {
+	tuser := "nobody"
+	writePath := "/tmp/gooptionstextfile.txt"
+	err := func() error {
+		fs, err := ConnectWithOptions(WithNamenode(fmt.Sprintf("%s:%d", server, ssport)), WithUser(tuser), WithSetting("dfs.replication", "2"))
+		if err != nil {
+			return fmt.Errorf("Error on connecting with options: %v\n", err)
+		}
+		defer fs.Disconnect()
+		file, err := fs.OpenFile(writePath, O_WRONLY|O_CREATE, 0, 0, 0)
+		if err != nil {
+			return fmt.Errorf("Error on opening file for writing: %v\n", err)
+		}
+		if err = file.Close(); err != nil {
+			return fmt.Errorf("Error on closing file: %v\n", err)
+		}
+		defer fs.Delete(writePath)
+
+		info, err := fs.GetPathInfo(writePath)
+		if err != nil {
+			return fmt.Errorf("Error on getting path info: %v\n", err)
+		}
+		if info.Owner != tuser || info.Replication != 2 {
+			return fmt.Errorf("HDFS new file has owner %s and replication %d, want %s and 2\n", info.Owner, info.Replication, tuser)
+		}
+		return nil
+	}()
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	if _, err = ConnectWithOptions(WithNamenode("hdfs:///")); err == nil {
+		t.Errorf("ConnectWithOptions with no namenode host: no error\n")
+	}
+}
+
 func TestCleanup(t *testing.T) {
 	fs, err := Connect(server, ssport)
 	if err != nil {
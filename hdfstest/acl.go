@@ -0,0 +1,387 @@
+package hdfstest
+
+import (
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// ACLs are kept as HDFS keeps them: the permission bits of a node hold the
+// entries of the owner and others, and the group entry or, if the node has
+// other access entries, the mask; the acl of the node holds the rest. The
+// changes to ACLs follow AclTransformation of the namenode.
+
+// aclKey identifies the entries an ACL holds at most one of.
+type aclKey struct {
+	scope hdfs.AclScope
+	typ   hdfs.AclType
+	name  string
+}
+
+func keyOf(e hdfs.AclEntry) aclKey {
+	return aclKey{e.Scope, e.Type, e.Name}
+}
+
+func (n *node) aclStatus() *hdfs.AclStatus {
+	return &hdfs.AclStatus{
+		Owner:      n.owner,
+		Group:      n.group,
+		Sticky:     n.perm&01000 != 0,
+		Permission: n.perm,
+		Entries:    append([]hdfs.AclEntry(nil), n.acl...),
+	}
+}
+
+// hasAccessAcl reports whether the access of n is checked against its ACL
+// rather than its permission bits alone.
+func (n *node) hasAccessAcl() bool {
+	return len(n.acl) > 0 && n.acl[0].Scope == hdfs.AclAccess
+}
+
+// setAcl stores the whole ACL acl, as built by buildAcl, in n.
+func (n *node) setAcl(acl []hdfs.AclEntry) {
+	var access, defaults []hdfs.AclEntry
+	perm := n.perm & 01000
+	group, mask := int16(0), int16(-1)
+	for _, e := range acl {
+		switch {
+		case e.Scope == hdfs.AclDefault:
+			defaults = append(defaults, e)
+		case e.Type == hdfs.AclUser && e.Name == "":
+			perm |= e.Perm << 6
+		case e.Type == hdfs.AclOther:
+			perm |= e.Perm
+		case e.Type == hdfs.AclMask:
+			mask = e.Perm
+		default:
+			if e.Type == hdfs.AclGroup && e.Name == "" {
+				group = e.Perm
+			}
+			access = append(access, e)
+		}
+	}
+	if mask < 0 {
+		perm |= group << 3
+		access = nil
+	} else {
+		perm |= mask << 3
+	}
+	n.perm, n.acl = perm, append(access, defaults...)
+	if len(n.acl) == 0 {
+		n.acl = nil
+	}
+}
+
+// buildAcl completes the entries of acl with the default entries of the
+// owner, group and others copied from the access ones, and the masks, as
+// calculateMasks of the namenode does: provided holds the masks given or
+// kept of every scope, dirty the scopes changed and maskDirty those whose
+// mask was given or removed. The ACL is sorted and validated.
+func buildAcl(acl []hdfs.AclEntry, provided map[hdfs.AclScope]hdfs.AclEntry, dirty, maskDirty map[hdfs.AclScope]bool) ([]hdfs.AclEntry, error) {
+	hdfs.SortAcl(acl)
+	found := map[aclKey]int16{}
+	scopes := map[hdfs.AclScope]bool{}
+	for _, e := range acl {
+		found[keyOf(e)] = e.Perm
+		scopes[e.Scope] = true
+	}
+	if scopes[hdfs.AclDefault] {
+		for _, t := range []hdfs.AclType{hdfs.AclUser, hdfs.AclGroup, hdfs.AclOther} {
+			perm, ok := found[aclKey{hdfs.AclAccess, t, ""}]
+			if _, exists := found[aclKey{hdfs.AclDefault, t, ""}]; !exists && ok {
+				acl = append(acl, hdfs.AclEntry{Scope: hdfs.AclDefault, Type: t, Perm: perm})
+			}
+		}
+	}
+	union := map[hdfs.AclScope]int16{}
+	needed := map[hdfs.AclScope]bool{}
+	for _, e := range acl {
+		if e.Type == hdfs.AclGroup || e.Name != "" {
+			union[e.Scope] |= e.Perm
+		}
+		if e.Name != "" {
+			needed[e.Scope] = true
+		}
+	}
+	for scope := range scopes {
+		mask, ok := provided[scope]
+		switch {
+		case !ok && needed[scope] && maskDirty[scope]:
+			return nil, syscall.EINVAL // the mask is needed and cannot be removed
+		case ok && (!dirty[scope] || maskDirty[scope]):
+			acl = append(acl, mask)
+		case ok || needed[scope]:
+			acl = append(acl, hdfs.AclEntry{Scope: scope, Type: hdfs.AclMask, Perm: union[scope]})
+		}
+	}
+	hdfs.SortAcl(acl)
+	seen := map[aclKey]bool{}
+	base := map[aclKey]bool{}
+	for _, e := range acl {
+		k := keyOf(e)
+		if seen[k] || e.Name != "" && (e.Type == hdfs.AclMask || e.Type == hdfs.AclOther) || e.Perm&^7 != 0 {
+			return nil, syscall.EINVAL
+		}
+		seen[k] = true
+		if e.Name == "" {
+			base[k] = true
+		}
+	}
+	for scope := range scopes {
+		for _, t := range []hdfs.AclType{hdfs.AclUser, hdfs.AclGroup, hdfs.AclOther} {
+			if !base[aclKey{scope, t, ""}] {
+				return nil, syscall.EINVAL
+			}
+		}
+	}
+	return acl, nil
+}
+
+// specKeys returns the keys of the entries of spec, failing with EINVAL if
+// it holds one twice.
+func specKeys(spec []hdfs.AclEntry) (map[aclKey]hdfs.AclEntry, error) {
+	keys := make(map[aclKey]hdfs.AclEntry, len(spec))
+	for _, e := range spec {
+		if _, ok := keys[keyOf(e)]; ok {
+			return nil, syscall.EINVAL
+		}
+		keys[keyOf(e)] = e
+	}
+	return keys, nil
+}
+
+func replaceAcl(existing, spec []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+	if _, err := specKeys(spec); err != nil {
+		return nil, err
+	}
+	var acl []hdfs.AclEntry
+	provided := map[hdfs.AclScope]hdfs.AclEntry{}
+	dirty, maskDirty := map[hdfs.AclScope]bool{}, map[hdfs.AclScope]bool{}
+	for _, e := range spec {
+		dirty[e.Scope] = true
+		if e.Type == hdfs.AclMask {
+			provided[e.Scope], maskDirty[e.Scope] = e, true
+		} else {
+			acl = append(acl, e)
+		}
+	}
+	for _, e := range existing {
+		if dirty[e.Scope] {
+			continue
+		}
+		if e.Type == hdfs.AclMask {
+			provided[e.Scope] = e
+		} else {
+			acl = append(acl, e)
+		}
+	}
+	return buildAcl(acl, provided, dirty, maskDirty)
+}
+
+func mergeAcl(existing, spec []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+	keys, err := specKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	var acl []hdfs.AclEntry
+	provided := map[hdfs.AclScope]hdfs.AclEntry{}
+	dirty, maskDirty := map[hdfs.AclScope]bool{}, map[hdfs.AclScope]bool{}
+	for _, e := range existing {
+		if _, ok := keys[keyOf(e)]; !ok && e.Type == hdfs.AclMask {
+			provided[e.Scope] = e
+		} else if !ok {
+			acl = append(acl, e)
+		}
+	}
+	for _, e := range spec {
+		dirty[e.Scope] = true
+		if e.Type == hdfs.AclMask {
+			provided[e.Scope], maskDirty[e.Scope] = e, true
+		} else {
+			acl = append(acl, e)
+		}
+	}
+	return buildAcl(acl, provided, dirty, maskDirty)
+}
+
+func filterAcl(existing, spec []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+	keys, err := specKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	var acl []hdfs.AclEntry
+	provided := map[hdfs.AclScope]hdfs.AclEntry{}
+	dirty, maskDirty := map[hdfs.AclScope]bool{}, map[hdfs.AclScope]bool{}
+	for _, e := range existing {
+		switch _, ok := keys[keyOf(e)]; {
+		case ok:
+			dirty[e.Scope] = true
+			if e.Type == hdfs.AclMask {
+				maskDirty[e.Scope] = true
+			}
+		case e.Type == hdfs.AclMask:
+			provided[e.Scope] = e
+		default:
+			acl = append(acl, e)
+		}
+	}
+	return buildAcl(acl, provided, dirty, maskDirty)
+}
+
+// inheritAcl gives the node n, created in parent with the permission mode,
+// the ACL inherited from the default ACL of parent, reporting whether it
+// has one; the umask does not apply then.
+func inheritAcl(parent, n *node, mode int16) bool {
+	var defaults []hdfs.AclEntry
+	for _, e := range parent.acl {
+		if e.Scope == hdfs.AclDefault {
+			defaults = append(defaults, e)
+		}
+	}
+	if len(defaults) == 0 {
+		return false
+	}
+	minimal := len(defaults) == 3
+	var acl []hdfs.AclEntry
+	for _, e := range defaults {
+		perm := e.Perm
+		switch {
+		case e.Type == hdfs.AclUser && e.Name == "":
+			perm &= mode >> 6
+		case e.Type == hdfs.AclGroup && e.Name == "" && minimal, e.Type == hdfs.AclMask:
+			perm &= mode >> 3
+		case e.Type == hdfs.AclOther:
+			perm &= mode
+		}
+		acl = append(acl, hdfs.AclEntry{Scope: hdfs.AclAccess, Type: e.Type, Name: e.Name, Perm: perm & 7})
+	}
+	if n.dir {
+		acl = append(acl, defaults...)
+	}
+	n.perm = 0
+	n.setAcl(acl)
+	return true
+}
+
+// aclAccess reports whether m may access n, which has an access ACL, with
+// the permission bits in want: the entry of the user, else those of its
+// groups, bounded by the mask.
+func (m *MemFS) aclAccess(n *node, want int16) bool {
+	mask := n.perm >> 3 & 7
+	for _, e := range n.acl {
+		if e.Scope == hdfs.AclAccess && e.Type == hdfs.AclUser && e.Name == m.user {
+			return e.Perm&mask&want == want
+		}
+	}
+	inGroup := false
+	for _, e := range n.acl {
+		if e.Scope != hdfs.AclAccess || e.Type != hdfs.AclGroup {
+			continue
+		}
+		if e.Name == "" && m.inGroup(n.group) || e.Name != "" && m.inGroup(e.Name) {
+			if e.Perm&mask&want == want {
+				return true
+			}
+			inGroup = true
+		}
+	}
+	if inGroup {
+		return false
+	}
+	return n.perm&want == want
+}
+
+// changeAcl replaces the ACL of p, which m must own, with the one change
+// returns for its whole ACL.
+func (m *MemFS) changeAcl(op, p string, change func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error)) error {
+	if err := m.begin(op, p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError(op, p, err)
+	}
+	if n.readonly || !m.superuser() && n.owner != m.user {
+		return pathError(op, p, syscall.EACCES)
+	}
+	acl, err := change(n.aclStatus().Acl())
+	if err != nil {
+		return pathError(op, p, err)
+	}
+	for _, e := range acl {
+		if e.Scope == hdfs.AclDefault && !n.dir {
+			return pathError(op, p, syscall.EINVAL) // only directories have default ACLs
+		}
+	}
+	n.setAcl(acl)
+	return nil
+}
+
+// GetAclStatus returns the ACL of p.
+func (m *MemFS) GetAclStatus(p string) (*hdfs.AclStatus, error) {
+	if err := m.begin("getacl", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, pathError("getacl", p, err)
+	}
+	return n.aclStatus(), nil
+}
+
+// SetAcl replaces the entries of the scopes of entries in the ACL of p.
+func (m *MemFS) SetAcl(p string, entries []hdfs.AclEntry) error {
+	return m.changeAcl("setacl", p, func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+		return replaceAcl(acl, entries)
+	})
+}
+
+// ModifyAclEntries adds entries to the ACL of p, replacing those of the
+// same scope, type and name.
+func (m *MemFS) ModifyAclEntries(p string, entries []hdfs.AclEntry) error {
+	return m.changeAcl("setacl", p, func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+		return mergeAcl(acl, entries)
+	})
+}
+
+// RemoveAclEntries removes the entries of the scopes, types and names of
+// entries from the ACL of p.
+func (m *MemFS) RemoveAclEntries(p string, entries []hdfs.AclEntry) error {
+	return m.changeAcl("setacl", p, func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+		spec := make([]hdfs.AclEntry, len(entries))
+		for i, e := range entries {
+			spec[i] = hdfs.AclEntry{Scope: e.Scope, Type: e.Type, Name: e.Name}
+		}
+		return filterAcl(acl, spec)
+	})
+}
+
+// RemoveDefaultAcl removes the default entries of the ACL of p.
+func (m *MemFS) RemoveDefaultAcl(p string) error {
+	return m.changeAcl("setacl", p, func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+		var access []hdfs.AclEntry
+		for _, e := range acl {
+			if e.Scope == hdfs.AclAccess {
+				access = append(access, e)
+			}
+		}
+		return access, nil
+	})
+}
+
+// RemoveAcl removes the entries of the ACL of p but those of the owner,
+// group and others; the group bits of its permission are those of the
+// group entry again.
+func (m *MemFS) RemoveAcl(p string) error {
+	return m.changeAcl("setacl", p, func(acl []hdfs.AclEntry) ([]hdfs.AclEntry, error) {
+		var base []hdfs.AclEntry
+		for _, e := range acl {
+			if e.Scope == hdfs.AclAccess && e.Name == "" && e.Type != hdfs.AclMask {
+				base = append(base, e)
+			}
+		}
+		return base, nil
+	})
+}
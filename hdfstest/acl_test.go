@@ -0,0 +1,114 @@
+package hdfstest
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+func spec(t *testing.T, s string) []hdfs.AclEntry {
+	entries, err := hdfs.ParseAclSpec(s)
+	if err != nil {
+		t.Fatalf("Error on parsing %q: %v\n", s, err)
+	}
+	return entries
+}
+
+func TestAcls(t *testing.T) {
+	m := NewMemFS()
+	m.CreateDirectory("/d")
+	writeFile(t, m, "/d/f", "data")
+	m.Chmod("/d/f", 0640)
+	bob := m.AsUser("bob", "staff")
+
+	if _, err := bob.Open("/d/f", hdfs.O_RDONLY, 0, 0, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Open without an ACL entry: got %v\n", err)
+	}
+	if err := m.ModifyAclEntries("/d/f", spec(t, "user:bob:r--")); err != nil {
+		t.Errorf("Error on ModifyAclEntries: %v\n", err)
+	}
+	s, err := m.GetAclStatus("/d/f")
+	if err != nil {
+		t.Fatalf("Error on GetAclStatus: %v\n", err)
+	}
+	if s.Permission != 0640 || hdfs.FormatAclSpec(s.Entries) != "user:bob:r--,group::r--" {
+		t.Errorf("GetAclStatus: got %o %v\n", s.Permission, s.Entries)
+	}
+	if acl := hdfs.FormatAclSpec(s.Acl()); acl != "user::rw-,user:bob:r--,group::r--,mask::r--,other::---" {
+		t.Errorf("Acl: got %s\n", acl)
+	}
+	if readFile(t, bob, "/d/f") != "data" {
+		t.Errorf("Read through an ACL entry: wrong content\n")
+	}
+
+	// the group bits of the permission are the mask
+	m.Chmod("/d/f", 0600)
+	if _, err := bob.Open("/d/f", hdfs.O_RDONLY, 0, 0, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Open with the mask cleared: got %v\n", err)
+	}
+	if s, _ := m.GetAclStatus("/d/f"); s.Effective(s.Entries[0]) != 0 {
+		t.Errorf("Effective with the mask cleared: got %o\n", s.Effective(s.Entries[0]))
+	}
+	m.Chmod("/d/f", 0640)
+
+	for _, c := range []struct {
+		op   string
+		err  error
+		want error
+	}{
+		{"default entry of a file", m.ModifyAclEntries("/d/f", spec(t, "default:user:bob:r-x")), syscall.EINVAL},
+		{"removing the owner entry", m.RemoveAclEntries("/d/f", spec(t, "user::")), syscall.EINVAL},
+		{"no base entries", m.SetAcl("/d/f", spec(t, "user:bob:rwx")), syscall.EINVAL},
+		{"duplicate entries", m.ModifyAclEntries("/d/f", spec(t, "user:bob:r--,user:bob:rwx")), syscall.EINVAL},
+		{"named mask", m.ModifyAclEntries("/d/f", []hdfs.AclEntry{{Type: hdfs.AclMask, Name: "bob"}}), syscall.EINVAL},
+		{"not the owner", bob.ModifyAclEntries("/d/f", spec(t, "user:bob:rwx")), fs.ErrPermission},
+		{"missing path", m.RemoveAcl("/missing"), fs.ErrNotExist},
+	} {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%s: got %v, want %v\n", c.op, c.err, c.want)
+		}
+	}
+
+	// new files and directories inherit the default ACL, filtered by their
+	// mode rather than the umask
+	if err := m.SetAcl("/d", spec(t, "default:user:bob:rwx")); err != nil {
+		t.Errorf("Error on SetAcl: %v\n", err)
+	}
+	if s, _ := m.GetAclStatus("/d"); hdfs.FormatAclSpec(s.Entries) != "default:user::rwx,default:user:bob:rwx,default:group::r-x,default:mask::rwx,default:other::r-x" {
+		t.Errorf("Default ACL: got %v\n", s.Entries)
+	}
+	writeFile(t, m, "/d/g", "")
+	if s, _ := m.GetAclStatus("/d/g"); s.Permission != 0664 || hdfs.FormatAclSpec(s.Entries) != "user:bob:rwx,group::r-x" {
+		t.Errorf("Inherited ACL of a file: got %o %v\n", s.Permission, s.Entries)
+	}
+	if f, err := bob.Open("/d/g", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0); err != nil {
+		t.Errorf("Error on appending through an inherited entry: %v\n", err)
+	} else {
+		f.Close()
+	}
+	m.CreateDirectory("/d/sub")
+	if s, _ := m.GetAclStatus("/d/sub"); s.Permission != 0775 || len(s.Entries) != 7 {
+		t.Errorf("Inherited ACL of a directory: got %o %v\n", s.Permission, s.Entries)
+	}
+
+	if err := m.RemoveDefaultAcl("/d"); err != nil {
+		t.Errorf("Error on RemoveDefaultAcl: %v\n", err)
+	}
+	if s, _ := m.GetAclStatus("/d"); len(s.Entries) != 0 || s.Permission != 0755 {
+		t.Errorf("RemoveDefaultAcl: got %o %v\n", s.Permission, s.Entries)
+	}
+	m.Chmod("/d/f", 0600)
+	if err := m.RemoveAcl("/d/f"); err != nil {
+		t.Errorf("Error on RemoveAcl: %v\n", err)
+	}
+	if s, _ := m.GetAclStatus("/d/f"); s.Entries != nil || s.Permission != 0640 {
+		t.Errorf("RemoveAcl: got %o %v\n", s.Permission, s.Entries)
+	}
+	if s, _ := m.GetAclStatus("/d/f"); !reflect.DeepEqual(s.Acl(), spec(t, "user::rw-,group::r--,other::---")) {
+		t.Errorf("Acl of a minimal ACL: got %v\n", s.Acl())
+	}
+}
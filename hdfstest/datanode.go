@@ -0,0 +1,439 @@
+package hdfstest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+	"github.com/zyxar/hdfs/internal/rpc"
+	"github.com/zyxar/hdfs/internal/transfer"
+)
+
+const (
+	// readPacketSize is the data size of the packets a Datanode sends.
+	readPacketSize = 64 << 10
+	// mirrorTimeout bounds waiting for the next datanode of a pipeline.
+	mirrorTimeout = 5 * time.Second
+)
+
+// defaultChecksum is the checksum of the replicas a Namenode stores itself.
+var defaultChecksum = transfer.Checksum{Type: hdfsproto.ChecksumCRC32C, BytesPerChecksum: 512}
+
+// Datanode is a fake datanode serving OP_READ_BLOCK, OP_WRITE_BLOCK and
+// OP_BLOCK_CHECKSUM of the data transfer protocol from memory, pipelines
+// included, and getReplicaVisibleLength of ClientDatanodeProtocol on its
+// IPC port. Create one with Namenode.AddDatanode.
+type Datanode struct {
+	name   string
+	l      net.Listener
+	ipc    net.Listener
+	server *rpc.Server
+
+	mu       sync.Mutex
+	replicas map[uint64]*replica
+	conns    map[net.Conn]bool
+	closed   bool
+	corrupt  bool
+	crashIn  int64 // bytes to receive before crashing, 0 for never
+}
+
+type replica struct {
+	gs        uint64
+	data      []byte
+	checksum  transfer.Checksum
+	finalized bool
+	synced    int64 // bytes persisted by the last packet asking to sync
+}
+
+func newDatanode(name string, l, ipc net.Listener) *Datanode {
+	dn := &Datanode{name: name, l: l, ipc: ipc, replicas: map[uint64]*replica{}, conns: map[net.Conn]bool{}}
+	dn.server = rpc.NewServer(ipc, hdfsproto.ClientDatanodeProtocol, dn.serveIPC)
+	return dn
+}
+
+// Name returns the host name the Namenode reports for the datanode.
+func (dn *Datanode) Name() string {
+	return dn.name
+}
+
+// Addr returns the host:port the Datanode listens on.
+func (dn *Datanode) Addr() string {
+	return dn.l.Addr().String()
+}
+
+// Close stops the Datanode, closing its connections, as a crash would. The
+// Namenode keeps listing it among the locations of its replicas.
+func (dn *Datanode) Close() error {
+	err := dn.l.Close()
+	dn.server.Close()
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	dn.closed = true
+	for c := range dn.conns {
+		c.Close()
+	}
+	return err
+}
+
+// Corrupt makes the datanode flip a bit of the data it serves, as if its
+// replicas were damaged on disk, so that readers fail checksum verification.
+func (dn *Datanode) Corrupt() {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	dn.corrupt = true
+}
+
+// CrashAfter makes the datanode crash, as Close does, once it has received
+// n more bytes of block data.
+func (dn *Datanode) CrashAfter(n int64) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	dn.crashIn = n
+}
+
+// Synced returns the bytes of its replicas the datanode was asked to persist
+// to disk by the packets of an hsync.
+func (dn *Datanode) Synced() int64 {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	var n int64
+	for _, r := range dn.replicas {
+		n += r.synced
+	}
+	return n
+}
+
+func (dn *Datanode) info() *hdfsproto.DatanodeInfo {
+	host, port, _ := net.SplitHostPort(dn.Addr())
+	p, _ := strconv.Atoi(port)
+	_, port, _ = net.SplitHostPort(dn.ipc.Addr().String())
+	ipc, _ := strconv.Atoi(port)
+	return &hdfsproto.DatanodeInfo{IPAddr: host, HostName: dn.name, DatanodeUUID: dn.name, XferPort: uint32(p), IPCPort: uint32(ipc)}
+}
+
+// put stores a finalized replica.
+func (dn *Datanode) put(id, gs uint64, data []byte) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	dn.replicas[id] = &replica{gs: gs, data: append([]byte(nil), data...), checksum: defaultChecksum, finalized: true}
+}
+
+// finalized returns a copy of the data of a finalized replica of the given
+// generation stamp and length.
+func (dn *Datanode) finalized(id, gs uint64, length int64) ([]byte, bool) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	r := dn.replicas[id]
+	if r == nil || !r.finalized || r.gs != gs || int64(len(r.data)) != length {
+		return nil, false
+	}
+	return append([]byte(nil), r.data...), true
+}
+
+func (dn *Datanode) remove(id uint64) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	delete(dn.replicas, id)
+}
+
+// track registers c to be closed by Close, closing it at once if the
+// Datanode is closed.
+func (dn *Datanode) track(c net.Conn) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	if dn.closed {
+		c.Close()
+		return
+	}
+	dn.conns[c] = true
+}
+
+func (dn *Datanode) untrack(c net.Conn) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	delete(dn.conns, c)
+	c.Close()
+}
+
+// received counts n bytes of block data received and reports whether the
+// datanode must crash.
+func (dn *Datanode) received(n int) bool {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	if dn.crashIn <= 0 {
+		return false
+	}
+	dn.crashIn -= int64(n)
+	return dn.crashIn <= 0
+}
+
+func (dn *Datanode) serve() {
+	go dn.server.Serve()
+	for {
+		c, err := dn.l.Accept()
+		if err != nil {
+			return
+		}
+		dn.track(c)
+		go dn.handle(c)
+	}
+}
+
+// serveIPC answers getReplicaVisibleLength with the bytes of the replica
+// acknowledged so far, which is all of those received.
+func (dn *Datanode) serveIPC(user, method string, req []byte) (protowire.Marshaler, error) {
+	if method != "getReplicaVisibleLength" {
+		return nil, &rpc.RemoteError{Class: "org.apache.hadoop.ipc.RpcNoSuchMethodException", Message: "Unknown method " + method}
+	}
+	var r hdfsproto.GetReplicaVisibleLengthRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	rep := dn.replicas[r.Block.BlockID]
+	if rep == nil || rep.gs < r.Block.GenerationStamp {
+		return nil, &rpc.RemoteError{Class: "org.apache.hadoop.hdfs.server.datanode.ReplicaNotFoundException", Message: "Replica not found for " + strconv.FormatUint(r.Block.BlockID, 10)}
+	}
+	return &hdfsproto.GetReplicaVisibleLengthResponse{Length: uint64(len(rep.data))}, nil
+}
+
+func respond(w net.Conn, resp *hdfsproto.BlockOpResponse) error {
+	_, err := w.Write(protowire.AppendDelimited(nil, resp))
+	return err
+}
+
+func (dn *Datanode) handle(c net.Conn) {
+	defer dn.untrack(c)
+	r := bufio.NewReader(c)
+	op, err := transfer.ReadOp(r)
+	if err != nil {
+		return
+	}
+	switch op {
+	case hdfsproto.ReadBlock:
+		var req hdfsproto.OpReadBlock
+		if protowire.ReadDelimited(r, &req) == nil {
+			dn.readBlock(c, r, &req)
+		}
+	case hdfsproto.WriteBlock:
+		var req hdfsproto.OpWriteBlock
+		if protowire.ReadDelimited(r, &req) == nil {
+			dn.writeBlock(c, r, &req)
+		}
+	case hdfsproto.BlockChecksum:
+		var req hdfsproto.OpBlockChecksum
+		if protowire.ReadDelimited(r, &req) == nil {
+			dn.blockChecksum(c, &req)
+		}
+	default:
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusErrorInvalid, Message: "unsupported operation " + strconv.Itoa(int(op))})
+	}
+}
+
+func (dn *Datanode) readBlock(c net.Conn, r *bufio.Reader, req *hdfsproto.OpReadBlock) {
+	b := req.Header.Block
+	dn.mu.Lock()
+	rep := dn.replicas[b.BlockID]
+	var data []byte
+	var checksum transfer.Checksum
+	found := rep != nil && rep.gs >= b.GenerationStamp
+	if found {
+		data, checksum = append([]byte(nil), rep.data...), rep.checksum
+	}
+	corrupt := dn.corrupt
+	dn.mu.Unlock()
+	if !found {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusError, Message: "replica not found for block " + strconv.FormatUint(b.BlockID, 10)})
+		return
+	}
+	size := int64(len(data))
+	offset := int64(req.Offset)
+	if offset > size {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusErrorInvalid, Message: "offset beyond the replica"})
+		return
+	}
+	bpc := int64(checksum.BytesPerChecksum)
+	start := offset - offset%bpc
+	end := offset + int64(req.Len)
+	if end > size || end < offset {
+		end = size
+	}
+	if rem := end % bpc; rem != 0 {
+		end += bpc - rem
+		if end > size {
+			end = size
+		}
+	}
+	cs := checksum.Proto()
+	if respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusSuccess, Checksum: &cs, ChunkOffset: uint64(start)}) != nil {
+		return
+	}
+	seqno := int64(0)
+	for off := start; off < end; seqno++ {
+		n := int64(readPacketSize) / bpc * bpc
+		if n > end-off {
+			n = end - off
+		}
+		chunk := data[off : off+n]
+		sums := checksum.Sum(chunk)
+		if corrupt {
+			chunk = append([]byte(nil), chunk...)
+			chunk[0] ^= 1
+		}
+		if transfer.WritePacket(c, &transfer.Packet{PacketHeader: hdfsproto.PacketHeader{OffsetInBlock: off, Seqno: seqno}, Sums: sums, Data: chunk}) != nil {
+			return
+		}
+		off += n
+	}
+	last := &transfer.Packet{PacketHeader: hdfsproto.PacketHeader{OffsetInBlock: end, Seqno: seqno, LastPacketInBlock: true}}
+	if transfer.WritePacket(c, last) != nil {
+		return
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	var status hdfsproto.ClientReadStatus
+	protowire.ReadDelimited(r, &status)
+}
+
+// blockChecksum answers the checksum of a replica, computed from its data
+// and the checksum it was written with.
+func (dn *Datanode) blockChecksum(c net.Conn, req *hdfsproto.OpBlockChecksum) {
+	b := req.Block
+	dn.mu.Lock()
+	rep := dn.replicas[b.BlockID]
+	var data []byte
+	var checksum transfer.Checksum
+	found := rep != nil && rep.gs >= b.GenerationStamp
+	if found {
+		data, checksum = append([]byte(nil), rep.data...), rep.checksum
+	}
+	dn.mu.Unlock()
+	if !found {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusError, Message: "replica not found for block " + strconv.FormatUint(b.BlockID, 10)})
+		return
+	}
+	sum, err := checksum.BlockChecksum(data, req.Type)
+	if err != nil {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusErrorInvalid, Message: err.Error()})
+		return
+	}
+	respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusSuccess, BlockChecksum: sum})
+}
+
+// writeBlock receives a block, or the rest of one being recovered, and
+// forwards it to the rest of the pipeline.
+func (dn *Datanode) writeBlock(c net.Conn, r *bufio.Reader, req *hdfsproto.OpWriteBlock) {
+	b := req.Header.Block
+	checksum := transfer.ChecksumOf(&req.RequestedChecksum)
+	if err := checksum.Valid(); err != nil {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusErrorInvalid, Message: err.Error()})
+		return
+	}
+	dn.mu.Lock()
+	rep := dn.replicas[b.BlockID]
+	switch req.Stage {
+	case hdfsproto.StagePipelineSetupCreate:
+		rep = &replica{gs: b.GenerationStamp, checksum: checksum}
+		dn.replicas[b.BlockID] = rep
+	case hdfsproto.StagePipelineSetupStreamingRecovery, hdfsproto.StagePipelineCloseRecovery:
+		if rep != nil {
+			if int64(len(rep.data)) > int64(req.MinBytesRcvd) {
+				rep.data = rep.data[:req.MinBytesRcvd]
+			}
+			rep.gs, rep.finalized = req.LatestGenerationStamp, false
+		}
+	default:
+		rep = nil
+	}
+	dn.mu.Unlock()
+	if rep == nil {
+		respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusError, Message: "cannot write block " + strconv.FormatUint(b.BlockID, 10) + " at this stage"})
+		return
+	}
+
+	var mirror net.Conn
+	var mr *bufio.Reader
+	if len(req.Targets) > 0 {
+		next := req.Targets[0]
+		fwd := *req
+		fwd.Targets = req.Targets[1:]
+		var resp hdfsproto.BlockOpResponse
+		m, err := transfer.Dial(next, mirrorTimeout)
+		if err == nil {
+			dn.track(m)
+			defer dn.untrack(m)
+			m.SetDeadline(time.Now().Add(mirrorTimeout))
+			mr = bufio.NewReader(m)
+			if err = transfer.WriteOp(m, hdfsproto.WriteBlock, &fwd); err == nil {
+				err = protowire.ReadDelimited(mr, &resp)
+			}
+			m.SetDeadline(time.Time{})
+		}
+		if err != nil || resp.Status != hdfsproto.StatusSuccess {
+			bad := next.XferAddr()
+			if resp.FirstBadLink != "" {
+				bad = resp.FirstBadLink
+			}
+			respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusError, FirstBadLink: bad, Message: "mirror failed"})
+			return
+		}
+		mirror = m
+	}
+	if respond(c, &hdfsproto.BlockOpResponse{Status: hdfsproto.StatusSuccess}) != nil {
+		return
+	}
+
+	for {
+		p, err := transfer.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		if dn.received(len(p.Data)) {
+			dn.Close()
+			return
+		}
+		ack := &hdfsproto.PipelineAck{Seqno: p.Seqno, Reply: []uint64{hdfsproto.StatusSuccess}}
+		if checksum.Verify(p.Data, p.Sums) != nil {
+			ack.Reply[0] = hdfsproto.StatusErrorChecksum
+		} else {
+			if mirror != nil {
+				var mack hdfsproto.PipelineAck
+				mirror.SetDeadline(time.Now().Add(mirrorTimeout))
+				err := transfer.WritePacket(mirror, p)
+				if err == nil {
+					err = protowire.ReadDelimited(mr, &mack)
+				}
+				if err != nil || mack.Seqno != p.Seqno {
+					mack.Reply = []uint64{hdfsproto.StatusError}
+				}
+				ack.Reply = append(ack.Reply, mack.Reply...)
+			}
+			dn.mu.Lock()
+			if p.OffsetInBlock <= int64(len(rep.data)) {
+				rep.data = append(rep.data[:p.OffsetInBlock], p.Data...)
+				rep.finalized = p.LastPacketInBlock
+				if p.SyncBlock {
+					rep.synced = int64(len(rep.data))
+				}
+			} else {
+				ack.Reply[0] = hdfsproto.StatusErrorInvalid
+			}
+			dn.mu.Unlock()
+		}
+		if _, err := c.Write(protowire.AppendDelimited(nil, ack)); err != nil {
+			return
+		}
+		for _, status := range ack.Reply {
+			if status != hdfsproto.StatusSuccess {
+				return
+			}
+		}
+		if p.LastPacketInBlock {
+			return
+		}
+	}
+}
@@ -0,0 +1,1327 @@
+// Package hdfstest provides an in-memory hdfs.FileSystem for unit tests that
+// cannot reach a cluster or start a JVM.
+//
+// MemFS models what libhdfs exposes of HDFS: directories and files with
+// owner, group and permission bits, ACLs, replication, block size,
+// modification and access times, extended attributes, block locations spread
+// over a configurable set of datanodes, single-writer leases, snapshots,
+// quotas, encryption zones and their trash roots, and data that only
+// becomes visible to readers once it is flushed.
+// Failures are reported with the same *hdfs.PathError values the cgo binding
+// returns, e.g. hdfs.ErrUnsupported for O_RDWR, syscall.EBADF for Seek on a
+// file opened for writing and fs.ErrPermission for access violations.
+package hdfstest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+const (
+	// Superuser owns the root directory of a new MemFS and bypasses
+	// permission checks, as the user running the namenode does.
+	Superuser = "hdfs"
+	// Supergroup is the group of the root directory.
+	Supergroup = "supergroup"
+
+	defaultURI         = "hdfs://localhost:8020"
+	defaultBlockSize   = 64 << 20
+	defaultReplication = 3
+	defaultCapacity    = 1 << 40
+	maxReplication     = 512
+	umask              = 022
+)
+
+// permission bits checked against owner, group and other.
+const (
+	permRead  = 4
+	permWrite = 2
+	permExec  = 1
+)
+
+// MemFS is a connection to an in-memory file system. The zero value is not
+// usable; create one with NewMemFS and more connections to the same tree
+// with AsUser.
+type MemFS struct {
+	t      *tree
+	user   string
+	groups []string
+
+	mu     sync.Mutex
+	cwd    string
+	closed bool
+}
+
+var (
+	_ hdfs.FileSystem       = (*MemFS)(nil)
+	_ hdfs.Truncater        = (*MemFS)(nil)
+	_ hdfs.FileCloseChecker = (*MemFS)(nil)
+	_ hdfs.Concater         = (*MemFS)(nil)
+	_ hdfs.Checksummer      = (*MemFS)(nil)
+	_ hdfs.XAttrer          = (*MemFS)(nil)
+	_ hdfs.Acler            = (*MemFS)(nil)
+	_ hdfs.Snapshotter      = (*MemFS)(nil)
+	_ hdfs.Summarizer       = (*MemFS)(nil)
+	_ hdfs.QuotaSetter      = (*MemFS)(nil)
+	_ hdfs.Trasher          = (*MemFS)(nil)
+	_ hdfs.Syncer           = (*memFile)(nil)
+)
+
+type tree struct {
+	sync.Mutex
+	root        *node
+	uri         string
+	blockSize   int64
+	replication int16
+	capacity    int64
+	nonDFSUsed  int64 // raw space taken outside the file system
+	datanodes   []string
+	now         func() time.Time
+	lastID      int64
+	trash       time.Duration // fs.trash.interval of the namenode
+}
+
+type node struct {
+	id           int64 // kept by the copies of the node in snapshots
+	dir          bool
+	children     map[string]*node
+	data         []byte
+	owner, group string
+	perm         int16
+	replication  int16
+	blockSize    int64
+	mtime, atime time.Time
+	writer       *memFile // holder of the lease, if any
+	synced       int64    // length of the data persisted to disk, and known to the namenode
+	deleted      bool
+	xattrs       map[string][]byte
+	acl          []hdfs.AclEntry  // entries the permission bits do not hold
+	snapshots    map[string]*node // by name, non-nil if snapshottable
+	readonly     bool             // part of a snapshot
+	quota        *quota           // nil if none is set
+	ez           string           // key of the encryption zone rooted here
+}
+
+// NewMemFS returns a connection, as Superuser, to a new file system holding
+// only the root directory. Files get a 64MB block size and a replication of
+// 3 by default, and their blocks are located on a single datanode named
+// "localhost".
+func NewMemFS() *MemFS {
+	t := &tree{
+		uri:         defaultURI,
+		blockSize:   defaultBlockSize,
+		replication: defaultReplication,
+		capacity:    defaultCapacity,
+		datanodes:   []string{"localhost"},
+		now:         time.Now,
+	}
+	t.root = &node{id: t.newID(), dir: true, children: map[string]*node{}, owner: Superuser, group: Supergroup, perm: 0755, mtime: t.time()}
+	return &MemFS{t: t, user: Superuser, groups: []string{Supergroup}, cwd: "/user/" + Superuser}
+}
+
+// AsUser returns a new connection to the same file system as user, member
+// of groups, as hdfs.ConnectAsUser does.
+func (m *MemFS) AsUser(user string, groups ...string) *MemFS {
+	return &MemFS{t: m.t, user: user, groups: groups, cwd: "/user/" + user}
+}
+
+// SetDatanodes sets the hosts GetHosts spreads block replicas over.
+func (m *MemFS) SetDatanodes(hosts ...string) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.datanodes = append([]string(nil), hosts...)
+}
+
+// SetDefaults sets the block size and replication of files created without
+// explicit values.
+func (m *MemFS) SetDefaults(blockSize int64, replication int16) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.blockSize, m.t.replication = blockSize, replication
+}
+
+// SetCapacity sets the raw capacity reported by GetCapacity.
+func (m *MemFS) SetCapacity(capacity int64) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.capacity = capacity
+}
+
+// SetNonDFSUsed sets the raw space the datanodes use for other data than
+// blocks, which GetRemaining does not report and GetUsed does not count.
+func (m *MemFS) SetNonDFSUsed(used int64) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.nonDFSUsed = used
+}
+
+// SetClock replaces time.Now as the source of modification and access times.
+func (m *MemFS) SetClock(now func() time.Time) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.now = now
+}
+
+// newID returns a new inode ID.
+func (t *tree) newID() int64 {
+	t.lastID++
+	return t.lastID
+}
+
+// time returns the current time at the one second precision of libhdfs.
+func (t *tree) time() time.Time {
+	return time.Unix(t.now().Unix(), 0)
+}
+
+func pathError(op, p string, err error) error {
+	return &hdfs.PathError{Op: op, Path: p, Err: err}
+}
+
+// begin locks the tree for an operation, failing if m was disconnected.
+func (m *MemFS) begin(op, p string) error {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return pathError(op, p, os.ErrClosed)
+	}
+	m.t.Lock()
+	return nil
+}
+
+// abs resolves p, which may be a full URI, relative to the working directory.
+func (m *MemFS) abs(p string) string {
+	if strings.HasPrefix(p, m.t.uri) {
+		p = p[len(m.t.uri):]
+	}
+	if !strings.HasPrefix(p, "/") {
+		m.mu.Lock()
+		p = path.Join(m.cwd, p)
+		m.mu.Unlock()
+	}
+	return path.Clean(p)
+}
+
+func (m *MemFS) superuser() bool {
+	return m.user == Superuser
+}
+
+func (m *MemFS) inGroup(group string) bool {
+	for _, g := range m.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// access reports whether m may access n with the permission bits in want.
+// Nobody may write to a snapshot.
+func (m *MemFS) access(n *node, want int16) bool {
+	if n.readonly && want&permWrite != 0 {
+		return false
+	}
+	if m.superuser() {
+		return true
+	}
+	bits := n.perm
+	switch {
+	case n.owner == m.user:
+		bits >>= 6
+	case n.hasAccessAcl():
+		return m.aclAccess(n, want)
+	case m.inGroup(n.group):
+		bits >>= 3
+	}
+	return bits&want == want
+}
+
+// walk resolves the absolute path p, checking traverse permission on every
+// directory on the way. It returns the directory holding the last element of
+// p, nil for the root, and the node itself, nil if it does not exist. The
+// snapshots of a snapshottable directory are found under hdfs.SnapshotDir.
+func (m *MemFS) walk(p string) (parent, n *node, err error) {
+	n = m.t.root
+	if p == "/" {
+		return nil, n, nil
+	}
+	names := strings.Split(p[1:], "/")
+	for i, name := range names {
+		if !n.dir {
+			return nil, nil, syscall.ENOENT
+		}
+		if !m.access(n, permExec) {
+			return nil, nil, syscall.EACCES
+		}
+		if name == hdfs.SnapshotDir && n.snapshots != nil {
+			parent, n = n, snapshotDir(n)
+			continue
+		}
+		parent, n = n, n.children[name]
+		if n == nil {
+			if i < len(names)-1 {
+				return nil, nil, syscall.ENOENT
+			}
+			return parent, nil, nil
+		}
+	}
+	return parent, n, nil
+}
+
+// lookup is walk for paths that must exist.
+func (m *MemFS) lookup(p string) (parent, n *node, err error) {
+	parent, n, err = m.walk(p)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	return
+}
+
+// mkdirs creates the missing directories of the absolute path p and returns
+// the last one.
+func (m *MemFS) mkdirs(p string) (*node, error) {
+	n := m.t.root
+	if p == "/" {
+		return n, nil
+	}
+	var chain []*node
+	for _, name := range strings.Split(p[1:], "/") {
+		if !n.dir {
+			return nil, hdfs.ErrInternal
+		}
+		chain = append(chain, n)
+		if !m.access(n, permExec) {
+			return nil, syscall.EACCES
+		}
+		if name == hdfs.SnapshotDir && n.snapshots != nil {
+			n = snapshotDir(n)
+			continue
+		}
+		child := n.children[name]
+		if child == nil {
+			if !m.access(n, permWrite) {
+				return nil, syscall.EACCES
+			}
+			if err := checkQuota(chain, usage{names: 1}, nil); err != nil {
+				return nil, err
+			}
+			child = &node{id: m.t.newID(), dir: true, children: map[string]*node{}, owner: m.user, group: n.group, perm: 0777 &^ umask, mtime: m.t.time()}
+			inheritAcl(n, child, 0777)
+			n.children[name] = child
+			n.mtime = child.mtime
+		}
+		n = child
+	}
+	if !n.dir {
+		return nil, hdfs.ErrInternal
+	}
+	return n, nil
+}
+
+// Open opens path for reading or writing. As with libhdfs, O_WRONLY creates
+// or truncates the file and its missing parent directories, O_WRONLY|O_APPEND
+// appends to an existing file, and O_RDWR or O_CREATE|O_EXCL fail with
+// hdfs.ErrUnsupported. Only one writer may hold a file at a time.
+func (m *MemFS) Open(p string, flags int, buffersize int, replication int, blocksize uint32) (hdfs.FileHandle, error) {
+	if flags&syscall.O_RDWR != 0 || flags&(syscall.O_EXCL|syscall.O_CREAT) == syscall.O_EXCL|syscall.O_CREAT {
+		return nil, pathError("open", p, hdfs.ErrUnsupported)
+	}
+	if err := m.begin("open", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	abs := m.abs(p)
+	if flags&hdfs.O_WRONLY == 0 {
+		_, n, err := m.lookup(abs)
+		if err != nil {
+			return nil, pathError("open", p, err)
+		}
+		if n.dir {
+			return nil, pathError("open", p, hdfs.ErrInternal)
+		}
+		if !m.access(n, permRead) {
+			return nil, pathError("open", p, syscall.EACCES)
+		}
+		return &memFile{m: m, name: p, node: n, data: n.data}, nil
+	}
+	if flags&hdfs.O_APPEND != 0 {
+		_, n, err := m.lookup(abs)
+		if err != nil {
+			return nil, pathError("open", p, err)
+		}
+		if n.dir || n.writer != nil {
+			return nil, pathError("open", p, hdfs.ErrInternal)
+		}
+		if !m.access(n, permWrite) {
+			return nil, pathError("open", p, syscall.EACCES)
+		}
+		f := &memFile{m: m, name: p, node: n, write: true, off: int64(len(n.data))}
+		n.writer = f
+		return f, nil
+	}
+	if abs == "/" {
+		return nil, pathError("open", p, hdfs.ErrInternal)
+	}
+	dir, err := m.mkdirs(path.Dir(abs))
+	if err != nil {
+		return nil, pathError("open", p, err)
+	}
+	name := path.Base(abs)
+	if old := dir.children[name]; old != nil && (old.dir || old.writer != nil) {
+		return nil, pathError("open", p, hdfs.ErrInternal)
+	}
+	if !m.access(dir, permWrite) {
+		return nil, pathError("open", p, syscall.EACCES)
+	}
+	if dir.children[name] == nil {
+		if err := checkQuota(m.t.chain(dir), usage{names: 1}, nil); err != nil {
+			return nil, pathError("open", p, err)
+		}
+	}
+	if replication == 0 {
+		replication = int(m.t.replication)
+	}
+	if replication < 1 || replication > maxReplication {
+		return nil, pathError("open", p, hdfs.ErrInternal)
+	}
+	bs := int64(blocksize)
+	if bs == 0 {
+		bs = m.t.blockSize
+	}
+	now := m.t.time()
+	n := &node{id: m.t.newID(), owner: m.user, group: dir.group, perm: 0666 &^ umask, replication: int16(replication), blockSize: bs, mtime: now, atime: now}
+	inheritAcl(dir, n, 0666)
+	if old := dir.children[name]; old != nil {
+		old.deleted = true
+	}
+	dir.children[name] = n
+	dir.mtime = now
+	f := &memFile{m: m, name: p, node: n, write: true}
+	n.writer = f
+	return f, nil
+}
+
+// Exists returns nil if path exists.
+func (m *MemFS) Exists(p string) error {
+	if err := m.begin("exists", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	if _, _, err := m.lookup(m.abs(p)); err != nil {
+		return pathError("exists", p, err)
+	}
+	return nil
+}
+
+// checkSubtree reports whether m may delete everything below n.
+func (m *MemFS) checkSubtree(n *node) bool {
+	if !n.dir {
+		return true
+	}
+	if len(n.children) > 0 && !m.access(n, permRead|permWrite|permExec) {
+		return false
+	}
+	for _, c := range n.children {
+		if !m.checkSubtree(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func markDeleted(n *node) {
+	n.deleted = true
+	for _, c := range n.children {
+		markDeleted(c)
+	}
+}
+
+// Delete removes path and, recursively, everything below it.
+func (m *MemFS) Delete(p string) error {
+	return m.delete("delete", p, true)
+}
+
+// Remove removes the file or empty directory path, failing with ENOTEMPTY
+// on a directory that is not empty.
+func (m *MemFS) Remove(p string) error {
+	return m.delete("remove", p, false)
+}
+
+func (m *MemFS) delete(op, p string, recursive bool) error {
+	if err := m.begin(op, p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	abs := m.abs(p)
+	parent, n, err := m.lookup(abs)
+	if err != nil {
+		return pathError(op, p, err)
+	}
+	if parent == nil {
+		return pathError(op, p, hdfs.ErrInternal)
+	}
+	if !recursive && len(n.children) > 0 {
+		return pathError(op, p, syscall.ENOTEMPTY)
+	}
+	if !m.access(parent, permWrite|permExec) || !m.checkSubtree(n) {
+		return pathError(op, p, syscall.EACCES)
+	}
+	if hasSnapshots(n) {
+		return pathError(op, p, syscall.ENOTEMPTY)
+	}
+	delete(parent.children, path.Base(abs))
+	parent.mtime = m.t.time()
+	markDeleted(n)
+	return nil
+}
+
+// Rename moves oldpath to newpath, or into newpath if it is a directory.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	if err := m.begin("rename", oldpath); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	src, dst := m.abs(oldpath), m.abs(newpath)
+	sparent, n, err := m.lookup(src)
+	if err != nil {
+		return pathError("rename", oldpath, err)
+	}
+	if sparent == nil {
+		return pathError("rename", oldpath, hdfs.ErrInternal)
+	}
+	dparent, target, err := m.walk(dst)
+	if err == syscall.EACCES {
+		return pathError("rename", oldpath, err)
+	}
+	if target != nil && target.dir {
+		dparent, dst = target, path.Join(dst, path.Base(src))
+		target = dparent.children[path.Base(src)]
+	}
+	if src == dst {
+		return nil
+	}
+	// like FileSystem.rename, refuse to overwrite or to create parents
+	if err != nil || target != nil || dparent == nil || !dparent.dir || strings.HasPrefix(dst+"/", src+"/") {
+		return pathError("rename", oldpath, hdfs.ErrInternal)
+	}
+	if !m.access(sparent, permWrite|permExec) || !m.access(dparent, permWrite|permExec) {
+		return pathError("rename", oldpath, syscall.EACCES)
+	}
+	if hasSnapshots(n) {
+		return pathError("rename", oldpath, syscall.ENOTEMPTY)
+	}
+	schain, dchain := m.t.chain(sparent), m.t.chain(dparent)
+	if zoneOf(schain) != zoneOf(dchain) {
+		return pathError("rename", oldpath, syscall.EXDEV)
+	}
+	if err := checkQuota(dchain, usageOf(n), schain); err != nil {
+		return pathError("rename", oldpath, err)
+	}
+	delete(sparent.children, path.Base(src))
+	dparent.children[path.Base(dst)] = n
+	now := m.t.time()
+	sparent.mtime, dparent.mtime = now, now
+	return nil
+}
+
+// GetWorkingDirectory copies the URI of the working directory, followed by
+// a NUL byte, into buffer.
+func (m *MemFS) GetWorkingDirectory(buffer []byte, size uint32) ([]byte, error) {
+	if err := m.begin("getwd", ""); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	m.mu.Lock()
+	wd := m.t.uri + m.cwd
+	m.mu.Unlock()
+	if len(wd)+1 > int(size) || len(wd)+1 > len(buffer) {
+		return nil, pathError("getwd", "", syscall.ERANGE)
+	}
+	buffer[copy(buffer, wd)] = 0
+	return buffer, nil
+}
+
+// SetWorkingDirectory sets the directory relative paths are resolved
+// against. As with libhdfs, the directory does not need to exist.
+func (m *MemFS) SetWorkingDirectory(p string) error {
+	if err := m.begin("chdir", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	abs := m.abs(p)
+	m.mu.Lock()
+	m.cwd = abs
+	m.mu.Unlock()
+	return nil
+}
+
+// CreateDirectory creates path and its missing parents.
+func (m *MemFS) CreateDirectory(p string) error {
+	if err := m.begin("mkdir", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	if _, err := m.mkdirs(m.abs(p)); err != nil {
+		return pathError("mkdir", p, err)
+	}
+	return nil
+}
+
+// SetReplication sets the replication of a file.
+func (m *MemFS) SetReplication(p string, replication int16) error {
+	if err := m.begin("setrep", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("setrep", p, err)
+	}
+	if n.dir || replication < 1 || replication > maxReplication {
+		return pathError("setrep", p, hdfs.ErrInternal)
+	}
+	if !m.access(n, permWrite) {
+		return pathError("setrep", p, syscall.EACCES)
+	}
+	if err := checkQuota(m.t.chain(n), usage{space: int64(len(n.data)) * int64(replication-n.replication)}, nil); err != nil {
+		return pathError("setrep", p, err)
+	}
+	n.replication = replication
+	return nil
+}
+
+// size returns the length of n a namenode reports: while a writer holds
+// it, only its complete blocks and what the last Hsync recorded, however
+// much of the rest readers already see.
+func (n *node) size() int64 {
+	if n.writer == nil {
+		return int64(len(n.data))
+	}
+	if full := int64(len(n.data)) / n.blockSize * n.blockSize; full > n.synced {
+		return full
+	}
+	return n.synced
+}
+
+func (m *MemFS) info(p string, n *node) *hdfs.FileInfo {
+	info := &hdfs.FileInfo{
+		Kind:        'F',
+		Name:        m.t.uri + p,
+		LastMod:     n.mtime,
+		Size:        n.size(),
+		Replication: n.replication,
+		BlockSize:   n.blockSize,
+		Owner:       n.owner,
+		Group:       n.group,
+		Permissions: n.perm,
+		LastAccess:  n.atime,
+	}
+	if n.dir {
+		info.Kind = 'D'
+		info.LastAccess = time.Unix(0, 0)
+	}
+	return info
+}
+
+// ListDirectory lists the entries of a directory sorted by name. Listing a
+// file returns the file itself.
+func (m *MemFS) ListDirectory(p string) ([]*hdfs.FileInfo, error) {
+	if err := m.begin("readdir", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	abs := m.abs(p)
+	_, n, err := m.lookup(abs)
+	if err != nil {
+		return nil, pathError("readdir", p, err)
+	}
+	if !n.dir {
+		return []*hdfs.FileInfo{m.info(abs, n)}, nil
+	}
+	if !m.access(n, permRead|permExec) {
+		return nil, pathError("readdir", p, syscall.EACCES)
+	}
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	infos := make([]*hdfs.FileInfo, len(names))
+	for i, name := range names {
+		infos[i] = m.info(path.Join(abs, name), n.children[name])
+	}
+	return infos, nil
+}
+
+// GetPathInfo returns information about path. The size of a file being
+// written only covers flushed data.
+func (m *MemFS) GetPathInfo(p string) (*hdfs.FileInfo, error) {
+	if err := m.begin("stat", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	abs := m.abs(p)
+	_, n, err := m.lookup(abs)
+	if err != nil {
+		return nil, pathError("stat", p, err)
+	}
+	return m.info(abs, n), nil
+}
+
+// SyncedSize returns the length of the file path that would survive the
+// datanodes losing power: the data written up to the last Hsync of a file
+// being written, or all of it once closed.
+func (m *MemFS) SyncedSize(p string) (int64, error) {
+	if err := m.begin("stat", p); err != nil {
+		return 0, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return 0, pathError("stat", p, err)
+	}
+	if n.dir {
+		return 0, pathError("stat", p, syscall.EISDIR)
+	}
+	return n.synced, nil
+}
+
+// GetFileChecksum returns the checksum HDFS computes for a file, as an
+// MD5MD5CRC of CRC32C checksums of 512 bytes: the default settings of the
+// datanodes of a Namenode. As on HDFS, directories are not found.
+func (m *MemFS) GetFileChecksum(p string) (*hdfs.FileChecksum, error) {
+	if err := m.begin("checksum", p); err != nil {
+		return nil, err
+	}
+	_, n, err := m.lookup(m.abs(p))
+	if err == nil && n.dir {
+		err = syscall.ENOENT
+	} else if err == nil && !m.access(n, permRead) {
+		err = syscall.EACCES
+	}
+	var data []byte
+	var blockSize int64
+	if err == nil {
+		data, blockSize = n.data, n.blockSize
+	}
+	m.t.Unlock()
+	if err != nil {
+		return nil, pathError("checksum", p, err)
+	}
+	return hdfs.ComputeChecksum(bytes.NewReader(data), hdfs.ChecksumOptions{BlockSize: blockSize})
+}
+
+// GetHosts returns, for every block of path overlapping the given range, the
+// datanodes holding its replicas. Replicas are placed round-robin over the
+// datanodes set with SetDatanodes.
+func (m *MemFS) GetHosts(p string, start, length int64) ([][]string, error) {
+	if err := m.begin("gethosts", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, pathError("gethosts", p, err)
+	}
+	if start < 0 || length < 0 {
+		return nil, pathError("gethosts", p, hdfs.ErrInternal)
+	}
+	if !m.access(n, permRead) {
+		return nil, pathError("gethosts", p, syscall.EACCES)
+	}
+	hosts := [][]string{}
+	if n.dir || len(m.t.datanodes) == 0 {
+		return hosts, nil
+	}
+	size := int64(len(n.data))
+	replicas := int(n.replication)
+	if replicas > len(m.t.datanodes) {
+		replicas = len(m.t.datanodes)
+	}
+	for i, off := 0, int64(0); off < size; i, off = i+1, off+n.blockSize {
+		if off >= start+length || off+n.blockSize <= start {
+			continue
+		}
+		block := make([]string, replicas)
+		for j := range block {
+			block[j] = m.t.datanodes[(i+j)%len(m.t.datanodes)]
+		}
+		hosts = append(hosts, block)
+	}
+	return hosts, nil
+}
+
+// GetDefaultBlockSize returns the block size of files created without an
+// explicit one.
+func (m *MemFS) GetDefaultBlockSize() (int64, error) {
+	if err := m.begin("blocksize", ""); err != nil {
+		return -1, err
+	}
+	defer m.t.Unlock()
+	return m.t.blockSize, nil
+}
+
+// GetCapacity returns the capacity set with SetCapacity.
+func (m *MemFS) GetCapacity() (int64, error) {
+	if err := m.begin("capacity", ""); err != nil {
+		return -1, err
+	}
+	defer m.t.Unlock()
+	return m.t.capacity, nil
+}
+
+func used(n *node) (total int64) {
+	for _, c := range n.children {
+		total += used(c)
+	}
+	return total + int64(len(n.data))*int64(n.replication)
+}
+
+// GetUsed returns the raw space used by all replicas of all files.
+func (m *MemFS) GetUsed() (int64, error) {
+	if err := m.begin("used", ""); err != nil {
+		return -1, err
+	}
+	defer m.t.Unlock()
+	return used(m.t.root), nil
+}
+
+// GetRemaining returns the capacity not used by replicas nor set with
+// SetNonDFSUsed.
+func (m *MemFS) GetRemaining() (int64, error) {
+	if err := m.begin("remaining", ""); err != nil {
+		return -1, err
+	}
+	defer m.t.Unlock()
+	return m.t.capacity - used(m.t.root) - m.t.nonDFSUsed, nil
+}
+
+// Chown changes the owner and group of path; an empty owner or group is left
+// unchanged. Only the superuser may give a file away; its owner may only
+// change the group to one of the groups it belongs to.
+func (m *MemFS) Chown(p, owner, group string) error {
+	if err := m.begin("chown", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("chown", p, err)
+	}
+	if n.readonly || !m.superuser() && (n.owner != m.user || owner != "" && owner != m.user || group != "" && !m.inGroup(group)) {
+		return pathError("chown", p, syscall.EACCES)
+	}
+	if owner != "" {
+		n.owner = owner
+	}
+	if group != "" {
+		n.group = group
+	}
+	return nil
+}
+
+// Chmod sets the permission bits of path. Only the owner or the superuser
+// may change them.
+func (m *MemFS) Chmod(p string, mode int16) error {
+	if err := m.begin("chmod", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("chmod", p, err)
+	}
+	if n.readonly || !m.superuser() && n.owner != m.user {
+		return pathError("chmod", p, syscall.EACCES)
+	}
+	n.perm = mode & 01777
+	return nil
+}
+
+// Utime sets the modification and access times of path, at one second
+// precision.
+func (m *MemFS) Utime(p string, mtime, atime time.Time) error {
+	if err := m.begin("utime", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("utime", p, err)
+	}
+	if !m.access(n, permWrite) {
+		return pathError("utime", p, syscall.EACCES)
+	}
+	n.mtime, n.atime = time.Unix(mtime.Unix(), 0), time.Unix(atime.Unix(), 0)
+	return nil
+}
+
+// TruncateFile cuts the file path to newLength. As on HDFS, it is done at
+// once when newLength is on a block boundary; otherwise it reports the last
+// block as being recovered, which on a MemFS is over by the time it returns.
+func (m *MemFS) TruncateFile(p string, newLength int64) (bool, error) {
+	if err := m.begin("truncate", p); err != nil {
+		return false, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return false, pathError("truncate", p, err)
+	}
+	if n.dir || n.writer != nil {
+		return false, pathError("truncate", p, hdfs.ErrInternal)
+	}
+	if !m.access(n, permWrite) {
+		return false, pathError("truncate", p, syscall.EACCES)
+	}
+	size := int64(len(n.data))
+	if newLength < 0 || newLength > size {
+		return false, pathError("truncate", p, syscall.EINVAL)
+	}
+	n.data = n.data[:newLength:newLength]
+	if n.synced > newLength {
+		n.synced = newLength
+	}
+	n.mtime = m.t.time()
+	return newLength == size || newLength%n.blockSize == 0, nil
+}
+
+// IsFileClosed reports whether no writer holds the file path.
+func (m *MemFS) IsFileClosed(p string) (bool, error) {
+	if err := m.begin("isclosed", p); err != nil {
+		return false, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return false, pathError("isclosed", p, err)
+	}
+	if n.dir {
+		return false, pathError("isclosed", p, syscall.EISDIR)
+	}
+	return n.writer == nil, nil
+}
+
+// ConcatFiles appends the data of srcs, in order, to the file target and
+// removes them. The files must be distinct, closed and of the block size of
+// target.
+func (m *MemFS) ConcatFiles(target string, srcs []string) error {
+	if err := m.begin("concat", target); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, tn, err := m.lookup(m.abs(target))
+	if err != nil {
+		return pathError("concat", target, err)
+	}
+	if tn.dir || tn.writer != nil || len(srcs) == 0 {
+		return pathError("concat", target, syscall.EINVAL)
+	}
+	if !m.access(tn, permWrite) {
+		return pathError("concat", target, syscall.EACCES)
+	}
+	parents := make([]*node, len(srcs))
+	nodes := make([]*node, len(srcs))
+	for i, src := range srcs {
+		parent, n, err := m.lookup(m.abs(src))
+		if err != nil {
+			return pathError("concat", src, err)
+		}
+		if n == tn || n.dir || n.writer != nil || n.blockSize != tn.blockSize {
+			return pathError("concat", src, syscall.EINVAL)
+		}
+		for _, prev := range nodes[:i] {
+			if n == prev {
+				return pathError("concat", src, syscall.EINVAL)
+			}
+		}
+		if !m.access(parent, permWrite|permExec) {
+			return pathError("concat", src, syscall.EACCES)
+		}
+		parents[i], nodes[i] = parent, n
+	}
+	data := tn.data[:len(tn.data):len(tn.data)]
+	now := m.t.time()
+	for i, src := range srcs {
+		data = append(data, nodes[i].data...)
+		delete(parents[i].children, path.Base(m.abs(src)))
+		parents[i].mtime = now
+		markDeleted(nodes[i])
+	}
+	tn.data, tn.synced, tn.mtime = data, int64(len(data)), now
+	return nil
+}
+
+// xattrNamespaces are the prefixes of the names of extended attributes,
+// and whether they are kept to the superuser.
+var xattrNamespaces = []struct {
+	prefix    string
+	superuser bool
+}{
+	{"user.", false},
+	{"trusted.", true},
+	{"security.", false},
+	{"raw.", true},
+}
+
+// xattrName returns name with its namespace prefix in lower case, as HDFS
+// stores it, failing with EINVAL for other namespaces and EACCES for those
+// m may not use.
+func (m *MemFS) xattrName(name string) (string, error) {
+	for _, ns := range xattrNamespaces {
+		if len(name) > len(ns.prefix) && strings.EqualFold(name[:len(ns.prefix)], ns.prefix) {
+			if ns.superuser && !m.superuser() {
+				return "", syscall.EACCES
+			}
+			return ns.prefix + name[len(ns.prefix):], nil
+		}
+	}
+	return "", syscall.EINVAL
+}
+
+// visible reports whether m may see the extended attribute name.
+func (m *MemFS) visible(name string) bool {
+	_, err := m.xattrName(name)
+	return err == nil
+}
+
+// xattrNode returns the node of p for an operation on its extended
+// attributes, which needs the permission bits in want.
+func (m *MemFS) xattrNode(p string, want int16) (*node, error) {
+	_, n, err := m.lookup(m.abs(p))
+	if err == nil && want != 0 && !m.access(n, want) {
+		err = syscall.EACCES
+	}
+	return n, err
+}
+
+// SetXAttr sets the extended attribute name of p to value. With flags
+// XATTR_CREATE it fails with fs.ErrExist if the attribute exists, with
+// XATTR_REPLACE with hdfs.ErrNoXAttr if it does not; 0 is both.
+func (m *MemFS) SetXAttr(p, name string, value []byte, flags int) error {
+	if err := m.begin("setxattr", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.xattrNode(p, permWrite)
+	if err == nil {
+		name, err = m.xattrName(name)
+	}
+	if err != nil {
+		return pathError("setxattr", p, err)
+	}
+	if flags&(hdfs.XATTR_CREATE|hdfs.XATTR_REPLACE) == 0 {
+		flags = hdfs.XATTR_CREATE | hdfs.XATTR_REPLACE
+	}
+	_, exists := n.xattrs[name]
+	if exists && flags&hdfs.XATTR_REPLACE == 0 {
+		return pathError("setxattr", p, syscall.EEXIST)
+	}
+	if !exists && flags&hdfs.XATTR_CREATE == 0 {
+		return pathError("setxattr", p, hdfs.ErrNoXAttr)
+	}
+	if n.xattrs == nil {
+		n.xattrs = map[string][]byte{}
+	}
+	n.xattrs[name] = append([]byte{}, value...)
+	return nil
+}
+
+// GetXAttr returns the value of the extended attribute name of p.
+func (m *MemFS) GetXAttr(p, name string) ([]byte, error) {
+	values, err := m.GetXAttrs(p, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		return value, nil
+	}
+	return nil, pathError("getxattr", p, hdfs.ErrNoXAttr)
+}
+
+// GetXAttrs returns the values of the extended attributes names of p, all
+// those m may see if none is given.
+func (m *MemFS) GetXAttrs(p string, names ...string) (map[string][]byte, error) {
+	if err := m.begin("getxattr", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	n, err := m.xattrNode(p, permRead)
+	if err != nil {
+		return nil, pathError("getxattr", p, err)
+	}
+	values := map[string][]byte{}
+	if len(names) == 0 {
+		for name, value := range n.xattrs {
+			if m.visible(name) {
+				values[name] = append([]byte{}, value...)
+			}
+		}
+		return values, nil
+	}
+	for _, name := range names {
+		name, err := m.xattrName(name)
+		if err != nil {
+			return nil, pathError("getxattr", p, err)
+		}
+		value, ok := n.xattrs[name]
+		if !ok {
+			return nil, pathError("getxattr", p, hdfs.ErrNoXAttr)
+		}
+		values[name] = append([]byte{}, value...)
+	}
+	return values, nil
+}
+
+// ListXAttrs returns the sorted names of the extended attributes of p that
+// m may see.
+func (m *MemFS) ListXAttrs(p string) ([]string, error) {
+	if err := m.begin("listxattr", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	n, err := m.xattrNode(p, 0)
+	if err != nil {
+		return nil, pathError("listxattr", p, err)
+	}
+	names := []string{}
+	for name := range n.xattrs {
+		if m.visible(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RemoveXAttr removes the extended attribute name of p.
+func (m *MemFS) RemoveXAttr(p, name string) error {
+	if err := m.begin("removexattr", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.xattrNode(p, permWrite)
+	if err == nil {
+		name, err = m.xattrName(name)
+	}
+	if err == nil {
+		if _, ok := n.xattrs[name]; !ok {
+			err = hdfs.ErrNoXAttr
+		}
+	}
+	if err != nil {
+		return pathError("removexattr", p, err)
+	}
+	delete(n.xattrs, name)
+	return nil
+}
+
+// Disconnect closes the connection; using it afterwards fails with
+// os.ErrClosed. Other connections to the same tree are not affected.
+func (m *MemFS) Disconnect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return pathError("disconnect", "", os.ErrClosed)
+	}
+	m.closed = true
+	return nil
+}
+
+// memFile is a file opened on a MemFS. A reader sees the data flushed before
+// it was opened; a writer holds the lease on its node and buffers writes
+// until Flush or Close.
+type memFile struct {
+	m       *MemFS
+	name    string
+	node    *node
+	write   bool
+	data    []byte // visible data when opened for reading
+	off     int64
+	pending []byte
+	closed  bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+// begin locks the tree for an operation on f, failing if f was closed.
+func (f *memFile) begin() error {
+	f.m.t.Lock()
+	if f.closed {
+		f.m.t.Unlock()
+		return os.ErrClosed
+	}
+	return nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.m.t.Unlock()
+	if f.write {
+		return 0, pathError("read", f.name, syscall.EINVAL)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if f.off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.m.t.Unlock()
+	if f.write {
+		return 0, pathError("read", f.name, syscall.EINVAL)
+	}
+	if off < 0 {
+		return 0, pathError("read", f.name, hdfs.ErrInternal)
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.m.t.Unlock()
+	if !f.write {
+		return 0, pathError("write", f.name, syscall.EINVAL)
+	}
+	f.pending = append(f.pending, b...)
+	f.off += int64(len(b))
+	return len(b), nil
+}
+
+// Seek seeks a file opened for reading; the end of the file is its current
+// visible length. On a writer only Seek(0, io.SeekCurrent) is allowed.
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.m.t.Unlock()
+	if whence == io.SeekCurrent && offset == 0 {
+		return f.off, nil
+	}
+	if f.write {
+		return 0, pathError("seek", f.name, syscall.EBADF)
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.off + offset
+	case io.SeekEnd:
+		abs = int64(len(f.node.data)) + offset
+	default:
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+	if abs < 0 || abs > int64(len(f.node.data)) {
+		return 0, pathError("seek", f.name, hdfs.ErrInternal)
+	}
+	if abs > int64(len(f.data)) {
+		f.data = f.node.data
+	}
+	f.off = abs
+	return abs, nil
+}
+
+// flush publishes pending writes, failing with EDQUOT if they exceed a
+// space quota; the tree must be locked.
+func (f *memFile) flush() error {
+	if f.node.deleted {
+		return pathError("flush", f.name, syscall.ENOENT)
+	}
+	if len(f.pending) > 0 {
+		u := usage{space: int64(len(f.pending)) * int64(f.node.replication)}
+		if err := checkQuota(f.m.t.chain(f.node), u, nil); err != nil {
+			return pathError("flush", f.name, err)
+		}
+		f.node.data = append(f.node.data[:len(f.node.data):len(f.node.data)], f.pending...)
+		f.pending = nil
+	}
+	return nil
+}
+
+// Flush makes the data written so far visible to readers opened afterwards.
+func (f *memFile) Flush() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.m.t.Unlock()
+	if !f.write {
+		return pathError("flush", f.name, syscall.EBADF)
+	}
+	return f.flush()
+}
+
+// Hflush is Flush. As on HDFS, the size of the file GetPathInfo reports
+// does not count the data until an Hsync or Close, or until its block is
+// complete.
+func (f *memFile) Hflush() error {
+	return f.Flush()
+}
+
+// Hsync is Flush, also recording the data as synced, which SyncedSize
+// and the size of the file report.
+func (f *memFile) Hsync() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.m.t.Unlock()
+	if !f.write {
+		return pathError("hsync", f.name, syscall.EBADF)
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+	f.node.synced = int64(len(f.node.data))
+	return nil
+}
+
+// Close flushes a writer, releases its lease and updates the modification
+// time of the file.
+func (f *memFile) Close() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.m.t.Unlock()
+	f.closed = true
+	if !f.write {
+		return nil
+	}
+	f.node.writer = nil
+	if err := f.flush(); err != nil {
+		return pathError("close", f.name, errors.Unwrap(err))
+	}
+	f.node.synced = int64(len(f.node.data))
+	f.node.mtime = f.m.t.time()
+	return nil
+}
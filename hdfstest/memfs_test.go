@@ -0,0 +1,360 @@
+package hdfstest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+func writeFile(t *testing.T, m hdfs.FileSystem, path, content string) {
+	file, err := m.Open(path, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for writing: %v\n", path, err)
+	}
+	if _, err = io.WriteString(file, content); err != nil {
+		t.Fatalf("Error on writing %s: %v\n", path, err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatalf("Error on closing %s: %v\n", path, err)
+	}
+}
+
+func readFile(t *testing.T, m hdfs.FileSystem, path string) string {
+	file, err := m.Open(path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for reading: %v\n", path, err)
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", path, err)
+	}
+	return string(b)
+}
+
+func TestReadWrite(t *testing.T) {
+	m := NewMemFS()
+	writeFile(t, m, "/tmp/a.txt", "hello, ")
+
+	file, err := m.Open("/tmp/a.txt", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for appending: %v\n", err)
+	}
+	if _, err = m.Open("/tmp/a.txt", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Second writer: got %v, want ErrInternal\n", err)
+	}
+	io.WriteString(file, "world")
+	if pos, err := file.Seek(0, io.SeekCurrent); pos != 12 || err != nil {
+		t.Errorf("Writer offset: got %d, %v; want 12\n", pos, err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Seek on writer: got %v, want EBADF\n", err)
+	}
+	if _, err = file.Read(make([]byte, 1)); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Read on writer: got %v, want EINVAL\n", err)
+	}
+	if s := readFile(t, m, "/tmp/a.txt"); s != "hello, " {
+		t.Errorf("Unflushed data visible: %q\n", s)
+	}
+	if err = file.Flush(); err != nil {
+		t.Errorf("Error on flushing: %v\n", err)
+	}
+	if s := readFile(t, m, "/tmp/a.txt"); s != "hello, world" {
+		t.Errorf("Flushed data not visible: %q\n", s)
+	}
+	if err = file.Close(); err != nil {
+		t.Errorf("Error on closing: %v\n", err)
+	}
+	if err = file.Close(); err != os.ErrClosed {
+		t.Errorf("Second close: got %v, want os.ErrClosed\n", err)
+	}
+
+	file, err = m.Open("/tmp/a.txt", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for reading: %v\n", err)
+	}
+	defer file.Close()
+	if pos, err := file.Seek(-5, io.SeekEnd); pos != 7 || err != nil {
+		t.Errorf("Seek from end: got %d, %v; want 7\n", pos, err)
+	}
+	b := make([]byte, 8)
+	if n, err := file.ReadAt(b, 7); n != 5 || err != io.EOF || string(b[:n]) != "world" {
+		t.Errorf("ReadAt past end: got %d %q, %v\n", n, b[:n], err)
+	}
+	if _, err = file.Write(b); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Write on reader: got %v, want EINVAL\n", err)
+	}
+	if err = file.Flush(); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Flush on reader: got %v, want EBADF\n", err)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	m := NewMemFS()
+	writeFile(t, m, "/tmp/a.txt", "a")
+	tests := []struct {
+		path   string
+		flags  int
+		target error
+	}{
+		{"/tmp/a.txt", syscall.O_RDWR, hdfs.ErrUnsupported},
+		{"/tmp/b.txt", hdfs.O_WRONLY | hdfs.O_CREATE | syscall.O_EXCL, hdfs.ErrUnsupported},
+		{"/tmp/missing", hdfs.O_RDONLY, fs.ErrNotExist},
+		{"/tmp/missing", hdfs.O_WRONLY | hdfs.O_APPEND, fs.ErrNotExist},
+		{"/tmp", hdfs.O_RDONLY, hdfs.ErrInternal},
+		{"/tmp/a.txt/b", hdfs.O_WRONLY, hdfs.ErrInternal},
+	}
+	for _, tt := range tests {
+		_, err := m.Open(tt.path, tt.flags, 0, 0, 0)
+		var perr *hdfs.PathError
+		if !errors.Is(err, tt.target) || !errors.As(err, &perr) || perr.Op != "open" || perr.Path != tt.path {
+			t.Errorf("Open(%s, %#o): got %v, want %v\n", tt.path, tt.flags, err, tt.target)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	m := NewMemFS()
+	if err := m.CreateDirectory("/tmp"); err != nil {
+		t.Fatalf("Error on creating directory: %v\n", err)
+	}
+	writeFile(t, m, "/tmp/private", "secret")
+	if err := m.Chmod("/tmp/private", 0600); err != nil {
+		t.Fatalf("Error on changing mode: %v\n", err)
+	}
+
+	nobody := m.AsUser("nobody", "users")
+	if _, err := nobody.Open("/tmp/private", hdfs.O_RDONLY, 0, 0, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Reading private file: got %v, want ErrPermission\n", err)
+	}
+	if _, err := nobody.Open("/tmp/new", hdfs.O_WRONLY, 0, 0, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Creating in 0755 directory: got %v, want ErrPermission\n", err)
+	}
+	if err := nobody.Chmod("/tmp", 0777); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Chmod by non-owner: got %v, want ErrPermission\n", err)
+	}
+	if err := m.Chmod("/tmp", 01777); err != nil {
+		t.Fatalf("Error on changing mode: %v\n", err)
+	}
+	writeFile(t, nobody, "/tmp/mine", "mine")
+	info, err := m.GetPathInfo("/tmp/mine")
+	if err != nil {
+		t.Fatalf("Error on getting path info: %v\n", err)
+	}
+	if info.Owner != "nobody" || info.Group != Supergroup || info.Permissions != 0644 {
+		t.Errorf("Unexpected ownership of new file: %v\n", info)
+	}
+	if err = nobody.Chown("/tmp/mine", "root", ""); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Giving file away: got %v, want ErrPermission\n", err)
+	}
+	if err = nobody.Chown("/tmp/mine", "", "users"); err != nil {
+		t.Errorf("Error on changing group: %v\n", err)
+	}
+	if err = m.Chown("/tmp/mine", "root", ""); err != nil {
+		t.Errorf("Error on changing owner as superuser: %v\n", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	m := NewMemFS()
+	writeFile(t, m, "/a/b/c.txt", "c")
+	writeFile(t, m, "/a/a.txt", "a")
+	if err := m.CreateDirectory("/d"); err != nil {
+		t.Fatalf("Error on creating directory: %v\n", err)
+	}
+
+	infos, err := m.ListDirectory("/a")
+	if err != nil {
+		t.Fatalf("Error on listing directory: %v\n", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if want := []string{"hdfs://localhost:8020/a/a.txt", "hdfs://localhost:8020/a/b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListDirectory: got %v, want %v\n", names, want)
+	}
+	if infos, err = m.ListDirectory("/d"); err != nil || infos == nil || len(infos) != 0 {
+		t.Errorf("Listing empty directory: got %v, %v\n", infos, err)
+	}
+
+	if err = m.Rename("/a/b", "/d"); err != nil {
+		t.Fatalf("Error on renaming into directory: %v\n", err)
+	}
+	if err = m.Exists("/d/b/c.txt"); err != nil {
+		t.Errorf("Renamed file missing: %v\n", err)
+	}
+	if err = m.Rename("/a/a.txt", "/d/b/c.txt"); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Renaming over a file: got %v, want ErrInternal\n", err)
+	}
+	if err = m.Rename("/d", "/d/b/e"); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Renaming into own subtree: got %v, want ErrInternal\n", err)
+	}
+	if err = m.Remove("/d"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Removing directory that is not empty: got %v, want ENOTEMPTY\n", err)
+	}
+	if err = m.Remove("/d/b/c.txt"); err != nil {
+		t.Errorf("Error on removing file: %v\n", err)
+	}
+	if err = m.Delete("/d"); err != nil {
+		t.Errorf("Error on deleting directory: %v\n", err)
+	}
+	if err = m.Exists("/d/b/c.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Deleted file: got %v, want ErrNotExist\n", err)
+	}
+	if err = m.Delete("/d"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Deleting missing path: got %v, want ErrNotExist\n", err)
+	}
+
+	if err = m.SetWorkingDirectory("/a"); err != nil {
+		t.Errorf("Error on setting working directory: %v\n", err)
+	}
+	buffer := make([]byte, 64)
+	if _, err = m.GetWorkingDirectory(buffer, uint32(len(buffer))); err != nil || string(buffer[:bytes.IndexByte(buffer, 0)]) != "hdfs://localhost:8020/a" {
+		t.Errorf("GetWorkingDirectory: got %q, %v\n", buffer, err)
+	}
+	if s := readFile(t, m, "a.txt"); s != "a" {
+		t.Errorf("Reading relative path: got %q\n", s)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	now := time.Unix(1334475316, 0)
+	m := NewMemFS()
+	m.SetClock(func() time.Time { return now })
+	m.SetDefaults(4, 3)
+	m.SetDatanodes("dn1", "dn2")
+	writeFile(t, m, "/f", "0123456789")
+
+	info, err := m.GetPathInfo("/f")
+	if err != nil {
+		t.Fatalf("Error on getting path info: %v\n", err)
+	}
+	if info.Kind != 'F' || info.Size != 10 || info.BlockSize != 4 || info.Replication != 3 || !info.LastMod.Equal(now) {
+		t.Errorf("Unexpected path info: %v\n", info)
+	}
+	if used, _ := m.GetUsed(); used != 30 {
+		t.Errorf("GetUsed: got %d, want 30\n", used)
+	}
+	m.SetNonDFSUsed(70)
+	if remaining, _ := m.GetRemaining(); remaining != 1<<40-100 {
+		t.Errorf("GetRemaining: got %d, want %d\n", remaining, 1<<40-100)
+	}
+	if err = m.SetReplication("/f", 1); err != nil {
+		t.Errorf("Error on setting replication: %v\n", err)
+	}
+	hosts, err := m.GetHosts("/f", 3, 2)
+	if want := [][]string{{"dn1"}, {"dn2"}}; err != nil || !reflect.DeepEqual(hosts, want) {
+		t.Errorf("GetHosts: got %v, %v; want %v\n", hosts, err, want)
+	}
+	mtime, atime := now.Add(-time.Hour), now.Add(-time.Minute)
+	if err = m.Utime("/f", mtime, atime); err != nil {
+		t.Errorf("Error on setting times: %v\n", err)
+	}
+	if info, _ = m.GetPathInfo("/f"); !info.LastMod.Equal(mtime) || !info.LastAccess.Equal(atime) {
+		t.Errorf("Utime not applied: %v\n", info)
+	}
+	if err = m.Disconnect(); err != nil {
+		t.Errorf("Error on disconnecting: %v\n", err)
+	}
+	if err = m.Exists("/f"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Use after disconnect: got %v, want ErrClosed\n", err)
+	}
+}
+
+func TestXAttrs(t *testing.T) {
+	m := NewMemFS()
+	writeFile(t, m, "/f", "data")
+	m.Chmod("/f", 0644)
+
+	if err := m.SetXAttr("/f", "USER.a", []byte("1"), hdfs.XATTR_CREATE); err != nil {
+		t.Errorf("Error on creating an attribute: %v\n", err)
+	}
+	for _, c := range []struct {
+		name  string
+		flags int
+		want  error
+	}{
+		{"user.a", hdfs.XATTR_CREATE, fs.ErrExist},
+		{"user.b", hdfs.XATTR_REPLACE, hdfs.ErrNoXAttr},
+		{"system.a", 0, syscall.EINVAL},
+		{"a", 0, syscall.EINVAL},
+		{"user.", 0, syscall.EINVAL},
+	} {
+		if err := m.SetXAttr("/f", c.name, nil, c.flags); !errors.Is(err, c.want) {
+			t.Errorf("SetXAttr %s with flags %d: got %v, want %v\n", c.name, c.flags, err, c.want)
+		}
+	}
+	for _, name := range []string{"user.a", "user.b", "trusted.c"} {
+		if err := m.SetXAttr("/f", name, []byte(name), 0); err != nil {
+			t.Errorf("Error on setting %s: %v\n", name, err)
+		}
+	}
+	if value, err := m.GetXAttr("/f", "user.a"); err != nil || string(value) != "user.a" {
+		t.Errorf("GetXAttr: got %q, %v\n", value, err)
+	}
+	if _, err := m.GetXAttr("/f", "user.missing"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("GetXAttr of a missing attribute: got %v\n", err)
+	}
+	if _, err := m.GetXAttrs("/f", "user.a", "user.missing"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("GetXAttrs with a missing attribute: got %v\n", err)
+	}
+	if names, err := m.ListXAttrs("/f"); err != nil || !reflect.DeepEqual(names, []string{"trusted.c", "user.a", "user.b"}) {
+		t.Errorf("ListXAttrs: got %v, %v\n", names, err)
+	}
+
+	// trusted attributes are kept to the superuser, user ones follow the
+	// permission bits
+	u := m.AsUser("alice")
+	if names, err := u.ListXAttrs("/f"); err != nil || !reflect.DeepEqual(names, []string{"user.a", "user.b"}) {
+		t.Errorf("ListXAttrs as another user: got %v, %v\n", names, err)
+	}
+	if values, err := u.GetXAttrs("/f"); err != nil || len(values) != 2 {
+		t.Errorf("GetXAttrs as another user: got %v, %v\n", values, err)
+	}
+	if _, err := u.GetXAttr("/f", "trusted.c"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("GetXAttr of a trusted attribute: got %v\n", err)
+	}
+	if err := u.SetXAttr("/f", "user.a", nil, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("SetXAttr without write permission: got %v\n", err)
+	}
+
+	if err := m.RemoveXAttr("/f", "user.a"); err != nil {
+		t.Errorf("Error on removing an attribute: %v\n", err)
+	}
+	if err := m.RemoveXAttr("/f", "user.a"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("RemoveXAttr of a missing attribute: got %v\n", err)
+	}
+	m.Rename("/f", "/g")
+	if values, err := m.GetXAttrs("/g"); err != nil || len(values) != 2 || string(values["user.b"]) != "user.b" {
+		t.Errorf("GetXAttrs after rename: got %v, %v\n", values, err)
+	}
+	if _, err := m.ListXAttrs("/f"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ListXAttrs of a missing path: got %v\n", err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	m := NewMemFS()
+	files := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"}
+	for _, name := range files {
+		writeFile(t, m, "/data/"+name, "content of "+name)
+	}
+	fsys, err := fs.Sub(hdfs.FS(m), "data")
+	if err != nil {
+		t.Fatalf("Error on fs.Sub: %v\n", err)
+	}
+	if err = fstest.TestFS(fsys, files...); err != nil {
+		t.Errorf("fstest.TestFS: %v\n", err)
+	}
+}
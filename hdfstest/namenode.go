@@ -0,0 +1,1281 @@
+package hdfstest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+	"github.com/zyxar/hdfs/internal/rpc"
+)
+
+const (
+	// defaultListingLimit is the number of entries per getListing call, as
+	// dfs.ls.limit.
+	defaultListingLimit = 1000
+	// poolID is the block pool of the blocks a Namenode allocates.
+	poolID = "BP-hdfstest"
+	// firstGenerationStamp is the generation stamp of new blocks.
+	firstGenerationStamp = 1000
+)
+
+// Namenode is a fake namenode serving ClientNamenodeProtocol over Hadoop
+// IPC from a MemFS, for testing the pure-Go backend without a cluster.
+// Calls run as the user named in the connection context, with the groups
+// set by SetGroups; the superuser is in the supergroup.
+//
+// Without datanodes, block locations name the hosts of
+// MemFS.SetDatanodes and hold no data. Once datanodes are added with
+// AddDatanode, file data lives on them: the content of a file is split
+// into blocks and stored on its datanodes when its locations are first
+// asked for, and files written by clients are committed to the MemFS when
+// they are completed.
+type Namenode struct {
+	m      *MemFS
+	l      net.Listener
+	server *rpc.Server
+
+	mu           sync.Mutex
+	groups       map[string][]string
+	listingLimit int
+	datanodes    []*Datanode
+	files        map[*node]*blockList // blocks of file content on the datanodes
+	leases       map[*node]*lease     // files being written by clients
+	nextBlockID  uint64
+	nextGS       uint64
+	nextPlace    int
+}
+
+// block is a block of a file and the datanodes holding its replicas.
+type block struct {
+	id, gs uint64
+	length int64
+	locs   []*Datanode
+}
+
+// blockList is the blocks of a file, holding data.
+type blockList struct {
+	blocks []*block
+	data   []byte
+}
+
+// lease is a file written by a client, through file. The blocks of the
+// file from index appended on are new.
+type lease struct {
+	file     *memFile
+	client   string
+	blocks   []*block
+	appended int
+}
+
+// NewNamenode starts a Namenode for m on a local port.
+func NewNamenode(m *MemFS) (*Namenode, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	nn := &Namenode{
+		m:            m,
+		l:            l,
+		groups:       map[string][]string{Superuser: {Supergroup}},
+		listingLimit: defaultListingLimit,
+		files:        map[*node]*blockList{},
+		leases:       map[*node]*lease{},
+		nextBlockID:  1 << 30,
+		nextGS:       firstGenerationStamp,
+	}
+	nn.server = rpc.NewServer(l, hdfsproto.ClientProtocol, nn.serve)
+	go nn.server.Serve()
+	return nn, nil
+}
+
+// Addr returns the host:port the Namenode listens on.
+func (nn *Namenode) Addr() string {
+	return nn.l.Addr().String()
+}
+
+// Close stops the Namenode and its datanodes, closing their connections.
+func (nn *Namenode) Close() error {
+	err := nn.server.Close()
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	for _, dn := range nn.datanodes {
+		dn.Close()
+	}
+	return err
+}
+
+// AddDatanode starts a Datanode on a local port and registers it, with the
+// host name "dn1" for the first one, "dn2" for the second, and so on.
+// Replicas are placed round-robin over the datanodes.
+func (nn *Namenode) AddDatanode() (*Datanode, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	ipc, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	nn.mu.Lock()
+	dn := newDatanode(fmt.Sprintf("dn%d", len(nn.datanodes)+1), l, ipc)
+	nn.datanodes = append(nn.datanodes, dn)
+	nn.mu.Unlock()
+	go dn.serve()
+	return dn, nil
+}
+
+// SetGroups sets the groups of user.
+func (nn *Namenode) SetGroups(user string, groups ...string) {
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	nn.groups[user] = groups
+}
+
+// SetListingLimit sets the number of entries returned per getListing call.
+func (nn *Namenode) SetListingLimit(limit int) {
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	nn.listingLimit = limit
+}
+
+type handler func(nn *Namenode, m *MemFS, req []byte) (protowire.Marshaler, error)
+
+var handlers = map[string]handler{
+	"getFileInfo":       (*Namenode).getFileInfo,
+	"getListing":        (*Namenode).getListing,
+	"mkdirs":            (*Namenode).mkdirs,
+	"rename":            (*Namenode).rename,
+	"delete":            (*Namenode).delete,
+	"setPermission":     (*Namenode).setPermission,
+	"setOwner":          (*Namenode).setOwner,
+	"setReplication":    (*Namenode).setReplication,
+	"setTimes":          (*Namenode).setTimes,
+	"getContentSummary": (*Namenode).getContentSummary,
+	"setQuota":          (*Namenode).setQuota,
+	"getFsStats":        (*Namenode).getFsStats,
+	"getServerDefaults": (*Namenode).getServerDefaults,
+	"getBlockLocations": (*Namenode).getBlockLocations,
+	"truncate":          (*Namenode).truncate,
+	"isFileClosed":      (*Namenode).isFileClosed,
+	"concat":            (*Namenode).concat,
+	"setXAttr":          (*Namenode).setXAttr,
+	"getXAttrs":         (*Namenode).getXAttrs,
+	"listXAttrs":        (*Namenode).listXAttrs,
+	"removeXAttr":       (*Namenode).removeXAttr,
+	"getAclStatus":      (*Namenode).getAclStatus,
+	"setAcl":            (*Namenode).setAcl,
+	"modifyAclEntries":  (*Namenode).modifyAclEntries,
+	"removeAclEntries":  (*Namenode).removeAclEntries,
+	"removeDefaultAcl":  (*Namenode).removeDefaultAcl,
+	"removeAcl":         (*Namenode).removeAcl,
+
+	"allowSnapshot":              (*Namenode).allowSnapshot,
+	"disallowSnapshot":           (*Namenode).disallowSnapshot,
+	"createSnapshot":             (*Namenode).createSnapshot,
+	"deleteSnapshot":             (*Namenode).deleteSnapshot,
+	"renameSnapshot":             (*Namenode).renameSnapshot,
+	"getSnapshottableDirListing": (*Namenode).getSnapshottableDirListing,
+	"getSnapshotDiffReport":      (*Namenode).getSnapshotDiffReport,
+
+	"getEZForPath":        (*Namenode).getEZForPath,
+	"listEncryptionZones": (*Namenode).listEncryptionZones,
+
+	"create":                 (*Namenode).create,
+	"append":                 (*Namenode).append,
+	"addBlock":               (*Namenode).addBlock,
+	"abandonBlock":           (*Namenode).abandonBlock,
+	"complete":               (*Namenode).complete,
+	"fsync":                  (*Namenode).fsync,
+	"updateBlockForPipeline": (*Namenode).updateBlockForPipeline,
+	"updatePipeline":         (*Namenode).updatePipeline,
+	"renewLease":             (*Namenode).renewLease,
+}
+
+func (nn *Namenode) serve(user, method string, req []byte) (protowire.Marshaler, error) {
+	h := handlers[method]
+	if h == nil {
+		return nil, &rpc.RemoteError{Class: "org.apache.hadoop.ipc.RpcNoSuchMethodException", Message: "Unknown method " + method}
+	}
+	nn.mu.Lock()
+	groups := nn.groups[user]
+	nn.mu.Unlock()
+	resp, err := h(nn, nn.m.AsUser(user, groups...), req)
+	if err != nil {
+		return nil, exception(err)
+	}
+	return resp, nil
+}
+
+// exception turns a MemFS error into the exception a namenode would throw.
+func exception(err error) error {
+	class := "java.io.IOException"
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		class = "java.io.FileNotFoundException"
+	case errors.Is(err, fs.ErrPermission):
+		class = "org.apache.hadoop.security.AccessControlException"
+	case errors.Is(err, syscall.ENOTEMPTY): // before fs.ErrExist, which it matches
+		class = "org.apache.hadoop.fs.PathIsNotEmptyDirectoryException"
+	case errors.Is(err, fs.ErrExist):
+		class = "org.apache.hadoop.fs.FileAlreadyExistsException"
+	case errors.Is(err, syscall.ENOTDIR):
+		class = "org.apache.hadoop.fs.ParentNotDirectoryException"
+	case errors.Is(err, hdfs.ErrUnsupported):
+		class = "java.lang.UnsupportedOperationException"
+	case errors.Is(err, syscall.EINVAL):
+		class = "org.apache.hadoop.HadoopIllegalArgumentException"
+	case errors.Is(err, syscall.EDQUOT):
+		class = "org.apache.hadoop.hdfs.protocol.QuotaExceededException"
+	case errors.Is(err, hdfs.ErrNoXAttr):
+		return &rpc.RemoteError{Class: class, Message: "At least one of the attributes provided was not found."}
+	case errors.Is(err, syscall.EXDEV):
+		return &rpc.RemoteError{Class: class, Message: err.Error() + ": can't be moved from an encryption zone."}
+	}
+	return &rpc.RemoteError{Class: class, Message: err.Error()}
+}
+
+// result reports err as a false result if it is one of the failures the
+// namenode reports that way, and as an exception otherwise.
+func result(err error) (protowire.Marshaler, error) {
+	if err == nil {
+		return &hdfsproto.BoolResponse{Result: true}, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, hdfs.ErrInternal) {
+		return &hdfsproto.BoolResponse{}, nil
+	}
+	return nil, err
+}
+
+func unmarshal(req []byte, m protowire.Unmarshaler) error {
+	if err := protowire.Unmarshal(req, m); err != nil {
+		return &rpc.RemoteError{Class: "com.google.protobuf.InvalidProtocolBufferException", Message: err.Error()}
+	}
+	return nil
+}
+
+func millis(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func fileStatus(info *hdfs.FileInfo, name string) *hdfsproto.FileStatus {
+	st := &hdfsproto.FileStatus{
+		FileType:         hdfsproto.IsFile,
+		Path:             []byte(name),
+		Length:           uint64(info.Size),
+		Permission:       uint32(info.Permissions),
+		Owner:            info.Owner,
+		Group:            info.Group,
+		ModificationTime: millis(info.LastMod),
+		AccessTime:       millis(info.LastAccess),
+		BlockReplication: uint32(info.Replication),
+		BlockSize:        uint64(info.BlockSize),
+	}
+	if info.IsDir() {
+		st.FileType = hdfsproto.IsDir
+		st.Length, st.AccessTime, st.BlockReplication, st.BlockSize = 0, 0, 0, 0
+	}
+	return st
+}
+
+func (nn *Namenode) getFileInfo(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	info, err := m.GetPathInfo(r.Src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &hdfsproto.GetFileInfoResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.GetFileInfoResponse{FS: fileStatus(info, "")}, nil
+}
+
+func (nn *Namenode) getListing(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.GetListingRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	info, err := m.GetPathInfo(r.Src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &hdfsproto.GetListingResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &hdfsproto.GetListingResponse{Found: true, PartialListing: []*hdfsproto.FileStatus{fileStatus(info, "")}}, nil
+	}
+	infos, err := m.ListDirectory(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	after := string(r.StartAfter)
+	i := sort.Search(len(infos), func(i int) bool { return path.Base(infos[i].Name) > after })
+	infos = infos[i:]
+	nn.mu.Lock()
+	limit := nn.listingLimit
+	nn.mu.Unlock()
+	resp := &hdfsproto.GetListingResponse{Found: true}
+	if len(infos) > limit {
+		resp.RemainingEntries = uint32(len(infos) - limit)
+		infos = infos[:limit]
+	}
+	for _, info := range infos {
+		resp.PartialListing = append(resp.PartialListing, fileStatus(info, path.Base(info.Name)))
+	}
+	return resp, nil
+}
+
+func (nn *Namenode) mkdirs(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.MkdirsRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if err := m.CreateDirectory(r.Src); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.BoolResponse{Result: true}, nil
+}
+
+func (nn *Namenode) rename(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.RenameRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return result(m.Rename(r.Src, r.Dst))
+}
+
+func (nn *Namenode) delete(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.DeleteRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if !r.Recursive {
+		return result(m.Remove(r.Src))
+	}
+	return result(m.Delete(r.Src))
+}
+
+func (nn *Namenode) setPermission(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetPermissionRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.Chmod(r.Src, int16(r.Permission))
+}
+
+func (nn *Namenode) setOwner(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetOwnerRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.Chown(r.Src, r.Username, r.Groupname)
+}
+
+func (nn *Namenode) setReplication(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetReplicationRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return result(m.SetReplication(r.Src, int16(r.Replication)))
+}
+
+func (nn *Namenode) truncate(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.TruncateRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	done, err := m.TruncateFile(r.Src, int64(r.NewLength))
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.BoolResponse{Result: done}, nil
+}
+
+func (nn *Namenode) isFileClosed(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.IsFileClosedRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	closed, err := m.IsFileClosed(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.BoolResponse{Result: closed}, nil
+}
+
+func (nn *Namenode) concat(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.ConcatRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if err := m.ConcatFiles(r.Trg, r.Srcs); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, nil
+}
+
+// xattrPrefixes are the name prefixes of the XAttrNamespaceProto values.
+var xattrPrefixes = []string{"user.", "trusted.", "security.", "system.", "raw."}
+
+func xattrName(x *hdfsproto.XAttr) string {
+	if x.Namespace >= uint64(len(xattrPrefixes)) {
+		return x.Name
+	}
+	return xattrPrefixes[x.Namespace] + x.Name
+}
+
+// xattrProto splits the name of an extended attribute back into its
+// namespace and the rest.
+func xattrProto(name string, value []byte) *hdfsproto.XAttr {
+	for ns, prefix := range xattrPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return &hdfsproto.XAttr{Namespace: uint64(ns), Name: name[len(prefix):], Value: value}
+		}
+	}
+	return &hdfsproto.XAttr{Name: name, Value: value}
+}
+
+func (nn *Namenode) setXAttr(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetXAttrRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if r.XAttr == nil {
+		return nil, pathError("setxattr", r.Src, syscall.EINVAL)
+	}
+	return &hdfsproto.Empty{}, m.SetXAttr(r.Src, xattrName(r.XAttr), r.XAttr.Value, int(r.Flag))
+}
+
+func (nn *Namenode) getXAttrs(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.XAttrsRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(r.XAttrs))
+	for i, x := range r.XAttrs {
+		names[i] = xattrName(x)
+	}
+	values, err := m.GetXAttrs(r.Src, names...)
+	if err != nil {
+		return nil, err
+	}
+	resp := &hdfsproto.XAttrsResponse{}
+	for name, value := range values {
+		resp.XAttrs = append(resp.XAttrs, xattrProto(name, value))
+	}
+	return resp, nil
+}
+
+func (nn *Namenode) listXAttrs(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	names, err := m.ListXAttrs(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	resp := &hdfsproto.XAttrsResponse{}
+	for _, name := range names {
+		resp.XAttrs = append(resp.XAttrs, xattrProto(name, nil))
+	}
+	return resp, nil
+}
+
+func (nn *Namenode) removeXAttr(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.XAttrsRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if len(r.XAttrs) != 1 {
+		return nil, pathError("removexattr", r.Src, syscall.EINVAL)
+	}
+	return &hdfsproto.Empty{}, m.RemoveXAttr(r.Src, xattrName(r.XAttrs[0]))
+}
+
+func (nn *Namenode) getAclStatus(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	s, err := m.GetAclStatus(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	st := &hdfsproto.AclStatus{Owner: s.Owner, Group: s.Group, Sticky: s.Sticky, Permission: uint32(s.Permission)}
+	for _, e := range s.Entries {
+		st.Entries = append(st.Entries, &hdfsproto.AclEntry{Type: uint32(e.Type), Scope: uint32(e.Scope), Permissions: uint32(e.Perm), Name: e.Name})
+	}
+	return &hdfsproto.GetAclStatusResponse{Result: st}, nil
+}
+
+// aclRequest decodes an AclRequest and calls change with its path and
+// entries.
+func aclRequest(req []byte, change func(p string, entries []hdfs.AclEntry) error) (protowire.Marshaler, error) {
+	var r hdfsproto.AclRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	entries := make([]hdfs.AclEntry, len(r.AclSpec))
+	for i, e := range r.AclSpec {
+		entries[i] = hdfs.AclEntry{Scope: hdfs.AclScope(e.Scope), Type: hdfs.AclType(e.Type), Name: e.Name, Perm: int16(e.Permissions)}
+	}
+	return &hdfsproto.Empty{}, change(r.Src, entries)
+}
+
+func (nn *Namenode) setAcl(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	return aclRequest(req, m.SetAcl)
+}
+
+func (nn *Namenode) modifyAclEntries(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	return aclRequest(req, m.ModifyAclEntries)
+}
+
+func (nn *Namenode) removeAclEntries(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	return aclRequest(req, m.RemoveAclEntries)
+}
+
+func (nn *Namenode) removeDefaultAcl(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.RemoveDefaultAcl(r.Src)
+}
+
+func (nn *Namenode) removeAcl(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.RemoveAcl(r.Src)
+}
+
+func (nn *Namenode) allowSnapshot(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.AllowSnapshot(r.Src)
+}
+
+func (nn *Namenode) disallowSnapshot(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.DisallowSnapshot(r.Src)
+}
+
+func (nn *Namenode) createSnapshot(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SnapshotRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	p, err := m.CreateSnapshot(r.SnapshotRoot, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.CreateSnapshotResponse{SnapshotPath: p}, nil
+}
+
+func (nn *Namenode) deleteSnapshot(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SnapshotRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.DeleteSnapshot(r.SnapshotRoot, r.Name)
+}
+
+func (nn *Namenode) renameSnapshot(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SnapshotRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	return &hdfsproto.Empty{}, m.RenameSnapshot(r.SnapshotRoot, r.Name, r.NewName)
+}
+
+func (nn *Namenode) getSnapshottableDirListing(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	dirs, err := m.ListSnapshottableDirs()
+	if err != nil {
+		return nil, err
+	}
+	resp := &hdfsproto.GetSnapshottableDirListingResponse{}
+	for _, dir := range dirs {
+		info, err := m.GetPathInfo(dir.Path)
+		if err != nil {
+			return nil, err
+		}
+		parent, name := path.Split(dir.Path)
+		resp.Dirs = append(resp.Dirs, &hdfsproto.SnapshottableDirStatus{
+			DirStatus:      *fileStatus(info, name),
+			SnapshotQuota:  uint32(dir.SnapshotQuota),
+			SnapshotNumber: uint32(dir.Snapshots),
+			ParentFullPath: []byte(path.Clean(parent)),
+		})
+	}
+	return resp, nil
+}
+
+// diffPath returns the relative path of a SnapshotDiffEntry as the
+// namenode sends it, empty for the snapshot root.
+func diffPath(p string) []byte {
+	if p == "." {
+		return []byte{}
+	}
+	return []byte(p)
+}
+
+func (nn *Namenode) getSnapshotDiffReport(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.GetSnapshotDiffReportRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	report, err := m.SnapshotDiff(r.SnapshotRoot, r.FromSnapshot, r.ToSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	resp := &hdfsproto.GetSnapshotDiffReportResponse{Report: hdfsproto.SnapshotDiffReport{
+		SnapshotRoot: r.SnapshotRoot,
+		FromSnapshot: r.FromSnapshot,
+		ToSnapshot:   r.ToSnapshot,
+	}}
+	for _, e := range report.Entries {
+		entry := &hdfsproto.SnapshotDiffReportEntry{Fullpath: diffPath(e.Path), ModificationLabel: e.Type.Label()}
+		if e.Type == hdfs.DiffRename {
+			entry.TargetPath = diffPath(e.Target)
+		}
+		resp.Report.Entries = append(resp.Report.Entries, entry)
+	}
+	return resp, nil
+}
+
+func (nn *Namenode) setTimes(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetTimesRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	info, err := m.GetPathInfo(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	mtime, atime := info.LastMod, info.LastAccess
+	if r.Mtime != -1 {
+		mtime = time.Unix(0, r.Mtime*int64(time.Millisecond))
+	}
+	if r.Atime != -1 {
+		atime = time.Unix(0, r.Atime*int64(time.Millisecond))
+	}
+	return &hdfsproto.Empty{}, m.Utime(r.Src, mtime, atime)
+}
+
+func (nn *Namenode) getContentSummary(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	cs, err := m.GetContentSummary(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	s := hdfsproto.ContentSummary{
+		Length:         uint64(cs.Length),
+		FileCount:      uint64(cs.FileCount),
+		DirectoryCount: uint64(cs.DirectoryCount),
+		Quota:          uint64(cs.Quota),
+		SpaceConsumed:  uint64(cs.SpaceConsumed),
+		SpaceQuota:     uint64(cs.SpaceQuota),
+	}
+	// like the namenode, list every storage type once one has a quota
+	noQuota := ^uint64(0) // -1
+	if len(cs.TypeQuotas) > 0 {
+		for t := hdfs.StorageDisk; t <= hdfs.StorageNVDIMM; t++ {
+			if !t.SupportsQuota() {
+				continue
+			}
+			info := &hdfsproto.StorageTypeQuotaInfo{Type: uint64(t) + 1, Quota: noQuota}
+			if t == hdfs.StorageDisk { // where MemFS keeps all data
+				info.Consumed = uint64(cs.SpaceConsumed)
+			}
+			if tq, ok := cs.TypeQuotas[t]; ok {
+				info.Quota, info.Consumed = uint64(tq.Quota), uint64(tq.Consumed)
+			}
+			s.TypeQuotaInfos = append(s.TypeQuotaInfos, info)
+		}
+	}
+	return &hdfsproto.GetContentSummaryResponse{Summary: s}, nil
+}
+
+func (nn *Namenode) setQuota(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SetQuotaRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if r.StorageType != 0 {
+		return &hdfsproto.Empty{}, m.SetTypeQuota(r.Path, hdfs.StorageType(r.StorageType-1), int64(r.StoragespaceQuota))
+	}
+	return &hdfsproto.Empty{}, m.SetQuota(r.Path, int64(r.NamespaceQuota), int64(r.StoragespaceQuota))
+}
+
+func (nn *Namenode) getFsStats(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	capacity, err := m.GetCapacity()
+	if err != nil {
+		return nil, err
+	}
+	used, err := m.GetUsed()
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := m.GetRemaining()
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.GetFsStatsResponse{Capacity: uint64(capacity), Used: uint64(used), Remaining: uint64(remaining)}, nil
+}
+
+func (nn *Namenode) getServerDefaults(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	return &hdfsproto.GetServerDefaultsResponse{ServerDefaults: hdfsproto.ServerDefaults{
+		BlockSize:        uint64(m.t.blockSize),
+		BytesPerChecksum: 512,
+		WritePacketSize:  64 << 10,
+		Replication:      uint32(m.t.replication),
+		FileBufferSize:   4096,
+		ChecksumType:     2, // CHECKSUM_CRC32C
+		TrashInterval:    uint64(m.t.trash / time.Minute),
+	}}, nil
+}
+
+func encryptionZone(z zone) *hdfsproto.EncryptionZone {
+	return &hdfsproto.EncryptionZone{
+		ID:                    z.id,
+		Path:                  z.path,
+		Suite:                 hdfsproto.CipherAESCTRNoPadding,
+		CryptoProtocolVersion: hdfsproto.CryptoEncryptionZones,
+		KeyName:               z.key,
+	}
+}
+
+func (nn *Namenode) getEZForPath(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.SrcRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if err := m.Exists(r.Src); err != nil {
+		return nil, err
+	}
+	m.t.Lock()
+	defer m.t.Unlock()
+	var resp hdfsproto.GetEZForPathResponse
+	if z, ok := m.t.zone(m.abs(r.Src)); ok {
+		resp.Zone = encryptionZone(z)
+	}
+	return &resp, nil
+}
+
+// listEncryptionZones lists the zones in a single batch; only the superuser
+// may.
+func (nn *Namenode) listEncryptionZones(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.ListEncryptionZonesRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	if !m.superuser() {
+		return nil, pathError("listzones", "", syscall.EACCES)
+	}
+	m.t.Lock()
+	defer m.t.Unlock()
+	var resp hdfsproto.ListEncryptionZonesResponse
+	for _, z := range m.t.zones() {
+		if z.id > r.PrevID {
+			resp.Zones = append(resp.Zones, encryptionZone(z))
+		}
+	}
+	return &resp, nil
+}
+
+func (nn *Namenode) getBlockLocations(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.GetBlockLocationsRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	if len(nn.datanodes) == 0 {
+		return hostLocations(m, &r)
+	}
+	n, err := lookupFile(m, "gethosts", r.Src, permRead)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &hdfsproto.GetBlockLocationsResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if l := nn.leases[n]; l != nil {
+		return &hdfsproto.GetBlockLocationsResponse{Locations: leaseLocations(l, &r)}, nil
+	}
+	bl := nn.blocksOf(n)
+	locs := &hdfsproto.LocatedBlocks{FileLength: uint64(len(bl.data)), IsLastBlockComplete: true}
+	start, end := int64(r.Offset), int64(r.Offset+r.Length)
+	var off int64
+	for _, b := range bl.blocks {
+		if off < end && off+b.length > start {
+			locs.Blocks = append(locs.Blocks, located(b, off))
+		}
+		off += b.length
+	}
+	return &hdfsproto.GetBlockLocationsResponse{Locations: locs}, nil
+}
+
+// leaseLocations answers getBlockLocations for a file being written, as a
+// namenode does: the file length counts only the complete blocks, and the
+// new block being written, if any, is the last block, with the length of
+// the last fsync. Readers ask its datanodes for the rest.
+func leaseLocations(l *lease, r *hdfsproto.GetBlockLocationsRequest) *hdfsproto.LocatedBlocks {
+	locs := &hdfsproto.LocatedBlocks{UnderConstruction: true, IsLastBlockComplete: true}
+	start, end := int64(r.Offset), int64(r.Offset+r.Length)
+	var off int64
+	for i, b := range l.blocks {
+		lb := located(b, off)
+		writing := i == len(l.blocks)-1 && i >= l.appended
+		if off < end && (off+b.length > start || writing) {
+			locs.Blocks = append(locs.Blocks, lb)
+		}
+		if i == len(l.blocks)-1 {
+			locs.LastBlock, locs.IsLastBlockComplete = lb, !writing
+		}
+		if !writing {
+			locs.FileLength += uint64(b.length)
+		}
+		off += b.length
+	}
+	return locs
+}
+
+// hostLocations answers getBlockLocations without datanodes, from the hosts
+// of m.
+func hostLocations(m *MemFS, r *hdfsproto.GetBlockLocationsRequest) (protowire.Marshaler, error) {
+	info, err := m.GetPathInfo(r.Src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &hdfsproto.GetBlockLocationsResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, notFile(r.Src)
+	}
+	hosts, err := m.GetHosts(r.Src, int64(r.Offset), int64(r.Length))
+	if err != nil {
+		return nil, err
+	}
+	locs := &hdfsproto.LocatedBlocks{FileLength: uint64(info.Size), IsLastBlockComplete: true}
+	first := int64(r.Offset) / info.BlockSize
+	for i, names := range hosts {
+		off := (first + int64(i)) * info.BlockSize
+		size := info.Size - off
+		if size > info.BlockSize {
+			size = info.BlockSize
+		}
+		b := &hdfsproto.LocatedBlock{Block: hdfsproto.ExtendedBlock{PoolID: poolID, BlockID: uint64(first + int64(i)), NumBytes: uint64(size)}, Offset: uint64(off)}
+		for _, name := range names {
+			b.Locs = append(b.Locs, &hdfsproto.DatanodeInfo{IPAddr: "127.0.0.1", HostName: name, XferPort: 50010})
+		}
+		locs.Blocks = append(locs.Blocks, b)
+	}
+	return &hdfsproto.GetBlockLocationsResponse{Locations: locs}, nil
+}
+
+func notFile(p string) error {
+	return &rpc.RemoteError{Class: "java.io.FileNotFoundException", Message: "Path is not a file: " + p}
+}
+
+// lookupFile returns the node of the file p, which the user of m must be
+// allowed to access as want.
+func lookupFile(m *MemFS, op, p string, want int16) (*node, error) {
+	if err := m.begin(op, p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, pathError(op, p, err)
+	}
+	if n.dir {
+		return nil, notFile(p)
+	}
+	if !m.access(n, want) {
+		return nil, pathError(op, p, syscall.EACCES)
+	}
+	return n, nil
+}
+
+func located(b *block, off int64) *hdfsproto.LocatedBlock {
+	lb := &hdfsproto.LocatedBlock{
+		Block:  hdfsproto.ExtendedBlock{PoolID: poolID, BlockID: b.id, GenerationStamp: b.gs, NumBytes: uint64(b.length)},
+		Offset: uint64(off),
+	}
+	for _, dn := range b.locs {
+		lb.Locs = append(lb.Locs, dn.info())
+	}
+	return lb
+}
+
+// blocksOf returns the blocks holding the content of n, first storing it
+// on the datanodes if it changed since. nn.mu must be held.
+func (nn *Namenode) blocksOf(n *node) *blockList {
+	nn.m.t.Lock()
+	data, replication, blockSize := n.data, int(n.replication), n.blockSize
+	nn.m.t.Unlock()
+	if bl := nn.files[n]; bl != nil && len(bl.data) == len(data) && (len(data) == 0 || &bl.data[0] == &data[0]) {
+		return bl
+	}
+	if old := nn.files[n]; old != nil {
+		for _, b := range old.blocks {
+			for _, dn := range b.locs {
+				dn.remove(b.id)
+			}
+		}
+	}
+	bl := &blockList{data: data}
+	for off := int64(0); off < int64(len(data)); off += blockSize {
+		end := off + blockSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		b := nn.newBlock(replication, nil)
+		b.length = end - off
+		for _, dn := range b.locs {
+			dn.put(b.id, b.gs, data[off:end])
+		}
+		bl.blocks = append(bl.blocks, b)
+	}
+	nn.files[n] = bl
+	return bl
+}
+
+// newBlock allocates a block on up to replication datanodes, skipping
+// those named in exclude. nn.mu must be held.
+func (nn *Namenode) newBlock(replication int, exclude map[string]bool) *block {
+	b := &block{id: nn.nextBlockID, gs: nn.nextGS}
+	nn.nextBlockID++
+	for i := 0; i < len(nn.datanodes) && len(b.locs) < replication; i++ {
+		dn := nn.datanodes[(nn.nextPlace+i)%len(nn.datanodes)]
+		if !exclude[dn.name] {
+			b.locs = append(b.locs, dn)
+		}
+	}
+	nn.nextPlace++
+	return b
+}
+
+func (nn *Namenode) datanode(uuid string) *Datanode {
+	for _, dn := range nn.datanodes {
+		if dn.name == uuid {
+			return dn
+		}
+	}
+	return nil
+}
+
+// leaseOf returns the lease of client on src. nn.mu must be held.
+func (nn *Namenode) leaseOf(m *MemFS, src, client string) (*lease, *node, error) {
+	if err := m.begin("lease", src); err != nil {
+		return nil, nil, err
+	}
+	_, n, err := m.lookup(m.abs(src))
+	m.t.Unlock()
+	if err == nil && nn.leases[n] != nil && nn.leases[n].client == client {
+		return nn.leases[n], n, nil
+	}
+	return nil, nil, &rpc.RemoteError{Class: "org.apache.hadoop.hdfs.server.namenode.LeaseExpiredException", Message: "No lease on " + src}
+}
+
+// find returns the block of l with the given ID.
+func (l *lease) find(id uint64) (*block, error) {
+	for _, b := range l.blocks {
+		if b.id == id {
+			return b, nil
+		}
+	}
+	return nil, &rpc.RemoteError{Class: "java.io.IOException", Message: fmt.Sprintf("unknown block %d", id)}
+}
+
+// commit records the length the client wrote to a block.
+func (l *lease) commit(eb *hdfsproto.ExtendedBlock) error {
+	b, err := l.find(eb.BlockID)
+	if err != nil {
+		return err
+	}
+	b.gs, b.length = eb.GenerationStamp, int64(eb.NumBytes)
+	return nil
+}
+
+var errNoDatanodes = &rpc.RemoteError{Class: "java.io.IOException", Message: "no datanode to write to"}
+
+func (nn *Namenode) create(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.CreateRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	if len(nn.datanodes) == 0 {
+		return nil, errNoDatanodes
+	}
+	if r.CreateFlag&hdfsproto.CreateFlagOverwrite == 0 {
+		if _, err := m.GetPathInfo(r.Src); err == nil {
+			return nil, &rpc.RemoteError{Class: "org.apache.hadoop.fs.FileAlreadyExistsException", Message: r.Src + " already exists"}
+		}
+	}
+	if !r.CreateParent {
+		if _, err := m.GetPathInfo(path.Dir(r.Src)); err != nil {
+			return nil, err
+		}
+	}
+	f, err := m.Open(r.Src, hdfs.O_WRONLY, 0, int(r.Replication), uint32(r.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	mf := f.(*memFile)
+	nn.leases[mf.node] = &lease{file: mf, client: r.ClientName}
+	info, err := m.GetPathInfo(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.CreateResponse{FS: fileStatus(info, "")}, nil
+}
+
+// append reopens a file for writing. The next block written is always a
+// new one, as with CreateFlag NEW_BLOCK.
+func (nn *Namenode) append(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.AppendRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	if len(nn.datanodes) == 0 {
+		return nil, errNoDatanodes
+	}
+	f, err := m.Open(r.Src, hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	mf := f.(*memFile)
+	bl := nn.blocksOf(mf.node)
+	nn.leases[mf.node] = &lease{file: mf, client: r.ClientName, blocks: append([]*block(nil), bl.blocks...), appended: len(bl.blocks)}
+	info, err := m.GetPathInfo(r.Src)
+	if err != nil {
+		return nil, err
+	}
+	return &hdfsproto.AppendResponse{FS: fileStatus(info, "")}, nil
+}
+
+func (nn *Namenode) addBlock(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.AddBlockRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	l, n, err := nn.leaseOf(m, r.Src, r.ClientName)
+	if err != nil {
+		return nil, err
+	}
+	if r.Previous != nil {
+		if err := l.commit(r.Previous); err != nil {
+			return nil, err
+		}
+		nn.recordLength(n, l)
+	}
+	exclude := map[string]bool{}
+	for _, dn := range r.ExcludeNodes {
+		exclude[dn.DatanodeUUID] = true
+	}
+	nn.m.t.Lock()
+	replication := int(n.replication)
+	nn.m.t.Unlock()
+	b := nn.newBlock(replication, exclude)
+	if len(b.locs) == 0 {
+		return nil, &rpc.RemoteError{Class: "java.io.IOException", Message: "File " + r.Src + " could only be written to 0 of the 1 minReplication nodes"}
+	}
+	var off int64
+	for _, prev := range l.blocks {
+		off += prev.length
+	}
+	l.blocks = append(l.blocks, b)
+	return &hdfsproto.BlockResponse{Block: *located(b, off)}, nil
+}
+
+func (nn *Namenode) abandonBlock(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.AbandonBlockRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	l, _, err := nn.leaseOf(m, r.Src, r.Holder)
+	if err != nil {
+		return nil, err
+	}
+	if i := len(l.blocks) - 1; i >= l.appended && l.blocks[i].id == r.Block.BlockID {
+		for _, dn := range l.blocks[i].locs {
+			dn.remove(r.Block.BlockID)
+		}
+		l.blocks = l.blocks[:i]
+	}
+	return &hdfsproto.Empty{}, nil
+}
+
+// complete commits the file to the MemFS once every new block has a
+// finalized replica of the length the client reported.
+func (nn *Namenode) complete(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.CompleteRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	l, n, err := nn.leaseOf(m, r.Src, r.ClientName)
+	if err != nil {
+		return nil, err
+	}
+	if r.Last != nil {
+		if err := l.commit(r.Last); err != nil {
+			return nil, err
+		}
+	}
+	var data []byte
+	for _, b := range l.blocks[l.appended:] {
+		var replica []byte
+		ok := false
+		for _, dn := range b.locs {
+			if replica, ok = dn.finalized(b.id, b.gs, b.length); ok {
+				break
+			}
+		}
+		if !ok {
+			return &hdfsproto.BoolResponse{}, nil
+		}
+		data = append(data, replica...)
+	}
+	delete(nn.leases, n)
+	if _, err := l.file.Write(data); err != nil {
+		return nil, err
+	}
+	if err := l.file.Close(); err != nil {
+		return nil, err
+	}
+	nn.m.t.Lock()
+	nn.files[n] = &blockList{blocks: l.blocks, data: n.data}
+	nn.m.t.Unlock()
+	return &hdfsproto.BoolResponse{Result: true}, nil
+}
+
+// fsync records the length of the last block of a file being written.
+func (nn *Namenode) fsync(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.FsyncRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	l, n, err := nn.leaseOf(m, r.Src, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	if i := len(l.blocks) - 1; r.LastBlockLength >= 0 && i >= l.appended {
+		l.blocks[i].length = r.LastBlockLength
+		nn.recordLength(n, l)
+	}
+	return &hdfsproto.Empty{}, nil
+}
+
+// recordLength records the length of the blocks of n written through l
+// in the MemFS, which reports it as the size of n until it is completed.
+// nn.mu must be held.
+func (nn *Namenode) recordLength(n *node, l *lease) {
+	var length int64
+	for _, b := range l.blocks {
+		length += b.length
+	}
+	nn.m.t.Lock()
+	n.synced = length
+	nn.m.t.Unlock()
+}
+
+// leasedBlock returns the block with the given ID being written by client.
+// nn.mu must be held.
+func (nn *Namenode) leasedBlock(id uint64, client string) (*block, error) {
+	for _, l := range nn.leases {
+		if l.client != client {
+			continue
+		}
+		if b, err := l.find(id); err == nil {
+			return b, nil
+		}
+	}
+	return nil, &rpc.RemoteError{Class: "java.io.IOException", Message: fmt.Sprintf("block %d is not being written by %s", id, client)}
+}
+
+func (nn *Namenode) updateBlockForPipeline(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.UpdateBlockForPipelineRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	b, err := nn.leasedBlock(r.Block.BlockID, r.ClientName)
+	if err != nil {
+		return nil, err
+	}
+	nn.nextGS++
+	lb := located(b, 0)
+	lb.Block.GenerationStamp = nn.nextGS
+	return &hdfsproto.BlockResponse{Block: *lb}, nil
+}
+
+func (nn *Namenode) updatePipeline(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.UpdatePipelineRequest
+	if err := unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	nn.mu.Lock()
+	defer nn.mu.Unlock()
+	b, err := nn.leasedBlock(r.OldBlock.BlockID, r.ClientName)
+	if err != nil {
+		return nil, err
+	}
+	var locs []*Datanode
+	for _, id := range r.NewNodes {
+		dn := nn.datanode(id.DatanodeUUID)
+		if dn == nil {
+			return nil, &rpc.RemoteError{Class: "java.io.IOException", Message: "unknown datanode " + id.DatanodeUUID}
+		}
+		locs = append(locs, dn)
+	}
+	b.gs, b.length, b.locs = r.NewBlock.GenerationStamp, int64(r.NewBlock.NumBytes), locs
+	return &hdfsproto.Empty{}, nil
+}
+
+// renewLease accepts the renewal; leases of a Namenode never expire.
+func (nn *Namenode) renewLease(m *MemFS, req []byte) (protowire.Marshaler, error) {
+	var r hdfsproto.RenewLeaseRequest
+	return &hdfsproto.Empty{}, unmarshal(req, &r)
+}
@@ -0,0 +1,231 @@
+package hdfstest
+
+import (
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// quota holds the quotas set on a directory, -1 for those not set.
+type quota struct {
+	ns, space int64
+	types     map[hdfs.StorageType]int64
+}
+
+// usage is what the tree of a node counts against quotas: its files and
+// directories, and the bytes of its data times their replication. As the
+// default storage policy does, MemFS keeps all data on DISK.
+type usage struct {
+	names, space int64
+}
+
+func usageOf(n *node) usage {
+	u := usage{names: 1, space: int64(len(n.data)) * int64(n.replication)}
+	for _, c := range n.children {
+		cu := usageOf(c)
+		u.names += cu.names
+		u.space += cu.space
+	}
+	return u
+}
+
+// exceeds reports whether adding u to the tree of n exceeds one of its
+// quotas.
+func (q *quota) exceeds(n *node, u usage) bool {
+	if q == nil || u.names <= 0 && u.space <= 0 {
+		return false
+	}
+	cur := usageOf(n)
+	if q.ns >= 0 && u.names > 0 && cur.names+u.names > q.ns {
+		return true
+	}
+	if u.space <= 0 {
+		return false
+	}
+	if q.space >= 0 && cur.space+u.space > q.space {
+		return true
+	}
+	disk, ok := q.types[hdfs.StorageDisk]
+	return ok && cur.space+u.space > disk
+}
+
+// chain returns the directories from the root down to n, n included, or
+// nil if n is no longer in the tree.
+func (t *tree) chain(n *node) []*node {
+	var find func(d *node, c []*node) []*node
+	find = func(d *node, c []*node) []*node {
+		c = append(c, d)
+		if d == n {
+			return c
+		}
+		for _, child := range d.children {
+			if found := find(child, c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(t.root, nil)
+}
+
+// checkQuota fails with EDQUOT if adding u under the last directory of
+// chain exceeds the quota of one of them but those of skip, which already
+// count u.
+func checkQuota(chain []*node, u usage, skip []*node) error {
+next:
+	for _, d := range chain {
+		for _, s := range skip {
+			if d == s {
+				continue next
+			}
+		}
+		if d.quota.exceeds(d, u) {
+			return syscall.EDQUOT
+		}
+	}
+	return nil
+}
+
+// quotaDir returns the directory p, on which m must be the superuser to set
+// quotas. As the namenode does, it fails with ENOENT on files.
+func (m *MemFS) quotaDir(p string) (*node, error) {
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, err
+	}
+	if !m.superuser() || n.readonly {
+		return nil, syscall.EACCES
+	}
+	if !n.dir {
+		return nil, syscall.ENOENT
+	}
+	return n, nil
+}
+
+func validQuota(q int64) bool {
+	return q >= 0 || q == hdfs.QuotaReset
+}
+
+// setQuota sets *q to value unless it is QuotaDontSet.
+func setQuota(q *int64, value int64) {
+	if value != hdfs.QuotaDontSet {
+		*q = value
+	}
+}
+
+// dropEmptyQuota forgets the quotas of n once none is set.
+func dropEmptyQuota(n *node) {
+	if n.quota.ns < 0 && n.quota.space < 0 && len(n.quota.types) == 0 {
+		n.quota = nil
+	}
+}
+
+// SetQuota sets the namespace and space quotas of the directory p. Only
+// the superuser may set them.
+func (m *MemFS) SetQuota(p string, nsQuota, ssQuota int64) error {
+	if err := m.begin("setquota", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.quotaDir(p)
+	if err != nil {
+		return pathError("setquota", p, err)
+	}
+	if !validQuota(nsQuota) || !validQuota(ssQuota) {
+		return pathError("setquota", p, syscall.EINVAL)
+	}
+	if n.quota == nil {
+		n.quota = &quota{ns: -1, space: -1}
+	}
+	setQuota(&n.quota.ns, nsQuota)
+	setQuota(&n.quota.space, ssQuota)
+	dropEmptyQuota(n)
+	return nil
+}
+
+// SetTypeQuota sets the space quota of the directory p on the storage
+// type t. Only the superuser may set it.
+func (m *MemFS) SetTypeQuota(p string, t hdfs.StorageType, q int64) error {
+	if err := m.begin("setquota", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.quotaDir(p)
+	if err != nil {
+		return pathError("setquota", p, err)
+	}
+	if _, err := hdfs.ParseStorageType(t.String()); err != nil || !t.SupportsQuota() || !validQuota(q) {
+		return pathError("setquota", p, syscall.EINVAL)
+	}
+	if q == hdfs.QuotaDontSet {
+		return nil
+	}
+	if n.quota == nil {
+		n.quota = &quota{ns: -1, space: -1}
+	}
+	if q == hdfs.QuotaReset {
+		delete(n.quota.types, t)
+	} else {
+		if n.quota.types == nil {
+			n.quota.types = map[hdfs.StorageType]int64{}
+		}
+		n.quota.types[t] = q
+	}
+	dropEmptyQuota(n)
+	return nil
+}
+
+// ClearQuota clears the namespace and space quotas of the directory p.
+func (m *MemFS) ClearQuota(p string) error {
+	return m.SetQuota(p, hdfs.QuotaReset, hdfs.QuotaReset)
+}
+
+// GetContentSummary returns the disk usage and quotas of the tree rooted
+// at p, every directory of which m must be able to read.
+func (m *MemFS) GetContentSummary(p string) (*hdfs.ContentSummary, error) {
+	if err := m.begin("du", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, pathError("du", p, err)
+	}
+	s := &hdfs.ContentSummary{Quota: -1, SpaceQuota: -1}
+	var sum func(n *node) bool
+	sum = func(n *node) bool {
+		if !n.dir {
+			s.FileCount++
+			s.Length += int64(len(n.data))
+			s.SpaceConsumed += int64(len(n.data)) * int64(n.replication)
+			return true
+		}
+		if !m.access(n, permRead|permExec) {
+			return false
+		}
+		s.DirectoryCount++
+		for _, c := range n.children {
+			if !sum(c) {
+				return false
+			}
+		}
+		return true
+	}
+	if !sum(n) {
+		return nil, pathError("du", p, syscall.EACCES)
+	}
+	if q := n.quota; q != nil {
+		s.Quota, s.SpaceQuota = q.ns, q.space
+		for t, v := range q.types {
+			if s.TypeQuotas == nil {
+				s.TypeQuotas = map[hdfs.StorageType]hdfs.TypeQuota{}
+			}
+			tq := hdfs.TypeQuota{Quota: v}
+			if t == hdfs.StorageDisk {
+				tq.Consumed = s.SpaceConsumed
+			}
+			s.TypeQuotas[t] = tq
+		}
+	}
+	return s, nil
+}
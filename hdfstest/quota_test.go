@@ -0,0 +1,84 @@
+package hdfstest
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+func TestQuotas(t *testing.T) {
+	m := NewMemFS()
+	m.CreateDirectory("/q/sub")
+	writeFile(t, m, "/q/f", "abcd")
+	m.CreateDirectory("/other")
+
+	s, err := m.GetContentSummary("/q")
+	if err != nil {
+		t.Fatalf("Error on GetContentSummary: %v\n", err)
+	}
+	if want := (&hdfs.ContentSummary{Length: 4, FileCount: 1, DirectoryCount: 2, Quota: -1, SpaceConsumed: 12, SpaceQuota: -1}); !reflect.DeepEqual(s, want) {
+		t.Errorf("GetContentSummary: got %+v, want %+v\n", s, want)
+	}
+
+	if err := m.AsUser("alice").SetQuota("/q", 4, hdfs.QuotaDontSet); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("SetQuota by a user: got %v\n", err)
+	}
+	if err := m.SetQuota("/q/f", 4, hdfs.QuotaDontSet); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SetQuota of a file: got %v\n", err)
+	}
+	if err := m.SetQuota("/q", -2, hdfs.QuotaDontSet); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("SetQuota with an invalid quota: got %v\n", err)
+	}
+	if err := m.SetTypeQuota("/q", hdfs.StorageRAMDisk, 100); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("SetTypeQuota on RAM_DISK: got %v\n", err)
+	}
+	if err := m.SetQuota("/q", 4, 30); err != nil {
+		t.Fatalf("Error on SetQuota: %v\n", err)
+	}
+	if err := m.SetTypeQuota("/q", hdfs.StorageSSD, 100); err != nil {
+		t.Fatalf("Error on SetTypeQuota: %v\n", err)
+	}
+	s, _ = m.GetContentSummary("/q")
+	if s.Quota != 4 || s.SpaceQuota != 30 || len(s.TypeQuotas) != 1 || s.TypeQuotas[hdfs.StorageSSD] != (hdfs.TypeQuota{Quota: 100}) {
+		t.Errorf("GetContentSummary with quotas: got %+v\n", s)
+	}
+
+	writeFile(t, m, "/q/sub/g", "")
+	if err := m.CreateDirectory("/q/sub/d"); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("CreateDirectory over the namespace quota: got %v\n", err)
+	}
+	if _, err := m.Open("/q/h", hdfs.O_WRONLY, 0, 0, 0); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("Open over the namespace quota: got %v\n", err)
+	}
+	writeFile(t, m, "/q/sub/g", "xyzxyz") // overwrites, up to the space quota
+	f, _ := m.Open("/q/sub/g", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	f.Write([]byte("!"))
+	if err := f.Close(); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("Close over the space quota: got %v\n", err)
+	}
+	if err := m.SetReplication("/q/f", 5); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("SetReplication over the space quota: got %v\n", err)
+	}
+	writeFile(t, m, "/other/x", "")
+	if err := m.Rename("/other/x", "/q/sub"); !errors.Is(err, syscall.EDQUOT) {
+		t.Errorf("Rename into a directory over its quota: got %v\n", err)
+	}
+	if err := m.Rename("/q/f", "/q/sub/f"); err != nil {
+		t.Errorf("Error on Rename within the quota directory: %v\n", err)
+	}
+
+	if err := m.ClearQuota("/q"); err != nil {
+		t.Fatalf("Error on ClearQuota: %v\n", err)
+	}
+	if err := m.Rename("/other/x", "/q/sub"); err != nil {
+		t.Errorf("Error on Rename once the quota is cleared: %v\n", err)
+	}
+	m.SetTypeQuota("/q", hdfs.StorageSSD, hdfs.QuotaReset)
+	if s, _ = m.GetContentSummary("/q"); s.Quota != -1 || s.SpaceQuota != -1 || s.TypeQuotas != nil {
+		t.Errorf("GetContentSummary without quotas: got %+v\n", s)
+	}
+}
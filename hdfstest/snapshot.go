@@ -0,0 +1,350 @@
+package hdfstest
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// snapshotQuota is the number of snapshots a directory may hold.
+const snapshotQuota = 65536
+
+// freeze returns a read-only copy of the tree under n, as a snapshot keeps
+// it. The copy shares the data of files, which writers never change in
+// place.
+func freeze(n *node) *node {
+	c := *n
+	c.writer, c.snapshots, c.readonly = nil, nil, true
+	c.data = n.data[:len(n.data):len(n.data)]
+	c.acl = append([]hdfs.AclEntry(nil), n.acl...)
+	if n.xattrs != nil {
+		c.xattrs = make(map[string][]byte, len(n.xattrs))
+		for name, value := range n.xattrs {
+			c.xattrs[name] = value
+		}
+	}
+	if n.children != nil {
+		c.children = make(map[string]*node, len(n.children))
+		for name, child := range n.children {
+			c.children[name] = freeze(child)
+		}
+	}
+	return &c
+}
+
+// snapshotDir returns the read-only directory listing the snapshots of n.
+func snapshotDir(n *node) *node {
+	return &node{dir: true, children: n.snapshots, owner: n.owner, group: n.group, perm: n.perm, mtime: n.mtime, readonly: true}
+}
+
+// hasSnapshots reports whether n or a directory below it has snapshots,
+// which keeps it from being deleted or renamed.
+func hasSnapshots(n *node) bool {
+	if len(n.snapshots) > 0 {
+		return true
+	}
+	for _, c := range n.children {
+		if hasSnapshots(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func validSnapshotName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != hdfs.SnapshotDir && !strings.Contains(name, "/")
+}
+
+// snapshottable returns the snapshottable directory p, which m must own if
+// owner is set.
+func (m *MemFS) snapshottable(p string, owner bool) (*node, error) {
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return nil, err
+	}
+	if n.snapshots == nil {
+		return nil, syscall.EINVAL
+	}
+	if owner && !m.superuser() && n.owner != m.user {
+		return nil, syscall.EACCES
+	}
+	return n, nil
+}
+
+// AllowSnapshot lets snapshots be taken of the directory p. Only the
+// superuser may allow them.
+func (m *MemFS) AllowSnapshot(p string) error {
+	if err := m.begin("allowsnapshot", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("allowsnapshot", p, err)
+	}
+	if !n.dir {
+		return pathError("allowsnapshot", p, syscall.ENOTDIR)
+	}
+	if !m.superuser() || n.readonly {
+		return pathError("allowsnapshot", p, syscall.EACCES)
+	}
+	if n.snapshots == nil {
+		n.snapshots = map[string]*node{}
+	}
+	return nil
+}
+
+// DisallowSnapshot stops snapshots being taken of the directory p, which
+// must have none left. Only the superuser may disallow them.
+func (m *MemFS) DisallowSnapshot(p string) error {
+	if err := m.begin("disallowsnapshot", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	if err != nil {
+		return pathError("disallowsnapshot", p, err)
+	}
+	if !n.dir {
+		return pathError("disallowsnapshot", p, syscall.ENOTDIR)
+	}
+	if !m.superuser() {
+		return pathError("disallowsnapshot", p, syscall.EACCES)
+	}
+	if len(n.snapshots) > 0 {
+		return pathError("disallowsnapshot", p, syscall.ENOTEMPTY)
+	}
+	n.snapshots = nil
+	return nil
+}
+
+// CreateSnapshot takes a snapshot of the directory p, named as HDFS does
+// after the current time if name is empty, and returns its path.
+func (m *MemFS) CreateSnapshot(p, name string) (string, error) {
+	if err := m.begin("createsnapshot", p); err != nil {
+		return "", err
+	}
+	defer m.t.Unlock()
+	n, err := m.snapshottable(p, true)
+	if err != nil {
+		return "", pathError("createsnapshot", p, err)
+	}
+	if name == "" {
+		name = m.t.now().Format("s20060102-150405.000")
+	}
+	if !validSnapshotName(name) {
+		return "", pathError("createsnapshot", p, syscall.EINVAL)
+	}
+	if n.snapshots[name] != nil {
+		return "", pathError("createsnapshot", p, syscall.EEXIST)
+	}
+	if len(n.snapshots) >= snapshotQuota {
+		return "", pathError("createsnapshot", p, syscall.EDQUOT)
+	}
+	root := freeze(n)
+	root.mtime = m.t.time()
+	n.snapshots[name] = root
+	return hdfs.SnapshotPath(m.abs(p), name), nil
+}
+
+// DeleteSnapshot deletes the snapshot name of the directory p.
+func (m *MemFS) DeleteSnapshot(p, name string) error {
+	if err := m.begin("deletesnapshot", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.snapshottable(p, true)
+	if err != nil {
+		return pathError("deletesnapshot", p, err)
+	}
+	if n.snapshots[name] == nil {
+		return pathError("deletesnapshot", p, syscall.ENOENT)
+	}
+	delete(n.snapshots, name)
+	return nil
+}
+
+// RenameSnapshot renames the snapshot oldName of the directory p.
+func (m *MemFS) RenameSnapshot(p, oldName, newName string) error {
+	if err := m.begin("renamesnapshot", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	n, err := m.snapshottable(p, true)
+	if err != nil {
+		return pathError("renamesnapshot", p, err)
+	}
+	s := n.snapshots[oldName]
+	switch {
+	case s == nil:
+		return pathError("renamesnapshot", p, syscall.ENOENT)
+	case oldName == newName:
+		return nil
+	case !validSnapshotName(newName):
+		return pathError("renamesnapshot", p, syscall.EINVAL)
+	case n.snapshots[newName] != nil:
+		return pathError("renamesnapshot", p, syscall.EEXIST)
+	}
+	delete(n.snapshots, oldName)
+	n.snapshots[newName] = s
+	return nil
+}
+
+// ListSnapshottableDirs returns the snapshottable directories, sorted by
+// path: all of them for the superuser, those m owns for others.
+func (m *MemFS) ListSnapshottableDirs() ([]*hdfs.SnapshottableDir, error) {
+	if err := m.begin("lssnapshottabledir", ""); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	var dirs []*hdfs.SnapshottableDir
+	var list func(p string, n *node)
+	list = func(p string, n *node) {
+		if n.snapshots != nil && (m.superuser() || n.owner == m.user) {
+			dirs = append(dirs, &hdfs.SnapshottableDir{Path: p, Snapshots: len(n.snapshots), SnapshotQuota: snapshotQuota})
+		}
+		for _, name := range sortedNames(n) {
+			list(path.Join(p, name), n.children[name])
+		}
+	}
+	list("/", m.t.root)
+	return dirs, nil
+}
+
+func sortedNames(n *node) []string {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SnapshotDiff returns the changes made to the directory p between its
+// snapshots from and to, "" or "." standing for its current state. As the
+// namenode does, it tells renames from deletions and creations by the inode
+// IDs the snapshots keep.
+func (m *MemFS) SnapshotDiff(p, from, to string) (*hdfs.SnapshotDiffReport, error) {
+	if err := m.begin("snapshotdiff", p); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	n, err := m.snapshottable(p, false)
+	if err != nil {
+		return nil, pathError("snapshotdiff", p, err)
+	}
+	if !m.access(n, permRead|permExec) {
+		return nil, pathError("snapshotdiff", p, syscall.EACCES)
+	}
+	a, b := n, n
+	if from != "" && from != "." {
+		if a = n.snapshots[from]; a == nil {
+			return nil, pathError("snapshotdiff", p, syscall.ENOENT)
+		}
+	}
+	if to != "" && to != "." {
+		if b = n.snapshots[to]; b == nil {
+			return nil, pathError("snapshotdiff", p, syscall.ENOENT)
+		}
+	}
+	d := &differ{from: map[int64]place{}, to: map[int64]place{}}
+	d.index(d.from, a, nil, ".")
+	d.index(d.to, b, nil, ".")
+	d.diff(a, b, ".")
+	return &hdfs.SnapshotDiffReport{Dir: m.abs(p), From: from, To: to, Entries: d.entries}, nil
+}
+
+// place is where a node is in a tree.
+type place struct {
+	n, parent *node
+	path      string // relative to the root of the diff
+}
+
+type differ struct {
+	from, to map[int64]place // by inode ID
+	entries  []hdfs.SnapshotDiffEntry
+}
+
+func (d *differ) index(places map[int64]place, n, parent *node, p string) {
+	places[n.id] = place{n, parent, p}
+	for name, c := range n.children {
+		d.index(places, c, n, path.Join(p, name))
+	}
+}
+
+func (d *differ) add(t hdfs.DiffType, p, target string) {
+	d.entries = append(d.entries, hdfs.SnapshotDiffEntry{Type: t, Path: p, Target: target})
+}
+
+// diff appends the changes from a to b, the same inode at the path p of
+// the later tree.
+func (d *differ) diff(a, b *node, p string) {
+	if modified(a, b) {
+		d.add(hdfs.DiffModify, p, "")
+	}
+	for _, name := range sortedNames(b) {
+		c, cp := b.children[name], path.Join(p, name)
+		old, ok := d.from[c.id]
+		if !ok {
+			d.add(hdfs.DiffCreate, cp, "")
+			continue
+		}
+		if old.parent == nil || old.parent.id != b.id || path.Base(old.path) != name {
+			d.add(hdfs.DiffRename, old.path, cp)
+		}
+		d.diff(old.n, c, cp)
+	}
+	for _, name := range sortedNames(a) {
+		if _, ok := d.to[a.children[name].id]; !ok {
+			d.add(hdfs.DiffDelete, path.Join(p, name), "")
+		}
+	}
+}
+
+// modified reports whether a changed into b: its attributes, the data of a
+// file or the entries of a directory.
+func modified(a, b *node) bool {
+	if a.owner != b.owner || a.group != b.group || a.perm != b.perm || !sameAcl(a.acl, b.acl) || !sameXAttrs(a.xattrs, b.xattrs) {
+		return true
+	}
+	if !a.dir {
+		return a.replication != b.replication || !a.mtime.Equal(b.mtime) || !bytes.Equal(a.data, b.data)
+	}
+	if len(a.children) != len(b.children) {
+		return true
+	}
+	for name, c := range a.children {
+		if bc := b.children[name]; bc == nil || bc.id != c.id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameAcl(a, b []hdfs.AclEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameXAttrs(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if v, ok := b[name]; !ok || !bytes.Equal(v, value) {
+			return false
+		}
+	}
+	return true
+}
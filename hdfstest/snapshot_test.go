@@ -0,0 +1,125 @@
+package hdfstest
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+func TestSnapshots(t *testing.T) {
+	m := NewMemFS()
+	m.CreateDirectory("/d/sub")
+	writeFile(t, m, "/d/f", "old")
+	writeFile(t, m, "/d/gone", "")
+	writeFile(t, m, "/d/a", "")
+	m.Chown("/d", "alice", "")
+	alice := m.AsUser("alice", "staff")
+
+	if _, err := alice.CreateSnapshot("/d", "s0"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("CreateSnapshot of a directory not snapshottable: got %v\n", err)
+	}
+	if err := alice.AllowSnapshot("/d"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("AllowSnapshot by a user: got %v\n", err)
+	}
+	if err := m.AllowSnapshot("/d"); err != nil {
+		t.Fatalf("Error on AllowSnapshot: %v\n", err)
+	}
+	p, err := alice.CreateSnapshot("/d", "s0")
+	if err != nil {
+		t.Fatalf("Error on CreateSnapshot: %v\n", err)
+	}
+	if p != "/d/.snapshot/s0" {
+		t.Errorf("CreateSnapshot: got %s\n", p)
+	}
+	if _, err := alice.CreateSnapshot("/d", "s0"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CreateSnapshot of an existing name: got %v\n", err)
+	}
+
+	if f, err := m.Open("/d/f", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0); err != nil {
+		t.Fatalf("Error on Open for appending: %v\n", err)
+	} else {
+		f.Write([]byte("new"))
+		f.Close()
+	}
+	m.Delete("/d/gone")
+	m.Rename("/d/a", "/d/sub/b")
+	writeFile(t, m, "/d/sub/c", "")
+	if got := readFile(t, m, "/d/.snapshot/s0/f"); got != "old" {
+		t.Errorf("Read from a snapshot: got %q\n", got)
+	}
+	if err := m.Exists("/d/.snapshot/s0/gone"); err != nil {
+		t.Errorf("Exists of a file deleted since the snapshot: %v\n", err)
+	}
+	if _, err := m.Open("/d/.snapshot/s0/f", hdfs.O_WRONLY, 0, 0, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Open of a snapshot for writing: got %v\n", err)
+	}
+	if err := m.Delete("/d/.snapshot/s0/f"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Delete from a snapshot: got %v\n", err)
+	}
+	if infos, err := m.ListDirectory("/d/.snapshot"); err != nil || len(infos) != 1 || infos[0].Name != defaultURI+"/d/.snapshot/s0" {
+		t.Errorf("ListDirectory of the snapshots: got %v, %v\n", infos, err)
+	}
+	if err := m.Delete("/d"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Delete of a directory with snapshots: got %v\n", err)
+	}
+
+	r, err := alice.SnapshotDiff("/d", "s0", "")
+	if err != nil {
+		t.Fatalf("Error on SnapshotDiff: %v\n", err)
+	}
+	want := []hdfs.SnapshotDiffEntry{
+		{Type: hdfs.DiffModify, Path: "."},
+		{Type: hdfs.DiffModify, Path: "f"},
+		{Type: hdfs.DiffModify, Path: "sub"},
+		{Type: hdfs.DiffRename, Path: "a", Target: "sub/b"},
+		{Type: hdfs.DiffCreate, Path: "sub/c"},
+		{Type: hdfs.DiffDelete, Path: "gone"},
+	}
+	if !reflect.DeepEqual(r.Entries, want) {
+		t.Errorf("SnapshotDiff: got %v, want %v\n", r.Entries, want)
+	}
+	alice.CreateSnapshot("/d", "s1")
+	if r, _ := alice.SnapshotDiff("/d", "s1", ""); len(r.Entries) != 0 {
+		t.Errorf("SnapshotDiff without changes: got %v\n", r.Entries)
+	}
+	if r, _ := alice.SnapshotDiff("/d", "s1", "s0"); len(r.Entries) != 6 || r.Entries[1] != (hdfs.SnapshotDiffEntry{Type: hdfs.DiffRename, Path: "sub/b", Target: "a"}) {
+		t.Errorf("SnapshotDiff backwards: got %v\n", r.Entries)
+	}
+	if _, err := alice.SnapshotDiff("/d", "s9", ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SnapshotDiff of a missing snapshot: got %v\n", err)
+	}
+
+	if dirs, err := alice.ListSnapshottableDirs(); err != nil || len(dirs) != 1 || *dirs[0] != (hdfs.SnapshottableDir{Path: "/d", Snapshots: 2, SnapshotQuota: snapshotQuota}) {
+		t.Errorf("ListSnapshottableDirs: got %v, %v\n", dirs, err)
+	}
+	if dirs, _ := m.AsUser("bob").ListSnapshottableDirs(); len(dirs) != 0 {
+		t.Errorf("ListSnapshottableDirs of another user: got %v\n", dirs)
+	}
+	if err := alice.RenameSnapshot("/d", "s1", "s0"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("RenameSnapshot to an existing name: got %v\n", err)
+	}
+	if err := alice.RenameSnapshot("/d", "s1", "s2"); err != nil {
+		t.Errorf("Error on RenameSnapshot: %v\n", err)
+	}
+	if err := m.DisallowSnapshot("/d"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("DisallowSnapshot with snapshots: got %v\n", err)
+	}
+	for _, name := range []string{"s0", "s2"} {
+		if err := alice.DeleteSnapshot("/d", name); err != nil {
+			t.Errorf("Error on DeleteSnapshot %s: %v\n", name, err)
+		}
+	}
+	if err := alice.DeleteSnapshot("/d", "s0"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DeleteSnapshot of a missing snapshot: got %v\n", err)
+	}
+	if err := m.DisallowSnapshot("/d"); err != nil {
+		t.Errorf("Error on DisallowSnapshot: %v\n", err)
+	}
+	if err := m.Exists("/d/.snapshot"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Exists of the snapshots once disallowed: got %v\n", err)
+	}
+}
@@ -0,0 +1,151 @@
+package hdfstest
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// trashDir is the name of the trash directories, in home directories and at
+// the root of encryption zones.
+const trashDir = ".Trash"
+
+// zone is an encryption zone.
+type zone struct {
+	id   int64 // of the root directory
+	path string
+	key  string
+}
+
+// zoneOf returns the root of the innermost encryption zone of chain, the
+// directories from the root down, or nil.
+func zoneOf(chain []*node) *node {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].ez != "" {
+			return chain[i]
+		}
+	}
+	return nil
+}
+
+// zone returns the encryption zone of the absolute path p, which need not
+// exist, and whether there is one.
+func (t *tree) zone(p string) (zone, bool) {
+	var z zone
+	found := false
+	n, dir := t.root, "/"
+	for _, name := range strings.Split(p[1:], "/") {
+		if n.ez != "" {
+			z, found = zone{n.id, dir, n.ez}, true
+		}
+		if n = n.children[name]; n == nil {
+			return z, found
+		}
+		dir = path.Join(dir, name)
+	}
+	if n.ez != "" {
+		z, found = zone{n.id, dir, n.ez}, true
+	}
+	return z, found
+}
+
+// zones returns the encryption zones, sorted by ID.
+func (t *tree) zones() []zone {
+	var zs []zone
+	var find func(n *node, p string)
+	find = func(n *node, p string) {
+		if n.ez != "" {
+			zs = append(zs, zone{n.id, p, n.ez})
+		}
+		for name, c := range n.children {
+			find(c, path.Join(p, name))
+		}
+	}
+	find(t.root, "/")
+	sort.Slice(zs, func(i, j int) bool { return zs[i].id < zs[j].id })
+	return zs
+}
+
+// CreateEncryptionZone makes the empty directory p an encryption zone of
+// the key keyName, as hdfs crypto -createZone does; only the superuser may.
+// Zones cannot be nested. MemFS does not encrypt data: files cannot be
+// renamed in or out of a zone, failing with EXDEV, and the zone holds a
+// trash of its own.
+func (m *MemFS) CreateEncryptionZone(p, keyName string) error {
+	if err := m.begin("createzone", p); err != nil {
+		return err
+	}
+	defer m.t.Unlock()
+	_, n, err := m.lookup(m.abs(p))
+	switch {
+	case err != nil:
+		return pathError("createzone", p, err)
+	case !m.superuser():
+		return pathError("createzone", p, syscall.EACCES)
+	case !n.dir:
+		return pathError("createzone", p, syscall.ENOTDIR)
+	case len(n.children) > 0:
+		return pathError("createzone", p, syscall.ENOTEMPTY)
+	case keyName == "":
+		return pathError("createzone", p, syscall.EINVAL)
+	}
+	if zoneOf(m.t.chain(n)) != nil {
+		return pathError("createzone", p, syscall.EEXIST)
+	}
+	n.ez = keyName
+	return nil
+}
+
+// SetTrashInterval sets fs.trash.interval of the namenode, 0 by default,
+// which disables the trash.
+func (m *MemFS) SetTrashInterval(d time.Duration) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	m.t.trash = d
+}
+
+// TrashInterval returns the interval set with SetTrashInterval.
+func (m *MemFS) TrashInterval() (time.Duration, error) {
+	m.t.Lock()
+	defer m.t.Unlock()
+	return m.t.trash, nil
+}
+
+// TrashRoot returns the trash directory of the user for p: .Trash/<user> at
+// the root of the encryption zone of the parent of p, or .Trash in the home
+// directory.
+func (m *MemFS) TrashRoot(p string) (string, error) {
+	if err := m.begin("trashroot", p); err != nil {
+		return "", err
+	}
+	defer m.t.Unlock()
+	if z, ok := m.t.zone(path.Dir(m.abs(p))); ok {
+		return path.Join(z.path, trashDir, m.user), nil
+	}
+	return path.Join("/user", m.user, trashDir), nil
+}
+
+// TrashRoots returns the trash directories of the user that exist. As
+// listing encryption zones takes the superuser, those of zones are only
+// found for the superuser.
+func (m *MemFS) TrashRoots() ([]string, error) {
+	if err := m.begin("trashroots", ""); err != nil {
+		return nil, err
+	}
+	defer m.t.Unlock()
+	roots := []string{path.Join("/user", m.user, trashDir)}
+	if m.superuser() {
+		for _, z := range m.t.zones() {
+			roots = append(roots, path.Join(z.path, trashDir, m.user))
+		}
+	}
+	var found []string
+	for _, root := range roots {
+		if _, n, err := m.walk(root); err == nil && n != nil && n.dir {
+			found = append(found, root)
+		}
+	}
+	return found, nil
+}
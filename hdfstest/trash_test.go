@@ -0,0 +1,86 @@
+package hdfstest
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEncryptionZones(t *testing.T) {
+	m := NewMemFS()
+	m.CreateDirectory("/ez/sub")
+	m.CreateDirectory("/zone")
+	m.CreateDirectory("/user/alice")
+	m.Chown("/user/alice", "alice", "")
+	writeFile(t, m, "/f", "data")
+	alice := m.AsUser("alice")
+
+	for _, c := range []struct {
+		m       *MemFS
+		p, key  string
+		errno   syscall.Errno
+		explain string
+	}{
+		{alice, "/zone", "k", syscall.EACCES, "by a user"},
+		{m, "/f", "k", syscall.ENOTDIR, "of a file"},
+		{m, "/ez", "k", syscall.ENOTEMPTY, "of a directory not empty"},
+		{m, "/zone", "", syscall.EINVAL, "without a key"},
+	} {
+		if err := c.m.CreateEncryptionZone(c.p, c.key); !errors.Is(err, c.errno) {
+			t.Errorf("CreateEncryptionZone %s: got %v, want %v\n", c.explain, err, c.errno)
+		}
+	}
+	if err := m.CreateEncryptionZone("/zone", "k"); err != nil {
+		t.Fatalf("Error on CreateEncryptionZone: %v\n", err)
+	}
+	m.CreateDirectory("/zone/inner")
+	if err := m.CreateEncryptionZone("/zone/inner", "k2"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CreateEncryptionZone in a zone: got %v\n", err)
+	}
+
+	writeFile(t, m, "/zone/a", "secret")
+	if err := m.Rename("/zone/a", "/a"); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("Rename out of a zone: got %v\n", err)
+	}
+	if err := m.Rename("/f", "/zone/f"); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("Rename into a zone: got %v\n", err)
+	}
+	if err := m.Rename("/zone/a", "/zone/inner/a"); err != nil {
+		t.Errorf("Error on Rename within a zone: %v\n", err)
+	}
+
+	for p, want := range map[string]string{
+		"/zone/inner/a": "/zone/.Trash/hdfs",
+		"/zone/a":       "/zone/.Trash/hdfs",
+		"/zone":         "/user/hdfs/.Trash",
+		"/f":            "/user/hdfs/.Trash",
+	} {
+		if root, err := m.TrashRoot(p); root != want || err != nil {
+			t.Errorf("TrashRoot of %s: got %q, %v, want %q\n", p, root, err, want)
+		}
+	}
+	if root, err := alice.TrashRoot("/zone/x"); root != "/zone/.Trash/alice" || err != nil {
+		t.Errorf("TrashRoot of a user: got %q, %v\n", root, err)
+	}
+
+	m.CreateDirectory("/zone/.Trash/hdfs")
+	m.CreateDirectory("/zone/.Trash/alice")
+	m.CreateDirectory("/user/alice/.Trash")
+	if roots, err := m.TrashRoots(); err != nil || !reflect.DeepEqual(roots, []string{"/zone/.Trash/hdfs"}) {
+		t.Errorf("TrashRoots: got %v, %v\n", roots, err)
+	}
+	if roots, err := alice.TrashRoots(); err != nil || !reflect.DeepEqual(roots, []string{"/user/alice/.Trash"}) {
+		t.Errorf("TrashRoots of a user: got %v, %v\n", roots, err)
+	}
+
+	if d, err := m.TrashInterval(); d != 0 || err != nil {
+		t.Errorf("TrashInterval by default: got %v, %v\n", d, err)
+	}
+	m.SetTrashInterval(time.Hour)
+	if d, err := alice.TrashInterval(); d != time.Hour || err != nil {
+		t.Errorf("TrashInterval: got %v, %v\n", d, err)
+	}
+}
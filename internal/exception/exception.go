@@ -0,0 +1,122 @@
+// Package exception maps the Java exceptions thrown by HDFS to the errno
+// values libhdfs reports for them, for the backends that receive the
+// exceptions themselves: Hadoop IPC and WebHDFS.
+package exception
+
+import (
+	"strings"
+	"syscall"
+)
+
+var errnos = map[string]syscall.Errno{
+	"java.io.FileNotFoundException":                                  syscall.ENOENT,
+	"org.apache.hadoop.security.AccessControlException":              syscall.EACCES,
+	"org.apache.hadoop.fs.FileAlreadyExistsException":                syscall.EEXIST,
+	"org.apache.hadoop.fs.ParentNotDirectoryException":               syscall.ENOTDIR,
+	"org.apache.hadoop.fs.PathIsNotEmptyDirectoryException":          syscall.ENOTEMPTY,
+	"org.apache.hadoop.fs.InvalidPathException":                      syscall.EINVAL,
+	"org.apache.hadoop.fs.UnresolvedLinkException":                   syscall.ENOLINK,
+	"org.apache.hadoop.hdfs.protocol.NSQuotaExceededException":       syscall.EDQUOT,
+	"org.apache.hadoop.hdfs.protocol.DSQuotaExceededException":       syscall.EDQUOT,
+	"org.apache.hadoop.hdfs.protocol.QuotaExceededException":         syscall.EDQUOT,
+	"org.apache.hadoop.HadoopIllegalArgumentException":               syscall.EINVAL,
+	"java.lang.IllegalArgumentException":                             syscall.EINVAL,
+	"java.lang.UnsupportedOperationException":                        syscall.ENOTSUP,
+	"org.apache.hadoop.hdfs.protocol.AclException":                   syscall.EINVAL,
+	"org.apache.hadoop.hdfs.protocol.SnapshotAccessControlException": syscall.EACCES,
+}
+
+// Errno returns the errno libhdfs reports for the exception class, 0 if it
+// reports none. A class without a package, as the exception field of a
+// WebHDFS error carries it, matches the class of that simple name.
+func Errno(class string) syscall.Errno {
+	if errno, ok := errnos[class]; ok || strings.Contains(class, ".") {
+		return errno
+	}
+	for name, errno := range errnos {
+		if strings.HasSuffix(name, "."+class) {
+			return errno
+		}
+	}
+	return 0
+}
+
+// messages are the failures HDFS throws as a plain java.io.IOException, or
+// a SnapshotException, told apart by a part of their message; the first for
+// an errno is the message of Message.
+var messages = []struct {
+	text  string
+	errno syscall.Errno
+}{
+	{"At least one of the attributes provided was not found.", syscall.ENODATA}, // getXAttrs
+	{"No matching attributes found for remove operation", syscall.ENODATA},
+	{"The CREATE flag must be specified.", syscall.ENODATA}, // setXAttr
+	{"The REPLACE flag must be specified.", syscall.EEXIST},
+	{"is not a snapshottable directory", syscall.EINVAL},
+	{"there is already a snapshot with the same name", syscall.EEXIST}, // createSnapshot
+	{"already exists for directory", syscall.EEXIST},                   // renameSnapshot
+	{"Cannot find the snapshot", syscall.ENOENT},                       // getSnapshotDiffReport
+	{"the snapshot does not exist", syscall.ENOENT},                    // deleteSnapshot
+	{"does not exist for directory", syscall.ENOENT},                   // renameSnapshot
+	{"Please redo the operation after removing all the snapshots", syscall.ENOTEMPTY},
+	{"is snapshottable and already has snapshots", syscall.ENOTEMPTY}, // delete
+	{"can't be moved from an encryption zone.", syscall.EXDEV},        // rename
+	{"can't be moved into an encryption zone.", syscall.EXDEV},
+	{"can't be moved from encryption zone", syscall.EXDEV},
+}
+
+// messageClasses are the exception classes messages are thrown as.
+var messageClasses = map[string]bool{
+	"java.io.IOException": true,
+	"IOException":         true,
+	"org.apache.hadoop.hdfs.protocol.SnapshotException": true,
+	"SnapshotException": true,
+}
+
+// ErrnoMessage is Errno for an exception thrown with message, also telling
+// the errno of the exceptions listed in messages.
+func ErrnoMessage(class, message string) syscall.Errno {
+	if errno := Errno(class); errno != 0 || !messageClasses[class] {
+		return errno
+	}
+	for _, m := range messages {
+		if strings.Contains(message, m.text) {
+			return m.errno
+		}
+	}
+	return 0
+}
+
+// Message returns the message of the java.io.IOException HDFS throws for
+// errno when it has no exception class for it, "" if there is none, so
+// that ErrnoMessage("java.io.IOException", Message(errno)) is errno.
+func Message(errno syscall.Errno) string {
+	if Class(errno) != "" {
+		return ""
+	}
+	for _, m := range messages {
+		if m.errno == errno {
+			return m.text
+		}
+	}
+	return ""
+}
+
+var classes = map[syscall.Errno]string{
+	syscall.ENOENT:    "java.io.FileNotFoundException",
+	syscall.EACCES:    "org.apache.hadoop.security.AccessControlException",
+	syscall.EEXIST:    "org.apache.hadoop.fs.FileAlreadyExistsException",
+	syscall.ENOTDIR:   "org.apache.hadoop.fs.ParentNotDirectoryException",
+	syscall.ENOTEMPTY: "org.apache.hadoop.fs.PathIsNotEmptyDirectoryException",
+	syscall.EINVAL:    "org.apache.hadoop.fs.InvalidPathException",
+	syscall.ENOLINK:   "org.apache.hadoop.fs.UnresolvedLinkException",
+	syscall.EDQUOT:    "org.apache.hadoop.hdfs.protocol.QuotaExceededException",
+	syscall.ENOTSUP:   "java.lang.UnsupportedOperationException",
+}
+
+// Class returns the exception class HDFS throws for the failures reported
+// as errno, "" if there is none, so that servers can report errors as the
+// Java exceptions clients expect. Errno(Class(errno)) is errno.
+func Class(errno syscall.Errno) string {
+	return classes[errno]
+}
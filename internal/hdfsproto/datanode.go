@@ -0,0 +1,55 @@
+package hdfsproto
+
+import "github.com/zyxar/hdfs/internal/protowire"
+
+// ClientDatanodeProtocol is the protocol name calls to the IPC port of a
+// datanode are made to.
+const ClientDatanodeProtocol = "org.apache.hadoop.hdfs.protocol.ClientDatanodeProtocol"
+
+// GetReplicaVisibleLengthRequest is GetReplicaVisibleLengthRequestProto.
+type GetReplicaVisibleLengthRequest struct {
+	Block ExtendedBlock
+}
+
+func (m *GetReplicaVisibleLengthRequest) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Block)
+}
+
+func (m *GetReplicaVisibleLengthRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message(&m.Block)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// GetReplicaVisibleLengthResponse is GetReplicaVisibleLengthResponseProto:
+// the bytes of a replica being written that readers may see, those
+// acknowledged by the whole pipeline.
+type GetReplicaVisibleLengthResponse struct {
+	Length uint64
+}
+
+func (m *GetReplicaVisibleLengthResponse) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Length)
+}
+
+func (m *GetReplicaVisibleLengthResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Length = d.Uint64()
+		} else {
+			d.Skip()
+		}
+	}
+}
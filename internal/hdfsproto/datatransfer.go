@@ -0,0 +1,495 @@
+package hdfsproto
+
+import "github.com/zyxar/hdfs/internal/protowire"
+
+// DataTransferVersion is the version of the data transfer protocol that
+// starts every operation sent to a datanode.
+const DataTransferVersion = 28
+
+// Operation codes of the data transfer protocol.
+const (
+	WriteBlock    = 80
+	ReadBlock     = 81
+	BlockChecksum = 85
+)
+
+// Status values of datatransfer.proto.
+const (
+	StatusSuccess          = 0
+	StatusError            = 1
+	StatusErrorChecksum    = 2
+	StatusErrorInvalid     = 3
+	StatusErrorExists      = 4
+	StatusErrorAccessToken = 5
+	StatusChecksumOK       = 6
+)
+
+// ChecksumTypeProto values.
+const (
+	ChecksumNull   = 0
+	ChecksumCRC32  = 1
+	ChecksumCRC32C = 2
+)
+
+// BlockChecksumTypeProto values.
+const (
+	BlockChecksumMD5CRC       = 1
+	BlockChecksumCompositeCRC = 2
+)
+
+// BlockConstructionStage values of OpWriteBlockProto.
+const (
+	StagePipelineSetupAppend            = 0
+	StagePipelineSetupAppendRecovery    = 1
+	StageDataStreaming                  = 2
+	StagePipelineSetupStreamingRecovery = 3
+	StagePipelineClose                  = 4
+	StagePipelineCloseRecovery          = 5
+	StagePipelineSetupCreate            = 6
+)
+
+// ClientOperationHeader is ClientOperationHeaderProto with its
+// BaseHeaderProto inlined.
+type ClientOperationHeader struct {
+	Block      ExtendedBlock
+	Token      Token
+	ClientName string
+}
+
+func (m *ClientOperationHeader) MarshalProto(e *protowire.Encoder) {
+	var base protowire.Encoder
+	base.Message(1, &m.Block)
+	base.Message(2, &m.Token)
+	e.BytesField(1, base.Bytes())
+	e.String(2, m.ClientName)
+}
+
+func (m *ClientOperationHeader) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			base := protowire.NewDecoder(d.Bytes())
+			for {
+				f, ok := base.Next()
+				if !ok {
+					break
+				}
+				switch f {
+				case 1:
+					base.Message(&m.Block)
+				case 2:
+					base.Message(&m.Token)
+				default:
+					base.Skip()
+				}
+			}
+			if err := base.Err(); err != nil {
+				return err
+			}
+		case 2:
+			m.ClientName = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// Checksum is ChecksumProto.
+type Checksum struct {
+	Type             uint64
+	BytesPerChecksum uint32
+}
+
+func (m *Checksum) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Type)
+	e.Uint64(2, uint64(m.BytesPerChecksum))
+}
+
+func (m *Checksum) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Type = d.Uint64()
+		case 2:
+			m.BytesPerChecksum = uint32(d.Uint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// OpReadBlock is OpReadBlockProto.
+type OpReadBlock struct {
+	Header        ClientOperationHeader
+	Offset        uint64
+	Len           uint64
+	SendChecksums bool
+}
+
+func (m *OpReadBlock) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Header)
+	e.Uint64(2, m.Offset)
+	e.Uint64(3, m.Len)
+	e.Bool(4, m.SendChecksums)
+}
+
+func (m *OpReadBlock) UnmarshalProto(d *protowire.Decoder) error {
+	m.SendChecksums = true
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.Header)
+		case 2:
+			m.Offset = d.Uint64()
+		case 3:
+			m.Len = d.Uint64()
+		case 4:
+			m.SendChecksums = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// OpWriteBlock is OpWriteBlockProto. Targets are the datanodes of the
+// pipeline after the one the operation is sent to.
+type OpWriteBlock struct {
+	Header                ClientOperationHeader
+	Targets               []*DatanodeInfo
+	Stage                 uint64
+	PipelineSize          uint32
+	MinBytesRcvd          uint64
+	MaxBytesRcvd          uint64
+	LatestGenerationStamp uint64
+	RequestedChecksum     Checksum
+}
+
+func (m *OpWriteBlock) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Header)
+	for _, t := range m.Targets {
+		e.Message(2, t)
+	}
+	e.Uint64(4, m.Stage)
+	e.Uint64(5, uint64(m.PipelineSize))
+	e.Uint64(6, m.MinBytesRcvd)
+	e.Uint64(7, m.MaxBytesRcvd)
+	e.Uint64(8, m.LatestGenerationStamp)
+	e.Message(9, &m.RequestedChecksum)
+}
+
+func (m *OpWriteBlock) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.Header)
+		case 2:
+			t := new(DatanodeInfo)
+			d.Message(t)
+			m.Targets = append(m.Targets, t)
+		case 4:
+			m.Stage = d.Uint64()
+		case 5:
+			m.PipelineSize = uint32(d.Uint64())
+		case 6:
+			m.MinBytesRcvd = d.Uint64()
+		case 7:
+			m.MaxBytesRcvd = d.Uint64()
+		case 8:
+			m.LatestGenerationStamp = d.Uint64()
+		case 9:
+			d.Message(&m.RequestedChecksum)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// OpBlockChecksum is OpBlockChecksumProto with its BaseHeaderProto and
+// BlockChecksumOptionsProto inlined.
+type OpBlockChecksum struct {
+	Block ExtendedBlock
+	Token Token
+	Type  uint64 // BlockChecksumMD5CRC or BlockChecksumCompositeCRC
+}
+
+func (m *OpBlockChecksum) MarshalProto(e *protowire.Encoder) {
+	var base protowire.Encoder
+	base.Message(1, &m.Block)
+	base.Message(2, &m.Token)
+	e.BytesField(1, base.Bytes())
+	var opts protowire.Encoder
+	opts.Uint64(1, m.Type)
+	e.BytesField(2, opts.Bytes())
+}
+
+func (m *OpBlockChecksum) UnmarshalProto(d *protowire.Decoder) error {
+	m.Type = BlockChecksumMD5CRC
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			base := protowire.NewDecoder(d.Bytes())
+			for {
+				f, ok := base.Next()
+				if !ok {
+					break
+				}
+				switch f {
+				case 1:
+					base.Message(&m.Block)
+				case 2:
+					base.Message(&m.Token)
+				default:
+					base.Skip()
+				}
+			}
+			if err := base.Err(); err != nil {
+				return err
+			}
+		case 2:
+			opts := protowire.NewDecoder(d.Bytes())
+			for {
+				f, ok := opts.Next()
+				if !ok {
+					break
+				}
+				if f == 1 {
+					m.Type = opts.Uint64()
+				} else {
+					opts.Skip()
+				}
+			}
+			if err := opts.Err(); err != nil {
+				return err
+			}
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// BlockChecksumResponse is OpBlockChecksumResponseProto: the MD5 of the
+// checksums of the chunks of a block, or its CRC, as 4 big-endian bytes.
+type BlockChecksumResponse struct {
+	BytesPerCrc   uint32
+	CrcPerBlock   uint64
+	BlockChecksum []byte
+	CrcType       uint64
+}
+
+func (m *BlockChecksumResponse) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, uint64(m.BytesPerCrc))
+	e.Uint64(2, m.CrcPerBlock)
+	e.BytesField(3, m.BlockChecksum)
+	e.Uint64(4, m.CrcType)
+}
+
+func (m *BlockChecksumResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.BytesPerCrc = uint32(d.Uint64())
+		case 2:
+			m.CrcPerBlock = d.Uint64()
+		case 3:
+			m.BlockChecksum = append([]byte(nil), d.Bytes()...)
+		case 4:
+			m.CrcType = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// BlockOpResponse is BlockOpResponseProto. Checksum and ChunkOffset are
+// its ReadOpChecksumInfoProto, set in the response to OpReadBlock, and
+// BlockChecksum the response to OpBlockChecksum.
+type BlockOpResponse struct {
+	Status        uint64
+	FirstBadLink  string
+	BlockChecksum *BlockChecksumResponse
+	Checksum      *Checksum
+	ChunkOffset   uint64
+	Message       string
+}
+
+func (m *BlockOpResponse) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Status)
+	if m.FirstBadLink != "" {
+		e.String(2, m.FirstBadLink)
+	}
+	if m.BlockChecksum != nil {
+		e.Message(3, m.BlockChecksum)
+	}
+	if m.Checksum != nil {
+		var info protowire.Encoder
+		info.Message(1, m.Checksum)
+		info.Uint64(2, m.ChunkOffset)
+		e.BytesField(4, info.Bytes())
+	}
+	if m.Message != "" {
+		e.String(5, m.Message)
+	}
+}
+
+func (m *BlockOpResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Status = d.Uint64()
+		case 2:
+			m.FirstBadLink = d.String()
+		case 3:
+			m.BlockChecksum = new(BlockChecksumResponse)
+			d.Message(m.BlockChecksum)
+		case 4:
+			info := protowire.NewDecoder(d.Bytes())
+			for {
+				f, ok := info.Next()
+				if !ok {
+					break
+				}
+				switch f {
+				case 1:
+					m.Checksum = new(Checksum)
+					info.Message(m.Checksum)
+				case 2:
+					m.ChunkOffset = info.Uint64()
+				default:
+					info.Skip()
+				}
+			}
+			if err := info.Err(); err != nil {
+				return err
+			}
+		case 5:
+			m.Message = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// ClientReadStatus is ClientReadStatusProto, sent by a reader once it has
+// read and verified the block range it asked for.
+type ClientReadStatus struct {
+	Status uint64
+}
+
+func (m *ClientReadStatus) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Status)
+}
+
+func (m *ClientReadStatus) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Status = d.Uint64()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// PacketHeader is PacketHeaderProto.
+type PacketHeader struct {
+	OffsetInBlock     int64
+	Seqno             int64
+	LastPacketInBlock bool
+	DataLen           int32
+	SyncBlock         bool
+}
+
+func (m *PacketHeader) MarshalProto(e *protowire.Encoder) {
+	e.Fixed64(1, uint64(m.OffsetInBlock))
+	e.Fixed64(2, uint64(m.Seqno))
+	e.Bool(3, m.LastPacketInBlock)
+	e.Fixed32(4, uint32(m.DataLen))
+	if m.SyncBlock {
+		e.Bool(5, m.SyncBlock)
+	}
+}
+
+func (m *PacketHeader) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.OffsetInBlock = int64(d.Fixed64())
+		case 2:
+			m.Seqno = int64(d.Fixed64())
+		case 3:
+			m.LastPacketInBlock = d.Bool()
+		case 4:
+			m.DataLen = int32(d.Fixed32())
+		case 5:
+			m.SyncBlock = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// PipelineAck is PipelineAckProto: the status of a packet at each datanode
+// of the pipeline, in pipeline order.
+type PipelineAck struct {
+	Seqno int64
+	Reply []uint64
+}
+
+func (m *PipelineAck) MarshalProto(e *protowire.Encoder) {
+	e.Sint64(1, m.Seqno)
+	for _, r := range m.Reply {
+		e.Uint64(2, r)
+	}
+}
+
+func (m *PipelineAck) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Seqno = d.Sint64()
+		case 2:
+			m.Reply = d.Uint64s(m.Reply)
+		default:
+			d.Skip()
+		}
+	}
+}
@@ -0,0 +1,532 @@
+// Package hdfsproto holds the messages of hdfs.proto,
+// ClientNamenodeProtocol.proto, ClientDatanodeProtocol.proto and
+// datatransfer.proto used by the pure-Go backend and by the fake namenode
+// and datanode of hdfstest, with hand-written protocol buffer codecs.
+package hdfsproto
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// ClientProtocol is the protocol name namenode calls are made to.
+const ClientProtocol = "org.apache.hadoop.hdfs.protocol.ClientProtocol"
+
+// FileType values of HdfsFileStatusProto.
+const (
+	IsDir     = 1
+	IsFile    = 2
+	IsSymlink = 3
+)
+
+// FileStatus is HdfsFileStatusProto. Path is the local name in listings,
+// empty for getFileInfo.
+type FileStatus struct {
+	FileType         uint64
+	Path             []byte
+	Length           uint64
+	Permission       uint32
+	Owner            string
+	Group            string
+	ModificationTime uint64
+	AccessTime       uint64
+	Symlink          []byte
+	BlockReplication uint32
+	BlockSize        uint64
+	Locations        *LocatedBlocks
+	FileID           uint64
+	ChildrenNum      int32
+}
+
+func (m *FileStatus) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.FileType)
+	e.BytesField(2, m.Path)
+	e.Uint64(3, m.Length)
+	e.Message(4, &permission{m.Permission})
+	e.String(5, m.Owner)
+	e.String(6, m.Group)
+	e.Uint64(7, m.ModificationTime)
+	e.Uint64(8, m.AccessTime)
+	if m.Symlink != nil {
+		e.BytesField(9, m.Symlink)
+	}
+	e.Uint64(10, uint64(m.BlockReplication))
+	e.Uint64(11, m.BlockSize)
+	if m.Locations != nil {
+		e.Message(12, m.Locations)
+	}
+	e.Uint64(13, m.FileID)
+	e.Int64(14, int64(m.ChildrenNum))
+}
+
+func (m *FileStatus) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.FileType = d.Uint64()
+		case 2:
+			m.Path = append([]byte(nil), d.Bytes()...)
+		case 3:
+			m.Length = d.Uint64()
+		case 4:
+			var p permission
+			d.Message(&p)
+			m.Permission = p.perm
+		case 5:
+			m.Owner = d.String()
+		case 6:
+			m.Group = d.String()
+		case 7:
+			m.ModificationTime = d.Uint64()
+		case 8:
+			m.AccessTime = d.Uint64()
+		case 9:
+			m.Symlink = append([]byte(nil), d.Bytes()...)
+		case 10:
+			m.BlockReplication = uint32(d.Uint64())
+		case 11:
+			m.BlockSize = d.Uint64()
+		case 12:
+			m.Locations = new(LocatedBlocks)
+			d.Message(m.Locations)
+		case 13:
+			m.FileID = d.Uint64()
+		case 14:
+			m.ChildrenNum = int32(d.Int64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// permission is FsPermissionProto.
+type permission struct {
+	perm uint32
+}
+
+func (m *permission) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, uint64(m.perm))
+}
+
+func (m *permission) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.perm = uint32(d.Uint64())
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// LocatedBlocks is LocatedBlocksProto.
+type LocatedBlocks struct {
+	FileLength          uint64
+	Blocks              []*LocatedBlock
+	UnderConstruction   bool
+	LastBlock           *LocatedBlock
+	IsLastBlockComplete bool
+}
+
+func (m *LocatedBlocks) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.FileLength)
+	for _, b := range m.Blocks {
+		e.Message(2, b)
+	}
+	e.Bool(3, m.UnderConstruction)
+	if m.LastBlock != nil {
+		e.Message(4, m.LastBlock)
+	}
+	e.Bool(5, m.IsLastBlockComplete)
+}
+
+func (m *LocatedBlocks) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.FileLength = d.Uint64()
+		case 2:
+			b := new(LocatedBlock)
+			d.Message(b)
+			m.Blocks = append(m.Blocks, b)
+		case 3:
+			m.UnderConstruction = d.Bool()
+		case 4:
+			m.LastBlock = new(LocatedBlock)
+			d.Message(m.LastBlock)
+		case 5:
+			m.IsLastBlockComplete = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// LocatedBlock is LocatedBlockProto: a block, its offset in the file and the
+// datanodes holding its replicas.
+type LocatedBlock struct {
+	Block      ExtendedBlock
+	Offset     uint64
+	Locs       []*DatanodeInfo
+	Corrupt    bool
+	BlockToken Token
+}
+
+func (m *LocatedBlock) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Block)
+	e.Uint64(2, m.Offset)
+	for _, loc := range m.Locs {
+		e.Message(3, loc)
+	}
+	e.Bool(4, m.Corrupt)
+	e.Message(5, &m.BlockToken)
+}
+
+func (m *LocatedBlock) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.Block)
+		case 2:
+			m.Offset = d.Uint64()
+		case 3:
+			loc := new(DatanodeInfo)
+			d.Message(loc)
+			m.Locs = append(m.Locs, loc)
+		case 4:
+			m.Corrupt = d.Bool()
+		case 5:
+			d.Message(&m.BlockToken)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// ExtendedBlock is ExtendedBlockProto.
+type ExtendedBlock struct {
+	PoolID          string
+	BlockID         uint64
+	GenerationStamp uint64
+	NumBytes        uint64
+}
+
+func (m *ExtendedBlock) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.PoolID)
+	e.Uint64(2, m.BlockID)
+	e.Uint64(3, m.GenerationStamp)
+	e.Uint64(4, m.NumBytes)
+}
+
+func (m *ExtendedBlock) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.PoolID = d.String()
+		case 2:
+			m.BlockID = d.Uint64()
+		case 3:
+			m.GenerationStamp = d.Uint64()
+		case 4:
+			m.NumBytes = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// Token is TokenProto.
+type Token struct {
+	Identifier []byte
+	Password   []byte
+	Kind       string
+	Service    string
+}
+
+func (m *Token) MarshalProto(e *protowire.Encoder) {
+	e.BytesField(1, m.Identifier)
+	e.BytesField(2, m.Password)
+	e.String(3, m.Kind)
+	e.String(4, m.Service)
+}
+
+func (m *Token) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Identifier = append([]byte(nil), d.Bytes()...)
+		case 2:
+			m.Password = append([]byte(nil), d.Bytes()...)
+		case 3:
+			m.Kind = d.String()
+		case 4:
+			m.Service = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// DatanodeInfo is DatanodeInfoProto, with the fields of its DatanodeIDProto
+// inlined.
+type DatanodeInfo struct {
+	IPAddr       string
+	HostName     string
+	DatanodeUUID string
+	XferPort     uint32
+	InfoPort     uint32
+	IPCPort      uint32
+}
+
+func (m *DatanodeInfo) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, (*DatanodeID)(m))
+}
+
+func (m *DatanodeInfo) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message((*DatanodeID)(m))
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// DatanodeID is DatanodeIDProto, for the calls that identify datanodes
+// without the rest of DatanodeInfoProto.
+type DatanodeID DatanodeInfo
+
+func (m *DatanodeID) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.IPAddr)
+	e.String(2, m.HostName)
+	e.String(3, m.DatanodeUUID)
+	e.Uint64(4, uint64(m.XferPort))
+	e.Uint64(5, uint64(m.InfoPort))
+	e.Uint64(6, uint64(m.IPCPort))
+}
+
+func (m *DatanodeID) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.IPAddr = d.String()
+		case 2:
+			m.HostName = d.String()
+		case 3:
+			m.DatanodeUUID = d.String()
+		case 4:
+			m.XferPort = uint32(d.Uint64())
+		case 5:
+			m.InfoPort = uint32(d.Uint64())
+		case 6:
+			m.IPCPort = uint32(d.Uint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// XferAddr returns the host:port the datanode serves data transfer on.
+func (m *DatanodeInfo) XferAddr() string {
+	return net.JoinHostPort(m.IPAddr, strconv.Itoa(int(m.XferPort)))
+}
+
+// IPCAddr returns the host:port the datanode serves ClientDatanodeProtocol
+// on.
+func (m *DatanodeInfo) IPCAddr() string {
+	return net.JoinHostPort(m.IPAddr, strconv.Itoa(int(m.IPCPort)))
+}
+
+// ContentSummary is ContentSummaryProto, flattening its
+// StorageTypeQuotaInfosProto.
+type ContentSummary struct {
+	Length         uint64
+	FileCount      uint64
+	DirectoryCount uint64
+	Quota          uint64
+	SpaceConsumed  uint64
+	SpaceQuota     uint64
+	TypeQuotaInfos []*StorageTypeQuotaInfo
+}
+
+func (m *ContentSummary) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Length)
+	e.Uint64(2, m.FileCount)
+	e.Uint64(3, m.DirectoryCount)
+	e.Uint64(4, m.Quota)
+	e.Uint64(5, m.SpaceConsumed)
+	e.Uint64(6, m.SpaceQuota)
+	if len(m.TypeQuotaInfos) > 0 {
+		var infos protowire.Encoder
+		for _, info := range m.TypeQuotaInfos {
+			infos.Message(1, info)
+		}
+		e.BytesField(7, infos.Bytes())
+	}
+}
+
+func (m *ContentSummary) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Length = d.Uint64()
+		case 2:
+			m.FileCount = d.Uint64()
+		case 3:
+			m.DirectoryCount = d.Uint64()
+		case 4:
+			m.Quota = d.Uint64()
+		case 5:
+			m.SpaceConsumed = d.Uint64()
+		case 6:
+			m.SpaceQuota = d.Uint64()
+		case 7:
+			if err := m.unmarshalTypeQuotaInfos(d.Bytes()); err != nil {
+				return err
+			}
+		default:
+			d.Skip()
+		}
+	}
+}
+
+func (m *ContentSummary) unmarshalTypeQuotaInfos(b []byte) error {
+	d := protowire.NewDecoder(b)
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			info := new(StorageTypeQuotaInfo)
+			d.Message(info)
+			m.TypeQuotaInfos = append(m.TypeQuotaInfos, info)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// StorageTypeQuotaInfo is StorageTypeQuotaInfoProto. Type is a
+// StorageTypeProto: DISK 1, SSD 2, ARCHIVE 3, RAM_DISK 4, PROVIDED 5 and
+// NVDIMM 6.
+type StorageTypeQuotaInfo struct {
+	Type     uint64
+	Quota    uint64
+	Consumed uint64
+}
+
+func (m *StorageTypeQuotaInfo) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Type)
+	e.Uint64(2, m.Quota)
+	e.Uint64(3, m.Consumed)
+}
+
+func (m *StorageTypeQuotaInfo) UnmarshalProto(d *protowire.Decoder) error {
+	m.Type = 1 // DISK, the default
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Type = d.Uint64()
+		case 2:
+			m.Quota = d.Uint64()
+		case 3:
+			m.Consumed = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// ServerDefaults is FsServerDefaultsProto.
+type ServerDefaults struct {
+	BlockSize           uint64
+	BytesPerChecksum    uint32
+	WritePacketSize     uint32
+	Replication         uint32
+	FileBufferSize      uint32
+	EncryptDataTransfer bool
+	TrashInterval       uint64
+	ChecksumType        uint64
+}
+
+func (m *ServerDefaults) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.BlockSize)
+	e.Uint64(2, uint64(m.BytesPerChecksum))
+	e.Uint64(3, uint64(m.WritePacketSize))
+	e.Uint64(4, uint64(m.Replication))
+	e.Uint64(5, uint64(m.FileBufferSize))
+	e.Bool(6, m.EncryptDataTransfer)
+	e.Uint64(7, m.TrashInterval)
+	e.Uint64(8, m.ChecksumType)
+}
+
+func (m *ServerDefaults) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.BlockSize = d.Uint64()
+		case 2:
+			m.BytesPerChecksum = uint32(d.Uint64())
+		case 3:
+			m.WritePacketSize = uint32(d.Uint64())
+		case 4:
+			m.Replication = uint32(d.Uint64())
+		case 5:
+			m.FileBufferSize = uint32(d.Uint64())
+		case 6:
+			m.EncryptDataTransfer = d.Bool()
+		case 7:
+			m.TrashInterval = d.Uint64()
+		case 8:
+			m.ChecksumType = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
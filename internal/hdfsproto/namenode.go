@@ -0,0 +1,1812 @@
+package hdfsproto
+
+import "github.com/zyxar/hdfs/internal/protowire"
+
+// Empty is the request or response of calls without arguments or results,
+// such as GetFsStatusRequestProto and SetPermissionResponseProto.
+type Empty struct{}
+
+func (m *Empty) MarshalProto(e *protowire.Encoder) {}
+
+func (m *Empty) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		if _, ok := d.Next(); !ok {
+			return d.Err()
+		}
+		d.Skip()
+	}
+}
+
+// BoolResponse is the response of calls reporting success as a bool
+// result, such as MkdirsResponseProto and DeleteResponseProto.
+type BoolResponse struct {
+	Result bool
+}
+
+func (m *BoolResponse) MarshalProto(e *protowire.Encoder) {
+	e.Bool(1, m.Result)
+}
+
+func (m *BoolResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Result = d.Bool()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// SrcRequest is the request of calls taking only a path, such as
+// GetFileInfoRequestProto and GetContentSummaryRequestProto.
+type SrcRequest struct {
+	Src string
+}
+
+func (m *SrcRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+}
+
+func (m *SrcRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Src = d.String()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// GetFileInfoResponse is GetFileInfoResponseProto; FS is nil if the path
+// does not exist.
+type GetFileInfoResponse struct {
+	FS *FileStatus
+}
+
+func (m *GetFileInfoResponse) MarshalProto(e *protowire.Encoder) {
+	if m.FS != nil {
+		e.Message(1, m.FS)
+	}
+}
+
+func (m *GetFileInfoResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.FS = new(FileStatus)
+			d.Message(m.FS)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// GetListingRequest is GetListingRequestProto.
+type GetListingRequest struct {
+	Src          string
+	StartAfter   []byte
+	NeedLocation bool
+}
+
+func (m *GetListingRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.BytesField(2, m.StartAfter)
+	e.Bool(3, m.NeedLocation)
+}
+
+func (m *GetListingRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.StartAfter = append([]byte(nil), d.Bytes()...)
+		case 3:
+			m.NeedLocation = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetListingResponse is GetListingResponseProto with its
+// DirectoryListingProto inlined; Found is false if the path does not exist.
+type GetListingResponse struct {
+	Found            bool
+	PartialListing   []*FileStatus
+	RemainingEntries uint32
+}
+
+func (m *GetListingResponse) MarshalProto(e *protowire.Encoder) {
+	if !m.Found {
+		return
+	}
+	var list protowire.Encoder
+	for _, fs := range m.PartialListing {
+		list.Message(1, fs)
+	}
+	list.Uint64(2, uint64(m.RemainingEntries))
+	e.BytesField(1, list.Bytes())
+}
+
+func (m *GetListingResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field != 1 {
+			d.Skip()
+			continue
+		}
+		m.Found = true
+		list := protowire.NewDecoder(d.Bytes())
+		for {
+			f, ok := list.Next()
+			if !ok {
+				break
+			}
+			switch f {
+			case 1:
+				fs := new(FileStatus)
+				list.Message(fs)
+				m.PartialListing = append(m.PartialListing, fs)
+			case 2:
+				m.RemainingEntries = uint32(list.Uint64())
+			default:
+				list.Skip()
+			}
+		}
+		if err := list.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// MkdirsRequest is MkdirsRequestProto.
+type MkdirsRequest struct {
+	Src          string
+	Masked       uint32
+	CreateParent bool
+}
+
+func (m *MkdirsRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Message(2, &permission{m.Masked})
+	e.Bool(3, m.CreateParent)
+}
+
+func (m *MkdirsRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			var p permission
+			d.Message(&p)
+			m.Masked = p.perm
+		case 3:
+			m.CreateParent = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// RenameRequest is RenameRequestProto.
+type RenameRequest struct {
+	Src string
+	Dst string
+}
+
+func (m *RenameRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.String(2, m.Dst)
+}
+
+func (m *RenameRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Dst = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// DeleteRequest is DeleteRequestProto.
+type DeleteRequest struct {
+	Src       string
+	Recursive bool
+}
+
+func (m *DeleteRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Bool(2, m.Recursive)
+}
+
+func (m *DeleteRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Recursive = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SetPermissionRequest is SetPermissionRequestProto.
+type SetPermissionRequest struct {
+	Src        string
+	Permission uint32
+}
+
+func (m *SetPermissionRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Message(2, &permission{m.Permission})
+}
+
+func (m *SetPermissionRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			var p permission
+			d.Message(&p)
+			m.Permission = p.perm
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SetOwnerRequest is SetOwnerRequestProto; an empty Username or Groupname
+// is left out, leaving it unchanged.
+type SetOwnerRequest struct {
+	Src       string
+	Username  string
+	Groupname string
+}
+
+func (m *SetOwnerRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	if m.Username != "" {
+		e.String(2, m.Username)
+	}
+	if m.Groupname != "" {
+		e.String(3, m.Groupname)
+	}
+}
+
+func (m *SetOwnerRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Username = d.String()
+		case 3:
+			m.Groupname = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SetReplicationRequest is SetReplicationRequestProto.
+type SetReplicationRequest struct {
+	Src         string
+	Replication uint32
+}
+
+func (m *SetReplicationRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Uint64(2, uint64(m.Replication))
+}
+
+func (m *SetReplicationRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Replication = uint32(d.Uint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// TruncateRequest is TruncateRequestProto; the response is a BoolResponse,
+// false while the last block is being recovered.
+type TruncateRequest struct {
+	Src        string
+	NewLength  uint64
+	ClientName string
+}
+
+func (m *TruncateRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Uint64(2, m.NewLength)
+	e.String(3, m.ClientName)
+}
+
+func (m *TruncateRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.NewLength = d.Uint64()
+		case 3:
+			m.ClientName = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// IsFileClosedRequest is IsFileClosedRequestProto; the response is a
+// BoolResponse.
+type IsFileClosedRequest struct {
+	Src string
+}
+
+func (m *IsFileClosedRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+}
+
+func (m *IsFileClosedRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Src = d.String()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// ConcatRequest is ConcatRequestProto; the response is empty.
+type ConcatRequest struct {
+	Trg  string
+	Srcs []string
+}
+
+func (m *ConcatRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Trg)
+	for _, src := range m.Srcs {
+		e.String(2, src)
+	}
+}
+
+func (m *ConcatRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Trg = d.String()
+		case 2:
+			m.Srcs = append(m.Srcs, d.String())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// XAttr is XAttrProto: an extended attribute, its name split into the
+// namespace, one of the XAttrNamespace values, and the rest. Value is nil
+// in requests and responses that carry names only.
+type XAttr struct {
+	Namespace uint64
+	Name      string
+	Value     []byte
+}
+
+// XAttrNamespaceProto values, in the order of their name prefixes "user.",
+// "trusted.", "security.", "system." and "raw.".
+const (
+	XAttrUser = iota
+	XAttrTrusted
+	XAttrSecurity
+	XAttrSystem
+	XAttrRaw
+)
+
+func (m *XAttr) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Namespace)
+	e.String(2, m.Name)
+	if m.Value != nil {
+		e.BytesField(3, m.Value)
+	}
+}
+
+func (m *XAttr) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Namespace = d.Uint64()
+		case 2:
+			m.Name = d.String()
+		case 3:
+			m.Value = append([]byte{}, d.Bytes()...)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SetXAttrRequest is SetXAttrRequestProto, with Flag holding the
+// XAttrSetFlagProto bits, CREATE 1 and REPLACE 2; the response is empty.
+type SetXAttrRequest struct {
+	Src   string
+	XAttr *XAttr
+	Flag  uint32
+}
+
+func (m *SetXAttrRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	if m.XAttr != nil {
+		e.Message(2, m.XAttr)
+	}
+	e.Uint64(3, uint64(m.Flag))
+}
+
+func (m *SetXAttrRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.XAttr = &XAttr{}
+			d.Message(m.XAttr)
+		case 3:
+			m.Flag = uint32(d.Uint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// XAttrsRequest is GetXAttrsRequestProto, the XAttrs holding the names to
+// get, all if none, and RemoveXAttrRequestProto, holding the one to remove.
+// Its responses are an XAttrsResponse and empty.
+type XAttrsRequest struct {
+	Src    string
+	XAttrs []*XAttr
+}
+
+func (m *XAttrsRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	for _, x := range m.XAttrs {
+		e.Message(2, x)
+	}
+}
+
+func (m *XAttrsRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			x := &XAttr{}
+			d.Message(x)
+			m.XAttrs = append(m.XAttrs, x)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// XAttrsResponse is GetXAttrsResponseProto and ListXAttrsResponseProto,
+// whose XAttrs have no values; the request of listXAttrs is a
+// SrcRequest.
+type XAttrsResponse struct {
+	XAttrs []*XAttr
+}
+
+func (m *XAttrsResponse) MarshalProto(e *protowire.Encoder) {
+	for _, x := range m.XAttrs {
+		e.Message(1, x)
+	}
+}
+
+func (m *XAttrsResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			x := &XAttr{}
+			d.Message(x)
+			m.XAttrs = append(m.XAttrs, x)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// AclEntry is AclEntryProto: Type, Scope and Permissions hold the
+// AclEntryTypeProto, AclEntryScopeProto and FsActionProto values, the
+// latter the rwx bits; Name is empty for the entries of the owner, group,
+// mask and others.
+type AclEntry struct {
+	Type        uint32
+	Scope       uint32
+	Permissions uint32
+	Name        string
+}
+
+func (m *AclEntry) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, uint64(m.Type))
+	e.Uint64(2, uint64(m.Scope))
+	e.Uint64(3, uint64(m.Permissions))
+	if m.Name != "" {
+		e.String(4, m.Name)
+	}
+}
+
+func (m *AclEntry) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Type = uint32(d.Uint64())
+		case 2:
+			m.Scope = uint32(d.Uint64())
+		case 3:
+			m.Permissions = uint32(d.Uint64())
+		case 4:
+			m.Name = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// AclRequest is SetAclRequestProto, ModifyAclEntriesRequestProto and
+// RemoveAclEntriesRequestProto; their responses are empty, as are those of
+// removeDefaultAcl and removeAcl, whose requests are a SrcRequest.
+type AclRequest struct {
+	Src     string
+	AclSpec []*AclEntry
+}
+
+func (m *AclRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	for _, a := range m.AclSpec {
+		e.Message(2, a)
+	}
+}
+
+func (m *AclRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			a := &AclEntry{}
+			d.Message(a)
+			m.AclSpec = append(m.AclSpec, a)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// AclStatus is AclStatusProto; the Entries leave out those the permission
+// holds.
+type AclStatus struct {
+	Owner      string
+	Group      string
+	Sticky     bool
+	Entries    []*AclEntry
+	Permission uint32
+}
+
+func (m *AclStatus) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Owner)
+	e.String(2, m.Group)
+	e.Bool(3, m.Sticky)
+	for _, a := range m.Entries {
+		e.Message(4, a)
+	}
+	e.Message(5, &permission{m.Permission})
+}
+
+func (m *AclStatus) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Owner = d.String()
+		case 2:
+			m.Group = d.String()
+		case 3:
+			m.Sticky = d.Bool()
+		case 4:
+			a := &AclEntry{}
+			d.Message(a)
+			m.Entries = append(m.Entries, a)
+		case 5:
+			var p permission
+			d.Message(&p)
+			m.Permission = p.perm
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetAclStatusResponse is GetAclStatusResponseProto; the request is a
+// SrcRequest.
+type GetAclStatusResponse struct {
+	Result *AclStatus
+}
+
+func (m *GetAclStatusResponse) MarshalProto(e *protowire.Encoder) {
+	if m.Result != nil {
+		e.Message(1, m.Result)
+	}
+}
+
+func (m *GetAclStatusResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Result = &AclStatus{}
+			d.Message(m.Result)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// SnapshotRequest is the request of the calls on the snapshots of a
+// directory: CreateSnapshotRequestProto, DeleteSnapshotRequestProto and,
+// with NewName, RenameSnapshotRequestProto. AllowSnapshotRequestProto and
+// DisallowSnapshotRequestProto are SrcRequests.
+type SnapshotRequest struct {
+	SnapshotRoot string
+	Name         string // the namenode names the snapshot if empty
+	NewName      string
+}
+
+func (m *SnapshotRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.SnapshotRoot)
+	if m.Name != "" {
+		e.String(2, m.Name)
+	}
+	if m.NewName != "" {
+		e.String(3, m.NewName)
+	}
+}
+
+func (m *SnapshotRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.SnapshotRoot = d.String()
+		case 2:
+			m.Name = d.String()
+		case 3:
+			m.NewName = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// CreateSnapshotResponse is CreateSnapshotResponseProto.
+type CreateSnapshotResponse struct {
+	SnapshotPath string
+}
+
+func (m *CreateSnapshotResponse) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.SnapshotPath)
+}
+
+func (m *CreateSnapshotResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.SnapshotPath = d.String()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// SnapshottableDirStatus is SnapshottableDirectoryStatusProto; the path of
+// the directory is ParentFullPath joined with DirStatus.Path.
+type SnapshottableDirStatus struct {
+	DirStatus      FileStatus
+	SnapshotQuota  uint32
+	SnapshotNumber uint32
+	ParentFullPath []byte
+}
+
+func (m *SnapshottableDirStatus) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.DirStatus)
+	e.Uint64(2, uint64(m.SnapshotQuota))
+	e.Uint64(3, uint64(m.SnapshotNumber))
+	e.BytesField(4, m.ParentFullPath)
+}
+
+func (m *SnapshottableDirStatus) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.DirStatus)
+		case 2:
+			m.SnapshotQuota = uint32(d.Uint64())
+		case 3:
+			m.SnapshotNumber = uint32(d.Uint64())
+		case 4:
+			m.ParentFullPath = append([]byte(nil), d.Bytes()...)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetSnapshottableDirListingResponse is
+// GetSnapshottableDirListingResponseProto, flattening its
+// SnapshottableDirectoryListingProto, which is missing when there are no
+// snapshottable directories. The request is Empty.
+type GetSnapshottableDirListingResponse struct {
+	Dirs []*SnapshottableDirStatus
+}
+
+func (m *GetSnapshottableDirListingResponse) MarshalProto(e *protowire.Encoder) {
+	if len(m.Dirs) == 0 {
+		return
+	}
+	var list protowire.Encoder
+	for _, dir := range m.Dirs {
+		list.Message(1, dir)
+	}
+	e.BytesField(1, list.Bytes())
+}
+
+func (m *GetSnapshottableDirListingResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field != 1 {
+			d.Skip()
+			continue
+		}
+		list := protowire.NewDecoder(d.Bytes())
+		for {
+			f, ok := list.Next()
+			if !ok {
+				break
+			}
+			if f == 1 {
+				dir := new(SnapshottableDirStatus)
+				list.Message(dir)
+				m.Dirs = append(m.Dirs, dir)
+			} else {
+				list.Skip()
+			}
+		}
+		if err := list.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// GetSnapshotDiffReportRequest is GetSnapshotDiffReportRequestProto; an
+// empty snapshot name stands for the current state of the directory.
+type GetSnapshotDiffReportRequest struct {
+	SnapshotRoot string
+	FromSnapshot string
+	ToSnapshot   string
+}
+
+func (m *GetSnapshotDiffReportRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.SnapshotRoot)
+	e.String(2, m.FromSnapshot)
+	e.String(3, m.ToSnapshot)
+}
+
+func (m *GetSnapshotDiffReportRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.SnapshotRoot = d.String()
+		case 2:
+			m.FromSnapshot = d.String()
+		case 3:
+			m.ToSnapshot = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SnapshotDiffReportEntry is SnapshotDiffReportEntryProto. The paths are
+// relative to the snapshot root, empty for the root itself; TargetPath is
+// only set for renames. ModificationLabel is "+", "-", "M" or "R".
+type SnapshotDiffReportEntry struct {
+	Fullpath          []byte
+	ModificationLabel string
+	TargetPath        []byte
+}
+
+func (m *SnapshotDiffReportEntry) MarshalProto(e *protowire.Encoder) {
+	e.BytesField(1, m.Fullpath)
+	e.String(2, m.ModificationLabel)
+	if m.TargetPath != nil {
+		e.BytesField(3, m.TargetPath)
+	}
+}
+
+func (m *SnapshotDiffReportEntry) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Fullpath = append([]byte(nil), d.Bytes()...)
+		case 2:
+			m.ModificationLabel = d.String()
+		case 3:
+			m.TargetPath = append([]byte(nil), d.Bytes()...)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SnapshotDiffReport is SnapshotDiffReportProto.
+type SnapshotDiffReport struct {
+	SnapshotRoot string
+	FromSnapshot string
+	ToSnapshot   string
+	Entries      []*SnapshotDiffReportEntry
+}
+
+func (m *SnapshotDiffReport) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.SnapshotRoot)
+	e.String(2, m.FromSnapshot)
+	e.String(3, m.ToSnapshot)
+	for _, entry := range m.Entries {
+		e.Message(4, entry)
+	}
+}
+
+func (m *SnapshotDiffReport) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.SnapshotRoot = d.String()
+		case 2:
+			m.FromSnapshot = d.String()
+		case 3:
+			m.ToSnapshot = d.String()
+		case 4:
+			entry := new(SnapshotDiffReportEntry)
+			d.Message(entry)
+			m.Entries = append(m.Entries, entry)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetSnapshotDiffReportResponse is GetSnapshotDiffReportResponseProto.
+type GetSnapshotDiffReportResponse struct {
+	Report SnapshotDiffReport
+}
+
+func (m *GetSnapshotDiffReportResponse) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Report)
+}
+
+func (m *GetSnapshotDiffReportResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message(&m.Report)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// SetTimesRequest is SetTimesRequestProto, with times in milliseconds since
+// the epoch; -1 leaves a time unchanged.
+type SetTimesRequest struct {
+	Src   string
+	Mtime int64
+	Atime int64
+}
+
+func (m *SetTimesRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Int64(2, m.Mtime)
+	e.Int64(3, m.Atime)
+}
+
+func (m *SetTimesRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Mtime = d.Int64()
+		case 3:
+			m.Atime = d.Int64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// SetQuotaRequest is SetQuotaRequestProto; a StorageType other than 0 sets
+// the space quota on that storage type only. The response is Empty.
+type SetQuotaRequest struct {
+	Path              string
+	NamespaceQuota    uint64
+	StoragespaceQuota uint64
+	StorageType       uint64
+}
+
+func (m *SetQuotaRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Path)
+	e.Uint64(2, m.NamespaceQuota)
+	e.Uint64(3, m.StoragespaceQuota)
+	if m.StorageType != 0 {
+		e.Uint64(4, m.StorageType)
+	}
+}
+
+func (m *SetQuotaRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Path = d.String()
+		case 2:
+			m.NamespaceQuota = d.Uint64()
+		case 3:
+			m.StoragespaceQuota = d.Uint64()
+		case 4:
+			m.StorageType = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetContentSummaryResponse is GetContentSummaryResponseProto.
+type GetContentSummaryResponse struct {
+	Summary ContentSummary
+}
+
+func (m *GetContentSummaryResponse) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Summary)
+}
+
+func (m *GetContentSummaryResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message(&m.Summary)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// GetFsStatsResponse is GetFsStatsResponseProto.
+type GetFsStatsResponse struct {
+	Capacity        uint64
+	Used            uint64
+	Remaining       uint64
+	UnderReplicated uint64
+	CorruptBlocks   uint64
+	MissingBlocks   uint64
+}
+
+func (m *GetFsStatsResponse) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, m.Capacity)
+	e.Uint64(2, m.Used)
+	e.Uint64(3, m.Remaining)
+	e.Uint64(4, m.UnderReplicated)
+	e.Uint64(5, m.CorruptBlocks)
+	e.Uint64(6, m.MissingBlocks)
+}
+
+func (m *GetFsStatsResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Capacity = d.Uint64()
+		case 2:
+			m.Used = d.Uint64()
+		case 3:
+			m.Remaining = d.Uint64()
+		case 4:
+			m.UnderReplicated = d.Uint64()
+		case 5:
+			m.CorruptBlocks = d.Uint64()
+		case 6:
+			m.MissingBlocks = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetServerDefaultsResponse is GetServerDefaultsResponseProto.
+type GetServerDefaultsResponse struct {
+	ServerDefaults ServerDefaults
+}
+
+func (m *GetServerDefaultsResponse) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.ServerDefaults)
+}
+
+func (m *GetServerDefaultsResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message(&m.ServerDefaults)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// The cipher suite and protocol version of EncryptionZoneProto for zones
+// encrypted with AES-CTR, the default.
+const (
+	CipherAESCTRNoPadding = 2 // CipherSuiteProto AES_CTR_NOPADDING
+	CryptoEncryptionZones = 2 // CryptoProtocolVersionProto ENCRYPTION_ZONES
+)
+
+// EncryptionZone is EncryptionZoneProto.
+type EncryptionZone struct {
+	ID                    int64
+	Path                  string
+	Suite                 uint64
+	CryptoProtocolVersion uint64
+	KeyName               string
+}
+
+func (m *EncryptionZone) MarshalProto(e *protowire.Encoder) {
+	e.Int64(1, m.ID)
+	e.String(2, m.Path)
+	e.Uint64(3, m.Suite)
+	e.Uint64(4, m.CryptoProtocolVersion)
+	e.String(5, m.KeyName)
+}
+
+func (m *EncryptionZone) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.ID = d.Int64()
+		case 2:
+			m.Path = d.String()
+		case 3:
+			m.Suite = d.Uint64()
+		case 4:
+			m.CryptoProtocolVersion = d.Uint64()
+		case 5:
+			m.KeyName = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetEZForPathResponse is GetEZForPathResponseProto; Zone is nil for paths
+// in no encryption zone. The request is a SrcRequest.
+type GetEZForPathResponse struct {
+	Zone *EncryptionZone
+}
+
+func (m *GetEZForPathResponse) MarshalProto(e *protowire.Encoder) {
+	if m.Zone != nil {
+		e.Message(1, m.Zone)
+	}
+}
+
+func (m *GetEZForPathResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Zone = new(EncryptionZone)
+			d.Message(m.Zone)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// ListEncryptionZonesRequest is ListEncryptionZonesRequestProto, listing
+// the zones after the one of ID PrevID.
+type ListEncryptionZonesRequest struct {
+	PrevID int64
+}
+
+func (m *ListEncryptionZonesRequest) MarshalProto(e *protowire.Encoder) {
+	e.Int64(1, m.PrevID)
+}
+
+func (m *ListEncryptionZonesRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.PrevID = d.Int64()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// ListEncryptionZonesResponse is ListEncryptionZonesResponseProto.
+type ListEncryptionZonesResponse struct {
+	Zones   []*EncryptionZone
+	HasMore bool
+}
+
+func (m *ListEncryptionZonesResponse) MarshalProto(e *protowire.Encoder) {
+	for _, z := range m.Zones {
+		e.Message(1, z)
+	}
+	e.Bool(2, m.HasMore)
+}
+
+func (m *ListEncryptionZonesResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			z := new(EncryptionZone)
+			d.Message(z)
+			m.Zones = append(m.Zones, z)
+		case 2:
+			m.HasMore = d.Bool()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetBlockLocationsRequest is GetBlockLocationsRequestProto.
+type GetBlockLocationsRequest struct {
+	Src    string
+	Offset uint64
+	Length uint64
+}
+
+func (m *GetBlockLocationsRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Uint64(2, m.Offset)
+	e.Uint64(3, m.Length)
+}
+
+func (m *GetBlockLocationsRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Offset = d.Uint64()
+		case 3:
+			m.Length = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// GetBlockLocationsResponse is GetBlockLocationsResponseProto; Locations
+// is nil if the file does not exist.
+type GetBlockLocationsResponse struct {
+	Locations *LocatedBlocks
+}
+
+func (m *GetBlockLocationsResponse) MarshalProto(e *protowire.Encoder) {
+	if m.Locations != nil {
+		e.Message(1, m.Locations)
+	}
+}
+
+func (m *GetBlockLocationsResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.Locations = new(LocatedBlocks)
+			d.Message(m.Locations)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// CreateFlagProto bits.
+const (
+	CreateFlagCreate    = 0x01
+	CreateFlagOverwrite = 0x02
+	CreateFlagAppend    = 0x04
+	CreateFlagNewBlock  = 0x20
+)
+
+// CreateRequest is CreateRequestProto.
+type CreateRequest struct {
+	Src          string
+	Masked       uint32
+	ClientName   string
+	CreateFlag   uint32
+	CreateParent bool
+	Replication  uint32
+	BlockSize    uint64
+}
+
+func (m *CreateRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.Message(2, &permission{m.Masked})
+	e.String(3, m.ClientName)
+	e.Uint64(4, uint64(m.CreateFlag))
+	e.Bool(5, m.CreateParent)
+	e.Uint64(6, uint64(m.Replication))
+	e.Uint64(7, m.BlockSize)
+}
+
+func (m *CreateRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			var p permission
+			d.Message(&p)
+			m.Masked = p.perm
+		case 3:
+			m.ClientName = d.String()
+		case 4:
+			m.CreateFlag = uint32(d.Uint64())
+		case 5:
+			m.CreateParent = d.Bool()
+		case 6:
+			m.Replication = uint32(d.Uint64())
+		case 7:
+			m.BlockSize = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// CreateResponse is CreateResponseProto.
+type CreateResponse struct {
+	FS *FileStatus
+}
+
+func (m *CreateResponse) MarshalProto(e *protowire.Encoder) {
+	if m.FS != nil {
+		e.Message(1, m.FS)
+	}
+}
+
+func (m *CreateResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.FS = new(FileStatus)
+			d.Message(m.FS)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// AppendRequest is AppendRequestProto.
+type AppendRequest struct {
+	Src        string
+	ClientName string
+	Flag       uint32
+}
+
+func (m *AppendRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.String(2, m.ClientName)
+	e.Uint64(3, uint64(m.Flag))
+}
+
+func (m *AppendRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.ClientName = d.String()
+		case 3:
+			m.Flag = uint32(d.Uint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// AppendResponse is AppendResponseProto; Block is the last block to append
+// to, nil if the file ends on a block boundary or a new block was asked for.
+type AppendResponse struct {
+	Block *LocatedBlock
+	FS    *FileStatus
+}
+
+func (m *AppendResponse) MarshalProto(e *protowire.Encoder) {
+	if m.Block != nil {
+		e.Message(1, m.Block)
+	}
+	if m.FS != nil {
+		e.Message(2, m.FS)
+	}
+}
+
+func (m *AppendResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Block = new(LocatedBlock)
+			d.Message(m.Block)
+		case 2:
+			m.FS = new(FileStatus)
+			d.Message(m.FS)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// AddBlockRequest is AddBlockRequestProto. Previous is the last block
+// written, nil for the first block of the file.
+type AddBlockRequest struct {
+	Src          string
+	ClientName   string
+	Previous     *ExtendedBlock
+	ExcludeNodes []*DatanodeInfo
+	FileID       uint64
+}
+
+func (m *AddBlockRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.String(2, m.ClientName)
+	if m.Previous != nil {
+		e.Message(3, m.Previous)
+	}
+	for _, n := range m.ExcludeNodes {
+		e.Message(4, n)
+	}
+	e.Uint64(5, m.FileID)
+}
+
+func (m *AddBlockRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.ClientName = d.String()
+		case 3:
+			m.Previous = new(ExtendedBlock)
+			d.Message(m.Previous)
+		case 4:
+			n := new(DatanodeInfo)
+			d.Message(n)
+			m.ExcludeNodes = append(m.ExcludeNodes, n)
+		case 5:
+			m.FileID = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// BlockResponse is the response of calls returning a located block:
+// AddBlockResponseProto and UpdateBlockForPipelineResponseProto.
+type BlockResponse struct {
+	Block LocatedBlock
+}
+
+func (m *BlockResponse) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Block)
+}
+
+func (m *BlockResponse) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			d.Message(&m.Block)
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+// AbandonBlockRequest is AbandonBlockRequestProto.
+type AbandonBlockRequest struct {
+	Block  ExtendedBlock
+	Src    string
+	Holder string
+	FileID uint64
+}
+
+func (m *AbandonBlockRequest) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Block)
+	e.String(2, m.Src)
+	e.String(3, m.Holder)
+	e.Uint64(4, m.FileID)
+}
+
+func (m *AbandonBlockRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.Block)
+		case 2:
+			m.Src = d.String()
+		case 3:
+			m.Holder = d.String()
+		case 4:
+			m.FileID = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// CompleteRequest is CompleteRequestProto; its response is a BoolResponse,
+// false while the last block has not reached its minimal replication.
+type CompleteRequest struct {
+	Src        string
+	ClientName string
+	Last       *ExtendedBlock
+	FileID     uint64
+}
+
+func (m *CompleteRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.String(2, m.ClientName)
+	if m.Last != nil {
+		e.Message(3, m.Last)
+	}
+	e.Uint64(4, m.FileID)
+}
+
+func (m *CompleteRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.ClientName = d.String()
+		case 3:
+			m.Last = new(ExtendedBlock)
+			d.Message(m.Last)
+		case 4:
+			m.FileID = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// FsyncRequest is FsyncRequestProto, which persists the blocks of a file
+// being written on the namenode and records the length of its last block;
+// the response is empty.
+type FsyncRequest struct {
+	Src             string
+	Client          string
+	LastBlockLength int64 // -1 if unknown
+	FileID          uint64
+}
+
+func (m *FsyncRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.Src)
+	e.String(2, m.Client)
+	e.Sint64(3, m.LastBlockLength)
+	e.Uint64(4, m.FileID)
+}
+
+func (m *FsyncRequest) UnmarshalProto(d *protowire.Decoder) error {
+	m.LastBlockLength = -1
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.Src = d.String()
+		case 2:
+			m.Client = d.String()
+		case 3:
+			m.LastBlockLength = d.Sint64()
+		case 4:
+			m.FileID = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// UpdateBlockForPipelineRequest is UpdateBlockForPipelineRequestProto; the
+// response carries the block with a new generation stamp.
+type UpdateBlockForPipelineRequest struct {
+	Block      ExtendedBlock
+	ClientName string
+}
+
+func (m *UpdateBlockForPipelineRequest) MarshalProto(e *protowire.Encoder) {
+	e.Message(1, &m.Block)
+	e.String(2, m.ClientName)
+}
+
+func (m *UpdateBlockForPipelineRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			d.Message(&m.Block)
+		case 2:
+			m.ClientName = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// UpdatePipelineRequest is UpdatePipelineRequestProto.
+type UpdatePipelineRequest struct {
+	ClientName string
+	OldBlock   ExtendedBlock
+	NewBlock   ExtendedBlock
+	NewNodes   []*DatanodeInfo
+	StorageIDs []string
+}
+
+func (m *UpdatePipelineRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.ClientName)
+	e.Message(2, &m.OldBlock)
+	e.Message(3, &m.NewBlock)
+	for _, n := range m.NewNodes {
+		e.Message(4, (*DatanodeID)(n))
+	}
+	for _, id := range m.StorageIDs {
+		e.String(5, id)
+	}
+}
+
+func (m *UpdatePipelineRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.ClientName = d.String()
+		case 2:
+			d.Message(&m.OldBlock)
+		case 3:
+			d.Message(&m.NewBlock)
+		case 4:
+			n := new(DatanodeInfo)
+			d.Message((*DatanodeID)(n))
+			m.NewNodes = append(m.NewNodes, n)
+		case 5:
+			m.StorageIDs = append(m.StorageIDs, d.String())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// RenewLeaseRequest is RenewLeaseRequestProto.
+type RenewLeaseRequest struct {
+	ClientName string
+}
+
+func (m *RenewLeaseRequest) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.ClientName)
+}
+
+func (m *RenewLeaseRequest) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.ClientName = d.String()
+		} else {
+			d.Skip()
+		}
+	}
+}
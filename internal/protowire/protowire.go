@@ -0,0 +1,338 @@
+// Package protowire encodes and decodes the protocol buffer wire format, as
+// much of it as the Hadoop RPC and data transfer protocols use, without
+// generated code or dependencies.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Wire types.
+const (
+	Varint  = 0
+	Fixed64 = 1
+	Bytes   = 2
+	Fixed32 = 5
+)
+
+var (
+	errOverflow  = errors.New("protowire: varint overflows 64 bits")
+	errTruncated = errors.New("protowire: truncated message")
+	errWireType  = errors.New("protowire: unexpected wire type")
+)
+
+// Encoder appends fields to a message.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// Uint64 appends a uint32, uint64, int32 or int64 field; negative int32 and
+// int64 values must be passed sign-extended.
+func (e *Encoder) Uint64(field int, v uint64) {
+	e.tag(field, Varint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// Int64 appends an int32 or int64 field.
+func (e *Encoder) Int64(field int, v int64) {
+	e.Uint64(field, uint64(v))
+}
+
+// Sint64 appends a zigzag-encoded sint32 or sint64 field.
+func (e *Encoder) Sint64(field int, v int64) {
+	e.Uint64(field, uint64(v<<1)^uint64(v>>63))
+}
+
+// Bool appends a bool field.
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.Uint64(field, 1)
+	} else {
+		e.Uint64(field, 0)
+	}
+}
+
+// Fixed32 appends a fixed32 or float field.
+func (e *Encoder) Fixed32(field int, v uint32) {
+	e.tag(field, Fixed32)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// Fixed64 appends a fixed64 or double field.
+func (e *Encoder) Fixed64(field int, v uint64) {
+	e.tag(field, Fixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+// Double appends a double field.
+func (e *Encoder) Double(field int, v float64) {
+	e.Fixed64(field, math.Float64bits(v))
+}
+
+// BytesField appends a bytes field.
+func (e *Encoder) BytesField(field int, v []byte) {
+	e.tag(field, Bytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// String appends a string field.
+func (e *Encoder) String(field int, v string) {
+	e.tag(field, Bytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Packed appends a packed repeated varint field.
+func (e *Encoder) Packed(field int, vs []uint64) {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, v)
+	}
+	e.BytesField(field, b)
+}
+
+// Message appends an embedded message field.
+func (e *Encoder) Message(field int, m Marshaler) {
+	var sub Encoder
+	m.MarshalProto(&sub)
+	e.BytesField(field, sub.buf)
+}
+
+// Marshaler is a message that can encode itself.
+type Marshaler interface {
+	MarshalProto(e *Encoder)
+}
+
+// Unmarshaler is a message that can decode itself.
+type Unmarshaler interface {
+	UnmarshalProto(d *Decoder) error
+}
+
+// Marshal returns the encoding of m.
+func Marshal(m Marshaler) []byte {
+	var e Encoder
+	m.MarshalProto(&e)
+	return e.buf
+}
+
+// Unmarshal decodes b into m.
+func Unmarshal(b []byte, m Unmarshaler) error {
+	return m.UnmarshalProto(NewDecoder(b))
+}
+
+// AppendDelimited appends the encoding of m prefixed with its varint length,
+// as protobuf's writeDelimitedTo does.
+func AppendDelimited(b []byte, m Marshaler) []byte {
+	msg := Marshal(m)
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// ReadDelimited reads a varint length-prefixed message from r into m.
+func ReadDelimited(r io.ByteReader, m Unmarshaler) error {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, n)
+	for i := range b {
+		if b[i], err = r.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return Unmarshal(b, m)
+}
+
+// Decoder reads the fields of a message in order. Each call to Next must be
+// followed by a call to exactly one of the value methods or Skip.
+type Decoder struct {
+	buf      []byte
+	wireType int
+	err      error
+}
+
+// NewDecoder returns a Decoder reading b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Next advances to the next field and returns its number, or false at the
+// end of the message or on error.
+func (d *Decoder) Next() (int, bool) {
+	if d.err != nil || len(d.buf) == 0 {
+		return 0, false
+	}
+	tag := d.varint()
+	if d.err != nil {
+		return 0, false
+	}
+	d.wireType = int(tag & 7)
+	return int(tag >> 3), true
+}
+
+// Err returns the first error met.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *Decoder) varint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		if n == 0 {
+			d.fail(errTruncated)
+		} else {
+			d.fail(errOverflow)
+		}
+		d.buf = nil
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *Decoder) take(n uint64) []byte {
+	if uint64(len(d.buf)) < n {
+		d.fail(errTruncated)
+		d.buf = nil
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *Decoder) expect(wireType int) bool {
+	if d.wireType != wireType {
+		d.fail(errWireType)
+		d.buf = nil
+		return false
+	}
+	return true
+}
+
+// Uint64 returns the value of a varint field.
+func (d *Decoder) Uint64() uint64 {
+	if !d.expect(Varint) {
+		return 0
+	}
+	return d.varint()
+}
+
+// Int64 returns the value of an int32 or int64 field.
+func (d *Decoder) Int64() int64 {
+	return int64(d.Uint64())
+}
+
+// Sint64 returns the value of a sint32 or sint64 field.
+func (d *Decoder) Sint64() int64 {
+	v := d.Uint64()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// Uint64s appends to vs the value of a repeated varint field, which may be
+// packed or not, and returns the extended slice.
+func (d *Decoder) Uint64s(vs []uint64) []uint64 {
+	if d.wireType != Bytes {
+		return append(vs, d.Uint64())
+	}
+	packed := NewDecoder(d.Bytes())
+	for len(packed.buf) > 0 && packed.err == nil {
+		vs = append(vs, packed.varint())
+	}
+	d.fail(packed.err)
+	return vs
+}
+
+// Bool returns the value of a bool field.
+func (d *Decoder) Bool() bool {
+	return d.Uint64() != 0
+}
+
+// Fixed32 returns the value of a fixed32 field.
+func (d *Decoder) Fixed32() uint32 {
+	if !d.expect(Fixed32) {
+		return 0
+	}
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// Fixed64 returns the value of a fixed64 field.
+func (d *Decoder) Fixed64() uint64 {
+	if !d.expect(Fixed64) {
+		return 0
+	}
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// Double returns the value of a double field.
+func (d *Decoder) Double() float64 {
+	return math.Float64frombits(d.Fixed64())
+}
+
+// Bytes returns the value of a bytes field, aliasing the decoded buffer.
+func (d *Decoder) Bytes() []byte {
+	if !d.expect(Bytes) {
+		return nil
+	}
+	return d.take(d.varint())
+}
+
+// String returns the value of a string field.
+func (d *Decoder) String() string {
+	return string(d.Bytes())
+}
+
+// Message decodes an embedded message field into m.
+func (d *Decoder) Message(m Unmarshaler) {
+	b := d.Bytes()
+	if d.err == nil {
+		d.fail(Unmarshal(b, m))
+	}
+}
+
+// Skip skips the value of the current field.
+func (d *Decoder) Skip() {
+	switch d.wireType {
+	case Varint:
+		d.varint()
+	case Fixed64:
+		d.take(8)
+	case Bytes:
+		d.take(d.varint())
+	case Fixed32:
+		d.take(4)
+	default:
+		d.fail(errWireType)
+		d.buf = nil
+	}
+}
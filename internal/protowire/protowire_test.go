@@ -0,0 +1,111 @@
+package protowire
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+type sample struct {
+	u     uint64
+	i     int64
+	s     int64
+	b     bool
+	f     uint32
+	str   string
+	inner *sample
+}
+
+func (m *sample) MarshalProto(e *Encoder) {
+	e.Uint64(1, m.u)
+	e.Int64(2, m.i)
+	e.Sint64(3, m.s)
+	e.Bool(4, m.b)
+	e.Fixed32(5, m.f)
+	e.String(6, m.str)
+	if m.inner != nil {
+		e.Message(7, m.inner)
+	}
+}
+
+func (m *sample) UnmarshalProto(d *Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			m.u = d.Uint64()
+		case 2:
+			m.i = d.Int64()
+		case 3:
+			m.s = d.Sint64()
+		case 4:
+			m.b = d.Bool()
+		case 5:
+			m.f = d.Fixed32()
+		case 6:
+			m.str = d.String()
+		case 7:
+			m.inner = new(sample)
+			d.Message(m.inner)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := &sample{u: 1 << 40, i: -2, s: -3, b: true, f: 0xdeadbeef, str: "hdfs", inner: &sample{str: "inner"}}
+	var out sample
+	if err := Unmarshal(Marshal(in), &out); err != nil {
+		t.Fatalf("Error on unmarshaling: %v\n", err)
+	}
+	if out.u != in.u || out.i != in.i || out.s != in.s || !out.b || out.f != in.f || out.str != in.str || out.inner == nil || out.inner.str != "inner" {
+		t.Errorf("round trip: got %+v, want %+v\n", out, in)
+	}
+
+	var e Encoder
+	e.Fixed64(99, 1)
+	e.Double(98, 1.5)
+	e.Uint64(1, 7)
+	var skipped sample
+	if err := Unmarshal(e.Bytes(), &skipped); err != nil || skipped.u != 7 {
+		t.Errorf("unknown fields: got %+v, %v\n", skipped, err)
+	}
+
+	b := AppendDelimited(nil, in)
+	out = sample{}
+	if err := ReadDelimited(bufio.NewReader(bytes.NewReader(b)), &out); err != nil || out.str != "hdfs" {
+		t.Errorf("ReadDelimited: got %+v, %v\n", out, err)
+	}
+	if err := ReadDelimited(bufio.NewReader(bytes.NewReader(b[:len(b)-1])), &out); err == nil {
+		t.Errorf("ReadDelimited of a truncated message: no error\n")
+	}
+
+	enc := Marshal(in)
+	if err := Unmarshal(enc[:len(enc)-2], &out); err == nil {
+		t.Errorf("Unmarshal of a truncated message: no error\n")
+	}
+	e = Encoder{}
+	e.String(1, "not a varint")
+	if err := Unmarshal(e.Bytes(), &out); err == nil {
+		t.Errorf("Unmarshal with a wrong wire type: no error\n")
+	}
+
+	e = Encoder{}
+	e.Packed(1, []uint64{1, 300})
+	e.Uint64(1, 2)
+	d := NewDecoder(e.Bytes())
+	var vs []uint64
+	for {
+		if _, ok := d.Next(); !ok {
+			break
+		}
+		vs = d.Uint64s(vs)
+	}
+	if d.Err() != nil || len(vs) != 3 || vs[0] != 1 || vs[1] != 300 || vs[2] != 2 {
+		t.Errorf("Uint64s: got %v, %v\n", vs, d.Err())
+	}
+}
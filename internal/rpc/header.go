@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// Values of the RPC headers, from RpcHeader.proto.
+const (
+	rpcKindProtocolBuffer = 2
+	rpcOpFinalPacket      = 0
+
+	statusSuccess = 0
+	statusError   = 1
+	statusFatal   = 2
+
+	connectionContextCallID = -3
+)
+
+// requestHeader is RpcRequestHeaderProto.
+type requestHeader struct {
+	callID     int32
+	clientID   []byte
+	retryCount int32
+}
+
+func (h *requestHeader) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, rpcKindProtocolBuffer)
+	e.Uint64(2, rpcOpFinalPacket)
+	e.Sint64(3, int64(h.callID))
+	e.BytesField(4, h.clientID)
+	e.Sint64(5, int64(h.retryCount))
+}
+
+func (h *requestHeader) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 3:
+			h.callID = int32(d.Sint64())
+		case 4:
+			h.clientID = append([]byte(nil), d.Bytes()...)
+		case 5:
+			h.retryCount = int32(d.Sint64())
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// methodHeader is RequestHeaderProto, naming the method called.
+type methodHeader struct {
+	method   string
+	protocol string
+	version  uint64
+}
+
+func (h *methodHeader) MarshalProto(e *protowire.Encoder) {
+	e.String(1, h.method)
+	e.String(2, h.protocol)
+	e.Uint64(3, h.version)
+}
+
+func (h *methodHeader) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			h.method = d.String()
+		case 2:
+			h.protocol = d.String()
+		case 3:
+			h.version = d.Uint64()
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// responseHeader is RpcResponseHeaderProto.
+type responseHeader struct {
+	callID         uint32
+	status         uint64
+	serverVersion  uint32
+	exceptionClass string
+	errorMsg       string
+	clientID       []byte
+}
+
+func (h *responseHeader) MarshalProto(e *protowire.Encoder) {
+	e.Uint64(1, uint64(h.callID))
+	e.Uint64(2, h.status)
+	e.Uint64(3, uint64(h.serverVersion))
+	if h.status != statusSuccess {
+		e.String(4, h.exceptionClass)
+		e.String(5, h.errorMsg)
+	}
+	e.BytesField(7, h.clientID)
+}
+
+func (h *responseHeader) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 1:
+			h.callID = uint32(d.Uint64())
+		case 2:
+			h.status = d.Uint64()
+		case 3:
+			h.serverVersion = uint32(d.Uint64())
+		case 4:
+			h.exceptionClass = d.String()
+		case 5:
+			h.errorMsg = d.String()
+		case 7:
+			h.clientID = append([]byte(nil), d.Bytes()...)
+		default:
+			d.Skip()
+		}
+	}
+}
+
+// connectionContext is IpcConnectionContextProto, sent once after the
+// connection header.
+type connectionContext struct {
+	user     string
+	protocol string
+}
+
+func (c *connectionContext) MarshalProto(e *protowire.Encoder) {
+	var u protowire.Encoder
+	u.String(1, c.user) // UserInformationProto.effectiveUser
+	e.BytesField(2, u.Bytes())
+	e.String(3, c.protocol)
+}
+
+func (c *connectionContext) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		switch field {
+		case 2:
+			u := protowire.NewDecoder(d.Bytes())
+			for {
+				f, ok := u.Next()
+				if !ok {
+					break
+				}
+				if f == 1 {
+					c.user = u.String()
+				} else {
+					u.Skip()
+				}
+			}
+			if err := u.Err(); err != nil {
+				return err
+			}
+		case 3:
+			c.protocol = d.String()
+		default:
+			d.Skip()
+		}
+	}
+}
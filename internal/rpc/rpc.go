@@ -0,0 +1,227 @@
+// Package rpc implements the Hadoop IPC protocol (version 9, protocol
+// buffer engine, SIMPLE authentication): a client multiplexing calls over one
+// connection, and a server for fakes used in tests.
+package rpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+const (
+	ipcVersion = 9
+	// maxFrame bounds the responses accepted, as ipc.maximum.data.length.
+	maxFrame = 128 << 20
+)
+
+var connectionHeader = []byte{'h', 'r', 'p', 'c', ipcVersion, 0 /* service class */, 0 /* SIMPLE auth */}
+
+// ErrClosed is returned by calls on a closed Client.
+var ErrClosed = errors.New("rpc: client closed")
+
+// RemoteError is an exception thrown by the server.
+type RemoteError struct {
+	Class   string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	msg := e.Message
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	return e.Class + ": " + msg
+}
+
+// Client is a connection to a Hadoop RPC server for one protocol. Calls may
+// be made concurrently.
+type Client struct {
+	conn     net.Conn
+	protocol string
+	clientID []byte
+
+	wmu sync.Mutex // serializes writes
+
+	mu     sync.Mutex
+	nextID int32
+	calls  map[int32]chan result
+	err    error
+}
+
+type result struct {
+	body []byte
+	err  error
+}
+
+// Dial connects to the server at addr as user, for the named protocol, e.g.
+// "org.apache.hadoop.hdfs.protocol.ClientProtocol".
+func Dial(addr, user, protocol string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, user, protocol)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient starts a session for user and protocol over conn.
+func NewClient(conn net.Conn, user, protocol string) (*Client, error) {
+	c := &Client{conn: conn, protocol: protocol, clientID: make([]byte, 16), calls: map[int32]chan result{}}
+	if _, err := rand.Read(c.clientID); err != nil {
+		return nil, err
+	}
+	frame := append([]byte(nil), connectionHeader...)
+	frame = append(frame, c.frame(connectionContextCallID, &connectionContext{user, protocol})...)
+	if _, err := conn.Write(frame); err != nil {
+		return nil, err
+	}
+	go c.read()
+	return c, nil
+}
+
+// frame returns a length-prefixed request made of the header for callID
+// followed by msgs.
+func (c *Client) frame(callID int32, msgs ...protowire.Marshaler) []byte {
+	b := protowire.AppendDelimited(make([]byte, 4), &requestHeader{callID, c.clientID, -1})
+	for _, m := range msgs {
+		b = protowire.AppendDelimited(b, m)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
+
+// Call invokes method with req and decodes the response into resp. Errors
+// thrown by the server are returned as *RemoteError.
+func (c *Client) Call(method string, req protowire.Marshaler, resp protowire.Unmarshaler) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	id := c.nextID
+	c.nextID = (c.nextID + 1) & 0x7fffffff
+	done := make(chan result, 1)
+	c.calls[id] = done
+	c.mu.Unlock()
+
+	frame := c.frame(id, &methodHeader{method, c.protocol, 1}, req)
+	c.wmu.Lock()
+	_, err := c.conn.Write(frame)
+	c.wmu.Unlock()
+	if err != nil {
+		c.fail(err)
+	}
+	r := <-done
+	if r.err != nil {
+		return r.err
+	}
+	msg, _, err := splitDelimited(r.body)
+	if err != nil {
+		return err
+	}
+	return protowire.Unmarshal(msg, resp)
+}
+
+// read dispatches responses to their calls until the connection fails.
+func (c *Client) read() {
+	r := bufio.NewReader(c.conn)
+	for {
+		body, err := readFrame(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		msg, rest, err := splitDelimited(body)
+		var h responseHeader
+		if err == nil {
+			err = protowire.Unmarshal(msg, &h)
+		}
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if h.status == statusFatal {
+			c.fail(&RemoteError{h.exceptionClass, h.errorMsg})
+			return
+		}
+		c.mu.Lock()
+		done := c.calls[int32(h.callID)]
+		delete(c.calls, int32(h.callID))
+		c.mu.Unlock()
+		if done == nil {
+			continue
+		}
+		if h.status == statusSuccess {
+			done <- result{rest, nil}
+		} else {
+			done <- result{nil, &RemoteError{h.exceptionClass, h.errorMsg}}
+		}
+	}
+}
+
+// fail closes the connection, failing all pending and future calls with
+// err.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	c.err = err
+	c.conn.Close()
+	for id, done := range c.calls {
+		done <- result{nil, err}
+		delete(c.calls, id)
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return nil
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > maxFrame {
+		return nil, fmt.Errorf("rpc: frame of %d bytes exceeds the limit", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// splitDelimited splits a varint length-prefixed message off b.
+func splitDelimited(b []byte) (msg, rest []byte, err error) {
+	n, k := binary.Uvarint(b)
+	if k <= 0 || uint64(len(b)-k) < n {
+		return nil, nil, errors.New("rpc: truncated message")
+	}
+	return b[k : k+int(n)], b[k+int(n):], nil
+}
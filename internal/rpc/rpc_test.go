@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+const testProtocol = "org.example.EchoProtocol"
+
+type echo struct {
+	s string
+}
+
+func (m *echo) MarshalProto(e *protowire.Encoder) {
+	e.String(1, m.s)
+}
+
+func (m *echo) UnmarshalProto(d *protowire.Decoder) error {
+	for {
+		field, ok := d.Next()
+		if !ok {
+			return d.Err()
+		}
+		if field == 1 {
+			m.s = d.String()
+		} else {
+			d.Skip()
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error on listening: %v\n", err)
+	}
+	s := NewServer(l, testProtocol, func(user, method string, req []byte) (protowire.Marshaler, error) {
+		var m echo
+		if err := protowire.Unmarshal(req, &m); err != nil {
+			return nil, err
+		}
+		switch method {
+		case "whoami":
+			return &echo{user}, nil
+		case "echo":
+			return &m, nil
+		}
+		return nil, &RemoteError{"java.lang.NoSuchMethodException", method + "\n\tat somewhere"}
+	})
+	go s.Serve()
+	defer s.Close()
+
+	c, err := Dial(l.Addr().String(), "alice", testProtocol, time.Second)
+	if err != nil {
+		t.Fatalf("Error on dialing: %v\n", err)
+	}
+	defer c.Close()
+
+	var resp echo
+	if err := c.Call("whoami", &echo{}, &resp); err != nil || resp.s != "alice" {
+		t.Errorf("whoami: got %q, %v\n", resp.s, err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			var resp echo
+			if err := c.Call("echo", &echo{s}, &resp); err != nil || resp.s != s {
+				t.Errorf("echo %q: got %q, %v\n", s, resp.s, err)
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+
+	err = c.Call("nosuch", &echo{}, &resp)
+	var re *RemoteError
+	if !errors.As(err, &re) || re.Class != "java.lang.NoSuchMethodException" || re.Error() != "java.lang.NoSuchMethodException: nosuch" {
+		t.Errorf("nosuch: got %v\n", err)
+	}
+
+	other, err := Dial(l.Addr().String(), "bob", "org.example.Other", time.Second)
+	if err != nil {
+		t.Fatalf("Error on dialing: %v\n", err)
+	}
+	if err := other.Call("echo", &echo{}, &resp); !errors.As(err, &re) || re.Class != "org.apache.hadoop.ipc.RpcNoSuchProtocolException" {
+		t.Errorf("unknown protocol: got %v\n", err)
+	}
+	other.Close()
+	if err := other.Call("echo", &echo{}, &resp); err != ErrClosed {
+		t.Errorf("call on a closed client: got %v\n", err)
+	}
+
+	s.Close()
+	if err := c.Call("echo", &echo{"x"}, &resp); err == nil {
+		t.Errorf("call after the server closed: no error\n")
+	}
+}
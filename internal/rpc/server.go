@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// Handler serves a call of method by user, whose encoded request is req.
+// Returning a *RemoteError throws that exception; other errors are thrown as
+// java.io.IOException.
+type Handler func(user, method string, req []byte) (protowire.Marshaler, error)
+
+// Server serves one protocol over Hadoop IPC, for fakes in tests.
+type Server struct {
+	l        net.Listener
+	protocol string
+	handler  Handler
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer returns a Server answering calls to protocol accepted from l
+// with h. Call Serve to start it.
+func NewServer(l net.Listener, protocol string, h Handler) *Server {
+	return &Server{l: l, protocol: protocol, handler: h, conns: map[net.Conn]bool{}}
+}
+
+// Serve accepts connections until the Server is closed.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections, closes those open and waits for their
+// calls to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.l.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	header := make([]byte, len(connectionHeader))
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:5], connectionHeader[:5]) {
+		return
+	}
+	var wmu sync.Mutex
+	var calls sync.WaitGroup
+	defer calls.Wait()
+	user := ""
+	for {
+		body, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		var rh requestHeader
+		msg, rest, err := splitDelimited(body)
+		if err == nil {
+			err = protowire.Unmarshal(msg, &rh)
+		}
+		if err != nil {
+			return
+		}
+		if rh.callID == connectionContextCallID {
+			var cc connectionContext
+			if msg, _, err = splitDelimited(rest); err == nil {
+				err = protowire.Unmarshal(msg, &cc)
+			}
+			if err != nil {
+				return
+			}
+			user = cc.user
+			continue
+		}
+		var mh methodHeader
+		msg, rest, err = splitDelimited(rest)
+		if err == nil {
+			err = protowire.Unmarshal(msg, &mh)
+		}
+		var req []byte
+		if err == nil {
+			req, _, err = splitDelimited(rest)
+		}
+		if err != nil {
+			return
+		}
+		calls.Add(1)
+		go func(rh requestHeader, user string) {
+			defer calls.Done()
+			var resp protowire.Marshaler
+			var err error
+			if mh.protocol != s.protocol {
+				err = &RemoteError{"org.apache.hadoop.ipc.RpcNoSuchProtocolException", "Unknown protocol: " + mh.protocol}
+			} else {
+				resp, err = s.handler(user, mh.method, req)
+			}
+			h := &responseHeader{callID: uint32(rh.callID), serverVersion: ipcVersion, clientID: rh.clientID}
+			if err != nil {
+				var re *RemoteError
+				if !errors.As(err, &re) {
+					re = &RemoteError{"java.io.IOException", err.Error()}
+				}
+				h.status, h.exceptionClass, h.errorMsg = statusError, re.Class, re.Message
+			}
+			b := protowire.AppendDelimited(make([]byte, 4), h)
+			if err == nil {
+				b = protowire.AppendDelimited(b, resp)
+			}
+			binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+			wmu.Lock()
+			conn.Write(b)
+			wmu.Unlock()
+		}(rh, user)
+	}
+}
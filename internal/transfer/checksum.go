@@ -0,0 +1,148 @@
+package transfer
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// ErrChecksum reports block data that does not match its checksums.
+var ErrChecksum = errors.New("transfer: checksum error")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum computes and verifies the checksums of the chunks of
+// BytesPerChecksum bytes block data is divided into.
+type Checksum struct {
+	Type             uint64 // hdfsproto.ChecksumNull, ChecksumCRC32 or ChecksumCRC32C
+	BytesPerChecksum int
+}
+
+// ChecksumOf returns the Checksum described by c.
+func ChecksumOf(c *hdfsproto.Checksum) Checksum {
+	return Checksum{c.Type, int(c.BytesPerChecksum)}
+}
+
+// Proto returns the ChecksumProto describing c.
+func (c Checksum) Proto() hdfsproto.Checksum {
+	return hdfsproto.Checksum{Type: c.Type, BytesPerChecksum: uint32(c.BytesPerChecksum)}
+}
+
+// Valid reports an unknown type or chunk size.
+func (c Checksum) Valid() error {
+	if c.Type > hdfsproto.ChecksumCRC32C || c.BytesPerChecksum <= 0 {
+		return fmt.Errorf("transfer: unsupported checksum type %d with %d bytes per checksum", c.Type, c.BytesPerChecksum)
+	}
+	return nil
+}
+
+// Size returns the size of the checksum of a chunk.
+func (c Checksum) Size() int {
+	if c.Type == hdfsproto.ChecksumNull {
+		return 0
+	}
+	return 4
+}
+
+func (c Checksum) table() *crc32.Table {
+	if c.Type == hdfsproto.ChecksumCRC32 {
+		return crc32.IEEETable
+	}
+	return castagnoli
+}
+
+// Sum returns the checksums of the chunks of data; the last chunk may be
+// partial.
+func (c Checksum) Sum(data []byte) []byte {
+	if c.Type == hdfsproto.ChecksumNull {
+		return nil
+	}
+	sums := make([]byte, 0, (len(data)+c.BytesPerChecksum-1)/c.BytesPerChecksum*4)
+	for len(data) > 0 {
+		n := c.BytesPerChecksum
+		if n > len(data) {
+			n = len(data)
+		}
+		sums = binary.BigEndian.AppendUint32(sums, crc32.Checksum(data[:n], c.table()))
+		data = data[n:]
+	}
+	return sums
+}
+
+// Verify checks data against sums, as computed by Sum.
+func (c Checksum) Verify(data, sums []byte) error {
+	if c.Type == hdfsproto.ChecksumNull {
+		return nil
+	}
+	if len(sums) != (len(data)+c.BytesPerChecksum-1)/c.BytesPerChecksum*4 {
+		return errMalformedPacket
+	}
+	for off := 0; len(data) > 0; off += c.BytesPerChecksum {
+		n := c.BytesPerChecksum
+		if n > len(data) {
+			n = len(data)
+		}
+		if crc32.Checksum(data[:n], c.table()) != binary.BigEndian.Uint32(sums) {
+			return fmt.Errorf("%w in the chunk at %d", ErrChecksum, off)
+		}
+		data, sums = data[n:], sums[4:]
+	}
+	return nil
+}
+
+// BlockChecksum returns what a datanode answers OP_BLOCK_CHECKSUM with for
+// a replica holding data: for hdfsproto.BlockChecksumMD5CRC the MD5 of the
+// checksums of its chunks, for hdfsproto.BlockChecksumCompositeCRC the CRC
+// of the whole replica, which the datanode composes from them.
+func (c Checksum) BlockChecksum(data []byte, typ uint64) (*hdfsproto.BlockChecksumResponse, error) {
+	if c.Type == hdfsproto.ChecksumNull {
+		return nil, errors.New("transfer: no checksum of the replica to compute a block checksum from")
+	}
+	sums := c.Sum(data)
+	resp := &hdfsproto.BlockChecksumResponse{BytesPerCrc: uint32(c.BytesPerChecksum), CrcPerBlock: uint64(len(sums) / 4), CrcType: c.Type}
+	switch typ {
+	case hdfsproto.BlockChecksumMD5CRC:
+		sum := md5.Sum(sums)
+		resp.BlockChecksum = sum[:]
+	case hdfsproto.BlockChecksumCompositeCRC:
+		resp.BlockChecksum = binary.BigEndian.AppendUint32(nil, crc32.Checksum(data, c.table()))
+	default:
+		return nil, fmt.Errorf("transfer: unsupported block checksum type %d", typ)
+	}
+	return resp, nil
+}
+
+// GetBlockChecksum asks dn for the checksum of b of the given
+// BlockChecksumTypeProto. A zero timeout means none. Failures are
+// *DatanodeError values.
+func GetBlockChecksum(dn *hdfsproto.DatanodeInfo, b *hdfsproto.LocatedBlock, typ uint64, timeout time.Duration) (*hdfsproto.BlockChecksumResponse, error) {
+	conn, err := Dial(dn, timeout)
+	if err != nil {
+		return nil, &DatanodeError{dn, err}
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := WriteOp(conn, hdfsproto.BlockChecksum, &hdfsproto.OpBlockChecksum{Block: b.Block, Token: b.BlockToken, Type: typ}); err != nil {
+		return nil, &DatanodeError{dn, err}
+	}
+	var resp hdfsproto.BlockOpResponse
+	if err := protowire.ReadDelimited(bufio.NewReader(conn), &resp); err != nil {
+		return nil, &DatanodeError{dn, err}
+	}
+	if resp.Status != hdfsproto.StatusSuccess {
+		return nil, &DatanodeError{dn, statusError(resp.Status, resp.Message)}
+	}
+	if resp.BlockChecksum == nil {
+		return nil, &DatanodeError{dn, errors.New("transfer: no checksum in the block checksum response")}
+	}
+	return resp.BlockChecksum, nil
+}
@@ -0,0 +1,173 @@
+package transfer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// BlockReader reads a range of a block from one datanode, verifying the
+// checksums of the data it receives.
+type BlockReader struct {
+	dn        *hdfsproto.DatanodeInfo
+	conn      net.Conn
+	r         *bufio.Reader
+	timeout   time.Duration
+	checksum  Checksum
+	next      int64 // offset in the block of the next packet
+	skip      int64 // bytes before the requested offset left to drop
+	remaining int64 // bytes of the range not yet received
+	buf       []byte
+	last      bool // the last packet of the block was received
+}
+
+// ReadBlock asks dn for length bytes of b from offset in the block, which
+// the returned reader delivers until io.EOF. A zero timeout means none.
+// Failures, of ReadBlock as of Read, are *DatanodeError values.
+func ReadBlock(dn *hdfsproto.DatanodeInfo, b *hdfsproto.LocatedBlock, client string, offset, length int64, timeout time.Duration) (*BlockReader, error) {
+	conn, err := Dial(dn, timeout)
+	if err != nil {
+		return nil, &DatanodeError{dn, err}
+	}
+	br := &BlockReader{dn: dn, conn: conn, r: bufio.NewReader(conn), timeout: timeout, remaining: length}
+	if err := br.start(b, client, offset, length); err != nil {
+		conn.Close()
+		return nil, &DatanodeError{dn, err}
+	}
+	return br, nil
+}
+
+func (br *BlockReader) deadline() {
+	if br.timeout > 0 {
+		br.conn.SetDeadline(time.Now().Add(br.timeout))
+	}
+}
+
+func (br *BlockReader) start(b *hdfsproto.LocatedBlock, client string, offset, length int64) error {
+	br.deadline()
+	op := &hdfsproto.OpReadBlock{
+		Header:        hdfsproto.ClientOperationHeader{Block: b.Block, Token: b.BlockToken, ClientName: client},
+		Offset:        uint64(offset),
+		Len:           uint64(length),
+		SendChecksums: true,
+	}
+	if err := WriteOp(br.conn, hdfsproto.ReadBlock, op); err != nil {
+		return err
+	}
+	var resp hdfsproto.BlockOpResponse
+	if err := protowire.ReadDelimited(br.r, &resp); err != nil {
+		return err
+	}
+	if resp.Status != hdfsproto.StatusSuccess {
+		return statusError(resp.Status, resp.Message)
+	}
+	if resp.Checksum == nil {
+		return errors.New("transfer: no checksum in the read response")
+	}
+	br.checksum = ChecksumOf(resp.Checksum)
+	if err := br.checksum.Valid(); err != nil {
+		return err
+	}
+	chunk := int64(resp.ChunkOffset)
+	if chunk > offset || offset-chunk >= int64(br.checksum.BytesPerChecksum) {
+		return errors.New("transfer: bad chunk offset in the read response")
+	}
+	br.next, br.skip = chunk, offset-chunk
+	return nil
+}
+
+// Read reads the next bytes of the range.
+func (br *BlockReader) Read(p []byte) (int, error) {
+	for len(br.buf) == 0 {
+		if br.remaining == 0 {
+			br.finish()
+			return 0, io.EOF
+		}
+		if br.last {
+			return 0, &DatanodeError{br.dn, io.ErrUnexpectedEOF}
+		}
+		if err := br.readPacket(); err != nil {
+			return 0, &DatanodeError{br.dn, err}
+		}
+	}
+	n := copy(p, br.buf)
+	br.buf = br.buf[n:]
+	return n, nil
+}
+
+func (br *BlockReader) readPacket() error {
+	br.deadline()
+	p, err := ReadPacket(br.r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if p.LastPacketInBlock {
+		br.last = true
+	}
+	if len(p.Data) == 0 {
+		return nil
+	}
+	if p.OffsetInBlock != br.next {
+		return errMalformedPacket
+	}
+	if err := br.checksum.Verify(p.Data, p.Sums); err != nil {
+		return err
+	}
+	br.next += int64(len(p.Data))
+	data := p.Data
+	if br.skip > 0 {
+		n := br.skip
+		if n > int64(len(data)) {
+			n = int64(len(data))
+		}
+		data, br.skip = data[n:], br.skip-n
+	}
+	if int64(len(data)) > br.remaining {
+		data = data[:br.remaining]
+	}
+	br.remaining -= int64(len(data))
+	br.buf = data
+	return nil
+}
+
+// finish reads the empty packet ending the range and tells the datanode
+// the data was verified, as the Java client does; failures no longer
+// matter.
+func (br *BlockReader) finish() {
+	if br.conn == nil {
+		return
+	}
+	for !br.last {
+		if err := br.readPacket(); err != nil {
+			return
+		}
+	}
+	br.deadline()
+	status := &hdfsproto.ClientReadStatus{Status: hdfsproto.StatusChecksumOK}
+	br.conn.Write(protowire.AppendDelimited(nil, status))
+	br.conn.Close()
+	br.conn = nil
+}
+
+// Datanode returns the datanode the reader reads from.
+func (br *BlockReader) Datanode() *hdfsproto.DatanodeInfo {
+	return br.dn
+}
+
+// Close closes the connection to the datanode.
+func (br *BlockReader) Close() error {
+	if br.conn == nil {
+		return nil
+	}
+	err := br.conn.Close()
+	br.conn = nil
+	return err
+}
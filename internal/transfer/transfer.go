@@ -0,0 +1,138 @@
+// Package transfer speaks the data transfer protocol of HDFS datanodes:
+// reading a block range with OP_READ_BLOCK, writing a block through a
+// pipeline of datanodes with OP_WRITE_BLOCK and asking for the checksum of
+// a block with OP_BLOCK_CHECKSUM, for the pure-Go backend, and the framing
+// the fake datanode of hdfstest serves.
+package transfer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+// maxPacketSize bounds the packets accepted from a peer.
+const maxPacketSize = 16 << 20
+
+var errMalformedPacket = errors.New("transfer: malformed packet")
+
+// DatanodeError is a failure to read from or write to Datanode.
+type DatanodeError struct {
+	Datanode *hdfsproto.DatanodeInfo
+	Err      error
+}
+
+func (e *DatanodeError) Error() string {
+	return "datanode " + e.Datanode.XferAddr() + ": " + e.Err.Error()
+}
+
+func (e *DatanodeError) Unwrap() error {
+	return e.Err
+}
+
+// statusError reports a status other than success from a datanode.
+func statusError(status uint64, message string) error {
+	var name string
+	switch status {
+	case hdfsproto.StatusError:
+		name = "ERROR"
+	case hdfsproto.StatusErrorChecksum:
+		name = "ERROR_CHECKSUM"
+	case hdfsproto.StatusErrorInvalid:
+		name = "ERROR_INVALID"
+	case hdfsproto.StatusErrorExists:
+		name = "ERROR_EXISTS"
+	case hdfsproto.StatusErrorAccessToken:
+		name = "ERROR_ACCESS_TOKEN"
+	default:
+		name = fmt.Sprintf("status %d", status)
+	}
+	if message == "" {
+		return errors.New("transfer: " + name)
+	}
+	return fmt.Errorf("transfer: %s: %s", name, message)
+}
+
+// Dial connects to the data transfer port of dn; a zero timeout means none.
+func Dial(dn *hdfsproto.DatanodeInfo, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", dn.XferAddr(), timeout)
+}
+
+// WriteOp sends an operation: the protocol version, the op code and the
+// delimited request m.
+func WriteOp(w io.Writer, op byte, m protowire.Marshaler) error {
+	b := []byte{hdfsproto.DataTransferVersion >> 8, hdfsproto.DataTransferVersion & 0xff, op}
+	_, err := w.Write(protowire.AppendDelimited(b, m))
+	return err
+}
+
+// ReadOp reads the version and op code of an operation, whose delimited
+// request follows.
+func ReadOp(r io.Reader) (byte, error) {
+	var b [3]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	if v := binary.BigEndian.Uint16(b[:2]); v != hdfsproto.DataTransferVersion {
+		return 0, fmt.Errorf("transfer: unsupported protocol version %d", v)
+	}
+	return b[2], nil
+}
+
+// Packet is a packet of block data and the checksums of its chunks.
+type Packet struct {
+	hdfsproto.PacketHeader
+	Sums []byte
+	Data []byte
+}
+
+// WritePacket sends p, setting its DataLen.
+func WritePacket(w io.Writer, p *Packet) error {
+	p.DataLen = int32(len(p.Data))
+	header := protowire.Marshal(&p.PacketHeader)
+	b := make([]byte, 6, 6+len(header)+len(p.Sums)+len(p.Data))
+	binary.BigEndian.PutUint32(b, uint32(4+len(p.Sums)+len(p.Data)))
+	binary.BigEndian.PutUint16(b[4:], uint16(len(header)))
+	b = append(b, header...)
+	b = append(b, p.Sums...)
+	b = append(b, p.Data...)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadPacket reads a packet.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	var b [6]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	payload := binary.BigEndian.Uint32(b[:4])
+	headerLen := int(binary.BigEndian.Uint16(b[4:]))
+	if payload < 4 || payload > maxPacketSize {
+		return nil, errMalformedPacket
+	}
+	buf := make([]byte, headerLen+int(payload)-4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	p := new(Packet)
+	if err := protowire.Unmarshal(buf[:headerLen], &p.PacketHeader); err != nil {
+		return nil, err
+	}
+	body := buf[headerLen:]
+	if p.DataLen < 0 || int(p.DataLen) > len(body) {
+		return nil, errMalformedPacket
+	}
+	split := len(body) - int(p.DataLen)
+	p.Sums, p.Data = body[:split], body[split:]
+	return p, nil
+}
@@ -0,0 +1,86 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+)
+
+func TestChecksum(t *testing.T) {
+	data := []byte("123456789")
+	for _, c := range []struct {
+		typ  uint64
+		want []byte
+	}{
+		{hdfsproto.ChecksumCRC32, []byte{0xcb, 0xf4, 0x39, 0x26}},
+		{hdfsproto.ChecksumCRC32C, []byte{0xe3, 0x06, 0x92, 0x83}},
+	} {
+		sum := Checksum{c.typ, 512}
+		if got := sum.Sum(data); !bytes.Equal(got, c.want) {
+			t.Errorf("Sum of type %d: got %x, want %x\n", c.typ, got, c.want)
+		}
+	}
+
+	sum := Checksum{hdfsproto.ChecksumCRC32C, 4}
+	sums := sum.Sum(data)
+	if len(sums) != 12 {
+		t.Fatalf("Sum of 3 chunks: got %d bytes\n", len(sums))
+	}
+	if err := sum.Verify(data, sums); err != nil {
+		t.Errorf("Error on verifying: %v\n", err)
+	}
+	bad := append([]byte(nil), data...)
+	bad[8] ^= 1
+	if err := sum.Verify(bad, sums); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Verify of corrupt data: got %v\n", err)
+	}
+	if err := sum.Verify(data, sums[:8]); err == nil {
+		t.Errorf("Verify with missing checksums: no error\n")
+	}
+	if err := (Checksum{hdfsproto.ChecksumNull, 512}).Verify(bad, nil); err != nil {
+		t.Errorf("Verify without checksums: got %v\n", err)
+	}
+	if err := (Checksum{7, 512}).Valid(); err == nil {
+		t.Errorf("Valid of an unknown type: no error\n")
+	}
+}
+
+func TestPacket(t *testing.T) {
+	sum := Checksum{hdfsproto.ChecksumCRC32C, 512}
+	data := bytes.Repeat([]byte("hdfs"), 300)
+	in := &Packet{PacketHeader: hdfsproto.PacketHeader{OffsetInBlock: 1024, Seqno: 7, SyncBlock: true}, Sums: sum.Sum(data), Data: data}
+	var buf bytes.Buffer
+	if err := WritePacket(&buf, in); err != nil {
+		t.Fatalf("Error on writing a packet: %v\n", err)
+	}
+	if err := WritePacket(&buf, &Packet{PacketHeader: hdfsproto.PacketHeader{OffsetInBlock: 2224, Seqno: 8, LastPacketInBlock: true}}); err != nil {
+		t.Fatalf("Error on writing the last packet: %v\n", err)
+	}
+	out, err := ReadPacket(&buf)
+	if err != nil {
+		t.Fatalf("Error on reading a packet: %v\n", err)
+	}
+	if out.PacketHeader != in.PacketHeader || !bytes.Equal(out.Sums, in.Sums) || !bytes.Equal(out.Data, data) {
+		t.Errorf("ReadPacket: got %+v\n", out.PacketHeader)
+	}
+	if err := sum.Verify(out.Data, out.Sums); err != nil {
+		t.Errorf("Error on verifying a packet: %v\n", err)
+	}
+	out, err = ReadPacket(&buf)
+	if err != nil || !out.LastPacketInBlock || len(out.Data) != 0 || len(out.Sums) != 0 {
+		t.Errorf("ReadPacket of the last packet: got %+v, %v\n", out, err)
+	}
+
+	buf.Reset()
+	if err := WriteOp(&buf, hdfsproto.ReadBlock, &hdfsproto.ClientReadStatus{Status: hdfsproto.StatusChecksumOK}); err != nil {
+		t.Fatalf("Error on writing an op: %v\n", err)
+	}
+	if op, err := ReadOp(&buf); op != hdfsproto.ReadBlock || err != nil {
+		t.Errorf("ReadOp: got %d, %v\n", op, err)
+	}
+	if _, err := ReadOp(bytes.NewReader([]byte{0, 27, hdfsproto.ReadBlock})); err == nil {
+		t.Errorf("ReadOp of an old version: no error\n")
+	}
+}
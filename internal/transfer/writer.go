@@ -0,0 +1,454 @@
+package transfer
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zyxar/hdfs/internal/hdfsproto"
+	"github.com/zyxar/hdfs/internal/protowire"
+)
+
+const (
+	// maxQueuedPackets bounds the packets sent and not yet acknowledged,
+	// as dfs.client.write.max-packets-in-flight does.
+	maxQueuedPackets = 80
+	// maxHeaderLen is the room left for the framing and header in a packet.
+	maxHeaderLen = 33
+	// ackGrace is how long the acknowledgements in flight are still read
+	// after a write to the pipeline fails, to learn which datanode failed.
+	ackGrace = time.Second
+	// heartbeatSeqno is the seqno of the heartbeat packets of the pipeline.
+	heartbeatSeqno = -1
+)
+
+var errWriterClosed = errors.New("transfer: block writer closed")
+
+// Recoverer is the part of ClientNamenodeProtocol a BlockWriter needs to
+// replace a pipeline after a datanode failed.
+type Recoverer interface {
+	// UpdateBlockForPipeline returns b with a new generation stamp and
+	// its block token.
+	UpdateBlockForPipeline(b *hdfsproto.ExtendedBlock) (*hdfsproto.LocatedBlock, error)
+	// UpdatePipeline commits the new generation stamp, length and
+	// datanodes of a block.
+	UpdatePipeline(old, new *hdfsproto.ExtendedBlock, nodes []*hdfsproto.DatanodeInfo) error
+}
+
+// WriterConfig configures a BlockWriter.
+type WriterConfig struct {
+	ClientName string
+	Checksum   Checksum
+	// PacketSize is the size of a packet, framing included.
+	PacketSize int
+	// Timeout bounds connecting to a datanode and waiting for it; 0 means
+	// none.
+	Timeout time.Duration
+	// Recoverer, if not nil, lets the writer go on without the datanodes
+	// that fail; otherwise the first failure is final.
+	Recoverer Recoverer
+}
+
+type packet struct {
+	hdr  hdfsproto.PacketHeader
+	sums []byte
+	data []byte
+}
+
+// BlockWriter writes a block through a pipeline of datanodes. Data is sent
+// in packets of whole chunks, whose acknowledgements are read concurrently;
+// a packet stays queued until every datanode of the pipeline acknowledged
+// it. When a datanode fails, the writer drops it from the pipeline, gets a
+// new generation stamp from the namenode, sets up the remaining datanodes
+// for recovery and sends the queued packets again.
+type BlockWriter struct {
+	cfg    WriterConfig
+	block  hdfsproto.ExtendedBlock
+	token  hdfsproto.Token
+	nodes  []*hdfsproto.DatanodeInfo
+	chunks int // chunks per packet
+
+	conn    net.Conn
+	done    chan struct{} // closed when the ack reader of conn exits
+	buf     []byte        // data not yet sent, starting at offset
+	offset  int64
+	flushed int // bytes at the start of buf already sent by Flush
+	seqno   int64
+	err     error // final failure
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*packet
+	acked  int64
+	failed error // failure of the current pipeline
+	bad    int   // index of the datanode that failed, or -1
+}
+
+// NewBlockWriter sets up the pipeline of the datanodes of b, a new block,
+// and returns a writer for it. If a datanode fails the setup, the error is
+// a *DatanodeError naming it.
+func NewBlockWriter(cfg WriterConfig, b *hdfsproto.LocatedBlock) (*BlockWriter, error) {
+	if err := cfg.Checksum.Valid(); err != nil {
+		return nil, err
+	}
+	if len(b.Locs) == 0 {
+		return nil, errors.New("transfer: no datanode to write to")
+	}
+	w := &BlockWriter{cfg: cfg, block: b.Block, token: b.BlockToken, nodes: b.Locs}
+	w.chunks = (cfg.PacketSize - maxHeaderLen) / (cfg.Checksum.BytesPerChecksum + cfg.Checksum.Size())
+	if w.chunks < 1 {
+		w.chunks = 1
+	}
+	w.block.NumBytes = 0
+	w.cond = sync.NewCond(&w.mu)
+	if _, err := w.setup(hdfsproto.StagePipelineSetupCreate, w.block.GenerationStamp); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Size returns the number of bytes written to the block.
+func (w *BlockWriter) Size() int64 {
+	return w.offset + int64(len(w.buf))
+}
+
+// setup connects to the pipeline for stage and starts reading its
+// acknowledgements. On failure it returns the index of the datanode to
+// blame.
+func (w *BlockWriter) setup(stage, gs uint64) (int, error) {
+	dn := w.nodes[0]
+	conn, err := Dial(dn, w.cfg.Timeout)
+	if err != nil {
+		return 0, &DatanodeError{dn, err}
+	}
+	if w.cfg.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(w.cfg.Timeout))
+	}
+	w.mu.Lock()
+	acked := w.acked
+	w.mu.Unlock()
+	op := &hdfsproto.OpWriteBlock{
+		Header:                hdfsproto.ClientOperationHeader{Block: w.block, Token: w.token, ClientName: w.cfg.ClientName},
+		Targets:               w.nodes[1:],
+		Stage:                 stage,
+		PipelineSize:          uint32(len(w.nodes)),
+		MinBytesRcvd:          uint64(acked),
+		MaxBytesRcvd:          uint64(w.offset),
+		LatestGenerationStamp: gs,
+		RequestedChecksum:     w.cfg.Checksum.Proto(),
+	}
+	r := bufio.NewReader(conn)
+	var resp hdfsproto.BlockOpResponse
+	err = WriteOp(conn, hdfsproto.WriteBlock, op)
+	if err == nil {
+		err = protowire.ReadDelimited(r, &resp)
+	}
+	if err != nil {
+		conn.Close()
+		return 0, &DatanodeError{dn, err}
+	}
+	if resp.Status != hdfsproto.StatusSuccess {
+		conn.Close()
+		bad := 0
+		for i, n := range w.nodes {
+			if resp.FirstBadLink != "" && n.XferAddr() == resp.FirstBadLink {
+				bad = i
+			}
+		}
+		return bad, &DatanodeError{w.nodes[bad], statusError(resp.Status, resp.Message)}
+	}
+	conn.SetDeadline(time.Time{})
+	w.conn = conn
+	w.done = make(chan struct{})
+	w.mu.Lock()
+	w.failed, w.bad = nil, -1
+	w.mu.Unlock()
+	go w.readAcks(conn, r, w.done)
+	return -1, nil
+}
+
+// fail records the failure of the pipeline, blaming the datanode at index
+// bad, unless a failure was already recorded.
+func (w *BlockWriter) fail(bad int, err error) {
+	w.mu.Lock()
+	if w.failed == nil {
+		w.failed, w.bad = err, bad
+	}
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+func (w *BlockWriter) readAcks(conn net.Conn, r *bufio.Reader, done chan struct{}) {
+	defer close(done)
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.failed == nil {
+			w.cond.Wait()
+		}
+		failed := w.failed != nil
+		w.mu.Unlock()
+		if failed {
+			return
+		}
+		if w.cfg.Timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(w.cfg.Timeout))
+		}
+		var ack hdfsproto.PipelineAck
+		if err := protowire.ReadDelimited(r, &ack); err != nil {
+			w.fail(-1, err)
+			return
+		}
+		if ack.Seqno == heartbeatSeqno {
+			continue
+		}
+		for i, status := range ack.Reply {
+			if status != hdfsproto.StatusSuccess {
+				w.fail(i, statusError(status, "bad ack"))
+				return
+			}
+		}
+		w.mu.Lock()
+		if len(w.queue) == 0 || w.queue[0].hdr.Seqno != ack.Seqno {
+			w.mu.Unlock()
+			w.fail(-1, errors.New("transfer: unexpected ack"))
+			return
+		}
+		p := w.queue[0]
+		w.queue = w.queue[1:]
+		if end := p.hdr.OffsetInBlock + int64(len(p.data)); end > w.acked {
+			w.acked = end
+		}
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// writeFailed stops the pipeline after a failed write, leaving the ack
+// reader a moment to learn which datanode failed.
+func (w *BlockWriter) writeFailed(err error) {
+	w.conn.SetReadDeadline(time.Now().Add(ackGrace))
+	<-w.done
+	w.fail(-1, err)
+}
+
+func (w *BlockWriter) writePacket(p *packet) error {
+	if w.cfg.Timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.cfg.Timeout))
+	}
+	return WritePacket(w.conn, &Packet{PacketHeader: p.hdr, Sums: p.sums, Data: p.data})
+}
+
+// send queues p and sends it, recovering the pipeline if needed.
+func (w *BlockWriter) send(p *packet) error {
+	w.mu.Lock()
+	for len(w.queue) >= maxQueuedPackets && w.failed == nil {
+		w.cond.Wait()
+	}
+	w.queue = append(w.queue, p)
+	w.cond.Broadcast()
+	failed := w.failed != nil
+	w.mu.Unlock()
+	if !failed {
+		err := w.writePacket(p)
+		if err == nil {
+			return nil
+		}
+		w.writeFailed(err)
+	}
+	return w.recover()
+}
+
+// recover replaces the failed pipeline with one without the datanode to
+// blame and sends the queued packets again.
+func (w *BlockWriter) recover() error {
+	for {
+		<-w.done
+		w.conn.Close()
+		w.mu.Lock()
+		bad, cause := w.bad, w.failed
+		last := len(w.queue) > 0 && w.queue[len(w.queue)-1].hdr.LastPacketInBlock
+		acked := w.acked
+		w.mu.Unlock()
+		if bad < 0 || bad >= len(w.nodes) {
+			bad = 0
+		}
+		if w.cfg.Recoverer == nil || len(w.nodes) == 1 {
+			w.err = &DatanodeError{w.nodes[bad], cause}
+			return w.err
+		}
+		w.nodes = append(append([]*hdfsproto.DatanodeInfo(nil), w.nodes[:bad]...), w.nodes[bad+1:]...)
+		lb, err := w.cfg.Recoverer.UpdateBlockForPipeline(&w.block)
+		if err != nil {
+			w.err = err
+			return err
+		}
+		w.token = lb.BlockToken
+		stage := uint64(hdfsproto.StagePipelineSetupStreamingRecovery)
+		if last {
+			stage = hdfsproto.StagePipelineCloseRecovery
+		}
+		gs := lb.Block.GenerationStamp
+		if bad, err := w.setup(stage, gs); err != nil {
+			w.fail(bad, err)
+			continue
+		}
+		old := w.block
+		w.block.GenerationStamp, w.block.NumBytes = gs, uint64(acked)
+		if err := w.cfg.Recoverer.UpdatePipeline(&old, &w.block, w.nodes); err != nil {
+			w.fail(-1, err)
+			<-w.done
+			w.conn.Close()
+			w.err = err
+			return err
+		}
+		w.mu.Lock()
+		queue := append([]*packet(nil), w.queue...)
+		w.mu.Unlock()
+		var werr error
+		for _, p := range queue {
+			if werr = w.writePacket(p); werr != nil {
+				break
+			}
+		}
+		if werr == nil {
+			return nil
+		}
+		w.writeFailed(werr)
+	}
+}
+
+// sendBuf sends the buffered data as a packet, the last of the block or
+// one asking the datanodes to sync the block to disk as set.
+func (w *BlockWriter) sendBuf(last, sync bool) error {
+	p := &packet{
+		hdr:  hdfsproto.PacketHeader{OffsetInBlock: w.offset, Seqno: w.seqno, LastPacketInBlock: last, SyncBlock: sync},
+		sums: w.cfg.Checksum.Sum(w.buf),
+		data: w.buf,
+	}
+	w.seqno++
+	w.offset += int64(len(w.buf))
+	w.buf, w.flushed = nil, 0
+	return w.send(p)
+}
+
+// Write writes p to the block.
+func (w *BlockWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	size := w.chunks * w.cfg.Checksum.BytesPerChecksum
+	n := 0
+	for len(p) > 0 {
+		m := size - len(w.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		w.buf = append(w.buf, p[:m]...)
+		n, p = n+m, p[m:]
+		if len(w.buf) == size {
+			if err := w.sendBuf(false, false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// wait waits until every queued packet is acknowledged.
+func (w *BlockWriter) wait() error {
+	for {
+		w.mu.Lock()
+		for len(w.queue) > 0 && w.failed == nil {
+			w.cond.Wait()
+		}
+		failed := w.failed != nil
+		w.mu.Unlock()
+		if !failed {
+			return nil
+		}
+		if err := w.recover(); err != nil {
+			return err
+		}
+	}
+}
+
+// Flush sends the data written so far and waits until every datanode of
+// the pipeline acknowledged it, making it visible to new readers. A partial
+// last chunk is sent again, completed, with the next packet.
+func (w *BlockWriter) Flush() error {
+	return w.flush(false)
+}
+
+// Sync is Flush, also having every datanode of the pipeline persist the
+// block to disk before acknowledging it; with nothing new to send, an empty
+// packet carries the request.
+func (w *BlockWriter) Sync() error {
+	return w.flush(true)
+}
+
+func (w *BlockWriter) flush(sync bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	switch {
+	case len(w.buf) > w.flushed:
+		tail := len(w.buf) % w.cfg.Checksum.BytesPerChecksum
+		keep := append([]byte(nil), w.buf[len(w.buf)-tail:]...)
+		if err := w.sendBuf(false, sync); err != nil {
+			return err
+		}
+		if tail > 0 {
+			w.buf, w.flushed = keep, tail
+			w.offset -= int64(tail)
+		}
+	case sync:
+		p := &packet{hdr: hdfsproto.PacketHeader{OffsetInBlock: w.offset + int64(len(w.buf)), Seqno: w.seqno, SyncBlock: true}}
+		w.seqno++
+		if err := w.send(p); err != nil {
+			return err
+		}
+	}
+	return w.wait()
+}
+
+// Close sends the rest of the data and the last, empty packet of the block,
+// waits for their acknowledgements and returns the block written.
+func (w *BlockWriter) Close() (*hdfsproto.ExtendedBlock, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if len(w.buf) > w.flushed {
+		if err := w.sendBuf(false, false); err != nil {
+			return nil, err
+		}
+	} else {
+		w.offset += int64(len(w.buf))
+		w.buf, w.flushed = nil, 0
+	}
+	if err := w.sendBuf(true, false); err != nil {
+		return nil, err
+	}
+	if err := w.wait(); err != nil {
+		return nil, err
+	}
+	w.fail(-1, errWriterClosed)
+	<-w.done
+	w.conn.Close()
+	w.err = errWriterClosed
+	b := w.block
+	b.NumBytes = uint64(w.offset)
+	return &b, nil
+}
+
+// Abort closes the pipeline without ending the block.
+func (w *BlockWriter) Abort() {
+	if w.err != nil {
+		return
+	}
+	w.fail(-1, errWriterClosed)
+	w.conn.Close()
+	<-w.done
+	w.err = errWriterClosed
+}
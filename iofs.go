@@ -0,0 +1,279 @@
+package hdfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+)
+
+// FS returns an io/fs view of fsys rooted at "/", so that fs.WalkDir,
+// fs.Glob, fs.Sub, http.FS and template.ParseFS work on HDFS or any other
+// FileSystem. Names follow the io/fs conventions: they are unrooted and
+// slash-separated, "a/b" meaning "/a/b". Files are opened read-only. The returned file system also implements
+// fs.ReadDirFS, fs.StatFS, fs.GlobFS and fs.SubFS.
+func FS(fsys FileSystem) fs.FS {
+	return &ioFS{fsys, "/"}
+}
+
+type ioFS struct {
+	fsys FileSystem
+	root string
+}
+
+var (
+	_ fs.ReadDirFS = (*ioFS)(nil)
+	_ fs.StatFS    = (*ioFS)(nil)
+	_ fs.GlobFS    = (*ioFS)(nil)
+	_ fs.SubFS     = (*ioFS)(nil)
+)
+
+func (f *ioFS) join(name string) string {
+	return path.Join(f.root, name)
+}
+
+// pathError rewrites err into an *fs.PathError naming the io/fs name
+// rather than the absolute HDFS path.
+func (f *ioFS) pathError(op, name string, err error) error {
+	var perr *PathError
+	if errors.As(err, &perr) {
+		err = perr.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *ioFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fsys.GetPathInfo(f.join(name))
+	if err != nil {
+		return nil, f.pathError("open", name, err)
+	}
+	if info.IsDir() {
+		return &ioDir{fsys: f, name: name, info: info}, nil
+	}
+	file, err := f.fsys.Open(f.join(name), O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return nil, f.pathError("open", name, err)
+	}
+	return &ioFile{file, info}, nil
+}
+
+func (f *ioFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fsys.GetPathInfo(f.join(name))
+	if err != nil {
+		return nil, f.pathError("stat", name, err)
+	}
+	return info.Info(), nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (f *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := f.fsys.ListDirectory(f.join(name))
+	if err != nil {
+		return nil, f.pathError("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info.Info())
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Glob expands pattern, listing only the directories its meta characters
+// range over. As with fs.Glob, I/O errors are ignored.
+func (f *ioFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := f.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	dir, file := path.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return f.glob(dir, file, nil), nil
+	}
+	if dir == pattern {
+		return nil, path.ErrBadPattern
+	}
+	dirs, err := f.Glob(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = f.glob(d, file, matches)
+	}
+	return matches, nil
+}
+
+func (f *ioFS) glob(dir, pattern string, matches []string) []string {
+	entries, err := f.ReadDir(dir)
+	if err != nil {
+		return matches
+	}
+	for _, e := range entries {
+		if ok, _ := path.Match(pattern, e.Name()); ok {
+			matches = append(matches, path.Join(dir, e.Name()))
+		}
+	}
+	return matches
+}
+
+func (f *ioFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return f, nil
+	}
+	return &ioFS{f.fsys, f.join(dir)}, nil
+}
+
+func hasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
+
+func cleanGlobPath(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	default:
+		return dir[:len(dir)-1]
+	}
+}
+
+// ioFile is a regular file opened through FS.
+type ioFile struct {
+	FileHandle
+	info *FileInfo
+}
+
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	return f.info.Info(), nil
+}
+
+// ioDir is a directory opened through FS; its entries are listed on the
+// first call to ReadDir.
+type ioDir struct {
+	fsys    *ioFS
+	name    string
+	info    *FileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return d.info.Info(), nil
+}
+
+func (d *ioDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *ioDir) Close() error {
+	return nil
+}
+
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// IsDir reports whether info describes a directory.
+func (info *FileInfo) IsDir() bool {
+	return info.Kind == 'D'
+}
+
+// Mode returns the file mode bits derived from Kind and Permissions.
+func (info *FileInfo) Mode() fs.FileMode {
+	mode := fs.FileMode(info.Permissions) & fs.ModePerm
+	if info.Permissions&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	if info.IsDir() {
+		mode |= fs.ModeDir
+	}
+	return mode
+}
+
+// ModTime returns LastMod.
+func (info *FileInfo) ModTime() time.Time {
+	return info.LastMod
+}
+
+// Sys returns info itself.
+func (info *FileInfo) Sys() interface{} {
+	return info
+}
+
+// Info returns info as an fs.FileInfo. FileInfo cannot implement the
+// interface itself, as its Name and Size fields would clash with the
+// methods; the adapter's Name is the base name of the path rather than
+// the full URI held in the Name field, and its Sys returns info.
+func (info *FileInfo) Info() fs.FileInfo {
+	return fileInfo{info}
+}
+
+type fileInfo struct {
+	info *FileInfo
+}
+
+func (fi fileInfo) Name() string {
+	return baseName(fi.info.Name)
+}
+
+// baseName returns the last element of the path of name, which is usually a
+// full URI such as hdfs://host:port/dir/file.
+func baseName(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	return path.Base(name)
+}
+
+func (fi fileInfo) Size() int64        { return fi.info.Size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.info.Mode() }
+func (fi fileInfo) ModTime() time.Time { return fi.info.LastMod }
+func (fi fileInfo) IsDir() bool        { return fi.info.IsDir() }
+func (fi fileInfo) Sys() interface{}   { return fi.info }
@@ -0,0 +1,31 @@
+package hdfs
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestFileInfoMode(t *testing.T) {
+	tests := []struct {
+		info FileInfo
+		name string
+		mode fs.FileMode
+	}{
+		{FileInfo{Kind: 'F', Name: "hdfs://brick5:34310/tmp/a.txt", Permissions: 0644}, "a.txt", 0644},
+		{FileInfo{Kind: 'D', Name: "hdfs://brick5:34310/tmp", Permissions: 01777}, "tmp", fs.ModeDir | fs.ModeSticky | 0777},
+		{FileInfo{Kind: 'D', Name: "hdfs://brick5:34310/", Permissions: 0755}, "/", fs.ModeDir | 0755},
+		{FileInfo{Kind: 'F', Name: "file:/tmp/local", Permissions: 0600}, "local", 0600},
+	}
+	for _, tt := range tests {
+		fi := tt.info.Info()
+		if fi.Name() != tt.name {
+			t.Errorf("Name of %s: got %q, want %q\n", tt.info.Name, fi.Name(), tt.name)
+		}
+		if fi.Mode() != tt.mode {
+			t.Errorf("Mode of %s: got %v, want %v\n", tt.info.Name, fi.Mode(), tt.mode)
+		}
+		if fi.IsDir() != (tt.info.Kind == 'D') || fi.Sys() != &tt.info {
+			t.Errorf("Unexpected IsDir or Sys for %s\n", tt.info.Name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package s3gw
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var errChunked = errors.New("s3gw: malformed aws-chunked body")
+
+// chunkedReader decodes the aws-chunked content encoding of streaming
+// uploads: chunks of "<hex size>[;chunk-signature=<sig>]\r\n<data>\r\n",
+// the last one empty and followed by optional trailing headers and an
+// empty line. Signatures and trailing checksums are not verified.
+type chunkedReader struct {
+	r       *bufio.Reader
+	n       int64 // bytes left in the current chunk
+	started bool
+	err     error
+}
+
+func newChunkedReader(r io.Reader) *chunkedReader {
+	return &chunkedReader{r: bufio.NewReader(r)}
+}
+
+func (c *chunkedReader) line() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+// next starts the next chunk.
+func (c *chunkedReader) next() error {
+	if c.started {
+		if line, err := c.line(); err != nil {
+			return err
+		} else if line != "" {
+			return errChunked
+		}
+	}
+	c.started = true
+	line, err := c.line()
+	if err != nil {
+		return err
+	}
+	size, _, _ := strings.Cut(line, ";")
+	n, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+	if err != nil || n < 0 {
+		return errChunked
+	}
+	if n > 0 {
+		c.n = n
+		return nil
+	}
+	for {
+		line, err := c.line()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return io.EOF
+		}
+	}
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.n == 0 {
+		if c.err = c.next(); c.err != nil {
+			return 0, c.err
+		}
+	}
+	if int64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	c.err = err
+	return n, err
+}
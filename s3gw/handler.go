@@ -0,0 +1,352 @@
+// Package s3gw serves directories of an hdfs.FileSystem as the buckets of an
+// S3-compatible object store, for tools that only speak S3.
+//
+// Object keys map to paths below the directory of their bucket, "/"
+// separating directories. Objects are written to a staging directory,
+// .s3gw, in the bucket directory and renamed into place once complete, so
+// readers never see partial objects; parts of multipart uploads are staged
+// there as well. Requests use path-style addressing, such as GET
+// /bucket/key, and are not authenticated: signatures are ignored and every
+// request runs as the user of the FileSystem.
+package s3gw
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zyxar/hdfs"
+)
+
+const (
+	// stagingDir holds, in a bucket directory, the objects being written.
+	stagingDir = ".s3gw"
+	// defaultMinPartSize is the smallest part but the last that S3 accepts
+	// in a multipart upload.
+	defaultMinPartSize = 5 << 20
+	// maxKeyLength is the longest key S3 accepts, in bytes.
+	maxKeyLength = 1024
+	// timeFormat is the format of times in XML bodies.
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// Handler serves the buckets of an S3 API from the directories of a
+// FileSystem.
+type Handler struct {
+	// MinPartSize is the smallest size of the parts of a multipart upload
+	// but the last one, 5MB if 0, as on S3.
+	MinPartSize int64
+
+	fs      hdfs.FileSystem
+	buckets map[string]string
+	reqID   uint64
+}
+
+// NewHandler returns a Handler serving fsys, with buckets mapping bucket
+// names to absolute directories. A bucket exists as long as its directory
+// does.
+func NewHandler(fsys hdfs.FileSystem, buckets map[string]string) *Handler {
+	h := &Handler{fs: fsys, buckets: make(map[string]string, len(buckets))}
+	for name, dir := range buckets {
+		h.buckets[name] = path.Clean("/" + dir)
+	}
+	return h
+}
+
+// s3Error is a failure reported with an S3 error code.
+type s3Error struct {
+	code    string
+	status  int
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+var (
+	errNoSuchBucket     = &s3Error{"NoSuchBucket", http.StatusNotFound, "The specified bucket does not exist."}
+	errNoSuchKey        = &s3Error{"NoSuchKey", http.StatusNotFound, "The specified key does not exist."}
+	errNoSuchUpload     = &s3Error{"NoSuchUpload", http.StatusNotFound, "The specified multipart upload does not exist."}
+	errInvalidRange     = &s3Error{"InvalidRange", http.StatusRequestedRangeNotSatisfiable, "The requested range is not satisfiable."}
+	errNotImplemented   = &s3Error{"NotImplemented", http.StatusNotImplemented, "A header or query you provided implies functionality that is not implemented."}
+	errMethodNotAllowed = &s3Error{"MethodNotAllowed", http.StatusMethodNotAllowed, "The specified method is not allowed against this resource."}
+	errBadDigest        = &s3Error{"BadDigest", http.StatusBadRequest, "The Content-MD5 you specified did not match what we received."}
+	errSHA256Mismatch   = &s3Error{"XAmzContentSHA256Mismatch", http.StatusBadRequest, "The provided 'x-amz-content-sha256' header does not match what was computed."}
+	errIncompleteBody   = &s3Error{"IncompleteBody", http.StatusBadRequest, "You did not provide the number of bytes specified by the Content-Length HTTP header."}
+	errMalformedXML     = &s3Error{"MalformedXML", http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema."}
+	errInvalidPart      = &s3Error{"InvalidPart", http.StatusBadRequest, "One or more of the specified parts could not be found or its entity tag did not match."}
+	errInvalidPartOrder = &s3Error{"InvalidPartOrder", http.StatusBadRequest, "The list of parts was not in ascending order."}
+	errEntityTooSmall   = &s3Error{"EntityTooSmall", http.StatusBadRequest, "Your proposed upload is smaller than the minimum allowed object size."}
+	errIsDirectory      = &s3Error{"ObjectExistsAsDirectory", http.StatusConflict, "The key is a prefix of other objects."}
+	errParentIsObject   = &s3Error{"ParentIsObject", http.StatusConflict, "A prefix of the key is an object."}
+)
+
+func invalidArgument(format string, args ...interface{}) error {
+	return &s3Error{"InvalidArgument", http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// request is a request on an object, or on a bucket if key is "".
+type request struct {
+	h      *Handler
+	w      http.ResponseWriter
+	r      *http.Request
+	q      url.Values
+	bucket string
+	dir    string // directory of the bucket
+	key    string
+	path   string // path of the object
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &request{h: h, w: w, r: r, q: r.URL.Query()}
+	w.Header().Set("x-amz-request-id", fmt.Sprintf("%016X", atomic.AddUint64(&h.reqID, 1)))
+	req.bucket, req.key, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	var err error
+	if req.bucket == "" {
+		err = errMethodNotAllowed
+		if r.Method == http.MethodGet {
+			err = req.listBuckets()
+		}
+	} else {
+		err = req.route()
+	}
+	switch {
+	case err == nil:
+	case req.key == "":
+		req.writeError(err, errNoSuchBucket)
+	default:
+		req.writeError(err, errNoSuchKey)
+	}
+}
+
+// listParams are the parameters of ListObjects and ListObjectsV2.
+var listParams = map[string]bool{
+	"list-type": true, "prefix": true, "delimiter": true, "max-keys": true, "encoding-type": true,
+	"marker": true, "continuation-token": true, "start-after": true, "fetch-owner": true,
+}
+
+// route serves a request on a bucket or an object.
+func (req *request) route() error {
+	dir, ok := req.h.buckets[req.bucket]
+	if !ok {
+		return errNoSuchBucket
+	}
+	req.dir = dir
+	q, method := req.q, req.r.Method
+	if req.key == "" {
+		switch method {
+		case http.MethodHead:
+			return req.headBucket()
+		case http.MethodGet:
+			if q.Has("location") {
+				return req.getBucketLocation()
+			}
+			for name := range q {
+				if !listParams[name] {
+					return errNotImplemented
+				}
+			}
+			return req.listObjects()
+		}
+		return errNotImplemented
+	}
+	if err := req.checkKey(); err != nil {
+		return err
+	}
+	req.path = path.Join(req.dir, req.key)
+	switch method {
+	case http.MethodGet:
+		if q.Has("uploadId") {
+			return req.listParts()
+		}
+		return req.getObject(false)
+	case http.MethodHead:
+		return req.getObject(true)
+	case http.MethodPut:
+		if req.r.Header.Get("x-amz-copy-source") != "" {
+			return errNotImplemented
+		}
+		if q.Has("uploadId") {
+			return req.uploadPart()
+		}
+		return req.putObject()
+	case http.MethodPost:
+		if q.Has("uploads") {
+			return req.createMultipartUpload()
+		}
+		if q.Has("uploadId") {
+			return req.completeMultipartUpload()
+		}
+	case http.MethodDelete:
+		if q.Has("uploadId") {
+			return req.abortMultipartUpload()
+		}
+		return req.deleteObject()
+	}
+	return errMethodNotAllowed
+}
+
+// checkKey rejects keys that do not map to a path below the bucket
+// directory: empty, "." or ".." path elements, and the staging directory.
+// A trailing "/" marks a directory.
+func (req *request) checkKey() error {
+	if len(req.key) > maxKeyLength {
+		return &s3Error{"KeyTooLongError", http.StatusBadRequest, "Your key is too long."}
+	}
+	if !utf8.ValidString(req.key) {
+		return invalidArgument("The key is not valid UTF-8.")
+	}
+	elems := strings.Split(strings.TrimSuffix(req.key, "/"), "/")
+	for _, elem := range elems {
+		if elem == "" || elem == "." || elem == ".." {
+			return invalidArgument("The key %q has an empty, \".\" or \"..\" path element.", req.key)
+		}
+	}
+	if elems[0] == stagingDir {
+		return invalidArgument("The key %q is reserved.", req.key)
+	}
+	return nil
+}
+
+// writeError reports err with its S3 error code; missing paths are
+// reported as notFound.
+func (req *request) writeError(err error, notFound *s3Error) {
+	var e *s3Error
+	switch {
+	case errors.As(err, &e):
+	case errors.Is(err, syscall.ENOENT):
+		e = notFound
+	case errors.Is(err, syscall.EACCES):
+		e = &s3Error{"AccessDenied", http.StatusForbidden, "Access Denied"}
+	case errors.Is(err, hdfs.ErrUnsupported):
+		e = errNotImplemented
+	default:
+		e = &s3Error{"InternalError", http.StatusInternalServerError, err.Error()}
+	}
+	if e == errInvalidRange {
+		if info, err := req.h.fs.GetPathInfo(req.path); err == nil {
+			req.w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		}
+	}
+	if req.r.Method == http.MethodHead {
+		req.w.WriteHeader(e.status)
+		return
+	}
+	req.writeXML(e.status, &errorResponse{Code: e.code, Message: e.message, Resource: req.r.URL.Path, RequestId: req.w.Header().Get("x-amz-request-id")})
+}
+
+func (req *request) writeXML(status int, v interface{}) {
+	req.w.Header().Set("Content-Type", "application/xml")
+	req.w.WriteHeader(status)
+	req.w.Write([]byte(xml.Header))
+	xml.NewEncoder(req.w).Encode(v)
+}
+
+func (req *request) listBuckets() error {
+	var resp listAllMyBucketsResult
+	for name, dir := range req.h.buckets {
+		info, err := req.h.fs.GetPathInfo(dir)
+		if err != nil || info.Kind != 'D' {
+			continue
+		}
+		resp.Buckets = append(resp.Buckets, bucketEntry{name, info.LastMod.UTC().Format(timeFormat)})
+		resp.Owner = owner{info.Owner, info.Owner}
+	}
+	sort.Slice(resp.Buckets, func(i, j int) bool { return resp.Buckets[i].Name < resp.Buckets[j].Name })
+	req.writeXML(http.StatusOK, &resp)
+	return nil
+}
+
+// checkBucket returns errNoSuchBucket unless the bucket directory exists.
+func (req *request) checkBucket() error {
+	info, err := req.h.fs.GetPathInfo(req.dir)
+	if errors.Is(err, syscall.ENOENT) || err == nil && info.Kind != 'D' {
+		return errNoSuchBucket
+	}
+	return err
+}
+
+func (req *request) headBucket() error {
+	if err := req.checkBucket(); err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (req *request) getBucketLocation() error {
+	if err := req.checkBucket(); err != nil {
+		return err
+	}
+	req.writeXML(http.StatusOK, &locationConstraint{})
+	return nil
+}
+
+// staging returns a new path in the staging directory of the bucket.
+func (req *request) staging(elem ...string) string {
+	return path.Join(append([]string{req.dir, stagingDir}, elem...)...)
+}
+
+// randomID returns 16 random bytes in hex.
+func randomID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// etagXAttr is the extended attribute keeping the entity tag of an object,
+// with the size and modification time of the file it was computed for.
+const etagXAttr = "user.s3gw.etag"
+
+// keepETag stores tag in the etagXAttr of the staged object tmp, on
+// FileSystems that are an XAttrer. Failing to is not an error: etag derives
+// a tag then.
+func keepETag(fsys hdfs.FileSystem, tmp, tag string) {
+	x, ok := fsys.(hdfs.XAttrer)
+	if !ok {
+		return
+	}
+	info, err := fsys.GetPathInfo(tmp)
+	if err != nil {
+		return
+	}
+	x.SetXAttr(tmp, etagXAttr, []byte(fmt.Sprintf("%s %d %d", tag, info.Size, info.LastMod.UnixNano())), 0)
+}
+
+// etag returns the entity tag of the object stored at p: the one kept when
+// it was placed, unless the file changed since. Other objects, such as
+// files written without the gateway, get a tag derived from the size and
+// modification time, with the "-1" suffix of multipart uploads for clients
+// not to take it for an MD5.
+func etag(fsys hdfs.FileSystem, p string, info *hdfs.FileInfo) string {
+	if x, ok := fsys.(hdfs.XAttrer); ok {
+		if v, err := x.GetXAttr(p, etagXAttr); err == nil {
+			var tag string
+			var size, mtime int64
+			if _, err := fmt.Sscanf(string(v), "%s %d %d", &tag, &size, &mtime); err == nil && size == info.Size && mtime == info.LastMod.UnixNano() {
+				return tag
+			}
+		}
+	}
+	return fmt.Sprintf("\"%016x%016x-1\"", info.Size, info.LastMod.UnixNano())
+}
+
+func lastModified(info *hdfs.FileInfo) string {
+	return info.LastMod.UTC().Format(http.TimeFormat)
+}
+
+func xmlTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
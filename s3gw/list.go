@@ -0,0 +1,233 @@
+package s3gw
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// maxListKeys is the largest number of keys of a listing, as on S3.
+const maxListKeys = 1000
+
+// errListFull stops a walk once a listing holds max-keys entries and
+// another one is found.
+var errListFull = errors.New("s3gw: listing full")
+
+// lister collects the keys, and the common prefixes of keys, of a listing in
+// the lexicographic order of S3, walking the bucket directory in depth-first
+// order with the entries of every directory sorted by key, a subdirectory
+// sorting as its name followed by "/". Subtrees outside the prefix or
+// before the marker are not walked, nor are those rolled up into a common
+// prefix.
+type lister struct {
+	fs         hdfs.FileSystem
+	prefix     string
+	delimiter  string
+	marker     string // keys and common prefixes up to it are skipped
+	max        int
+	fetchOwner bool
+
+	contents  []objectEntry
+	prefixes  []commonPrefix
+	last      string // last key or common prefix listed
+	truncated bool
+}
+
+// entry is a directory entry of a bucket.
+type entry struct {
+	name string
+	key  string
+	path string
+	info *hdfs.FileInfo
+}
+
+func (l *lister) full() bool {
+	if len(l.contents)+len(l.prefixes) < l.max {
+		return false
+	}
+	l.truncated = true
+	return true
+}
+
+func (l *lister) addKey(e entry) error {
+	if l.full() {
+		return errListFull
+	}
+	obj := objectEntry{Key: e.key, LastModified: xmlTime(e.info.LastMod), ETag: etag(l.fs, e.path, e.info), Size: e.info.Size, StorageClass: "STANDARD"}
+	if e.info.Kind == 'D' {
+		obj.ETag, obj.Size = emptyETag, 0
+	}
+	if l.fetchOwner {
+		obj.Owner = &owner{e.info.Owner, e.info.Owner}
+	}
+	l.contents = append(l.contents, obj)
+	l.last = e.key
+	return nil
+}
+
+func (l *lister) addPrefix(cp string) error {
+	if cp == l.last || cp <= l.marker {
+		return nil
+	}
+	if l.full() {
+		return errListFull
+	}
+	l.prefixes = append(l.prefixes, commonPrefix{cp})
+	l.last = cp
+	return nil
+}
+
+// commonPrefix returns the common prefix key rolls up into, "" if none.
+func (l *lister) commonPrefix(key string) string {
+	if l.delimiter == "" || !strings.HasPrefix(key, l.prefix) {
+		return ""
+	}
+	i := strings.Index(key[len(l.prefix):], l.delimiter)
+	if i < 0 {
+		return ""
+	}
+	return key[:len(l.prefix)+i+len(l.delimiter)]
+}
+
+// walk lists the directory dir holding the keys starting with keyPrefix,
+// reporting whether it is empty.
+func (l *lister) walk(dir, keyPrefix string) (empty bool, err error) {
+	infos, err := l.fs.ListDirectory(dir)
+	if err != nil {
+		return false, err
+	}
+	entries := make([]entry, 0, len(infos))
+	for _, info := range infos {
+		name := path.Base(info.Name)
+		if keyPrefix == "" && name == stagingDir {
+			continue
+		}
+		key := keyPrefix + name
+		if info.Kind == 'D' {
+			key += "/"
+		}
+		entries = append(entries, entry{name, key, path.Join(dir, name), info})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	for _, e := range entries {
+		isDir := e.info.Kind == 'D'
+		if !strings.HasPrefix(e.key, l.prefix) && !(isDir && strings.HasPrefix(l.prefix, e.key)) {
+			continue
+		}
+		if cp := l.commonPrefix(e.key); cp != "" && (!isDir || strings.HasPrefix(e.key, cp)) {
+			if err := l.addPrefix(cp); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if e.key <= l.marker && !(isDir && strings.HasPrefix(l.marker, e.key)) {
+			continue
+		}
+		if !isDir {
+			if err := l.addKey(e); err != nil {
+				return false, err
+			}
+			continue
+		}
+		empty, err := l.walk(path.Join(dir, e.name), e.key)
+		if errors.Is(err, syscall.ENOENT) {
+			continue // removed meanwhile
+		}
+		if err != nil {
+			return false, err
+		}
+		if empty && strings.HasPrefix(e.key, l.prefix) && e.key > l.marker {
+			if err := l.addKey(e); err != nil {
+				return false, err
+			}
+		}
+	}
+	return len(infos) == 0, nil
+}
+
+// listObjects serves ListObjects, and ListObjectsV2 with list-type=2.
+func (req *request) listObjects() error {
+	q := req.q
+	v2 := q.Get("list-type") == "2"
+	max := maxListKeys
+	if s := q.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgument("Invalid max-keys %q.", s)
+		}
+		if n < max {
+			max = n
+		}
+	}
+	encoding := q.Get("encoding-type")
+	if encoding != "" && encoding != "url" {
+		return invalidArgument("Invalid encoding-type %q.", encoding)
+	}
+	l := &lister{fs: req.h.fs, prefix: q.Get("prefix"), delimiter: q.Get("delimiter"), max: max}
+	resp := &listBucketResult{Name: req.bucket, MaxKeys: max, EncodingType: encoding}
+	if v2 {
+		l.fetchOwner = q.Get("fetch-owner") == "true"
+		l.marker = q.Get("start-after")
+		resp.StartAfter = l.marker
+		if token := q.Get("continuation-token"); token != "" {
+			b, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return invalidArgument("The continuation token provided is incorrect.")
+			}
+			l.marker = string(b)
+			resp.ContinuationToken = token
+		}
+	} else {
+		l.marker = q.Get("marker")
+		resp.Marker = &l.marker
+	}
+	if err := req.checkBucket(); err != nil {
+		return err
+	}
+	if max > 0 {
+		if _, err := l.walk(req.dir, ""); err != nil && err != errListFull {
+			if errors.Is(err, syscall.ENOENT) {
+				return errNoSuchBucket
+			}
+			return err
+		}
+	} else {
+		l.truncated = true
+	}
+	resp.IsTruncated = l.truncated
+	resp.Contents, resp.CommonPrefixes = l.contents, l.prefixes
+	if v2 {
+		count := len(l.contents) + len(l.prefixes)
+		resp.KeyCount = &count
+		if l.truncated && l.last != "" {
+			resp.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(l.last))
+		}
+	} else if l.truncated && l.delimiter != "" {
+		resp.NextMarker = l.last
+	}
+	resp.Prefix, resp.Delimiter = l.prefix, l.delimiter
+	if encoding == "url" {
+		resp.Prefix, resp.Delimiter = url.QueryEscape(resp.Prefix), url.QueryEscape(resp.Delimiter)
+		resp.StartAfter, resp.NextMarker = url.QueryEscape(resp.StartAfter), url.QueryEscape(resp.NextMarker)
+		if resp.Marker != nil {
+			marker := url.QueryEscape(*resp.Marker)
+			resp.Marker = &marker
+		}
+		for i := range resp.Contents {
+			resp.Contents[i].Key = url.QueryEscape(resp.Contents[i].Key)
+		}
+		for i := range resp.CommonPrefixes {
+			resp.CommonPrefixes[i].Prefix = url.QueryEscape(resp.CommonPrefixes[i].Prefix)
+		}
+	}
+	req.writeXML(http.StatusOK, resp)
+	return nil
+}
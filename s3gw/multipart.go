@@ -0,0 +1,305 @@
+package s3gw
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+const (
+	// maxPartNumber is the largest part number of a multipart upload.
+	maxPartNumber = 10000
+	// maxCompleteBody bounds the body of CompleteMultipartUpload.
+	maxCompleteBody = 1 << 20
+	// keyFile holds, in the directory of an upload, the key of the object.
+	keyFile = "key"
+)
+
+// Multipart uploads live in staging("uploads", id), holding the key of the
+// object in keyFile and part n in a file named after n and the MD5 of the
+// part, such as "00001.<md5 hex>", so that listing the directory lists the
+// parts in order along with their entity tags.
+
+// upload returns the directory of the upload named by the uploadId
+// parameter, or errNoSuchUpload if the upload is not one of the key.
+func (req *request) upload() (string, error) {
+	id := req.q.Get("uploadId")
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return "", errNoSuchUpload
+	}
+	dir := req.staging("uploads", id)
+	key, err := req.readFile(path.Join(dir, keyFile))
+	if errors.Is(err, syscall.ENOENT) || err == nil && key != req.key {
+		return "", errNoSuchUpload
+	}
+	return dir, err
+}
+
+func (req *request) readFile(p string) (string, error) {
+	f, err := req.h.fs.Open(p, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxKeyLength+1))
+	return string(b), err
+}
+
+// part is a stored part of an upload.
+type part struct {
+	number int
+	etag   string
+	path   string
+	info   *hdfs.FileInfo
+}
+
+// parts lists the parts of the upload in dir by part number.
+func (req *request) parts(dir string) ([]part, error) {
+	infos, err := req.h.fs.ListDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	var parts []part
+	for _, info := range infos {
+		name := path.Base(info.Name)
+		num, sum, ok := strings.Cut(name, ".")
+		n, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			continue
+		}
+		parts = append(parts, part{n, `"` + sum + `"`, path.Join(dir, name), info})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+	return parts, nil
+}
+
+func partPrefix(n int) string {
+	return fmt.Sprintf("%05d.", n)
+}
+
+// createMultipartUpload serves CreateMultipartUpload.
+func (req *request) createMultipartUpload() error {
+	if strings.HasSuffix(req.key, "/") {
+		return invalidArgument("A key ending with \"/\" names a directory, which cannot hold data.")
+	}
+	if err := req.checkBucket(); err != nil {
+		return err
+	}
+	id := randomID()
+	dir := req.staging("uploads", id)
+	fs := req.h.fs
+	if err := fs.CreateDirectory(dir); err != nil {
+		return err
+	}
+	f, err := fs.Open(path.Join(dir, keyFile), hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err == nil {
+		_, err = io.WriteString(f, req.key)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fs.Delete(dir)
+		return err
+	}
+	req.writeXML(http.StatusOK, &initiateMultipartUploadResult{Bucket: req.bucket, Key: req.key, UploadId: id})
+	return nil
+}
+
+// uploadPart serves UploadPart, replacing any part of the same number.
+func (req *request) uploadPart() error {
+	n, err := strconv.Atoi(req.q.Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return invalidArgument("Part number must be an integer between 1 and %d, inclusive.", maxPartNumber)
+	}
+	dir, err := req.upload()
+	if err != nil {
+		return err
+	}
+	tmp := req.staging("tmp", randomID())
+	sum, _, err := req.writeBody(tmp)
+	if err != nil {
+		return err
+	}
+	fs := req.h.fs
+	parts, err := req.parts(dir)
+	if err != nil {
+		fs.Delete(tmp)
+		return err
+	}
+	for _, p := range parts {
+		if p.number == n {
+			fs.Delete(p.path)
+		}
+	}
+	tag := hex.EncodeToString(sum)
+	if err := fs.Rename(tmp, path.Join(dir, partPrefix(n)+tag)); err != nil {
+		fs.Delete(tmp)
+		if errors.Is(err, syscall.ENOENT) {
+			return errNoSuchUpload // aborted meanwhile
+		}
+		return err
+	}
+	req.w.Header().Set("ETag", `"`+tag+`"`)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// listParts serves ListParts.
+func (req *request) listParts() error {
+	dir, err := req.upload()
+	if err != nil {
+		return err
+	}
+	max := maxListKeys
+	if s := req.q.Get("max-parts"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgument("Invalid max-parts %q.", s)
+		}
+		if n < max {
+			max = n
+		}
+	}
+	marker := 0
+	if s := req.q.Get("part-number-marker"); s != "" {
+		if marker, err = strconv.Atoi(s); err != nil || marker < 0 {
+			return invalidArgument("Invalid part-number-marker %q.", s)
+		}
+	}
+	parts, err := req.parts(dir)
+	if err != nil {
+		return err
+	}
+	resp := &listPartsResult{Bucket: req.bucket, Key: req.key, UploadId: req.q.Get("uploadId"), PartNumberMarker: marker, MaxParts: max}
+	for _, p := range parts {
+		if p.number <= marker {
+			continue
+		}
+		if len(resp.Parts) == max {
+			resp.IsTruncated = true
+			break
+		}
+		resp.Parts = append(resp.Parts, partEntry{p.number, xmlTime(p.info.LastMod), p.etag, p.info.Size})
+		resp.NextPartNumberMarker = p.number
+	}
+	req.writeXML(http.StatusOK, resp)
+	return nil
+}
+
+// completeMultipartUpload serves CompleteMultipartUpload, concatenating the
+// parts listed in the body into the object. Its entity tag is the MD5 of
+// the MD5s of the parts followed by the number of parts, as on S3.
+func (req *request) completeMultipartUpload() error {
+	dir, err := req.upload()
+	if err != nil {
+		return err
+	}
+	var body completeMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(req.r.Body, maxCompleteBody)).Decode(&body); err != nil || len(body.Parts) == 0 {
+		return errMalformedXML
+	}
+	stored, err := req.parts(dir)
+	if err != nil {
+		return err
+	}
+	byNumber := make(map[int]part, len(stored))
+	for _, p := range stored {
+		byNumber[p.number] = p
+	}
+	min := req.h.MinPartSize
+	if min == 0 {
+		min = defaultMinPartSize
+	}
+	for i := 1; i < len(body.Parts); i++ {
+		if body.Parts[i].PartNumber <= body.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+	}
+	parts := make([]part, len(body.Parts))
+	for i, c := range body.Parts {
+		p, ok := byNumber[c.PartNumber]
+		if !ok || strings.Trim(c.ETag, `"`) != strings.Trim(p.etag, `"`) {
+			return errInvalidPart
+		}
+		if i < len(body.Parts)-1 && p.info.Size < min {
+			return errEntityTooSmall
+		}
+		parts[i] = p
+	}
+	tmp := req.staging("tmp", randomID())
+	sum, err := req.concat(tmp, parts)
+	if err != nil {
+		return err
+	}
+	tag := fmt.Sprintf("\"%x-%d\"", sum, len(parts))
+	if err := req.place(tmp, tag); err != nil {
+		return err
+	}
+	req.h.fs.Delete(dir)
+	req.writeXML(http.StatusOK, &completeMultipartUploadResult{Location: "/" + req.bucket + "/" + req.key, Bucket: req.bucket, Key: req.key, ETag: tag})
+	return nil
+}
+
+// concat writes the parts to the file dst, removed on failure, returning
+// the MD5 of their MD5s.
+func (req *request) concat(dst string, parts []part) (sum []byte, err error) {
+	fs := req.h.fs
+	if err := fs.CreateDirectory(path.Dir(dst)); err != nil {
+		return nil, err
+	}
+	w, err := fs.Open(dst, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	md := md5.New()
+	for _, p := range parts {
+		b, _ := hex.DecodeString(strings.Trim(p.etag, `"`))
+		md.Write(b)
+		if err = copyFile(w, fs, p.path); err != nil {
+			break
+		}
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fs.Delete(dst)
+		return nil, err
+	}
+	return md.Sum(nil), nil
+}
+
+func copyFile(w io.Writer, fs hdfs.FileSystem, name string) error {
+	r, err := fs.Open(name, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// abortMultipartUpload serves AbortMultipartUpload.
+func (req *request) abortMultipartUpload() error {
+	dir, err := req.upload()
+	if err != nil {
+		return err
+	}
+	if err := req.h.fs.Delete(dir); err != nil && !errors.Is(err, syscall.ENOENT) {
+		return err
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
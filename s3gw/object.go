@@ -0,0 +1,326 @@
+package s3gw
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// renameAttempts bounds the attempts to move a staged object into place
+// while other requests replace or delete it.
+const renameAttempts = 3
+
+// emptyETag is the entity tag of empty objects, the MD5 of nothing.
+const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// getObject serves GetObject, or HeadObject without the body. A Range of
+// bytes is read with ReadAt.
+func (req *request) getObject(head bool) error {
+	info, err := req.h.fs.GetPathInfo(req.path)
+	if err != nil {
+		return err
+	}
+	dirKey := strings.HasSuffix(req.key, "/")
+	if (info.Kind == 'D') != dirKey {
+		return errNoSuchKey
+	}
+	size := info.Size
+	if dirKey {
+		size = 0
+	}
+	h := req.w.Header()
+	h.Set("Last-Modified", lastModified(info))
+	h.Set("ETag", etag(req.h.fs, req.path, info))
+	h.Set("Accept-Ranges", "bytes")
+	contentType := mime.TypeByExtension(path.Ext(req.key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	status, start, n := http.StatusOK, int64(0), size
+	ranged, err := parseRange(req.r.Header.Get("Range"), size, &start, &n)
+	if err != nil {
+		return err
+	}
+	if ranged {
+		status = http.StatusPartialContent
+		h.Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(start+n-1, 10)+"/"+strconv.FormatInt(size, 10))
+	}
+	h.Set("Content-Length", strconv.FormatInt(n, 10))
+	if head || n == 0 {
+		req.w.WriteHeader(status)
+		return nil
+	}
+	f, err := req.h.fs.Open(req.path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	req.w.WriteHeader(status)
+	if ranged {
+		_, err = io.Copy(req.w, io.NewSectionReader(f, start, n))
+	} else {
+		_, err = io.CopyN(req.w, f, n)
+	}
+	if err != nil {
+		// the status is sent: cut the response short
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// parseRange parses a Range header of a single range of bytes into start
+// and n, reporting whether it applies. Other ranges are ignored, as S3
+// does, and unsatisfiable ones fail with errInvalidRange.
+func parseRange(s string, size int64, start, n *int64) (bool, error) {
+	spec := strings.TrimPrefix(s, "bytes=")
+	if spec == s || strings.Contains(spec, ",") {
+		return false, nil
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return false, nil
+	}
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return false, nil
+		}
+		if suffix <= 0 || size == 0 {
+			return false, errInvalidRange
+		}
+		if suffix > size {
+			suffix = size
+		}
+		*start, *n = size-suffix, suffix
+		return true, nil
+	}
+	a, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || a < 0 {
+		return false, nil
+	}
+	b := size - 1
+	if last != "" {
+		if b, err = strconv.ParseInt(last, 10, 64); err != nil || b < a {
+			return false, nil
+		}
+	}
+	if a >= size {
+		return false, errInvalidRange
+	}
+	if b >= size {
+		b = size - 1
+	}
+	*start, *n = a, b-a+1
+	return true, nil
+}
+
+// bodyError is a failure to read a request body.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return "s3gw: reading request body: " + e.err.Error()
+}
+
+type bodyReader struct {
+	r io.Reader
+}
+
+func (b bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &bodyError{err}
+	}
+	return n, err
+}
+
+// writeBody writes the request body to the file dst, checking its length
+// and the digests sent in Content-MD5 and x-amz-content-sha256, and
+// returns its MD5 and size. dst is removed on failure.
+func (req *request) writeBody(dst string) (sum []byte, size int64, err error) {
+	fs := req.h.fs
+	body, want := io.Reader(req.r.Body), req.r.ContentLength
+	sha := req.r.Header.Get("x-amz-content-sha256")
+	if strings.HasPrefix(sha, "STREAMING-") || strings.Contains(req.r.Header.Get("Content-Encoding"), "aws-chunked") {
+		body, want = newChunkedReader(body), -1
+		if s := req.r.Header.Get("x-amz-decoded-content-length"); s != "" {
+			if want, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, 0, invalidArgument("Invalid x-amz-decoded-content-length %q.", s)
+			}
+		}
+	}
+	if err := fs.CreateDirectory(path.Dir(dst)); err != nil {
+		return nil, 0, err
+	}
+	f, err := fs.Open(dst, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	md, sh := md5.New(), sha256.New()
+	size, err = io.Copy(io.MultiWriter(f, md, sh), bodyReader{body})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = checkDigests(req.r.Header, md, sh)
+	}
+	var be *bodyError
+	if errors.As(err, &be) || err == nil && want >= 0 && size != want {
+		err = errIncompleteBody
+	}
+	if err != nil {
+		fs.Delete(dst)
+		return nil, 0, err
+	}
+	return md.Sum(nil), size, nil
+}
+
+// checkDigests checks the digests of a body against its headers.
+func checkDigests(h http.Header, md, sh hash.Hash) error {
+	if s := h.Get("Content-MD5"); s != "" {
+		want, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || string(want) != string(md.Sum(nil)) {
+			return errBadDigest
+		}
+	}
+	if s := h.Get("x-amz-content-sha256"); len(s) == sha256.Size*2 {
+		if want, err := hex.DecodeString(s); err == nil && string(want) != string(sh.Sum(nil)) {
+			return errSHA256Mismatch
+		}
+	}
+	return nil
+}
+
+// checkParents turns err, the failure to look up a path below the bucket
+// directory, into errParentIsObject if a parent of the path is a file.
+func (req *request) checkParents(p string, err error) error {
+	for dir := path.Dir(p); strings.HasPrefix(dir, req.dir+"/"); dir = path.Dir(dir) {
+		if info, e := req.h.fs.GetPathInfo(dir); e == nil && info.Kind != 'D' {
+			return errParentIsObject
+		}
+	}
+	return err
+}
+
+// place moves the staged object tmp, whose entity tag is tag, to the path
+// of the object, replacing any object there; tmp is removed on failure.
+func (req *request) place(tmp, tag string) (err error) {
+	fs := req.h.fs
+	defer func() {
+		if err != nil {
+			fs.Delete(tmp)
+		}
+	}()
+	keepETag(fs, tmp, tag)
+	for i := 1; ; i++ {
+		info, err := fs.GetPathInfo(req.path)
+		switch {
+		case err == nil && info.Kind == 'D':
+			return errIsDirectory
+		case err == nil:
+			if err := req.remove(req.path, false); err != nil && !errors.Is(err, syscall.ENOENT) {
+				return err
+			}
+		case !errors.Is(err, syscall.ENOENT):
+			return req.checkParents(req.path, err)
+		}
+		if err := fs.CreateDirectory(path.Dir(req.path)); err != nil {
+			return req.checkParents(req.path, err)
+		}
+		if err := fs.Rename(tmp, req.path); err == nil || i == renameAttempts {
+			return err
+		}
+	}
+}
+
+// putObject serves PutObject. A key ending with "/" creates an empty
+// directory.
+func (req *request) putObject() error {
+	if strings.HasSuffix(req.key, "/") {
+		if req.r.ContentLength > 0 {
+			return invalidArgument("A key ending with \"/\" names a directory, which cannot hold data.")
+		}
+		if err := req.h.fs.CreateDirectory(req.path); err != nil {
+			return req.checkParents(req.path, err)
+		}
+		req.w.Header().Set("ETag", emptyETag)
+		req.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	tmp := req.staging("tmp", randomID())
+	sum, _, err := req.writeBody(tmp)
+	if err != nil {
+		return err
+	}
+	tag := `"` + hex.EncodeToString(sum) + `"`
+	if err := req.place(tmp, tag); err != nil {
+		return err
+	}
+	req.w.Header().Set("ETag", tag)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteObject serves DeleteObject, removing the directories the object
+// leaves empty; deleting a missing object succeeds, as on S3. A directory
+// is only removed while empty.
+func (req *request) deleteObject() error {
+	info, err := req.h.fs.GetPathInfo(req.path)
+	switch {
+	case err != nil:
+		if !errors.Is(err, syscall.ENOENT) && req.checkParents(req.path, err) != errParentIsObject {
+			return err
+		}
+	case (info.Kind == 'D') != strings.HasSuffix(req.key, "/"):
+	default:
+		err := req.remove(req.path, info.Kind == 'D')
+		if errors.Is(err, syscall.ENOTEMPTY) {
+			break
+		}
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			return err
+		}
+		req.prune(path.Dir(req.path))
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// remove deletes the file, or empty directory, p. A recursive Delete would
+// also take what a PutObject writes below p meanwhile, so directories are
+// only removed on FileSystems that are a Remover, failing with
+// ErrUnsupported on others.
+func (req *request) remove(p string, dir bool) error {
+	if r, ok := req.h.fs.(hdfs.Remover); ok {
+		return r.Remove(p)
+	}
+	if dir {
+		return &hdfs.PathError{Op: "remove", Path: p, Err: hdfs.ErrUnsupported}
+	}
+	return req.h.fs.Delete(p)
+}
+
+// prune removes dir and its parents below the bucket directory while they
+// are empty. On FileSystems that are not a Remover they stay, listed as
+// the keys of empty directories.
+func (req *request) prune(dir string) {
+	for ; strings.HasPrefix(dir, req.dir+"/"); dir = path.Dir(dir) {
+		if req.remove(dir, true) != nil {
+			return
+		}
+	}
+}
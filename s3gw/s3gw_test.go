@@ -0,0 +1,438 @@
+package s3gw
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func serveMemFS(t *testing.T) (*hdfstest.MemFS, *httptest.Server) {
+	m := hdfstest.NewMemFS()
+	m.CreateDirectory("/data/bucket")
+	h := NewHandler(m, map[string]string{"bucket": "/data/bucket", "gone": "/data/gone"})
+	h.MinPartSize = 4
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return m, srv
+}
+
+// do sends a request, returning the response with its body read.
+func do(t *testing.T, method, url string, body string, header ...string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error on creating request: %v\n", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error on %s %s: %v\n", method, url, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error on reading response: %v\n", err)
+	}
+	return resp, string(b)
+}
+
+// errorCode returns the S3 error code of a response body.
+func errorCode(body string) string {
+	var e errorResponse
+	xml.Unmarshal([]byte(body), &e)
+	return e.Code
+}
+
+func readFile(t *testing.T, m *hdfstest.MemFS, p string) string {
+	f, err := m.Open(p, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s: %v\n", p, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", p, err)
+	}
+	return string(b)
+}
+
+func TestObjects(t *testing.T) {
+	m, srv := serveMemFS(t)
+	u := srv.URL + "/bucket/"
+
+	resp, _ := do(t, "PUT", u+"a/b/c.txt", "hello, world")
+	sum := md5.Sum([]byte("hello, world"))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != fmt.Sprintf("\"%x\"", sum) {
+		t.Errorf("PutObject: got %s, ETag %s\n", resp.Status, resp.Header.Get("ETag"))
+	}
+	if got := readFile(t, m, "/data/bucket/a/b/c.txt"); got != "hello, world" {
+		t.Errorf("Stored object: got %q\n", got)
+	}
+	resp, body := do(t, "GET", u+"a/b/c.txt", "")
+	if resp.StatusCode != http.StatusOK || body != "hello, world" || resp.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("GetObject: got %s, %q, %s\n", resp.Status, body, resp.Header.Get("Content-Type"))
+	}
+	resp, body = do(t, "HEAD", u+"a/b/c.txt", "")
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != 12 || body != "" || resp.Header.Get("ETag") != fmt.Sprintf("\"%x\"", sum) {
+		t.Errorf("HeadObject: got %s, %d, %q, ETag %s\n", resp.Status, resp.ContentLength, body, resp.Header.Get("ETag"))
+	}
+	for _, c := range []struct {
+		r, body, contentRange string
+	}{
+		{"bytes=7-", "world", "bytes 7-11/12"},
+		{"bytes=0-4", "hello", "bytes 0-4/12"},
+		{"bytes=-5", "world", "bytes 7-11/12"},
+		{"bytes=7-100", "world", "bytes 7-11/12"},
+	} {
+		resp, body := do(t, "GET", u+"a/b/c.txt", "", "Range", c.r)
+		if resp.StatusCode != http.StatusPartialContent || body != c.body || resp.Header.Get("Content-Range") != c.contentRange {
+			t.Errorf("GetObject with Range %s: got %s, %q, %s\n", c.r, resp.Status, body, resp.Header.Get("Content-Range"))
+		}
+	}
+	resp, body = do(t, "GET", u+"a/b/c.txt", "", "Range", "bytes=12-")
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable || errorCode(body) != "InvalidRange" || resp.Header.Get("Content-Range") != "bytes */12" {
+		t.Errorf("GetObject with unsatisfiable Range: got %s, %s\n", resp.Status, body)
+	}
+
+	// a file changed without the gateway gets a tag of its size and time
+	f, _ := m.Open("/data/bucket/a/b/c.txt", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	f.Write([]byte("!"))
+	f.Close()
+	if resp, _ := do(t, "HEAD", u+"a/b/c.txt", ""); !strings.HasSuffix(resp.Header.Get("ETag"), `-1"`) {
+		t.Errorf("HeadObject of an appended object: got ETag %s\n", resp.Header.Get("ETag"))
+	}
+
+	// replacing, then deleting, pruning the emptied directories
+	do(t, "PUT", u+"a/b/c.txt", "bye")
+	if got := readFile(t, m, "/data/bucket/a/b/c.txt"); got != "bye" {
+		t.Errorf("Replaced object: got %q\n", got)
+	}
+	do(t, "PUT", u+"a/d", "d")
+	if resp, _ := do(t, "DELETE", u+"a/b/c.txt", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DeleteObject: got %s\n", resp.Status)
+	}
+	if m.Exists("/data/bucket/a/b") == nil {
+		t.Errorf("Emptied directory left after DeleteObject\n")
+	}
+	if m.Exists("/data/bucket/a/d") != nil {
+		t.Errorf("Sibling removed by DeleteObject\n")
+	}
+	if resp, _ := do(t, "DELETE", u+"missing", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DeleteObject on missing key: got %s\n", resp.Status)
+	}
+
+	// directories
+	if resp, _ := do(t, "PUT", u+"empty/", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("PutObject of directory: got %s\n", resp.Status)
+	}
+	if info, err := m.GetPathInfo("/data/bucket/empty"); err != nil || info.Kind != 'D' {
+		t.Errorf("Directory object: got %v, %v\n", info, err)
+	}
+	if resp, body := do(t, "PUT", u+"a", "x"); resp.StatusCode != http.StatusConflict || errorCode(body) != "ObjectExistsAsDirectory" {
+		t.Errorf("PutObject over directory: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "PUT", u+"a/d/e", "x"); resp.StatusCode != http.StatusConflict || errorCode(body) != "ParentIsObject" {
+		t.Errorf("PutObject below object: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "GET", u+"a", ""); resp.StatusCode != http.StatusNotFound || errorCode(body) != "NoSuchKey" {
+		t.Errorf("GetObject of directory: got %s, %s\n", resp.Status, body)
+	}
+}
+
+// racingFS is a MemFS where a PutObject creates dir/new just as the
+// directory dir is listed or removed.
+type racingFS struct {
+	*hdfstest.MemFS
+	dir string
+}
+
+func (r *racingFS) race(p string) {
+	if p == r.dir {
+		r.MemFS.CreateDirectory(p + "/new")
+	}
+}
+
+func (r *racingFS) ListDirectory(p string) ([]*hdfs.FileInfo, error) {
+	infos, err := r.MemFS.ListDirectory(p)
+	r.race(p)
+	return infos, err
+}
+
+func (r *racingFS) Remove(p string) error {
+	r.race(p)
+	return r.MemFS.Remove(p)
+}
+
+func TestDeleteObjectPrune(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.CreateDirectory("/data/bucket")
+	fsys := &racingFS{m, "/data/bucket/a"}
+	srv := httptest.NewServer(NewHandler(fsys, map[string]string{"bucket": "/data/bucket"}))
+	defer srv.Close()
+	u := srv.URL + "/bucket/"
+
+	do(t, "PUT", u+"a/b/c", "c")
+	if resp, _ := do(t, "DELETE", u+"a/b/c", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DeleteObject: got %s\n", resp.Status)
+	}
+	if m.Exists("/data/bucket/a/b") == nil {
+		t.Errorf("Emptied directory left after DeleteObject\n")
+	}
+	if m.Exists("/data/bucket/a/new") != nil {
+		t.Errorf("Object put meanwhile removed by DeleteObject\n")
+	}
+
+	// without a non-recursive delete, emptied directories stay
+	srv.Config.Handler = NewHandler(struct{ hdfs.FileSystem }{m}, map[string]string{"bucket": "/data/bucket"})
+	do(t, "PUT", u+"d/e", "e")
+	if resp, _ := do(t, "DELETE", u+"d/e", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DeleteObject without Remover: got %s\n", resp.Status)
+	}
+	if m.Exists("/data/bucket/d/e") == nil || m.Exists("/data/bucket/d") != nil {
+		t.Errorf("DeleteObject without Remover: object or its directory removed wrongly\n")
+	}
+	if resp, body := do(t, "DELETE", u+"d/", ""); resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("DeleteObject of directory without Remover: got %s, %s\n", resp.Status, body)
+	}
+}
+
+func TestUploads(t *testing.T) {
+	m, srv := serveMemFS(t)
+	u := srv.URL + "/bucket/"
+	sum := md5.Sum([]byte("hello"))
+
+	resp, body := do(t, "PUT", u+"f", "hello", "Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("PutObject with Content-MD5: got %s, %s\n", resp.Status, body)
+	}
+	resp, body = do(t, "PUT", u+"g", "hellO", "Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if resp.StatusCode != http.StatusBadRequest || errorCode(body) != "BadDigest" {
+		t.Errorf("PutObject with bad Content-MD5: got %s, %s\n", resp.Status, body)
+	}
+	if m.Exists("/data/bucket/g") == nil {
+		t.Errorf("Object stored despite bad digest\n")
+	}
+
+	chunked := "5;chunk-signature=abc\r\nhello\r\n7;chunk-signature=def\r\n, world\r\n0;chunk-signature=ghi\r\n\r\n"
+	resp, body = do(t, "PUT", u+"chunked", chunked,
+		"x-amz-content-sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD",
+		"x-amz-decoded-content-length", "12")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("PutObject with aws-chunked body: got %s, %s\n", resp.Status, body)
+	}
+	if got := readFile(t, m, "/data/bucket/chunked"); got != "hello, world" {
+		t.Errorf("Object from aws-chunked body: got %q\n", got)
+	}
+	resp, body = do(t, "PUT", u+"chunked", chunked,
+		"x-amz-content-sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD",
+		"x-amz-decoded-content-length", "13")
+	if resp.StatusCode != http.StatusBadRequest || errorCode(body) != "IncompleteBody" {
+		t.Errorf("PutObject with short aws-chunked body: got %s, %s\n", resp.Status, body)
+	}
+
+	infos, err := m.ListDirectory("/data/bucket/.s3gw/tmp")
+	if err != nil || len(infos) != 0 {
+		t.Errorf("Staged objects left: got %v, %v\n", infos, err)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	m, srv := serveMemFS(t)
+	u := srv.URL + "/bucket/dir/obj"
+
+	resp, body := do(t, "POST", u+"?uploads", "")
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal([]byte(body), &initiated); resp.StatusCode != http.StatusOK || err != nil || initiated.UploadId == "" || initiated.Key != "dir/obj" {
+		t.Fatalf("CreateMultipartUpload: got %s, %s\n", resp.Status, body)
+	}
+	id := initiated.UploadId
+	etags := make([]string, 6)
+	for i, data := range []string{"", "part1", "xx", "part3"} {
+		if i == 0 {
+			continue
+		}
+		resp, body := do(t, "PUT", fmt.Sprintf("%s?partNumber=%d&uploadId=%s", u, i, id), data)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("UploadPart %d: got %s, %s\n", i, resp.Status, body)
+		}
+		etags[i] = resp.Header.Get("ETag")
+	}
+	// replacing part 2
+	resp, _ = do(t, "PUT", u+"?partNumber=2&uploadId="+id, "part2")
+	etags[2] = resp.Header.Get("ETag")
+	if resp, body := do(t, "PUT", u+"?partNumber=0&uploadId="+id, "x"); resp.StatusCode != http.StatusBadRequest || errorCode(body) != "InvalidArgument" {
+		t.Errorf("UploadPart with part number 0: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "PUT", srv.URL+"/bucket/other?partNumber=1&uploadId="+id, "x"); resp.StatusCode != http.StatusNotFound || errorCode(body) != "NoSuchUpload" {
+		t.Errorf("UploadPart to other key: got %s, %s\n", resp.Status, body)
+	}
+
+	resp, body = do(t, "GET", u+"?max-parts=2&uploadId="+id, "")
+	var parts listPartsResult
+	if err := xml.Unmarshal([]byte(body), &parts); err != nil || !parts.IsTruncated || len(parts.Parts) != 2 || parts.NextPartNumberMarker != 2 || parts.Parts[1].ETag != etags[2] || parts.Parts[1].Size != 5 {
+		t.Errorf("ListParts: got %s, %s\n", resp.Status, body)
+	}
+	resp, body = do(t, "GET", u+"?part-number-marker=2&uploadId="+id, "")
+	parts = listPartsResult{}
+	if err := xml.Unmarshal([]byte(body), &parts); err != nil || parts.IsTruncated || len(parts.Parts) != 1 || parts.Parts[0].PartNumber != 3 {
+		t.Errorf("ListParts after marker: got %s, %s\n", resp.Status, body)
+	}
+
+	complete := func(nums ...int) string {
+		var b strings.Builder
+		b.WriteString("<CompleteMultipartUpload>")
+		for _, n := range nums {
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", n, etags[n])
+		}
+		b.WriteString("</CompleteMultipartUpload>")
+		return b.String()
+	}
+	for _, c := range []struct {
+		body, code string
+	}{
+		{"<Complete", "MalformedXML"},
+		{complete(2, 1), "InvalidPartOrder"},
+		{strings.Replace(complete(1, 2), etags[2], `"00"`, 1), "InvalidPart"},
+		{complete(1, 4), "InvalidPart"},
+	} {
+		if resp, body := do(t, "POST", u+"?uploadId="+id, c.body); resp.StatusCode != http.StatusBadRequest || errorCode(body) != c.code {
+			t.Errorf("CompleteMultipartUpload expecting %s: got %s, %s\n", c.code, resp.Status, body)
+		}
+	}
+	for n, data := range map[int]string{4: "x", 5: "y"} {
+		resp, _ := do(t, "PUT", fmt.Sprintf("%s?partNumber=%d&uploadId=%s", u, n, id), data)
+		etags[n] = resp.Header.Get("ETag")
+	}
+	if resp, body := do(t, "POST", u+"?uploadId="+id, complete(1, 5, 2)); resp.StatusCode != http.StatusBadRequest || errorCode(body) != "InvalidPartOrder" {
+		t.Errorf("CompleteMultipartUpload out of order: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "POST", u+"?uploadId="+id, complete(1, 4, 5)); resp.StatusCode != http.StatusBadRequest || errorCode(body) != "EntityTooSmall" {
+		t.Errorf("CompleteMultipartUpload with small part: got %s, %s\n", resp.Status, body)
+	}
+
+	resp, body = do(t, "POST", u+"?uploadId="+id, complete(1, 2, 3))
+	var completed completeMultipartUploadResult
+	if err := xml.Unmarshal([]byte(body), &completed); resp.StatusCode != http.StatusOK || err != nil || !strings.HasSuffix(completed.ETag, `-3"`) || completed.Location != "/bucket/dir/obj" {
+		t.Errorf("CompleteMultipartUpload: got %s, %s\n", resp.Status, body)
+	}
+	if got := readFile(t, m, "/data/bucket/dir/obj"); got != "part1part2part3" {
+		t.Errorf("Completed object: got %q\n", got)
+	}
+	if resp, _ := do(t, "HEAD", u, ""); resp.Header.Get("ETag") != completed.ETag {
+		t.Errorf("HeadObject of the completed object: got ETag %s, want %s\n", resp.Header.Get("ETag"), completed.ETag)
+	}
+	if resp, body := do(t, "GET", u+"?uploadId="+id, ""); resp.StatusCode != http.StatusNotFound || errorCode(body) != "NoSuchUpload" {
+		t.Errorf("ListParts after completion: got %s, %s\n", resp.Status, body)
+	}
+
+	resp, body = do(t, "POST", u+"?uploads", "")
+	initiated = initiateMultipartUploadResult{}
+	xml.Unmarshal([]byte(body), &initiated)
+	do(t, "PUT", u+"?partNumber=1&uploadId="+initiated.UploadId, "part")
+	if resp, _ := do(t, "DELETE", u+"?uploadId="+initiated.UploadId, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("AbortMultipartUpload: got %s\n", resp.Status)
+	}
+	if infos, err := m.ListDirectory("/data/bucket/.s3gw/uploads"); err != nil || len(infos) != 0 {
+		t.Errorf("Uploads left: got %v, %v\n", infos, err)
+	}
+	if got := readFile(t, m, "/data/bucket/dir/obj"); got != "part1part2part3" {
+		t.Errorf("Object after abort: got %q\n", got)
+	}
+}
+
+// list lists the bucket with the query q, following continuation tokens,
+// returning the keys and common prefixes of every page.
+func list(t *testing.T, srv *httptest.Server, q string) (pages [][]string) {
+	token := ""
+	for {
+		url := srv.URL + "/bucket?" + q
+		if token != "" {
+			url += "&continuation-token=" + token
+		}
+		resp, body := do(t, "GET", url, "")
+		var result listBucketResult
+		if err := xml.Unmarshal([]byte(body), &result); resp.StatusCode != http.StatusOK || err != nil {
+			t.Fatalf("ListObjects %s: got %s, %s\n", q, resp.Status, body)
+		}
+		var page []string
+		for _, c := range result.Contents {
+			page = append(page, c.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			page = append(page, p.Prefix)
+		}
+		pages = append(pages, page)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return pages
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	_, srv := serveMemFS(t)
+	for _, key := range []string{"a", "a.b", "b/c", "b/d/e", "b/d/f", "b-c", "c/"} {
+		do(t, "PUT", srv.URL+"/bucket/"+key, "")
+	}
+	// an upload in progress stays out of listings
+	do(t, "POST", srv.URL+"/bucket/z?uploads", "")
+
+	for _, c := range []struct {
+		q     string
+		pages [][]string
+	}{
+		{"list-type=2", [][]string{{"a", "a.b", "b-c", "b/c", "b/d/e", "b/d/f", "c/"}}},
+		{"list-type=2&max-keys=3", [][]string{{"a", "a.b", "b-c"}, {"b/c", "b/d/e", "b/d/f"}, {"c/"}}},
+		{"list-type=2&delimiter=/", [][]string{{"a", "a.b", "b-c", "b/", "c/"}}},
+		{"list-type=2&delimiter=/&max-keys=2", [][]string{{"a", "a.b"}, {"b-c", "b/"}, {"c/"}}},
+		{"list-type=2&prefix=b/", [][]string{{"b/c", "b/d/e", "b/d/f"}}},
+		{"list-type=2&prefix=b/d&delimiter=/", [][]string{{"b/d/"}}},
+		{"list-type=2&prefix=b&delimiter=/", [][]string{{"b-c", "b/"}}},
+		{"list-type=2&delimiter=.", [][]string{{"a", "b-c", "b/c", "b/d/e", "b/d/f", "c/", "a."}}},
+		{"list-type=2&start-after=b/c", [][]string{{"b/d/e", "b/d/f", "c/"}}},
+		{"list-type=2&prefix=x", [][]string{nil}},
+	} {
+		if got := list(t, srv, c.q); !reflect.DeepEqual(got, c.pages) {
+			t.Errorf("ListObjectsV2 %s: got %q, want %q\n", c.q, got, c.pages)
+		}
+	}
+
+	resp, body := do(t, "GET", srv.URL+"/bucket?delimiter=/&max-keys=3&marker=a", "")
+	var result listBucketResult
+	if err := xml.Unmarshal([]byte(body), &result); resp.StatusCode != http.StatusOK || err != nil ||
+		result.Marker == nil || *result.Marker != "a" || !result.IsTruncated || result.NextMarker != "b/" || len(result.Contents) != 2 || len(result.CommonPrefixes) != 1 {
+		t.Errorf("ListObjects with marker: got %s, %s\n", resp.Status, body)
+	}
+
+	if resp, body := do(t, "GET", srv.URL+"/gone?list-type=2", ""); resp.StatusCode != http.StatusNotFound || errorCode(body) != "NoSuchBucket" {
+		t.Errorf("ListObjects on missing bucket directory: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "GET", srv.URL+"/nobucket/key", ""); resp.StatusCode != http.StatusNotFound || errorCode(body) != "NoSuchBucket" {
+		t.Errorf("GetObject on unknown bucket: got %s, %s\n", resp.Status, body)
+	}
+	if resp, body := do(t, "GET", srv.URL+"/bucket/.s3gw/tmp", ""); resp.StatusCode != http.StatusBadRequest || errorCode(body) != "InvalidArgument" {
+		t.Errorf("GetObject on reserved key: got %s, %s\n", resp.Status, body)
+	}
+	resp, body = do(t, "GET", srv.URL+"/", "")
+	var buckets listAllMyBucketsResult
+	if err := xml.Unmarshal([]byte(body), &buckets); err != nil || len(buckets.Buckets) != 1 || buckets.Buckets[0].Name != "bucket" {
+		t.Errorf("ListBuckets: got %s, %s\n", resp.Status, body)
+	}
+	if resp, _ := do(t, "HEAD", srv.URL+"/gone", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("HeadBucket on missing bucket directory: got %s\n", resp.Status)
+	}
+	if resp, body := do(t, "GET", srv.URL+"/bucket?versions", ""); resp.StatusCode != http.StatusNotImplemented || errorCode(body) != "NotImplemented" {
+		t.Errorf("ListObjectVersions: got %s, %s\n", resp.Status, body)
+	}
+}
@@ -0,0 +1,103 @@
+package hdfs
+
+import (
+	"fmt"
+	"path"
+)
+
+// SnapshotDir is the name of the read-only directory under a snapshottable
+// directory that holds its snapshots.
+const SnapshotDir = ".snapshot"
+
+// SnapshotPath returns the path of the snapshot name of the directory dir,
+// under which the files of dir are read as they were when it was taken.
+func SnapshotPath(dir, name string) string {
+	return path.Join(dir, SnapshotDir, name)
+}
+
+// SnapshottableDir is a directory snapshots are allowed on.
+type SnapshottableDir struct {
+	Path          string
+	Snapshots     int // snapshots taken and not deleted
+	SnapshotQuota int // snapshots the directory may hold
+}
+
+// DiffType is the change a SnapshotDiffEntry reports.
+type DiffType int
+
+const (
+	DiffCreate DiffType = iota
+	DiffModify
+	DiffDelete
+	DiffRename
+)
+
+// diffTypes are the names and labels of the DiffTypes, as in the
+// SnapshotDiffReport.DiffType enum.
+var diffTypes = []struct{ name, label string }{
+	{"CREATE", "+"},
+	{"MODIFY", "M"},
+	{"DELETE", "-"},
+	{"RENAME", "R"},
+}
+
+func (t DiffType) String() string {
+	if t < 0 || int(t) >= len(diffTypes) {
+		return fmt.Sprintf("DiffType(%d)", int(t))
+	}
+	return diffTypes[t].name
+}
+
+// Label returns the one character hdfs snapshotDiff prints for t.
+func (t DiffType) Label() string {
+	if t < 0 || int(t) >= len(diffTypes) {
+		return "?"
+	}
+	return diffTypes[t].label
+}
+
+// ParseDiffType returns the DiffType of the name or the label s.
+func ParseDiffType(s string) (DiffType, error) {
+	for i, t := range diffTypes {
+		if s == t.name || s == t.label {
+			return DiffType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("hdfs: invalid snapshot diff type %q", s)
+}
+
+// SnapshotDiffEntry is a change between two snapshots of a directory. Path,
+// and Target for a DiffRename, are relative to the directory, "." being the
+// directory itself.
+type SnapshotDiffEntry struct {
+	Type   DiffType
+	Path   string
+	Target string
+}
+
+// String formats e as hdfs snapshotDiff prints it, such as "R\t./a -> ./b".
+func (e SnapshotDiffEntry) String() string {
+	s := e.Type.Label() + "\t" + diffPath(e.Path)
+	if e.Type == DiffRename {
+		s += " -> " + diffPath(e.Target)
+	}
+	return s
+}
+
+func diffPath(p string) string {
+	if p == "." || p == "" {
+		return "."
+	}
+	return "./" + p
+}
+
+// SnapshotDiffReport lists the changes made to the directory Dir between
+// its snapshots From and To, an empty name standing for its current state.
+// A directory is reported as modified when entries are created, deleted or
+// renamed in it; the content of a created or deleted directory is not
+// reported.
+type SnapshotDiffReport struct {
+	Dir      string
+	From, To string
+	Entries  []SnapshotDiffEntry
+}
@@ -0,0 +1,124 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ContentSummary is the disk usage of a file or directory tree. Quota and
+// SpaceQuota are -1 when not set.
+type ContentSummary struct {
+	Length         int64 // bytes of data, not counting replication
+	FileCount      int64
+	DirectoryCount int64 // including the directory itself
+	Quota          int64 // namespace quota: files and directories
+	SpaceConsumed  int64 // bytes of data times replication
+	SpaceQuota     int64
+	TypeQuotas     map[StorageType]TypeQuota // the storage types with a quota set
+}
+
+// TypeQuota is the space quota of a directory on a storage type, and the
+// space its tree consumes there.
+type TypeQuota struct {
+	Quota    int64
+	Consumed int64
+}
+
+// Values of the quotas given to SetQuota and SetTypeQuota, as in
+// HdfsConstants.
+const (
+	QuotaDontSet = math.MaxInt64 // leaves the quota as it is
+	QuotaReset   = -1            // clears the quota
+)
+
+// StorageType is the kind of storage a datanode keeps replicas on, as
+// storage policies choose them.
+type StorageType int
+
+const (
+	StorageDisk StorageType = iota
+	StorageSSD
+	StorageArchive
+	StorageRAMDisk
+	StorageProvided
+	StorageNVDIMM
+)
+
+// storageTypes are the names of the StorageTypes, as in the StorageType
+// enum.
+var storageTypes = []string{"DISK", "SSD", "ARCHIVE", "RAM_DISK", "PROVIDED", "NVDIMM"}
+
+func (t StorageType) String() string {
+	if t < 0 || int(t) >= len(storageTypes) {
+		return fmt.Sprintf("StorageType(%d)", int(t))
+	}
+	return storageTypes[t]
+}
+
+// ParseStorageType returns the StorageType named s, in any case, as hdfs
+// dfsadmin -setSpaceQuota -storageType takes it.
+func ParseStorageType(s string) (StorageType, error) {
+	for i, name := range storageTypes {
+		if strings.EqualFold(s, name) {
+			return StorageType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("hdfs: invalid storage type %q", s)
+}
+
+// SupportsQuota reports whether quotas can be set on t: on every storage
+// type but the transient RAM_DISK.
+func (t StorageType) SupportsQuota() bool {
+	return t != StorageRAMDisk
+}
+
+// GetContentSummary returns the ContentSummary of the tree rooted at path.
+// FileSystems that are not a Summarizer, or fail with ErrUnsupported, have
+// the tree walked instead, which tells no quotas.
+func GetContentSummary(fsys FileSystem, path string) (*ContentSummary, error) {
+	if s, ok := fsys.(Summarizer); ok {
+		if cs, err := s.GetContentSummary(path); !errors.Is(err, ErrUnsupported) {
+			return cs, err
+		}
+	}
+	cs := &ContentSummary{Quota: -1, SpaceQuota: -1}
+	err := Walk(fsys, path, func(_ string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			cs.DirectoryCount++
+			return nil
+		}
+		cs.FileCount++
+		cs.Length += info.Size
+		cs.SpaceConsumed += info.Size * int64(info.Replication)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// GetRemaining returns the raw space left for new blocks. FileSystems that
+// are not a SpaceReporter, or fail with ErrUnsupported, report their
+// capacity less the space used, which counts no space used outside them.
+func GetRemaining(fsys FileSystem) (int64, error) {
+	if r, ok := fsys.(SpaceReporter); ok {
+		if n, err := r.GetRemaining(); !errors.Is(err, ErrUnsupported) {
+			return n, err
+		}
+	}
+	capacity, err := fsys.GetCapacity()
+	if err != nil {
+		return -1, err
+	}
+	used, err := fsys.GetUsed()
+	if err != nil {
+		return -1, err
+	}
+	return capacity - used, nil
+}
@@ -0,0 +1,21 @@
+package hdfs_test
+
+import (
+	"testing"
+
+	"github.com/zyxar/hdfs"
+)
+
+func TestGetRemaining(t *testing.T) {
+	m := newTree(t, "/data/a")
+	m.SetCapacity(1000)
+	m.SetNonDFSUsed(100)
+	used, _ := m.GetUsed()
+
+	if n, err := hdfs.GetRemaining(m); n != 900-used || err != nil {
+		t.Errorf("GetRemaining: got %d, %v, want %d\n", n, err, 900-used)
+	}
+	if n, err := hdfs.GetRemaining(struct{ hdfs.FileSystem }{m}); n != 1000-used || err != nil {
+		t.Errorf("GetRemaining without a SpaceReporter: got %d, %v, want %d\n", n, err, 1000-used)
+	}
+}
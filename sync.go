@@ -0,0 +1,160 @@
+package hdfs
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Hflush makes the data written to f visible to readers opening the file
+// afterwards, with f.Hflush if f is a Syncer and f.Flush otherwise.
+func Hflush(f FileHandle) error {
+	if s, ok := f.(Syncer); ok {
+		return s.Hflush()
+	}
+	return f.Flush()
+}
+
+// Hsync makes the data written to f visible and durable with f.Hsync,
+// failing with ErrUnsupported if f is not a Syncer.
+func Hsync(f FileHandle) error {
+	if s, ok := f.(Syncer); ok {
+		return s.Hsync()
+	}
+	return &PathError{"hsync", f.Name(), ErrUnsupported}
+}
+
+// SyncPolicy tells a SyncWriter when to flush the data written to it. The
+// zero SyncPolicy leaves flushing to the caller and to Close.
+type SyncPolicy struct {
+	// Bytes flushes once Bytes were written since the last flush, if > 0.
+	Bytes int64
+	// Interval flushes what was written in the last Interval, if > 0,
+	// even while no more is written.
+	Interval time.Duration
+	// Sync flushes with Hsync instead of Hflush.
+	Sync bool
+	// OnClose calls Hsync before closing the file: closing it makes its
+	// data visible but, unless the datanodes sync on close, not durable.
+	OnClose bool
+}
+
+// SyncWriter writes to a file open for writing, flushing the data written
+// as its SyncPolicy says. The first failure to flush in the background is
+// returned by the next Write, Flush or Close.
+type SyncWriter struct {
+	f      FileHandle
+	policy SyncPolicy
+
+	mu      sync.Mutex
+	pending int64 // bytes written since the last flush
+	err     error // failure of a flush in the background
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSyncWriter returns a SyncWriter writing to f with policy.
+func NewSyncWriter(f FileHandle, policy SyncPolicy) *SyncWriter {
+	w := &SyncWriter{f: f, policy: policy}
+	if policy.Interval > 0 {
+		w.stop, w.done = make(chan struct{}), make(chan struct{})
+		go w.tick()
+	}
+	return w
+}
+
+// Name returns the name of the file written.
+func (w *SyncWriter) Name() string {
+	return w.f.Name()
+}
+
+// Write writes p to the file, flushing it if Bytes were written since the
+// last flush.
+func (w *SyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.f.Write(p)
+	w.pending += int64(n)
+	if err == nil && w.policy.Bytes > 0 && w.pending >= w.policy.Bytes {
+		err = w.flush()
+	}
+	return n, err
+}
+
+// Flush flushes the data written so far as the policy says, with Hsync or
+// Hflush.
+func (w *SyncWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush()
+}
+
+// flush flushes the file; w.mu must be held.
+func (w *SyncWriter) flush() error {
+	var err error
+	if w.policy.Sync {
+		err = Hsync(w.f)
+	} else {
+		err = Hflush(w.f)
+	}
+	if err == nil {
+		w.pending = 0
+	}
+	return err
+}
+
+// tick flushes the data pending every Interval until Close.
+func (w *SyncWriter) tick() {
+	defer close(w.done)
+	t := time.NewTicker(w.policy.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+		}
+		w.mu.Lock()
+		if w.err == nil && w.pending > 0 {
+			w.err = w.flush()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Close stops flushing in the background and closes the file, after an
+// Hsync if OnClose is set. The file is closed even if the Hsync fails.
+func (w *SyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return os.ErrClosed
+	}
+	w.closed = true
+	w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	err := w.err
+	if err == nil && w.policy.OnClose {
+		err = Hsync(w.f)
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package hdfs_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+// seen returns what a reader opening path now sees of it.
+func seen(t *testing.T, fsys hdfs.FileSystem, path string) string {
+	f, err := fsys.Open(path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s: %v\n", path, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", path, err)
+	}
+	return string(b)
+}
+
+func synced(t *testing.T, m *hdfstest.MemFS, path string) int64 {
+	n, err := m.SyncedSize(path)
+	if err != nil {
+		t.Fatalf("Error on SyncedSize of %s: %v\n", path, err)
+	}
+	return n
+}
+
+// TestVisibility shows what other readers see of a file being written
+// after each call of the writer.
+func TestVisibility(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	w, err := m.Open("/file", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on creating file: %v\n", err)
+	}
+	early, _ := m.Open("/file", hdfs.O_RDONLY, 0, 0, 0)
+	defer early.Close()
+
+	// written data stays with the writer
+	io.WriteString(w, "one ")
+	if got := seen(t, m, "/file"); got != "" {
+		t.Errorf("After Write: got %q\n", got)
+	}
+
+	// Hflush: new readers see the data, which is not durable yet, nor
+	// counted in the size of the file
+	if err := hdfs.Hflush(w); err != nil {
+		t.Errorf("Error on Hflush: %v\n", err)
+	}
+	if got := seen(t, m, "/file"); got != "one " {
+		t.Errorf("After Hflush: got %q\n", got)
+	}
+	if info, err := m.GetPathInfo("/file"); err != nil || info.Size != 0 {
+		t.Errorf("Size after Hflush: got %v, %v\n", info, err)
+	}
+	if n := synced(t, m, "/file"); n != 0 {
+		t.Errorf("Synced after Hflush: got %d\n", n)
+	}
+
+	// Hsync: the data is also durable, and the size grows
+	io.WriteString(w, "two ")
+	if err := hdfs.Hsync(w); err != nil {
+		t.Errorf("Error on Hsync: %v\n", err)
+	}
+	if got := seen(t, m, "/file"); got != "one two " {
+		t.Errorf("After Hsync: got %q\n", got)
+	}
+	if n := synced(t, m, "/file"); n != 8 {
+		t.Errorf("Synced after Hsync: got %d\n", n)
+	}
+	if info, err := m.GetPathInfo("/file"); err != nil || info.Size != 8 {
+		t.Errorf("Size after Hsync: got %v, %v\n", info, err)
+	}
+
+	// a reader opened before keeps the length of then
+	if b, _ := ioutil.ReadAll(early); len(b) != 0 {
+		t.Errorf("Reader opened before: got %q\n", b)
+	}
+
+	// Close: everything is visible
+	io.WriteString(w, "three")
+	if err := w.Close(); err != nil {
+		t.Errorf("Error on Close: %v\n", err)
+	}
+	if got := seen(t, m, "/file"); got != "one two three" {
+		t.Errorf("After Close: got %q\n", got)
+	}
+	if info, err := m.GetPathInfo("/file"); err != nil || info.Size != 13 {
+		t.Errorf("Size after Close: got %v, %v\n", info, err)
+	}
+
+	r, _ := m.Open("/file", hdfs.O_RDONLY, 0, 0, 0)
+	defer r.Close()
+	if err := hdfs.Hflush(r); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Hflush of a reader: got %v\n", err)
+	}
+	if err := hdfs.Hsync(struct{ hdfs.FileHandle }{r}); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Hsync of a file without it: got %v\n", err)
+	}
+}
+
+func TestSyncWriter(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	open := func(path string) hdfs.FileHandle {
+		f, err := m.Open(path, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+		if err != nil {
+			t.Fatalf("Error on creating %s: %v\n", path, err)
+		}
+		return f
+	}
+
+	// every N bytes
+	w := hdfs.NewSyncWriter(open("/bytes"), hdfs.SyncPolicy{Bytes: 4, Sync: true})
+	io.WriteString(w, "abc")
+	if got := seen(t, m, "/bytes"); got != "" {
+		t.Errorf("Below Bytes: got %q\n", got)
+	}
+	io.WriteString(w, "de")
+	if got, n := seen(t, m, "/bytes"), synced(t, m, "/bytes"); got != "abcde" || n != 5 {
+		t.Errorf("Past Bytes: got %q, %d synced\n", got, n)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Error on Close: %v\n", err)
+	}
+	if err := w.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Second Close: got %v\n", err)
+	}
+
+	// every interval
+	w = hdfs.NewSyncWriter(open("/interval"), hdfs.SyncPolicy{Interval: time.Millisecond})
+	io.WriteString(w, "tick")
+	deadline := time.Now().Add(5 * time.Second)
+	for seen(t, m, "/interval") != "tick" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Data not flushed after Interval\n")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := synced(t, m, "/interval"); n != 0 {
+		t.Errorf("Synced by Hflush: got %d\n", n)
+	}
+	w.Close()
+
+	// on Close, failing on a file without Hsync but closing it anyway
+	f := open("/close")
+	w = hdfs.NewSyncWriter(struct{ hdfs.FileHandle }{f}, hdfs.SyncPolicy{OnClose: true})
+	io.WriteString(w, "last")
+	if err := w.Close(); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Close with Hsync unsupported: got %v\n", err)
+	}
+	if got := seen(t, m, "/close"); got != "last" {
+		t.Errorf("After Close: got %q\n", got)
+	}
+}
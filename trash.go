@@ -0,0 +1,393 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A trash root holds what was moved to the trash since the last checkpoint
+// under Current, and the checkpoints under the times they were made at, as
+// TrashPolicyDefault lays them out.
+const (
+	trashCurrent        = "Current"
+	checkpointFormat    = "060102150405"
+	oldCheckpointFormat = "0601021504"
+	trashPerm           = 0700
+)
+
+// trasher returns fsys as a Trasher, failing with ErrUnsupported if it is
+// not one.
+func trasher(fsys FileSystem, op, p string) (Trasher, error) {
+	t, ok := fsys.(Trasher)
+	if !ok {
+		return nil, &PathError{op, p, ErrUnsupported}
+	}
+	return t, nil
+}
+
+// namePath returns the path of a URI, such as the Name of a FileInfo.
+func namePath(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		return u.Path
+	}
+	return name
+}
+
+// absPath resolves p, which may be a full URI, against the working
+// directory of fsys.
+func absPath(fsys FileSystem, p string) (string, error) {
+	p = namePath(p)
+	if !path.IsAbs(p) {
+		buf := make([]byte, 4096)
+		wd, err := fsys.GetWorkingDirectory(buf, uint32(len(buf)))
+		if err != nil {
+			return "", err
+		}
+		if i := strings.IndexByte(string(wd), 0); i >= 0 {
+			wd = wd[:i]
+		}
+		p = path.Join(namePath(string(wd)), p)
+	}
+	return path.Clean(p), nil
+}
+
+// inDir reports whether p is dir or below it.
+func inDir(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// timeSuffix returns the current time in milliseconds, which names taken in
+// the trash get appended.
+func timeSuffix() string {
+	return strconv.FormatInt(time.Now().UnixMilli(), 10)
+}
+
+// MoveToTrash moves p to the trash of the user, as hdfs dfs -rm does unless
+// given -skipTrash, and returns where it went: under Current in the
+// TrashRoot of p, at the full path p had. A name already taken there gets
+// the current time in milliseconds appended, and so does a directory to
+// create where a file was moved to before.
+//
+// Nothing is moved, and "" is returned with no error, if the trash is
+// disabled or p is already in the trash; hdfs dfs -rm then deletes it for
+// good. Files stay in Current until Expunge makes a checkpoint of it.
+func MoveToTrash(fsys FileSystem, p string) (string, error) {
+	t, err := trasher(fsys, "trash", p)
+	if err != nil {
+		return "", err
+	}
+	interval, err := t.TrashInterval()
+	if err != nil || interval <= 0 {
+		return "", err
+	}
+	if _, err := fsys.GetPathInfo(p); err != nil {
+		return "", err
+	}
+	abs, err := absPath(fsys, p)
+	if err != nil {
+		return "", err
+	}
+	root, err := t.TrashRoot(abs)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case inDir(abs, root):
+		return "", nil
+	case inDir(root, abs):
+		return "", &PathError{"trash", p, fmt.Errorf("%w: it contains the trash %s", syscall.EINVAL, root)}
+	}
+	dir, err := mkTrashDir(fsys, root, path.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	base := path.Join(dir, path.Base(abs))
+	dst := base
+	for {
+		err := fsys.Exists(dst)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		dst = base + timeSuffix()
+	}
+	if err := fsys.Rename(abs, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// mkTrashDir creates Current in the trash root and the directory dir below
+// it, with their missing parents, readable by the user only, and returns
+// the path of dir there. A file in the way gets a directory next to it
+// instead, its name appended the current time.
+func mkTrashDir(fsys FileSystem, root, dir string) (string, error) {
+	names := []string{trashCurrent}
+	if dir != "/" {
+		names = append(names, strings.Split(dir[1:], "/")...)
+	}
+	p := root
+	for i := -1; i < len(names); i++ {
+		if i >= 0 {
+			p += "/" + names[i]
+		}
+		for {
+			info, err := fsys.GetPathInfo(p)
+			if errors.Is(err, fs.ErrNotExist) {
+				if err := fsys.CreateDirectory(p); err != nil {
+					return "", err
+				}
+				if err := fsys.Chmod(p, trashPerm); err != nil {
+					return "", err
+				}
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			if info.IsDir() {
+				break
+			}
+			if i < 0 {
+				return "", &PathError{"trash", root, syscall.ENOTDIR}
+			}
+			p += timeSuffix()
+		}
+	}
+	return p, nil
+}
+
+// checkpointTime returns the time the checkpoint name was made at. Names of
+// checkpoints made in the same second have -1, -2... appended.
+func checkpointTime(name string) (time.Time, bool) {
+	name, _, _ = strings.Cut(name, "-")
+	for _, layout := range []string{checkpointFormat, oldCheckpointFormat} {
+		if t, err := time.ParseInLocation(layout, name, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Expunge empties the trash of the user as hdfs dfs -expunge does: in each
+// of its TrashRoots, the checkpoints older than the TrashInterval are
+// deleted, then Current becomes a checkpoint named after the current time.
+// What MoveToTrash moved is thus deleted for good by the first Expunge at
+// least the TrashInterval after the one that checkpointed it. Checkpoints
+// that cannot be deleted are left for the next Expunge, and the first error
+// is returned.
+func Expunge(fsys FileSystem) error {
+	t, err := trasher(fsys, "expunge", "")
+	if err != nil {
+		return err
+	}
+	interval, err := t.TrashInterval()
+	if err != nil {
+		return err
+	}
+	roots, err := t.TrashRoots()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var first error
+	for _, root := range roots {
+		if err := deleteCheckpoints(fsys, root, interval, now); err != nil && first == nil {
+			first = err
+		}
+		if err := checkpoint(fsys, root, now); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// deleteCheckpoints deletes the checkpoints of the trash root older than
+// interval.
+func deleteCheckpoints(fsys FileSystem, root string, interval time.Duration, now time.Time) error {
+	infos, err := fsys.ListDirectory(root)
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, info := range infos {
+		name := path.Base(namePath(info.Name))
+		t, ok := checkpointTime(name)
+		if !ok || !info.IsDir() || now.Sub(t) <= interval {
+			continue
+		}
+		if err := fsys.Delete(root + "/" + name); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// checkpoint renames Current, if any, in the trash root after now.
+func checkpoint(fsys FileSystem, root string, now time.Time) error {
+	current := root + "/" + trashCurrent
+	if err := fsys.Exists(current); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	base := root + "/" + now.Format(checkpointFormat)
+	name := base
+	for i := 1; ; i++ {
+		err := fsys.Exists(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return fsys.Rename(current, name)
+}
+
+// RestoreFromTrash moves p back from the trash of the user, where
+// MoveToTrash put it, creating its missing parents, and returns where it
+// was: in Current, else in the newest checkpoint holding it. Of the copies
+// of p there, the one moved last is restored, whose name may have had the
+// time it was moved at appended. p must not exist.
+func RestoreFromTrash(fsys FileSystem, p string) (string, error) {
+	t, err := trasher(fsys, "restore", p)
+	if err != nil {
+		return "", err
+	}
+	abs, err := absPath(fsys, p)
+	if err != nil {
+		return "", err
+	}
+	switch err := fsys.Exists(abs); {
+	case err == nil:
+		return "", &PathError{"restore", p, syscall.EEXIST}
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+	// the trash root depends on the encryption zone of the parent of p,
+	// which the nearest parent left tells
+	top := abs
+	for dir := path.Dir(top); dir != "/"; dir = path.Dir(top) {
+		err := fsys.Exists(dir)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		top = dir
+	}
+	root, err := t.TrashRoot(top)
+	if err != nil {
+		return "", err
+	}
+	dirs, err := trashDirs(fsys, root)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range dirs {
+		src, err := lastCopy(fsys, d+abs)
+		if err != nil {
+			return "", err
+		}
+		if src == "" {
+			continue
+		}
+		if err := fsys.CreateDirectory(path.Dir(abs)); err != nil {
+			return "", err
+		}
+		if err := fsys.Rename(src, abs); err != nil {
+			return "", err
+		}
+		return src, nil
+	}
+	return "", &PathError{"restore", p, syscall.ENOENT}
+}
+
+// trashDirs returns Current and the checkpoints of the trash root, newest
+// first.
+func trashDirs(fsys FileSystem, root string) ([]string, error) {
+	infos, err := fsys.ListDirectory(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	type cp struct {
+		name string
+		t    time.Time
+	}
+	var current []string
+	var cps []cp
+	for _, info := range infos {
+		name := path.Base(namePath(info.Name))
+		if !info.IsDir() {
+			continue
+		}
+		if name == trashCurrent {
+			current = append(current, root+"/"+name)
+		} else if t, ok := checkpointTime(name); ok {
+			cps = append(cps, cp{name, t})
+		}
+	}
+	sort.Slice(cps, func(i, j int) bool {
+		if !cps[i].t.Equal(cps[j].t) {
+			return cps[i].t.After(cps[j].t)
+		}
+		return cps[i].name > cps[j].name
+	})
+	dirs := current
+	for _, c := range cps {
+		dirs = append(dirs, root+"/"+c.name)
+	}
+	return dirs, nil
+}
+
+// lastCopy returns the copy of p in the trash moved there last: p, or p
+// with the time it was moved at appended, the latest, or "" if there is
+// none.
+func lastCopy(fsys FileSystem, p string) (string, error) {
+	infos, err := fsys.ListDirectory(path.Dir(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	base := path.Base(p)
+	last, lastTime := "", int64(-1)
+	for _, info := range infos {
+		name := path.Base(namePath(info.Name))
+		suffix, ok := strings.CutPrefix(name, base)
+		if !ok {
+			continue
+		}
+		t := int64(0)
+		if suffix != "" {
+			if len(suffix) < 13 {
+				continue
+			}
+			if t, err = strconv.ParseInt(suffix, 10, 64); err != nil {
+				continue
+			}
+		}
+		if t > lastTime {
+			last, lastTime = path.Dir(p)+"/"+name, t
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,131 @@
+package hdfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+)
+
+func TestMoveToTrash(t *testing.T) {
+	m := newTree(t, "/data/a", "/data/dir/x")
+	m.Chmod("/data", 0777)
+	m.CreateDirectory("/user/alice")
+	m.Chown("/user/alice", "alice", "")
+	m.CreateDirectory("/ez")
+	if err := m.CreateEncryptionZone("/ez", "key"); err != nil {
+		t.Fatalf("Error on CreateEncryptionZone: %v\n", err)
+	}
+	if f, err := m.Open("/ez/f", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0); err == nil {
+		f.Close()
+	}
+
+	if p, err := hdfs.MoveToTrash(m, "/data/a"); p != "" || err != nil {
+		t.Errorf("MoveToTrash with the trash disabled: got %q, %v\n", p, err)
+	}
+	if _, err := hdfs.MoveToTrash(struct{ hdfs.FileSystem }{m}, "/data/a"); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("MoveToTrash without a trash: got %v\n", err)
+	}
+	m.SetTrashInterval(time.Hour)
+
+	p, err := hdfs.MoveToTrash(m, "/data/a")
+	if err != nil || p != "/user/hdfs/.Trash/Current/data/a" {
+		t.Fatalf("MoveToTrash: got %q, %v\n", p, err)
+	}
+	if info, err := m.GetPathInfo("/user/hdfs/.Trash/Current/data"); err != nil || info.Permissions != 0700 {
+		t.Errorf("Permissions of the trash: got %v, %v\n", info, err)
+	}
+	if f, err := m.Open("/data/a", hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0); err == nil {
+		f.Close()
+	}
+	second, err := hdfs.MoveToTrash(m, "/data/a")
+	if err != nil || !regexp.MustCompile(`^/user/hdfs/\.Trash/Current/data/a[0-9]{13}$`).MatchString(second) {
+		t.Errorf("MoveToTrash of a name taken: got %q, %v\n", second, err)
+	}
+	if p, err := hdfs.MoveToTrash(m, p); p != "" || err != nil {
+		t.Errorf("MoveToTrash of the trash: got %q, %v\n", p, err)
+	}
+	if _, err := hdfs.MoveToTrash(m, "/user"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("MoveToTrash of a parent of the trash: got %v\n", err)
+	}
+	if _, err := hdfs.MoveToTrash(m, "/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("MoveToTrash of a missing file: got %v\n", err)
+	}
+	if p, err := hdfs.MoveToTrash(m, "/ez/f"); p != "/ez/.Trash/hdfs/Current/ez/f" || err != nil {
+		t.Errorf("MoveToTrash in an encryption zone: got %q, %v\n", p, err)
+	}
+	if p, err := hdfs.MoveToTrash(m.AsUser("alice"), "/data/dir"); p != "/user/alice/.Trash/Current/data/dir" || err != nil {
+		t.Errorf("MoveToTrash by another user: got %q, %v\n", p, err)
+	}
+
+	if src, err := hdfs.RestoreFromTrash(m, "/data/a"); src != second || err != nil {
+		t.Errorf("RestoreFromTrash: got %q, %v, want %q\n", src, err, second)
+	}
+	if _, err := hdfs.RestoreFromTrash(m, "/data/a"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("RestoreFromTrash of an existing file: got %v\n", err)
+	}
+	m.Delete("/data/a")
+	if src, err := hdfs.RestoreFromTrash(m, "/data/a"); src != p || err != nil {
+		t.Errorf("RestoreFromTrash of the first copy: got %q, %v\n", src, err)
+	}
+	if src, err := hdfs.RestoreFromTrash(m, "/ez/f"); src != "/ez/.Trash/hdfs/Current/ez/f" || err != nil {
+		t.Errorf("RestoreFromTrash in an encryption zone: got %q, %v\n", src, err)
+	}
+	if _, err := hdfs.RestoreFromTrash(m, "/data/dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RestoreFromTrash from the trash of another user: got %v\n", err)
+	}
+}
+
+func TestExpunge(t *testing.T) {
+	m := newTree(t, "/data/a", "/data/b")
+	m.SetTrashInterval(time.Hour)
+	old := "/user/hdfs/.Trash/200101000000"
+	m.CreateDirectory(old)
+	if _, err := hdfs.MoveToTrash(m, "/data/a"); err != nil {
+		t.Fatalf("Error on MoveToTrash: %v\n", err)
+	}
+
+	if err := hdfs.Expunge(m); err != nil {
+		t.Fatalf("Error on Expunge: %v\n", err)
+	}
+	infos, err := m.ListDirectory("/user/hdfs/.Trash")
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("Trash after Expunge: got %v, %v\n", infos, err)
+	}
+	checkpoint := path.Base(infos[0].Name)
+	if _, err := time.ParseInLocation("060102150405", checkpoint, time.Local); err != nil {
+		t.Errorf("Checkpoint made by Expunge: got %s\n", checkpoint)
+	}
+
+	if _, err := hdfs.MoveToTrash(m, "/data/b"); err != nil {
+		t.Fatalf("Error on MoveToTrash: %v\n", err)
+	}
+	if err := hdfs.Expunge(m); err != nil {
+		t.Fatalf("Error on Expunge: %v\n", err)
+	}
+	infos, err = m.ListDirectory("/user/hdfs/.Trash")
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("Trash after a second Expunge: got %v, %v\n", infos, err)
+	}
+	newest := path.Base(infos[0].Name)
+	if newest == checkpoint {
+		newest = path.Base(infos[1].Name)
+	}
+	if src, err := hdfs.RestoreFromTrash(m, "/data/a"); src != "/user/hdfs/.Trash/"+checkpoint+"/data/a" || err != nil {
+		t.Errorf("RestoreFromTrash from a checkpoint: got %q, %v\n", src, err)
+	}
+	if src, err := hdfs.RestoreFromTrash(m, "/data/b"); src != "/user/hdfs/.Trash/"+newest+"/data/b" || err != nil {
+		t.Errorf("RestoreFromTrash from the newest checkpoint: got %q, %v\n", src, err)
+	}
+	if _, err := hdfs.RestoreFromTrash(m, "/data/c"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RestoreFromTrash of a file never trashed: got %v\n", err)
+	}
+	if err := hdfs.Expunge(struct{ hdfs.FileSystem }{m}); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Expunge without a trash: got %v\n", err)
+	}
+}
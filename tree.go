@@ -0,0 +1,290 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+)
+
+// TreeOptions controls the recursive operations RemoveAll, CopyTree,
+// ChmodRecursive, ChownRecursive and SetReplicationRecursive. A nil
+// *TreeOptions applies the changes silently.
+type TreeOptions struct {
+	// DryRun walks the tree and reports the changes to Changed without
+	// making them.
+	DryRun bool
+	// Changed, if not nil, is called with the operation ("delete", "mkdir",
+	// "copy", "chmod", "chown" or "setrep") and path of every change made,
+	// or that would be made in dry-run mode. Paths that already match the
+	// requested state are not reported.
+	Changed func(op, path string)
+}
+
+// TreeError aggregates the failures, usually *PathError values, met by a
+// recursive operation on the tree rooted at Path. The operation carries on
+// past them, so everything else has been processed.
+type TreeError struct {
+	Op   string
+	Path string
+	Errs []error
+}
+
+func (e *TreeError) Error() string {
+	if len(e.Errs) == 1 {
+		return e.Op + " " + e.Path + ": " + e.Errs[0].Error()
+	}
+	return fmt.Sprintf("%s %s: %d errors, first: %v", e.Op, e.Path, len(e.Errs), e.Errs[0])
+}
+
+// Unwrap returns Errs, so that errors.Is and errors.As look into each of them.
+func (e *TreeError) Unwrap() []error {
+	return e.Errs
+}
+
+// treeOp carries the options and failures of one recursive operation.
+type treeOp struct {
+	opts *TreeOptions
+	errs []error
+}
+
+func newTreeOp(opts *TreeOptions) *treeOp {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+	return &treeOp{opts: opts}
+}
+
+// change reports a change and makes it with do unless in dry-run mode.
+func (t *treeOp) change(op, path string, do func() error) bool {
+	if !t.opts.DryRun {
+		if err := do(); err != nil {
+			t.errs = append(t.errs, err)
+			return false
+		}
+	}
+	if t.opts.Changed != nil {
+		t.opts.Changed(op, path)
+	}
+	return true
+}
+
+func (t *treeOp) fail(err error) {
+	t.errs = append(t.errs, err)
+}
+
+func (t *treeOp) err(op, path string) error {
+	if len(t.errs) == 0 {
+		return nil
+	}
+	return &TreeError{op, path, t.errs}
+}
+
+// walk calls fn for every path of the tree rooted at root, parents first,
+// recording listing failures rather than stopping.
+func (t *treeOp) walk(fsys FileSystem, root string, fn func(path string, info *FileInfo)) {
+	Walk(fsys, root, func(path string, info *FileInfo, err error) error {
+		if err != nil {
+			t.fail(err)
+			return nil
+		}
+		fn(path, info)
+		return nil
+	})
+}
+
+// RemoveAll removes path and everything below it. It first tries a single
+// recursive Delete; if that fails for another reason than path not existing,
+// it removes the tree bottom-up, one path at a time, so that everything that
+// can be removed is, and returns a *TreeError listing what could not. A
+// missing path is not an error.
+func RemoveAll(fsys FileSystem, path string, opts *TreeOptions) error {
+	t := newTreeOp(opts)
+	if _, err := fsys.GetPathInfo(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if !t.opts.DryRun {
+		err := fsys.Delete(path)
+		if err == nil {
+			if t.opts.Changed != nil {
+				t.opts.Changed("delete", path)
+			}
+			return nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	t.removeAll(fsys, path)
+	return t.err("removeall", path)
+}
+
+func (t *treeOp) removeAll(fsys FileSystem, p string) bool {
+	info, err := fsys.GetPathInfo(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	if err != nil {
+		t.fail(err)
+		return false
+	}
+	if info.IsDir() {
+		infos, err := fsys.ListDirectory(p)
+		if err != nil {
+			t.fail(err)
+			return false
+		}
+		sortInfos(infos)
+		ok := true
+		for _, child := range infos {
+			ok = t.removeAll(fsys, path.Join(p, baseName(child.Name))) && ok
+		}
+		if !ok {
+			return false
+		}
+	}
+	return t.change("delete", p, func() error { return fsys.Delete(p) })
+}
+
+// CopyTree copies the file or directory tree src of srcFS to dst on dstFS,
+// which may be the same file system. As with Copy, an existing directory dst
+// receives a copy named after src, and existing files are overwritten.
+// Failing paths are skipped and listed in the returned *TreeError.
+func CopyTree(srcFS FileSystem, src string, dstFS FileSystem, dst string, opts *TreeOptions) error {
+	t := newTreeOp(opts)
+	info, err := srcFS.GetPathInfo(src)
+	if err != nil {
+		return err
+	}
+	if dinfo, err := dstFS.GetPathInfo(dst); err == nil && dinfo.IsDir() {
+		dst = path.Join(dst, baseName(info.Name))
+	}
+	same, err := sameFS(srcFS, dstFS)
+	if err != nil {
+		return err
+	}
+	if same {
+		asrc, err := absPath(srcFS, src)
+		if err != nil {
+			return err
+		}
+		adst, err := absPath(dstFS, dst)
+		if err != nil {
+			return err
+		}
+		if inDir(adst, asrc) {
+			return &PathError{"copytree", src, errors.New("cannot copy a directory into itself")}
+		}
+	}
+	t.copyTree(srcFS, src, info, dstFS, dst)
+	return t.err("copytree", src)
+}
+
+// sameFS reports whether a and b are the same file system: the same value,
+// or connections to the same cluster, naming the root with the same scheme
+// and authority.
+func sameFS(a, b FileSystem) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	ua, err := fsURI(a)
+	if err != nil {
+		return false, err
+	}
+	ub, err := fsURI(b)
+	if err != nil {
+		return false, err
+	}
+	return ua == ub, nil
+}
+
+// fsURI returns the scheme and authority fsys names its paths with, such as
+// "hdfs://nn:8020".
+func fsURI(fsys FileSystem) (string, error) {
+	info, err := fsys.GetPathInfo("/")
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(info.Name)
+	if err != nil {
+		return "", &PathError{"stat", "/", err}
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func (t *treeOp) copyTree(srcFS FileSystem, src string, info *FileInfo, dstFS FileSystem, dst string) {
+	if !info.IsDir() {
+		t.change("copy", dst, func() error { return copyFile(srcFS, src, dstFS, dst) })
+		return
+	}
+	if !t.change("mkdir", dst, func() error { return dstFS.CreateDirectory(dst) }) {
+		return
+	}
+	infos, err := srcFS.ListDirectory(src)
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	sortInfos(infos)
+	for _, child := range infos {
+		name := baseName(child.Name)
+		t.copyTree(srcFS, path.Join(src, name), child, dstFS, path.Join(dst, name))
+	}
+}
+
+func copyFile(srcFS FileSystem, src string, dstFS FileSystem, dst string) error {
+	r, err := srcFS.Open(src, O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := dstFS.Open(dst, O_WRONLY|O_CREATE, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// ChmodRecursive sets the permission bits of path and everything below it.
+// Failing paths are skipped and listed in the returned *TreeError.
+func ChmodRecursive(fsys FileSystem, path string, mode int16, opts *TreeOptions) error {
+	t := newTreeOp(opts)
+	t.walk(fsys, path, func(p string, info *FileInfo) {
+		if info.Permissions != mode {
+			t.change("chmod", p, func() error { return fsys.Chmod(p, mode) })
+		}
+	})
+	return t.err("chmod", path)
+}
+
+// ChownRecursive sets the owner and group of path and everything below it;
+// an empty owner or group is left unchanged. Failing paths are skipped and
+// listed in the returned *TreeError.
+func ChownRecursive(fsys FileSystem, path, owner, group string, opts *TreeOptions) error {
+	t := newTreeOp(opts)
+	t.walk(fsys, path, func(p string, info *FileInfo) {
+		if owner != "" && info.Owner != owner || group != "" && info.Group != group {
+			t.change("chown", p, func() error { return fsys.Chown(p, owner, group) })
+		}
+	})
+	return t.err("chown", path)
+}
+
+// SetReplicationRecursive sets the replication of path, if it is a file, or
+// of every file below it. Failing paths are skipped and listed in the
+// returned *TreeError.
+func SetReplicationRecursive(fsys FileSystem, path string, replication int16, opts *TreeOptions) error {
+	t := newTreeOp(opts)
+	t.walk(fsys, path, func(p string, info *FileInfo) {
+		if !info.IsDir() && info.Replication != replication {
+			t.change("setrep", p, func() error { return fsys.SetReplication(p, replication) })
+		}
+	})
+	return t.err("setrep", path)
+}
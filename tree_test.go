@@ -0,0 +1,149 @@
+package hdfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+type recorder []string
+
+func (r *recorder) opts(dryRun bool) *hdfs.TreeOptions {
+	*r = nil
+	return &hdfs.TreeOptions{DryRun: dryRun, Changed: func(op, path string) { *r = append(*r, op+" "+path) }}
+}
+
+func TestRemoveAll(t *testing.T) {
+	m := newTree(t, "/t/a/x", "/t/a/y", "/t/locked/z", "/t/b")
+	for _, p := range []string{"/t", "/t/a", "/t/a/x", "/t/a/y", "/t/b"} {
+		m.Chown(p, "alice", "")
+	}
+	alice := m.AsUser("alice")
+
+	var r recorder
+	if err := hdfs.RemoveAll(alice, "/t", r.opts(true)); err != nil {
+		t.Errorf("Error on dry-run removing: %v\n", err)
+	}
+	want := recorder{"delete /t/a/x", "delete /t/a/y", "delete /t/a", "delete /t/b", "delete /t/locked/z", "delete /t/locked", "delete /t"}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("RemoveAll dry-run: got %v, want %v\n", r, want)
+	}
+	if err := alice.Exists("/t/a/x"); err != nil {
+		t.Errorf("RemoveAll dry-run removed /t/a/x: %v\n", err)
+	}
+
+	err := hdfs.RemoveAll(alice, "/t", r.opts(false))
+	var terr *hdfs.TreeError
+	if !errors.As(err, &terr) || len(terr.Errs) != 1 || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("RemoveAll: got %v, want a TreeError with one permission error\n", err)
+	}
+	if want = (recorder{"delete /t/a/x", "delete /t/a/y", "delete /t/a", "delete /t/b"}); !reflect.DeepEqual(r, want) {
+		t.Errorf("RemoveAll: got %v, want %v\n", r, want)
+	}
+	if err := alice.Exists("/t/locked/z"); err != nil {
+		t.Errorf("RemoveAll removed /t/locked/z: %v\n", err)
+	}
+
+	if err := hdfs.RemoveAll(m, "/t", r.opts(false)); err != nil {
+		t.Errorf("Error on removing: %v\n", err)
+	}
+	if want = (recorder{"delete /t"}); !reflect.DeepEqual(r, want) {
+		t.Errorf("RemoveAll: got %v, want %v\n", r, want)
+	}
+	if err := hdfs.RemoveAll(m, "/t", nil); err != nil {
+		t.Errorf("Error on removing a missing path: %v\n", err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src := newTree(t, "/s/d/x", "/s/y")
+	file, _ := src.Open("/s/d/x", hdfs.O_WRONLY, 0, 0, 0)
+	file.Write([]byte("hello"))
+	file.Close()
+	dst := newTree(t, "/backup/keep")
+
+	var r recorder
+	if err := hdfs.CopyTree(src, "/s", dst, "/backup", r.opts(true)); err != nil {
+		t.Errorf("Error on dry-run copying: %v\n", err)
+	}
+	want := recorder{"mkdir /backup/s", "mkdir /backup/s/d", "copy /backup/s/d/x", "copy /backup/s/y"}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("CopyTree dry-run: got %v, want %v\n", r, want)
+	}
+	if err := dst.Exists("/backup/s"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CopyTree dry-run created /backup/s: %v\n", err)
+	}
+
+	if err := hdfs.CopyTree(src, "/s", dst, "/backup", r.opts(false)); err != nil {
+		t.Errorf("Error on copying: %v\n", err)
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("CopyTree: got %v, want %v\n", r, want)
+	}
+	if info, err := dst.GetPathInfo("/backup/s/d/x"); err != nil || info.Size != 5 {
+		t.Errorf("CopyTree: got %v, %v for /backup/s/d/x\n", info, err)
+	}
+
+	if err := hdfs.CopyTree(src, "/s", src, "/s/d", nil); err == nil {
+		t.Errorf("CopyTree into itself succeeded\n")
+	}
+	if err := hdfs.CopyTree(src, "/s", src.AsUser(hdfstest.Superuser), "/s/d", nil); err == nil {
+		t.Errorf("CopyTree into itself over another connection succeeded\n")
+	}
+	src.SetWorkingDirectory("/")
+	if err := hdfs.CopyTree(src, "s", src, "/s/d", nil); err == nil {
+		t.Errorf("CopyTree of a relative path into itself succeeded\n")
+	}
+	if err := hdfs.CopyTree(src, "/missing", dst, "/x", nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CopyTree from a missing path: got %v\n", err)
+	}
+}
+
+func TestChangeRecursive(t *testing.T) {
+	m := newTree(t, "/c/a/x", "/c/y")
+	m.Chmod("/c/y", 0600)
+	m.Chown("/c/a", "bob", "")
+
+	var r recorder
+	if err := hdfs.ChmodRecursive(m, "/c", 0600, r.opts(true)); err != nil {
+		t.Errorf("Error on dry-run chmod: %v\n", err)
+	}
+	want := recorder{"chmod /c", "chmod /c/a", "chmod /c/a/x"}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("ChmodRecursive dry-run: got %v, want %v\n", r, want)
+	}
+	if info, _ := m.GetPathInfo("/c/a/x"); info.Permissions == 0600 {
+		t.Errorf("ChmodRecursive dry-run changed /c/a/x\n")
+	}
+
+	if err := hdfs.ChownRecursive(m, "/c", "bob", "", r.opts(false)); err != nil {
+		t.Errorf("Error on chown: %v\n", err)
+	}
+	if want = (recorder{"chown /c", "chown /c/a/x", "chown /c/y"}); !reflect.DeepEqual(r, want) {
+		t.Errorf("ChownRecursive: got %v, want %v\n", r, want)
+	}
+
+	bob := m.AsUser("bob")
+	m.Chown("/c/y", "hdfs", "")
+	err := hdfs.ChmodRecursive(bob, "/c", 0700, r.opts(false))
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("ChmodRecursive: got %v, want a permission error\n", err)
+	}
+	if want = (recorder{"chmod /c", "chmod /c/a", "chmod /c/a/x"}); !reflect.DeepEqual(r, want) {
+		t.Errorf("ChmodRecursive: got %v, want %v\n", r, want)
+	}
+
+	if err := hdfs.SetReplicationRecursive(m, "/c", 2, r.opts(false)); err != nil {
+		t.Errorf("Error on setrep: %v\n", err)
+	}
+	if want = (recorder{"setrep /c/a/x", "setrep /c/y"}); !reflect.DeepEqual(r, want) {
+		t.Errorf("SetReplicationRecursive: got %v, want %v\n", r, want)
+	}
+	if info, _ := m.GetPathInfo("/c/y"); info.Replication != 2 {
+		t.Errorf("SetReplicationRecursive: got replication %d for /c/y\n", info.Replication)
+	}
+}
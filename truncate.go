@@ -0,0 +1,77 @@
+package hdfs
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// Delays between the checks that a truncated file is closed again.
+const (
+	minTruncatePoll = 10 * time.Millisecond
+	maxTruncatePoll = time.Second
+)
+
+// Truncate cuts the file path to newLength, which must not be more than its
+// size. Unless newLength is on a block boundary, the namenode recovers the
+// last block in the background; Truncate then waits until the file is
+// closed again, which it asks the FileSystems implementing FileCloseChecker
+// and, as hadoop fs -truncate -w does, infers from the size of the file on
+// the others. FileSystems that are not a Truncater fail with ErrUnsupported.
+// The wait has no bound: a recovery that never ends, as when the datanodes
+// holding the block are dead, keeps Truncate waiting; TruncateContext gives
+// up when its context is done.
+func Truncate(fsys FileSystem, path string, newLength int64) error {
+	return TruncateContext(context.Background(), fsys, path, newLength)
+}
+
+// TruncateContext is Truncate with a context, returning ctx.Err() if ctx is
+// done before the file is closed again. The file stays truncated then, and
+// its recovery goes on.
+func TruncateContext(ctx context.Context, fsys FileSystem, path string, newLength int64) error {
+	t, ok := fsys.(Truncater)
+	if !ok {
+		return &PathError{"truncate", path, ErrUnsupported}
+	}
+	if newLength < 0 {
+		return &PathError{"truncate", path, syscall.EINVAL}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done, err := t.TruncateFile(path, newLength)
+	if err != nil || done {
+		return err
+	}
+	for delay := minTruncatePoll; ; delay *= 2 {
+		if closed, err := truncated(fsys, path, newLength); err != nil || closed {
+			return err
+		}
+		if delay > maxTruncatePoll {
+			delay = maxTruncatePoll
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// truncated reports whether the recovery of the file path, truncated to
+// newLength, is over.
+func truncated(fsys FileSystem, path string, newLength int64) (bool, error) {
+	if c, ok := fsys.(FileCloseChecker); ok {
+		if closed, err := c.IsFileClosed(path); !errors.Is(err, ErrUnsupported) {
+			return closed, err
+		}
+	}
+	info, err := fsys.GetPathInfo(path)
+	if err != nil {
+		return false, err
+	}
+	return info.Size == newLength, nil
+}
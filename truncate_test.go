@@ -0,0 +1,112 @@
+package hdfs_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+// put creates the file path holding data, of the given block size or the
+// default one if 0.
+func put(t *testing.T, fsys hdfs.FileSystem, path, data string, blockSize uint32) {
+	f, err := fsys.Open(path, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, blockSize)
+	if err != nil {
+		t.Fatalf("Error on creating %s: %v\n", path, err)
+	}
+	f.Write([]byte(data))
+	if err := f.Close(); err != nil {
+		t.Fatalf("Error on closing %s: %v\n", path, err)
+	}
+}
+
+// recovering is a MemFS whose files stay under recovery for a number of
+// IsFileClosed calls after being truncated.
+type recovering struct {
+	*hdfstest.MemFS
+	polls int
+}
+
+func (r *recovering) IsFileClosed(path string) (bool, error) {
+	if r.polls > 0 {
+		r.polls--
+		return false, nil
+	}
+	return r.MemFS.IsFileClosed(path)
+}
+
+func TestTruncate(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	m.SetDefaults(4, 1)
+	// on a block boundary, done at once
+	put(t, m, "/file", "0123456789", 0)
+	if done, err := m.TruncateFile("/file", 8); err != nil || !done {
+		t.Errorf("TruncateFile on a block boundary: got %v, %v\n", done, err)
+	}
+	if got := seen(t, m, "/file"); got != "01234567" {
+		t.Errorf("After truncating to 8: got %q\n", got)
+	}
+	if done, err := m.TruncateFile("/file", 6); err != nil || done {
+		t.Errorf("TruncateFile within a block: got %v, %v\n", done, err)
+	}
+
+	// waiting for the recovery, asking the file system or checking the size
+	r := &recovering{MemFS: m, polls: 3}
+	if err := hdfs.Truncate(r, "/file", 5); err != nil {
+		t.Errorf("Error on Truncate: %v\n", err)
+	}
+	if r.polls != 0 {
+		t.Errorf("Truncate returned with %d polls left\n", r.polls)
+	}
+	put(t, m, "/stuck", "0123456789", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	stuck := &recovering{MemFS: m, polls: 1 << 30}
+	if err := hdfs.TruncateContext(ctx, stuck, "/stuck", 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TruncateContext of a recovery that never ends: got %v\n", err)
+	}
+	if err := hdfs.Truncate(struct {
+		hdfs.FileSystem
+		hdfs.Truncater
+	}{m, m}, "/file", 3); err != nil {
+		t.Errorf("Error on Truncate without IsFileClosed: %v\n", err)
+	}
+	if got := seen(t, m, "/file"); got != "012" {
+		t.Errorf("After Truncate: got %q\n", got)
+	}
+
+	// appending after truncation
+	w, err := m.Open("/file", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on appending: %v\n", err)
+	}
+	w.Write([]byte("xyz"))
+	if err := w.Close(); err != nil {
+		t.Errorf("Error on closing: %v\n", err)
+	}
+	if got := seen(t, m, "/file"); got != "012xyz" {
+		t.Errorf("After appending: got %q\n", got)
+	}
+
+	for _, tt := range []struct {
+		fsys   hdfs.FileSystem
+		path   string
+		length int64
+		want   error
+	}{
+		{m, "/file", 7, syscall.EINVAL},
+		{m, "/file", -1, syscall.EINVAL},
+		{m, "/missing", 0, syscall.ENOENT},
+		{m, "/", 0, hdfs.ErrInternal},
+		{m.AsUser("other"), "/file", 0, syscall.EACCES},
+		{struct{ hdfs.FileSystem }{m}, "/file", 0, hdfs.ErrUnsupported},
+	} {
+		if err := hdfs.Truncate(tt.fsys, tt.path, tt.length); !errors.Is(err, tt.want) {
+			t.Errorf("Truncate(%s, %d): got %v, want %v\n", tt.path, tt.length, err, tt.want)
+		}
+	}
+}
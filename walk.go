@@ -0,0 +1,170 @@
+package hdfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// WalkFunc is the type of the function called by Walk for each file or
+// directory, with the same contract as filepath.WalkFunc: path is root joined
+// with the names leading to the file, and returning fs.SkipDir (the same
+// value as filepath.SkipDir) skips a directory, or the rest of the directory
+// holding a file, while fs.SkipAll stops the walk.
+type WalkFunc func(path string, info *FileInfo, err error) error
+
+// Walk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, in lexical order. Errors from
+// GetPathInfo on root or from listing a directory are passed to fn.
+func Walk(fsys FileSystem, root string, fn WalkFunc) error {
+	info, err := fsys.GetPathInfo(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fsys, root, info, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walk(fsys FileSystem, p string, info *FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(p, info, nil)
+	}
+	infos, err := fsys.ListDirectory(p)
+	err1 := fn(p, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+	sortInfos(infos)
+	for _, child := range infos {
+		err = walk(fsys, path.Join(p, baseName(child.Name)), child, fn)
+		if err != nil {
+			if !child.IsDir() && err == fs.SkipDir {
+				return nil
+			}
+			if child.IsDir() && err == fs.SkipDir {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func sortInfos(infos []*FileInfo) {
+	sort.Slice(infos, func(i, j int) bool { return baseName(infos[i].Name) < baseName(infos[j].Name) })
+}
+
+// WalkResult is a file or directory found by WalkParallel, or the error met
+// getting information about root or listing the directory Path.
+type WalkResult struct {
+	Path string
+	Info *FileInfo
+	Err  error
+}
+
+// WalkParallel walks the file tree rooted at root like Walk, but lists up to
+// workers directories at a time and sends what it finds, in no particular
+// order, on the returned channel. The channel is closed once the walk is
+// over. Directories for which skip, if not nil, returns true are sent but not
+// descended into; skip may be called concurrently. Closing done stops the
+// walk early; the channel must be drained until closed otherwise.
+func WalkParallel(fsys FileSystem, root string, workers int, skip func(path string, info *FileInfo) bool, done <-chan struct{}) <-chan WalkResult {
+	if workers < 1 {
+		workers = 1
+	}
+	w := &walker{fsys: fsys, skip: skip, done: done, results: make(chan WalkResult, workers)}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run(root, workers)
+	return w.results
+}
+
+type walker struct {
+	fsys    FileSystem
+	skip    func(path string, info *FileInfo) bool
+	done    <-chan struct{}
+	results chan WalkResult
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string // directories to list
+	pending int      // directories queued or being listed
+	stopped bool
+}
+
+func (w *walker) run(root string, workers int) {
+	defer close(w.results)
+	info, err := w.fsys.GetPathInfo(root)
+	if !w.send(WalkResult{root, info, err}) || err != nil || !w.descend(root, info) {
+		return
+	}
+	w.queue, w.pending = []string{root}, 1
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *walker) descend(p string, info *FileInfo) bool {
+	return info.IsDir() && (w.skip == nil || !w.skip(p, info))
+}
+
+// send delivers r, reporting false if the walk was stopped.
+func (w *walker) send(r WalkResult) bool {
+	select {
+	case w.results <- r:
+		return true
+	case <-w.done:
+		w.mu.Lock()
+		w.stopped = true
+		w.cond.Broadcast()
+		w.mu.Unlock()
+		return false
+	}
+}
+
+func (w *walker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.pending > 0 && !w.stopped {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 || w.stopped {
+			w.mu.Unlock()
+			return
+		}
+		dir := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.mu.Unlock()
+
+		var subdirs []string
+		infos, err := w.fsys.ListDirectory(dir)
+		if err != nil {
+			w.send(WalkResult{dir, nil, err})
+		}
+		for _, info := range infos {
+			p := path.Join(dir, baseName(info.Name))
+			if !w.send(WalkResult{p, info, nil}) {
+				break
+			}
+			if w.descend(p, info) {
+				subdirs = append(subdirs, p)
+			}
+		}
+
+		w.mu.Lock()
+		w.queue = append(w.queue, subdirs...)
+		w.pending += len(subdirs) - 1
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
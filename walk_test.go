@@ -0,0 +1,106 @@
+package hdfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func newTree(t *testing.T, files ...string) *hdfstest.MemFS {
+	m := hdfstest.NewMemFS()
+	for _, name := range files {
+		file, err := m.Open(name, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+		if err != nil {
+			t.Fatalf("Error on creating %s: %v\n", name, err)
+		}
+		file.Close()
+	}
+	return m
+}
+
+func TestWalk(t *testing.T) {
+	m := newTree(t, "/w/b/x", "/w/a/y", "/w/a/z", "/w/c", "/w/skip/deep/file")
+
+	var got []string
+	err := hdfs.Walk(m, "/w", func(path string, info *hdfs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		if path == "/w/skip" {
+			return fs.SkipDir
+		}
+		if path == "/w/a/y" {
+			return fs.SkipDir // skips the rest of /w/a
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Error on walking: %v\n", err)
+	}
+	want := []string{"/w", "/w/a", "/w/a/y", "/w/b", "/w/b/x", "/w/c", "/w/skip"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk: got %v, want %v\n", got, want)
+	}
+
+	got = nil
+	hdfs.Walk(m, "/w", func(path string, info *hdfs.FileInfo, err error) error {
+		got = append(got, path)
+		if path == "/w/b" {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if want = []string{"/w", "/w/a", "/w/a/y", "/w/a/z", "/w/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk with SkipAll: got %v, want %v\n", got, want)
+	}
+
+	err = hdfs.Walk(m, "/missing", func(path string, info *hdfs.FileInfo, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Walking missing root: got %v, want ErrNotExist\n", err)
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	var files []string
+	for _, d := range []string{"a", "b", "c", "d"} {
+		for _, f := range []string{"1", "2", "3"} {
+			files = append(files, "/p/"+d+"/"+d+f+"/file")
+		}
+	}
+	m := newTree(t, append(files, "/p/skip/hidden")...)
+
+	skip := func(path string, info *hdfs.FileInfo) bool { return path == "/p/skip" }
+	var got []string
+	for r := range hdfs.WalkParallel(m, "/p", 3, skip, nil) {
+		if r.Err != nil {
+			t.Errorf("Error on walking %s: %v\n", r.Path, r.Err)
+			continue
+		}
+		if !r.Info.IsDir() {
+			got = append(got, r.Path)
+		}
+		if r.Path == "/p/skip/hidden" {
+			t.Errorf("Walked into skipped directory\n")
+		}
+	}
+	sort.Strings(got)
+	sort.Strings(files)
+	if !reflect.DeepEqual(got, files) {
+		t.Errorf("WalkParallel: got %v, want %v\n", got, files)
+	}
+
+	done := make(chan struct{})
+	results := hdfs.WalkParallel(m, "/p", 2, nil, done)
+	<-results
+	close(done)
+	for range results {
+	}
+}
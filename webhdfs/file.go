@@ -0,0 +1,437 @@
+package webhdfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/zyxar/hdfs"
+)
+
+// File is a file of an FS opened for reading or writing. A reader streams
+// the data from the datanodes with OPEN requests, issuing a new one after a
+// Seek; a writer streams it in the body of a CREATE or APPEND request,
+// which Flush ends, so data written afterwards goes to a new APPEND.
+type File struct {
+	fs    *FS
+	name  string
+	abs   string
+	write bool
+
+	mu     sync.Mutex
+	closed bool
+
+	size int64         // length of the file when last looked up
+	pos  int64         // offset of the next Read
+	body io.ReadCloser // response of the OPEN request at pos, if any
+
+	up      *upload // request sending written data, if any
+	written int64
+	err     error // failure of a previous upload
+}
+
+var (
+	_ hdfs.FileHandle = (*File)(nil)
+	_ hdfs.Syncer     = (*File)(nil)
+)
+
+// upload is a CREATE or APPEND request sending the data written to a pipe.
+type upload struct {
+	w    *io.PipeWriter
+	done chan error
+}
+
+// Open opens path for reading or writing. As with libhdfs, O_WRONLY creates
+// or truncates the file, O_WRONLY|O_APPEND appends to an existing file, and
+// O_RDWR or O_CREATE|O_EXCL fail with hdfs.ErrUnsupported. A buffersize,
+// replication or blocksize of 0 means the server default.
+func (fs *FS) Open(path string, flags int, buffersize int, replication int, blocksize uint32) (hdfs.FileHandle, error) {
+	f, err := fs.OpenFile(path, flags, buffersize, replication, blocksize)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFile is Open returning the *File.
+func (fs *FS) OpenFile(path string, flags int, buffersize int, replication int, blocksize uint32) (*File, error) {
+	if flags&syscall.O_RDWR != 0 || flags&(syscall.O_EXCL|syscall.O_CREAT) == syscall.O_EXCL|syscall.O_CREAT {
+		return nil, &hdfs.PathError{Op: "open", Path: path, Err: hdfs.ErrUnsupported}
+	}
+	f := &File{fs: fs, name: path, abs: fs.abs(path), write: flags&hdfs.O_WRONLY != 0}
+	if !f.write {
+		st, err := fs.stat("open", path)
+		if err != nil {
+			return nil, err
+		}
+		if st.Type == "DIRECTORY" {
+			return nil, &hdfs.PathError{Op: "open", Path: path, Err: hdfs.ErrInternal}
+		}
+		f.size = st.Length
+		return f, nil
+	}
+	params := url.Values{}
+	if buffersize > 0 {
+		params.Set("buffersize", strconv.Itoa(buffersize))
+	}
+	var err error
+	if flags&hdfs.O_APPEND != 0 {
+		st, err := fs.stat("open", path)
+		if err != nil {
+			return nil, err
+		}
+		f.written = st.Length
+		f.up, err = fs.startUpload("open", path, http.MethodPost, "APPEND", params)
+	} else {
+		params.Set("overwrite", "true")
+		if replication > 0 {
+			params.Set("replication", strconv.Itoa(replication))
+		}
+		if blocksize > 0 {
+			params.Set("blocksize", strconv.FormatUint(uint64(blocksize), 10))
+		}
+		f.up, err = fs.startUpload("open", path, http.MethodPut, "CREATE", params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// startUpload makes the two steps of a CREATE or APPEND: the namenode
+// redirects the request to a datanode, to which the data is then sent.
+func (fs *FS) startUpload(op, p, method, webop string, params url.Values) (*upload, error) {
+	req, err := http.NewRequest(method, fs.url(webop, fs.abs(p), params), nil)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: err}
+	}
+	resp, err := fs.send(fs.noRedirect, op, p, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusTemporaryRedirect || loc == "" {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: fmt.Errorf("%w: %s without a redirect", hdfs.ErrInternal, resp.Status)}
+	}
+	target, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	pr, pw := io.Pipe()
+	req, err = http.NewRequest(method, target.String(), pr)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	up := &upload{w: pw, done: make(chan error, 1)}
+	go func() {
+		resp, err := fs.noRedirect.Do(req)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", hdfs.ErrInternal, err)
+		} else {
+			if resp.StatusCode >= http.StatusMultipleChoices {
+				err = responseError(resp)
+			}
+			resp.Body.Close()
+		}
+		pr.CloseWithError(err)
+		up.done <- err
+	}()
+	return up, nil
+}
+
+// finish ends the upload and waits for its response.
+func (up *upload) finish() error {
+	up.w.Close()
+	return <-up.done
+}
+
+// Name returns the path the file was opened with.
+func (f *File) Name() string {
+	return f.name
+}
+
+// begin locks f for an operation, failing if f was closed.
+func (f *File) begin() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return os.ErrClosed
+	}
+	return nil
+}
+
+// refresh looks up the current length of the file.
+func (f *File) refresh(op string) error {
+	st, err := f.fs.stat(op, f.abs)
+	if err != nil {
+		return &hdfs.PathError{Op: op, Path: f.name, Err: err.(*hdfs.PathError).Err}
+	}
+	f.size = st.Length
+	return nil
+}
+
+// open returns the data of the file from off, at most length bytes unless
+// length is negative.
+func (f *File) open(op string, off, length int64) (io.ReadCloser, error) {
+	params := url.Values{"offset": {strconv.FormatInt(off, 10)}}
+	if length >= 0 {
+		params.Set("length", strconv.FormatInt(length, 10))
+	}
+	req, err := http.NewRequest(http.MethodGet, f.fs.url("OPEN", f.abs, params), nil)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	resp, err := f.fs.send(f.fs.client, op, f.name, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// closeBody drops the response of the current OPEN request.
+func (f *File) closeBody() {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+}
+
+// Read reads up to len(b) bytes from the file into b, advancing the file
+// offset. It returns io.EOF at the end of the file.
+func (f *File) Read(b []byte) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.mu.Unlock()
+	if f.write {
+		return 0, &hdfs.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if f.body == nil {
+		if f.pos >= f.size {
+			if err := f.refresh("read"); err != nil {
+				return 0, err
+			}
+			if f.pos >= f.size {
+				return 0, io.EOF
+			}
+		}
+		body, err := f.open("read", f.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(b)
+	f.pos += int64(n)
+	if err == io.EOF {
+		f.closeBody()
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+	if err != nil {
+		f.closeBody()
+		if n > 0 {
+			return n, nil
+		}
+		return 0, &hdfs.PathError{Op: "read", Path: f.name, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	return n, nil
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off,
+// without moving the file offset. It returns io.EOF when the file ends
+// before b is full.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.mu.Unlock()
+	if f.write {
+		return 0, &hdfs.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if off < 0 {
+		return 0, &hdfs.PathError{Op: "read", Path: f.name, Err: hdfs.ErrInternal}
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if off >= f.size {
+		if err := f.refresh("read"); err != nil {
+			return 0, err
+		}
+		if off >= f.size {
+			return 0, io.EOF
+		}
+	}
+	body, err := f.open("read", off, int64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, b)
+	switch err {
+	case nil:
+		return n, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		return n, io.EOF
+	}
+	return n, &hdfs.PathError{Op: "read", Path: f.name, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+}
+
+// Seek sets the offset of the next Read; the end of the file is its current
+// length. On a writer only Seek(0, io.SeekCurrent) is allowed.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.mu.Unlock()
+	if whence == io.SeekCurrent && offset == 0 {
+		if f.write {
+			return f.written, nil
+		}
+		return f.pos, nil
+	}
+	if f.write {
+		return 0, &hdfs.PathError{Op: "seek", Path: f.name, Err: syscall.EBADF}
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.pos + offset
+	case io.SeekEnd:
+		if err := f.refresh("seek"); err != nil {
+			return 0, err
+		}
+		abs = f.size + offset
+	default:
+		return 0, &hdfs.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	if abs > f.size {
+		if err := f.refresh("seek"); err != nil {
+			return 0, err
+		}
+	}
+	if abs < 0 || abs > f.size {
+		return 0, &hdfs.PathError{Op: "seek", Path: f.name, Err: hdfs.ErrInternal}
+	}
+	if abs != f.pos {
+		f.closeBody()
+		f.pos = abs
+	}
+	return abs, nil
+}
+
+// Write writes b to the file, appending it to the request of the current
+// upload, or of a new APPEND after a Flush.
+func (f *File) Write(b []byte) (int, error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	defer f.mu.Unlock()
+	if !f.write {
+		return 0, &hdfs.PathError{Op: "write", Path: f.name, Err: syscall.EINVAL}
+	}
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if f.up == nil {
+		up, err := f.fs.startUpload("write", f.abs, http.MethodPost, "APPEND", nil)
+		if err != nil {
+			f.err = &hdfs.PathError{Op: "write", Path: f.name, Err: err.(*hdfs.PathError).Err}
+			return 0, f.err
+		}
+		f.up = up
+	}
+	n, err := f.up.w.Write(b)
+	f.written += int64(n)
+	if err != nil {
+		f.up = nil
+		f.err = &hdfs.PathError{Op: "write", Path: f.name, Err: err}
+		return n, f.err
+	}
+	return n, nil
+}
+
+// end finishes the current upload, if any, recording its failure.
+func (f *File) end(op string) error {
+	if f.up == nil {
+		return f.err
+	}
+	err := f.up.finish()
+	f.up = nil
+	if err != nil {
+		f.err = &hdfs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	return f.err
+}
+
+// Flush ends the request carrying the data written so far, making it
+// visible to readers once the datanodes acknowledge it.
+func (f *File) Flush() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+	if !f.write {
+		return &hdfs.PathError{Op: "flush", Path: f.name, Err: syscall.EBADF}
+	}
+	return f.end("flush")
+}
+
+// Hflush is Flush: the datanode ending the request closes the file, whose
+// length readers then see.
+func (f *File) Hflush() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+	if !f.write {
+		return &hdfs.PathError{Op: "hflush", Path: f.name, Err: syscall.EBADF}
+	}
+	return f.end("hflush")
+}
+
+// Hsync fails with hdfs.ErrUnsupported, leaving the data written so far
+// unflushed: WebHDFS cannot have the datanodes persist the data to disk.
+// Flush makes it visible.
+func (f *File) Hsync() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+	if !f.write {
+		return &hdfs.PathError{Op: "hsync", Path: f.name, Err: syscall.EBADF}
+	}
+	return &hdfs.PathError{Op: "hsync", Path: f.name, Err: hdfs.ErrUnsupported}
+}
+
+// Close closes the file, ending the upload of a writer.
+func (f *File) Close() error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+	f.closed = true
+	if !f.write {
+		f.closeBody()
+		return nil
+	}
+	return f.end("close")
+}
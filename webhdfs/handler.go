@@ -0,0 +1,1141 @@
+package webhdfs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/internal/exception"
+)
+
+// defaultListLimit is the number of entries of a LISTSTATUS_BATCH response,
+// as dfs.ls.limit defaults to.
+const defaultListLimit = 1000
+
+// defaultMaxConns is the number of connections a Handler keeps by default.
+const defaultMaxConns = 64
+
+// Handler serves the WebHDFS REST API under /webhdfs/v1 from hdfs.FileSystem
+// connections, as an HttpFS gateway does: data is read and written by the
+// handler itself, without redirecting clients to datanodes. Requests are
+// made as the user in their user.name parameter, with the pseudo
+// authentication of WebHDFS: the name is trusted, so a Handler should only
+// be reachable by the users it serves.
+type Handler struct {
+	// ListLimit is the number of entries per LISTSTATUS_BATCH response, 1000
+	// if 0. The directory is listed anew for every batch.
+	ListLimit int
+	// MaxConns is the number of connections kept, 64 if 0. Beyond it, the
+	// least recently used connections not serving a request are
+	// disconnected.
+	MaxConns int
+
+	connect func(user string) (hdfs.FileSystem, error)
+
+	mu     sync.Mutex
+	conns  map[string]*conn
+	closed bool
+}
+
+// conn is the connection of a user.
+type conn struct {
+	fsys   hdfs.FileSystem
+	active int       // requests being served with it
+	used   time.Time // when its last request ended
+}
+
+// NewHandler returns a Handler serving the file systems returned by
+// connect, called for every user making requests, "" for requests without
+// a user.name, and again once their connection was disconnected. Up to
+// MaxConns connections are kept until Close.
+func NewHandler(connect func(user string) (hdfs.FileSystem, error)) *Handler {
+	return &Handler{connect: connect, conns: map[string]*conn{}}
+}
+
+// Close disconnects the connections of h; later requests fail.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var errs []error
+	for user, c := range h.conns {
+		if err := c.fsys.Disconnect(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(h.conns, user)
+	}
+	h.closed = true
+	return errors.Join(errs...)
+}
+
+// fileSystem returns the connection of user, to be released once the
+// request is served.
+func (h *Handler) fileSystem(user string) (hdfs.FileSystem, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, &hdfs.PathError{Op: "connect", Path: user, Err: errors.New("webhdfs: handler closed")}
+	}
+	c := h.conns[user]
+	if c == nil {
+		fsys, err := h.connect(user)
+		if err != nil {
+			return nil, err
+		}
+		c = &conn{fsys: fsys}
+		h.conns[user] = c
+	}
+	c.active++
+	return c.fsys, nil
+}
+
+// release ends a request made with the connection of user, disconnecting
+// the least recently used idle connections beyond MaxConns.
+func (h *Handler) release(user string) {
+	h.mu.Lock()
+	if c := h.conns[user]; c != nil {
+		c.active--
+		c.used = time.Now()
+	}
+	max := h.MaxConns
+	if max <= 0 {
+		max = defaultMaxConns
+	}
+	var idle []string
+	for u, c := range h.conns {
+		if c.active == 0 {
+			idle = append(idle, u)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return h.conns[idle[i]].used.Before(h.conns[idle[j]].used) })
+	var evicted []hdfs.FileSystem
+	for _, u := range idle {
+		if len(h.conns) <= max {
+			break
+		}
+		evicted = append(evicted, h.conns[u].fsys)
+		delete(h.conns, u)
+	}
+	h.mu.Unlock()
+	for _, fsys := range evicted {
+		fsys.Disconnect()
+	}
+}
+
+// request is a request for an operation on path, made with fs.
+type request struct {
+	w    http.ResponseWriter
+	r    *http.Request
+	h    *Handler
+	fs   hdfs.FileSystem
+	path string
+	q    url.Values
+}
+
+var ops = map[string]struct {
+	method string
+	serve  func(*request) error
+}{
+	"OPEN":                  {http.MethodGet, (*request).open},
+	"GETFILESTATUS":         {http.MethodGet, (*request).getFileStatus},
+	"LISTSTATUS":            {http.MethodGet, (*request).listStatus},
+	"LISTSTATUS_BATCH":      {http.MethodGet, (*request).listStatus},
+	"GETCONTENTSUMMARY":     {http.MethodGet, (*request).getContentSummary},
+	"GETFILECHECKSUM":       {http.MethodGet, (*request).getFileChecksum},
+	"GETFILEBLOCKLOCATIONS": {http.MethodGet, (*request).getFileBlockLocations},
+	"GETHOMEDIRECTORY":      {http.MethodGet, (*request).getHomeDirectory},
+	"GETTRASHROOT":          {http.MethodGet, (*request).getTrashRoot},
+	"GETSERVERDEFAULTS":     {http.MethodGet, (*request).getServerDefaults},
+	"GETSTATUS":             {http.MethodGet, (*request).getStatus},
+	"GETXATTRS":             {http.MethodGet, (*request).getXAttrs},
+	"LISTXATTRS":            {http.MethodGet, (*request).listXAttrs},
+	"GETACLSTATUS":          {http.MethodGet, (*request).getAclStatus},
+
+	"GETSNAPSHOTDIFF":               {http.MethodGet, (*request).getSnapshotDiff},
+	"GETSNAPSHOTTABLEDIRECTORYLIST": {http.MethodGet, (*request).getSnapshottableDirList},
+
+	"CREATE":           {http.MethodPut, (*request).create},
+	"MKDIRS":           {http.MethodPut, (*request).mkdirs},
+	"RENAME":           {http.MethodPut, (*request).rename},
+	"SETPERMISSION":    {http.MethodPut, (*request).setPermission},
+	"SETOWNER":         {http.MethodPut, (*request).setOwner},
+	"SETREPLICATION":   {http.MethodPut, (*request).setReplication},
+	"SETTIMES":         {http.MethodPut, (*request).setTimes},
+	"SETXATTR":         {http.MethodPut, (*request).setXAttr},
+	"REMOVEXATTR":      {http.MethodPut, (*request).removeXAttr},
+	"SETACL":           {http.MethodPut, (*request).setAcl},
+	"MODIFYACLENTRIES": {http.MethodPut, (*request).modifyAclEntries},
+	"REMOVEACLENTRIES": {http.MethodPut, (*request).removeAclEntries},
+	"REMOVEDEFAULTACL": {http.MethodPut, (*request).removeDefaultAcl},
+	"REMOVEACL":        {http.MethodPut, (*request).removeAcl},
+
+	"ALLOWSNAPSHOT":    {http.MethodPut, (*request).allowSnapshot},
+	"DISALLOWSNAPSHOT": {http.MethodPut, (*request).disallowSnapshot},
+	"CREATESNAPSHOT":   {http.MethodPut, (*request).createSnapshot},
+	"RENAMESNAPSHOT":   {http.MethodPut, (*request).renameSnapshot},
+
+	"SETQUOTA":              {http.MethodPut, (*request).setQuota},
+	"SETQUOTABYSTORAGETYPE": {http.MethodPut, (*request).setQuotaByStorageType},
+
+	"APPEND":   {http.MethodPost, (*request).create},
+	"TRUNCATE": {http.MethodPost, (*request).truncate},
+	"CONCAT":   {http.MethodPost, (*request).concat},
+	"DELETE":   {http.MethodDelete, (*request).delete},
+
+	"DELETESNAPSHOT": {http.MethodDelete, (*request).deleteSnapshot},
+}
+
+// paramError reports an invalid request parameter, thrown by WebHDFS as an
+// IllegalArgumentException.
+type paramError struct {
+	name, value string
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("Invalid value for webhdfs parameter %q: %q", e.name, e.value)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != apiPrefix && !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	op, ok := ops[strings.ToUpper(q.Get("op"))]
+	if !ok {
+		writeError(w, &paramError{"op", q.Get("op")})
+		return
+	}
+	if r.Method != op.method {
+		writeError(w, &paramError{"op", q.Get("op") + " with " + r.Method})
+		return
+	}
+	user := q.Get("user.name")
+	fsys, err := h.fileSystem(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer h.release(user)
+	req := &request{w: w, r: r, h: h, fs: fsys, path: path.Clean("/" + strings.TrimPrefix(r.URL.Path, apiPrefix)), q: q}
+	if err := op.serve(req); err != nil {
+		writeError(w, err)
+	}
+}
+
+// writeError reports err as the RemoteException WebHDFS throws for it, with
+// the status code it uses.
+func writeError(w http.ResponseWriter, err error) {
+	class, msg := "java.io.IOException", err.Error()
+	var pe *paramError
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &pe):
+		class = "java.lang.IllegalArgumentException"
+	case errors.Is(err, hdfs.ErrUnsupported):
+		class = exception.Class(syscall.ENOTSUP)
+	case errors.As(err, &errno) && exception.Class(errno) != "":
+		class = exception.Class(errno)
+	case errors.As(err, &errno) && exception.Message(errno) != "":
+		msg = exception.Message(errno)
+	}
+	code := http.StatusForbidden // as for any IOException
+	switch class {
+	case exception.Class(syscall.ENOENT):
+		code = http.StatusNotFound
+	case "java.lang.IllegalArgumentException", exception.Class(syscall.ENOTSUP):
+		code = http.StatusBadRequest
+	}
+	var re remoteException
+	re.RemoteException.JavaClassName = class
+	re.RemoteException.Exception = class[strings.LastIndexByte(class, '.')+1:]
+	re.RemoteException.Message = msg
+	writeJSON(w, code, &re)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// intParam returns the integer parameter name, def if it is absent.
+func (req *request) intParam(name string, def int64) (int64, error) {
+	s := req.q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &paramError{name, s}
+	}
+	return n, nil
+}
+
+// boolParam returns the boolean parameter name, false if it is absent.
+func (req *request) boolParam(name string) (bool, error) {
+	s := req.q.Get(name)
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, &paramError{name, s}
+	}
+	return b, nil
+}
+
+// permParam returns the octal permission parameter, -1 if it is absent.
+func (req *request) permParam() (int16, error) {
+	s := req.q.Get("permission")
+	if s == "" {
+		return -1, nil
+	}
+	mode, err := strconv.ParseInt(s, 8, 16)
+	if err != nil || mode < 0 || mode > 01777 {
+		return 0, &paramError{"permission", s}
+	}
+	return int16(mode), nil
+}
+
+// toFileStatus converts info to the FileStatus of an entry named suffix
+// in a listing, "" for the path itself.
+func toFileStatus(info *hdfs.FileInfo, suffix string) fileStatus {
+	st := fileStatus{
+		AccessTime:       info.LastAccess.UnixNano() / 1e6,
+		BlockSize:        info.BlockSize,
+		Group:            info.Group,
+		Length:           info.Size,
+		ModificationTime: info.LastMod.UnixNano() / 1e6,
+		Owner:            info.Owner,
+		PathSuffix:       suffix,
+		Permission:       strconv.FormatInt(int64(info.Permissions), 8),
+		Replication:      info.Replication,
+		Type:             "FILE",
+	}
+	if info.Kind == 'D' {
+		st.Type = "DIRECTORY"
+		st.Length = 0
+	}
+	return st
+}
+
+// open streams length bytes of the file from offset, to its end if length
+// is absent.
+func (req *request) open() error {
+	off, err := req.intParam("offset", 0)
+	if err != nil {
+		return err
+	}
+	length, err := req.intParam("length", -1)
+	if err != nil {
+		return err
+	}
+	if off < 0 {
+		return &paramError{"offset", req.q.Get("offset")}
+	}
+	info, err := req.fs.GetPathInfo(req.path)
+	if err != nil {
+		return err
+	}
+	if info.Kind == 'D' {
+		return &hdfs.PathError{Op: "open", Path: req.path, Err: syscall.ENOENT}
+	}
+	if off > info.Size {
+		return &hdfs.PathError{Op: "open", Path: req.path, Err: fmt.Errorf("%w: offset %d beyond the end %d", hdfs.ErrInternal, off, info.Size)}
+	}
+	n := info.Size - off
+	if length >= 0 && length < n {
+		n = length
+	}
+	f, err := req.fs.Open(req.path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if off > 0 {
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	req.w.Header().Set("Content-Type", "application/octet-stream")
+	req.w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	req.w.WriteHeader(http.StatusOK)
+	if _, err := io.CopyN(req.w, f, n); err != nil {
+		// the status is sent: cut the response short
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// create serves CREATE and APPEND. Without data=true it redirects the
+// client to the same URL with it, as a namenode redirects to a datanode;
+// with it, the request body is written to the file.
+func (req *request) create() error {
+	appending := req.r.Method == http.MethodPost
+	overwrite, err := req.boolParam("overwrite")
+	if err != nil {
+		return err
+	}
+	data, err := req.boolParam("data")
+	if err != nil {
+		return err
+	}
+	replication, err := req.intParam("replication", 0)
+	if err != nil {
+		return err
+	}
+	blocksize, err := req.intParam("blocksize", 0)
+	if err != nil {
+		return err
+	}
+	buffersize, err := req.intParam("buffersize", 0)
+	if err != nil {
+		return err
+	}
+	perm, err := req.permParam()
+	if err != nil {
+		return err
+	}
+	if appending {
+		if err := req.fs.Exists(req.path); err != nil {
+			return err
+		}
+	} else if !overwrite && req.fs.Exists(req.path) == nil {
+		return &hdfs.PathError{Op: "create", Path: req.path, Err: syscall.EEXIST}
+	}
+	if !data {
+		q := req.r.URL.Query()
+		q.Set("data", "true")
+		u := *req.r.URL
+		u.RawQuery = q.Encode()
+		http.Redirect(req.w, req.r, u.RequestURI(), http.StatusTemporaryRedirect)
+		return nil
+	}
+	flags := hdfs.O_WRONLY | hdfs.O_CREATE
+	if appending {
+		flags = hdfs.O_WRONLY | hdfs.O_APPEND
+	}
+	f, err := req.fs.Open(req.path, flags, int(buffersize), int(replication), uint32(blocksize))
+	if err != nil {
+		return err
+	}
+	if perm >= 0 && !appending {
+		if err := req.fs.Chmod(req.path, perm); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := io.Copy(f, req.r.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if appending {
+		req.w.WriteHeader(http.StatusOK)
+	} else {
+		req.w.WriteHeader(http.StatusCreated)
+	}
+	return nil
+}
+
+func (req *request) getFileStatus() error {
+	info, err := req.fs.GetPathInfo(req.path)
+	if err != nil {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &fileStatusResponse{toFileStatus(info, "")})
+	return nil
+}
+
+// listStatus serves LISTSTATUS and LISTSTATUS_BATCH, whose batches hold
+// the entries after startAfter.
+func (req *request) listStatus() error {
+	info, err := req.fs.GetPathInfo(req.path)
+	if err != nil {
+		return err
+	}
+	var sts []fileStatus
+	if info.Kind != 'D' {
+		sts = []fileStatus{toFileStatus(info, "")}
+	} else {
+		infos, err := req.fs.ListDirectory(req.path)
+		if err != nil {
+			return err
+		}
+		after := req.q.Get("startAfter")
+		sts = make([]fileStatus, 0, len(infos))
+		for _, info := range infos {
+			if name := path.Base(info.Name); name > after {
+				sts = append(sts, toFileStatus(info, name))
+			}
+		}
+	}
+	if strings.ToUpper(req.q.Get("op")) == "LISTSTATUS" {
+		writeJSON(req.w, http.StatusOK, &listStatusResponse{fileStatuses{sts}})
+		return nil
+	}
+	limit := req.h.ListLimit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	var resp listStatusBatchResponse
+	if len(sts) > limit {
+		resp.DirectoryListing.RemainingEntries = int64(len(sts) - limit)
+		sts = sts[:limit]
+	}
+	resp.DirectoryListing.PartialListing.FileStatuses.FileStatus = sts
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// getContentSummary reports the disk usage of the tree, walked if the
+// FileSystem cannot compute it.
+func (req *request) getContentSummary() error {
+	s, err := hdfs.GetContentSummary(req.fs, req.path)
+	if err != nil {
+		return err
+	}
+	var resp contentSummaryResponse
+	resp.ContentSummary.DirectoryCount = s.DirectoryCount
+	resp.ContentSummary.FileCount = s.FileCount
+	resp.ContentSummary.Length = s.Length
+	resp.ContentSummary.Quota = s.Quota
+	resp.ContentSummary.SpaceConsumed = s.SpaceConsumed
+	resp.ContentSummary.SpaceQuota = s.SpaceQuota
+	for t, tq := range s.TypeQuotas {
+		if resp.ContentSummary.TypeQuota == nil {
+			resp.ContentSummary.TypeQuota = map[string]typeQuota{}
+		}
+		resp.ContentSummary.TypeQuota[t.String()] = typeQuota{Consumed: tq.Consumed, Quota: tq.Quota}
+	}
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+func (req *request) getFileChecksum() error {
+	fsys, ok := req.fs.(hdfs.Checksummer)
+	if !ok {
+		return &hdfs.PathError{Op: "checksum", Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	c, err := fsys.GetFileChecksum(req.path)
+	if err != nil {
+		return err
+	}
+	var resp fileChecksumResponse
+	resp.FileChecksum.Algorithm = c.Algorithm
+	resp.FileChecksum.Bytes = hex.EncodeToString(c.Bytes)
+	resp.FileChecksum.Length = len(c.Bytes)
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+func (req *request) getFileBlockLocations() error {
+	off, err := req.intParam("offset", 0)
+	if err != nil {
+		return err
+	}
+	length, err := req.intParam("length", -1)
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		info, err := req.fs.GetPathInfo(req.path)
+		if err != nil {
+			return err
+		}
+		length = info.Size
+	}
+	hosts, err := req.fs.GetHosts(req.path, off, length)
+	if err != nil {
+		return err
+	}
+	var resp blockLocationsResponse
+	for _, h := range hosts {
+		resp.BlockLocations.BlockLocation = append(resp.BlockLocations.BlockLocation, blockLocation{Hosts: h})
+	}
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// home returns the home directory of the user, where the file system starts
+// in.
+func (req *request) home() (string, error) {
+	wd, err := req.fs.GetWorkingDirectory(make([]byte, 4096), 4096)
+	if err != nil {
+		return "", err
+	}
+	if i := strings.IndexByte(string(wd), 0); i >= 0 {
+		wd = wd[:i]
+	}
+	home := string(wd)
+	if u, err := url.Parse(home); err == nil && u.Scheme != "" {
+		home = u.Path
+	}
+	return home, nil
+}
+
+func (req *request) getHomeDirectory() error {
+	home, err := req.home()
+	if err != nil {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &pathResponse{home})
+	return nil
+}
+
+// getTrashRoot answers the trash root of the path, which WebHDFS tells
+// even of file systems without a trash, as FileSystem.getTrashRoot does.
+func (req *request) getTrashRoot() error {
+	var root string
+	var err error
+	if fsys, ok := req.fs.(hdfs.Trasher); ok {
+		root, err = fsys.TrashRoot(req.path)
+	} else if root, err = req.home(); err == nil {
+		root = path.Join(root, ".Trash")
+	}
+	if err != nil {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &pathResponse{root})
+	return nil
+}
+
+func (req *request) getServerDefaults() error {
+	bs, err := req.fs.GetDefaultBlockSize()
+	if err != nil {
+		return err
+	}
+	var resp serverDefaultsResponse
+	resp.FsServerDefaults.BlockSize = bs
+	if fsys, ok := req.fs.(hdfs.Trasher); ok {
+		interval, err := fsys.TrashInterval()
+		if err != nil {
+			return err
+		}
+		resp.FsServerDefaults.TrashInterval = int64(interval / time.Minute)
+	}
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+func (req *request) getStatus() error {
+	capacity, err := req.fs.GetCapacity()
+	if err != nil {
+		return err
+	}
+	used, err := req.fs.GetUsed()
+	if err != nil {
+		return err
+	}
+	remaining, err := hdfs.GetRemaining(req.fs)
+	if err != nil {
+		return err
+	}
+	var resp statusResponse
+	resp.FsStatus.Capacity = capacity
+	resp.FsStatus.Used = used
+	resp.FsStatus.Remaining = remaining
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// writeBoolean answers a boolean, false for the failures HDFS reports as
+// false rather than as an exception: missing paths or, if exists, existing
+// ones.
+func (req *request) writeBoolean(err error, exists bool) error {
+	if err != nil && !errors.Is(err, syscall.ENOENT) && !(exists && errors.Is(err, syscall.EEXIST)) {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &booleanResponse{err == nil})
+	return nil
+}
+
+func (req *request) mkdirs() error {
+	perm, err := req.permParam()
+	if err != nil {
+		return err
+	}
+	if err := req.fs.CreateDirectory(req.path); err != nil {
+		return err
+	}
+	if perm >= 0 {
+		if err := req.fs.Chmod(req.path, perm); err != nil {
+			return err
+		}
+	}
+	return req.writeBoolean(nil, false)
+}
+
+func (req *request) rename() error {
+	dst := req.q.Get("destination")
+	if !strings.HasPrefix(dst, "/") {
+		return &paramError{"destination", dst}
+	}
+	return req.writeBoolean(req.fs.Rename(req.path, path.Clean(dst)), true)
+}
+
+// delete removes a path, refusing non-empty directories unless recursive
+// is true.
+func (req *request) delete() error {
+	recursive, err := req.boolParam("recursive")
+	if err != nil {
+		return err
+	}
+	if r, ok := req.fs.(hdfs.Remover); ok && !recursive {
+		return req.writeBoolean(r.Remove(req.path), false)
+	}
+	if !recursive {
+		info, err := req.fs.GetPathInfo(req.path)
+		if err != nil {
+			return req.writeBoolean(err, false)
+		}
+		if info.Kind == 'D' {
+			infos, err := req.fs.ListDirectory(req.path)
+			if err != nil {
+				return err
+			}
+			if len(infos) > 0 {
+				return &hdfs.PathError{Op: "delete", Path: req.path, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	return req.writeBoolean(req.fs.Delete(req.path), false)
+}
+
+func (req *request) setPermission() error {
+	perm, err := req.permParam()
+	if err != nil {
+		return err
+	}
+	if perm < 0 {
+		perm = 0755
+	}
+	return req.fs.Chmod(req.path, perm)
+}
+
+func (req *request) setOwner() error {
+	owner, group := req.q.Get("owner"), req.q.Get("group")
+	if owner == "" && group == "" {
+		return &paramError{"owner", owner}
+	}
+	return req.fs.Chown(req.path, owner, group)
+}
+
+func (req *request) setReplication() error {
+	replication, err := req.intParam("replication", 0)
+	if err != nil {
+		return err
+	}
+	if replication <= 0 || replication > 1<<15-1 {
+		return &paramError{"replication", req.q.Get("replication")}
+	}
+	return req.writeBoolean(req.fs.SetReplication(req.path, int16(replication)), false)
+}
+
+// truncate cuts a file, answering false while its last block is being
+// recovered.
+func (req *request) truncate() error {
+	fsys, ok := req.fs.(hdfs.Truncater)
+	if !ok {
+		return &hdfs.PathError{Op: "truncate", Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	newLength, err := req.intParam("newlength", -1)
+	if err != nil {
+		return err
+	}
+	if newLength < 0 {
+		return &paramError{"newlength", req.q.Get("newlength")}
+	}
+	done, err := fsys.TruncateFile(req.path, newLength)
+	if err != nil {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &booleanResponse{done})
+	return nil
+}
+
+// concat appends the comma-separated files sources to the file, copying
+// them if the FileSystem cannot move their blocks.
+func (req *request) concat() error {
+	sources := req.q.Get("sources")
+	srcs := strings.Split(sources, ",")
+	for i, src := range srcs {
+		if !strings.HasPrefix(src, "/") {
+			return &paramError{"sources", sources}
+		}
+		srcs[i] = path.Clean(src)
+	}
+	return hdfs.Concat(req.fs, req.path, srcs)
+}
+
+// xattrer returns the FileSystem of req as an hdfs.XAttrer.
+func (req *request) xattrer(op string) (hdfs.XAttrer, error) {
+	fsys, ok := req.fs.(hdfs.XAttrer)
+	if !ok {
+		return nil, &hdfs.PathError{Op: op, Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	return fsys, nil
+}
+
+// setXAttr sets the attribute xattr.name to xattr.value, with the flag
+// CREATE, REPLACE or both, separated by a comma.
+func (req *request) setXAttr() error {
+	fsys, err := req.xattrer("setxattr")
+	if err != nil {
+		return err
+	}
+	value, err := decodeXAttrValue(req.q.Get("xattr.value"))
+	if err != nil {
+		return &paramError{"xattr.value", req.q.Get("xattr.value")}
+	}
+	flags := 0
+	if flag := req.q.Get("flag"); flag != "" {
+		for _, f := range strings.Split(flag, ",") {
+			switch strings.ToUpper(f) {
+			case "CREATE":
+				flags |= hdfs.XATTR_CREATE
+			case "REPLACE":
+				flags |= hdfs.XATTR_REPLACE
+			default:
+				return &paramError{"flag", flag}
+			}
+		}
+	}
+	return fsys.SetXAttr(req.path, req.q.Get("xattr.name"), value, flags)
+}
+
+// getXAttrs answers the attributes named by the xattr.name parameters, all
+// of them if there are none, with values in the encoding parameter: text,
+// the default, hex or base64.
+func (req *request) getXAttrs() error {
+	fsys, err := req.xattrer("getxattr")
+	if err != nil {
+		return err
+	}
+	encoding := req.q.Get("encoding")
+	switch strings.ToLower(encoding) {
+	case "", "text", "hex", "base64":
+	default:
+		return &paramError{"encoding", encoding}
+	}
+	values, err := fsys.GetXAttrs(req.path, req.q["xattr.name"]...)
+	if err != nil {
+		return err
+	}
+	resp := xattrsResponse{XAttrs: []xattr{}}
+	for name, value := range values {
+		v := encodeXAttrValue(value, encoding)
+		resp.XAttrs = append(resp.XAttrs, xattr{Name: name, Value: &v})
+	}
+	sort.Slice(resp.XAttrs, func(i, j int) bool { return resp.XAttrs[i].Name < resp.XAttrs[j].Name })
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+func (req *request) listXAttrs() error {
+	fsys, err := req.xattrer("listxattr")
+	if err != nil {
+		return err
+	}
+	names, err := fsys.ListXAttrs(req.path)
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		names = []string{}
+	}
+	b, _ := json.Marshal(names)
+	writeJSON(req.w, http.StatusOK, &xattrNamesResponse{string(b)})
+	return nil
+}
+
+func (req *request) removeXAttr() error {
+	fsys, err := req.xattrer("removexattr")
+	if err != nil {
+		return err
+	}
+	return fsys.RemoveXAttr(req.path, req.q.Get("xattr.name"))
+}
+
+// acler returns the FileSystem of req as an hdfs.Acler.
+func (req *request) acler(op string) (hdfs.Acler, error) {
+	fsys, ok := req.fs.(hdfs.Acler)
+	if !ok {
+		return nil, &hdfs.PathError{Op: op, Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	return fsys, nil
+}
+
+func (req *request) getAclStatus() error {
+	fsys, err := req.acler("getacl")
+	if err != nil {
+		return err
+	}
+	s, err := fsys.GetAclStatus(req.path)
+	if err != nil {
+		return err
+	}
+	var resp aclStatusResponse
+	resp.AclStatus.Entries = []string{}
+	for _, e := range s.Entries {
+		resp.AclStatus.Entries = append(resp.AclStatus.Entries, e.String())
+	}
+	resp.AclStatus.Owner, resp.AclStatus.Group = s.Owner, s.Group
+	resp.AclStatus.Permission = strconv.FormatInt(int64(s.Permission), 8)
+	resp.AclStatus.StickyBit = s.Sticky
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// changeAcl calls change with the entries of the aclspec parameter.
+func (req *request) changeAcl(change func(fsys hdfs.Acler, entries []hdfs.AclEntry) error) error {
+	fsys, err := req.acler("setacl")
+	if err != nil {
+		return err
+	}
+	spec := req.q.Get("aclspec")
+	entries, err := hdfs.ParseAclSpec(spec)
+	if err != nil {
+		return &paramError{"aclspec", spec}
+	}
+	return change(fsys, entries)
+}
+
+func (req *request) setAcl() error {
+	return req.changeAcl(func(fsys hdfs.Acler, entries []hdfs.AclEntry) error {
+		return fsys.SetAcl(req.path, entries)
+	})
+}
+
+func (req *request) modifyAclEntries() error {
+	return req.changeAcl(func(fsys hdfs.Acler, entries []hdfs.AclEntry) error {
+		return fsys.ModifyAclEntries(req.path, entries)
+	})
+}
+
+func (req *request) removeAclEntries() error {
+	return req.changeAcl(func(fsys hdfs.Acler, entries []hdfs.AclEntry) error {
+		return fsys.RemoveAclEntries(req.path, entries)
+	})
+}
+
+func (req *request) removeDefaultAcl() error {
+	fsys, err := req.acler("setacl")
+	if err != nil {
+		return err
+	}
+	return fsys.RemoveDefaultAcl(req.path)
+}
+
+func (req *request) removeAcl() error {
+	fsys, err := req.acler("setacl")
+	if err != nil {
+		return err
+	}
+	return fsys.RemoveAcl(req.path)
+}
+
+// snapshotter returns the FileSystem of req as an hdfs.Snapshotter.
+func (req *request) snapshotter(op string) (hdfs.Snapshotter, error) {
+	fsys, ok := req.fs.(hdfs.Snapshotter)
+	if !ok {
+		return nil, &hdfs.PathError{Op: op, Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	return fsys, nil
+}
+
+func (req *request) allowSnapshot() error {
+	fsys, err := req.snapshotter("allowsnapshot")
+	if err != nil {
+		return err
+	}
+	return fsys.AllowSnapshot(req.path)
+}
+
+func (req *request) disallowSnapshot() error {
+	fsys, err := req.snapshotter("disallowsnapshot")
+	if err != nil {
+		return err
+	}
+	return fsys.DisallowSnapshot(req.path)
+}
+
+func (req *request) createSnapshot() error {
+	fsys, err := req.snapshotter("createsnapshot")
+	if err != nil {
+		return err
+	}
+	p, err := fsys.CreateSnapshot(req.path, req.q.Get("snapshotname"))
+	if err != nil {
+		return err
+	}
+	writeJSON(req.w, http.StatusOK, &pathResponse{p})
+	return nil
+}
+
+func (req *request) deleteSnapshot() error {
+	fsys, err := req.snapshotter("deletesnapshot")
+	if err != nil {
+		return err
+	}
+	return fsys.DeleteSnapshot(req.path, req.q.Get("snapshotname"))
+}
+
+func (req *request) renameSnapshot() error {
+	fsys, err := req.snapshotter("renamesnapshot")
+	if err != nil {
+		return err
+	}
+	return fsys.RenameSnapshot(req.path, req.q.Get("oldsnapshotname"), req.q.Get("snapshotname"))
+}
+
+// getSnapshottableDirList lists the snapshottable directories; the path
+// is ignored.
+func (req *request) getSnapshottableDirList() error {
+	fsys, err := req.snapshotter("lssnapshottabledir")
+	if err != nil {
+		return err
+	}
+	dirs, err := fsys.ListSnapshottableDirs()
+	if err != nil {
+		return err
+	}
+	resp := snapshottableDirListResponse{SnapshottableDirectoryList: []snapshottableDir{}}
+	for _, d := range dirs {
+		info, err := req.fs.GetPathInfo(d.Path)
+		if err != nil {
+			return err
+		}
+		parent, name := path.Split(d.Path)
+		resp.SnapshottableDirectoryList = append(resp.SnapshottableDirectoryList, snapshottableDir{
+			DirStatus:      toFileStatus(info, name),
+			ParentFullPath: path.Clean(parent),
+			SnapshotNumber: d.Snapshots,
+			SnapshotQuota:  d.SnapshotQuota,
+		})
+	}
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// toDiffPath returns the path of a SnapshotDiffEntry as WebHDFS sends it,
+// empty for the snapshot root.
+func toDiffPath(p string) string {
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+func (req *request) getSnapshotDiff() error {
+	fsys, err := req.snapshotter("snapshotdiff")
+	if err != nil {
+		return err
+	}
+	from, to := req.q.Get("oldsnapshotname"), req.q.Get("snapshotname")
+	r, err := fsys.SnapshotDiff(req.path, from, to)
+	if err != nil {
+		return err
+	}
+	var resp snapshotDiffReportResponse
+	resp.SnapshotDiffReport.DiffList = []diffReportEntry{}
+	for _, e := range r.Entries {
+		entry := diffReportEntry{SourcePath: toDiffPath(e.Path), Type: e.Type.String()}
+		if e.Type == hdfs.DiffRename {
+			target := toDiffPath(e.Target)
+			entry.TargetPath = &target
+		}
+		resp.SnapshotDiffReport.DiffList = append(resp.SnapshotDiffReport.DiffList, entry)
+	}
+	resp.SnapshotDiffReport.SnapshotRoot = req.path
+	resp.SnapshotDiffReport.FromSnapshot, resp.SnapshotDiffReport.ToSnapshot = from, to
+	writeJSON(req.w, http.StatusOK, &resp)
+	return nil
+}
+
+// setTimes sets the modification and access times, in milliseconds; -1
+// leaves a time unchanged.
+func (req *request) setTimes() error {
+	mtime, err := req.intParam("modificationtime", -1)
+	if err != nil {
+		return err
+	}
+	atime, err := req.intParam("accesstime", -1)
+	if err != nil {
+		return err
+	}
+	if mtime < 0 && atime < 0 {
+		return nil
+	}
+	info, err := req.fs.GetPathInfo(req.path)
+	if err != nil {
+		return err
+	}
+	mt, at := info.LastMod, info.LastAccess
+	if mtime >= 0 {
+		mt = time.Unix(0, mtime*int64(time.Millisecond))
+	}
+	if atime >= 0 {
+		at = time.Unix(0, atime*int64(time.Millisecond))
+	}
+	return req.fs.Utime(req.path, mt, at)
+}
+
+// quotaSetter returns the FileSystem of req as an hdfs.QuotaSetter.
+func (req *request) quotaSetter() (hdfs.QuotaSetter, error) {
+	fsys, ok := req.fs.(hdfs.QuotaSetter)
+	if !ok {
+		return nil, &hdfs.PathError{Op: "setquota", Path: req.path, Err: hdfs.ErrUnsupported}
+	}
+	return fsys, nil
+}
+
+// setQuota sets the namespace and space quotas; a missing quota is left as
+// it is, and -1 clears it.
+func (req *request) setQuota() error {
+	fsys, err := req.quotaSetter()
+	if err != nil {
+		return err
+	}
+	nsQuota, err := req.intParam("namespacequota", hdfs.QuotaDontSet)
+	if err != nil {
+		return err
+	}
+	ssQuota, err := req.intParam("storagespacequota", hdfs.QuotaDontSet)
+	if err != nil {
+		return err
+	}
+	return fsys.SetQuota(req.path, nsQuota, ssQuota)
+}
+
+// setQuotaByStorageType sets the space quota on the storage type
+// storagetype.
+func (req *request) setQuotaByStorageType() error {
+	fsys, err := req.quotaSetter()
+	if err != nil {
+		return err
+	}
+	t, err := hdfs.ParseStorageType(req.q.Get("storagetype"))
+	if err != nil {
+		return &paramError{"storagetype", req.q.Get("storagetype")}
+	}
+	quota, err := req.intParam("storagespacequota", hdfs.QuotaDontSet)
+	if err != nil {
+		return err
+	}
+	return fsys.SetTypeQuota(req.path, t, quota)
+}
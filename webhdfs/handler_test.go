@@ -0,0 +1,381 @@
+package webhdfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+func serveMemFS(t *testing.T) (*hdfstest.MemFS, *httptest.Server) {
+	m := hdfstest.NewMemFS()
+	h := NewHandler(func(user string) (hdfs.FileSystem, error) {
+		if user == "" {
+			return m, nil
+		}
+		return m.AsUser(user), nil
+	})
+	h.ListLimit = 2
+	srv := httptest.NewServer(h)
+	t.Cleanup(func() {
+		srv.Close()
+		h.Close()
+	})
+	return m, srv
+}
+
+func TestHandler(t *testing.T) {
+	m, srv := serveMemFS(t)
+	m.CreateDirectory("/user/alice")
+	m.Chown("/user/alice", "alice", "")
+	c, err := Connect(srv.URL, "alice")
+	if err != nil {
+		t.Fatalf("Error on connecting: %v\n", err)
+	}
+	defer c.Disconnect()
+
+	writeFile(t, c, "f", "hello, ")
+	f, err := c.Open("f", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for appending: %v\n", err)
+	}
+	io.WriteString(f, "world")
+	if err := f.Close(); err != nil {
+		t.Errorf("Error on closing: %v\n", err)
+	}
+	if got := readFile(t, m, "/user/alice/f"); got != "hello, world" {
+		t.Errorf("Content written through the handler: got %q\n", got)
+	}
+	r, err := c.Open("/user/alice/f", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for reading: %v\n", err)
+	}
+	b := make([]byte, 5)
+	if n, err := r.ReadAt(b, 7); n != 5 || err != nil || string(b) != "world" {
+		t.Errorf("ReadAt: got %q, %v\n", b[:n], err)
+	}
+	r.Close()
+
+	for _, name := range []string{"c", "a", "b"} {
+		writeFile(t, c, "dir/"+name, name)
+	}
+	infos, err := c.ListDirectory("dir")
+	if err != nil || len(infos) != 3 || infos[0].Name != c.uri+"/user/alice/dir/a" || infos[2].Size != 1 {
+		t.Errorf("ListDirectory: got %v, %v\n", infos, err)
+	}
+	if err := c.Chmod("f", 0600); err != nil {
+		t.Errorf("Error on Chmod: %v\n", err)
+	}
+	mtime := time.Unix(1500000000, 0)
+	if err := c.Utime("f", mtime, mtime); err != nil {
+		t.Errorf("Error on Utime: %v\n", err)
+	}
+	if err := c.SetReplication("f", 1); err != nil {
+		t.Errorf("Error on SetReplication: %v\n", err)
+	}
+	if err := c.Rename("f", "/user/alice/g"); err != nil {
+		t.Errorf("Error on Rename: %v\n", err)
+	}
+	info, err := m.GetPathInfo("/user/alice/g")
+	if err != nil || info.Permissions != 0600 || !info.LastMod.Equal(mtime) || info.Replication != 1 || info.Owner != "alice" {
+		t.Errorf("GetPathInfo after changes: got %+v, %v\n", info, err)
+	}
+	if err := hdfs.Concat(c, "dir/a", []string{"dir/b", "/user/alice/dir/c"}); err != nil {
+		t.Errorf("Error on Concat: %v\n", err)
+	}
+	if err := hdfs.Truncate(c, "dir/a", 2); err != nil {
+		t.Errorf("Error on Truncate: %v\n", err)
+	}
+	if got := readFile(t, m, "/user/alice/dir/a"); got != "ab" {
+		t.Errorf("Content after Concat and Truncate: got %q\n", got)
+	}
+	if err := c.Exists("dir/b"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Source after Concat: got %v, want ENOENT\n", err)
+	}
+	if _, err := c.TruncateFile("dir/a", 3); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("TruncateFile past the end: got %v, want EINVAL\n", err)
+	}
+	if err := c.Remove("dir"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("Remove of a directory that is not empty: got %v, want ENOTEMPTY\n", err)
+	}
+	if err := c.Delete("dir"); err != nil {
+		t.Errorf("Error on Delete: %v\n", err)
+	}
+	if err := c.Exists("dir"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Exists after Delete: got %v, want ENOENT\n", err)
+	}
+	if err := c.Rename("missing", "/user/alice/h"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Rename of a missing file: got %v, want ENOENT\n", err)
+	}
+	if err := c.Chown("/", "alice", ""); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Chown without permission: got %v, want EACCES\n", err)
+	}
+	if s, err := c.GetContentSummary("g"); err != nil || s.Length != 12 || s.FileCount != 1 || s.Quota != -1 {
+		t.Errorf("GetContentSummary: got %+v, %v\n", s, err)
+	}
+	if err := hdfs.VerifyChecksum(c, "g", strings.NewReader("hello, world")); err != nil {
+		t.Errorf("Error on VerifyChecksum: %v\n", err)
+	}
+	if err := hdfs.VerifyChecksum(c, "g", strings.NewReader("hello, World")); !errors.Is(err, hdfs.ErrChecksum) {
+		t.Errorf("VerifyChecksum of other data: got %v, want ErrChecksum\n", err)
+	}
+	if err := c.SetXAttr("g", "user.schema", []byte{0, 1, 0xff}, 0); err != nil {
+		t.Errorf("Error on SetXAttr: %v\n", err)
+	}
+	if err := c.SetXAttr("g", "user.lineage", nil, hdfs.XATTR_REPLACE); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("SetXAttr with XATTR_REPLACE on a missing attribute: got %v, want ErrNoXAttr\n", err)
+	}
+	if value, err := c.GetXAttr("g", "user.schema"); err != nil || !bytes.Equal(value, []byte{0, 1, 0xff}) {
+		t.Errorf("GetXAttr: got %v, %v\n", value, err)
+	}
+	if names, err := c.ListXAttrs("g"); err != nil || !reflect.DeepEqual(names, []string{"user.schema"}) {
+		t.Errorf("ListXAttrs: got %v, %v\n", names, err)
+	}
+	if err := c.RemoveXAttr("g", "user.schema"); err != nil {
+		t.Errorf("Error on RemoveXAttr: %v\n", err)
+	}
+	if _, err := c.GetXAttrs("g", "user.schema"); !errors.Is(err, hdfs.ErrNoXAttr) {
+		t.Errorf("GetXAttrs of a removed attribute: got %v, want ErrNoXAttr\n", err)
+	}
+	entries, _ := hdfs.ParseAclSpec("user:bob:rw-")
+	if err := c.ModifyAclEntries("g", entries); err != nil {
+		t.Errorf("Error on ModifyAclEntries: %v\n", err)
+	}
+	if s, err := c.GetAclStatus("g"); err != nil || s.Owner != "alice" || hdfs.FormatAclSpec(s.Entries) != "user:bob:rw-,group::---" {
+		t.Errorf("GetAclStatus: got %+v, %v\n", s, err)
+	}
+	if err := c.RemoveAclEntries("g", entries); err != nil {
+		t.Errorf("Error on RemoveAclEntries: %v\n", err)
+	}
+	if err := c.RemoveAcl("g"); err != nil {
+		t.Errorf("Error on RemoveAcl: %v\n", err)
+	}
+	if s, err := c.GetAclStatus("g"); err != nil || len(s.Entries) != 0 {
+		t.Errorf("GetAclStatus after RemoveAcl: got %+v, %v\n", s, err)
+	}
+	if hosts, err := c.GetHosts("g", 0, 100); err != nil || !reflect.DeepEqual(hosts, [][]string{{"localhost"}}) {
+		t.Errorf("GetHosts: got %v, %v\n", hosts, err)
+	}
+	if used, err := c.GetUsed(); used != 12 || err != nil {
+		t.Errorf("GetUsed: got %d, %v\n", used, err)
+	}
+	m.SetNonDFSUsed(100)
+	if n, err := c.GetRemaining(); n != 1<<40-112 || err != nil {
+		t.Errorf("GetRemaining: got %d, %v\n", n, err)
+	}
+
+	m.AllowSnapshot("/user/alice")
+	if p, err := c.CreateSnapshot("/user/alice", "s0"); err != nil || p != "/user/alice/.snapshot/s0" {
+		t.Errorf("CreateSnapshot: got %s, %v\n", p, err)
+	}
+	writeFile(t, c, "h", "new")
+	if err := c.Exists(hdfs.SnapshotPath("/user/alice", "s0") + "/h"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Exists in a snapshot of a file created since: got %v, want ENOENT\n", err)
+	}
+	want := &hdfs.SnapshotDiffReport{Dir: "/user/alice", From: "s0", Entries: []hdfs.SnapshotDiffEntry{{Type: hdfs.DiffModify, Path: "."}, {Type: hdfs.DiffCreate, Path: "h"}}}
+	if r, err := c.SnapshotDiff("/user/alice", "s0", ""); err != nil || !reflect.DeepEqual(r, want) {
+		t.Errorf("SnapshotDiff: got %+v, %v\n", r, err)
+	}
+	if err := c.RenameSnapshot("/user/alice", "s0", "s1"); err != nil {
+		t.Errorf("Error on RenameSnapshot: %v\n", err)
+	}
+	if dirs, err := c.ListSnapshottableDirs(); err != nil || len(dirs) != 1 || *dirs[0] != (hdfs.SnapshottableDir{Path: "/user/alice", Snapshots: 1, SnapshotQuota: 65536}) {
+		t.Errorf("ListSnapshottableDirs: got %v, %v\n", dirs, err)
+	}
+	if err := c.DeleteSnapshot("/user/alice", "s1"); err != nil {
+		t.Errorf("Error on DeleteSnapshot: %v\n", err)
+	}
+	if err := c.DisallowSnapshot("/user/alice"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("DisallowSnapshot by a user: got %v\n", err)
+	}
+
+	if err := c.SetQuota("/user/alice", 100, hdfs.QuotaDontSet); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("SetQuota by a user: got %v\n", err)
+	}
+	m.SetQuota("/user/alice", 100, hdfs.QuotaDontSet)
+	m.SetTypeQuota("/user/alice", hdfs.StorageDisk, 1<<20)
+	s, err := c.GetContentSummary("/user/alice")
+	if want, _ := m.GetContentSummary("/user/alice"); err != nil || !reflect.DeepEqual(s, want) {
+		t.Errorf("GetContentSummary with quotas: got %+v, %v, want %+v\n", s, err, want)
+	}
+
+	if p, err := hdfs.MoveToTrash(c, "g"); p != "" || err != nil {
+		t.Errorf("MoveToTrash with the trash disabled: got %q, %v\n", p, err)
+	}
+	m.SetTrashInterval(time.Hour)
+	if d, err := c.TrashInterval(); d != time.Hour || err != nil {
+		t.Errorf("TrashInterval: got %v, %v\n", d, err)
+	}
+	if p, err := hdfs.MoveToTrash(c, "g"); p != "/user/alice/.Trash/Current/user/alice/g" || err != nil {
+		t.Errorf("MoveToTrash: got %q, %v\n", p, err)
+	}
+	if roots, err := c.TrashRoots(); err != nil || !reflect.DeepEqual(roots, []string{"/user/alice/.Trash"}) {
+		t.Errorf("TrashRoots: got %v, %v\n", roots, err)
+	}
+	if err := hdfs.Expunge(c); err != nil {
+		t.Errorf("Error on Expunge: %v\n", err)
+	}
+	if p, err := hdfs.RestoreFromTrash(c, "g"); !strings.HasPrefix(p, "/user/alice/.Trash/") || err != nil || readFile(t, m, "/user/alice/g") != "hello, world" {
+		t.Errorf("RestoreFromTrash: got %q, %v\n", p, err)
+	}
+}
+
+func TestHandlerRequests(t *testing.T) {
+	m, srv := serveMemFS(t)
+	writeFile(t, m, "/d/f", "0123456789")
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	do := func(method, query string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, srv.URL+apiPrefix+query, strings.NewReader("data"))
+		resp, err := noRedirect.Do(req)
+		if err != nil {
+			t.Fatalf("Error on %s %s: %v\n", method, query, err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	exception := func(body string) string {
+		var re remoteException
+		json.Unmarshal([]byte(body), &re)
+		return re.RemoteException.Exception
+	}
+	for _, c := range []struct {
+		method, query string
+		code          int
+		body          string
+	}{
+		{"GET", "/d/f?op=OPEN&offset=2&length=3", 200, "234"},
+		{"GET", "/d/f?op=OPEN&offset=8", 200, "89"},
+		{"GET", "/d/f?op=GETHOMEDIRECTORY&user.name=alice", 200, `{"Path":"/user/alice"}` + "\n"},
+		{"GET", "/d/f?op=GETTRASHROOT&user.name=alice", 200, `{"Path":"/user/alice/.Trash"}` + "\n"},
+		{"DELETE", "/d/g?op=DELETE", 200, `{"boolean":false}` + "\n"},
+		{"PUT", "/d/f?op=SETTIMES&accesstime=1000", 200, ""},
+		{"PUT", "/d/f?op=SETXATTR&xattr.name=user.a&xattr.value=0saGk%3D&flag=CREATE", 200, ""},
+		{"GET", "/d/f?op=GETXATTRS&xattr.name=user.a&encoding=text", 200, `{"XAttrs":[{"name":"user.a","value":"\"hi\""}]}` + "\n"},
+		{"GET", "/d/f?op=LISTXATTRS", 200, `{"XAttrNames":"[\"user.a\"]"}` + "\n"},
+		{"PUT", "/d/f?op=SETACL&aclspec=user::rw-,user:bob:r--,group::r--,other::---", 200, ""},
+		{"GET", "/d/f?op=GETACLSTATUS", 200, `{"AclStatus":{"entries":["user:bob:r--","group::r--"],"group":"supergroup","owner":"hdfs","permission":"640","stickyBit":false}}` + "\n"},
+		{"PUT", "/d/f?op=REMOVEACL", 200, ""},
+		{"PUT", "/d?op=ALLOWSNAPSHOT", 200, ""},
+		{"PUT", "/d?op=CREATESNAPSHOT&snapshotname=s0", 200, `{"Path":"/d/.snapshot/s0"}` + "\n"},
+		{"PUT", "/d/f?op=SETPERMISSION&permission=600", 200, ""},
+		{"PUT", "/d?op=RENAMESNAPSHOT&oldsnapshotname=s0&snapshotname=s1", 200, ""},
+		{"GET", "/d?op=GETSNAPSHOTDIFF&oldsnapshotname=s1&snapshotname=", 200, `{"SnapshotDiffReport":{"diffList":[{"sourcePath":"f","type":"MODIFY"}],"fromSnapshot":"s1","snapshotRoot":"/d","toSnapshot":""}}` + "\n"},
+		{"DELETE", "/d?op=DELETESNAPSHOT&snapshotname=s1", 200, ""},
+		{"PUT", "/d?op=DISALLOWSNAPSHOT", 200, ""},
+		{"GET", "/?op=GETSNAPSHOTTABLEDIRECTORYLIST", 200, `{"SnapshottableDirectoryList":[]}` + "\n"},
+		{"PUT", "/d?op=SETQUOTA&namespacequota=3&storagespacequota=1000", 200, ""},
+		{"PUT", "/d?op=SETQUOTABYSTORAGETYPE&storagetype=ssd&storagespacequota=500", 200, ""},
+		{"GET", "/d?op=GETCONTENTSUMMARY", 200, `{"ContentSummary":{"directoryCount":1,"fileCount":1,"length":10,"quota":3,"spaceConsumed":30,"spaceQuota":1000,"typeQuota":{"SSD":{"consumed":0,"quota":500}}}}` + "\n"},
+	} {
+		if resp, body := do(c.method, c.query); resp.StatusCode != c.code || body != c.body {
+			t.Errorf("%s %s: got %d %q, want %d %q\n", c.method, c.query, resp.StatusCode, body, c.code, c.body)
+		}
+	}
+	for _, c := range []struct {
+		method, query string
+		code          int
+		exception     string
+	}{
+		{"GET", "/d/f?op=NOPE", 400, "IllegalArgumentException"},
+		{"PUT", "/d/f?op=OPEN", 400, "IllegalArgumentException"},
+		{"GET", "/d/f?op=OPEN&offset=x", 400, "IllegalArgumentException"},
+		{"GET", "/d/f?op=OPEN&offset=11", 403, "IOException"},
+		{"GET", "/d/g?op=OPEN", 404, "FileNotFoundException"},
+		{"PUT", "/d/f?op=CREATE", 403, "FileAlreadyExistsException"},
+		{"DELETE", "/d?op=DELETE", 403, "PathIsNotEmptyDirectoryException"},
+		{"PUT", "/d?op=SETOWNER", 400, "IllegalArgumentException"},
+		{"PUT", "/d?op=SETPERMISSION&user.name=alice", 403, "AccessControlException"},
+		{"GET", "/d?op=GETFILECHECKSUM", 404, "FileNotFoundException"},
+		{"GET", "/d/f?op=GETXATTRS&xattr.name=user.b", 403, "IOException"},
+		{"PUT", "/d/f?op=SETXATTR&xattr.name=user.a&flag=CREATE", 403, "FileAlreadyExistsException"},
+		{"PUT", "/d/f?op=SETXATTR&xattr.name=user.a&flag=NOPE", 400, "IllegalArgumentException"},
+		{"PUT", "/d/f?op=SETXATTR&xattr.name=a", 403, "InvalidPathException"},
+		{"PUT", "/d/f?op=SETACL&aclspec=user:bob:rwz", 400, "IllegalArgumentException"},
+		{"PUT", "/d/f?op=REMOVEACLENTRIES&aclspec=user::", 403, "InvalidPathException"},
+		{"GET", "/d/g?op=GETACLSTATUS", 404, "FileNotFoundException"},
+		{"PUT", "/d?op=ALLOWSNAPSHOT&user.name=alice", 403, "AccessControlException"},
+		{"PUT", "/d?op=CREATESNAPSHOT", 403, "InvalidPathException"},
+		{"PUT", "/d?op=SETQUOTA&namespacequota=4&user.name=alice", 403, "AccessControlException"},
+		{"PUT", "/d?op=SETQUOTABYSTORAGETYPE&storagetype=TAPE&storagespacequota=1", 400, "IllegalArgumentException"},
+	} {
+		if resp, body := do(c.method, c.query); resp.StatusCode != c.code || exception(body) != c.exception {
+			t.Errorf("%s %s: got %d %s, want %d %s\n", c.method, c.query, resp.StatusCode, body, c.code, c.exception)
+		}
+	}
+	if info, _ := m.GetPathInfo("/d/f"); info.LastAccess.Unix() != 1 {
+		t.Errorf("Access time after SETTIMES: got %v\n", info.LastAccess)
+	}
+
+	resp, _ := do("PUT", "/d/new?op=CREATE&permission=600")
+	loc, _ := resp.Location()
+	if resp.StatusCode != http.StatusTemporaryRedirect || loc == nil || loc.Query().Get("data") != "true" {
+		t.Fatalf("CREATE without data: got %d, %v\n", resp.StatusCode, loc)
+	}
+	if resp, body := do("PUT", strings.TrimPrefix(loc.RequestURI(), apiPrefix)); resp.StatusCode != http.StatusCreated {
+		t.Errorf("CREATE with data: got %d %s\n", resp.StatusCode, body)
+	}
+	if info, err := m.GetPathInfo("/d/new"); err != nil || info.Size != 4 || info.Permissions != 0600 {
+		t.Errorf("GetPathInfo after CREATE: got %+v, %v\n", info, err)
+	}
+	if resp, body := do("POST", "/d/new?op=APPEND&data=true"); resp.StatusCode != http.StatusOK {
+		t.Errorf("APPEND with data: got %d %s\n", resp.StatusCode, body)
+	}
+	if got := readFile(t, m, "/d/new"); got != "datadata" {
+		t.Errorf("Content after APPEND: got %q\n", got)
+	}
+	if resp, _ := do("GET", "/../../f?op=GETFILESTATUS"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Path outside the root: got %d\n", resp.StatusCode)
+	}
+	if resp, err := http.Get(srv.URL + "/other?op=LISTSTATUS"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Path outside the API: got %v, %v\n", resp, err)
+	}
+}
+
+func TestHandlerConns(t *testing.T) {
+	m := hdfstest.NewMemFS()
+	conns := map[string][]*hdfstest.MemFS{}
+	h := NewHandler(func(user string) (hdfs.FileSystem, error) {
+		c := m.AsUser(user)
+		conns[user] = append(conns[user], c)
+		return c, nil
+	})
+	defer h.Close()
+	h.MaxConns = 2
+	serve := func(user string) {
+		if _, err := h.fileSystem(user); err != nil {
+			t.Fatalf("Error on connecting as %s: %v\n", user, err)
+		}
+		h.release(user)
+	}
+
+	busy, _ := h.fileSystem("busy") // serving a request throughout
+	serve("alice")
+	serve("bob")
+	if err := conns["alice"][0].Exists("/"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Least recently used connection beyond MaxConns: got %v, want os.ErrClosed\n", err)
+	}
+	if err := busy.Exists("/"); err != nil {
+		t.Errorf("Connection serving a request disconnected: %v\n", err)
+	}
+	serve("alice")
+	if len(conns["alice"]) != 2 || len(conns["bob"]) != 1 {
+		t.Errorf("Connections made: got %d for alice, %d for bob, want 2, 1\n", len(conns["alice"]), len(conns["bob"]))
+	}
+	h.release("busy")
+	if len(h.conns) != 2 {
+		t.Errorf("Connections kept: got %d, want 2\n", len(h.conns))
+	}
+}
@@ -0,0 +1,158 @@
+package webhdfs
+
+// JSON bodies of the WebHDFS REST API.
+
+type remoteException struct {
+	RemoteException struct {
+		Exception     string `json:"exception"`
+		JavaClassName string `json:"javaClassName"`
+		Message       string `json:"message"`
+	} `json:"RemoteException"`
+}
+
+type fileStatus struct {
+	AccessTime       int64  `json:"accessTime"`
+	BlockSize        int64  `json:"blockSize"`
+	Group            string `json:"group"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+	Owner            string `json:"owner"`
+	PathSuffix       string `json:"pathSuffix"`
+	Permission       string `json:"permission"`
+	Replication      int16  `json:"replication"`
+	Type             string `json:"type"` // FILE, DIRECTORY or SYMLINK
+}
+
+type fileStatusResponse struct {
+	FileStatus fileStatus `json:"FileStatus"`
+}
+
+type fileStatuses struct {
+	FileStatus []fileStatus `json:"FileStatus"`
+}
+
+type listStatusResponse struct {
+	FileStatuses fileStatuses `json:"FileStatuses"`
+}
+
+type listStatusBatchResponse struct {
+	DirectoryListing struct {
+		PartialListing struct {
+			FileStatuses fileStatuses `json:"FileStatuses"`
+		} `json:"partialListing"`
+		RemainingEntries int64 `json:"remainingEntries"`
+	} `json:"DirectoryListing"`
+}
+
+type booleanResponse struct {
+	Boolean bool `json:"boolean"`
+}
+
+type contentSummaryResponse struct {
+	ContentSummary struct {
+		DirectoryCount int64 `json:"directoryCount"`
+		FileCount      int64 `json:"fileCount"`
+		Length         int64 `json:"length"`
+		Quota          int64 `json:"quota"`
+		SpaceConsumed  int64 `json:"spaceConsumed"`
+		SpaceQuota     int64 `json:"spaceQuota"`
+		// by storage type, present once one has a quota
+		TypeQuota map[string]typeQuota `json:"typeQuota,omitempty"`
+	} `json:"ContentSummary"`
+}
+
+type typeQuota struct {
+	Consumed int64 `json:"consumed"`
+	Quota    int64 `json:"quota"`
+}
+
+type fileChecksumResponse struct {
+	FileChecksum struct {
+		Algorithm string `json:"algorithm"`
+		Bytes     string `json:"bytes"` // hex
+		Length    int    `json:"length"`
+	} `json:"FileChecksum"`
+}
+
+type xattr struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"` // encoded by encodeXAttrValue
+}
+
+type xattrsResponse struct {
+	XAttrs []xattr `json:"XAttrs"`
+}
+
+type xattrNamesResponse struct {
+	XAttrNames string `json:"XAttrNames"` // a JSON array, as a string
+}
+
+type aclStatusResponse struct {
+	AclStatus struct {
+		Entries    []string `json:"entries"` // as in an ACL spec
+		Group      string   `json:"group"`
+		Owner      string   `json:"owner"`
+		Permission string   `json:"permission"`
+		StickyBit  bool     `json:"stickyBit"`
+	} `json:"AclStatus"`
+}
+
+type snapshottableDir struct {
+	DirStatus      fileStatus `json:"dirStatus"`
+	ParentFullPath string     `json:"parentFullPath"`
+	SnapshotNumber int        `json:"snapshotNumber"`
+	SnapshotQuota  int        `json:"snapshotQuota"`
+}
+
+type snapshottableDirListResponse struct {
+	SnapshottableDirectoryList []snapshottableDir `json:"SnapshottableDirectoryList"`
+}
+
+type diffReportEntry struct {
+	SourcePath string  `json:"sourcePath"` // relative, empty for the root
+	TargetPath *string `json:"targetPath,omitempty"`
+	Type       string  `json:"type"` // CREATE, MODIFY, DELETE or RENAME
+}
+
+type snapshotDiffReportResponse struct {
+	SnapshotDiffReport struct {
+		DiffList     []diffReportEntry `json:"diffList"`
+		FromSnapshot string            `json:"fromSnapshot"`
+		SnapshotRoot string            `json:"snapshotRoot"`
+		ToSnapshot   string            `json:"toSnapshot"`
+	} `json:"SnapshotDiffReport"`
+}
+
+type blockLocation struct {
+	Hosts  []string `json:"hosts"`
+	Length int64    `json:"length"`
+	Offset int64    `json:"offset"`
+}
+
+type blockLocationsResponse struct {
+	BlockLocations struct {
+		BlockLocation []blockLocation `json:"BlockLocation"`
+	} `json:"BlockLocations"`
+}
+
+// pathResponse is the response of GETHOMEDIRECTORY, GETTRASHROOT and
+// CREATESNAPSHOT.
+type pathResponse struct {
+	Path string `json:"Path"`
+}
+
+type serverDefaultsResponse struct {
+	FsServerDefaults struct {
+		BlockSize     int64 `json:"blockSize"`
+		Replication   int16 `json:"replication"`
+		TrashInterval int64 `json:"trashInterval"` // in minutes
+	} `json:"FsServerDefaults"`
+}
+
+type statusResponse struct {
+	FsStatus struct {
+		Capacity  int64 `json:"capacity"`
+		Used      int64 `json:"used"`
+		Remaining int64 `json:"remaining"`
+	} `json:"FsStatus"`
+}
@@ -0,0 +1,893 @@
+// Package webhdfs is a client of the WebHDFS REST API, as served by the
+// namenodes and datanodes of HDFS or by an HttpFS gateway, for clusters that
+// can only be reached over HTTP. *FS implements hdfs.FileSystem with the
+// semantics, and the *hdfs.PathError values, documented on *hdfs.Fs; it
+// needs neither cgo nor a JVM.
+package webhdfs
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/internal/exception"
+)
+
+const (
+	// apiPrefix is the path of the API on a namenode or HttpFS gateway.
+	apiPrefix = "/webhdfs/v1"
+	// defaultUmask is applied to the permissions of new directories.
+	defaultUmask = 022
+	// maxErrorBody bounds the error bodies read.
+	maxErrorBody = 64 << 10
+)
+
+// FS is a connection to a WebHDFS endpoint. Requests carry the user in the
+// user.name parameter of pseudo authentication; other authentication
+// schemes can be added by the http.Client given to ConnectClient.
+type FS struct {
+	endpoint   url.URL // scheme, host and path prefix of the API
+	uri        string  // webhdfs://authority, prefixing the names in FileInfo
+	user       string
+	client     *http.Client
+	noRedirect *http.Client // client returning redirects to the caller
+	ownClient  bool         // client was made by ConnectClient, not given
+
+	mu      sync.Mutex
+	cwd     string
+	closed  bool
+	noBatch bool // the server does not know LISTSTATUS_BATCH
+}
+
+var (
+	_ hdfs.FileSystem  = (*FS)(nil)
+	_ hdfs.Truncater   = (*FS)(nil)
+	_ hdfs.Concater    = (*FS)(nil)
+	_ hdfs.Checksummer = (*FS)(nil)
+	_ hdfs.XAttrer     = (*FS)(nil)
+	_ hdfs.Acler       = (*FS)(nil)
+	_ hdfs.Snapshotter = (*FS)(nil)
+	_ hdfs.Summarizer  = (*FS)(nil)
+	_ hdfs.QuotaSetter = (*FS)(nil)
+	_ hdfs.Trasher     = (*FS)(nil)
+)
+
+// Connect returns a connection to the WebHDFS endpoint at uri as user, ""
+// being the current user or $HADOOP_USER_NAME. The uri is
+// "webhdfs://host:port" or "swebhdfs://host:port" for the HTTP or HTTPS
+// address of a namenode, or an http or https URL of a gateway, to which
+// /webhdfs/v1 is appended unless its path already ends with it. No request
+// is made until the first operation.
+func Connect(uri, user string) (*FS, error) {
+	return ConnectClient(uri, user, nil)
+}
+
+// ConnectClient is Connect making its requests with client. With nil, it
+// makes a client of its own, with the settings of http.DefaultTransport,
+// whose idle connections Disconnect closes; those of a given client are
+// left to its owner.
+func ConnectClient(uri, user string, client *http.Client) (*FS, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: "connect", Path: uri, Err: err}
+	}
+	scheme := "webhdfs"
+	switch u.Scheme {
+	case "webhdfs":
+		u.Scheme = "http"
+	case "swebhdfs":
+		u.Scheme = "https"
+	case "https":
+		scheme = "swebhdfs"
+	case "http":
+	default:
+		return nil, &hdfs.PathError{Op: "connect", Path: uri, Err: hdfs.ErrUnsupported}
+	}
+	if u.Host == "" {
+		return nil, &hdfs.PathError{Op: "connect", Path: uri, Err: syscall.EINVAL}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, apiPrefix) {
+		u.Path += apiPrefix
+	}
+	u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+	own := client == nil
+	if own {
+		client = &http.Client{}
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			client.Transport = t.Clone()
+		}
+	}
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	if user == "" {
+		user = currentUser()
+	}
+	return &FS{
+		endpoint:   *u,
+		uri:        scheme + "://" + u.Host,
+		user:       user,
+		client:     client,
+		noRedirect: &noRedirect,
+		ownClient:  own,
+		cwd:        "/user/" + user,
+	}, nil
+}
+
+func currentUser() string {
+	if name := os.Getenv("HADOOP_USER_NAME"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Disconnect closes the idle connections of fs; later operations fail with
+// os.ErrClosed.
+func (fs *FS) Disconnect() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.closed {
+		return &hdfs.PathError{Op: "disconnect", Path: "", Err: os.ErrClosed}
+	}
+	fs.closed = true
+	if fs.ownClient {
+		fs.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// abs resolves p, which may be a URI of the file system, relative to the
+// working directory.
+func (fs *FS) abs(p string) string {
+	if strings.Contains(p, "://") {
+		if u, err := url.Parse(p); err == nil {
+			p = u.Path
+		}
+	}
+	if !strings.HasPrefix(p, "/") {
+		fs.mu.Lock()
+		p = path.Join(fs.cwd, p)
+		fs.mu.Unlock()
+	}
+	return path.Clean(p)
+}
+
+// url returns the URL of the operation webop on the absolute path abs.
+func (fs *FS) url(webop, abs string, params url.Values) string {
+	u := fs.endpoint
+	u.Path += abs
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("op", webop)
+	if fs.user != "" {
+		params.Set("user.name", fs.user)
+	}
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// send sends req with client. Responses with an error status are turned
+// into a *hdfs.PathError for op on p.
+func (fs *FS) send(client *http.Client, op, p string, req *http.Request) (*http.Response, error) {
+	fs.mu.Lock()
+	closed := fs.closed
+	fs.mu.Unlock()
+	if closed {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: os.ErrClosed}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, &hdfs.PathError{Op: op, Path: p, Err: responseError(resp)}
+	}
+	return resp, nil
+}
+
+// responseError maps the RemoteException of an error response to the
+// errno libhdfs reports for it, falling back on the status code.
+func responseError(resp *http.Response) error {
+	var re remoteException
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(body, &re) == nil {
+		e := re.RemoteException
+		class := e.JavaClassName
+		if class == "" {
+			class = e.Exception
+		}
+		if class != "" {
+			msg := e.Message
+			if i := strings.IndexByte(msg, '\n'); i >= 0 {
+				msg = msg[:i]
+			}
+			switch errno := exception.ErrnoMessage(class, msg); errno {
+			case 0:
+				return fmt.Errorf("%w: %s: %s", hdfs.ErrInternal, class, msg)
+			case syscall.ENOTSUP:
+				return hdfs.ErrUnsupported
+			default:
+				return errno
+			}
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return syscall.ENOENT
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return syscall.EACCES
+	}
+	return fmt.Errorf("%w: %s", hdfs.ErrInternal, resp.Status)
+}
+
+// call makes the WebHDFS operation webop on path p with an HTTP method and
+// decodes the JSON response into v, unless nil. Failures are
+// *hdfs.PathError values for op.
+func (fs *FS) call(op, p, method, webop string, params url.Values, v interface{}) error {
+	req, err := http.NewRequest(method, fs.url(webop, fs.abs(p), params), nil)
+	if err != nil {
+		return &hdfs.PathError{Op: op, Path: p, Err: err}
+	}
+	resp, err := fs.send(fs.client, op, p, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return &hdfs.PathError{Op: op, Path: p, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	return nil
+}
+
+// boolCall makes a call answering a boolean; false is reported as ENOENT
+// if p does not exist and ErrInternal otherwise, as libhdfs does, or as the
+// error telling whether p exists failed with.
+func (fs *FS) boolCall(op, p, method, webop string, params url.Values) error {
+	var resp booleanResponse
+	if err := fs.call(op, p, method, webop, params, &resp); err != nil {
+		return err
+	}
+	if resp.Boolean {
+		return nil
+	}
+	switch err := fs.Exists(p); {
+	case errors.Is(err, os.ErrNotExist):
+		return &hdfs.PathError{Op: op, Path: p, Err: syscall.ENOENT}
+	case err != nil:
+		return err
+	}
+	return &hdfs.PathError{Op: op, Path: p, Err: hdfs.ErrInternal}
+}
+
+func millis(ms int64) time.Time {
+	return time.Unix(ms/1000, 0)
+}
+
+func (fs *FS) fileInfo(abs string, st *fileStatus) *hdfs.FileInfo {
+	perm, _ := strconv.ParseInt(st.Permission, 8, 16)
+	info := &hdfs.FileInfo{
+		Kind:        'F',
+		Name:        fs.uri + abs,
+		LastMod:     millis(st.ModificationTime),
+		Size:        st.Length,
+		Replication: st.Replication,
+		BlockSize:   st.BlockSize,
+		Owner:       st.Owner,
+		Group:       st.Group,
+		Permissions: int16(perm),
+		LastAccess:  millis(st.AccessTime),
+	}
+	if st.Type == "DIRECTORY" {
+		info.Kind = 'D'
+	}
+	return info
+}
+
+func (fs *FS) stat(op, p string) (*fileStatus, error) {
+	var resp fileStatusResponse
+	if err := fs.call(op, p, http.MethodGet, "GETFILESTATUS", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.FileStatus, nil
+}
+
+// Exists returns nil if path exists; otherwise the error satisfies
+// errors.Is(err, fs.ErrNotExist).
+func (fs *FS) Exists(path string) error {
+	_, err := fs.stat("exists", path)
+	return err
+}
+
+// GetPathInfo returns information about path.
+func (fs *FS) GetPathInfo(path string) (*hdfs.FileInfo, error) {
+	st, err := fs.stat("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.fileInfo(fs.abs(path), st), nil
+}
+
+// ListDirectory lists the entries of a directory, a batch at a time with
+// LISTSTATUS_BATCH, or at once with LISTSTATUS from servers that do not
+// know it. Listing a file returns the file itself.
+func (fs *FS) ListDirectory(path string) ([]*hdfs.FileInfo, error) {
+	abs := fs.abs(path)
+	fs.mu.Lock()
+	noBatch := fs.noBatch
+	fs.mu.Unlock()
+	var sts []fileStatus
+	if !noBatch {
+		params := url.Values{}
+		for {
+			var resp listStatusBatchResponse
+			err := fs.call("readdir", path, http.MethodGet, "LISTSTATUS_BATCH", params, &resp)
+			if len(sts) == 0 && errors.Is(err, syscall.EINVAL) {
+				// an older server, rejecting the op as an
+				// IllegalArgumentException; fall back on LISTSTATUS
+				fs.mu.Lock()
+				fs.noBatch = true
+				fs.mu.Unlock()
+				return fs.ListDirectory(path)
+			}
+			if err != nil {
+				return nil, err
+			}
+			batch := resp.DirectoryListing.PartialListing.FileStatuses.FileStatus
+			sts = append(sts, batch...)
+			if resp.DirectoryListing.RemainingEntries == 0 || len(batch) == 0 {
+				break
+			}
+			params.Set("startAfter", batch[len(batch)-1].PathSuffix)
+		}
+	} else {
+		var resp listStatusResponse
+		if err := fs.call("readdir", path, http.MethodGet, "LISTSTATUS", nil, &resp); err != nil {
+			return nil, err
+		}
+		sts = resp.FileStatuses.FileStatus
+	}
+	infos := make([]*hdfs.FileInfo, len(sts))
+	for i := range sts {
+		p := abs
+		if sts[i].PathSuffix != "" {
+			p = pathJoin(abs, sts[i].PathSuffix)
+		}
+		infos[i] = fs.fileInfo(p, &sts[i])
+	}
+	return infos, nil
+}
+
+// pathJoin is path.Join, for methods whose path parameter shadows the
+// package.
+func pathJoin(dir, name string) string {
+	return path.Join(dir, name)
+}
+
+// CreateDirectory creates path and its missing parents, with permissions
+// 0777 less the umask 022.
+func (fs *FS) CreateDirectory(path string) error {
+	params := url.Values{"permission": {strconv.FormatInt(0777&^defaultUmask, 8)}}
+	return fs.boolCall("mkdir", path, http.MethodPut, "MKDIRS", params)
+}
+
+// Rename moves oldpath to newpath, or into newpath if it is a directory.
+func (fs *FS) Rename(oldpath, newpath string) error {
+	params := url.Values{"destination": {fs.abs(newpath)}}
+	return fs.boolCall("rename", oldpath, http.MethodPut, "RENAME", params)
+}
+
+// Delete removes path, recursively if it is a directory.
+func (fs *FS) Delete(path string) error {
+	return fs.boolCall("delete", path, http.MethodDelete, "DELETE", url.Values{"recursive": {"true"}})
+}
+
+// Remove removes the file or empty directory path, failing with ENOTEMPTY
+// on a directory that is not empty.
+func (fs *FS) Remove(path string) error {
+	return fs.boolCall("remove", path, http.MethodDelete, "DELETE", url.Values{"recursive": {"false"}})
+}
+
+// SetReplication sets the replication of a file.
+func (fs *FS) SetReplication(path string, replication int16) error {
+	params := url.Values{"replication": {strconv.Itoa(int(replication))}}
+	return fs.boolCall("setrep", path, http.MethodPut, "SETREPLICATION", params)
+}
+
+// TruncateFile cuts the file path to newLength, reporting false while the
+// namenode recovers its last block.
+func (fs *FS) TruncateFile(path string, newLength int64) (bool, error) {
+	var resp booleanResponse
+	params := url.Values{"newlength": {strconv.FormatInt(newLength, 10)}}
+	if err := fs.call("truncate", path, http.MethodPost, "TRUNCATE", params, &resp); err != nil {
+		return false, err
+	}
+	return resp.Boolean, nil
+}
+
+// ConcatFiles moves the blocks of srcs to the end of target and deletes
+// srcs.
+func (fs *FS) ConcatFiles(target string, srcs []string) error {
+	abs := make([]string, len(srcs))
+	for i, src := range srcs {
+		abs[i] = fs.abs(src)
+	}
+	params := url.Values{"sources": {strings.Join(abs, ",")}}
+	return fs.call("concat", target, http.MethodPost, "CONCAT", params, nil)
+}
+
+// SetXAttr sets the extended attribute name of path to value, with flags
+// XATTR_CREATE, XATTR_REPLACE, or 0 for either.
+func (fs *FS) SetXAttr(path, name string, value []byte, flags int) error {
+	var set []string
+	if flags&hdfs.XATTR_CREATE != 0 || flags&hdfs.XATTR_REPLACE == 0 {
+		set = append(set, "CREATE")
+	}
+	if flags&hdfs.XATTR_REPLACE != 0 || flags&hdfs.XATTR_CREATE == 0 {
+		set = append(set, "REPLACE")
+	}
+	params := url.Values{
+		"xattr.name":  {name},
+		"xattr.value": {encodeXAttrValue(value, "hex")},
+		"flag":        {strings.Join(set, ",")},
+	}
+	return fs.call("setxattr", path, http.MethodPut, "SETXATTR", params, nil)
+}
+
+// GetXAttr returns the value of the extended attribute name of path.
+func (fs *FS) GetXAttr(path, name string) ([]byte, error) {
+	values, err := fs.GetXAttrs(path, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		return value, nil
+	}
+	return nil, &hdfs.PathError{Op: "getxattr", Path: path, Err: hdfs.ErrNoXAttr}
+}
+
+// GetXAttrs returns the values of the extended attributes names of path,
+// all of them if none is given.
+func (fs *FS) GetXAttrs(path string, names ...string) (map[string][]byte, error) {
+	params := url.Values{"encoding": {"hex"}}
+	if len(names) > 0 {
+		params["xattr.name"] = names
+	}
+	var resp xattrsResponse
+	if err := fs.call("getxattr", path, http.MethodGet, "GETXATTRS", params, &resp); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(resp.XAttrs))
+	for _, x := range resp.XAttrs {
+		value := []byte{}
+		if x.Value != nil {
+			var err error
+			if value, err = decodeXAttrValue(*x.Value); err != nil {
+				return nil, &hdfs.PathError{Op: "getxattr", Path: path, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+			}
+		}
+		values[x.Name] = value
+	}
+	return values, nil
+}
+
+// ListXAttrs returns the names of the extended attributes of path.
+func (fs *FS) ListXAttrs(path string) ([]string, error) {
+	var resp xattrNamesResponse
+	if err := fs.call("listxattr", path, http.MethodGet, "LISTXATTRS", nil, &resp); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	if err := json.Unmarshal([]byte(resp.XAttrNames), &names); err != nil {
+		return nil, &hdfs.PathError{Op: "listxattr", Path: path, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	return names, nil
+}
+
+// RemoveXAttr removes the extended attribute name of path.
+func (fs *FS) RemoveXAttr(path, name string) error {
+	return fs.call("removexattr", path, http.MethodPut, "REMOVEXATTR", url.Values{"xattr.name": {name}}, nil)
+}
+
+// encodeXAttrValue encodes the value of an extended attribute as WebHDFS
+// does with encoding "hex", as 0x and hexadecimal digits, "base64", as 0s
+// and base64, or "text", in double quotes.
+func encodeXAttrValue(value []byte, encoding string) string {
+	switch strings.ToLower(encoding) {
+	case "hex":
+		return "0x" + hex.EncodeToString(value)
+	case "base64":
+		return "0s" + base64.StdEncoding.EncodeToString(value)
+	}
+	return `"` + string(value) + `"`
+}
+
+// decodeXAttrValue decodes a value encoded by encodeXAttrValue, or plain
+// text.
+func decodeXAttrValue(s string) ([]byte, error) {
+	switch {
+	case len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X"):
+		return hex.DecodeString(s[2:])
+	case len(s) >= 2 && (s[:2] == "0s" || s[:2] == "0S"):
+		return base64.StdEncoding.DecodeString(s[2:])
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return []byte(s[1 : len(s)-1]), nil
+	}
+	return []byte(s), nil
+}
+
+// GetAclStatus returns the ACL of path.
+func (fs *FS) GetAclStatus(path string) (*hdfs.AclStatus, error) {
+	var resp aclStatusResponse
+	if err := fs.call("getacl", path, http.MethodGet, "GETACLSTATUS", nil, &resp); err != nil {
+		return nil, err
+	}
+	st := resp.AclStatus
+	perm, _ := strconv.ParseInt(st.Permission, 8, 16)
+	entries, err := hdfs.ParseAclSpec(strings.Join(st.Entries, ","))
+	if len(st.Entries) == 0 {
+		entries, err = nil, nil
+	}
+	if err != nil {
+		return nil, &hdfs.PathError{Op: "getacl", Path: path, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+	}
+	return &hdfs.AclStatus{Owner: st.Owner, Group: st.Group, Sticky: st.StickyBit, Permission: int16(perm), Entries: entries}, nil
+}
+
+// SetAcl replaces the entries of the scopes of entries in the ACL of path.
+func (fs *FS) SetAcl(path string, entries []hdfs.AclEntry) error {
+	return fs.call("setacl", path, http.MethodPut, "SETACL", url.Values{"aclspec": {hdfs.FormatAclSpec(entries)}}, nil)
+}
+
+// ModifyAclEntries adds entries to the ACL of path, replacing those of the
+// same scope, type and name.
+func (fs *FS) ModifyAclEntries(path string, entries []hdfs.AclEntry) error {
+	return fs.call("setacl", path, http.MethodPut, "MODIFYACLENTRIES", url.Values{"aclspec": {hdfs.FormatAclSpec(entries)}}, nil)
+}
+
+// RemoveAclEntries removes the entries of the scopes, types and names of
+// entries from the ACL of path.
+func (fs *FS) RemoveAclEntries(path string, entries []hdfs.AclEntry) error {
+	return fs.call("setacl", path, http.MethodPut, "REMOVEACLENTRIES", url.Values{"aclspec": {aclNames(entries)}}, nil)
+}
+
+// aclNames formats entries without their permissions, as the spec of
+// REMOVEACLENTRIES.
+func aclNames(entries []hdfs.AclEntry) string {
+	specs := make([]string, len(entries))
+	for i, e := range entries {
+		s := e.String()
+		specs[i] = s[:strings.LastIndexByte(s, ':')]
+	}
+	return strings.Join(specs, ",")
+}
+
+// RemoveDefaultAcl removes the default entries of the ACL of path.
+func (fs *FS) RemoveDefaultAcl(path string) error {
+	return fs.call("setacl", path, http.MethodPut, "REMOVEDEFAULTACL", nil, nil)
+}
+
+// RemoveAcl removes the entries of the ACL of path but those its
+// permission holds.
+func (fs *FS) RemoveAcl(path string) error {
+	return fs.call("setacl", path, http.MethodPut, "REMOVEACL", nil, nil)
+}
+
+// AllowSnapshot lets snapshots be taken of the directory path.
+func (fs *FS) AllowSnapshot(path string) error {
+	return fs.call("allowsnapshot", path, http.MethodPut, "ALLOWSNAPSHOT", nil, nil)
+}
+
+// DisallowSnapshot stops snapshots being taken of the directory path.
+func (fs *FS) DisallowSnapshot(path string) error {
+	return fs.call("disallowsnapshot", path, http.MethodPut, "DISALLOWSNAPSHOT", nil, nil)
+}
+
+// CreateSnapshot takes a snapshot of the directory path and returns its
+// path.
+func (fs *FS) CreateSnapshot(path, name string) (string, error) {
+	params := url.Values{}
+	if name != "" {
+		params.Set("snapshotname", name)
+	}
+	var resp pathResponse
+	if err := fs.call("createsnapshot", path, http.MethodPut, "CREATESNAPSHOT", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.Path, nil
+}
+
+// DeleteSnapshot deletes the snapshot name of the directory path.
+func (fs *FS) DeleteSnapshot(path, name string) error {
+	return fs.call("deletesnapshot", path, http.MethodDelete, "DELETESNAPSHOT", url.Values{"snapshotname": {name}}, nil)
+}
+
+// RenameSnapshot renames the snapshot oldName of the directory path.
+func (fs *FS) RenameSnapshot(path, oldName, newName string) error {
+	params := url.Values{"oldsnapshotname": {oldName}, "snapshotname": {newName}}
+	return fs.call("renamesnapshot", path, http.MethodPut, "RENAMESNAPSHOT", params, nil)
+}
+
+// ListSnapshottableDirs returns the directories snapshots are allowed on.
+func (fs *FS) ListSnapshottableDirs() ([]*hdfs.SnapshottableDir, error) {
+	var resp snapshottableDirListResponse
+	if err := fs.call("lssnapshottabledir", "/", http.MethodGet, "GETSNAPSHOTTABLEDIRECTORYLIST", nil, &resp); err != nil {
+		return nil, err
+	}
+	dirs := make([]*hdfs.SnapshottableDir, len(resp.SnapshottableDirectoryList))
+	for i, d := range resp.SnapshottableDirectoryList {
+		dirs[i] = &hdfs.SnapshottableDir{
+			Path:          path.Join(d.ParentFullPath, d.DirStatus.PathSuffix),
+			Snapshots:     d.SnapshotNumber,
+			SnapshotQuota: d.SnapshotQuota,
+		}
+	}
+	return dirs, nil
+}
+
+// diffEntryPath returns the path of a diffReportEntry relative to the
+// snapshot root, which WebHDFS sends as an empty path.
+func diffEntryPath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// SnapshotDiff returns the changes made to the directory path between its
+// snapshots from and to.
+func (fs *FS) SnapshotDiff(path, from, to string) (*hdfs.SnapshotDiffReport, error) {
+	var resp snapshotDiffReportResponse
+	params := url.Values{"oldsnapshotname": {from}, "snapshotname": {to}}
+	if err := fs.call("snapshotdiff", path, http.MethodGet, "GETSNAPSHOTDIFF", params, &resp); err != nil {
+		return nil, err
+	}
+	r := &hdfs.SnapshotDiffReport{Dir: fs.abs(path), From: from, To: to}
+	for _, e := range resp.SnapshotDiffReport.DiffList {
+		t, err := hdfs.ParseDiffType(e.Type)
+		if err != nil {
+			return nil, &hdfs.PathError{Op: "snapshotdiff", Path: path, Err: fmt.Errorf("%w: %v", hdfs.ErrInternal, err)}
+		}
+		entry := hdfs.SnapshotDiffEntry{Type: t, Path: diffEntryPath(e.SourcePath)}
+		if t == hdfs.DiffRename && e.TargetPath != nil {
+			entry.Target = diffEntryPath(*e.TargetPath)
+		}
+		r.Entries = append(r.Entries, entry)
+	}
+	return r, nil
+}
+
+// Chmod sets the permission bits of path.
+func (fs *FS) Chmod(path string, mode int16) error {
+	params := url.Values{"permission": {strconv.FormatInt(int64(mode)&01777, 8)}}
+	return fs.call("chmod", path, http.MethodPut, "SETPERMISSION", params, nil)
+}
+
+// Chown changes the owner and group of path; an empty owner or group is
+// left unchanged.
+func (fs *FS) Chown(path, owner, group string) error {
+	if owner == "" && group == "" {
+		return nil
+	}
+	params := url.Values{}
+	if owner != "" {
+		params.Set("owner", owner)
+	}
+	if group != "" {
+		params.Set("group", group)
+	}
+	return fs.call("chown", path, http.MethodPut, "SETOWNER", params, nil)
+}
+
+// Utime sets the modification and access times of path, at one second
+// precision.
+func (fs *FS) Utime(path string, mtime, atime time.Time) error {
+	params := url.Values{
+		"modificationtime": {strconv.FormatInt(mtime.Unix()*1000, 10)},
+		"accesstime":       {strconv.FormatInt(atime.Unix()*1000, 10)},
+	}
+	return fs.call("utime", path, http.MethodPut, "SETTIMES", params, nil)
+}
+
+// GetContentSummary returns the disk usage and quotas of the tree rooted at
+// path.
+func (fs *FS) GetContentSummary(path string) (*hdfs.ContentSummary, error) {
+	var resp contentSummaryResponse
+	if err := fs.call("du", path, http.MethodGet, "GETCONTENTSUMMARY", nil, &resp); err != nil {
+		return nil, err
+	}
+	s := resp.ContentSummary
+	cs := &hdfs.ContentSummary{Length: s.Length, FileCount: s.FileCount, DirectoryCount: s.DirectoryCount, Quota: s.Quota, SpaceConsumed: s.SpaceConsumed, SpaceQuota: s.SpaceQuota}
+	for name, tq := range s.TypeQuota {
+		t, err := hdfs.ParseStorageType(name)
+		if err != nil || tq.Quota == hdfs.QuotaReset {
+			continue // a storage type unknown to this client, or without quota
+		}
+		if cs.TypeQuotas == nil {
+			cs.TypeQuotas = map[hdfs.StorageType]hdfs.TypeQuota{}
+		}
+		cs.TypeQuotas[t] = hdfs.TypeQuota{Quota: tq.Quota, Consumed: tq.Consumed}
+	}
+	return cs, nil
+}
+
+// SetQuota sets the namespace and space quotas of the directory path.
+func (fs *FS) SetQuota(path string, nsQuota, ssQuota int64) error {
+	params := url.Values{
+		"namespacequota":    {strconv.FormatInt(nsQuota, 10)},
+		"storagespacequota": {strconv.FormatInt(ssQuota, 10)},
+	}
+	return fs.call("setquota", path, http.MethodPut, "SETQUOTA", params, nil)
+}
+
+// SetTypeQuota sets the space quota of the directory path on the storage
+// type t.
+func (fs *FS) SetTypeQuota(path string, t hdfs.StorageType, quota int64) error {
+	params := url.Values{
+		"storagetype":       {t.String()},
+		"storagespacequota": {strconv.FormatInt(quota, 10)},
+	}
+	return fs.call("setquota", path, http.MethodPut, "SETQUOTABYSTORAGETYPE", params, nil)
+}
+
+// ClearQuota clears the namespace and space quotas of the directory path.
+func (fs *FS) ClearQuota(path string) error {
+	return fs.SetQuota(path, hdfs.QuotaReset, hdfs.QuotaReset)
+}
+
+// TrashRoot returns the trash directory of the user for path, as the
+// server tells: .Trash/<user> at the root of the encryption zone of the
+// parent of path, or .Trash in the home directory.
+func (fs *FS) TrashRoot(path string) (string, error) {
+	var resp pathResponse
+	if err := fs.call("trashroot", path, http.MethodGet, "GETTRASHROOT", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Path, nil
+}
+
+// TrashRoots returns the trash directories of the user that exist. WebHDFS
+// cannot list encryption zones, so only the one in the home directory is
+// found.
+func (fs *FS) TrashRoots() ([]string, error) {
+	root := "/user/" + fs.user + "/.Trash"
+	switch err := fs.Exists(root); {
+	case errors.Is(err, syscall.ENOENT):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return []string{root}, nil
+}
+
+// TrashInterval returns fs.trash.interval of the server.
+func (fs *FS) TrashInterval() (time.Duration, error) {
+	var resp serverDefaultsResponse
+	if err := fs.call("trashinterval", "/", http.MethodGet, "GETSERVERDEFAULTS", nil, &resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.FsServerDefaults.TrashInterval) * time.Minute, nil
+}
+
+// GetFileChecksum returns the checksum of the content of a file, as
+// computed by its datanodes.
+func (fs *FS) GetFileChecksum(path string) (*hdfs.FileChecksum, error) {
+	var resp fileChecksumResponse
+	if err := fs.call("checksum", path, http.MethodGet, "GETFILECHECKSUM", nil, &resp); err != nil {
+		return nil, err
+	}
+	c := resp.FileChecksum
+	b, err := hex.DecodeString(c.Bytes)
+	if err != nil || len(b) != c.Length {
+		return nil, &hdfs.PathError{Op: "checksum", Path: path, Err: fmt.Errorf("%w: malformed checksum %q", hdfs.ErrInternal, c.Bytes)}
+	}
+	return &hdfs.FileChecksum{Algorithm: c.Algorithm, Bytes: b}, nil
+}
+
+// GetHosts returns, for every block of path overlapping the given range, the
+// host names of the datanodes holding its replicas.
+func (fs *FS) GetHosts(path string, start, length int64) ([][]string, error) {
+	if start < 0 || length < 0 {
+		return nil, &hdfs.PathError{Op: "gethosts", Path: path, Err: hdfs.ErrInternal}
+	}
+	params := url.Values{"offset": {strconv.FormatInt(start, 10)}, "length": {strconv.FormatInt(length, 10)}}
+	var resp blockLocationsResponse
+	if err := fs.call("gethosts", path, http.MethodGet, "GETFILEBLOCKLOCATIONS", params, &resp); err != nil {
+		return nil, err
+	}
+	hosts := [][]string{}
+	for _, b := range resp.BlockLocations.BlockLocation {
+		hosts = append(hosts, append([]string{}, b.Hosts...))
+	}
+	return hosts, nil
+}
+
+// GetWorkingDirectory copies the URI of the working directory, followed by
+// a NUL byte, into buffer.
+func (fs *FS) GetWorkingDirectory(buffer []byte, size uint32) ([]byte, error) {
+	fs.mu.Lock()
+	wd := fs.uri + fs.cwd
+	fs.mu.Unlock()
+	if len(wd)+1 > int(size) || len(wd)+1 > len(buffer) {
+		return nil, &hdfs.PathError{Op: "getwd", Path: "", Err: syscall.ERANGE}
+	}
+	buffer[copy(buffer, wd)] = 0
+	return buffer, nil
+}
+
+// SetWorkingDirectory sets the directory relative paths are resolved
+// against; it does not need to exist.
+func (fs *FS) SetWorkingDirectory(path string) error {
+	abs := fs.abs(path)
+	fs.mu.Lock()
+	fs.cwd = abs
+	fs.mu.Unlock()
+	return nil
+}
+
+// GetDefaultBlockSize returns the block size the server applies to files
+// created without one.
+func (fs *FS) GetDefaultBlockSize() (int64, error) {
+	var resp serverDefaultsResponse
+	if err := fs.call("blocksize", "/", http.MethodGet, "GETSERVERDEFAULTS", nil, &resp); err != nil {
+		return -1, err
+	}
+	return resp.FsServerDefaults.BlockSize, nil
+}
+
+func (fs *FS) status(op string) (*statusResponse, error) {
+	var resp statusResponse
+	if err := fs.call(op, "/", http.MethodGet, "GETSTATUS", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetCapacity returns the raw capacity of the file system.
+func (fs *FS) GetCapacity() (int64, error) {
+	st, err := fs.status("capacity")
+	if err != nil {
+		return -1, err
+	}
+	return st.FsStatus.Capacity, nil
+}
+
+// GetUsed returns the raw size of all files, replicas included.
+func (fs *FS) GetUsed() (int64, error) {
+	st, err := fs.status("used")
+	if err != nil {
+		return -1, err
+	}
+	return st.FsStatus.Used, nil
+}
+
+// GetRemaining returns the raw space the datanodes have left, which does
+// not count the space used outside HDFS.
+func (fs *FS) GetRemaining() (int64, error) {
+	st, err := fs.status("remaining")
+	if err != nil {
+		return -1, err
+	}
+	return st.FsStatus.Remaining, nil
+}
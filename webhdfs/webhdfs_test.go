@@ -0,0 +1,625 @@
+package webhdfs
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zyxar/hdfs"
+	"github.com/zyxar/hdfs/hdfstest"
+)
+
+// fakeServer serves the WebHDFS API over a MemFS, acting as both namenode
+// and datanode: data operations are redirected to /datanode on the same
+// server.
+type fakeServer struct {
+	m     *hdfstest.MemFS
+	batch int // entries per LISTSTATUS_BATCH, 0 rejecting the op, < 0 failing it
+
+	mu  sync.Mutex
+	ops []string
+}
+
+func (s *fakeServer) count(op string) (n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.ops {
+		if o == op {
+			n++
+		}
+	}
+	return n
+}
+
+func summarize(m hdfs.FileSystem, p string, s *hdfs.ContentSummary) error {
+	infos, err := m.ListDirectory(p)
+	if err != nil {
+		return err
+	}
+	info, err := m.GetPathInfo(p)
+	if err != nil {
+		return err
+	}
+	if info.Kind != 'D' {
+		s.FileCount++
+		s.Length += info.Size
+		s.SpaceConsumed += info.Size * int64(info.Replication)
+		return nil
+	}
+	s.DirectoryCount++
+	for _, info := range infos {
+		if err := summarize(m, path.Join(p, path.Base(info.Name)), s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	op := q.Get("op")
+	datanode := strings.HasPrefix(r.URL.Path, "/datanode/")
+	p := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/datanode"), apiPrefix)
+	m := s.m.AsUser(q.Get("user.name"))
+	s.mu.Lock()
+	s.ops = append(s.ops, op)
+	s.mu.Unlock()
+	if !datanode {
+		switch op {
+		case "OPEN", "CREATE", "APPEND", "GETFILECHECKSUM":
+			if op != "CREATE" {
+				if err := m.Exists(p); err != nil {
+					writeError(w, err)
+					return
+				}
+			}
+			http.Redirect(w, r, "/datanode"+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+	}
+	switch op {
+	case "OPEN":
+		f, err := m.Open(p, hdfs.O_RDONLY, 0, 0, 0)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer f.Close()
+		off, _ := strconv.ParseInt(q.Get("offset"), 10, 64)
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			writeError(w, err)
+			return
+		}
+		var rd io.Reader = f
+		if l := q.Get("length"); l != "" {
+			n, _ := strconv.ParseInt(l, 10, 64)
+			rd = io.LimitReader(f, n)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, rd)
+	case "CREATE", "APPEND":
+		flags := hdfs.O_WRONLY
+		if op == "APPEND" {
+			flags |= hdfs.O_APPEND
+		}
+		rep, _ := strconv.Atoi(q.Get("replication"))
+		bs, _ := strconv.ParseUint(q.Get("blocksize"), 10, 32)
+		f, err := m.Open(p, flags, 0, rep, uint32(bs))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if _, err := io.Copy(f, r.Body); err != nil {
+			f.Close()
+			writeError(w, err)
+			return
+		}
+		if err := f.Close(); err != nil {
+			writeError(w, err)
+			return
+		}
+		if op == "CREATE" {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "GETFILECHECKSUM":
+		f, err := m.Open(p, hdfs.O_RDONLY, 0, 0, 0)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer f.Close()
+		h := md5.New()
+		io.Copy(h, f)
+		var resp fileChecksumResponse
+		resp.FileChecksum.Algorithm = "MD5-of-0MD5-of-512CRC32C"
+		resp.FileChecksum.Bytes = hex.EncodeToString(h.Sum(nil))
+		resp.FileChecksum.Length = h.Size()
+		writeJSON(w, http.StatusOK, &resp)
+	case "GETFILESTATUS":
+		info, err := m.GetPathInfo(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &fileStatusResponse{toFileStatus(info, "")})
+	case "LISTSTATUS", "LISTSTATUS_BATCH":
+		if op == "LISTSTATUS_BATCH" && s.batch == 0 {
+			var re remoteException
+			re.RemoteException.JavaClassName = "java.lang.IllegalArgumentException"
+			re.RemoteException.Message = "Invalid value for webhdfs parameter \"op\""
+			writeJSON(w, http.StatusBadRequest, &re)
+			return
+		}
+		if op == "LISTSTATUS_BATCH" && s.batch < 0 {
+			var re remoteException
+			re.RemoteException.JavaClassName = "java.io.IOException"
+			re.RemoteException.Message = "Server busy"
+			writeJSON(w, http.StatusInternalServerError, &re)
+			return
+		}
+		infos, err := m.ListDirectory(p)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		dir, _ := m.GetPathInfo(p)
+		var sts []fileStatus
+		for _, info := range infos {
+			suffix := ""
+			if dir.Kind == 'D' {
+				suffix = path.Base(info.Name)
+			}
+			if suffix > q.Get("startAfter") || suffix == "" {
+				sts = append(sts, toFileStatus(info, suffix))
+			}
+		}
+		if op == "LISTSTATUS" {
+			writeJSON(w, http.StatusOK, &listStatusResponse{fileStatuses{sts}})
+			return
+		}
+		var resp listStatusBatchResponse
+		if len(sts) > s.batch {
+			resp.DirectoryListing.RemainingEntries = int64(len(sts) - s.batch)
+			sts = sts[:s.batch]
+		}
+		resp.DirectoryListing.PartialListing.FileStatuses.FileStatus = sts
+		writeJSON(w, http.StatusOK, &resp)
+	case "MKDIRS", "RENAME", "DELETE", "SETREPLICATION":
+		var err error
+		switch op {
+		case "MKDIRS":
+			err = m.CreateDirectory(p)
+		case "RENAME":
+			err = m.Rename(p, q.Get("destination"))
+		case "DELETE":
+			err = m.Delete(p)
+		case "SETREPLICATION":
+			rep, _ := strconv.Atoi(q.Get("replication"))
+			err = m.SetReplication(p, int16(rep))
+		}
+		if errors.Is(err, syscall.EACCES) {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &booleanResponse{err == nil})
+	case "SETPERMISSION", "SETOWNER", "SETTIMES":
+		var err error
+		switch op {
+		case "SETPERMISSION":
+			mode, _ := strconv.ParseInt(q.Get("permission"), 8, 16)
+			err = m.Chmod(p, int16(mode))
+		case "SETOWNER":
+			err = m.Chown(p, q.Get("owner"), q.Get("group"))
+		case "SETTIMES":
+			mtime, _ := strconv.ParseInt(q.Get("modificationtime"), 10, 64)
+			atime, _ := strconv.ParseInt(q.Get("accesstime"), 10, 64)
+			err = m.Utime(p, time.Unix(0, mtime*1e6), time.Unix(0, atime*1e6))
+		}
+		if err != nil {
+			writeError(w, err)
+		}
+	case "GETCONTENTSUMMARY":
+		var sum hdfs.ContentSummary
+		if err := summarize(m, p, &sum); err != nil {
+			writeError(w, err)
+			return
+		}
+		var resp contentSummaryResponse
+		resp.ContentSummary.DirectoryCount = sum.DirectoryCount
+		resp.ContentSummary.FileCount = sum.FileCount
+		resp.ContentSummary.Length = sum.Length
+		resp.ContentSummary.Quota = -1
+		resp.ContentSummary.SpaceConsumed = sum.SpaceConsumed
+		resp.ContentSummary.SpaceQuota = -1
+		writeJSON(w, http.StatusOK, &resp)
+	case "GETFILEBLOCKLOCATIONS":
+		off, _ := strconv.ParseInt(q.Get("offset"), 10, 64)
+		length, _ := strconv.ParseInt(q.Get("length"), 10, 64)
+		hosts, err := m.GetHosts(p, off, length)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		var resp blockLocationsResponse
+		for _, h := range hosts {
+			resp.BlockLocations.BlockLocation = append(resp.BlockLocations.BlockLocation, blockLocation{Hosts: h})
+		}
+		writeJSON(w, http.StatusOK, &resp)
+	case "GETSERVERDEFAULTS":
+		var resp serverDefaultsResponse
+		resp.FsServerDefaults.BlockSize, _ = m.GetDefaultBlockSize()
+		writeJSON(w, http.StatusOK, &resp)
+	case "GETSTATUS":
+		var resp statusResponse
+		resp.FsStatus.Capacity, _ = m.GetCapacity()
+		resp.FsStatus.Used, _ = m.GetUsed()
+		resp.FsStatus.Remaining, _ = m.GetRemaining()
+		writeJSON(w, http.StatusOK, &resp)
+	default:
+		writeError(w, hdfs.ErrUnsupported)
+	}
+}
+
+func connectFake(t *testing.T, user string) (*fakeServer, *FS) {
+	s := &fakeServer{m: hdfstest.NewMemFS(), batch: 2}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	c, err := Connect(srv.URL, user)
+	if err != nil {
+		t.Fatalf("Error on connecting: %v\n", err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return s, c
+}
+
+func writeFile(t *testing.T, c hdfs.FileSystem, path, content string) {
+	file, err := c.Open(path, hdfs.O_WRONLY|hdfs.O_CREATE, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for writing: %v\n", path, err)
+	}
+	if _, err = io.WriteString(file, content); err != nil {
+		t.Fatalf("Error on writing %s: %v\n", path, err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatalf("Error on closing %s: %v\n", path, err)
+	}
+}
+
+func readFile(t *testing.T, c hdfs.FileSystem, path string) string {
+	file, err := c.Open(path, hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening %s for reading: %v\n", path, err)
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Error on reading %s: %v\n", path, err)
+	}
+	return string(b)
+}
+
+func TestConnect(t *testing.T) {
+	for uri, want := range map[string]string{
+		"webhdfs://nn:9870":                      "http://nn:9870/webhdfs/v1",
+		"swebhdfs://nn:9871":                     "https://nn:9871/webhdfs/v1",
+		"http://gw:14000/":                       "http://gw:14000/webhdfs/v1",
+		"https://knox/gateway/c1/webhdfs/v1?x=1": "https://knox/gateway/c1/webhdfs/v1",
+	} {
+		c, err := Connect(uri, "alice")
+		if err != nil {
+			t.Errorf("Error on connecting to %s: %v\n", uri, err)
+			continue
+		}
+		if got := c.endpoint.String(); got != want {
+			t.Errorf("Endpoint of %s: got %s, want %s\n", uri, got, want)
+		}
+	}
+	if _, err := Connect("hdfs://nn:8020", "alice"); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Connect to an hdfs URI: got %v, want ErrUnsupported\n", err)
+	}
+	t.Setenv("HADOOP_USER_NAME", "bob")
+	c, _ := Connect("webhdfs://nn:9870", "")
+	wd, err := c.GetWorkingDirectory(make([]byte, 64), 64)
+	if err != nil || !strings.HasPrefix(string(wd), "webhdfs://nn:9870/user/bob\x00") {
+		t.Errorf("GetWorkingDirectory: got %q, %v\n", wd, err)
+	}
+	if _, err := c.GetWorkingDirectory(make([]byte, 8), 8); !errors.Is(err, syscall.ERANGE) {
+		t.Errorf("GetWorkingDirectory into a short buffer: got %v, want ERANGE\n", err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	s, c := connectFake(t, hdfstest.Superuser)
+	writeFile(t, c, "/tmp/a.txt", "hello, ")
+
+	file, err := c.OpenFile("/tmp/a.txt", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for appending: %v\n", err)
+	}
+	io.WriteString(file, "world")
+	if err := file.Flush(); err != nil {
+		t.Errorf("Error on flushing: %v\n", err)
+	}
+	if got := readFile(t, c, "/tmp/a.txt"); got != "hello, world" {
+		t.Errorf("Content after Flush: got %q\n", got)
+	}
+	io.WriteString(file, "!")
+	if err := file.Hsync(); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Hsync: got %v, want ErrUnsupported\n", err)
+	}
+	if got := readFile(t, c, "/tmp/a.txt"); got != "hello, world" {
+		t.Errorf("Content after Hsync: got %q\n", got)
+	}
+	if err := file.Flush(); err != nil {
+		t.Errorf("Error on flushing: %v\n", err)
+	}
+	if got := readFile(t, c, "/tmp/a.txt"); got != "hello, world!" {
+		t.Errorf("Content after Flush: got %q\n", got)
+	}
+	if pos, err := file.Seek(0, io.SeekCurrent); pos != 13 || err != nil {
+		t.Errorf("Writer offset: got %d, %v; want 13\n", pos, err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Seek on a writer: got %v, want EBADF\n", err)
+	}
+	if _, err = file.Read(make([]byte, 1)); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Read on a writer: got %v, want EINVAL\n", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Errorf("Error on closing: %v\n", err)
+	}
+	if _, err := file.Write([]byte("x")); err != os.ErrClosed {
+		t.Errorf("Write after Close: got %v, want os.ErrClosed\n", err)
+	}
+	if s.count("APPEND") != 4 {
+		t.Errorf("APPEND requests: got %d, want 4\n", s.count("APPEND"))
+	}
+
+	r, err := c.Open("/tmp/a.txt", hdfs.O_RDONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening file for reading: %v\n", err)
+	}
+	defer r.Close()
+	b := make([]byte, 5)
+	if n, err := r.ReadAt(b, 7); n != 5 || err != nil || string(b) != "world" {
+		t.Errorf("ReadAt: got %q, %v\n", b[:n], err)
+	}
+	if n, err := r.ReadAt(b, 10); n != 3 || err != io.EOF || string(b[:n]) != "ld!" {
+		t.Errorf("ReadAt across the end: got %q, %v\n", b[:n], err)
+	}
+	if pos, err := r.Seek(-6, io.SeekEnd); pos != 7 || err != nil {
+		t.Errorf("Seek from the end: got %d, %v\n", pos, err)
+	}
+	if rest, err := ioutil.ReadAll(r); string(rest) != "world!" || err != nil {
+		t.Errorf("Read after Seek: got %q, %v\n", rest, err)
+	}
+	if _, err := r.Seek(14, io.SeekStart); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Seek beyond the end: got %v, want ErrInternal\n", err)
+	}
+	if _, err := r.Write(b); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Write on a reader: got %v, want EINVAL\n", err)
+	}
+	if err := r.Flush(); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Flush on a reader: got %v, want EBADF\n", err)
+	}
+
+	writeFile(t, c, "/tmp/a.txt", "truncated")
+	if got := readFile(t, c, "/tmp/a.txt"); got != "truncated" {
+		t.Errorf("Content after overwriting: got %q\n", got)
+	}
+	if _, err := c.Open("/tmp/a.txt", syscall.O_RDWR, 0, 0, 0); !errors.Is(err, hdfs.ErrUnsupported) {
+		t.Errorf("Open with O_RDWR: got %v, want ErrUnsupported\n", err)
+	}
+	if _, err := c.Open("/tmp", hdfs.O_RDONLY, 0, 0, 0); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Open of a directory: got %v, want ErrInternal\n", err)
+	}
+	if _, err := c.Open("/tmp/missing", hdfs.O_WRONLY|hdfs.O_APPEND, 0, 0, 0); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Append to a missing file: got %v, want ENOENT\n", err)
+	}
+	w, err := c.Open("/tmp", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening a directory for writing: %v\n", err)
+	}
+	w.Write([]byte("x"))
+	if err := w.Close(); !errors.Is(err, hdfs.ErrInternal) {
+		t.Errorf("Close of a failed upload: got %v, want ErrInternal\n", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	s, c := connectFake(t, hdfstest.Superuser)
+	if err := c.CreateDirectory("/a/b"); err != nil {
+		t.Fatalf("Error on creating a directory: %v\n", err)
+	}
+	writeFile(t, c, "/a/b/f", "0123456789")
+	info, err := c.GetPathInfo("/a/b/f")
+	if err != nil {
+		t.Fatalf("Error on GetPathInfo: %v\n", err)
+	}
+	if info.Kind != 'F' || info.Size != 10 || info.Name != c.uri+"/a/b/f" || info.Permissions != 0644 || info.Owner != hdfstest.Superuser {
+		t.Errorf("GetPathInfo: got %+v\n", info)
+	}
+	if err := c.Chmod("/a/b/f", 0600); err != nil {
+		t.Errorf("Error on Chmod: %v\n", err)
+	}
+	if err := c.Chown("/a/b/f", "alice", "staff"); err != nil {
+		t.Errorf("Error on Chown: %v\n", err)
+	}
+	mtime := time.Unix(1500000000, 0)
+	if err := c.Utime("/a/b/f", mtime, mtime); err != nil {
+		t.Errorf("Error on Utime: %v\n", err)
+	}
+	if err := c.SetReplication("/a/b/f", 2); err != nil {
+		t.Errorf("Error on SetReplication: %v\n", err)
+	}
+	info, _ = c.GetPathInfo("/a/b/f")
+	if info.Permissions != 0600 || info.Owner != "alice" || info.Group != "staff" || !info.LastMod.Equal(mtime) || info.Replication != 2 {
+		t.Errorf("GetPathInfo after changes: got %+v\n", info)
+	}
+	sum, err := c.GetContentSummary("/a")
+	if err != nil || !reflect.DeepEqual(*sum, hdfs.ContentSummary{Length: 10, FileCount: 1, DirectoryCount: 2, Quota: -1, SpaceConsumed: 20, SpaceQuota: -1}) {
+		t.Errorf("GetContentSummary: got %+v, %v\n", sum, err)
+	}
+	want := md5.Sum([]byte("0123456789"))
+	if ck, err := c.GetFileChecksum("/a/b/f"); err != nil || ck.Algorithm != "MD5-of-0MD5-of-512CRC32C" || string(ck.Bytes) != string(want[:]) {
+		t.Errorf("GetFileChecksum: got %+v, %v\n", ck, err)
+	}
+	if hosts, err := c.GetHosts("/a/b/f", 0, 10); err != nil || !reflect.DeepEqual(hosts, [][]string{{"localhost"}}) {
+		t.Errorf("GetHosts: got %v, %v\n", hosts, err)
+	}
+	if bs, err := c.GetDefaultBlockSize(); bs != 64<<20 || err != nil {
+		t.Errorf("GetDefaultBlockSize: got %d, %v\n", bs, err)
+	}
+	if used, err := c.GetUsed(); used != 20 || err != nil {
+		t.Errorf("GetUsed: got %d, %v\n", used, err)
+	}
+	if capacity, err := c.GetCapacity(); capacity <= 0 || err != nil {
+		t.Errorf("GetCapacity: got %d, %v\n", capacity, err)
+	}
+	s.m.SetNonDFSUsed(5)
+	if remaining, err := c.GetRemaining(); remaining != 1<<40-25 || err != nil {
+		t.Errorf("GetRemaining: got %d, %v\n", remaining, err)
+	}
+
+	if err := c.SetWorkingDirectory("/a"); err != nil {
+		t.Errorf("Error on SetWorkingDirectory: %v\n", err)
+	}
+	if err := c.Rename("b/f", "/a/g"); err != nil {
+		t.Errorf("Error on Rename: %v\n", err)
+	}
+	if err := c.Exists("webhdfs://ignored/a/g"); err != nil {
+		t.Errorf("Exists after Rename: %v\n", err)
+	}
+	if err := c.Rename("b/f", "/a/h"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Rename of a missing file: got %v, want ENOENT\n", err)
+	}
+	if err := c.Delete("/a"); err != nil {
+		t.Errorf("Error on Delete: %v\n", err)
+	}
+	if _, err := c.GetPathInfo("/a"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetPathInfo after Delete: got %v, want ENOENT\n", err)
+	}
+	if err := c.Delete("/a"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Delete of a missing path: got %v, want ENOENT\n", err)
+	}
+}
+
+func TestListDirectory(t *testing.T) {
+	s, c := connectFake(t, hdfstest.Superuser)
+	var want []string
+	for _, name := range []string{"e", "a", "d", "c", "b"} {
+		writeFile(t, c, "/dir/"+name, name)
+		want = append(want, c.uri+"/dir/"+name)
+	}
+	sort.Strings(want)
+	list := func() []string {
+		infos, err := c.ListDirectory("/dir")
+		if err != nil {
+			t.Fatalf("Error on ListDirectory: %v\n", err)
+		}
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name)
+		}
+		return names
+	}
+	if got := list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDirectory: got %v, want %v\n", got, want)
+	}
+	if n := s.count("LISTSTATUS_BATCH"); n != 3 {
+		t.Errorf("LISTSTATUS_BATCH requests: got %d, want 3\n", n)
+	}
+	if infos, err := c.ListDirectory("/dir/a"); err != nil || len(infos) != 1 || infos[0].Name != c.uri+"/dir/a" {
+		t.Errorf("ListDirectory of a file: got %v, %v\n", infos, err)
+	}
+	if _, err := c.ListDirectory("/missing"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("ListDirectory of a missing path: got %v, want ENOENT\n", err)
+	}
+
+	s.batch = -1
+	if _, err := c.ListDirectory("/dir"); err == nil || c.noBatch {
+		t.Errorf("ListDirectory on a server error: got %v, falling back %v\n", err, c.noBatch)
+	}
+	s.batch = 0
+	if got := list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDirectory with LISTSTATUS: got %v, want %v\n", got, want)
+	}
+	list()
+	if n := s.count("LISTSTATUS"); n != 2 || !c.noBatch {
+		t.Errorf("LISTSTATUS requests: got %d, want 2\n", n)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s, c := connectFake(t, "alice")
+	s.m.CreateDirectory("/private")
+	s.m.Chmod("/private", 0700)
+	if _, err := c.ListDirectory("/private"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("ListDirectory without permission: got %v, want EACCES\n", err)
+	}
+	if err := c.Chmod("/private", 0777); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Chmod without permission: got %v, want EACCES\n", err)
+	}
+	w, err := c.Open("/private/f", hdfs.O_WRONLY, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Error on opening a file for writing: %v\n", err)
+	}
+	w.Write([]byte("data"))
+	if err := w.Close(); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Close of a denied upload: got %v, want EACCES\n", err)
+	}
+	var pe *hdfs.PathError
+	if _, err := c.GetPathInfo("/nothing"); !errors.As(err, &pe) || pe.Op != "stat" || pe.Path != "/nothing" || pe.Err != syscall.ENOENT {
+		t.Errorf("GetPathInfo of a missing path: got %#v\n", err)
+	}
+	if err := c.Disconnect(); err != nil {
+		t.Errorf("Error on Disconnect: %v\n", err)
+	}
+	if err := c.Exists("/"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Exists after Disconnect: got %v, want os.ErrClosed\n", err)
+	}
+
+	// a false answer, with the path not known to exist or not
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("op") == "GETFILESTATUS" {
+			writeError(w, syscall.EACCES)
+			return
+		}
+		writeJSON(w, http.StatusOK, &booleanResponse{false})
+	}))
+	defer srv.Close()
+	tr := &idleCounter{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	c, _ = ConnectClient(srv.URL, "alice", &http.Client{Transport: tr})
+	if err := c.Rename("/a", "/b"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Rename failing with an unreadable path: got %v, want EACCES\n", err)
+	}
+	c.Disconnect()
+	if tr.closes != 0 {
+		t.Errorf("Disconnect closed the idle connections of the client given\n")
+	}
+}
+
+// idleCounter is a transport counting the calls to CloseIdleConnections.
+type idleCounter struct {
+	*http.Transport
+	closes int
+}
+
+func (t *idleCounter) CloseIdleConnections() {
+	t.closes++
+	t.Transport.CloseIdleConnections()
+}
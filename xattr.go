@@ -0,0 +1,47 @@
+package hdfs
+
+import (
+	"strings"
+	"syscall"
+)
+
+// Flags of SetXAttr, as XAttrSetFlag. With neither, or both, the attribute
+// is created if missing and replaced otherwise.
+const (
+	XATTR_CREATE  = 1 // fail with fs.ErrExist if the attribute exists
+	XATTR_REPLACE = 2 // fail with ErrNoXAttr if the attribute is missing
+)
+
+// xattrNamespaces are the prefixes of the names of extended attributes, in
+// the order of XAttrNamespaceProto.
+var xattrNamespaces = []string{"user.", "trusted.", "security.", "system.", "raw."}
+
+// splitXAttrName splits the name of an extended attribute into its
+// namespace, an index of xattrNamespaces, and the rest. HDFS matches the
+// prefix regardless of case, and keeps the system namespace for itself;
+// other names fail with EINVAL.
+func splitXAttrName(op, path, name string) (int, string, error) {
+	for ns, prefix := range xattrNamespaces {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) && prefix != "system." {
+			return ns, name[len(prefix):], nil
+		}
+	}
+	return 0, "", &PathError{op, path, syscall.EINVAL}
+}
+
+// xattrName joins the namespace and the rest of the name of an extended
+// attribute, as split by splitXAttrName.
+func xattrName(ns int, name string) string {
+	if ns < 0 || ns >= len(xattrNamespaces) {
+		return name
+	}
+	return xattrNamespaces[ns] + name
+}
+
+// xattrFlags returns the flags SetXAttr is called with, both for neither.
+func xattrFlags(flags int) int {
+	if flags&(XATTR_CREATE|XATTR_REPLACE) == 0 {
+		return XATTR_CREATE | XATTR_REPLACE
+	}
+	return flags & (XATTR_CREATE | XATTR_REPLACE)
+}